@@ -2,6 +2,10 @@ package cmd
 
 import (
 	"context"
+	"fmt"
+	"net"
+	"net/http"
+	"os"
 
 	log "github.com/sirupsen/logrus"
 
@@ -24,19 +28,71 @@ import (
 	_ "github.com/distribution/distribution/v3/registry/storage/driver/swift"
 
 	// then init() the custom drivers
-	_ "github.com/forta-network/disco/drivers/ipfs"
+	"github.com/forta-network/disco/drivers/ipfs"
 	_ "github.com/forta-network/disco/drivers/r2"
 
 	"github.com/forta-network/disco/config"
+	"github.com/forta-network/disco/deps"
 	"github.com/forta-network/disco/proxy"
+	"github.com/forta-network/disco/proxy/services"
+	"github.com/forta-network/disco/systemd"
+	"github.com/forta-network/disco/utils"
 )
 
-// Main executes the main command.
+// Main executes the main command or the subcommand given in the arguments.
 func Main(ctx context.Context) {
-	if err := config.Init(); err != nil {
+	// the registry logs with the standard logger, too
+	log.AddHook(utils.RedactHook{})
+	if len(os.Args) > 1 {
+		if err := runCommand(ctx, os.Args[1:]); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+		return
+	}
+
+	cfg, err := config.Init()
+	if err != nil {
 		log.WithError(err).Fatal("failed to initialize the config")
 	}
-	registry, err := registry.NewRegistry(ctx, config.DistributionConfig)
+	cfg.ApplyLogging()
+	tracer, err := cfg.Tracer()
+	if err != nil {
+		log.WithError(err).Fatal("failed to initialize the tracer")
+	}
+	if tracer != nil {
+		utils.SetTracer(tracer)
+		go tracer.Run(ctx)
+	}
+	ipfsClient := deps.New(cfg)
+	ipfs.SetDependencies(cfg, ipfsClient)
+	go cfg.Watch(ctx)
+	discoService := services.NewDiscoService(cfg, ipfsClient)
+	if cfg.PruneUploads.Enabled {
+		go discoService.RunUploadPruner(ctx)
+	}
+	if cfg.DigestRetention.Enabled {
+		go discoService.RunDigestRepoPruner(ctx)
+	}
+	if cfg.Snapshots.Enabled {
+		go discoService.RunSnapshots(ctx)
+	}
+	if cfg.PublishRoot.Enabled {
+		go discoService.RunRootPublisher(ctx)
+	}
+	if cfg.Warmup.Enabled {
+		go discoService.RunWarmup(ctx)
+	}
+	if cfg.UsageAlerts.Enabled {
+		go discoService.RunUsageAlerts(ctx)
+	}
+	if cfg.UsageStats.Enabled {
+		go discoService.RunUsageStatsExporter(ctx)
+	}
+	if err := discoService.ShareHTTPSecret(ctx); err != nil {
+		log.WithError(err).Fatal("failed to share the http secret")
+	}
+	registry, err := registry.NewRegistry(ctx, cfg.Distribution)
 	if err != nil {
 		log.WithError(err).Fatal("failed to initialize the registry")
 	}
@@ -44,15 +100,63 @@ func Main(ctx context.Context) {
 		_ = registry.ListenAndServe()
 	}()
 
-	proxyServer, err := proxy.New()
+	addrs := registryAddrs{cfg.Distribution.HTTP.Addr: "the default registry"}
+	vhosts, err := startVirtualHosts(ctx, cfg, addrs)
+	if err != nil {
+		log.WithError(err).Fatal("failed to start the virtual hosts")
+	}
+	tenants, err := startTenants(ctx, cfg, addrs)
+	if err != nil {
+		log.WithError(err).Fatal("failed to start the tenants")
+	}
+	// the registries set the logging from their configs
+	cfg.ApplyLogging()
+
+	proxyServer, err := proxy.New(cfg, discoService, vhosts, tenants)
 	if err != nil {
 		log.WithError(err).Panic("failed to create the disco proxy server")
 	}
+	adminServer, err := proxy.NewAdmin(cfg, discoService)
+	if err != nil {
+		log.WithError(err).Fatal("failed to create the disco admin server")
+	}
+	if adminServer != nil {
+		go func() {
+			log.WithField("address", adminServer.Addr).Info("serving the admin api")
+			if err := adminServer.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+				log.WithError(err).Fatal("admin server stopped")
+			}
+		}()
+	}
+	listeners, err := proxyListeners(cfg, proxyServer)
+	if err != nil {
+		log.WithError(err).Fatal("failed to listen for the disco proxy server")
+	}
+	if systemd.CanNotify() {
+		go notifyWhenReady(ctx, cfg)
+	}
 	go func() {
 		<-ctx.Done()
+		_ = systemd.Notify("STOPPING=1")
 		_ = proxyServer.Close()
+		if adminServer != nil {
+			_ = adminServer.Close()
+		}
 	}()
-	if err := proxyServer.ListenAndServe(); err != nil {
+	// the server stops serving on all of the listeners when it is closed
+	serveErrs := make(chan error, len(listeners))
+	for _, listener := range listeners {
+		go func(listener net.Listener) {
+			if proxyServer.TLSConfig != nil {
+				serveErrs <- proxyServer.ServeTLS(listener, "", "")
+			} else {
+				serveErrs <- proxyServer.Serve(listener)
+			}
+		}(listener)
+	}
+	err = <-serveErrs
+	_ = proxyServer.Close()
+	if err != nil {
 		log.WithError(err).Warn("proxy stopped")
 	}
 }
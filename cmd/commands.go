@@ -0,0 +1,99 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+)
+
+// command is a subcommand of the disco binary.
+type command struct {
+	usage string
+	run   func(ctx context.Context, args []string) error
+}
+
+var commands = map[string]*command{
+	"config": {
+		usage: "config validate [path]",
+		run:   runConfig,
+	},
+	"diff": {
+		usage: "diff [-config path] [-path root] [-token token] [-json] [<store|admin-url> <store|admin-url>]",
+		run:   runDiff,
+	},
+	"export": {
+		usage: "export [-config path] [-format docker|oci] [-o path] <repo|cid>",
+		run:   runExport,
+	},
+	"gc": {
+		usage: "gc [-config path] [-dry-run] [-older-than duration]",
+		run:   runGC,
+	},
+	"import": {
+		usage: "import [-config path] [-ref name] <oci-layout-dir|tarball>",
+		run:   runImport,
+	},
+	"init": {
+		usage: "init [-ipfs url] [-cache dir] [-htpasswd user:password] [-tls host] [-force] [path]",
+		run:   runInit,
+	},
+	"inspect": {
+		usage: "inspect [-config path] [-json] <repo|cid>",
+		run:   runInspect,
+	},
+	"pin": {
+		usage: "pin [-config path] <cid>...",
+		run:   runPin,
+	},
+	"prune-uploads": {
+		usage: "prune-uploads [-config path] [-dry-run] [-older-than duration]",
+		run:   runPruneUploads,
+	},
+	"repo": {
+		usage: "repo ls [-config path] [-json]",
+		run:   runRepo,
+	},
+	"restore": {
+		usage: "restore [-config path] [-node index] [-warm-cache] <root-cid|snapshot-id>",
+		run:   runRestore,
+	},
+	"status": {
+		usage: "status [-config path] [-timeout duration] [-usage]",
+		run:   runStatus,
+	},
+	"unpin": {
+		usage: "unpin [-config path] <cid>...",
+		run:   runUnpin,
+	},
+	"verify": {
+		usage: "verify [-config path] [-repair] [repo...]",
+		run:   runVerify,
+	},
+}
+
+// runCommand runs the subcommand named by the first argument.
+func runCommand(ctx context.Context, args []string) error {
+	cmd, ok := commands[args[0]]
+	if !ok {
+		return fmt.Errorf("unknown command '%s'\n\n%s", args[0], usage())
+	}
+	return cmd.run(ctx, args[1:])
+}
+
+func usage() string {
+	var lines []string
+	for _, cmd := range commands {
+		lines = append(lines, "  disco "+cmd.usage)
+	}
+	sort.Strings(lines)
+	return "usage:\n  disco\n" + strings.Join(lines, "\n")
+}
+
+func configPathArg(args []string) string {
+	if len(args) > 0 {
+		return args[0]
+	}
+	return os.Getenv("REGISTRY_CONFIGURATION_PATH")
+}
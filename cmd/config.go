@@ -0,0 +1,49 @@
+package cmd
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+
+	"github.com/forta-network/disco/config"
+)
+
+func runConfig(ctx context.Context, args []string) error {
+	if len(args) == 0 {
+		return errors.New("usage: disco config validate|print-effective [path]")
+	}
+	switch args[0] {
+	case "validate":
+		return validateConfig(configPathArg(args[1:]))
+	case "print-effective":
+		return printEffectiveConfig(configPathArg(args[1:]))
+	default:
+		return fmt.Errorf("unknown config command '%s'", args[0])
+	}
+}
+
+func validateConfig(configPath string) error {
+	configPath, err := config.ResolvePath(configPath)
+	if err != nil {
+		return err
+	}
+	if err := config.Validate(configPath, os.Getenv("DISCO_PROFILE")); err != nil {
+		return err
+	}
+	fmt.Printf("%s is valid\n", configPath)
+	return nil
+}
+
+func printEffectiveConfig(configPath string) error {
+	configPath, err := config.ResolvePath(configPath)
+	if err != nil {
+		return err
+	}
+	b, err := config.Effective(configPath, os.Getenv("DISCO_PROFILE"))
+	if err != nil {
+		return err
+	}
+	_, err = os.Stdout.Write(b)
+	return err
+}
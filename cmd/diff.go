@@ -0,0 +1,146 @@
+package cmd
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+
+	storagedriver "github.com/distribution/distribution/v3/registry/storage/driver"
+	"github.com/forta-network/disco/config"
+	"github.com/forta-network/disco/drivers"
+	"github.com/forta-network/disco/drivers/multidriver"
+	"github.com/forta-network/disco/proxy/services"
+)
+
+func runDiff(ctx context.Context, args []string) error {
+	flags := flag.NewFlagSet("diff", flag.ContinueOnError)
+	configPath := flags.String("config", "", "config file path")
+	root := flags.String("path", registryRoot, "root of the compared trees")
+	token := flags.String("token", os.Getenv("DISCO_ADMIN_TOKEN"), "admin token of the Disco instances")
+	jsonOutput := flags.Bool("json", false, "print as JSON")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+	sides := flags.Args()
+	switch len(sides) {
+	case 0:
+		sides = []string{services.StorePrimary, services.StoreSecondary}
+	case 2:
+	default:
+		return errors.New("usage: disco diff [-config path] [-path root] [-token token] [-json] [<store|admin-url> <store|admin-url>]")
+	}
+
+	var (
+		trees      [2][]*drivers.TreeFile
+		httpClient *http.Client
+	)
+	for i, side := range sides {
+		var err error
+		if strings.HasPrefix(side, "http://") || strings.HasPrefix(side, "https://") {
+			// the admin APIs are requested with the client TLS config
+			if httpClient == nil {
+				cfg, err := config.InitWithPath(*configPath)
+				if err != nil {
+					return err
+				}
+				httpClient = cfg.HTTPClient()
+			}
+			trees[i], err = fetchTree(ctx, httpClient, side, *root, *token)
+		} else {
+			trees[i], err = walkLocalTree(ctx, *configPath, side, *root)
+		}
+		if err != nil {
+			return fmt.Errorf("failed to walk %s: %v", side, err)
+		}
+	}
+	diff := drivers.DiffTrees(trees[0], trees[1])
+
+	if *jsonOutput {
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		if err := enc.Encode(diff); err != nil {
+			return err
+		}
+	} else {
+		for _, file := range diff.OnlyInFirst {
+			fmt.Printf("- %s (%d bytes)\n", file.Path, file.Size)
+		}
+		for _, file := range diff.OnlyInSecond {
+			fmt.Printf("+ %s (%d bytes)\n", file.Path, file.Size)
+		}
+		for _, mismatch := range diff.Mismatches {
+			fmt.Printf("~ %s (%d -> %d bytes)\n", mismatch.Path, mismatch.FirstSize, mismatch.SecondSize)
+		}
+	}
+	if !diff.Empty() {
+		return fmt.Errorf("%d files only in %s, %d files only in %s, %d files with different sizes",
+			len(diff.OnlyInFirst), sides[0], len(diff.OnlyInSecond), sides[1], len(diff.Mismatches))
+	}
+	if !*jsonOutput {
+		fmt.Printf("%s and %s have the same %d files\n", sides[0], sides[1], len(trees[0]))
+	}
+	return nil
+}
+
+// walkLocalTree walks the primary or the secondary store of the storage in the config.
+func walkLocalTree(ctx context.Context, configPath, store, root string) ([]*drivers.TreeFile, error) {
+	_, _, driver, err := openStorage(configPath)
+	if err != nil {
+		return nil, err
+	}
+	var storeDriver storagedriver.StorageDriver
+	multiDriver, ok := multidriver.Is(driver)
+	switch {
+	case store != services.StorePrimary && store != services.StoreSecondary:
+		return nil, fmt.Errorf("unknown store '%s' - should be %s, %s or an admin API URL",
+			store, services.StorePrimary, services.StoreSecondary)
+	case !ok:
+		storeDriver = driver
+	case store == services.StoreSecondary:
+		storeDriver = multiDriver.Secondary()
+	default:
+		storeDriver = multiDriver.Primary()
+	}
+	return drivers.WalkTree(ctx, storeDriver, root)
+}
+
+// fetchTree reads the tree of a store of a Disco instance from its admin API. The store can be
+// selected with the store query parameter of the URL.
+func fetchTree(ctx context.Context, httpClient *http.Client, adminURL, root, token string) ([]*drivers.TreeFile, error) {
+	u, err := url.Parse(adminURL)
+	if err != nil {
+		return nil, fmt.Errorf("invalid admin API URL: %v", err)
+	}
+	u.Path = strings.TrimSuffix(u.Path, "/") + "/disco/tree"
+	query := u.Query()
+	query.Set("path", root)
+	u.RawQuery = query.Encode()
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u.String(), nil)
+	if err != nil {
+		return nil, err
+	}
+	if len(token) > 0 {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("admin API responded with status %d", resp.StatusCode)
+	}
+	var body struct {
+		Files []*drivers.TreeFile `json:"files"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&body); err != nil {
+		return nil, fmt.Errorf("failed to decode the response: %v", err)
+	}
+	return body.Files, nil
+}
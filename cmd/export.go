@@ -0,0 +1,53 @@
+package cmd
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/forta-network/disco/proxy/services"
+)
+
+func runExport(ctx context.Context, args []string) error {
+	flags := flag.NewFlagSet("export", flag.ContinueOnError)
+	configPath := flags.String("config", "", "config file path")
+	format := flags.String("format", services.ExportFormatDocker, "tarball format: docker or oci")
+	output := flags.String("o", "", "output file path (default: <repo>.tar)")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+	if flags.NArg() != 1 {
+		return errors.New("usage: disco export [-config path] [-format docker|oci] [-o path] <repo|cid>")
+	}
+	repoName := flags.Arg(0)
+	outputPath := *output
+	if len(outputPath) == 0 {
+		outputPath = repoName + ".tar"
+	}
+
+	disco, err := newDiscoService(*configPath)
+	if err != nil {
+		return err
+	}
+	// write to a temporary file first so a failed export doesn't leave a broken tarball
+	f, err := os.CreateTemp(filepath.Dir(outputPath), ".disco-export-*")
+	if err != nil {
+		return fmt.Errorf("failed to create output file: %v", err)
+	}
+	defer os.Remove(f.Name())
+	if err := disco.Export(ctx, repoName, *format, f); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Close(); err != nil {
+		return fmt.Errorf("failed to write output file: %v", err)
+	}
+	if err := os.Rename(f.Name(), outputPath); err != nil {
+		return fmt.Errorf("failed to write output file: %v", err)
+	}
+	fmt.Printf("wrote %s\n", outputPath)
+	return nil
+}
@@ -0,0 +1,41 @@
+package cmd
+
+import (
+	"context"
+	"flag"
+	"fmt"
+
+	"github.com/forta-network/disco/proxy/services"
+)
+
+func runGC(ctx context.Context, args []string) error {
+	flags := flag.NewFlagSet("gc", flag.ContinueOnError)
+	configPath := flags.String("config", "", "config file path")
+	dryRun := flags.Bool("dry-run", false, "only print the blobs which would be deleted")
+	olderThan := flags.Duration("older-than", services.DefaultGCOlderThan, "delete only the blobs older than this")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+
+	disco, err := newDiscoService(*configPath)
+	if err != nil {
+		return err
+	}
+	result, err := disco.GarbageCollect(ctx, services.GCOptions{
+		DryRun:    *dryRun,
+		OlderThan: *olderThan,
+	})
+	if result != nil {
+		action := "deleted"
+		if *dryRun {
+			action = "would delete"
+		}
+		var size int64
+		for _, blob := range result.Blobs {
+			fmt.Printf("%s %s from %s (%d bytes)\n", action, blob.Digest, blob.Store, blob.Size)
+			size += blob.Size
+		}
+		fmt.Printf("%s %d blobs (%d bytes) unreferenced by %d repositories\n", action, len(result.Blobs), size, result.Repositories)
+	}
+	return err
+}
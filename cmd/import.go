@@ -0,0 +1,94 @@
+package cmd
+
+import (
+	"archive/tar"
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+func runImport(ctx context.Context, args []string) error {
+	flags := flag.NewFlagSet("import", flag.ContinueOnError)
+	configPath := flags.String("config", "", "config file path")
+	ref := flags.String("ref", "", "ref name of the image to import from a multi-image OCI layout")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+	if flags.NArg() != 1 {
+		return errors.New("usage: disco import [-config path] [-ref name] <oci-layout-dir|tarball>")
+	}
+
+	layoutDir := flags.Arg(0)
+	stat, err := os.Stat(layoutDir)
+	if err != nil {
+		return err
+	}
+	if !stat.IsDir() {
+		tmpDir, err := os.MkdirTemp("", "disco-import-")
+		if err != nil {
+			return err
+		}
+		defer os.RemoveAll(tmpDir)
+		if err := extractTar(layoutDir, tmpDir); err != nil {
+			return fmt.Errorf("failed to extract %s: %v", layoutDir, err)
+		}
+		layoutDir = tmpDir
+	}
+
+	disco, err := newDiscoService(*configPath)
+	if err != nil {
+		return err
+	}
+	repoCid, err := disco.Import(ctx, os.DirFS(layoutDir), *ref)
+	if err != nil {
+		return err
+	}
+	fmt.Println(repoCid)
+	return nil
+}
+
+func extractTar(tarPath, dir string) error {
+	f, err := os.Open(tarPath)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	tr := tar.NewReader(f)
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		target := filepath.Join(dir, hdr.Name)
+		if !strings.HasPrefix(target, filepath.Clean(dir)+string(os.PathSeparator)) {
+			return fmt.Errorf("invalid path in tarball: %s", hdr.Name)
+		}
+		switch hdr.Typeflag {
+		case tar.TypeDir:
+			if err := os.MkdirAll(target, 0755); err != nil {
+				return err
+			}
+		case tar.TypeReg:
+			if err := os.MkdirAll(filepath.Dir(target), 0755); err != nil {
+				return err
+			}
+			out, err := os.Create(target)
+			if err != nil {
+				return err
+			}
+			_, err = io.Copy(out, tr)
+			out.Close()
+			if err != nil {
+				return err
+			}
+		}
+	}
+}
@@ -0,0 +1,225 @@
+package cmd
+
+import (
+	"bytes"
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"errors"
+	"flag"
+	"fmt"
+	"math/big"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"text/template"
+	"time"
+
+	"github.com/forta-network/disco/config"
+	"golang.org/x/crypto/bcrypt"
+)
+
+const (
+	htpasswdFileName = "htpasswd"
+	tlsCertFileName  = "tls.crt"
+	tlsKeyFileName   = "tls.key"
+	tlsCertValidity  = time.Hour * 24 * 365
+)
+
+var starterConfig = template.Must(template.New("config").Parse(`# Disco configuration generated by "disco init".
+# See https://distribution.github.io/distribution/about/configuration/ for the
+# distribution settings and the README for the Disco settings.
+version: 0.1
+log:
+  level: info
+  fields:
+    service: disco
+storage:
+  ipfs:
+    router:
+      # The IPFS nodes which store the repositories. The content is spread among
+      # the nodes by hashing the repository names.
+      nodes:
+        - url: {{ .IPFSURL }}
+    # The cache is used for serving the content faster and for surviving
+    # the IPFS node restarts.
+    cache:
+      filesystem:
+        rootdirectory: {{ .CacheDir }}
+    # Set to true to use only the cache and never store in IPFS.
+    # cacheonly: false
+  maintenance:
+    uploadpurging:
+      enabled: false
+{{- if .HtpasswdPath }}
+auth:
+  htpasswd:
+    realm: disco
+    path: {{ .HtpasswdPath }}
+{{- end }}
+{{- if .TLSCertPath }}
+disco:
+  # Serve Disco with the self-signed certificate generated for {{ .TLSHost }}.
+  tls:
+    certificate: {{ .TLSCertPath }}
+    key: {{ .TLSKeyPath }}
+  # Set to true to skip cloning the repositories from the IPFS network on pull.
+  # noclone: false
+{{- else }}
+# disco:
+#   # Set to true to skip cloning the repositories from the IPFS network on pull.
+#   noclone: false
+{{- end }}
+http:
+  # The registry listens on this address and Disco proxies it on DISCO_PORT (default 1970).
+  addr: :5000
+  debug:
+    addr: :5050
+    prometheus:
+      enabled: true
+      path: /metrics
+  headers:
+    X-Content-Type-Options: [nosniff]
+`))
+
+type starterConfigValues struct {
+	IPFSURL      string
+	CacheDir     string
+	HtpasswdPath string
+	TLSHost      string
+	TLSCertPath  string
+	TLSKeyPath   string
+}
+
+func runInit(ctx context.Context, args []string) error {
+	flags := flag.NewFlagSet("init", flag.ContinueOnError)
+	ipfsURL := flags.String("ipfs", "http://localhost:5001", "IPFS node API URL")
+	cacheDir := flags.String("cache", "/var/lib/disco/cache", "filesystem cache directory")
+	htpasswdUser := flags.String("htpasswd", "", "generate an htpasswd file with a user in user:password format")
+	tlsHost := flags.String("tls", "", "generate a self-signed TLS certificate for given host")
+	force := flags.Bool("force", false, "overwrite the existing files")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+
+	configPath, err := config.ResolvePath(configPathArg(flags.Args()))
+	if err != nil {
+		return err
+	}
+	// the generated files are referred to with absolute paths from the config
+	configPath, err = filepath.Abs(configPath)
+	if err != nil {
+		return fmt.Errorf("failed to resolve config path: %v", err)
+	}
+	configDir := filepath.Dir(configPath)
+	values := starterConfigValues{
+		IPFSURL:  *ipfsURL,
+		CacheDir: *cacheDir,
+	}
+	var files []string
+	if len(*htpasswdUser) > 0 {
+		values.HtpasswdPath = filepath.Join(configDir, htpasswdFileName)
+		files = append(files, values.HtpasswdPath)
+	}
+	if len(*tlsHost) > 0 {
+		values.TLSHost = *tlsHost
+		values.TLSCertPath = filepath.Join(configDir, tlsCertFileName)
+		values.TLSKeyPath = filepath.Join(configDir, tlsKeyFileName)
+		files = append(files, values.TLSCertPath, values.TLSKeyPath)
+	}
+	if !*force {
+		for _, file := range append(files, configPath) {
+			if _, err := os.Stat(file); err == nil {
+				return fmt.Errorf("%s already exists - use -force to overwrite", file)
+			}
+		}
+	}
+
+	if err := os.MkdirAll(configDir, 0755); err != nil {
+		return fmt.Errorf("failed to create config dir: %v", err)
+	}
+	if len(values.HtpasswdPath) > 0 {
+		if err := writeHtpasswd(values.HtpasswdPath, *htpasswdUser); err != nil {
+			return err
+		}
+	}
+	if len(values.TLSCertPath) > 0 {
+		if err := writeSelfSignedCert(values.TLSCertPath, values.TLSKeyPath, values.TLSHost); err != nil {
+			return err
+		}
+	}
+	var buf bytes.Buffer
+	if err := starterConfig.Execute(&buf, values); err != nil {
+		return fmt.Errorf("failed to generate config: %v", err)
+	}
+	if err := os.WriteFile(configPath, buf.Bytes(), 0644); err != nil {
+		return fmt.Errorf("failed to write config: %v", err)
+	}
+
+	for _, file := range append([]string{configPath}, files...) {
+		fmt.Printf("wrote %s\n", file)
+	}
+	return nil
+}
+
+func writeHtpasswd(path, userPass string) error {
+	segments := strings.SplitN(userPass, ":", 2)
+	if len(segments) != 2 || len(segments[0]) == 0 || len(segments[1]) == 0 {
+		return errors.New("expected the htpasswd user in user:password format")
+	}
+	hash, err := bcrypt.GenerateFromPassword([]byte(segments[1]), bcrypt.DefaultCost)
+	if err != nil {
+		return fmt.Errorf("failed to hash the password: %v", err)
+	}
+	if err := os.WriteFile(path, []byte(fmt.Sprintf("%s:%s\n", segments[0], hash)), 0600); err != nil {
+		return fmt.Errorf("failed to write htpasswd file: %v", err)
+	}
+	return nil
+}
+
+func writeSelfSignedCert(certPath, keyPath, host string) error {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return fmt.Errorf("failed to generate tls key: %v", err)
+	}
+	serial, err := rand.Int(rand.Reader, new(big.Int).Lsh(big.NewInt(1), 128))
+	if err != nil {
+		return fmt.Errorf("failed to generate certificate serial: %v", err)
+	}
+	now := time.Now()
+	template := &x509.Certificate{
+		SerialNumber:          serial,
+		Subject:               pkix.Name{CommonName: host},
+		NotBefore:             now,
+		NotAfter:              now.Add(tlsCertValidity),
+		KeyUsage:              x509.KeyUsageDigitalSignature | x509.KeyUsageKeyEncipherment,
+		ExtKeyUsage:           []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+		BasicConstraintsValid: true,
+	}
+	if ip := net.ParseIP(host); ip != nil {
+		template.IPAddresses = []net.IP{ip}
+	} else {
+		template.DNSNames = []string{host}
+	}
+	certDER, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		return fmt.Errorf("failed to create certificate: %v", err)
+	}
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		return fmt.Errorf("failed to encode tls key: %v", err)
+	}
+
+	if err := os.WriteFile(certPath, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: certDER}), 0644); err != nil {
+		return fmt.Errorf("failed to write certificate: %v", err)
+	}
+	if err := os.WriteFile(keyPath, pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER}), 0600); err != nil {
+		return fmt.Errorf("failed to write tls key: %v", err)
+	}
+	return nil
+}
@@ -0,0 +1,59 @@
+package cmd
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+	"text/tabwriter"
+)
+
+func runInspect(ctx context.Context, args []string) error {
+	flags := flag.NewFlagSet("inspect", flag.ContinueOnError)
+	configPath := flags.String("config", "", "config file path")
+	jsonOutput := flags.Bool("json", false, "print as JSON")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+	if flags.NArg() != 1 {
+		return errors.New("usage: disco inspect [-config path] [-json] <repo|cid>")
+	}
+
+	disco, err := newDiscoService(*configPath)
+	if err != nil {
+		return err
+	}
+	result, err := disco.Inspect(ctx, flags.Arg(0))
+	if err != nil {
+		return err
+	}
+	if *jsonOutput {
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		return enc.Encode(result)
+	}
+
+	fmt.Printf("repository:      %s\n", result.Repository)
+	fmt.Printf("cid:             %s\n", result.Cid)
+	fmt.Printf("manifest digest: %s\n", result.ManifestDigest)
+	fmt.Printf("stores:          %s\n", strings.Join(result.Stores, ", "))
+	if len(result.DiscoFile) > 0 {
+		fmt.Printf("disco.json:      %s\n", strings.TrimSpace(string(result.DiscoFile)))
+	} else {
+		fmt.Println("disco.json:      not found")
+	}
+	fmt.Println()
+	w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(w, "DIGEST\tCID\tSIZE\tSTORES")
+	for _, blob := range result.Blobs {
+		stores := strings.Join(blob.Stores, ", ")
+		if len(stores) == 0 {
+			stores = "missing"
+		}
+		fmt.Fprintf(w, "%s\t%s\t%d\t%s\n", blob.Digest, blob.Cid, blob.Size, stores)
+	}
+	return w.Flush()
+}
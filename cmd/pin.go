@@ -0,0 +1,49 @@
+package cmd
+
+import (
+	"context"
+	"flag"
+	"fmt"
+)
+
+func runPin(ctx context.Context, args []string) error {
+	return runPinCommand(ctx, "pin", args)
+}
+
+func runUnpin(ctx context.Context, args []string) error {
+	return runPinCommand(ctx, "unpin", args)
+}
+
+func runPinCommand(ctx context.Context, name string, args []string) error {
+	flags := flag.NewFlagSet(name, flag.ContinueOnError)
+	configPath := flags.String("config", "", "config file path")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+	if flags.NArg() == 0 {
+		return fmt.Errorf("usage: disco %s [-config path] <cid>...", name)
+	}
+
+	disco, err := newDiscoService(*configPath)
+	if err != nil {
+		return err
+	}
+	pinFunc := disco.Pin
+	if name == "unpin" {
+		pinFunc = disco.Unpin
+	}
+	var failed int
+	for _, repo := range flags.Args() {
+		cids, err := pinFunc(ctx, repo)
+		if err != nil {
+			fmt.Printf("%s: failed to %s: %v\n", repo, name, err)
+			failed++
+			continue
+		}
+		fmt.Printf("%s: %sned %d cids\n", repo, name, len(cids))
+	}
+	if failed > 0 {
+		return fmt.Errorf("failed to %s %d of %d repositories", name, failed, flags.NArg())
+	}
+	return nil
+}
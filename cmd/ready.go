@@ -0,0 +1,121 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/http"
+	"os"
+	"time"
+
+	"github.com/forta-network/disco/config"
+	"github.com/forta-network/disco/systemd"
+	log "github.com/sirupsen/logrus"
+)
+
+const (
+	readinessCheckInterval = time.Second
+	readinessCheckTimeout  = time.Second * 5
+)
+
+// proxyListeners returns the socket which systemd passes with socket activation, or
+// listens on the address of the server and on the unix socket from the config otherwise.
+func proxyListeners(cfg *config.Config, server *http.Server) ([]net.Listener, error) {
+	listeners, err := systemd.Listeners()
+	if err != nil {
+		return nil, err
+	}
+	if len(listeners) > 0 {
+		for _, extra := range listeners[1:] {
+			log.WithField("address", extra.Addr().String()).Warn("ignoring extra socket from systemd")
+			extra.Close()
+		}
+		log.WithField("address", listeners[0].Addr().String()).Info("using the socket from systemd")
+		return listeners[:1], nil
+	}
+	if !cfg.UnixSocket.NoTCP {
+		listener, err := net.Listen("tcp", server.Addr)
+		if err != nil {
+			return nil, err
+		}
+		listeners = append(listeners, listener)
+	}
+	if len(cfg.UnixSocket.Path) > 0 {
+		listener, err := listenUnix(&cfg.UnixSocket)
+		if err != nil {
+			for _, listener := range listeners {
+				listener.Close()
+			}
+			return nil, err
+		}
+		log.WithField("path", cfg.UnixSocket.Path).Info("listening on unix socket")
+		listeners = append(listeners, listener)
+	}
+	return listeners, nil
+}
+
+// listenUnix listens on the unix socket after removing the stale socket file of the
+// previous run, if any.
+func listenUnix(socketCfg *config.UnixSocketConfig) (net.Listener, error) {
+	mode, err := socketCfg.FileMode()
+	if err != nil {
+		return nil, err
+	}
+	if info, err := os.Stat(socketCfg.Path); err == nil {
+		if info.Mode()&os.ModeSocket == 0 {
+			return nil, fmt.Errorf("%s exists and is not a socket", socketCfg.Path)
+		}
+		if err := os.Remove(socketCfg.Path); err != nil {
+			return nil, fmt.Errorf("failed to remove the stale socket: %v", err)
+		}
+	}
+	listener, err := net.Listen("unix", socketCfg.Path)
+	if err != nil {
+		return nil, err
+	}
+	if err := os.Chmod(socketCfg.Path, mode); err != nil {
+		listener.Close()
+		return nil, fmt.Errorf("failed to set the socket mode: %v", err)
+	}
+	return listener, nil
+}
+
+// notifyWhenReady notifies systemd when the registry and the IPFS nodes are reachable.
+func notifyWhenReady(ctx context.Context, cfg *config.Config) {
+	httpClient := cfg.HTTPClient()
+	ticker := time.NewTicker(readinessCheckInterval)
+	defer ticker.Stop()
+	for {
+		if isReady(ctx, cfg, httpClient) {
+			break
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+	if err := systemd.Notify("READY=1"); err != nil {
+		log.WithError(err).Warn("failed to notify readiness")
+		return
+	}
+	log.Info("notified systemd readiness")
+}
+
+func isReady(ctx context.Context, cfg *config.Config, httpClient *http.Client) bool {
+	checks := []*statusCheck{
+		checkHTTP(ctx, readinessCheckTimeout, httpClient, "registry", registryURL(cfg)),
+	}
+	if !cfg.CacheOnly {
+		for i, node := range cfg.Router.Nodes {
+			checks = append(checks, checkIPFSNode(ctx, readinessCheckTimeout, httpClient, i, node))
+		}
+	}
+	for _, check := range checks {
+		if !check.ok {
+			log.WithField("component", check.component).Debugf("not ready: %s", check.status)
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,92 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/distribution/distribution/v3/registry"
+	"github.com/forta-network/disco/config"
+	"github.com/forta-network/disco/deps"
+	"github.com/forta-network/disco/drivers/ipfs"
+	"github.com/forta-network/disco/proxy"
+	"github.com/forta-network/disco/proxy/services"
+	log "github.com/sirupsen/logrus"
+)
+
+// registryAddrs tracks the addresses of the registries in the process, so that they don't collide.
+type registryAddrs map[string]string
+
+// startVirtualHosts loads the configs of the virtual hosts and starts their registries.
+func startVirtualHosts(ctx context.Context, cfg *config.Config, addrs registryAddrs) ([]*proxy.VirtualHost, error) {
+	var vhosts []*proxy.VirtualHost
+	for _, vhostCfg := range cfg.VirtualHosts {
+		name := "virtual host " + vhostCfg.Host
+		vcfg, discoService, err := startRegistry(ctx, cfg, vhostCfg.Config, name, addrs)
+		if err != nil {
+			return nil, err
+		}
+		vhosts = append(vhosts, &proxy.VirtualHost{
+			Host:   vhostCfg.Host,
+			Config: vcfg,
+			Disco:  discoService,
+		})
+	}
+	return vhosts, nil
+}
+
+// startTenants loads the configs of the tenants and starts their registries.
+func startTenants(ctx context.Context, cfg *config.Config, addrs registryAddrs) ([]*proxy.Tenant, error) {
+	var tenants []*proxy.Tenant
+	for _, tenantCfg := range cfg.Tenants {
+		name := "namespace " + tenantCfg.Namespace
+		tcfg, discoService, err := startRegistry(ctx, cfg, tenantCfg.Config, name, addrs)
+		if err != nil {
+			return nil, err
+		}
+		tenants = append(tenants, &proxy.Tenant{
+			Settings: tenantCfg,
+			Config:   tcfg,
+			Disco:    discoService,
+		})
+	}
+	return tenants, nil
+}
+
+// startRegistry loads the config from the path and starts a registry with it.
+func startRegistry(ctx context.Context, cfg *config.Config, configPath, name string, addrs registryAddrs) (*config.Config, *services.Disco, error) {
+	rcfg, err := config.Load(config.EnvVars{
+		RegistryConfigurationPath: configPath,
+		ConfigWatchInterval:       cfg.Vars.ConfigWatchInterval,
+	})
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to load the config of %s: %v", name, err)
+	}
+	addr := rcfg.Distribution.HTTP.Addr
+	if other, ok := addrs[addr]; ok {
+		return nil, nil, fmt.Errorf("the registry of %s should listen on a different address than %s", name, other)
+	}
+	addrs[addr] = name
+	if len(rcfg.VirtualHosts) > 0 || len(rcfg.Tenants) > 0 {
+		log.WithField("registry", name).Warn("ignoring the nested virtual hosts and tenants")
+	}
+
+	ipfsClient := deps.New(rcfg)
+	ipfs.SetDependencies(rcfg, ipfsClient)
+	go rcfg.Watch(ctx)
+	discoService := services.NewDiscoService(rcfg, ipfsClient)
+	if err := discoService.ShareHTTPSecret(ctx); err != nil {
+		return nil, nil, fmt.Errorf("failed to share the http secret of %s: %v", name, err)
+	}
+	reg, err := registry.NewRegistry(ctx, rcfg.Distribution)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to initialize the registry of %s: %v", name, err)
+	}
+	go func() {
+		_ = reg.ListenAndServe()
+	}()
+	log.WithFields(log.Fields{
+		"registry": name,
+		"address":  addr,
+	}).Info("started registry")
+	return rcfg, discoService, nil
+}
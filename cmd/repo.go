@@ -0,0 +1,62 @@
+package cmd
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"text/tabwriter"
+	"time"
+)
+
+func runRepo(ctx context.Context, args []string) error {
+	if len(args) == 0 {
+		return errors.New("usage: disco repo ls [-config path] [-json]")
+	}
+	switch args[0] {
+	case "ls":
+		return listRepos(ctx, args[1:])
+	default:
+		return fmt.Errorf("unknown repo command '%s'", args[0])
+	}
+}
+
+func listRepos(ctx context.Context, args []string) error {
+	flags := flag.NewFlagSet("repo ls", flag.ContinueOnError)
+	configPath := flags.String("config", "", "config file path")
+	jsonOutput := flags.Bool("json", false, "print as JSON")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+
+	disco, err := newDiscoService(*configPath)
+	if err != nil {
+		return err
+	}
+	repos, err := disco.ListRepoInfos(ctx)
+	if err != nil {
+		return err
+	}
+	if *jsonOutput {
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		return enc.Encode(repos)
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(w, "CID\tDIGEST\tNAME\tSIZE\tLAST PULL")
+	for _, repo := range repos {
+		lastPull := "-"
+		if repo.LastPull != nil {
+			lastPull = repo.LastPull.Format(time.RFC3339)
+		}
+		name := repo.Name
+		if len(name) == 0 {
+			name = "-"
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\n", repo.Cid, repo.Digest, name, formatBytes(uint64(repo.Size)), lastPull)
+	}
+	return w.Flush()
+}
@@ -0,0 +1,41 @@
+package cmd
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+
+	"github.com/forta-network/disco/proxy/services"
+)
+
+func runRestore(ctx context.Context, args []string) error {
+	flags := flag.NewFlagSet("restore", flag.ContinueOnError)
+	configPath := flags.String("config", "", "config file path")
+	node := flags.Int("node", -1, "restore only the ipfs node with this index")
+	warmCache := flags.Bool("warm-cache", false, "replicate the repositories in the cache after restoring")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+	if flags.NArg() != 1 {
+		return errors.New("usage: disco restore [-config path] [-node index] [-warm-cache] <root-cid|snapshot-id>")
+	}
+
+	disco, err := newDiscoService(*configPath)
+	if err != nil {
+		return err
+	}
+	result, err := disco.Restore(ctx, flags.Arg(0), services.RestoreOptions{
+		Node:      *node,
+		WarmCache: *warmCache,
+	})
+	if result != nil {
+		for _, root := range result.Roots {
+			fmt.Printf("restored ipfs node #%d from %s\n", root.Node, root.Cid)
+		}
+		for _, repo := range result.Warmed {
+			fmt.Printf("replicated %s in the cache\n", repo)
+		}
+	}
+	return err
+}
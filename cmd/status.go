@@ -0,0 +1,196 @@
+package cmd
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"net"
+	"net/http"
+	"os"
+	"text/tabwriter"
+	"time"
+
+	storagedriver "github.com/distribution/distribution/v3/registry/storage/driver"
+	"github.com/forta-network/disco/config"
+	"github.com/forta-network/disco/drivers/multidriver"
+	"github.com/forta-network/disco/ipfsclient"
+)
+
+const registryRoot = "/docker/registry/v2"
+
+type statusCheck struct {
+	component string
+	ok        bool
+	status    string
+}
+
+func runStatus(ctx context.Context, args []string) error {
+	flags := flag.NewFlagSet("status", flag.ContinueOnError)
+	configPath := flags.String("config", "", "config file path")
+	timeout := flags.Duration("timeout", time.Second*10, "timeout of each check")
+	usage := flags.Bool("usage", false, "compute the cache usage by walking the cache (can be slow)")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+
+	cfg, _, driver, err := openStorage(*configPath)
+	if err != nil {
+		return err
+	}
+	httpClient := cfg.HTTPClient()
+
+	checks := []*statusCheck{
+		{component: "version", ok: true, status: versionInfo()},
+	}
+	checks = append(checks, checkHTTP(ctx, *timeout, httpClient, "registry", registryURL(cfg)))
+	checks = append(checks, checkHTTP(ctx, *timeout, proxyClient(cfg, httpClient), "proxy", proxyURL(cfg)))
+
+	if !cfg.CacheOnly {
+		for i, node := range cfg.Router.Nodes {
+			checks = append(checks, checkIPFSNode(ctx, *timeout, httpClient, i, node))
+		}
+	}
+
+	var cacheDriver storagedriver.StorageDriver
+	if multiDriver, ok := multidriver.Is(driver); ok {
+		cacheDriver = multiDriver.Secondary()
+	} else if cfg.CacheOnly {
+		cacheDriver = driver
+	}
+	if cacheDriver != nil {
+		checks = append(checks, checkCache(ctx, *timeout, cacheDriver, *usage))
+	}
+	checks = append(checks, &statusCheck{component: "replication", ok: true, status: "synchronous (no queue)"})
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(w, "COMPONENT\tOK\tSTATUS")
+	var failed int
+	for _, check := range checks {
+		fmt.Fprintf(w, "%s\t%t\t%s\n", check.component, check.ok, check.status)
+		if !check.ok {
+			failed++
+		}
+	}
+	if err := w.Flush(); err != nil {
+		return err
+	}
+	if failed > 0 {
+		return fmt.Errorf("%d of %d checks failed", failed, len(checks))
+	}
+	return nil
+}
+
+func registryURL(cfg *config.Config) string {
+	scheme := "http"
+	if len(cfg.Distribution.HTTP.TLS.Certificate) > 0 {
+		scheme = "https"
+	}
+	return fmt.Sprintf("%s://localhost%s/v2/", scheme, cfg.Distribution.HTTP.Addr)
+}
+
+func proxyURL(cfg *config.Config) string {
+	scheme := "http"
+	if cfg.ServerTLS != nil {
+		scheme = "https"
+	}
+	return fmt.Sprintf("%s://localhost:%d/v2/", scheme, cfg.Vars.DiscoPort)
+}
+
+// proxyClient returns the client to reach the proxy with, which dials the unix socket if the
+// TCP listener is disabled.
+func proxyClient(cfg *config.Config, httpClient *http.Client) *http.Client {
+	if !cfg.UnixSocket.NoTCP {
+		return httpClient
+	}
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	if cfg.ClientTLS != nil {
+		transport.TLSClientConfig = cfg.ClientTLS.Clone()
+	}
+	transport.DialContext = func(ctx context.Context, _, _ string) (net.Conn, error) {
+		var dialer net.Dialer
+		return dialer.DialContext(ctx, "unix", cfg.UnixSocket.Path)
+	}
+	return &http.Client{Transport: transport}
+}
+
+func checkHTTP(ctx context.Context, timeout time.Duration, httpClient *http.Client, component, url string) *statusCheck {
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+	check := &statusCheck{component: component}
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		check.status = err.Error()
+		return check
+	}
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		check.status = fmt.Sprintf("unreachable: %v", err)
+		return check
+	}
+	resp.Body.Close()
+	// the registry responds with 401 if the auth is enabled
+	check.ok = resp.StatusCode == http.StatusOK || resp.StatusCode == http.StatusUnauthorized
+	check.status = fmt.Sprintf("%s responded with %d", url, resp.StatusCode)
+	return check
+}
+
+func checkIPFSNode(ctx context.Context, timeout time.Duration, httpClient *http.Client, index int, node *config.Node) *statusCheck {
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+	check := &statusCheck{component: fmt.Sprintf("ipfs node #%d", index)}
+	stat, err := ipfsclient.NewClientWithHTTPClient(node.URL, httpClient).RepoStat(ctx)
+	if err != nil {
+		check.status = fmt.Sprintf("%s is unreachable: %v", node.URL, err)
+		return check
+	}
+	check.ok = true
+	check.status = fmt.Sprintf("%s repo size %s of %s (%d objects)", node.URL,
+		formatBytes(stat.RepoSize), formatBytes(stat.StorageMax), stat.NumObjects)
+	return check
+}
+
+func checkCache(ctx context.Context, timeout time.Duration, driver storagedriver.StorageDriver, usage bool) *statusCheck {
+	check := &statusCheck{component: "cache"}
+	listCtx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+	_, err := driver.List(listCtx, registryRoot)
+	switch err.(type) {
+	case nil, storagedriver.PathNotFoundError:
+	default:
+		check.status = fmt.Sprintf("%s is unreachable: %v", driver.Name(), err)
+		return check
+	}
+	check.ok = true
+	check.status = fmt.Sprintf("%s is reachable", driver.Name())
+	if !usage {
+		return check
+	}
+	var size, files int64
+	err = driver.Walk(ctx, registryRoot, func(fileInfo storagedriver.FileInfo) error {
+		if !fileInfo.IsDir() {
+			size += fileInfo.Size()
+			files++
+		}
+		return nil
+	})
+	switch err.(type) {
+	case nil, storagedriver.PathNotFoundError:
+		check.status += fmt.Sprintf(", using %s (%d files)", formatBytes(uint64(size)), files)
+	default:
+		check.status += fmt.Sprintf(", failed to compute usage: %v", err)
+	}
+	return check
+}
+
+func formatBytes(n uint64) string {
+	const unit = 1024
+	if n < unit {
+		return fmt.Sprintf("%d B", n)
+	}
+	div, exp := uint64(unit), 0
+	for m := n / unit; m >= unit; m /= unit {
+		div *= unit
+		exp++
+	}
+	return fmt.Sprintf("%.1f %ciB", float64(n)/float64(div), "KMGTPE"[exp])
+}
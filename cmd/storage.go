@@ -0,0 +1,37 @@
+package cmd
+
+import (
+	"fmt"
+
+	storagedriver "github.com/distribution/distribution/v3/registry/storage/driver"
+	"github.com/forta-network/disco/config"
+	"github.com/forta-network/disco/deps"
+	"github.com/forta-network/disco/drivers/ipfs"
+	"github.com/forta-network/disco/interfaces"
+	"github.com/forta-network/disco/proxy/services"
+)
+
+// newDiscoService loads the config and creates the Disco service with the same storage as
+// the registry, for the commands which work on the storage directly.
+func newDiscoService(configPath string) (*services.Disco, error) {
+	cfg, ipfsClient, _, err := openStorage(configPath)
+	if err != nil {
+		return nil, err
+	}
+	return services.NewDiscoService(cfg, ipfsClient), nil
+}
+
+// openStorage loads the config and creates the IPFS client and the storage driver of the registry.
+func openStorage(configPath string) (*config.Config, interfaces.IPFSClient, storagedriver.StorageDriver, error) {
+	cfg, err := config.InitWithPath(configPath)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	ipfsClient := deps.New(cfg)
+	ipfs.SetDependencies(cfg, ipfsClient)
+	driver, err := ipfs.Create(cfg.Distribution.Storage.Parameters())
+	if err != nil {
+		return nil, nil, nil, fmt.Errorf("failed to create the storage driver: %v", err)
+	}
+	return cfg, ipfsClient, driver, nil
+}
@@ -0,0 +1,35 @@
+package cmd
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"time"
+
+	"github.com/forta-network/disco/config"
+)
+
+func runPruneUploads(ctx context.Context, args []string) error {
+	flags := flag.NewFlagSet("prune-uploads", flag.ContinueOnError)
+	configPath := flags.String("config", "", "config file path")
+	dryRun := flags.Bool("dry-run", false, "only print the uploads which would be deleted")
+	olderThan := flags.Duration("older-than", config.DefaultPruneUploadsAge, "delete only the uploads started before this")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+
+	disco, err := newDiscoService(*configPath)
+	if err != nil {
+		return err
+	}
+	pruned, err := disco.PruneUploads(ctx, *olderThan, *dryRun)
+	action := "deleted"
+	if *dryRun {
+		action = "would delete"
+	}
+	for _, upload := range pruned {
+		fmt.Printf("%s upload %s from %s (started at %s)\n", action, upload.ID, upload.Store, upload.StartedAt.Format(time.RFC3339))
+	}
+	fmt.Printf("%s %d uploads\n", action, len(pruned))
+	return err
+}
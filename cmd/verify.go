@@ -0,0 +1,54 @@
+package cmd
+
+import (
+	"context"
+	"flag"
+	"fmt"
+)
+
+func runVerify(ctx context.Context, args []string) error {
+	flags := flag.NewFlagSet("verify", flag.ContinueOnError)
+	configPath := flags.String("config", "", "config file path")
+	repair := flags.Bool("repair", false, "repair the problems by copying the content again")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+
+	disco, err := newDiscoService(*configPath)
+	if err != nil {
+		return err
+	}
+	repos := flags.Args()
+	if len(repos) == 0 {
+		repos, err = disco.ListGlobalRepos(ctx)
+		if err != nil {
+			return err
+		}
+	}
+
+	var failed int
+	for _, repo := range repos {
+		result, err := disco.Verify(ctx, repo, *repair)
+		if err != nil {
+			fmt.Printf("%s: failed to verify: %v\n", repo, err)
+			failed++
+			continue
+		}
+		for _, problem := range result.Repaired {
+			fmt.Printf("%s: repaired: %s\n", repo, problem)
+		}
+		for _, problem := range result.Problems {
+			fmt.Printf("%s: %s\n", repo, problem)
+		}
+		switch {
+		case len(result.Problems) > 0:
+			failed++
+		case len(result.Repaired) == 0:
+			fmt.Printf("%s: ok\n", repo)
+		}
+	}
+	if failed > 0 {
+		return fmt.Errorf("%d of %d repositories have problems", failed, len(repos))
+	}
+	return nil
+}
@@ -0,0 +1,24 @@
+package cmd
+
+import (
+	"runtime"
+	"runtime/debug"
+)
+
+// Version is the Disco version which is set at build time with:
+//
+//	-ldflags "-X github.com/forta-network/disco/cmd.Version=<version>"
+var Version = "dev"
+
+// versionInfo returns the version with the Go version and the VCS revision, if known.
+func versionInfo() string {
+	info := Version + " (" + runtime.Version()
+	if buildInfo, ok := debug.ReadBuildInfo(); ok {
+		for _, setting := range buildInfo.Settings {
+			if setting.Key == "vcs.revision" {
+				info += ", " + setting.Value
+			}
+		}
+	}
+	return info + ")"
+}
@@ -1,10 +1,16 @@
 package config
 
 import (
+	"bytes"
+	"crypto"
+	"crypto/tls"
 	"fmt"
 	"net/url"
 	"os"
 	"path"
+	"strconv"
+	"sync"
+	"time"
 
 	"github.com/distribution/distribution/v3/configuration"
 	"github.com/kelseyhightower/envconfig"
@@ -14,11 +20,15 @@ import (
 
 const (
 	defaultHomeDirDiscoConfigPath = ".disco/config.yaml"
+	defaultDiscoPort              = 1970
 )
 
-type envVars struct {
-	RegistryConfigurationPath string `envconfig:"registry_configuration_path"`
-	DiscoPort                 int    `envconfig:"disco_port" default:"1970"`
+// EnvVars contains the environment variables which Disco reads.
+type EnvVars struct {
+	RegistryConfigurationPath string        `envconfig:"registry_configuration_path"`
+	DiscoPort                 int           `envconfig:"disco_port"`
+	Profile                   string        `envconfig:"disco_profile"`
+	ConfigWatchInterval       time.Duration `envconfig:"config_watch_interval" default:"10s"`
 }
 
 // Node contains IPFS node parameters.
@@ -26,78 +36,981 @@ type Node struct {
 	URL string `yaml:"url"`
 }
 
+// Placement strategies for new repositories.
+const (
+	PlacementHash  = "hash"
+	PlacementUsage = "usage"
+)
+
 // RouterConfig contains router config parameters.
 type RouterConfig struct {
 	Nodes []*Node `yaml:"nodes"`
+	// Placement is the strategy to pick nodes for new repositories: "hash" (default) or "usage".
+	Placement string `yaml:"placement"`
+	// PlacementIndex is the file which the placement decisions are recorded to, unless the
+	// state is shared.
+	PlacementIndex string `yaml:"placementindex"`
+	// MaxUsage is the repo usage ratio (0-1) after which a node stops receiving new repositories.
+	MaxUsage float64 `yaml:"maxusage"`
+	// StatCache caches the stat results of the nodes.
+	StatCache StatCacheConfig `yaml:"statcache"`
+}
+
+// Default stat cache settings.
+const (
+	DefaultStatCacheTTL  = 2 * time.Second
+	DefaultStatCacheSize = 4096
+)
+
+// StatCacheConfig contains the settings of the memory cache which keeps the recent stat results
+// of the MFS paths, since a pull stats the same paths many times. The writes through the router
+// invalidate them.
+type StatCacheConfig struct {
+	Disabled bool          `yaml:"disabled"`
+	TTL      time.Duration `yaml:"ttl"`
+	Size     int           `yaml:"size"`
+}
+
+func (statCfg *StatCacheConfig) applyDefaults() {
+	if statCfg.TTL == 0 {
+		statCfg.TTL = DefaultStatCacheTTL
+	}
+	if statCfg.Size == 0 {
+		statCfg.Size = DefaultStatCacheSize
+	}
+}
+
+// Default upload pruning settings.
+const (
+	DefaultPruneUploadsInterval = time.Hour * 24
+	DefaultPruneUploadsAge      = time.Hour * 24 * 7
+)
+
+// Default digest repository retention settings.
+const (
+	DefaultDigestRetentionInterval = time.Hour * 24
+	DefaultDigestRetentionAge      = time.Hour * 24 * 30
+)
+
+// DigestRetentionConfig contains the settings of the scheduled pruning of the digest repositories
+// which were not pulled for a while. It needs the pull index.
+type DigestRetentionConfig struct {
+	Enabled  bool          `yaml:"enabled"`
+	Interval time.Duration `yaml:"interval"`
+	Age      time.Duration `yaml:"age"`
+}
+
+// DefaultDigestPushesDelay is the default time which Disco waits after the last manifest push by
+// digest to a repository before it makes the repository global.
+const DefaultDigestPushesDelay = time.Second * 30
+
+// DigestPushesConfig contains the settings of the globalization of the repositories which are
+// pushed only by digest, without the canonical tag.
+type DigestPushesConfig struct {
+	Enabled bool `yaml:"enabled"`
+	// Delay lets the pushes of the other manifests of the image, e.g. the manifest list after
+	// the platform manifests, finish before the last pushed manifest is made global.
+	Delay time.Duration `yaml:"delay"`
 }
 
-// Configuration variables
-var (
-	Vars               envVars
-	DistributionConfig *configuration.Configuration
-	Router             RouterConfig
-	Cache              configuration.Storage
-	CacheOnly          bool
-	RedirectTo         *url.URL
-	NoClone            bool
+func (digestPushesCfg *DigestPushesConfig) applyDefaults() {
+	if digestPushesCfg.Delay == 0 {
+		digestPushesCfg.Delay = DefaultDigestPushesDelay
+	}
+}
+
+// Default snapshot settings.
+const (
+	DefaultSnapshotsInterval  = time.Hour * 24
+	DefaultSnapshotsRetention = time.Hour * 24 * 7
 )
 
-// discoConfig contains the extra configuration settings that blend with
+// SnapshotsConfig contains the settings of the scheduled snapshots of the MFS root of the
+// registry in the IPFS nodes. The snapshots are recorded in the cache.
+type SnapshotsConfig struct {
+	Enabled  bool          `yaml:"enabled"`
+	Interval time.Duration `yaml:"interval"`
+	// Retention is how long the snapshots are kept. The latest snapshot is always kept.
+	Retention time.Duration `yaml:"retention"`
+}
+
+// DefaultPublishRootInterval is the default interval of the registry root publication.
+const DefaultPublishRootInterval = time.Minute * 10
+
+// PublishRootConfig contains the settings of the periodic publication of the MFS root of the
+// registry in the IPFS nodes, so that the nodes can be checked to have the same content.
+type PublishRootConfig struct {
+	Enabled  bool          `yaml:"enabled"`
+	Interval time.Duration `yaml:"interval"`
+	// Webhook receives the roots in a POST request.
+	Webhook string `yaml:"webhook"`
+	// IPNSKey is the name of the key in each node which its root is published to IPNS with.
+	IPNSKey string `yaml:"ipnskey"`
+}
+
+// DefaultWarmupInterval is the default interval of the warm-up of the listed repositories.
+const DefaultWarmupInterval = time.Minute * 10
+
+// WarmupConfig contains the CID v1 repositories which Disco should always be able to serve. They
+// are cloned, pinned and cached periodically.
+type WarmupConfig struct {
+	Enabled  bool          `yaml:"enabled"`
+	Interval time.Duration `yaml:"interval"`
+	// Cids are the CID v1 repositories.
+	Cids []string `yaml:"cids"`
+	// URL is the location of more repositories, one per line. The lines which start with '#'
+	// are ignored.
+	URL string `yaml:"url"`
+}
+
+func (warmupCfg *WarmupConfig) applyDefaults() {
+	if warmupCfg.Interval == 0 {
+		warmupCfg.Interval = DefaultWarmupInterval
+	}
+}
+
+// DefaultUsageAlertsInterval is the default interval of the storage usage checks.
+const DefaultUsageAlertsInterval = time.Minute * 5
+
+// UsageAlertsConfig contains the settings of the alerts which fire when the storage usage
+// crosses the thresholds.
+type UsageAlertsConfig struct {
+	Enabled  bool          `yaml:"enabled"`
+	Interval time.Duration `yaml:"interval"`
+	// IPFSRatio is the repo usage ratio (0-1) of an IPFS node which fires an alert.
+	IPFSRatio float64 `yaml:"ipfsratio"`
+	// CacheSize is the size of the cache, in bytes, which fires an alert.
+	CacheSize int64 `yaml:"cachesize"`
+	// Webhook receives the alerts in a POST request.
+	Webhook string `yaml:"webhook"`
+}
+
+// Usage statistics formats.
+const (
+	UsageStatsFormatJSON = "json"
+	UsageStatsFormatCSV  = "csv"
+)
+
+// DefaultUsageStatsInterval is the default interval of the usage statistics exports.
+const DefaultUsageStatsInterval = time.Hour
+
+// UsageStatsConfig contains the settings of the periodic export of the pulls, the pushes and
+// the bandwidth of each repository.
+type UsageStatsConfig struct {
+	Enabled  bool          `yaml:"enabled"`
+	Interval time.Duration `yaml:"interval"`
+	// Format is the format of the reports which are written to the cache: json or csv.
+	Format string `yaml:"format"`
+	// Webhook receives the reports in a POST request.
+	Webhook string `yaml:"webhook"`
+}
+
+// BandwidthConfig contains the settings of the accounting of the bytes which are served per
+// repository and per client.
+type BandwidthConfig struct {
+	Enabled bool `yaml:"enabled"`
+	// RepoCap and ClientCap limit the bytes which are served in a calendar month (UTC) per
+	// repository and per client. Zero is unlimited.
+	RepoCap   int64 `yaml:"repocap"`
+	ClientCap int64 `yaml:"clientcap"`
+}
+
+// APIKeysConfig contains the settings of the API keys which the registry and the admin API
+// requests should be authorized with.
+type APIKeysConfig struct {
+	Enabled bool `yaml:"enabled"`
+	// File is where the keys are stored, unless the shared state is configured.
+	File string `yaml:"file"`
+}
+
+// PruneUploadsConfig contains the settings of the scheduled pruning of the aborted uploads.
+type PruneUploadsConfig struct {
+	Enabled  bool          `yaml:"enabled"`
+	Interval time.Duration `yaml:"interval"`
+	Age      time.Duration `yaml:"age"`
+}
+
+// Default proxy timeouts.
+const (
+	DefaultReadTimeout  = time.Hour
+	DefaultWriteTimeout = time.Hour
+	DefaultIdleTimeout  = time.Second * 30
+	// DefaultOperationTimeout limits the Disco operations like making a repository global,
+	// which continue after the clients disconnect.
+	DefaultOperationTimeout = time.Minute * 30
+	// DefaultReplicationTimeout limits the replications between the IPFS nodes and the cache.
+	DefaultReplicationTimeout = time.Minute * 30
+)
+
+// TimeoutsConfig contains the timeouts of the proxy server and the operations. Zero disables
+// a timeout.
+type TimeoutsConfig struct {
+	Read        time.Duration
+	Write       time.Duration
+	Idle        time.Duration
+	Operation   time.Duration
+	Replication time.Duration
+}
+
+// timeoutSettings are the proxy timeouts in the config file. The timeouts which are not
+// set use the defaults, so that zero can disable a timeout.
+type timeoutSettings struct {
+	Read        *time.Duration `yaml:"read"`
+	Write       *time.Duration `yaml:"write"`
+	Idle        *time.Duration `yaml:"idle"`
+	Operation   *time.Duration `yaml:"operation"`
+	Replication *time.Duration `yaml:"replication"`
+}
+
+func (s *timeoutSettings) applyDefaults() {
+	s.Read = durationOrDefault(s.Read, DefaultReadTimeout)
+	s.Write = durationOrDefault(s.Write, DefaultWriteTimeout)
+	s.Idle = durationOrDefault(s.Idle, DefaultIdleTimeout)
+	s.Operation = durationOrDefault(s.Operation, DefaultOperationTimeout)
+	s.Replication = durationOrDefault(s.Replication, DefaultReplicationTimeout)
+}
+
+func durationOrDefault(d *time.Duration, defaultValue time.Duration) *time.Duration {
+	if d != nil {
+		return d
+	}
+	return &defaultValue
+}
+
+// Default reverse proxy transport settings.
+const (
+	DefaultProxyMaxIdleConns        = 100
+	DefaultProxyMaxIdleConnsPerHost = 100
+	// DefaultProxyFlushInterval flushes the responses immediately so that the blobs are streamed.
+	DefaultProxyFlushInterval = time.Duration(-1)
+	// DefaultProxyBufferSize is the size of the buffers which the responses are copied with.
+	DefaultProxyBufferSize = 32 << 10
+)
+
+// ProxyConfig contains the HTTP settings of the proxy and its transport to the registry.
+type ProxyConfig struct {
+	// HTTP2 enables HTTP/2 without TLS (h2c) between the clients and the proxy. HTTP/2 is
+	// always available with TLS.
+	HTTP2 bool `yaml:"http2"`
+	// Compression compresses the manifest, catalog, tag list and admin responses with gzip
+	// for the clients which accept it. The blobs are never compressed.
+	Compression         bool `yaml:"compression"`
+	MaxIdleConns        int  `yaml:"maxidleconns"`
+	MaxIdleConnsPerHost int  `yaml:"maxidleconnsperhost"`
+	// ResponseHeaderTimeout limits the time to wait for the registry response headers.
+	ResponseHeaderTimeout time.Duration `yaml:"responseheadertimeout"`
+	// FlushInterval is how often the responses are flushed to the clients. A negative value
+	// flushes immediately after each write.
+	FlushInterval time.Duration `yaml:"flushinterval"`
+	// BufferSize is the size of the buffers which the responses are copied from the registry
+	// to the clients with. Each response in progress holds one buffer.
+	BufferSize int `yaml:"buffersize"`
+}
+
+func (proxyCfg *ProxyConfig) applyDefaults() {
+	if proxyCfg.MaxIdleConns == 0 {
+		proxyCfg.MaxIdleConns = DefaultProxyMaxIdleConns
+	}
+	if proxyCfg.MaxIdleConnsPerHost == 0 {
+		proxyCfg.MaxIdleConnsPerHost = DefaultProxyMaxIdleConnsPerHost
+	}
+	if proxyCfg.FlushInterval == 0 {
+		proxyCfg.FlushInterval = DefaultProxyFlushInterval
+	}
+	if proxyCfg.BufferSize == 0 {
+		proxyCfg.BufferSize = DefaultProxyBufferSize
+	}
+}
+
+// ProviderRedis is the provider of the distributed lock and the shared state which uses Redis.
+const ProviderRedis = "redis"
+
+// Default lock settings.
+const (
+	DefaultLockTTL    = 30 * time.Second
+	DefaultLockPrefix = "disco/lock/"
+)
+
+// LockConfig contains the settings of the distributed lock which keeps the Disco replicas
+// behind a load balancer from making global and deleting the same repository at the same time.
+type LockConfig struct {
+	// Provider is the lock provider and empty if the repositories are not locked.
+	Provider string `yaml:"provider"`
+	Addr     string `yaml:"addr"`
+	Password string `yaml:"password"`
+	DB       int    `yaml:"db"`
+	// TTL is how long a lock is held after its holder stops refreshing it, e.g. after a crash.
+	TTL time.Duration `yaml:"ttl"`
+	// Prefix is prepended to the lock keys.
+	Prefix string `yaml:"prefix"`
+}
+
+func (lockCfg *LockConfig) applyDefaults() {
+	if lockCfg.TTL == 0 {
+		lockCfg.TTL = DefaultLockTTL
+	}
+	if len(lockCfg.Prefix) == 0 {
+		lockCfg.Prefix = DefaultLockPrefix
+	}
+}
+
+// DefaultSharedStatePrefix is prepended to the keys of the shared state by default.
+const DefaultSharedStatePrefix = "disco/state/"
+
+// SharedStateConfig contains the settings of the store which the Disco replicas behind a load
+// balancer share the pull index, the CID index and the clone cache in.
+type SharedStateConfig struct {
+	// Provider is the store provider and empty if the state is kept by each replica.
+	Provider string `yaml:"provider"`
+	Addr     string `yaml:"addr"`
+	Password string `yaml:"password"`
+	DB       int    `yaml:"db"`
+	// Prefix is prepended to the keys.
+	Prefix string `yaml:"prefix"`
+}
+
+func (stateCfg *SharedStateConfig) applyDefaults() {
+	if len(stateCfg.Prefix) == 0 {
+		stateCfg.Prefix = DefaultSharedStatePrefix
+	}
+}
+
+// DefaultPullPolicyTimeout is the default timeout of the requests to the pull policy endpoint.
+const DefaultPullPolicyTimeout = 5 * time.Second
+
+// PullPolicyConfig contains the policies which are evaluated before the manifests are pulled.
+type PullPolicyConfig struct {
+	// Deny lists the repositories, e.g. CIDs, which cannot be pulled.
+	Deny []string `yaml:"deny"`
+	// Endpoint is the URL of the external policy which decides the pulls, in the OPA style.
+	Endpoint string        `yaml:"endpoint"`
+	Token    string        `yaml:"token"`
+	Timeout  time.Duration `yaml:"timeout"`
+	// FailOpen allows the pulls when the endpoint fails.
+	FailOpen bool `yaml:"failopen"`
+}
+
+func (policyCfg *PullPolicyConfig) applyDefaults() {
+	if policyCfg.Timeout == 0 {
+		policyCfg.Timeout = DefaultPullPolicyTimeout
+	}
+}
+
+// Repository name resolver kinds.
+const (
+	NameResolverIdentity = "identity"
+	NameResolverDigest   = "digest"
+	NameResolverENS      = "ens"
+	NameResolverForta    = "forta"
+)
+
+// Default repository name resolution settings.
+const (
+	DefaultNamesTTL     = time.Minute
+	DefaultNamesTimeout = 10 * time.Second
+	// DefaultENSRegistry is the address of the ENS registry on the Ethereum mainnet.
+	DefaultENSRegistry = "0x00000000000C2E074eC69A0dFb2997BA6C7d2e1e"
+)
+
+// NamesConfig contains the chain of the resolvers which resolve the repository names of the
+// pulls to the CID v1 repositories.
+type NamesConfig struct {
+	// Resolvers are tried in order until one of them knows the name.
+	Resolvers []*NameResolverConfig `yaml:"resolvers"`
+	// TTL is how long the resolved names are cached.
+	TTL time.Duration `yaml:"ttl"`
+	// Timeout limits the resolution of a name.
+	Timeout time.Duration `yaml:"timeout"`
+}
+
+// NameResolverConfig contains the settings of a resolver in the chain.
+type NameResolverConfig struct {
+	Kind string `yaml:"kind"`
+	// Endpoint is the Ethereum JSON-RPC endpoint of the ens and the forta resolvers.
+	Endpoint string `yaml:"endpoint"`
+	// Registry is the address of the ENS registry or the Forta agent registry contract.
+	Registry string `yaml:"registry"`
+}
+
+func (namesCfg *NamesConfig) applyDefaults() {
+	if namesCfg.TTL == 0 {
+		namesCfg.TTL = DefaultNamesTTL
+	}
+	if namesCfg.Timeout == 0 {
+		namesCfg.Timeout = DefaultNamesTimeout
+	}
+	for _, resolver := range namesCfg.Resolvers {
+		if resolver.Kind == NameResolverENS && len(resolver.Registry) == 0 {
+			resolver.Registry = DefaultENSRegistry
+		}
+	}
+}
+
+// DefaultScannerTimeout is the default timeout of the requests to the scanner.
+const DefaultScannerTimeout = 5 * time.Minute
+
+// ScannerConfig contains the settings of the security scanner which the pushed images should
+// pass before they are made global.
+type ScannerConfig struct {
+	// Endpoint is the URL of the scanner webhook.
+	Endpoint string        `yaml:"endpoint"`
+	Token    string        `yaml:"token"`
+	Timeout  time.Duration `yaml:"timeout"`
+	// FailOpen makes the images global when the scanner fails.
+	FailOpen bool `yaml:"failopen"`
+}
+
+func (scannerCfg *ScannerConfig) applyDefaults() {
+	if scannerCfg.Timeout == 0 {
+		scannerCfg.Timeout = DefaultScannerTimeout
+	}
+}
+
+// LimitsConfig contains the resource limits of the proxy.
+type LimitsConfig struct {
+	// MaxInflightBytes limits the bytes of the uploads which are streamed through the proxy
+	// at the same time. The new uploads wait while the uploads in progress exceed it.
+	MaxInflightBytes int64 `yaml:"maxinflightbytes"`
+	// MaxUploads limits the upload requests which are proxied at the same time.
+	MaxUploads int `yaml:"maxuploads"`
+	// MaxClones limits the repositories which are cloned from the IPFS network at the same time.
+	MaxClones int `yaml:"maxclones"`
+	// QueueSize is how many uploads and clones can wait beyond the limits. The others are
+	// rejected with 429 Too Many Requests.
+	QueueSize int `yaml:"queuesize"`
+	// HighPriority are the repositories and the CIDs which are cloned before the others and
+	// preempt the background clones, e.g. prefetching, when the clone limit is reached.
+	HighPriority []string `yaml:"highpriority"`
+}
+
+// DefaultUnixSocketMode is the default file mode of the unix socket of the proxy.
+const DefaultUnixSocketMode = os.FileMode(0660)
+
+// UnixSocketConfig contains the settings of the unix socket which the proxy listens on in
+// addition to or instead of the TCP port.
+type UnixSocketConfig struct {
+	Path string `yaml:"path"`
+	// Mode is the file mode of the socket in octal, e.g. "0660".
+	Mode string `yaml:"mode"`
+	// NoTCP disables the TCP listener so that the proxy listens only on the unix socket.
+	NoTCP bool `yaml:"notcp"`
+}
+
+// FileMode returns the file mode of the socket or the default mode if it is not set.
+func (socketCfg *UnixSocketConfig) FileMode() (os.FileMode, error) {
+	if len(socketCfg.Mode) == 0 {
+		return DefaultUnixSocketMode, nil
+	}
+	mode, err := strconv.ParseUint(socketCfg.Mode, 8, 32)
+	if err != nil || mode > 0777 {
+		return 0, fmt.Errorf("invalid file mode '%s'", socketCfg.Mode)
+	}
+	return os.FileMode(mode), nil
+}
+
+// AdminConfig contains the settings of the admin API of the proxy under /disco/.
+type AdminConfig struct {
+	// Token is the bearer token which the admin requests should have. The admin API is
+	// disabled on the proxy port if it and the HMAC secret are empty.
+	Token string `yaml:"token"`
+	// Addr is the dedicated address which the admin API, the health check, the profiles and
+	// the metrics are served on instead of the proxy port, e.g. "127.0.0.1:1971". It needs
+	// the token, the HMAC secret or the API keys.
+	Addr string `yaml:"addr"`
+	// HMACSecret is the shared secret which the Disco nodes can sign their admin requests with
+	// instead of sending the token, e.g. between the nodes of a federation.
+	HMACSecret string `yaml:"hmacsecret"`
+	// HMACMaxSkew is how old or how far in the future a signed request can be.
+	HMACMaxSkew time.Duration `yaml:"hmacmaxskew"`
+}
+
+// DefaultHMACMaxSkew is the default maximum clock skew of the signed admin requests.
+const DefaultHMACMaxSkew = 5 * time.Minute
+
+func (adminCfg *AdminConfig) applyDefaults() {
+	if adminCfg.HMACMaxSkew == 0 {
+		adminCfg.HMACMaxSkew = DefaultHMACMaxSkew
+	}
+}
+
+// VirtualHostConfig maps a request host to a separate registry which is configured with
+// its own config file.
+type VirtualHostConfig struct {
+	Host string `yaml:"host"`
+	// Config is the path of the config file of the registry. The registry should listen on
+	// a different address than the others.
+	Config string `yaml:"config"`
+}
+
+// TenantConfig isolates a namespace in a separate registry which is configured with its
+// own config file. The repositories under the namespace are served from the root of the
+// tenant registry, e.g. <namespace>/myrepo is myrepo in the tenant registry.
+type TenantConfig struct {
+	Namespace string `yaml:"namespace"`
+	// Config is the path of the config file of the tenant registry.
+	Config string `yaml:"config"`
+	// Users are the basic auth users which can access the namespace. Everyone can access it
+	// if it is empty.
+	Users []string `yaml:"users"`
+	// Quota is the maximum size of the blobs in the tenant registry, in bytes. The pushes
+	// are denied after it is exceeded.
+	Quota int64 `yaml:"quota"`
+}
+
+// DefaultWriteChunkSize is the size of the chunks which the writes to the IPFS nodes are
+// buffered into by default. It matches the default chunk size of the IPFS nodes.
+const DefaultWriteChunkSize = 256 * 1024
+
+// DefaultCanonicalTag is the tag which the pushed repositories are made global with by default.
+const DefaultCanonicalTag = "latest"
+
+// DefaultCloneCacheTTL is how long the CID v1 repositories are remembered as present in the
+// storage by default.
+const DefaultCloneCacheTTL = time.Minute
+
+// CloneCacheConfig contains the settings of the memory cache which remembers the CID v1
+// repositories which are present in the storage, so that they are not checked on every pull.
+type CloneCacheConfig struct {
+	Disabled bool          `yaml:"disabled"`
+	TTL      time.Duration `yaml:"ttl"`
+}
+
+// DefaultMetadataCacheTTL is how long the metadata is cached in the shared state by default.
+const DefaultMetadataCacheTTL = 30 * time.Second
+
+// MetadataCacheConfig contains the settings of the cache which keeps the hot metadata of the
+// storage, e.g. the manifest links and the stat results, in the shared state, so that the
+// Disco replicas do not read it from the IPFS nodes on every request.
+type MetadataCacheConfig struct {
+	Enabled bool          `yaml:"enabled"`
+	TTL     time.Duration `yaml:"ttl"`
+}
+
+func (cacheCfg *MetadataCacheConfig) applyDefaults() {
+	if cacheCfg.TTL == 0 {
+		cacheCfg.TTL = DefaultMetadataCacheTTL
+	}
+}
+
+// Compression levels of the cache.
+const (
+	CompressionFastest = "fastest"
+	CompressionDefault = "default"
+	CompressionBetter  = "better"
+	CompressionBest    = "best"
+)
+
+// CacheCompressionConfig contains the settings of the zstd compression of the blobs in the
+// cache. The blobs which are already compressed, e.g. the gzip and the zstd layers, are stored
+// as they are.
+type CacheCompressionConfig struct {
+	Enabled bool `yaml:"enabled"`
+	// Level is "fastest", "default" (default), "better" or "best".
+	Level string `yaml:"level"`
+}
+
+func (compressionCfg *CacheCompressionConfig) applyDefaults() {
+	if len(compressionCfg.Level) == 0 {
+		compressionCfg.Level = CompressionDefault
+	}
+}
+
+// PinningConfig contains the pinning settings.
+type PinningConfig struct {
+	// RemoteServices are the names of the remote pinning services which are configured in
+	// the IPFS nodes (see "ipfs pin remote service add").
+	RemoteServices []string `yaml:"remoteservices"`
+}
+
+// Config contains the Disco configuration. It is created once and passed to
+// the components which need it.
+type Config struct {
+	Vars         EnvVars
+	Distribution *configuration.Configuration
+	Router       RouterConfig
+	Cache        configuration.Storage
+	CacheOnly    bool
+	// CacheCompression compresses the blobs in the cache.
+	CacheCompression CacheCompressionConfig
+	RedirectTo       *url.URL
+	// WriteChunkSize is the size of the chunks which the writes to the IPFS nodes are buffered into.
+	WriteChunkSize int
+	NoClone        bool
+	NoPrefetch     bool
+	// LazyClone clones only the manifests and the configs of the pulled repositories and copies
+	// the layers on their first requests.
+	LazyClone bool
+	// StreamBlobs serves the first requests of the lazily cloned layers from the IPFS network while
+	// the layers are copied in the background.
+	StreamBlobs bool
+	// CanonicalTag is the tag which the pushed repositories are made global with.
+	CanonicalTag string
+	// DigestPushes makes the repositories which are pushed only by digest global.
+	DigestPushes DigestPushesConfig
+	PruneUploads PruneUploadsConfig
+	// DigestRetention prunes the digest repositories which were not pulled for a while.
+	DigestRetention DigestRetentionConfig
+	Snapshots       SnapshotsConfig
+	PublishRoot     PublishRootConfig
+	Warmup          WarmupConfig
+	UsageAlerts     UsageAlertsConfig
+	UsageStats      UsageStatsConfig
+	Bandwidth       BandwidthConfig
+	APIKeys         APIKeysConfig
+	CloneCache      CloneCacheConfig
+	MetadataCache   MetadataCacheConfig
+	Pinning         PinningConfig
+	Timeouts        TimeoutsConfig
+	Proxy           ProxyConfig
+	Lock            LockConfig
+	SharedState     SharedStateConfig
+	PullPolicy      PullPolicyConfig
+	Names           NamesConfig
+	Scanner         ScannerConfig
+	Limits          LimitsConfig
+	Admin           AdminConfig
+	Log             LogConfig
+	Tracing         TracingConfig
+	UnixSocket      UnixSocketConfig
+	VirtualHosts    []*VirtualHostConfig
+	Tenants         []*TenantConfig
+	PushRules       []*PushRule
+	// PushKeys are the public keys which the pushes should be signed with, if any.
+	PushKeys []crypto.PublicKey
+	// PullKeys are the public keys which the manifests of the CID repositories should be signed
+	// with before they are served, if any.
+	PullKeys    []crypto.PublicKey
+	PullSigning PullSigningConfig
+	// PullIndex is the file which the last pull times of the repositories are recorded to.
+	PullIndex string
+	// CidIndex is the bbolt database file which maps the manifest and blob digests to their CIDs.
+	CidIndex string
+	// ServerTLS is the TLS config of the proxy listener and nil if TLS is disabled.
+	ServerTLS *tls.Config
+	// ClientTLS is the TLS config of the HTTP clients and nil if the defaults are used.
+	ClientTLS *tls.Config
+
+	// files are the files which the config was loaded from.
+	files []string
+
+	reloadMu       sync.Mutex
+	reloadHandlers []func()
+}
+
+// discoSettings contains the extra configuration settings that blend with
 // the distribution library config.
-var discoConfig struct {
+type discoSettings struct {
 	Storage struct {
 		IPFS struct {
 			Router    RouterConfig          `yaml:"router"`
 			Cache     configuration.Storage `yaml:"cache"`
 			CacheOnly bool                  `yaml:"cacheonly"`
-			Redirect  string                `yaml:"redirect"`
+			// CacheCompression compresses the blobs in the cache.
+			CacheCompression CacheCompressionConfig `yaml:"cachecompression"`
+			Redirect         string                 `yaml:"redirect"`
+			// WriteChunkSize is the size of the chunks which the writes to the IPFS nodes are
+			// buffered into.
+			WriteChunkSize int `yaml:"writechunksize"`
 		} `yaml:"ipfs"`
 	} `yaml:"storage"`
 	Disco struct {
-		NoClone bool `yaml:"noclone"`
+		NoClone         bool                  `yaml:"noclone"`
+		NoPrefetch      bool                  `yaml:"noprefetch"`
+		LazyClone       bool                  `yaml:"lazyclone"`
+		StreamBlobs     bool                  `yaml:"streamblobs"`
+		CanonicalTag    string                `yaml:"canonicaltag"`
+		DigestPushes    DigestPushesConfig    `yaml:"digestpushes"`
+		Port            int                   `yaml:"port"`
+		Secrets         SecretsConfig         `yaml:"secrets"`
+		TLS             TLSConfig             `yaml:"tls"`
+		PruneUploads    PruneUploadsConfig    `yaml:"pruneuploads"`
+		DigestRetention DigestRetentionConfig `yaml:"digestretention"`
+		Snapshots       SnapshotsConfig       `yaml:"snapshots"`
+		PublishRoot     PublishRootConfig     `yaml:"publishroot"`
+		Warmup          WarmupConfig          `yaml:"warmup"`
+		UsageAlerts     UsageAlertsConfig     `yaml:"usagealerts"`
+		UsageStats      UsageStatsConfig      `yaml:"usagestats"`
+		Bandwidth       BandwidthConfig       `yaml:"bandwidth"`
+		APIKeys         APIKeysConfig         `yaml:"apikeys"`
+		CloneCache      CloneCacheConfig      `yaml:"clonecache"`
+		MetadataCache   MetadataCacheConfig   `yaml:"metadatacache"`
+		Pinning         PinningConfig         `yaml:"pinning"`
+		Timeouts        timeoutSettings       `yaml:"timeouts"`
+		Proxy           ProxyConfig           `yaml:"proxy"`
+		Lock            LockConfig            `yaml:"lock"`
+		SharedState     SharedStateConfig     `yaml:"sharedstate"`
+		PullPolicy      PullPolicyConfig      `yaml:"pullpolicy"`
+		Names           NamesConfig           `yaml:"names"`
+		Scanner         ScannerConfig         `yaml:"scanner"`
+		Limits          LimitsConfig          `yaml:"limits"`
+		Admin           AdminConfig           `yaml:"admin"`
+		Log             LogConfig             `yaml:"log"`
+		Tracing         TracingConfig         `yaml:"tracing"`
+		UnixSocket      UnixSocketConfig      `yaml:"unixsocket"`
+		VirtualHosts    []*VirtualHostConfig  `yaml:"virtualhosts"`
+		Tenants         []*TenantConfig       `yaml:"tenants"`
+		PushRules       []*PushRule           `yaml:"pushrules"`
+		PushSigning     PushSigningConfig     `yaml:"pushsigning"`
+		PullSigning     PullSigningConfig     `yaml:"pullsigning"`
+		PullIndex       string                `yaml:"pullindex"`
+		CidIndex        string                `yaml:"cidindex"`
 	} `yaml:"disco"`
 }
 
-// Init parses and prepares all config variables.
-func Init() error {
-	envconfig.MustProcess("", &Vars)
+// Init reads the environment variables and loads the config from the file
+// which they point to.
+func Init() (*Config, error) {
+	return InitWithPath("")
+}
 
-	if len(Vars.RegistryConfigurationPath) == 0 {
-		homeDirPath, err := os.UserHomeDir()
-		if err != nil {
-			return fmt.Errorf("failed to get user home dir: %v", err)
-		}
-		Vars.RegistryConfigurationPath = path.Join(homeDirPath, defaultHomeDirDiscoConfigPath)
+// InitWithPath is similar to Init but loads the config from given path, unless it is empty.
+func InitWithPath(configPath string) (*Config, error) {
+	var vars EnvVars
+	if err := envconfig.Process("", &vars); err != nil {
+		return nil, fmt.Errorf("failed to read the environment variables: %v", err)
+	}
+	if len(configPath) > 0 {
+		vars.RegistryConfigurationPath = configPath
+	}
+	var err error
+	vars.RegistryConfigurationPath, err = ResolvePath(vars.RegistryConfigurationPath)
+	if err != nil {
+		return nil, err
+	}
+	log.WithField("config", vars.RegistryConfigurationPath).Info("found configuration")
+	return Load(vars)
+}
+
+// Load loads the config from the file in vars.RegistryConfigurationPath by using
+// the profile in vars.Profile, if any.
+func Load(vars EnvVars) (*Config, error) {
+	distrConfig, settings, files, err := readConfigFile(vars.RegistryConfigurationPath, vars.Profile)
+	if err != nil {
+		return nil, err
+	}
+	// the port from the environment takes precedence
+	if vars.DiscoPort == 0 {
+		vars.DiscoPort = settings.Disco.Port
+	}
+	if vars.DiscoPort == 0 {
+		vars.DiscoPort = defaultDiscoPort
+	}
+	redirectTo, err := parseRedirect(settings.Storage.IPFS.Redirect)
+	if err != nil {
+		return nil, err
+	}
+	serverTLS, err := settings.Disco.TLS.ServerConfig()
+	if err != nil {
+		return nil, fmt.Errorf("invalid disco.tls config: %v", err)
+	}
+	clientTLS, err := settings.Disco.TLS.ClientConfig()
+	if err != nil {
+		return nil, fmt.Errorf("invalid disco.tls config: %v", err)
+	}
+	pruneUploads := settings.Disco.PruneUploads
+	if pruneUploads.Interval == 0 {
+		pruneUploads.Interval = DefaultPruneUploadsInterval
+	}
+	if pruneUploads.Age == 0 {
+		pruneUploads.Age = DefaultPruneUploadsAge
+	}
+	digestRetention := settings.Disco.DigestRetention
+	if digestRetention.Interval == 0 {
+		digestRetention.Interval = DefaultDigestRetentionInterval
+	}
+	if digestRetention.Age == 0 {
+		digestRetention.Age = DefaultDigestRetentionAge
+	}
+	snapshots := settings.Disco.Snapshots
+	if snapshots.Interval == 0 {
+		snapshots.Interval = DefaultSnapshotsInterval
+	}
+	if snapshots.Retention == 0 {
+		snapshots.Retention = DefaultSnapshotsRetention
+	}
+	publishRoot := settings.Disco.PublishRoot
+	if publishRoot.Interval == 0 {
+		publishRoot.Interval = DefaultPublishRootInterval
+	}
+	usageAlerts := settings.Disco.UsageAlerts
+	if usageAlerts.Interval == 0 {
+		usageAlerts.Interval = DefaultUsageAlertsInterval
 	}
+	usageStats := settings.Disco.UsageStats
+	if usageStats.Interval == 0 {
+		usageStats.Interval = DefaultUsageStatsInterval
+	}
+	if len(usageStats.Format) == 0 {
+		usageStats.Format = UsageStatsFormatJSON
+	}
+	writeChunkSize := settings.Storage.IPFS.WriteChunkSize
+	if writeChunkSize == 0 {
+		writeChunkSize = DefaultWriteChunkSize
+	}
+	canonicalTag := settings.Disco.CanonicalTag
+	if len(canonicalTag) == 0 {
+		canonicalTag = DefaultCanonicalTag
+	}
+	cloneCache := settings.Disco.CloneCache
+	if cloneCache.TTL == 0 {
+		cloneCache.TTL = DefaultCloneCacheTTL
+	}
+	pushKeys, err := settings.Disco.PushSigning.LoadPublicKeys()
+	if err != nil {
+		return nil, fmt.Errorf("invalid disco.pushsigning config: %v", err)
+	}
+	pullKeys, err := settings.Disco.PullSigning.LoadPublicKeys()
+	if err != nil {
+		return nil, fmt.Errorf("invalid disco.pullsigning config: %v", err)
+	}
+	timeouts := settings.Disco.Timeouts
+	timeouts.applyDefaults()
+	proxyCfg := settings.Disco.Proxy
+	proxyCfg.applyDefaults()
+	lockCfg := settings.Disco.Lock
+	lockCfg.applyDefaults()
+	sharedState := settings.Disco.SharedState
+	sharedState.applyDefaults()
+	pullPolicy := settings.Disco.PullPolicy
+	pullPolicy.applyDefaults()
+	names := settings.Disco.Names
+	names.applyDefaults()
+	adminCfg := settings.Disco.Admin
+	adminCfg.applyDefaults()
+	scanner := settings.Disco.Scanner
+	scanner.applyDefaults()
+	metadataCache := settings.Disco.MetadataCache
+	metadataCache.applyDefaults()
+	warmup := settings.Disco.Warmup
+	warmup.applyDefaults()
+	digestPushes := settings.Disco.DigestPushes
+	digestPushes.applyDefaults()
+	tracing := settings.Disco.Tracing
+	tracing.applyDefaults()
+	router := settings.Storage.IPFS.Router
+	router.StatCache.applyDefaults()
+	cacheCompression := settings.Storage.IPFS.CacheCompression
+	cacheCompression.applyDefaults()
+	return &Config{
+		Vars:             vars,
+		Distribution:     distrConfig,
+		Router:           router,
+		Cache:            settings.Storage.IPFS.Cache,
+		CacheOnly:        settings.Storage.IPFS.CacheOnly,
+		CacheCompression: cacheCompression,
+		RedirectTo:       redirectTo,
+		WriteChunkSize:   writeChunkSize,
+		NoClone:          settings.Disco.NoClone,
+		NoPrefetch:       settings.Disco.NoPrefetch,
+		LazyClone:        settings.Disco.LazyClone,
+		StreamBlobs:      settings.Disco.StreamBlobs,
+		CanonicalTag:     canonicalTag,
+		DigestPushes:     digestPushes,
+		PruneUploads:     pruneUploads,
+		DigestRetention:  digestRetention,
+		Snapshots:        snapshots,
+		PublishRoot:      publishRoot,
+		Warmup:           warmup,
+		UsageAlerts:      usageAlerts,
+		UsageStats:       usageStats,
+		Bandwidth:        settings.Disco.Bandwidth,
+		APIKeys:          settings.Disco.APIKeys,
+		CloneCache:       cloneCache,
+		MetadataCache:    metadataCache,
+		Pinning:          settings.Disco.Pinning,
+		Timeouts: TimeoutsConfig{
+			Read:        *timeouts.Read,
+			Write:       *timeouts.Write,
+			Idle:        *timeouts.Idle,
+			Operation:   *timeouts.Operation,
+			Replication: *timeouts.Replication,
+		},
+		Proxy:        proxyCfg,
+		Lock:         lockCfg,
+		SharedState:  sharedState,
+		PullPolicy:   pullPolicy,
+		Names:        names,
+		Scanner:      scanner,
+		Limits:       settings.Disco.Limits,
+		Admin:        adminCfg,
+		Log:          settings.Disco.Log,
+		Tracing:      tracing,
+		UnixSocket:   settings.Disco.UnixSocket,
+		VirtualHosts: settings.Disco.VirtualHosts,
+		Tenants:      settings.Disco.Tenants,
+		PushRules:    settings.Disco.PushRules,
+		PushKeys:     pushKeys,
+		PullKeys:     pullKeys,
+		PullSigning:  settings.Disco.PullSigning,
+		PullIndex:    settings.Disco.PullIndex,
+		CidIndex:     settings.Disco.CidIndex,
+		ServerTLS:    serverTLS,
+		ClientTLS:    clientTLS,
+		files:        files,
+	}, nil
+}
 
-	file, err := os.Open(Vars.RegistryConfigurationPath)
+// ResolvePath returns the default config path in the user home dir if given path is empty.
+func ResolvePath(configPath string) (string, error) {
+	if len(configPath) > 0 {
+		return configPath, nil
+	}
+	homeDirPath, err := os.UserHomeDir()
 	if err != nil {
-		return fmt.Errorf("failed to open config file: %v", err)
+		return "", fmt.Errorf("failed to get user home dir: %v", err)
 	}
-	defer file.Close()
-	log.WithField("config", Vars.RegistryConfigurationPath).Info("found configuration")
+	return path.Join(homeDirPath, defaultHomeDirDiscoConfigPath), nil
+}
 
-	DistributionConfig, err = configuration.Parse(file)
+// readConfigFile parses the distribution config and the disco config from the file
+// and the files it includes, applies the profile and validates them.
+func readConfigFile(configPath, profile string) (distrConfig *configuration.Configuration, settings discoSettings, files []string, err error) {
+	raw, files, err := loadConfigFiles(configPath)
+	if err != nil {
+		return nil, settings, nil, err
+	}
+	b, err := applyProfile(raw, profile)
 	if err != nil {
-		return fmt.Errorf("error parsing %s: %v", Vars.RegistryConfigurationPath, err)
+		return nil, settings, nil, err
+	}
+	// interpolate in two passes: the secret providers can be configured by using
+	// the env and file references
+	b, err = interpolate(b, false)
+	if err != nil {
+		return nil, settings, nil, fmt.Errorf("failed to interpolate config values: %v", err)
+	}
+	var secretSettings discoSettings
+	if err := yaml.Unmarshal(b, &secretSettings); err != nil {
+		return nil, settings, nil, err
+	}
+	if err := configureSecrets(&secretSettings.Disco.Secrets); err != nil {
+		return nil, settings, nil, err
+	}
+	b, err = interpolate(b, true)
+	if err != nil {
+		return nil, settings, nil, fmt.Errorf("failed to interpolate config values: %v", err)
 	}
 
-	file, _ = os.Open(Vars.RegistryConfigurationPath)
-	defer file.Close()
-	err = yaml.NewDecoder(file).Decode(&discoConfig)
+	distrConfig, err = configuration.Parse(bytes.NewReader(b))
 	if err != nil {
-		return err
-	}
-	Router = discoConfig.Storage.IPFS.Router
-	Cache = discoConfig.Storage.IPFS.Cache
-	CacheOnly = discoConfig.Storage.IPFS.CacheOnly
-	NoClone = discoConfig.Disco.NoClone
-	if len(discoConfig.Storage.IPFS.Redirect) > 0 {
-		RedirectTo, err = url.Parse(discoConfig.Storage.IPFS.Redirect)
-		if err != nil {
-			return err
-		}
+		return nil, settings, nil, fmt.Errorf("error parsing %s: %v", configPath, err)
 	}
 
-	return nil
+	if err := yaml.Unmarshal(b, &settings); err != nil {
+		return nil, settings, nil, err
+	}
+	if err := applyEnvOverrides(&settings); err != nil {
+		return nil, settings, nil, err
+	}
+	if err := validate(raw, distrConfig, &settings); err != nil {
+		return nil, settings, nil, err
+	}
+	return distrConfig, settings, files, nil
+}
+
+func parseRedirect(redirect string) (*url.URL, error) {
+	if len(redirect) == 0 {
+		return nil, nil
+	}
+	return url.Parse(redirect)
 }
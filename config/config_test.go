@@ -0,0 +1,84 @@
+package config
+
+import (
+	"os"
+	"path"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestLoad_Timeouts(t *testing.T) {
+	r := require.New(t)
+
+	configPath := path.Join(t.TempDir(), "config.yaml")
+	r.NoError(os.WriteFile(configPath, []byte(`version: 0.1
+storage:
+  ipfs:
+    router:
+      nodes:
+        - url: http://localhost:5001
+`), 0644))
+	cfg, err := Load(EnvVars{RegistryConfigurationPath: configPath})
+	r.NoError(err)
+	r.Equal(TimeoutsConfig{
+		Read:        DefaultReadTimeout,
+		Write:       DefaultWriteTimeout,
+		Idle:        DefaultIdleTimeout,
+		Operation:   DefaultOperationTimeout,
+		Replication: DefaultReplicationTimeout,
+	}, cfg.Timeouts)
+
+	r.NoError(os.WriteFile(configPath, []byte(`version: 0.1
+storage:
+  ipfs:
+    router:
+      nodes:
+        - url: http://localhost:5001
+disco:
+  timeouts:
+    read: 10m
+    write: 0s
+`), 0644))
+	cfg, err = Load(EnvVars{RegistryConfigurationPath: configPath})
+	r.NoError(err)
+	r.Equal(TimeoutsConfig{
+		Read:        time.Minute * 10,
+		Write:       0,
+		Idle:        DefaultIdleTimeout,
+		Operation:   DefaultOperationTimeout,
+		Replication: DefaultReplicationTimeout,
+	}, cfg.Timeouts)
+
+	r.NoError(os.WriteFile(configPath, []byte(`version: 0.1
+storage:
+  ipfs:
+    router:
+      nodes:
+        - url: http://localhost:5001
+disco:
+  timeouts:
+    idle: -1s
+`), 0644))
+	_, err = Load(EnvVars{RegistryConfigurationPath: configPath})
+	r.Error(err)
+	r.Contains(err.Error(), "disco.timeouts.idle")
+}
+
+func TestUnixSocketConfig_FileMode(t *testing.T) {
+	r := require.New(t)
+
+	mode, err := (&UnixSocketConfig{}).FileMode()
+	r.NoError(err)
+	r.Equal(DefaultUnixSocketMode, mode)
+
+	mode, err = (&UnixSocketConfig{Mode: "0600"}).FileMode()
+	r.NoError(err)
+	r.Equal(os.FileMode(0600), mode)
+
+	_, err = (&UnixSocketConfig{Mode: "0999"}).FileMode()
+	r.Error(err)
+	_, err = (&UnixSocketConfig{Mode: "17777"}).FileMode()
+	r.Error(err)
+}
@@ -0,0 +1,154 @@
+package config
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/kelseyhightower/envconfig"
+	"gopkg.in/yaml.v3"
+)
+
+// redactedValue replaces the secret values in the effective config.
+const redactedValue = "<redacted>"
+
+// secretKeys are the keys of the secret values in the distribution and disco configs, in lower case.
+var secretKeys = map[string]bool{
+	"accesskey":     true,
+	"accountkey":    true,
+	"authorization": true,
+	"password":      true,
+	"privatekey":    true,
+	"private_key":   true,
+	"secret":        true,
+	"secretkey":     true,
+	"token":         true,
+}
+
+// Effective reads the config file like Load does and returns the resulting distribution and
+// disco config as a single YAML document. The includes, the profile, the interpolated values
+// and the REGISTRY_* and DISCO_* environment overrides are applied to the result and the
+// secret values are redacted.
+func Effective(configPath, profile string) ([]byte, error) {
+	distrConfig, settings, _, err := readConfigFile(configPath, profile)
+	if err != nil {
+		return nil, err
+	}
+	var vars EnvVars
+	if err := envconfig.Process("", &vars); err != nil {
+		return nil, fmt.Errorf("failed to read the environment variables: %v", err)
+	}
+	// apply the same defaults as Load
+	if vars.DiscoPort > 0 {
+		settings.Disco.Port = vars.DiscoPort
+	}
+	if settings.Disco.Port == 0 {
+		settings.Disco.Port = defaultDiscoPort
+	}
+	if settings.Disco.PruneUploads.Interval == 0 {
+		settings.Disco.PruneUploads.Interval = DefaultPruneUploadsInterval
+	}
+	if settings.Disco.PruneUploads.Age == 0 {
+		settings.Disco.PruneUploads.Age = DefaultPruneUploadsAge
+	}
+	if settings.Disco.DigestRetention.Interval == 0 {
+		settings.Disco.DigestRetention.Interval = DefaultDigestRetentionInterval
+	}
+	if settings.Disco.DigestRetention.Age == 0 {
+		settings.Disco.DigestRetention.Age = DefaultDigestRetentionAge
+	}
+	if settings.Disco.Snapshots.Interval == 0 {
+		settings.Disco.Snapshots.Interval = DefaultSnapshotsInterval
+	}
+	if settings.Disco.Snapshots.Retention == 0 {
+		settings.Disco.Snapshots.Retention = DefaultSnapshotsRetention
+	}
+	if settings.Disco.PublishRoot.Interval == 0 {
+		settings.Disco.PublishRoot.Interval = DefaultPublishRootInterval
+	}
+	if settings.Disco.UsageAlerts.Interval == 0 {
+		settings.Disco.UsageAlerts.Interval = DefaultUsageAlertsInterval
+	}
+	if settings.Disco.UsageStats.Interval == 0 {
+		settings.Disco.UsageStats.Interval = DefaultUsageStatsInterval
+	}
+	if len(settings.Disco.UsageStats.Format) == 0 {
+		settings.Disco.UsageStats.Format = UsageStatsFormatJSON
+	}
+	if settings.Storage.IPFS.WriteChunkSize == 0 {
+		settings.Storage.IPFS.WriteChunkSize = DefaultWriteChunkSize
+	}
+	if len(settings.Disco.CanonicalTag) == 0 {
+		settings.Disco.CanonicalTag = DefaultCanonicalTag
+	}
+	if settings.Disco.CloneCache.TTL == 0 {
+		settings.Disco.CloneCache.TTL = DefaultCloneCacheTTL
+	}
+	settings.Disco.Timeouts.applyDefaults()
+	settings.Disco.Proxy.applyDefaults()
+	settings.Disco.Lock.applyDefaults()
+	settings.Disco.SharedState.applyDefaults()
+	settings.Disco.PullPolicy.applyDefaults()
+	settings.Disco.Names.applyDefaults()
+	settings.Disco.Scanner.applyDefaults()
+	settings.Disco.Admin.applyDefaults()
+	settings.Disco.MetadataCache.applyDefaults()
+	settings.Disco.Warmup.applyDefaults()
+	settings.Disco.DigestPushes.applyDefaults()
+	settings.Disco.Tracing.applyDefaults()
+	settings.Storage.IPFS.Router.StatCache.applyDefaults()
+
+	distrNode, err := toYAMLNode(distrConfig)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode the distribution config: %v", err)
+	}
+	settingsNode, err := toYAMLNode(&settings)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode the disco config: %v", err)
+	}
+	root := mergeNodes(distrNode, settingsNode)
+	redactSecrets(root)
+	return yaml.Marshal(root)
+}
+
+func toYAMLNode(v interface{}) (*yaml.Node, error) {
+	b, err := yaml.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+	var root yaml.Node
+	if err := yaml.Unmarshal(b, &root); err != nil {
+		return nil, err
+	}
+	if len(root.Content) == 0 {
+		return &yaml.Node{Kind: yaml.MappingNode}, nil
+	}
+	return root.Content[0], nil
+}
+
+// redactSecrets replaces the values of the secret keys recursively.
+func redactSecrets(node *yaml.Node) {
+	if node.Kind == yaml.MappingNode {
+		for i := 0; i+1 < len(node.Content); i += 2 {
+			key, value := node.Content[i], node.Content[i+1]
+			if secretKeys[strings.ToLower(key.Value)] && !isEmptyNode(value) {
+				node.Content[i+1] = &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!str", Value: redactedValue}
+				continue
+			}
+			redactSecrets(value)
+		}
+		return
+	}
+	for _, child := range node.Content {
+		redactSecrets(child)
+	}
+}
+
+func isEmptyNode(node *yaml.Node) bool {
+	switch node.Kind {
+	case yaml.ScalarNode:
+		return len(node.Value) == 0 || node.Tag == "!!null"
+	case yaml.MappingNode, yaml.SequenceNode:
+		return len(node.Content) == 0
+	}
+	return false
+}
@@ -0,0 +1,58 @@
+package config
+
+import (
+	"os"
+	"path"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"gopkg.in/yaml.v3"
+)
+
+func TestEffective(t *testing.T) {
+	r := require.New(t)
+
+	configPath := path.Join(t.TempDir(), "config.yaml")
+	r.NoError(os.WriteFile(configPath, []byte(`version: 0.1
+log:
+  level: info
+http:
+  addr: :5000
+  secret: registry-secret
+storage:
+  ipfs:
+    router:
+      nodes:
+        - url: http://localhost:5001
+    cache:
+      s3:
+        bucket: file-bucket
+        accesskey: AKIAEXAMPLE
+        secretkey: hunter2
+`), 0644))
+	t.Setenv("REGISTRY_LOG_LEVEL", "debug")
+	t.Setenv("DISCO_STORAGE_IPFS_REDIRECT", "https://redirect.url")
+
+	b, err := Effective(configPath, "")
+	r.NoError(err)
+	r.NotContains(string(b), "registry-secret")
+	r.NotContains(string(b), "AKIAEXAMPLE")
+	r.NotContains(string(b), "hunter2")
+
+	var effective struct {
+		Log struct {
+			Level string `yaml:"level"`
+		} `yaml:"log"`
+		HTTP struct {
+			Secret string `yaml:"secret"`
+		} `yaml:"http"`
+		discoSettings `yaml:",inline"`
+	}
+	r.NoError(yaml.Unmarshal(b, &effective))
+	r.Equal("debug", effective.Log.Level)
+	r.Equal(redactedValue, effective.HTTP.Secret)
+	r.Equal("https://redirect.url", effective.Storage.IPFS.Redirect)
+	r.Equal("file-bucket", effective.Storage.IPFS.Cache["s3"]["bucket"])
+	r.Equal(redactedValue, effective.Storage.IPFS.Cache["s3"]["secretkey"])
+	r.Equal(defaultDiscoPort, effective.Disco.Port)
+}
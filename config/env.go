@@ -0,0 +1,95 @@
+package config
+
+import (
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// envOverride applies an environment variable value to the disco settings.
+type envOverride func(settings *discoSettings, value string) error
+
+// envOverrides maps the environment variables to the disco settings that they override.
+// The values are parsed as YAML, similar to the REGISTRY_* overrides of the distribution config.
+var envOverrides = map[string]envOverride{
+	"DISCO_STORAGE_IPFS_CACHEONLY": yamlOverride(func(settings *discoSettings) interface{} {
+		return &settings.Storage.IPFS.CacheOnly
+	}),
+	"DISCO_STORAGE_IPFS_REDIRECT": stringOverride(func(settings *discoSettings) *string {
+		return &settings.Storage.IPFS.Redirect
+	}),
+	"DISCO_STORAGE_IPFS_CACHE": yamlOverride(func(settings *discoSettings) interface{} {
+		return &settings.Storage.IPFS.Cache
+	}),
+	"DISCO_ROUTER_NODES": overrideRouterNodes,
+	"DISCO_ROUTER_PLACEMENT": stringOverride(func(settings *discoSettings) *string {
+		return &settings.Storage.IPFS.Router.Placement
+	}),
+	"DISCO_ROUTER_PLACEMENTINDEX": stringOverride(func(settings *discoSettings) *string {
+		return &settings.Storage.IPFS.Router.PlacementIndex
+	}),
+	"DISCO_ROUTER_MAXUSAGE": yamlOverride(func(settings *discoSettings) interface{} {
+		return &settings.Storage.IPFS.Router.MaxUsage
+	}),
+	"DISCO_NOCLONE": yamlOverride(func(settings *discoSettings) interface{} {
+		return &settings.Disco.NoClone
+	}),
+	"DISCO_NOPREFETCH": yamlOverride(func(settings *discoSettings) interface{} {
+		return &settings.Disco.NoPrefetch
+	}),
+	"DISCO_LAZYCLONE": yamlOverride(func(settings *discoSettings) interface{} {
+		return &settings.Disco.LazyClone
+	}),
+	"DISCO_STREAMBLOBS": yamlOverride(func(settings *discoSettings) interface{} {
+		return &settings.Disco.StreamBlobs
+	}),
+}
+
+func yamlOverride(field func(settings *discoSettings) interface{}) envOverride {
+	return func(settings *discoSettings, value string) error {
+		return yaml.Unmarshal([]byte(value), field(settings))
+	}
+}
+
+func stringOverride(field func(settings *discoSettings) *string) envOverride {
+	return func(settings *discoSettings, value string) error {
+		*field(settings) = value
+		return nil
+	}
+}
+
+// overrideRouterNodes accepts a comma-separated list of node URLs.
+func overrideRouterNodes(settings *discoSettings, value string) error {
+	var nodes []*Node
+	for _, nodeURL := range strings.Split(value, ",") {
+		nodeURL = strings.TrimSpace(nodeURL)
+		if len(nodeURL) == 0 {
+			continue
+		}
+		nodes = append(nodes, &Node{URL: nodeURL})
+	}
+	settings.Storage.IPFS.Router.Nodes = nodes
+	return nil
+}
+
+// applyEnvOverrides layers the DISCO_* environment variables over the settings from the file.
+func applyEnvOverrides(settings *discoSettings) error {
+	var names []string
+	for name := range envOverrides {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		value, ok := os.LookupEnv(name)
+		if !ok {
+			continue
+		}
+		if err := envOverrides[name](settings, value); err != nil {
+			return fmt.Errorf("invalid value in %s: %v", name, err)
+		}
+	}
+	return nil
+}
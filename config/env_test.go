@@ -0,0 +1,30 @@
+package config
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestApplyEnvOverrides(t *testing.T) {
+	r := require.New(t)
+
+	t.Setenv("DISCO_STORAGE_IPFS_CACHEONLY", "true")
+	t.Setenv("DISCO_STORAGE_IPFS_CACHE", "{filesystem: {rootdirectory: /tmp/cache}}")
+	t.Setenv("DISCO_ROUTER_NODES", "http://node1:5001, http://node2:5001")
+	t.Setenv("DISCO_NOCLONE", "true")
+
+	var settings discoSettings
+	settings.Storage.IPFS.Router.Nodes = []*Node{{URL: "http://localhost:5001"}}
+	r.NoError(applyEnvOverrides(&settings))
+
+	r.True(settings.Storage.IPFS.CacheOnly)
+	r.Equal("/tmp/cache", settings.Storage.IPFS.Cache["filesystem"]["rootdirectory"])
+	r.Len(settings.Storage.IPFS.Router.Nodes, 2)
+	r.Equal("http://node1:5001", settings.Storage.IPFS.Router.Nodes[0].URL)
+	r.Equal("http://node2:5001", settings.Storage.IPFS.Router.Nodes[1].URL)
+	r.True(settings.Disco.NoClone)
+
+	t.Setenv("DISCO_NOCLONE", "not-a-bool")
+	r.Error(applyEnvOverrides(&settings))
+}
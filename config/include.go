@@ -0,0 +1,150 @@
+package config
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+const includeKey = "include"
+
+// loadConfigFiles reads the config file and the files it includes with the include key
+// and merges them into a single YAML document. The included files are merged in the
+// order they are listed, and the including file is merged on top of them. Mappings are
+// merged key by key and the rest of the values are replaced. It also returns all of
+// the files which the config was loaded from.
+func loadConfigFiles(configPath string) ([]byte, []string, error) {
+	loader := &configLoader{visiting: make(map[string]bool)}
+	root, hasIncludes, err := loader.load(configPath)
+	if err != nil {
+		return nil, nil, err
+	}
+	if !hasIncludes {
+		return loader.raw, loader.files, nil
+	}
+	b, err := yaml.Marshal(root)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to merge config files: %v", err)
+	}
+	return b, loader.files, nil
+}
+
+type configLoader struct {
+	visiting map[string]bool
+	files    []string
+	raw      []byte // content of the first file
+}
+
+func (loader *configLoader) load(configPath string) (*yaml.Node, bool, error) {
+	absPath, err := filepath.Abs(configPath)
+	if err != nil {
+		return nil, false, fmt.Errorf("failed to resolve config path: %v", err)
+	}
+	if loader.visiting[absPath] {
+		return nil, false, fmt.Errorf("config file %s is included recursively", configPath)
+	}
+	loader.visiting[absPath] = true
+	defer delete(loader.visiting, absPath)
+
+	raw, err := os.ReadFile(configPath)
+	if err != nil {
+		return nil, false, fmt.Errorf("failed to open config file: %v", err)
+	}
+	if loader.raw == nil {
+		loader.raw = raw
+	}
+	loader.files = append(loader.files, configPath)
+
+	var doc yaml.Node
+	if err := yaml.Unmarshal(raw, &doc); err != nil {
+		return nil, false, fmt.Errorf("error parsing %s: %v", configPath, err)
+	}
+	if len(doc.Content) == 0 {
+		return &yaml.Node{Kind: yaml.MappingNode, Tag: "!!map"}, false, nil
+	}
+	root := doc.Content[0]
+	includes, err := removeIncludes(root)
+	if err != nil {
+		return nil, false, fmt.Errorf("invalid include in %s: %v", configPath, err)
+	}
+	if len(includes) == 0 {
+		return root, false, nil
+	}
+
+	merged := &yaml.Node{Kind: yaml.MappingNode, Tag: "!!map"}
+	for _, include := range includes {
+		if !filepath.IsAbs(include) {
+			include = filepath.Join(filepath.Dir(configPath), include)
+		}
+		matches, err := filepath.Glob(include) // sorted
+		if err != nil {
+			return nil, false, fmt.Errorf("invalid include pattern in %s: %v", configPath, err)
+		}
+		if len(matches) == 0 && !strings.ContainsAny(include, "*?[") {
+			return nil, false, fmt.Errorf("included config file %s not found", include)
+		}
+		for _, match := range matches {
+			includedRoot, _, err := loader.load(match)
+			if err != nil {
+				return nil, false, err
+			}
+			merged = mergeNodes(merged, includedRoot)
+		}
+	}
+	return mergeNodes(merged, root), true, nil
+}
+
+// removeIncludes removes the include key from the mapping and returns the paths from it.
+// The value can be a single path or a list of paths.
+func removeIncludes(root *yaml.Node) ([]string, error) {
+	if root.Kind != yaml.MappingNode {
+		return nil, nil
+	}
+	for i := 0; i+1 < len(root.Content); i += 2 {
+		if root.Content[i].Value != includeKey {
+			continue
+		}
+		value := root.Content[i+1]
+		root.Content = append(root.Content[:i], root.Content[i+2:]...)
+
+		var includes []string
+		switch value.Kind {
+		case yaml.ScalarNode:
+			includes = []string{value.Value}
+		case yaml.SequenceNode:
+			if err := value.Decode(&includes); err != nil {
+				return nil, err
+			}
+		default:
+			return nil, fmt.Errorf("expected a path or a list of paths (line %d)", value.Line)
+		}
+		return includes, nil
+	}
+	return nil, nil
+}
+
+// mergeNodes merges the src mapping into the dst mapping recursively. Any other kind of
+// src value replaces the dst value.
+func mergeNodes(dst, src *yaml.Node) *yaml.Node {
+	if dst.Kind != yaml.MappingNode || src.Kind != yaml.MappingNode {
+		return src
+	}
+	for i := 0; i+1 < len(src.Content); i += 2 {
+		key, value := src.Content[i], src.Content[i+1]
+		replaced := false
+		for j := 0; j+1 < len(dst.Content); j += 2 {
+			if dst.Content[j].Value == key.Value {
+				dst.Content[j+1] = mergeNodes(dst.Content[j+1], value)
+				replaced = true
+				break
+			}
+		}
+		if !replaced {
+			dst.Content = append(dst.Content, key, value)
+		}
+	}
+	return dst
+}
@@ -0,0 +1,72 @@
+package config
+
+import (
+	"os"
+	"path"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestReadConfigFile_Include(t *testing.T) {
+	r := require.New(t)
+
+	dir := t.TempDir()
+	r.NoError(os.Mkdir(path.Join(dir, "conf.d"), 0755))
+	r.NoError(os.WriteFile(path.Join(dir, "base.yaml"), []byte(`version: 0.1
+log:
+  level: info
+storage:
+  ipfs:
+    router:
+      nodes:
+        - url: http://localhost:5001
+`), 0644))
+	r.NoError(os.WriteFile(path.Join(dir, "conf.d", "01-cache.yaml"), []byte(`storage:
+  ipfs:
+    cache:
+      inmemory:
+`), 0644))
+	r.NoError(os.WriteFile(path.Join(dir, "conf.d", "02-redirect.yaml"), []byte(`storage:
+  ipfs:
+    redirect: https://first.url
+`), 0644))
+	configPath := path.Join(dir, "config.yaml")
+	r.NoError(os.WriteFile(configPath, []byte(`include:
+  - base.yaml
+  - conf.d/*.yaml
+log:
+  level: debug
+storage:
+  ipfs:
+    router:
+      nodes:
+        - url: http://localhost:5002
+        - url: http://localhost:5003
+`), 0644))
+
+	distrConfig, settings, files, err := readConfigFile(configPath, "")
+	r.NoError(err)
+	r.Len(files, 4)
+	r.Equal("debug", string(distrConfig.Log.Level))
+	r.Len(settings.Storage.IPFS.Router.Nodes, 2, "lists should be replaced")
+	r.Equal("http://localhost:5002", settings.Storage.IPFS.Router.Nodes[0].URL)
+	r.Contains(settings.Storage.IPFS.Cache, "inmemory")
+	r.Equal("https://first.url", settings.Storage.IPFS.Redirect)
+}
+
+func TestReadConfigFile_IncludeErrors(t *testing.T) {
+	r := require.New(t)
+
+	dir := t.TempDir()
+	configPath := path.Join(dir, "config.yaml")
+
+	r.NoError(os.WriteFile(configPath, []byte(`include: missing.yaml`), 0644))
+	_, _, _, err := readConfigFile(configPath, "")
+	r.Error(err)
+
+	r.NoError(os.WriteFile(configPath, []byte(`include: config.yaml`), 0644))
+	_, _, _, err = readConfigFile(configPath, "")
+	r.Error(err)
+	r.Contains(err.Error(), "recursively")
+}
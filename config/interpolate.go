@@ -0,0 +1,98 @@
+package config
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/forta-network/disco/secrets"
+	"gopkg.in/yaml.v3"
+)
+
+const secretTimeout = time.Second * 30
+
+// interpolationPattern matches the ${env:VAR}, ${file:/path/to/secret} and ${<provider>:<ref>} references.
+var interpolationPattern = regexp.MustCompile(`\$?\$\{([a-z0-9]+):([^}]+)\}`)
+
+// interpolate replaces the ${env:VAR} and ${file:/path} references in the config values
+// with the value of the environment variable or the content of the file. The references
+// to the secret providers, like ${vault:secret/data/disco#key}, are resolved only if
+// resolveSecrets is true. A reference can be escaped as $${env:VAR}.
+func interpolate(b []byte, resolveSecrets bool) ([]byte, error) {
+	if !interpolationPattern.Match(b) {
+		return b, nil
+	}
+	var root yaml.Node
+	if err := yaml.Unmarshal(b, &root); err != nil {
+		return nil, err
+	}
+	if err := interpolateNode(&root, resolveSecrets); err != nil {
+		return nil, err
+	}
+	return yaml.Marshal(&root)
+}
+
+func interpolateNode(node *yaml.Node, resolveSecrets bool) error {
+	if node.Kind == yaml.ScalarNode {
+		value, err := interpolateValue(node.Value, resolveSecrets)
+		if err != nil {
+			return fmt.Errorf("line %d: %v", node.Line, err)
+		}
+		if value != node.Value {
+			node.Value = value
+			node.Tag = "" // let the type be resolved again
+		}
+		return nil
+	}
+	for _, child := range node.Content {
+		if err := interpolateNode(child, resolveSecrets); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func interpolateValue(value string, resolveSecrets bool) (string, error) {
+	var err error
+	result := interpolationPattern.ReplaceAllStringFunc(value, func(ref string) string {
+		match := interpolationPattern.FindStringSubmatch(ref)
+		source, key := match[1], match[2]
+		isSecretRef := source != "env" && source != "file"
+		switch {
+		case isSecretRef != resolveSecrets:
+			return ref // resolve in the other pass
+
+		case strings.HasPrefix(ref, "$$"):
+			return ref[1:]
+		}
+
+		switch source {
+		case "env":
+			envValue, ok := os.LookupEnv(key)
+			if !ok {
+				err = fmt.Errorf("environment variable %s is not set", key)
+			}
+			return envValue
+
+		case "file":
+			b, readErr := os.ReadFile(key)
+			if readErr != nil {
+				err = fmt.Errorf("failed to read secret file: %v", readErr)
+			}
+			return strings.TrimRight(string(b), "\r\n")
+
+		default:
+			ctx, cancel := context.WithTimeout(context.Background(), secretTimeout)
+			defer cancel()
+			secret, secretErr := secrets.Resolve(ctx, source+":"+key)
+			if secretErr != nil {
+				err = fmt.Errorf("failed to resolve secret: %v", secretErr)
+			}
+			return secret
+		}
+	})
+	return result, err
+}
@@ -0,0 +1,87 @@
+package config
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path"
+	"testing"
+
+	"github.com/forta-network/disco/secrets"
+	"github.com/stretchr/testify/require"
+	"gopkg.in/yaml.v3"
+)
+
+func TestInterpolate(t *testing.T) {
+	r := require.New(t)
+
+	secretPath := path.Join(t.TempDir(), "secret")
+	r.NoError(os.WriteFile(secretPath, []byte("s3cr3t\n"), 0600))
+	t.Setenv("TEST_ACCESS_KEY", "key")
+	t.Setenv("TEST_PORT", "5000")
+
+	b, err := interpolate([]byte(`
+accesskey: ${env:TEST_ACCESS_KEY}
+secretkey: ${file:`+secretPath+`}
+port: ${env:TEST_PORT}
+url: http://${env:TEST_ACCESS_KEY}:${env:TEST_PORT}
+escaped: $${env:TEST_ACCESS_KEY}
+`), false)
+	r.NoError(err)
+
+	var values struct {
+		AccessKey string `yaml:"accesskey"`
+		SecretKey string `yaml:"secretkey"`
+		Port      int    `yaml:"port"`
+		URL       string `yaml:"url"`
+		Escaped   string `yaml:"escaped"`
+	}
+	r.NoError(yaml.Unmarshal(b, &values))
+	r.Equal("key", values.AccessKey)
+	r.Equal("s3cr3t", values.SecretKey)
+	r.Equal(5000, values.Port)
+	r.Equal("http://key:5000", values.URL)
+	r.Equal("${env:TEST_ACCESS_KEY}", values.Escaped)
+
+	_, err = interpolate([]byte(`accesskey: ${env:TEST_MISSING_KEY}`), false)
+	r.Error(err)
+}
+
+type testSecretProvider map[string]string
+
+func (p testSecretProvider) GetSecret(ctx context.Context, ref string) (string, error) {
+	value, ok := p[ref]
+	if !ok {
+		return "", errors.New("secret not found")
+	}
+	return value, nil
+}
+
+func TestInterpolate_Secrets(t *testing.T) {
+	r := require.New(t)
+
+	secrets.Register("testprovider", testSecretProvider{"r2#accesskey": "key"}, 0)
+
+	raw := []byte(`accesskey: ${testprovider:r2#accesskey}
+escaped: $${testprovider:r2#accesskey}
+`)
+	b, err := interpolate(raw, false)
+	r.NoError(err)
+	r.Equal(raw, b, "secret references should be skipped in the first pass")
+
+	b, err = interpolate(b, true)
+	r.NoError(err)
+
+	var values struct {
+		AccessKey string `yaml:"accesskey"`
+		Escaped   string `yaml:"escaped"`
+	}
+	r.NoError(yaml.Unmarshal(b, &values))
+	r.Equal("key", values.AccessKey)
+	r.Equal("${testprovider:r2#accesskey}", values.Escaped)
+
+	_, err = interpolate([]byte(`accesskey: ${testprovider:r2#missing}`), true)
+	r.Error(err)
+	_, err = interpolate([]byte(`accesskey: ${unknownprovider:r2#accesskey}`), true)
+	r.Error(err)
+}
@@ -0,0 +1,36 @@
+package config
+
+import (
+	log "github.com/sirupsen/logrus"
+)
+
+// Log formats.
+const (
+	LogFormatText = "text"
+	LogFormatJSON = "json"
+)
+
+// LogConfig contains the log settings of Disco. The level of the registry, log.level, is used
+// if the level is empty.
+type LogConfig struct {
+	Level  string `yaml:"level"`
+	Format string `yaml:"format"`
+}
+
+// ApplyLogging sets the log level and the format from the config. It should be called again
+// after the registry is created, since the registry sets them from its own config.
+func (cfg *Config) ApplyLogging() {
+	level := cfg.Log.Level
+	if len(level) == 0 && cfg.Distribution != nil {
+		level = string(cfg.Distribution.Log.Level)
+	}
+	if parsed, err := log.ParseLevel(level); err == nil {
+		log.SetLevel(parsed)
+	}
+	switch cfg.Log.Format {
+	case LogFormatJSON:
+		log.SetFormatter(&log.JSONFormatter{})
+	case LogFormatText:
+		log.SetFormatter(&log.TextFormatter{})
+	}
+}
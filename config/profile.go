@@ -0,0 +1,55 @@
+package config
+
+import (
+	"fmt"
+
+	"gopkg.in/yaml.v3"
+)
+
+const profilesKey = "profiles"
+
+// applyProfile merges the named profile from the profiles key over the rest of the config
+// and removes the profiles key. The profiles are partial configs, e.g.:
+//
+//	profiles:
+//	  dev:
+//	    storage:
+//	      ipfs:
+//	        cacheonly: true
+func applyProfile(b []byte, profile string) ([]byte, error) {
+	var doc yaml.Node
+	if err := yaml.Unmarshal(b, &doc); err != nil {
+		return nil, err
+	}
+	if len(doc.Content) == 0 || doc.Content[0].Kind != yaml.MappingNode {
+		if len(profile) > 0 {
+			return nil, fmt.Errorf("profile '%s' not found in the config", profile)
+		}
+		return b, nil
+	}
+	root := doc.Content[0]
+
+	var profiles *yaml.Node
+	for i := 0; i+1 < len(root.Content); i += 2 {
+		if root.Content[i].Value == profilesKey {
+			profiles = root.Content[i+1]
+			root.Content = append(root.Content[:i], root.Content[i+2:]...)
+			break
+		}
+	}
+	if profiles == nil {
+		if len(profile) > 0 {
+			return nil, fmt.Errorf("profile '%s' not found in the config", profile)
+		}
+		return b, nil
+	}
+
+	if len(profile) > 0 {
+		overlay := mappingValue(profiles, profile)
+		if overlay == nil {
+			return nil, fmt.Errorf("profile '%s' not found in the config", profile)
+		}
+		mergeNodes(root, overlay)
+	}
+	return yaml.Marshal(&doc)
+}
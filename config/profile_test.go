@@ -0,0 +1,83 @@
+package config
+
+import (
+	"os"
+	"path"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+const testProfilesConfig = `version: 0.1
+storage:
+  ipfs:
+    router:
+      nodes:
+        - url: http://localhost:5001
+disco:
+  port: 1971
+profiles:
+  staging:
+    storage:
+      ipfs:
+        router:
+          nodes:
+            - url: http://staging-1:5001
+            - url: http://staging-2:5001
+  prod:
+    storage:
+      ipfs:
+        cache:
+          inmemory:
+        cacheonly: true
+    disco:
+      port: 1980
+`
+
+func TestLoad_Profile(t *testing.T) {
+	r := require.New(t)
+
+	configPath := path.Join(t.TempDir(), "config.yaml")
+	r.NoError(os.WriteFile(configPath, []byte(testProfilesConfig), 0644))
+
+	cfg, err := Load(EnvVars{RegistryConfigurationPath: configPath})
+	r.NoError(err)
+	r.Len(cfg.Router.Nodes, 1)
+	r.False(cfg.CacheOnly)
+	r.Equal(1971, cfg.Vars.DiscoPort)
+
+	cfg, err = Load(EnvVars{RegistryConfigurationPath: configPath, Profile: "staging"})
+	r.NoError(err)
+	r.Len(cfg.Router.Nodes, 2)
+	r.Equal("http://staging-1:5001", cfg.Router.Nodes[0].URL)
+
+	cfg, err = Load(EnvVars{RegistryConfigurationPath: configPath, Profile: "prod"})
+	r.NoError(err)
+	r.Len(cfg.Router.Nodes, 1)
+	r.True(cfg.CacheOnly)
+	r.Equal(1980, cfg.Vars.DiscoPort)
+
+	cfg, err = Load(EnvVars{RegistryConfigurationPath: configPath, Profile: "prod", DiscoPort: 2000})
+	r.NoError(err)
+	r.Equal(2000, cfg.Vars.DiscoPort, "the environment should take precedence")
+
+	_, err = Load(EnvVars{RegistryConfigurationPath: configPath, Profile: "dev"})
+	r.Error(err)
+}
+
+func TestValidate_Profiles(t *testing.T) {
+	r := require.New(t)
+
+	configPath := path.Join(t.TempDir(), "config.yaml")
+	r.NoError(os.WriteFile(configPath, []byte(testProfilesConfig+`  dev:
+    storage:
+      ipfs:
+        cacheonyl: true
+`), 0644))
+
+	err := Validate(configPath, "")
+	r.Error(err)
+	validationErr, ok := err.(*ValidationError)
+	r.True(ok)
+	r.Equal([]string{"profiles.dev.storage.ipfs.cacheonyl: unknown key (line 28)"}, validationErr.Problems)
+}
@@ -0,0 +1,141 @@
+package config
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/signal"
+	"reflect"
+	"syscall"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// OnReload registers a handler which is called after the reloadable settings
+// are updated by Reload.
+func (cfg *Config) OnReload(handler func()) {
+	cfg.reloadMu.Lock()
+	defer cfg.reloadMu.Unlock()
+	cfg.reloadHandlers = append(cfg.reloadHandlers, handler)
+}
+
+// Reload reads the config file again and applies the reloadable settings: the router nodes,
+// the log level and format and the redirect URL. The rest of the changes take effect after a restart.
+// The reload is rejected if the nodes would route the content to other nodes or if the limits
+// change, since the limiters are created with the proxy.
+func (cfg *Config) Reload() error {
+	cfg.reloadMu.Lock()
+	defer cfg.reloadMu.Unlock()
+
+	distrConfig, settings, files, err := readConfigFile(cfg.Vars.RegistryConfigurationPath, cfg.Vars.Profile)
+	if err != nil {
+		return err
+	}
+	redirectTo, err := parseRedirect(settings.Storage.IPFS.Redirect)
+	if err != nil {
+		return err
+	}
+	if err := checkRouterNodes(cfg.Router.Nodes, settings.Storage.IPFS.Router.Nodes); err != nil {
+		return err
+	}
+	if !reflect.DeepEqual(cfg.Limits, settings.Disco.Limits) {
+		return fmt.Errorf("disco.limits cannot change on reload - restart to apply them")
+	}
+	if redirectTo != nil && cfg.CacheCompression.Enabled {
+		return fmt.Errorf("storage.ipfs.redirect cannot be used with storage.ipfs.cachecompression")
+	}
+
+	cfg.files = files
+	cfg.Router = settings.Storage.IPFS.Router
+	cfg.Router.StatCache.applyDefaults()
+	cfg.RedirectTo = redirectTo
+	if cfg.Distribution != nil {
+		cfg.Distribution.Log.Level = distrConfig.Log.Level
+	}
+	cfg.Log = settings.Disco.Log
+	cfg.ApplyLogging()
+
+	for _, handler := range cfg.reloadHandlers {
+		handler()
+	}
+	log.WithField("config", cfg.Vars.RegistryConfigurationPath).Info("reloaded configuration")
+	return nil
+}
+
+// checkRouterNodes checks that the reloaded nodes route the content like the current nodes. The
+// content is routed by the number of the nodes and their positions, so a node can only be
+// replaced with a new node at the same position.
+func checkRouterNodes(current, reloaded []*Node) error {
+	if len(reloaded) != len(current) {
+		return fmt.Errorf("the number of the router nodes cannot change from %d to %d on reload", len(current), len(reloaded))
+	}
+	positions := make(map[string]int)
+	for i, node := range current {
+		positions[node.URL] = i
+	}
+	for i, node := range reloaded {
+		if position, ok := positions[node.URL]; ok && position != i {
+			return fmt.Errorf("router node #%d cannot move to #%d on reload", position, i)
+		}
+	}
+	return nil
+}
+
+// Watch reloads the config when the process receives SIGHUP or when the config file
+// is modified. It blocks until the context is done.
+func (cfg *Config) Watch(ctx context.Context) {
+	sighup := make(chan os.Signal, 1)
+	signal.Notify(sighup, syscall.SIGHUP)
+	defer signal.Stop(sighup)
+
+	// only watch for signals if the file polling is disabled
+	var tick <-chan time.Time
+	if cfg.Vars.ConfigWatchInterval > 0 {
+		ticker := time.NewTicker(cfg.Vars.ConfigWatchInterval)
+		defer ticker.Stop()
+		tick = ticker.C
+	}
+
+	lastModTime := cfg.modTime()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+
+		case <-sighup:
+			log.Info("received SIGHUP - reloading configuration")
+
+		case <-tick:
+			modTime := cfg.modTime()
+			if !modTime.After(lastModTime) {
+				continue
+			}
+			lastModTime = modTime
+		}
+
+		if err := cfg.Reload(); err != nil {
+			log.WithError(err).Error("failed to reload configuration - keeping the previous one")
+		}
+	}
+}
+
+// modTime returns the latest modification time of the config files.
+func (cfg *Config) modTime() (modTime time.Time) {
+	cfg.reloadMu.Lock()
+	files := cfg.files
+	cfg.reloadMu.Unlock()
+	if len(files) == 0 {
+		files = []string{cfg.Vars.RegistryConfigurationPath}
+	}
+	for _, file := range files {
+		info, err := os.Stat(file)
+		if err != nil {
+			continue
+		}
+		if info.ModTime().After(modTime) {
+			modTime = info.ModTime()
+		}
+	}
+	return
+}
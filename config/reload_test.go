@@ -0,0 +1,113 @@
+package config
+
+import (
+	"os"
+	"path"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+const testConfig = `version: 0.1
+storage:
+  ipfs:
+    router:
+      nodes:
+        - url: http://localhost:5001
+`
+
+const testConfigReloaded = `version: 0.1
+log:
+  level: debug
+storage:
+  ipfs:
+    router:
+      nodes:
+        - url: http://localhost:5002
+    cache:
+      inmemory:
+    redirect: https://some.url
+`
+
+func TestReload(t *testing.T) {
+	r := require.New(t)
+
+	configPath := path.Join(t.TempDir(), "config.yaml")
+	r.NoError(os.WriteFile(configPath, []byte(testConfig), 0644))
+	cfg, err := Load(EnvVars{RegistryConfigurationPath: configPath})
+	r.NoError(err)
+	r.Len(cfg.Router.Nodes, 1)
+	r.Nil(cfg.RedirectTo)
+
+	var reloaded bool
+	cfg.OnReload(func() {
+		reloaded = true
+	})
+	r.NoError(os.WriteFile(configPath, []byte(testConfigReloaded), 0644))
+	r.NoError(cfg.Reload())
+	r.True(reloaded)
+	r.Len(cfg.Router.Nodes, 1)
+	r.Equal("http://localhost:5002", cfg.Router.Nodes[0].URL)
+	r.Equal("https://some.url", cfg.RedirectTo.String())
+}
+
+func TestReload_RouterNodes(t *testing.T) {
+	r := require.New(t)
+
+	configPath := path.Join(t.TempDir(), "config.yaml")
+	r.NoError(os.WriteFile(configPath, []byte(testConfig), 0644))
+	cfg, err := Load(EnvVars{RegistryConfigurationPath: configPath})
+	r.NoError(err)
+
+	// When a node is added
+	r.NoError(os.WriteFile(configPath, []byte(testConfig+"        - url: http://localhost:5002\n"), 0644))
+
+	// Then the reload should be rejected since the content would be routed to other nodes
+	r.Error(cfg.Reload())
+	r.Len(cfg.Router.Nodes, 1)
+
+	// And the nodes should not move
+	r.Error(checkRouterNodes(
+		[]*Node{{URL: "http://localhost:5001"}, {URL: "http://localhost:5002"}},
+		[]*Node{{URL: "http://localhost:5002"}, {URL: "http://localhost:5001"}},
+	))
+	r.NoError(checkRouterNodes(
+		[]*Node{{URL: "http://localhost:5001"}, {URL: "http://localhost:5002"}},
+		[]*Node{{URL: "http://localhost:5001"}, {URL: "http://localhost:5003"}},
+	))
+}
+
+func TestReload_Limits(t *testing.T) {
+	r := require.New(t)
+
+	configPath := path.Join(t.TempDir(), "config.yaml")
+	r.NoError(os.WriteFile(configPath, []byte(testConfig+"disco:\n  limits:\n    maxuploads: 20\n"), 0644))
+	cfg, err := Load(EnvVars{RegistryConfigurationPath: configPath})
+	r.NoError(err)
+
+	// When the limits are changed
+	r.NoError(os.WriteFile(configPath, []byte(testConfig+"disco:\n  limits:\n    maxuploads: 40\n"), 0644))
+
+	// Then the reload should be rejected since the limiters are not updated
+	r.Error(cfg.Reload())
+	r.Equal(20, cfg.Limits.MaxUploads)
+
+	// And the reload should be accepted when the limits are kept
+	r.NoError(os.WriteFile(configPath, []byte(testConfig+"disco:\n  log:\n    level: debug\n  limits:\n    maxuploads: 20\n"), 0644))
+	r.NoError(cfg.Reload())
+}
+
+func TestInit(t *testing.T) {
+	r := require.New(t)
+
+	configPath := path.Join(t.TempDir(), "config.yaml")
+	r.NoError(os.WriteFile(configPath, []byte(testConfig), 0644))
+	t.Setenv("REGISTRY_CONFIGURATION_PATH", configPath)
+	t.Setenv("DISCO_PORT", "1971")
+
+	cfg, err := Init()
+	r.NoError(err)
+	r.Equal(configPath, cfg.Vars.RegistryConfigurationPath)
+	r.Equal(1971, cfg.Vars.DiscoPort)
+	r.Len(cfg.Router.Nodes, 1)
+}
@@ -0,0 +1,77 @@
+package config
+
+import (
+	"fmt"
+	"regexp"
+)
+
+// Push rule actions.
+const (
+	PushActionAllow     = "allow"
+	PushActionDeny      = "deny"
+	PushActionImmutable = "immutable"
+)
+
+// PushRule allows or denies the pushes of the matching repositories and tags. The rules are
+// checked in order and the first matching rule applies. The pushes which match no rules are
+// allowed.
+type PushRule struct {
+	// Repository is the regular expression which the repository names are matched with.
+	// Empty matches all repositories.
+	Repository string `yaml:"repository"`
+	// Tag is the regular expression which the tags are matched with. Empty matches all tags.
+	Tag string `yaml:"tag"`
+	// Action is "allow", "deny" or "immutable". The immutable tags can be pushed only once.
+	Action string `yaml:"action"`
+	// Message is returned to the clients when the push is denied.
+	Message string `yaml:"message"`
+
+	repository *regexp.Regexp
+	tag        *regexp.Regexp
+}
+
+// Compile checks the action and compiles the patterns of the rule. The rules in the loaded
+// configs are already compiled.
+func (rule *PushRule) Compile() (err error) {
+	switch rule.Action {
+	case PushActionAllow, PushActionDeny, PushActionImmutable:
+	default:
+		return fmt.Errorf("expected '%s', '%s' or '%s' but found '%s'",
+			PushActionAllow, PushActionDeny, PushActionImmutable, rule.Action)
+	}
+	if rule.repository, err = compileRulePattern(rule.Repository); err != nil {
+		return fmt.Errorf("invalid repository pattern: %v", err)
+	}
+	if rule.tag, err = compileRulePattern(rule.Tag); err != nil {
+		return fmt.Errorf("invalid tag pattern: %v", err)
+	}
+	return nil
+}
+
+func compileRulePattern(pattern string) (*regexp.Regexp, error) {
+	if len(pattern) == 0 {
+		return nil, nil
+	}
+	return regexp.Compile(pattern)
+}
+
+// Matches tells if the rule applies to the repository and the tag.
+func (rule *PushRule) Matches(repoName, tag string) bool {
+	if rule.repository != nil && !rule.repository.MatchString(repoName) {
+		return false
+	}
+	if rule.tag != nil && !rule.tag.MatchString(tag) {
+		return false
+	}
+	return true
+}
+
+// MatchPushRule returns the first rule which applies to the repository and the tag, or nil.
+func MatchPushRule(rules []*PushRule, repoName, tag string) *PushRule {
+	for _, rule := range rules {
+		if rule.Matches(repoName, tag) {
+			return rule
+		}
+	}
+	return nil
+}
@@ -0,0 +1,58 @@
+package config
+
+import (
+	"os"
+	"path"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestLoad_PushRules(t *testing.T) {
+	r := require.New(t)
+
+	configPath := path.Join(t.TempDir(), "config.yaml")
+	r.NoError(os.WriteFile(configPath, []byte(`version: 0.1
+storage:
+  ipfs:
+    router:
+      nodes:
+        - url: http://localhost:5001
+disco:
+  pushrules:
+    - repository: ^reserved/
+      action: deny
+    - tag: ^v[0-9.]+$
+      action: immutable
+    - tag: ^latest$
+      action: allow
+    - action: deny
+      message: only latest and version tags can be pushed
+`), 0644))
+	cfg, err := Load(EnvVars{RegistryConfigurationPath: configPath})
+	r.NoError(err)
+
+	r.Equal(PushActionDeny, MatchPushRule(cfg.PushRules, "reserved/repo", "latest").Action)
+	r.Equal(PushActionImmutable, MatchPushRule(cfg.PushRules, "myrepo", "v1.2.3").Action)
+	r.Equal(PushActionAllow, MatchPushRule(cfg.PushRules, "myrepo", "latest").Action)
+	r.Equal("only latest and version tags can be pushed", MatchPushRule(cfg.PushRules, "myrepo", "dev").Message)
+	r.Nil(MatchPushRule(nil, "myrepo", "dev"))
+
+	r.NoError(os.WriteFile(configPath, []byte(`version: 0.1
+storage:
+  ipfs:
+    router:
+      nodes:
+        - url: http://localhost:5001
+disco:
+  pushrules:
+    - repository: "("
+      action: deny
+    - action: reject
+`), 0644))
+	err = Validate(configPath, "")
+	r.Error(err)
+	validationErr, ok := err.(*ValidationError)
+	r.True(ok)
+	r.Len(validationErr.Problems, 2)
+}
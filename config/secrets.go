@@ -0,0 +1,65 @@
+package config
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/forta-network/disco/secrets"
+)
+
+// DefaultSecretRefreshInterval is how long the secrets are cached before they are fetched again.
+const DefaultSecretRefreshInterval = time.Minute * 5
+
+// SecretsConfig configures the secret providers which the ${<provider>:<ref>} config
+// references are resolved with.
+type SecretsConfig struct {
+	Vault struct {
+		Address   string `yaml:"address"`
+		Token     string `yaml:"token"`
+		TokenFile string `yaml:"tokenfile"`
+		Namespace string `yaml:"namespace"`
+	} `yaml:"vault"`
+	AWSSecretsManager struct {
+		Region   string `yaml:"region"`
+		Endpoint string `yaml:"endpoint"`
+	} `yaml:"awssecretsmanager"`
+	RefreshInterval time.Duration `yaml:"refreshinterval"`
+}
+
+// configureSecrets registers the secret providers which are configured.
+func configureSecrets(cfg *SecretsConfig) error {
+	refreshInterval := cfg.RefreshInterval
+	if refreshInterval == 0 {
+		refreshInterval = DefaultSecretRefreshInterval
+	}
+
+	if len(cfg.Vault.Address) > 0 {
+		token := cfg.Vault.Token
+		if len(cfg.Vault.TokenFile) > 0 {
+			b, err := os.ReadFile(cfg.Vault.TokenFile)
+			if err != nil {
+				return fmt.Errorf("failed to read vault token file: %v", err)
+			}
+			token = strings.TrimSpace(string(b))
+		}
+		if len(token) == 0 {
+			token = os.Getenv("VAULT_TOKEN")
+		}
+		secrets.Register(secrets.VaultProviderName,
+			secrets.NewVaultProvider(cfg.Vault.Address, token, cfg.Vault.Namespace), refreshInterval)
+	}
+
+	if len(cfg.AWSSecretsManager.Region) > 0 {
+		ctx, cancel := context.WithTimeout(context.Background(), secretTimeout)
+		defer cancel()
+		provider, err := secrets.NewAWSSecretsManagerProvider(ctx, cfg.AWSSecretsManager.Region, cfg.AWSSecretsManager.Endpoint)
+		if err != nil {
+			return fmt.Errorf("failed to initialize aws secrets manager provider: %v", err)
+		}
+		secrets.Register(secrets.AWSSecretsManagerProviderName, provider, refreshInterval)
+	}
+	return nil
+}
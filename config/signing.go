@@ -0,0 +1,72 @@
+package config
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/pem"
+	"fmt"
+	"os"
+)
+
+// PushSigningConfig contains the public keys which the pushes should be signed with.
+type PushSigningConfig struct {
+	// PublicKeys are the paths of the PEM-encoded Ed25519, ECDSA or RSA public keys.
+	PublicKeys []string `yaml:"publickeys"`
+}
+
+// LoadPublicKeys reads the public keys from the files.
+func (signingCfg *PushSigningConfig) LoadPublicKeys() ([]crypto.PublicKey, error) {
+	return loadPublicKeys(signingCfg.PublicKeys)
+}
+
+// PullSigningConfig contains the trust root which the manifests of the CID repositories should be
+// signed with before they are served.
+type PullSigningConfig struct {
+	// PublicKeys are the paths of the PEM-encoded Ed25519, ECDSA or RSA public keys.
+	PublicKeys []string `yaml:"publickeys"`
+	// CosignRepository is the repository which the cosign signatures are pushed to, e.g. by
+	// setting COSIGN_REPOSITORY, since the CID repositories cannot be pushed to.
+	CosignRepository string `yaml:"cosignrepository"`
+}
+
+// LoadPublicKeys reads the public keys from the files.
+func (signingCfg *PullSigningConfig) LoadPublicKeys() ([]crypto.PublicKey, error) {
+	return loadPublicKeys(signingCfg.PublicKeys)
+}
+
+func loadPublicKeys(paths []string) ([]crypto.PublicKey, error) {
+	var keys []crypto.PublicKey
+	for _, path := range paths {
+		b, err := os.ReadFile(path)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read the public key file: %v", err)
+		}
+		rest := b
+		var found bool
+		for {
+			var block *pem.Block
+			block, rest = pem.Decode(rest)
+			if block == nil {
+				break
+			}
+			key, err := x509.ParsePKIXPublicKey(block.Bytes)
+			if err != nil {
+				return nil, fmt.Errorf("failed to parse the public key in %s: %v", path, err)
+			}
+			switch key.(type) {
+			case ed25519.PublicKey, *ecdsa.PublicKey, *rsa.PublicKey:
+			default:
+				return nil, fmt.Errorf("unsupported public key type %T in %s", key, path)
+			}
+			keys = append(keys, key)
+			found = true
+		}
+		if !found {
+			return nil, fmt.Errorf("no public keys found in %s", path)
+		}
+	}
+	return keys, nil
+}
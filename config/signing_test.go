@@ -0,0 +1,43 @@
+package config
+
+import (
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"encoding/pem"
+	"os"
+	"path"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestPushSigningConfig_LoadPublicKeys(t *testing.T) {
+	r := require.New(t)
+	dir := t.TempDir()
+
+	edPub, _, err := ed25519.GenerateKey(rand.Reader)
+	r.NoError(err)
+	ecPriv, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	r.NoError(err)
+	var keysPEM []byte
+	for _, key := range []interface{}{edPub, &ecPriv.PublicKey} {
+		b, err := x509.MarshalPKIXPublicKey(key)
+		r.NoError(err)
+		keysPEM = append(keysPEM, pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: b})...)
+	}
+	keysPath := path.Join(dir, "keys.pem")
+	r.NoError(os.WriteFile(keysPath, keysPEM, 0644))
+
+	keys, err := (&PushSigningConfig{PublicKeys: []string{keysPath}}).LoadPublicKeys()
+	r.NoError(err)
+	r.Len(keys, 2)
+	r.Equal(edPub, keys[0])
+
+	emptyPath := path.Join(dir, "empty.pem")
+	r.NoError(os.WriteFile(emptyPath, []byte("no keys"), 0644))
+	_, err = (&PushSigningConfig{PublicKeys: []string{emptyPath}}).LoadPublicKeys()
+	r.Error(err)
+}
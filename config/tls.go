@@ -0,0 +1,152 @@
+package config
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+)
+
+// TLSConfig contains the TLS settings which are shared by the proxy listener and
+// the HTTP clients of the registry, the IPFS nodes and the R2 cache.
+type TLSConfig struct {
+	// Certificate and Key enable TLS on the proxy listener.
+	Certificate string `yaml:"certificate"`
+	Key         string `yaml:"key"`
+	// ClientCAs makes the proxy listener require the client certificates signed by these CAs.
+	ClientCAs []string `yaml:"clientcas"`
+	// CAs are trusted by the clients in addition to the system CAs.
+	CAs []string `yaml:"cas"`
+	// ClientCertificate and ClientKey are presented by the clients.
+	ClientCertificate string `yaml:"clientcertificate"`
+	ClientKey         string `yaml:"clientkey"`
+	// MinVersion is the minimum TLS version: 1.0, 1.1, 1.2 (default) or 1.3.
+	MinVersion string `yaml:"minversion"`
+}
+
+var tlsVersions = map[string]uint16{
+	"1.0": tls.VersionTLS10,
+	"1.1": tls.VersionTLS11,
+	"1.2": tls.VersionTLS12,
+	"1.3": tls.VersionTLS13,
+}
+
+func (tlsCfg *TLSConfig) minVersion() (uint16, error) {
+	if len(tlsCfg.MinVersion) == 0 {
+		return tls.VersionTLS12, nil
+	}
+	version, ok := tlsVersions[tlsCfg.MinVersion]
+	if !ok {
+		return 0, fmt.Errorf("unknown tls version '%s'", tlsCfg.MinVersion)
+	}
+	return version, nil
+}
+
+// ServerConfig returns the TLS config for the proxy listener or nil if no certificate is configured.
+func (tlsCfg *TLSConfig) ServerConfig() (*tls.Config, error) {
+	if len(tlsCfg.Certificate) == 0 && len(tlsCfg.Key) == 0 {
+		if len(tlsCfg.ClientCAs) > 0 {
+			return nil, errors.New("clientcas requires a certificate and a key")
+		}
+		return nil, nil
+	}
+	minVersion, err := tlsCfg.minVersion()
+	if err != nil {
+		return nil, err
+	}
+	cert, err := tls.LoadX509KeyPair(tlsCfg.Certificate, tlsCfg.Key)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load the certificate: %v", err)
+	}
+	serverCfg := &tls.Config{
+		Certificates: []tls.Certificate{cert},
+		MinVersion:   minVersion,
+	}
+	if len(tlsCfg.ClientCAs) > 0 {
+		pool, err := loadCertPool(x509.NewCertPool(), tlsCfg.ClientCAs)
+		if err != nil {
+			return nil, err
+		}
+		serverCfg.ClientCAs = pool
+		serverCfg.ClientAuth = tls.RequireAndVerifyClientCert
+	}
+	return serverCfg, nil
+}
+
+// ClientConfig returns the TLS config for the HTTP clients or nil if the defaults should be used.
+func (tlsCfg *TLSConfig) ClientConfig() (*tls.Config, error) {
+	if len(tlsCfg.CAs) == 0 && len(tlsCfg.ClientCertificate) == 0 && len(tlsCfg.ClientKey) == 0 && len(tlsCfg.MinVersion) == 0 {
+		return nil, nil
+	}
+	minVersion, err := tlsCfg.minVersion()
+	if err != nil {
+		return nil, err
+	}
+	clientCfg := &tls.Config{MinVersion: minVersion}
+	if len(tlsCfg.CAs) > 0 {
+		pool, err := x509.SystemCertPool()
+		if err != nil {
+			pool = x509.NewCertPool()
+		}
+		clientCfg.RootCAs, err = loadCertPool(pool, tlsCfg.CAs)
+		if err != nil {
+			return nil, err
+		}
+	}
+	if len(tlsCfg.ClientCertificate) > 0 || len(tlsCfg.ClientKey) > 0 {
+		cert, err := tls.LoadX509KeyPair(tlsCfg.ClientCertificate, tlsCfg.ClientKey)
+		if err != nil {
+			return nil, fmt.Errorf("failed to load the client certificate: %v", err)
+		}
+		clientCfg.Certificates = []tls.Certificate{cert}
+	}
+	return clientCfg, nil
+}
+
+func loadCertPool(pool *x509.CertPool, paths []string) (*x509.CertPool, error) {
+	for _, path := range paths {
+		b, err := os.ReadFile(path)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read the ca file: %v", err)
+		}
+		if !pool.AppendCertsFromPEM(b) {
+			return nil, fmt.Errorf("no certificates found in %s", path)
+		}
+	}
+	return pool, nil
+}
+
+// HTTPTransport returns a transport which uses the client TLS config.
+func (cfg *Config) HTTPTransport() http.RoundTripper {
+	if cfg.ClientTLS == nil {
+		return http.DefaultTransport
+	}
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.TLSClientConfig = cfg.ClientTLS.Clone()
+	return transport
+}
+
+// ProxyTransport returns the transport of the reverse proxy to the registry, which uses the
+// client TLS config and the proxy settings.
+func (cfg *Config) ProxyTransport() http.RoundTripper {
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	if cfg.ClientTLS != nil {
+		transport.TLSClientConfig = cfg.ClientTLS.Clone()
+	}
+	transport.MaxIdleConns = cfg.Proxy.MaxIdleConns
+	transport.MaxIdleConnsPerHost = cfg.Proxy.MaxIdleConnsPerHost
+	transport.ResponseHeaderTimeout = cfg.Proxy.ResponseHeaderTimeout
+	// the responses are not read from the registry further than the buffer of the copy
+	transport.ReadBufferSize = cfg.Proxy.BufferSize
+	return transport
+}
+
+// HTTPClient returns an HTTP client which uses the client TLS config.
+func (cfg *Config) HTTPClient() *http.Client {
+	if cfg.ClientTLS == nil {
+		return http.DefaultClient
+	}
+	return &http.Client{Transport: cfg.HTTPTransport()}
+}
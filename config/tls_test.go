@@ -0,0 +1,118 @@
+package config
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/big"
+	"net/http"
+	"os"
+	"path"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func writeTestCert(t *testing.T, dir, name string) (certPath, keyPath string) {
+	r := require.New(t)
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	r.NoError(err)
+	template := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: name},
+		NotBefore:             time.Now(),
+		NotAfter:              time.Now().Add(time.Hour),
+		IsCA:                  true,
+		KeyUsage:              x509.KeyUsageDigitalSignature | x509.KeyUsageCertSign,
+		BasicConstraintsValid: true,
+	}
+	certDER, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	r.NoError(err)
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	r.NoError(err)
+
+	certPath = path.Join(dir, name+".crt")
+	keyPath = path.Join(dir, name+".key")
+	r.NoError(os.WriteFile(certPath, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: certDER}), 0644))
+	r.NoError(os.WriteFile(keyPath, pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER}), 0600))
+	return
+}
+
+func TestTLSConfig(t *testing.T) {
+	r := require.New(t)
+
+	dir := t.TempDir()
+	certPath, keyPath := writeTestCert(t, dir, "server")
+	caPath, _ := writeTestCert(t, dir, "ca")
+
+	tlsCfg := &TLSConfig{}
+	serverCfg, err := tlsCfg.ServerConfig()
+	r.NoError(err)
+	r.Nil(serverCfg)
+	clientCfg, err := tlsCfg.ClientConfig()
+	r.NoError(err)
+	r.Nil(clientCfg)
+
+	tlsCfg = &TLSConfig{
+		Certificate:       certPath,
+		Key:               keyPath,
+		ClientCAs:         []string{caPath},
+		CAs:               []string{caPath},
+		ClientCertificate: certPath,
+		ClientKey:         keyPath,
+		MinVersion:        "1.3",
+	}
+	serverCfg, err = tlsCfg.ServerConfig()
+	r.NoError(err)
+	r.Len(serverCfg.Certificates, 1)
+	r.Equal(tls.RequireAndVerifyClientCert, serverCfg.ClientAuth)
+	r.Equal(uint16(tls.VersionTLS13), serverCfg.MinVersion)
+
+	clientCfg, err = tlsCfg.ClientConfig()
+	r.NoError(err)
+	r.NotNil(clientCfg.RootCAs)
+	r.Len(clientCfg.Certificates, 1)
+	r.Equal(uint16(tls.VersionTLS13), clientCfg.MinVersion)
+
+	_, err = (&TLSConfig{MinVersion: "2.0"}).ClientConfig()
+	r.Error(err)
+	_, err = (&TLSConfig{CAs: []string{keyPath}}).ClientConfig()
+	r.Error(err)
+	_, err = (&TLSConfig{ClientCAs: []string{caPath}}).ServerConfig()
+	r.Error(err)
+}
+
+func TestConfig_HTTPClient(t *testing.T) {
+	r := require.New(t)
+
+	cfg := &Config{}
+	r.Equal(http.DefaultClient, cfg.HTTPClient())
+
+	cfg.ClientTLS = &tls.Config{MinVersion: tls.VersionTLS13}
+	transport, ok := cfg.HTTPClient().Transport.(*http.Transport)
+	r.True(ok)
+	r.Equal(uint16(tls.VersionTLS13), transport.TLSClientConfig.MinVersion)
+}
+
+func TestConfig_ProxyTransport(t *testing.T) {
+	r := require.New(t)
+
+	cfg := &Config{ClientTLS: &tls.Config{MinVersion: tls.VersionTLS13}}
+	cfg.Proxy.ResponseHeaderTimeout = time.Minute
+	cfg.Proxy.applyDefaults()
+	transport, ok := cfg.ProxyTransport().(*http.Transport)
+	r.True(ok)
+	r.NotSame(http.DefaultTransport, transport)
+	r.Equal(uint16(tls.VersionTLS13), transport.TLSClientConfig.MinVersion)
+	r.Equal(DefaultProxyMaxIdleConns, transport.MaxIdleConns)
+	r.Equal(DefaultProxyMaxIdleConnsPerHost, transport.MaxIdleConnsPerHost)
+	r.Equal(time.Minute, transport.ResponseHeaderTimeout)
+	r.Equal(DefaultProxyFlushInterval, cfg.Proxy.FlushInterval)
+	r.Equal(DefaultProxyBufferSize, transport.ReadBufferSize)
+}
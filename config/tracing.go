@@ -0,0 +1,50 @@
+package config
+
+import (
+	"github.com/forta-network/disco/utils"
+)
+
+// Default tracing settings.
+const (
+	DefaultTracingServiceName = "disco"
+	DefaultTracingSampleRatio = 1.0
+)
+
+// TracingConfig contains the settings of the export of the traces to an OpenTelemetry collector.
+type TracingConfig struct {
+	Enabled bool `yaml:"enabled"`
+	// Endpoint is the OTLP/HTTP traces endpoint of the collector, e.g.
+	// http://localhost:4318/v1/traces.
+	Endpoint string `yaml:"endpoint"`
+	// Headers are sent with the exports, e.g. to authenticate with the collector.
+	Headers     map[string]string `yaml:"headers"`
+	ServiceName string            `yaml:"servicename"`
+	// SampleRatio is the ratio (0-1) of the traces which are started by Disco and exported. The
+	// traces which are continued from the requests are exported if the callers sampled them.
+	SampleRatio *float64 `yaml:"sampleratio"`
+}
+
+func (tracingCfg *TracingConfig) applyDefaults() {
+	if len(tracingCfg.ServiceName) == 0 {
+		tracingCfg.ServiceName = DefaultTracingServiceName
+	}
+	if tracingCfg.SampleRatio == nil {
+		ratio := DefaultTracingSampleRatio
+		tracingCfg.SampleRatio = &ratio
+	}
+}
+
+// Tracer creates the tracer from the config, or returns nil if the tracing is disabled. The
+// exports are not traced.
+func (cfg *Config) Tracer() (*utils.Tracer, error) {
+	if !cfg.Tracing.Enabled {
+		return nil, nil
+	}
+	return utils.NewTracer(utils.TracerOptions{
+		Endpoint:    cfg.Tracing.Endpoint,
+		Headers:     cfg.Tracing.Headers,
+		ServiceName: cfg.Tracing.ServiceName,
+		SampleRatio: *cfg.Tracing.SampleRatio,
+		TLSConfig:   cfg.ClientTLS,
+	})
+}
@@ -0,0 +1,596 @@
+package config
+
+import (
+	"fmt"
+	"net/url"
+	"reflect"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/distribution/distribution/v3/configuration"
+	"github.com/forta-network/disco/utils"
+	log "github.com/sirupsen/logrus"
+	"gopkg.in/yaml.v3"
+)
+
+// tagPattern matches the valid tags as in the distribution spec.
+var tagPattern = regexp.MustCompile(`^[a-zA-Z0-9_][a-zA-Z0-9._-]{0,127}$`)
+
+// ethAddressPattern matches the hex-encoded contract addresses.
+var ethAddressPattern = regexp.MustCompile(`^0x[0-9a-fA-F]{40}$`)
+
+// ValidationError contains all of the problems found in a config file.
+type ValidationError struct {
+	Problems []string
+}
+
+// Error implements the error interface.
+func (e *ValidationError) Error() string {
+	// the parse errors of the URLs have the passwords in them
+	return utils.Redact(fmt.Sprintf("invalid configuration:\n  - %s", strings.Join(e.Problems, "\n  - ")))
+}
+
+// Validate reads the config file and checks it for unknown keys and inconsistent settings
+// by using given profile. The keys are checked in all of the profiles.
+func Validate(configPath, profile string) error {
+	_, _, _, err := readConfigFile(configPath, profile)
+	return err
+}
+
+// validate checks the raw config for unknown keys and the parsed config for inconsistent settings.
+func validate(b []byte, distrConfig *configuration.Configuration, settings *discoSettings) error {
+	var problems []string
+
+	var root yaml.Node
+	if err := yaml.Unmarshal(b, &root); err != nil {
+		return err
+	}
+	if len(root.Content) > 0 {
+		problems = append(problems, checkTopLevelKeys(root.Content[0], "")...)
+	}
+	problems = append(problems, checkSettings(distrConfig, settings)...)
+
+	if len(problems) > 0 {
+		return &ValidationError{Problems: problems}
+	}
+	return nil
+}
+
+// checkTopLevelKeys checks the keys of the config, or a profile in the config if the prefix is not empty.
+func checkTopLevelKeys(node *yaml.Node, prefix string) (problems []string) {
+	if node.Kind != yaml.MappingNode {
+		if len(prefix) > 0 {
+			return []string{fmt.Sprintf("%s: should be a YAML mapping (line %d)", strings.TrimSuffix(prefix, "."), node.Line)}
+		}
+		return []string{"the config should be a YAML mapping"}
+	}
+	distrKeys := yamlFields(reflect.TypeOf(configuration.Configuration{}))
+	discoKeys := yamlFields(reflect.TypeOf(discoSettings{}))
+	for i := 0; i+1 < len(node.Content); i += 2 {
+		key, value := node.Content[i], node.Content[i+1]
+		switch {
+		case key.Value == "disco":
+			problems = append(problems, checkKeys(value, discoKeys["disco"], prefix+"disco")...)
+
+		case key.Value == "storage":
+			ipfsNode := mappingValue(value, "ipfs")
+			if ipfsNode != nil {
+				ipfsType := yamlFields(discoKeys["storage"])["ipfs"]
+				problems = append(problems, checkKeys(ipfsNode, ipfsType, prefix+"storage.ipfs")...)
+			}
+
+		case key.Value == profilesKey && len(prefix) == 0:
+			if value.Kind != yaml.MappingNode {
+				problems = append(problems, fmt.Sprintf("%s: should be a mapping of profile names to configs (line %d)", profilesKey, value.Line))
+				continue
+			}
+			for j := 0; j+1 < len(value.Content); j += 2 {
+				profilePrefix := fmt.Sprintf("%s.%s.", profilesKey, value.Content[j].Value)
+				problems = append(problems, checkTopLevelKeys(value.Content[j+1], profilePrefix)...)
+			}
+
+		default:
+			if _, ok := distrKeys[key.Value]; !ok {
+				problems = append(problems, fmt.Sprintf("%s%s: unknown key (line %d)", prefix, key.Value, key.Line))
+			}
+		}
+	}
+	return
+}
+
+// checkKeys recursively checks the keys of the node against the YAML fields of given type.
+func checkKeys(node *yaml.Node, t reflect.Type, keyPath string) (problems []string) {
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	switch {
+	case t.Kind() == reflect.Struct && node.Kind == yaml.MappingNode:
+		fields := yamlFields(t)
+		for i := 0; i+1 < len(node.Content); i += 2 {
+			key, value := node.Content[i], node.Content[i+1]
+			fieldKeyPath := keyPath + "." + key.Value
+			fieldType, ok := fields[key.Value]
+			if !ok {
+				problems = append(problems, fmt.Sprintf("%s: unknown key (line %d)", fieldKeyPath, key.Line))
+				continue
+			}
+			problems = append(problems, checkKeys(value, fieldType, fieldKeyPath)...)
+		}
+
+	case t.Kind() == reflect.Slice && node.Kind == yaml.SequenceNode:
+		for i, item := range node.Content {
+			problems = append(problems, checkKeys(item, t.Elem(), fmt.Sprintf("%s[%d]", keyPath, i))...)
+		}
+	}
+	return
+}
+
+// yamlFields returns the YAML keys of a struct type and the types of the fields.
+func yamlFields(t reflect.Type) map[string]reflect.Type {
+	fields := make(map[string]reflect.Type)
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		if len(field.PkgPath) > 0 {
+			continue // unexported
+		}
+		name := strings.Split(field.Tag.Get("yaml"), ",")[0]
+		if name == "-" {
+			continue
+		}
+		if len(name) == 0 {
+			name = strings.ToLower(field.Name)
+		}
+		fields[name] = field.Type
+	}
+	return fields
+}
+
+func mappingValue(node *yaml.Node, key string) *yaml.Node {
+	if node.Kind != yaml.MappingNode {
+		return nil
+	}
+	for i := 0; i+1 < len(node.Content); i += 2 {
+		if node.Content[i].Value == key {
+			return node.Content[i+1]
+		}
+	}
+	return nil
+}
+
+func checkSettings(distrConfig *configuration.Configuration, settings *discoSettings) (problems []string) {
+	ipfsSettings := settings.Storage.IPFS
+
+	if storageType := distrConfig.Storage.Type(); storageType != "ipfs" {
+		problems = append(problems, fmt.Sprintf("storage: expected the ipfs storage driver but found '%s'", storageType))
+	}
+
+	if len(ipfsSettings.Router.Nodes) == 0 && !ipfsSettings.CacheOnly {
+		problems = append(problems, "storage.ipfs.router.nodes: at least one IPFS node is required unless storage.ipfs.cacheonly is enabled")
+	}
+	for i, node := range ipfsSettings.Router.Nodes {
+		if err := checkURL(node.URL); err != nil {
+			problems = append(problems, fmt.Sprintf("storage.ipfs.router.nodes[%d].url: %v", i, err))
+		}
+	}
+	switch ipfsSettings.Router.Placement {
+	case "", PlacementHash, PlacementUsage:
+	default:
+		problems = append(problems, fmt.Sprintf("storage.ipfs.router.placement: expected '%s' or '%s' but found '%s'",
+			PlacementHash, PlacementUsage, ipfsSettings.Router.Placement))
+	}
+	if ipfsSettings.Router.MaxUsage < 0 || ipfsSettings.Router.MaxUsage > 1 {
+		problems = append(problems, "storage.ipfs.router.maxusage: should be a ratio between 0 and 1")
+	}
+	if ipfsSettings.Router.StatCache.TTL < 0 {
+		problems = append(problems, "storage.ipfs.router.statcache.ttl: should be a positive duration")
+	}
+	if ipfsSettings.Router.StatCache.Size < 0 {
+		problems = append(problems, "storage.ipfs.router.statcache.size: should be a positive number")
+	}
+	if ipfsSettings.WriteChunkSize < 0 {
+		problems = append(problems, "storage.ipfs.writechunksize: should not be negative")
+	}
+
+	switch len(ipfsSettings.Cache) {
+	case 0:
+		if ipfsSettings.CacheOnly {
+			problems = append(problems, "storage.ipfs.cacheonly: requires a cache driver in storage.ipfs.cache")
+		}
+		if len(ipfsSettings.Redirect) > 0 {
+			problems = append(problems, "storage.ipfs.redirect: requires a cache driver in storage.ipfs.cache")
+		}
+	case 1:
+		if _, ok := ipfsSettings.Cache["ipfs"]; ok {
+			problems = append(problems, "storage.ipfs.cache: the cache driver cannot be ipfs")
+		}
+	default:
+		problems = append(problems, "storage.ipfs.cache: only one cache driver can be specified")
+	}
+	if len(ipfsSettings.Redirect) > 0 {
+		if err := checkURL(ipfsSettings.Redirect); err != nil {
+			problems = append(problems, fmt.Sprintf("storage.ipfs.redirect: %v", err))
+		}
+	}
+	if compressionCfg := ipfsSettings.CacheCompression; compressionCfg.Enabled {
+		if len(ipfsSettings.Cache) == 0 {
+			problems = append(problems, "storage.ipfs.cachecompression: requires a cache driver in storage.ipfs.cache")
+		}
+		// the redirected clients would download the compressed blobs
+		if len(ipfsSettings.Redirect) > 0 {
+			problems = append(problems, "storage.ipfs.cachecompression: cannot be used with storage.ipfs.redirect")
+		}
+		switch compressionCfg.Level {
+		case "", CompressionFastest, CompressionDefault, CompressionBetter, CompressionBest:
+		default:
+			problems = append(problems, fmt.Sprintf("storage.ipfs.cachecompression.level: expected '%s', '%s', '%s' or '%s' but found '%s'",
+				CompressionFastest, CompressionDefault, CompressionBetter, CompressionBest, compressionCfg.Level))
+		}
+	}
+
+	if settings.Disco.PruneUploads.Interval < 0 {
+		problems = append(problems, "disco.pruneuploads.interval: should be a positive duration")
+	}
+	if settings.Disco.PruneUploads.Age < 0 {
+		problems = append(problems, "disco.pruneuploads.age: should be a positive duration")
+	}
+
+	if settings.Disco.DigestRetention.Interval < 0 {
+		problems = append(problems, "disco.digestretention.interval: should be a positive duration")
+	}
+	if settings.Disco.DigestRetention.Age < 0 {
+		problems = append(problems, "disco.digestretention.age: should be a positive duration")
+	}
+	if settings.Disco.DigestRetention.Enabled && len(settings.Disco.PullIndex) == 0 && len(settings.Disco.SharedState.Provider) == 0 {
+		problems = append(problems, "disco.digestretention: requires disco.pullindex or disco.sharedstate")
+	}
+	if settings.Disco.APIKeys.Enabled && len(settings.Disco.APIKeys.File) == 0 && len(settings.Disco.SharedState.Provider) == 0 {
+		problems = append(problems, "disco.apikeys: requires disco.apikeys.file or disco.sharedstate")
+	}
+	if settings.Disco.Snapshots.Interval < 0 {
+		problems = append(problems, "disco.snapshots.interval: should be a positive duration")
+	}
+	if settings.Disco.Snapshots.Retention < 0 {
+		problems = append(problems, "disco.snapshots.retention: should be a positive duration")
+	}
+	if settings.Disco.Snapshots.Enabled && (len(ipfsSettings.Cache) == 0 || ipfsSettings.CacheOnly) {
+		problems = append(problems, "disco.snapshots: requires the ipfs nodes and a cache driver in storage.ipfs.cache")
+	}
+	if settings.Disco.PublishRoot.Interval < 0 {
+		problems = append(problems, "disco.publishroot.interval: should be a positive duration")
+	}
+	if webhook := settings.Disco.PublishRoot.Webhook; len(webhook) > 0 {
+		if err := checkURL(webhook); err != nil {
+			problems = append(problems, fmt.Sprintf("disco.publishroot.webhook: %v", err))
+		}
+	}
+	if settings.Disco.PublishRoot.Enabled && ipfsSettings.CacheOnly {
+		problems = append(problems, "disco.publishroot: requires the ipfs nodes")
+	}
+	if settings.Disco.DigestPushes.Delay < 0 {
+		problems = append(problems, "disco.digestpushes.delay: should be a positive duration")
+	}
+	if settings.Disco.StreamBlobs && !settings.Disco.LazyClone {
+		problems = append(problems, "disco.streamblobs: requires disco.lazyclone")
+	}
+	problems = append(problems, checkWarmup(&settings.Disco.Warmup, ipfsSettings.CacheOnly)...)
+	problems = append(problems, checkUsageAlerts(&settings.Disco.UsageAlerts)...)
+	if endpoint := settings.Disco.PullPolicy.Endpoint; len(endpoint) > 0 {
+		if err := checkURL(endpoint); err != nil {
+			problems = append(problems, fmt.Sprintf("disco.pullpolicy.endpoint: %v", err))
+		}
+	}
+	if settings.Disco.PullPolicy.Timeout < 0 {
+		problems = append(problems, "disco.pullpolicy.timeout: should be a positive duration")
+	}
+	problems = append(problems, checkNames(&settings.Disco.Names, ipfsSettings.CacheOnly)...)
+	if len(settings.Disco.PullSigning.CosignRepository) > 0 && len(settings.Disco.PullSigning.PublicKeys) == 0 {
+		problems = append(problems, "disco.pullsigning.cosignrepository: needs the public keys to verify the signatures")
+	}
+	if endpoint := settings.Disco.Scanner.Endpoint; len(endpoint) > 0 {
+		if err := checkURL(endpoint); err != nil {
+			problems = append(problems, fmt.Sprintf("disco.scanner.endpoint: %v", err))
+		}
+	}
+	if admin := settings.Disco.Admin; len(admin.Addr) > 0 && len(admin.Token) == 0 && len(admin.HMACSecret) == 0 && !settings.Disco.APIKeys.Enabled {
+		problems = append(problems, "disco.admin.addr: needs the token, the HMAC secret or the API keys")
+	}
+	if settings.Disco.Admin.HMACMaxSkew < 0 {
+		problems = append(problems, "disco.admin.hmacmaxskew: should be a positive duration")
+	}
+	if secret := settings.Disco.Admin.HMACSecret; len(secret) > 0 && len(secret) < 32 {
+		problems = append(problems, "disco.admin.hmacsecret: should be at least 32 characters")
+	}
+	if settings.Disco.Scanner.Timeout < 0 {
+		problems = append(problems, "disco.scanner.timeout: should be a positive duration")
+	}
+	problems = append(problems, checkUsageStats(&settings.Disco.UsageStats, len(ipfsSettings.Cache) > 0 || ipfsSettings.CacheOnly)...)
+	problems = append(problems, checkBandwidth(&settings.Disco.Bandwidth)...)
+	if tag := settings.Disco.CanonicalTag; len(tag) > 0 && !tagPattern.MatchString(tag) {
+		problems = append(problems, fmt.Sprintf("disco.canonicaltag: '%s' is not a valid tag", tag))
+	}
+	if settings.Disco.CloneCache.TTL < 0 {
+		problems = append(problems, "disco.clonecache.ttl: should be a positive duration")
+	}
+
+	timeouts := settings.Disco.Timeouts
+	for _, timeout := range []struct {
+		name  string
+		value *time.Duration
+	}{
+		{"read", timeouts.Read},
+		{"write", timeouts.Write},
+		{"idle", timeouts.Idle},
+		{"operation", timeouts.Operation},
+		{"replication", timeouts.Replication},
+	} {
+		if timeout.value != nil && *timeout.value < 0 {
+			problems = append(problems, fmt.Sprintf("disco.timeouts.%s: should be a positive duration or zero", timeout.name))
+		}
+	}
+
+	proxySettings := settings.Disco.Proxy
+	if proxySettings.MaxIdleConns < 0 {
+		problems = append(problems, "disco.proxy.maxidleconns: should be a positive number")
+	}
+	if proxySettings.MaxIdleConnsPerHost < 0 {
+		problems = append(problems, "disco.proxy.maxidleconnsperhost: should be a positive number")
+	}
+	if proxySettings.ResponseHeaderTimeout < 0 {
+		problems = append(problems, "disco.proxy.responseheadertimeout: should be a positive duration")
+	}
+
+	lockSettings := settings.Disco.Lock
+	switch lockSettings.Provider {
+	case "":
+	case ProviderRedis:
+		if len(lockSettings.Addr) == 0 {
+			problems = append(problems, "disco.lock.addr: is required for the redis lock provider")
+		}
+	default:
+		problems = append(problems, fmt.Sprintf("disco.lock.provider: expected '%s' but found '%s'", ProviderRedis, lockSettings.Provider))
+	}
+	if lockSettings.TTL < 0 {
+		problems = append(problems, "disco.lock.ttl: should be a positive duration")
+	}
+	if lockSettings.DB < 0 {
+		problems = append(problems, "disco.lock.db: should be a positive number")
+	}
+
+	stateSettings := settings.Disco.SharedState
+	switch stateSettings.Provider {
+	case "":
+	case ProviderRedis:
+		if len(stateSettings.Addr) == 0 {
+			problems = append(problems, "disco.sharedstate.addr: is required for the redis provider")
+		}
+	default:
+		problems = append(problems, fmt.Sprintf("disco.sharedstate.provider: expected '%s' but found '%s'", ProviderRedis, stateSettings.Provider))
+	}
+	if stateSettings.DB < 0 {
+		problems = append(problems, "disco.sharedstate.db: should be a positive number")
+	}
+	if settings.Disco.MetadataCache.TTL < 0 {
+		problems = append(problems, "disco.metadatacache.ttl: should be a positive duration")
+	}
+	if settings.Disco.MetadataCache.Enabled && stateSettings.Provider != ProviderRedis {
+		problems = append(problems, "disco.metadatacache: requires disco.sharedstate with the redis provider")
+	}
+
+	if settings.Disco.Proxy.BufferSize < 0 {
+		problems = append(problems, "disco.proxy.buffersize: should be a positive number")
+	}
+
+	if settings.Disco.Limits.MaxInflightBytes < 0 {
+		problems = append(problems, "disco.limits.maxinflightbytes: should be a positive number")
+	}
+	if settings.Disco.Limits.MaxUploads < 0 {
+		problems = append(problems, "disco.limits.maxuploads: should be a positive number")
+	}
+	if settings.Disco.Limits.MaxClones < 0 {
+		problems = append(problems, "disco.limits.maxclones: should be a positive number")
+	}
+	if settings.Disco.Limits.QueueSize < 0 {
+		problems = append(problems, "disco.limits.queuesize: should be a positive number")
+	}
+
+	if level := settings.Disco.Log.Level; len(level) > 0 {
+		if _, err := log.ParseLevel(level); err != nil {
+			problems = append(problems, fmt.Sprintf("disco.log.level: %v", err))
+		}
+	}
+	switch settings.Disco.Log.Format {
+	case "", LogFormatText, LogFormatJSON:
+	default:
+		problems = append(problems, fmt.Sprintf("disco.log.format: should be %s or %s", LogFormatText, LogFormatJSON))
+	}
+
+	tracing := settings.Disco.Tracing
+	if len(tracing.Endpoint) > 0 {
+		if err := checkURL(tracing.Endpoint); err != nil {
+			problems = append(problems, fmt.Sprintf("disco.tracing.endpoint: %v", err))
+		}
+	} else if tracing.Enabled {
+		problems = append(problems, "disco.tracing: requires an endpoint")
+	}
+	if ratio := tracing.SampleRatio; ratio != nil && (*ratio < 0 || *ratio > 1) {
+		problems = append(problems, "disco.tracing.sampleratio: should be between 0 and 1")
+	}
+
+	unixSocket := settings.Disco.UnixSocket
+	if _, err := unixSocket.FileMode(); err != nil {
+		problems = append(problems, fmt.Sprintf("disco.unixsocket.mode: %v", err))
+	}
+	if unixSocket.NoTCP && len(unixSocket.Path) == 0 {
+		problems = append(problems, "disco.unixsocket.notcp: needs a socket path")
+	}
+
+	hosts := make(map[string]bool)
+	for i, vhost := range settings.Disco.VirtualHosts {
+		if len(vhost.Host) == 0 {
+			problems = append(problems, fmt.Sprintf("disco.virtualhosts[%d].host: should not be empty", i))
+		}
+		if hosts[strings.ToLower(vhost.Host)] {
+			problems = append(problems, fmt.Sprintf("disco.virtualhosts[%d].host: duplicate host '%s'", i, vhost.Host))
+		}
+		hosts[strings.ToLower(vhost.Host)] = true
+		if len(vhost.Config) == 0 {
+			problems = append(problems, fmt.Sprintf("disco.virtualhosts[%d].config: should not be empty", i))
+		}
+	}
+
+	namespaces := make(map[string]bool)
+	for i, tenant := range settings.Disco.Tenants {
+		if len(tenant.Namespace) == 0 || strings.Contains(tenant.Namespace, "/") {
+			problems = append(problems, fmt.Sprintf("disco.tenants[%d].namespace: should be a single path component", i))
+		}
+		if namespaces[tenant.Namespace] {
+			problems = append(problems, fmt.Sprintf("disco.tenants[%d].namespace: duplicate namespace '%s'", i, tenant.Namespace))
+		}
+		namespaces[tenant.Namespace] = true
+		if len(tenant.Config) == 0 {
+			problems = append(problems, fmt.Sprintf("disco.tenants[%d].config: should not be empty", i))
+		}
+		if tenant.Quota < 0 {
+			problems = append(problems, fmt.Sprintf("disco.tenants[%d].quota: should be a positive number", i))
+		}
+	}
+
+	// the rules are compiled here so that they are ready after loading
+	for i, rule := range settings.Disco.PushRules {
+		if err := rule.Compile(); err != nil {
+			problems = append(problems, fmt.Sprintf("disco.pushrules[%d]: %v", i, err))
+		}
+	}
+
+	tlsSettings := settings.Disco.TLS
+	if _, err := tlsSettings.minVersion(); err != nil {
+		problems = append(problems, fmt.Sprintf("disco.tls.minversion: %v", err))
+	}
+	if (len(tlsSettings.Certificate) == 0) != (len(tlsSettings.Key) == 0) {
+		problems = append(problems, "disco.tls: certificate and key should be specified together")
+	}
+	if (len(tlsSettings.ClientCertificate) == 0) != (len(tlsSettings.ClientKey) == 0) {
+		problems = append(problems, "disco.tls: clientcertificate and clientkey should be specified together")
+	}
+	return
+}
+
+func checkWarmup(warmup *WarmupConfig, cacheOnly bool) (problems []string) {
+	if warmup.Interval < 0 {
+		problems = append(problems, "disco.warmup.interval: should be a positive duration")
+	}
+	if len(warmup.URL) > 0 {
+		if err := checkURL(warmup.URL); err != nil {
+			problems = append(problems, fmt.Sprintf("disco.warmup.url: %v", err))
+		}
+	}
+	if warmup.Enabled && len(warmup.Cids) == 0 && len(warmup.URL) == 0 {
+		problems = append(problems, "disco.warmup: requires cids or url")
+	}
+	if warmup.Enabled && cacheOnly {
+		problems = append(problems, "disco.warmup: requires the ipfs nodes")
+	}
+	return
+}
+
+func checkNames(names *NamesConfig, cacheOnly bool) (problems []string) {
+	if names.TTL < 0 {
+		problems = append(problems, "disco.names.ttl: should be a positive duration")
+	}
+	if names.Timeout < 0 {
+		problems = append(problems, "disco.names.timeout: should be a positive duration")
+	}
+	for i, resolver := range names.Resolvers {
+		keyPath := fmt.Sprintf("disco.names.resolvers[%d]", i)
+		switch resolver.Kind {
+		case NameResolverIdentity, NameResolverDigest:
+			continue
+		case NameResolverENS, NameResolverForta:
+		default:
+			problems = append(problems, fmt.Sprintf("%s.kind: should be one of identity, digest, ens and forta", keyPath))
+			continue
+		}
+		if err := checkURL(resolver.Endpoint); err != nil {
+			problems = append(problems, fmt.Sprintf("%s.endpoint: %v", keyPath, err))
+		}
+		if len(resolver.Registry) > 0 && !ethAddressPattern.MatchString(resolver.Registry) {
+			problems = append(problems, fmt.Sprintf("%s.registry: should be a 0x-prefixed contract address", keyPath))
+		}
+		if resolver.Kind == NameResolverForta && len(resolver.Registry) == 0 {
+			problems = append(problems, fmt.Sprintf("%s.registry: requires the agent registry address", keyPath))
+		}
+		if resolver.Kind == NameResolverForta && cacheOnly {
+			problems = append(problems, fmt.Sprintf("%s: requires the ipfs nodes", keyPath))
+		}
+	}
+	return
+}
+
+func checkUsageAlerts(alerts *UsageAlertsConfig) (problems []string) {
+	if alerts.Interval < 0 {
+		problems = append(problems, "disco.usagealerts.interval: should be a positive duration")
+	}
+	if alerts.IPFSRatio < 0 || alerts.IPFSRatio > 1 {
+		problems = append(problems, "disco.usagealerts.ipfsratio: should be a ratio between 0 and 1")
+	}
+	if alerts.CacheSize < 0 {
+		problems = append(problems, "disco.usagealerts.cachesize: should be a positive number")
+	}
+	if alerts.Enabled && alerts.IPFSRatio == 0 && alerts.CacheSize == 0 {
+		problems = append(problems, "disco.usagealerts: requires ipfsratio or cachesize")
+	}
+	if len(alerts.Webhook) > 0 {
+		if err := checkURL(alerts.Webhook); err != nil {
+			problems = append(problems, fmt.Sprintf("disco.usagealerts.webhook: %v", err))
+		}
+	}
+	return
+}
+
+func checkUsageStats(stats *UsageStatsConfig, hasCache bool) (problems []string) {
+	if stats.Interval < 0 {
+		problems = append(problems, "disco.usagestats.interval: should be a positive duration")
+	}
+	switch stats.Format {
+	case "", UsageStatsFormatJSON, UsageStatsFormatCSV:
+	default:
+		problems = append(problems, fmt.Sprintf("disco.usagestats.format: should be %s or %s", UsageStatsFormatJSON, UsageStatsFormatCSV))
+	}
+	if stats.Enabled && !hasCache && len(stats.Webhook) == 0 {
+		problems = append(problems, "disco.usagestats: requires a cache driver in storage.ipfs.cache or a webhook")
+	}
+	if len(stats.Webhook) > 0 {
+		if err := checkURL(stats.Webhook); err != nil {
+			problems = append(problems, fmt.Sprintf("disco.usagestats.webhook: %v", err))
+		}
+	}
+	return
+}
+
+func checkBandwidth(bandwidth *BandwidthConfig) (problems []string) {
+	if bandwidth.RepoCap < 0 {
+		problems = append(problems, "disco.bandwidth.repocap: should not be negative")
+	}
+	if bandwidth.ClientCap < 0 {
+		problems = append(problems, "disco.bandwidth.clientcap: should not be negative")
+	}
+	if !bandwidth.Enabled && (bandwidth.RepoCap > 0 || bandwidth.ClientCap > 0) {
+		problems = append(problems, "disco.bandwidth: the caps need the bandwidth accounting to be enabled")
+	}
+	return
+}
+
+func checkURL(rawURL string) error {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return fmt.Errorf("malformed URL: %v", err)
+	}
+	if u.Scheme != "http" && u.Scheme != "https" {
+		return fmt.Errorf("expected an http or https URL but found '%s'", rawURL)
+	}
+	if len(u.Host) == 0 {
+		return fmt.Errorf("missing host in '%s'", rawURL)
+	}
+	return nil
+}
@@ -0,0 +1,64 @@
+package config
+
+import (
+	"os"
+	"path"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+const testInvalidConfig = `version: 0.1
+storage:
+  ipfs:
+    rooter:
+      nodes:
+        - url: http://localhost:5001
+    cacheonly: true
+    redirect: ftp://some.url
+    cachecompression:
+      enabled: true
+      level: max
+  maintenance:
+    uploadpurging:
+      enabled: false
+disco:
+  noclone: true
+  nocloen: true
+  canonicaltag: -release
+  streamblobs: true
+  admin:
+    addr: 127.0.0.1:1971
+htp:
+  addr: :5000
+`
+
+func TestValidate(t *testing.T) {
+	r := require.New(t)
+
+	configPath := path.Join(t.TempDir(), "config.yaml")
+	r.NoError(os.WriteFile(configPath, []byte(testInvalidConfig), 0644))
+
+	err := Validate(configPath, "")
+	r.Error(err)
+	validationErr, ok := err.(*ValidationError)
+	r.True(ok)
+	r.ElementsMatch([]string{
+		"storage.ipfs.rooter: unknown key (line 4)",
+		"disco.nocloen: unknown key (line 17)",
+		"htp: unknown key (line 22)",
+		"storage.ipfs.cacheonly: requires a cache driver in storage.ipfs.cache",
+		"storage.ipfs.redirect: requires a cache driver in storage.ipfs.cache",
+		"storage.ipfs.redirect: expected an http or https URL but found 'ftp://some.url'",
+		"storage.ipfs.cachecompression: requires a cache driver in storage.ipfs.cache",
+		"storage.ipfs.cachecompression: cannot be used with storage.ipfs.redirect",
+		"storage.ipfs.cachecompression.level: expected 'fastest', 'default', 'better' or 'best' but found 'max'",
+		"disco.canonicaltag: '-release' is not a valid tag",
+		"disco.streamblobs: requires disco.lazyclone",
+		"disco.admin.addr: needs the token, the HMAC secret or the API keys",
+	}, validationErr.Problems)
+}
+
+func TestValidate_DefaultConfig(t *testing.T) {
+	require.NoError(t, Validate("default-config.yaml", ""))
+}
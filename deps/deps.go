@@ -1,29 +1,23 @@
 package deps
 
 import (
-	"sync"
+	"net/http"
 
 	"github.com/forta-network/disco/config"
 	"github.com/forta-network/disco/interfaces"
 	"github.com/forta-network/disco/ipfsclient"
+	"github.com/forta-network/disco/utils"
 	log "github.com/sirupsen/logrus"
 )
 
-var (
-	client interfaces.IPFSClient
-
-	once sync.Once
-)
-
-// Get checks the config and returns the service dependencies.
-func Get() interfaces.IPFSClient {
-	once.Do(func() {
-		client = initialize()
-	})
-	return client
-}
-
-func initialize() interfaces.IPFSClient {
+// New creates the service dependencies from the config.
+func New(cfg *config.Config) interfaces.IPFSClient {
 	log.Info("running with ipfs router client")
-	return ipfsclient.NewRouterClient(&config.Router)
+	// the requests to the nodes are traced in the spans of the operations
+	httpClient := &http.Client{Transport: utils.TraceTransport(cfg.HTTPTransport())}
+	routerClient := ipfsclient.NewRouterClient(&cfg.Router, cfg.SharedState, httpClient)
+	cfg.OnReload(func() {
+		routerClient.SetNodes(cfg.Router.Nodes)
+	})
+	return routerClient
 }
@@ -7,15 +7,16 @@ import (
 	"github.com/stretchr/testify/require"
 )
 
-func TestGet(t *testing.T) {
+func TestNew(t *testing.T) {
 	r := require.New(t)
 
-	config.Router.Nodes = []*config.Node{
+	cfg := &config.Config{}
+	cfg.Router.Nodes = []*config.Node{
 		{
 			URL: "http://ipfs.url:5001",
 		},
 	}
-	client := initialize()
+	client := New(cfg)
 
 	r.NotNil(client)
 }
@@ -0,0 +1,84 @@
+package drivers
+
+import (
+	"context"
+	"sort"
+
+	storagedriver "github.com/distribution/distribution/v3/registry/storage/driver"
+)
+
+// TreeFile is a file in the tree of a store.
+type TreeFile struct {
+	Path string `json:"path"`
+	Size int64  `json:"size"`
+}
+
+// SizeMismatch is a file which is in both trees with different sizes.
+type SizeMismatch struct {
+	Path       string `json:"path"`
+	FirstSize  int64  `json:"firstSize"`
+	SecondSize int64  `json:"secondSize"`
+}
+
+// TreeDiff is the difference between two trees.
+type TreeDiff struct {
+	OnlyInFirst  []*TreeFile     `json:"onlyInFirst"`
+	OnlyInSecond []*TreeFile     `json:"onlyInSecond"`
+	Mismatches   []*SizeMismatch `json:"mismatches"`
+}
+
+// Empty tells if the trees are the same.
+func (diff *TreeDiff) Empty() bool {
+	return len(diff.OnlyInFirst) == 0 && len(diff.OnlyInSecond) == 0 && len(diff.Mismatches) == 0
+}
+
+// WalkTree walks the store from the root and returns its files sorted by their paths. The tree
+// is empty if the root does not exist.
+func WalkTree(ctx context.Context, driver storagedriver.StorageDriver, root string) ([]*TreeFile, error) {
+	var files []*TreeFile
+	err := driver.Walk(ctx, root, func(fileInfo storagedriver.FileInfo) error {
+		if !fileInfo.IsDir() {
+			files = append(files, &TreeFile{Path: fileInfo.Path(), Size: fileInfo.Size()})
+		}
+		return nil
+	})
+	if _, ok := err.(storagedriver.PathNotFoundError); ok {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	sort.Slice(files, func(i, j int) bool {
+		return files[i].Path < files[j].Path
+	})
+	return files, nil
+}
+
+// DiffTrees compares the trees which are sorted by their paths.
+func DiffTrees(first, second []*TreeFile) *TreeDiff {
+	diff := &TreeDiff{}
+	var i, j int
+	for i < len(first) && j < len(second) {
+		switch {
+		case first[i].Path < second[j].Path:
+			diff.OnlyInFirst = append(diff.OnlyInFirst, first[i])
+			i++
+		case first[i].Path > second[j].Path:
+			diff.OnlyInSecond = append(diff.OnlyInSecond, second[j])
+			j++
+		default:
+			if first[i].Size != second[j].Size {
+				diff.Mismatches = append(diff.Mismatches, &SizeMismatch{
+					Path:       first[i].Path,
+					FirstSize:  first[i].Size,
+					SecondSize: second[j].Size,
+				})
+			}
+			i++
+			j++
+		}
+	}
+	diff.OnlyInFirst = append(diff.OnlyInFirst, first[i:]...)
+	diff.OnlyInSecond = append(diff.OnlyInSecond, second[j:]...)
+	return diff
+}
@@ -0,0 +1,43 @@
+package drivers
+
+import (
+	"context"
+	"testing"
+
+	"github.com/distribution/distribution/v3/registry/storage/driver/inmemory"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDiffTrees(t *testing.T) {
+	r := require.New(t)
+	ctx := context.Background()
+
+	// Given two stores which have converged partially
+	first := inmemory.New()
+	second := inmemory.New()
+	r.NoError(first.PutContent(ctx, "/root/a", []byte("a")))
+	r.NoError(second.PutContent(ctx, "/root/a", []byte("a")))
+	r.NoError(first.PutContent(ctx, "/root/b/c", []byte("c")))
+	r.NoError(second.PutContent(ctx, "/root/b/d", []byte("dd")))
+	r.NoError(first.PutContent(ctx, "/root/e", []byte("e")))
+	r.NoError(second.PutContent(ctx, "/root/e", []byte("ee")))
+
+	// When their trees are compared
+	firstTree, err := WalkTree(ctx, first, "/root")
+	r.NoError(err)
+	secondTree, err := WalkTree(ctx, second, "/root")
+	r.NoError(err)
+	diff := DiffTrees(firstTree, secondTree)
+
+	// Then the missing files and the size mismatches should be reported
+	r.False(diff.Empty())
+	r.Equal([]*TreeFile{{Path: "/root/b/c", Size: 1}}, diff.OnlyInFirst)
+	r.Equal([]*TreeFile{{Path: "/root/b/d", Size: 2}}, diff.OnlyInSecond)
+	r.Equal([]*SizeMismatch{{Path: "/root/e", FirstSize: 1, SecondSize: 2}}, diff.Mismatches)
+
+	// And the missing roots should be empty trees
+	tree, err := WalkTree(ctx, first, "/missing")
+	r.NoError(err)
+	r.Empty(tree)
+	r.True(DiffTrees(firstTree, firstTree).Empty())
+}
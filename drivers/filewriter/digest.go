@@ -0,0 +1,64 @@
+package filewriter
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"hash"
+
+	storagedriver "github.com/distribution/distribution/v3/registry/storage/driver"
+)
+
+// DigestWriter computes the sha256 digest of the content while it is written to the
+// underlying writer, so that the content can be verified without reading it again.
+type DigestWriter struct {
+	fw   storagedriver.FileWriter
+	hash hash.Hash
+}
+
+// WithDigest wraps given writer with a digest writer. The digest covers only the content
+// which is written through the digest writer, so the writers which append to existing
+// content should not be wrapped.
+func WithDigest(fw storagedriver.FileWriter) *DigestWriter {
+	return &DigestWriter{fw: fw, hash: sha256.New()}
+}
+
+// Write implements storagedriver.FileWriter.
+func (dw *DigestWriter) Write(p []byte) (int, error) {
+	n, err := dw.fw.Write(p)
+	dw.hash.Write(p[:n])
+	return n, err
+}
+
+// Size implements storagedriver.FileWriter.
+func (dw *DigestWriter) Size() int64 {
+	return dw.fw.Size()
+}
+
+// Close implements storagedriver.FileWriter.
+func (dw *DigestWriter) Close() error {
+	return dw.fw.Close()
+}
+
+// Cancel implements storagedriver.FileWriter.
+func (dw *DigestWriter) Cancel() error {
+	return dw.fw.Cancel()
+}
+
+// Commit implements storagedriver.FileWriter.
+func (dw *DigestWriter) Commit() error {
+	return dw.fw.Commit()
+}
+
+// Digest returns the hex-encoded sha256 digest of the content written so far.
+func (dw *DigestWriter) Digest() string {
+	return hex.EncodeToString(dw.hash.Sum(nil))
+}
+
+// Verify checks the digest of the content written so far against the expected hex digest.
+func (dw *DigestWriter) Verify(expected string) error {
+	if actual := dw.Digest(); actual != expected {
+		return fmt.Errorf("content has digest %s instead of %s", actual, expected)
+	}
+	return nil
+}
@@ -0,0 +1,23 @@
+package filewriter
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestDigestWriter(t *testing.T) {
+	r := require.New(t)
+
+	dw := WithDigest(&StubWriter{})
+
+	_, err := dw.Write([]byte("hello "))
+	r.NoError(err)
+	_, err = dw.Write([]byte("world"))
+	r.NoError(err)
+	r.Equal(int64(11), dw.Size())
+	r.Equal("b94d27b9934d3e08a52e52d7da7dabfac484efe37a5380ee9088f7ace2efcde9", dw.Digest())
+	r.NoError(dw.Verify("b94d27b9934d3e08a52e52d7da7dabfac484efe37a5380ee9088f7ace2efcde9"))
+	r.Error(dw.Verify("a948904f2f0f479b8f8197694b30184b0d2ed1c1cd2a1ec0fb85d299a192a447"))
+	r.NoError(dw.Commit())
+}
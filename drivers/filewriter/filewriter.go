@@ -1,59 +1,107 @@
 package filewriter
 
 import (
+	"bufio"
 	"context"
+	"errors"
 	"io"
-	"sync"
+	"time"
 
-	storagedriver "github.com/distribution/distribution/v3/registry/storage/driver"
 	log "github.com/sirupsen/logrus"
 )
 
-type FileWriter struct {
-	ctx  context.Context
-	path string
-	pr   *io.PipeReader
-	pw   *io.PipeWriter
-	size int64
+// removeTimeout limits the removal of the partial file after the writer is canceled. The
+// context of the writer is usually done by then, so the removal does not use it.
+const removeTimeout = time.Minute
+
+// ErrCanceled is returned from the writes after the writer is canceled.
+var ErrCanceled = errors.New("writer is canceled")
 
+type FileWriter struct {
+	ctx        context.Context
+	cancel     context.CancelFunc
+	path       string
+	pr         *io.PipeReader
+	pw         *io.PipeWriter
+	buf        *bufio.Writer
+	size       int64
+	removeFunc RemoveFunc
+	done       chan struct{}
+
+	committed bool
+	canceled  bool
+
+	// err is set before done is closed
 	err error
-	mu  sync.Mutex
 }
 
 // WriteFunc abstracts away the writer method.
 type WriteFunc func(ctx context.Context, path string, reader io.Reader) error
 
-// NewFileWriter creates a new file writer.
-func NewFileWriter(ctx context.Context, driverName string, writeFunc WriteFunc, path string, size int64) *FileWriter {
+// RemoveFunc abstracts away the removal of the partially written file.
+type RemoveFunc func(ctx context.Context, path string) error
+
+// NewFileWriter creates a new file writer. The remove func is called after the writer is
+// canceled and can be nil if the partial file should be kept. The writes are buffered into
+// chunks of given size before they are passed to the write func, unless the chunk size is zero.
+func NewFileWriter(ctx context.Context, driverName string, writeFunc WriteFunc, removeFunc RemoveFunc, path string, size int64, chunkSize int) *FileWriter {
 	pr, pw := io.Pipe()
+	ctx, cancel := context.WithCancel(ctx)
 
 	fw := &FileWriter{
-		ctx:  ctx,
-		path: path,
-		pr:   pr,
-		pw:   pw,
-		size: size,
+		ctx:        ctx,
+		cancel:     cancel,
+		path:       path,
+		pr:         pr,
+		pw:         pw,
+		size:       size,
+		removeFunc: removeFunc,
+		done:       make(chan struct{}),
+	}
+	if chunkSize > 0 {
+		fw.buf = bufio.NewWriterSize(pw, chunkSize)
 	}
 
 	go func(fw *FileWriter) {
-		fw.mu.Lock()
-		fw.err = writeFunc(ctx, path, pr)
-		log.WithField("driver", driverName).WithError(fw.err).Debug("writer done")
-		fw.mu.Unlock()
+		err := writeFunc(ctx, path, pr)
+		cancel()
+		// unblock the writes if the write func returns before reading everything
+		pr.CloseWithError(err)
+		log.WithField("driver", driverName).WithError(err).Debug("writer done")
+		fw.err = err
+		close(fw.done)
 	}(fw)
 
 	return fw
 }
 
-func (fw *FileWriter) getErr() error {
-	fw.mu.Lock()
-	err := fw.err
-	fw.mu.Unlock()
-	return err
+// wait blocks until the write func returns and returns its error.
+func (fw *FileWriter) wait() error {
+	<-fw.done
+	return fw.err
 }
 
 func (fw *FileWriter) Write(p []byte) (int, error) {
-	n, err := fw.pw.Write(p)
+	if fw.canceled {
+		return 0, ErrCanceled
+	}
+	// fail early instead of buffering if the write func failed
+	select {
+	case <-fw.done:
+		if fw.err != nil {
+			return 0, fw.err
+		}
+	default:
+	}
+	var (
+		n   int
+		err error
+	)
+	if fw.buf != nil {
+		n, err = fw.buf.Write(p)
+	} else {
+		n, err = fw.pw.Write(p)
+	}
 	fw.size += int64(n)
 	return n, err
 }
@@ -63,66 +111,53 @@ func (fw *FileWriter) Size() int64 {
 }
 
 func (fw *FileWriter) Close() error {
+	if fw.canceled {
+		return nil
+	}
+	var flushErr error
+	if fw.buf != nil {
+		flushErr = fw.buf.Flush()
+	}
 	fw.pw.Close()
-	return fw.getErr()
+	// the content should be readable from the path after the writer is closed
+	if err := fw.wait(); err != nil {
+		return err
+	}
+	return flushErr
 }
 
+// Cancel aborts the in-flight write and removes the partially written file. It does nothing
+// after the writer is committed.
 func (fw *FileWriter) Cancel() error {
-	return fw.Close()
+	if fw.committed || fw.canceled {
+		return nil
+	}
+	fw.canceled = true
+	fw.cancel()
+	// the buffered content is discarded
+	fw.pw.CloseWithError(ErrCanceled)
+	// the write func should not recreate the file after it is removed
+	_ = fw.wait()
+	if fw.removeFunc == nil {
+		return nil
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), removeTimeout)
+	defer cancel()
+	return fw.removeFunc(ctx, fw.path)
 }
 
+// Commit blocks until the content is written to the path and returns the write error.
 func (fw *FileWriter) Commit() error {
-	return fw.Close()
+	if fw.canceled {
+		return ErrCanceled
+	}
+	if err := fw.Close(); err != nil {
+		return err
+	}
+	fw.committed = true
+	return nil
 }
 
 func (fw *FileWriter) ReadCloser() io.ReadCloser {
 	return fw.pr
 }
-
-type loggerWriter struct {
-	name string
-	path string
-	fw   storagedriver.FileWriter
-}
-
-// WithLogger wraps given driver with a logger.
-func WithLogger(name, path string, fw storagedriver.FileWriter) storagedriver.FileWriter {
-	return &loggerWriter{name: name, path: path, fw: fw}
-}
-
-func (lw *loggerWriter) logger() *log.Entry {
-	return log.WithFields(log.Fields{
-		"driver": lw.name,
-		"path":   lw.path,
-	})
-}
-
-func (lw *loggerWriter) Write(p []byte) (int, error) {
-	n, err := lw.fw.Write(p)
-	lw.logger().WithFields(log.Fields{
-		"wrote":   n,
-		"newSize": lw.fw.Size(),
-	}).Debug("(FileWriter).Write")
-	return n, err
-}
-
-func (lw *loggerWriter) Size() int64 {
-	size := lw.fw.Size()
-	lw.logger().WithField("size", size).Debug("(FileWriter).Size")
-	return size
-}
-
-func (lw *loggerWriter) Close() error {
-	lw.logger().Debug("(FileWriter).Close")
-	return lw.fw.Close()
-}
-
-func (lw *loggerWriter) Cancel() error {
-	lw.logger().Debug("(FileWriter).Cancel")
-	return lw.fw.Cancel()
-}
-
-func (lw *loggerWriter) Commit() error {
-	lw.logger().Debug("(FileWriter).Commit")
-	return lw.fw.Commit()
-}
@@ -2,8 +2,10 @@ package filewriter
 
 import (
 	"context"
+	"errors"
 	"io"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/require"
 )
@@ -20,10 +22,10 @@ func TestFileWriter(t *testing.T) {
 		r.NoError(err)
 		r.Equal(1, n)
 		return nil
-	}, "", 0)
+	}, nil, "", 0, 0)
 	rc = fileWriter.ReadCloser()
 
-	fw := WithLogger("", "", fileWriter)
+	fw := WithMetrics("", "", fileWriter)
 
 	n, err := fw.Write([]byte("1"))
 	r.NoError(err)
@@ -36,3 +38,105 @@ func TestFileWriter(t *testing.T) {
 
 	r.Equal([]byte("1"), out)
 }
+
+func TestFileWriter_Cancel(t *testing.T) {
+	r := require.New(t)
+
+	var (
+		writeErr    error
+		removedPath string
+	)
+	fileWriter := NewFileWriter(context.Background(), "", func(ctx context.Context, path string, reader io.Reader) error {
+		_, writeErr = io.Copy(io.Discard, reader)
+		return writeErr
+	}, func(ctx context.Context, path string) error {
+		removedPath = path
+		return nil
+	}, "/test-path", 0, 0)
+
+	_, err := fileWriter.Write([]byte("1"))
+	r.NoError(err)
+
+	r.NoError(fileWriter.Cancel())
+	r.ErrorIs(writeErr, ErrCanceled)
+	r.Equal("/test-path", removedPath)
+
+	_, err = fileWriter.Write([]byte("2"))
+	r.ErrorIs(err, ErrCanceled)
+	r.ErrorIs(fileWriter.Commit(), ErrCanceled)
+	r.NoError(fileWriter.Close())
+}
+
+func TestFileWriter_CancelAfterCommit(t *testing.T) {
+	r := require.New(t)
+
+	fileWriter := NewFileWriter(context.Background(), "", func(ctx context.Context, path string, reader io.Reader) error {
+		_, err := io.Copy(io.Discard, reader)
+		return err
+	}, func(ctx context.Context, path string) error {
+		r.FailNow("should not remove committed file")
+		return nil
+	}, "/test-path", 0, 0)
+
+	r.NoError(fileWriter.Commit())
+	r.NoError(fileWriter.Cancel())
+}
+
+func TestFileWriter_CommitWaits(t *testing.T) {
+	r := require.New(t)
+
+	var written bool
+	fileWriter := NewFileWriter(context.Background(), "", func(ctx context.Context, path string, reader io.Reader) error {
+		_, err := io.Copy(io.Discard, reader)
+		time.Sleep(10 * time.Millisecond)
+		written = true
+		return err
+	}, nil, "/test-path", 0, 0)
+
+	_, err := fileWriter.Write([]byte("1"))
+	r.NoError(err)
+	r.NoError(fileWriter.Commit())
+	r.True(written)
+}
+
+func TestFileWriter_WriteFailure(t *testing.T) {
+	r := require.New(t)
+
+	writeErr := errors.New("failed to write")
+	fileWriter := NewFileWriter(context.Background(), "", func(ctx context.Context, path string, reader io.Reader) error {
+		return writeErr
+	}, nil, "/test-path", 0, 0)
+
+	_, err := fileWriter.Write([]byte("1"))
+	r.ErrorIs(err, writeErr)
+	r.ErrorIs(fileWriter.Commit(), writeErr)
+}
+
+func TestFileWriter_Chunks(t *testing.T) {
+	r := require.New(t)
+
+	var chunks []string
+	fileWriter := NewFileWriter(context.Background(), "", func(ctx context.Context, path string, reader io.Reader) error {
+		b := make([]byte, 16)
+		for {
+			n, err := reader.Read(b)
+			if n > 0 {
+				chunks = append(chunks, string(b[:n]))
+			}
+			if err == io.EOF {
+				return nil
+			}
+			if err != nil {
+				return err
+			}
+		}
+	}, nil, "/test-path", 0, 4)
+
+	for _, p := range []string{"a", "b", "c", "d", "e"} {
+		_, err := fileWriter.Write([]byte(p))
+		r.NoError(err)
+	}
+	r.Equal(int64(5), fileWriter.Size())
+	r.NoError(fileWriter.Commit())
+	r.Equal([]string{"abcd", "e"}, chunks)
+}
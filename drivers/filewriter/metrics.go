@@ -0,0 +1,128 @@
+package filewriter
+
+import (
+	"time"
+
+	storagedriver "github.com/distribution/distribution/v3/registry/storage/driver"
+	metrics "github.com/docker/go-metrics"
+	log "github.com/sirupsen/logrus"
+)
+
+// logInterval is the minimum interval between the progress logs of a writer.
+const logInterval = 10 * time.Second
+
+var (
+	metricsNamespace = metrics.NewNamespace("disco", "writer", nil)
+	// the metrics are exposed with the registry metrics
+	bytesWritten  = metricsNamespace.NewLabeledCounter("bytes", "The number of bytes written to the drivers", "driver")
+	writeDuration = metricsNamespace.NewLabeledTimer("write_duration", "The duration of the writes to the drivers", "driver")
+	writerResults = metricsNamespace.NewLabeledCounter("writers", "The outcomes of the driver writers", "driver", "outcome")
+)
+
+func init() {
+	metrics.Register(metricsNamespace)
+}
+
+// Writer outcomes.
+const (
+	outcomeCommitted = "committed"
+	outcomeCanceled  = "canceled"
+	outcomeFailed    = "failed"
+)
+
+type metricsWriter struct {
+	name    string
+	path    string
+	fw      storagedriver.FileWriter
+	start   time.Time
+	lastLog time.Time
+	written int64
+	writes  int
+	elapsed time.Duration
+	outcome string
+}
+
+// WithMetrics wraps given writer so that the writes are measured per driver. The progress is
+// logged at most once in every log interval and the totals are logged when the writer is done.
+func WithMetrics(name, path string, fw storagedriver.FileWriter) storagedriver.FileWriter {
+	now := time.Now()
+	return &metricsWriter{name: name, path: path, fw: fw, start: now, lastLog: now}
+}
+
+func (mw *metricsWriter) logger() *log.Entry {
+	return log.WithFields(log.Fields{
+		"driver":  mw.name,
+		"path":    mw.path,
+		"written": mw.written,
+		"writes":  mw.writes,
+		"size":    mw.fw.Size(),
+	})
+}
+
+func (mw *metricsWriter) Write(p []byte) (int, error) {
+	start := time.Now()
+	n, err := mw.fw.Write(p)
+	duration := time.Since(start)
+
+	mw.written += int64(n)
+	mw.writes++
+	mw.elapsed += duration
+	bytesWritten.WithValues(mw.name).Inc(float64(n))
+	writeDuration.WithValues(mw.name).Update(duration)
+
+	if now := time.Now(); now.Sub(mw.lastLog) >= logInterval {
+		mw.lastLog = now
+		mw.logger().Debug("writing")
+	}
+	return n, err
+}
+
+func (mw *metricsWriter) Size() int64 {
+	return mw.fw.Size()
+}
+
+func (mw *metricsWriter) Close() error {
+	return mw.fw.Close()
+}
+
+func (mw *metricsWriter) Cancel() error {
+	err := mw.fw.Cancel()
+	mw.done(outcomeCanceled, err)
+	return err
+}
+
+func (mw *metricsWriter) Commit() error {
+	err := mw.fw.Commit()
+	outcome := outcomeCommitted
+	if err != nil {
+		outcome = outcomeFailed
+	}
+	mw.done(outcome, err)
+	return err
+}
+
+// done records the outcome and logs the totals of the writer once, e.g. the cancel after
+// a failed commit is not recorded again.
+func (mw *metricsWriter) done(outcome string, err error) {
+	if len(mw.outcome) > 0 {
+		return
+	}
+	mw.outcome = outcome
+	writerResults.WithValues(mw.name, outcome).Inc(1)
+
+	total := time.Since(mw.start)
+	fields := log.Fields{
+		"outcome":   outcome,
+		"total":     total.String(),
+		"writeTime": mw.elapsed.String(),
+	}
+	if seconds := total.Seconds(); seconds > 0 {
+		fields["bytesPerSecond"] = int64(float64(mw.written) / seconds)
+	}
+	logger := mw.logger().WithFields(fields)
+	if err != nil {
+		logger.WithError(err).Warn("writer failed")
+		return
+	}
+	logger.Debug("writer done")
+}
@@ -0,0 +1,27 @@
+package filewriter
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestMetricsWriter(t *testing.T) {
+	r := require.New(t)
+
+	fw := WithMetrics("test", "/test-path", &StubWriter{})
+
+	_, err := fw.Write([]byte("hello "))
+	r.NoError(err)
+	_, err = fw.Write([]byte("world"))
+	r.NoError(err)
+	r.Equal(int64(11), fw.Size())
+
+	mw := fw.(*metricsWriter)
+	r.Equal(int64(11), mw.written)
+	r.Equal(2, mw.writes)
+
+	r.NoError(fw.Commit())
+	r.NoError(fw.Cancel())
+	r.Equal(outcomeCommitted, mw.outcome)
+}
@@ -8,23 +8,42 @@ import (
 	"github.com/forta-network/disco/drivers/multidriver"
 )
 
-// FixUploadPath rewrites .../repository/<name>/_uploads to another path to make things easier.
+// TLSConfigParameter passes the client TLS config from the Disco config to the cache drivers
+// which support it.
+const TLSConfigParameter = "disco.tlsconfig"
+
+// RepoUploadsPath is where the IPFS driver keeps the uploads, in a dir per repository.
+const RepoUploadsPath = "/docker/registry/v2/uploads/repos"
+
+// RepoUploadsKey returns the name of the dir which has the uploads of the repository. The
+// repository names cannot have "+", so the names are not mixed up.
+func RepoUploadsKey(repoName string) string {
+	return strings.ReplaceAll(repoName, "/", "+")
+}
+
+// RepoNameFromUploadsKey returns the repository name from the name of its uploads dir.
+func RepoNameFromUploadsKey(key string) string {
+	return strings.ReplaceAll(key, "+", "/")
+}
+
+// FixUploadPath rewrites .../repositories/<name>/_uploads to a path under RepoUploadsPath to
+// make things easier, e.g. .../repositories/myorg/myrepo/_uploads/<uuid>/data is rewritten to
+// /docker/registry/v2/uploads/repos/myorg+myrepo/<uuid>/data.
 func FixUploadPath(path string) string {
-	if !strings.Contains(path, "/_uploads") {
+	i := strings.Index(path, "/_uploads")
+	if i < 0 {
+		return path
+	}
+	rest := path[i+len("/_uploads"):]
+	if len(rest) > 0 && rest[0] != '/' {
 		return path
 	}
-	newPath := "/docker/registry/v2/uploads"
-	var append bool
-	// append the segments after "_uploads"
-	for _, segment := range strings.Split(path, "/") {
-		if append {
-			newPath += "/" + segment
-		}
-		if segment == "_uploads" {
-			append = true
-		}
+	j := strings.Index(path[:i], "/repositories/")
+	if j < 0 {
+		return path
 	}
-	return newPath
+	repoName := path[j+len("/repositories/") : i]
+	return RepoUploadsPath + "/" + RepoUploadsKey(repoName) + rest
 }
 
 // Copy copies from src to dst.
@@ -0,0 +1,18 @@
+package drivers
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestFixUploadPath(t *testing.T) {
+	r := require.New(t)
+
+	r.Equal(RepoUploadsPath+"/myorg+myrepo/1234/data",
+		FixUploadPath("/docker/registry/v2/repositories/myorg/myrepo/_uploads/1234/data"))
+	r.Equal(RepoUploadsPath+"/myrepo", FixUploadPath("/docker/registry/v2/repositories/myrepo/_uploads"))
+	r.Equal("/docker/registry/v2/repositories/myrepo/_manifests",
+		FixUploadPath("/docker/registry/v2/repositories/myrepo/_manifests"))
+	r.Equal("myorg/myrepo", RepoNameFromUploadsKey(RepoUploadsKey("myorg/myrepo")))
+}
@@ -6,27 +6,37 @@ import (
 	"fmt"
 	"io"
 	"strings"
+	"sync"
 
 	"github.com/distribution/distribution/v3/configuration"
 	storagedriver "github.com/distribution/distribution/v3/registry/storage/driver"
 	"github.com/distribution/distribution/v3/registry/storage/driver/base"
 	"github.com/distribution/distribution/v3/registry/storage/driver/factory"
 	"github.com/forta-network/disco/config"
-	"github.com/forta-network/disco/deps"
 	"github.com/forta-network/disco/drivers"
 	"github.com/forta-network/disco/drivers/filewriter"
+	"github.com/forta-network/disco/drivers/metacache"
 	"github.com/forta-network/disco/drivers/multidriver"
+	"github.com/forta-network/disco/drivers/zstdcache"
 	"github.com/forta-network/disco/interfaces"
+	"github.com/forta-network/disco/utils"
 	ipfsapi "github.com/ipfs/go-ipfs-api"
+	"github.com/klauspost/compress/zstd"
 )
 
 const (
 	driverName = "ipfs"
+
+	// parameters which pass the dependencies to the driver factory, in addition to
+	// the ones from the config file
+	paramConfig     = "discoconfig"
+	paramIPFSClient = "discoipfsclient"
 )
 
 var (
 	defaultFactory = &driverFactory{}
 	defaultDriver  storagedriver.StorageDriver
+	configDrivers  sync.Map // *config.Config -> storagedriver.StorageDriver
 )
 
 func init() {
@@ -36,18 +46,41 @@ func init() {
 // driver is the concrete IPFS driver implementation.
 type driver struct {
 	api interfaces.IPFSClient
+	// chunkSize is the size of the chunks which the writes are buffered into.
+	chunkSize int
 }
 
 type driverFactory struct{}
 
+// SetDependencies adds the config and the IPFS client to the ipfs storage parameters of
+// the distribution config, so the driver factory can use them when the registry creates the driver.
+func SetDependencies(cfg *config.Config, client interfaces.IPFSClient) {
+	params := cfg.Distribution.Storage[driverName]
+	if params == nil {
+		params = make(configuration.Parameters)
+		cfg.Distribution.Storage[driverName] = params
+	}
+	params[paramConfig] = cfg
+	params[paramIPFSClient] = client
+}
+
 func (df *driverFactory) Create(parameters map[string]interface{}) (storagedriver.StorageDriver, error) {
-	ipfsDriver, err := fromParameters(parameters)
+	cfg, ok := parameters[paramConfig].(*config.Config)
+	if !ok {
+		return nil, fmt.Errorf("failed to create ipfs driver: missing config dependency")
+	}
+	ipfsDriver, err := fromParameters(parameters, cfg.WriteChunkSize)
 	if err != nil {
-		defaultDriver = ipfsDriver
-		return nil, fmt.Errorf("failed to create ipfs driver: %v", err)
+		setDriver(cfg, ipfsDriver)
+		return nil, fmt.Errorf("failed to create ipfs driver: %v", utils.RedactError(err))
+	}
+	if cfg.MetadataCache.Enabled {
+		stateCfg := cfg.SharedState
+		client := utils.NewRedisClient(stateCfg.Addr, stateCfg.Password, stateCfg.DB)
+		ipfsDriver.Base.StorageDriver = metacache.New(ipfsDriver.Base.StorageDriver, client, stateCfg.Prefix+"metadata/", cfg.MetadataCache.TTL)
 	}
-	if config.Cache == nil {
-		defaultDriver = ipfsDriver
+	if cfg.Cache == nil {
+		setDriver(cfg, ipfsDriver)
 		return ipfsDriver, nil
 	}
 	// create multidriver by using cache as secondary
@@ -55,21 +88,45 @@ func (df *driverFactory) Create(parameters map[string]interface{}) (storagedrive
 		driverName   string
 		driverParams configuration.Parameters
 	)
-	for dName, dParams := range config.Cache {
+	for dName, dParams := range cfg.Cache {
 		driverName = dName
 		driverParams = dParams
 		break
 	}
+	if cfg.ClientTLS != nil {
+		params := make(map[string]interface{}, len(driverParams)+1)
+		for k, v := range driverParams {
+			params[k] = v
+		}
+		params[drivers.TLSConfigParameter] = cfg.ClientTLS
+		driverParams = params
+	}
 	cacheDriver, err := factory.Create(driverName, driverParams)
 	if err != nil {
-		return nil, fmt.Errorf("failed to create the cache driver (%s): %v", driverName, err)
+		// the drivers may print their parameters in the errors
+		return nil, fmt.Errorf("failed to create the cache driver (%s): %v", driverName, utils.RedactError(err))
+	}
+	if cfg.CacheCompression.Enabled {
+		_, level := zstd.EncoderLevelFromString(cfg.CacheCompression.Level)
+		cacheDriver = zstdcache.New(cacheDriver, level)
 	}
-	if config.CacheOnly {
-		defaultDriver = cacheDriver
-		return defaultDriver, nil
+	if cfg.CacheOnly {
+		setDriver(cfg, cacheDriver)
+		return cacheDriver, nil
 	}
-	defaultDriver, err = multidriver.New(config.RedirectTo, ipfsDriver, cacheDriver), nil
-	return defaultDriver, err
+	multiDriver := multidriver.New(cfg.RedirectTo, ipfsDriver, cacheDriver)
+	multiDriver.(multidriver.MultiDriver).SetReplicationTimeout(cfg.Timeouts.Replication)
+	cfg.OnReload(func() {
+		multiDriver.(multidriver.MultiDriver).SetRedirectTo(cfg.RedirectTo)
+	})
+	setDriver(cfg, multiDriver)
+	return multiDriver, nil
+}
+
+// setDriver sets the default driver and the driver of the config.
+func setDriver(cfg *config.Config, driver storagedriver.StorageDriver) {
+	defaultDriver = driver
+	configDrivers.Store(cfg, driver)
 }
 
 // New creates a new IPFS-only driver.
@@ -84,6 +141,15 @@ func Get() storagedriver.StorageDriver {
 	return defaultDriver
 }
 
+// GetFor returns the driver which was created with the config, or the default driver if
+// there is none. Each registry in the process has its own config.
+func GetFor(cfg *config.Config) storagedriver.StorageDriver {
+	if driver, ok := configDrivers.Load(cfg); ok {
+		return driver.(storagedriver.StorageDriver)
+	}
+	return defaultDriver
+}
+
 // Create create creates a new driver instance from parameters.
 func Create(parameters map[string]interface{}) (storagedriver.StorageDriver, error) {
 	return defaultFactory.Create(parameters)
@@ -92,17 +158,38 @@ func Create(parameters map[string]interface{}) (storagedriver.StorageDriver, err
 // Driver is the exposed IPFS driver implementation.
 type Driver struct {
 	base.Base
+	api interfaces.IPFSClient
+}
+
+// nodeRouter is implemented by the IPFS clients which route the paths to multiple nodes.
+type nodeRouter interface {
+	SameNode(ctx context.Context, src string, dest string) (bool, error)
+}
+
+// SameNode tells if the source and the destination paths are on the same IPFS node, so that the
+// content can be moved without copying it. The clients of a single node are always on it.
+func (d *Driver) SameNode(ctx context.Context, sourcePath string, destPath string) (bool, error) {
+	router, ok := d.api.(nodeRouter)
+	if !ok {
+		return true, nil
+	}
+	return router.SameNode(ctx, drivers.FixUploadPath(sourcePath), drivers.FixUploadPath(destPath))
 }
 
 // fromParameters constructs a new driver using given parameters.
-func fromParameters(parameters map[string]interface{}) (*Driver, error) {
-	api := deps.Get()
+func fromParameters(parameters map[string]interface{}, chunkSize int) (*Driver, error) {
+	api, ok := parameters[paramIPFSClient].(interfaces.IPFSClient)
+	if !ok {
+		return nil, fmt.Errorf("missing ipfs client dependency")
+	}
 	return &Driver{
 		Base: base.Base{
 			StorageDriver: &driver{
-				api: api,
+				api:       api,
+				chunkSize: chunkSize,
 			},
 		},
+		api: api,
 	}, nil
 }
 
@@ -133,6 +220,23 @@ func (d *driver) PutContent(ctx context.Context, path string, contents []byte) e
 // given byte offset.
 func (d *driver) Reader(ctx context.Context, path string, offset int64) (io.ReadCloser, error) {
 	path = drivers.FixUploadPath(path)
+	if offset < 0 {
+		return nil, storagedriver.InvalidOffsetError{Path: path, Offset: offset, DriverName: driverName}
+	}
+	// the offset at or past the end is read as empty like in the other drivers
+	if offset > 0 {
+		stat, err := d.api.FilesStat(ctx, path, ipfsapi.FilesStat.Size(true))
+		if err != nil && isNotFoundErr(err) {
+			return nil, storagedriver.PathNotFoundError{Path: path, DriverName: driverName}
+		}
+		if err != nil {
+			return nil, err
+		}
+		if offset >= int64(stat.Size) {
+			return io.NopCloser(bytes.NewReader(nil)), nil
+		}
+	}
+	// IPFS reads from the offset so the ranges of the blobs are not streamed from the start
 	reader, err := d.api.FilesRead(ctx, path, ipfsapi.FilesRead.Offset(offset))
 	if err != nil && isNotFoundErr(err) {
 		return nil, storagedriver.PathNotFoundError{Path: path, DriverName: driverName}
@@ -149,6 +253,8 @@ func (d *driver) Writer(ctx context.Context, path string, shouldAppend bool) (st
 	path = drivers.FixUploadPath(path)
 	fileOpts := []ipfsapi.FilesOpt{ipfsapi.FilesWrite.Create(true), ipfsapi.FilesWrite.Parents(true)}
 	var offset int64
+	// the partial file is removed after cancel unless the writer appends to existing content
+	var removeFunc filewriter.RemoveFunc = d.removePartialFile
 	if shouldAppend {
 		stat, err := d.api.FilesStat(ctx, path, ipfsapi.FilesStat.Size(true))
 		if err != nil && isNotFoundErr(err) {
@@ -159,8 +265,9 @@ func (d *driver) Writer(ctx context.Context, path string, shouldAppend bool) (st
 		}
 		offset = int64(stat.Size)
 		fileOpts = append(fileOpts, ipfsapi.FilesWrite.Offset(offset))
+		removeFunc = nil
 	}
-	return filewriter.NewFileWriter(ctx, d.Name(), d.writeFunc(path, fileOpts), path, offset), nil
+	return filewriter.NewFileWriter(ctx, d.Name(), d.writeFunc(path, fileOpts), removeFunc, path, offset, d.chunkSize), nil
 }
 
 func (d *driver) writeFunc(path string, opts []ipfsapi.FilesOpt) filewriter.WriteFunc {
@@ -169,6 +276,14 @@ func (d *driver) writeFunc(path string, opts []ipfsapi.FilesOpt) filewriter.Writ
 	}
 }
 
+func (d *driver) removePartialFile(ctx context.Context, path string) error {
+	err := d.api.FilesRm(ctx, path, true)
+	if err != nil && isNotFoundErr(err) {
+		return nil
+	}
+	return err
+}
+
 func isNotFoundErr(err error) bool {
 	e, ok := err.(*ipfsapi.Error)
 	if !ok {
@@ -50,6 +50,32 @@ func (s *DriverTestSuite) TestReader() {
 	s.r.Equal("1", string(b))
 }
 
+func (s *DriverTestSuite) TestReader_Offset() {
+	s.ipfsClient.EXPECT().FilesStat(gomock.Any(), testPath, gomock.Any()).
+		Return(&ipfsapi.FilesStatObject{Size: 5}, nil).Times(3)
+	s.ipfsClient.EXPECT().FilesRead(gomock.Any(), testPath, gomock.Any()).
+		Return(io.NopCloser(bytes.NewBufferString("5")), nil)
+
+	// the offset in the file is read from the node
+	reader, err := s.driver.Reader(context.Background(), testPath, 4)
+	s.r.NoError(err)
+	b, err := io.ReadAll(reader)
+	s.r.NoError(err)
+	s.r.Equal("5", string(b))
+
+	// the offsets at and past the end are read as empty
+	for _, offset := range []int64{5, 6} {
+		reader, err := s.driver.Reader(context.Background(), testPath, offset)
+		s.r.NoError(err)
+		b, err := io.ReadAll(reader)
+		s.r.NoError(err)
+		s.r.Empty(b)
+	}
+
+	_, err = s.driver.Reader(context.Background(), testPath, -1)
+	s.r.IsType(storagedriver.InvalidOffsetError{}, err)
+}
+
 func (s *DriverTestSuite) TestGetContent() {
 	s.ipfsClient.EXPECT().FilesRead(gomock.Any(), testPath, gomock.Any()).
 		Return(io.NopCloser(bytes.NewBufferString("1")), nil)
@@ -90,6 +116,21 @@ func (s *DriverTestSuite) TestWriter() {
 	s.r.Equal(1, n)
 }
 
+func (s *DriverTestSuite) TestWriterCancel() {
+	s.ipfsClient.EXPECT().FilesWrite(gomock.Any(), testPath, gomock.Any(), gomock.Any(), gomock.Any()).
+		DoAndReturn(func(ctx context.Context, path string, r io.Reader, opts ...ipfsapi.FilesOpt) error {
+			_, err := io.Copy(io.Discard, r)
+			return err
+		})
+	s.ipfsClient.EXPECT().FilesRm(gomock.Any(), testPath, true).Return(nil)
+
+	writer, err := s.driver.Writer(context.Background(), testPath, false)
+	s.r.NoError(err)
+	_, err = writer.Write([]byte("1"))
+	s.r.NoError(err)
+	s.r.NoError(writer.Cancel())
+}
+
 func (s *DriverTestSuite) TestPutContent() {
 	s.ipfsClient.EXPECT().FilesWrite(gomock.Any(), testPath, gomock.Any(), gomock.Any(), gomock.Any()).
 		Return(nil)
@@ -0,0 +1,193 @@
+package metacache
+
+import (
+	"context"
+	"encoding/json"
+	"strconv"
+	"strings"
+	"time"
+
+	storagedriver "github.com/distribution/distribution/v3/registry/storage/driver"
+	"github.com/forta-network/disco/utils"
+)
+
+// redisTimeout limits the cache requests so that a slow Redis does not slow down the driver
+// more than reading from the wrapped driver.
+const redisTimeout = time.Second
+
+// Key prefixes of the cache entries.
+const (
+	keyContent = "content"
+	keyStat    = "stat"
+)
+
+// Client sends the commands to Redis, e.g. utils.RedisClient.
+type Client interface {
+	Do(ctx context.Context, args ...string) (interface{}, error)
+}
+
+// driver caches the content-addressed metadata of the wrapped driver in Redis, so that the
+// Disco replicas share it and do not read it from the IPFS nodes on every request. The Disco
+// services write to the IPFS nodes without the driver, so only the metadata which cannot
+// change under a path is cached: the links under the digest paths and the stat results of
+// the blobs. The writes through the driver still invalidate it.
+type driver struct {
+	storagedriver.StorageDriver
+	client Client
+	prefix string
+	ttl    time.Duration
+}
+
+// New wraps the driver with the metadata cache. The keys are prefixed with the prefix and
+// expire after the TTL.
+func New(wrapped storagedriver.StorageDriver, client Client, prefix string, ttl time.Duration) storagedriver.StorageDriver {
+	return &driver{StorageDriver: wrapped, client: client, prefix: prefix, ttl: ttl}
+}
+
+// cacheFileInfo is the cached stat result.
+type cacheFileInfo struct {
+	Size    int64     `json:"size"`
+	ModTime time.Time `json:"modTime"`
+	IsDir   bool      `json:"isDir"`
+}
+
+// isDigestPath tells if the content under the path is addressed by a digest.
+func isDigestPath(path string) bool {
+	return strings.Contains(path, "/sha256/") && !strings.Contains(path, "/_uploads/")
+}
+
+// isCachedContent tells if the content of the path is cached.
+func isCachedContent(path string) bool {
+	return isDigestPath(path) && strings.HasSuffix(path, "/link")
+}
+
+// isCachedStat tells if the stat result of the path is cached.
+func isCachedStat(path string) bool {
+	return isCachedContent(path) || (isDigestPath(path) && strings.Contains(path, "/blobs/"))
+}
+
+func (d *driver) key(kind, path string) string {
+	return d.prefix + kind + ":" + path
+}
+
+func (d *driver) get(ctx context.Context, key string) (string, bool) {
+	ctx, cancel := context.WithTimeout(ctx, redisTimeout)
+	defer cancel()
+	reply, err := d.client.Do(ctx, "GET", key)
+	if err != nil {
+		utils.Logger(ctx).WithError(err).WithField("key", key).Warn("failed to read from the metadata cache")
+		return "", false
+	}
+	value, ok := reply.(string)
+	return value, ok
+}
+
+func (d *driver) set(ctx context.Context, key, value string) {
+	ctx, cancel := context.WithTimeout(ctx, redisTimeout)
+	defer cancel()
+	if _, err := d.client.Do(ctx, "SET", key, value, "PX", strconv.FormatInt(d.ttl.Milliseconds(), 10)); err != nil {
+		utils.Logger(ctx).WithError(err).WithField("key", key).Warn("failed to write to the metadata cache")
+	}
+}
+
+// invalidate deletes the cache entries of the path. The entries under a deleted directory
+// expire after the TTL.
+func (d *driver) invalidate(ctx context.Context, path string) {
+	if !isCachedStat(path) {
+		return
+	}
+	ctx, cancel := context.WithTimeout(ctx, redisTimeout)
+	defer cancel()
+	if _, err := d.client.Do(ctx, "DEL", d.key(keyContent, path), d.key(keyStat, path)); err != nil {
+		utils.Logger(ctx).WithError(err).WithField("path", path).Error("failed to invalidate the metadata cache")
+	}
+}
+
+// GetContent retrieves the content stored at "path" as a []byte.
+func (d *driver) GetContent(ctx context.Context, path string) ([]byte, error) {
+	if !isCachedContent(path) {
+		return d.StorageDriver.GetContent(ctx, path)
+	}
+	key := d.key(keyContent, path)
+	if value, ok := d.get(ctx, key); ok {
+		return []byte(value), nil
+	}
+	content, err := d.StorageDriver.GetContent(ctx, path)
+	if err != nil {
+		return nil, err
+	}
+	d.set(ctx, key, string(content))
+	return content, nil
+}
+
+// PutContent stores the []byte content at a location designated by "path".
+func (d *driver) PutContent(ctx context.Context, path string, content []byte) error {
+	err := d.StorageDriver.PutContent(ctx, path, content)
+	d.invalidate(ctx, path)
+	return err
+}
+
+// Writer returns a FileWriter which will store the content written to it at the location
+// designated by "path" after the call to Commit.
+func (d *driver) Writer(ctx context.Context, path string, append bool) (storagedriver.FileWriter, error) {
+	writer, err := d.StorageDriver.Writer(ctx, path, append)
+	if err != nil {
+		return nil, err
+	}
+	return &fileWriter{FileWriter: writer, driver: d, path: path}, nil
+}
+
+// Stat retrieves the FileInfo for the given path, including the current size in bytes and
+// the creation time.
+func (d *driver) Stat(ctx context.Context, path string) (storagedriver.FileInfo, error) {
+	if !isCachedStat(path) {
+		return d.StorageDriver.Stat(ctx, path)
+	}
+	key := d.key(keyStat, path)
+	if value, ok := d.get(ctx, key); ok {
+		var cached cacheFileInfo
+		if err := json.Unmarshal([]byte(value), &cached); err == nil {
+			return storagedriver.FileInfoInternal{FileInfoFields: storagedriver.FileInfoFields{
+				Path:    path,
+				Size:    cached.Size,
+				ModTime: cached.ModTime,
+				IsDir:   cached.IsDir,
+			}}, nil
+		}
+	}
+	info, err := d.StorageDriver.Stat(ctx, path)
+	if err != nil {
+		return nil, err
+	}
+	b, _ := json.Marshal(&cacheFileInfo{Size: info.Size(), ModTime: info.ModTime(), IsDir: info.IsDir()})
+	d.set(ctx, key, string(b))
+	return info, nil
+}
+
+// Move moves an object stored at sourcePath to destPath, removing the original object.
+func (d *driver) Move(ctx context.Context, sourcePath string, destPath string) error {
+	err := d.StorageDriver.Move(ctx, sourcePath, destPath)
+	d.invalidate(ctx, sourcePath)
+	d.invalidate(ctx, destPath)
+	return err
+}
+
+// Delete recursively deletes all objects stored at "path" and its subpaths.
+func (d *driver) Delete(ctx context.Context, path string) error {
+	err := d.StorageDriver.Delete(ctx, path)
+	d.invalidate(ctx, path)
+	return err
+}
+
+// fileWriter invalidates the cache entries of the path after the commit.
+type fileWriter struct {
+	storagedriver.FileWriter
+	driver *driver
+	path   string
+}
+
+func (fw *fileWriter) Commit() error {
+	err := fw.FileWriter.Commit()
+	fw.driver.invalidate(context.Background(), fw.path)
+	return err
+}
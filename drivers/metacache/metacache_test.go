@@ -0,0 +1,91 @@
+package metacache
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/distribution/distribution/v3/registry/storage/driver/inmemory"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeClient serves the commands which the cache uses.
+type fakeClient struct {
+	mu   sync.Mutex
+	keys map[string]string
+}
+
+func (client *fakeClient) Do(ctx context.Context, args ...string) (interface{}, error) {
+	client.mu.Lock()
+	defer client.mu.Unlock()
+	switch args[0] {
+	case "GET":
+		value, ok := client.keys[args[1]]
+		if !ok {
+			return nil, nil
+		}
+		return value, nil
+	case "SET":
+		client.keys[args[1]] = args[2]
+		return "OK", nil
+	default:
+		for _, key := range args[1:] {
+			delete(client.keys, key)
+		}
+		return int64(len(args) - 1), nil
+	}
+}
+
+func TestMetadataCache(t *testing.T) {
+	r := require.New(t)
+	ctx := context.Background()
+
+	const (
+		linkPath = "/docker/registry/v2/repositories/myrepo/_manifests/revisions/sha256/1234/link"
+		blobPath = "/docker/registry/v2/blobs/sha256/12/1234/data"
+		tagPath  = "/docker/registry/v2/repositories/myrepo/_manifests/tags/latest/current/link"
+	)
+	client := &fakeClient{keys: make(map[string]string)}
+	wrapped := inmemory.New()
+	d := New(wrapped, client, "disco/state/metadata/", time.Minute)
+	r.NoError(wrapped.PutContent(ctx, linkPath, []byte("sha256:1234")))
+	r.NoError(wrapped.PutContent(ctx, blobPath, []byte("blob")))
+	r.NoError(wrapped.PutContent(ctx, tagPath, []byte("sha256:1234")))
+
+	// When the metadata is read through the cache
+	content, err := d.GetContent(ctx, linkPath)
+	r.NoError(err)
+	r.Equal("sha256:1234", string(content))
+	_, err = d.Stat(ctx, blobPath)
+	r.NoError(err)
+	_, err = d.GetContent(ctx, tagPath)
+	r.NoError(err)
+
+	// Then only the content-addressed metadata should be cached
+	r.Len(client.keys, 2)
+	r.Equal("sha256:1234", client.keys["disco/state/metadata/content:"+linkPath])
+
+	// And it should be served from the cache afterwards
+	r.NoError(wrapped.Delete(ctx, "/docker/registry/v2"))
+	content, err = d.GetContent(ctx, linkPath)
+	r.NoError(err)
+	r.Equal("sha256:1234", string(content))
+	info, err := d.Stat(ctx, blobPath)
+	r.NoError(err)
+	r.Equal(int64(4), info.Size())
+	r.Equal(blobPath, info.Path())
+
+	// When the link is written through the cache
+	r.NoError(d.PutContent(ctx, linkPath, []byte("sha256:5678")))
+
+	// Then it should be invalidated
+	content, err = d.GetContent(ctx, linkPath)
+	r.NoError(err)
+	r.Equal("sha256:5678", string(content))
+
+	// And the deletes should invalidate it, too
+	r.NoError(d.Delete(ctx, linkPath))
+	_, err = d.GetContent(ctx, linkPath)
+	r.Error(err)
+}
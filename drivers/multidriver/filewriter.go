@@ -20,10 +20,12 @@ func newMultiFileWriter(primary storagedriver.FileWriter, secondary storagedrive
 func (fw *fileWriter) Write(p []byte) (int, error) {
 	n, errPri := fw.primary.Write(p)
 	if errPri != nil {
+		_ = fw.Cancel()
 		return n, errPri
 	}
 	n, errSec := fw.secondary.Write(p)
 	if errSec != nil {
+		_ = fw.Cancel()
 		return n, errSec
 	}
 	return n, nil
@@ -43,21 +45,25 @@ func (fw *fileWriter) Close() error {
 	return nil
 }
 
+// Cancel cancels both writers even if the primary fails to cancel, so that neither of the
+// stores is left with a partial file.
 func (fw *fileWriter) Cancel() error {
-	if err := fw.primary.Cancel(); err != nil {
-		return err
-	}
-	if err := fw.secondary.Cancel(); err != nil {
-		return err
+	errPri := fw.primary.Cancel()
+	errSec := fw.secondary.Cancel()
+	if errPri != nil {
+		return errPri
 	}
-	return nil
+	return errSec
 }
 
+// Commit cancels the writers which are not committed yet if a commit fails.
 func (fw *fileWriter) Commit() error {
 	if err := fw.primary.Commit(); err != nil {
+		_ = fw.Cancel()
 		return err
 	}
 	if err := fw.secondary.Commit(); err != nil {
+		_ = fw.Cancel()
 		return err
 	}
 	return nil
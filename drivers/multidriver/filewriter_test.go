@@ -1,6 +1,7 @@
 package multidriver
 
 import (
+	"errors"
 	"testing"
 
 	"github.com/forta-network/disco/drivers/filewriter"
@@ -24,3 +25,31 @@ func TestFileWriter(t *testing.T) {
 	r.NoError(fw.Close())
 	r.NoError(fw.Cancel())
 }
+
+type failingWriter struct {
+	filewriter.StubWriter
+	canceled bool
+}
+
+func (fw *failingWriter) Write(p []byte) (int, error) {
+	return 0, errors.New("failed to write")
+}
+
+func (fw *failingWriter) Cancel() error {
+	fw.canceled = true
+	return nil
+}
+
+func TestFileWriter_CancelOnFailure(t *testing.T) {
+	r := require.New(t)
+
+	priW := &failingWriter{}
+	secW := &failingWriter{}
+
+	fw := newMultiFileWriter(priW, secW)
+
+	_, err := fw.Write([]byte("1"))
+	r.Error(err)
+	r.True(priW.canceled)
+	r.True(secW.canceled)
+}
@@ -7,7 +7,9 @@ package mock_multidriver
 import (
 	context "context"
 	io "io"
+	url "net/url"
 	reflect "reflect"
+	time "time"
 
 	driver "github.com/distribution/distribution/v3/registry/storage/driver"
 	gomock "github.com/golang/mock/gomock"
@@ -108,6 +110,20 @@ func (mr *MockMultiDriverMockRecorder) Name() *gomock.Call {
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Name", reflect.TypeOf((*MockMultiDriver)(nil).Name))
 }
 
+// Primary mocks base method.
+func (m *MockMultiDriver) Primary() driver.StorageDriver {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Primary")
+	ret0, _ := ret[0].(driver.StorageDriver)
+	return ret0
+}
+
+// Primary indicates an expected call of Primary.
+func (mr *MockMultiDriverMockRecorder) Primary() *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Primary", reflect.TypeOf((*MockMultiDriver)(nil).Primary))
+}
+
 // PutContent mocks base method.
 func (m *MockMultiDriver) PutContent(ctx context.Context, path string, content []byte) error {
 	m.ctrl.T.Helper()
@@ -138,33 +154,71 @@ func (mr *MockMultiDriverMockRecorder) Reader(ctx, path, offset interface{}) *go
 }
 
 // ReplicateInPrimary mocks base method.
-func (m *MockMultiDriver) ReplicateInPrimary(contentPath string) (driver.FileInfo, error) {
+func (m *MockMultiDriver) ReplicateInPrimary(ctx context.Context, contentPath string) (driver.FileInfo, error) {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "ReplicateInPrimary", contentPath)
+	ret := m.ctrl.Call(m, "ReplicateInPrimary", ctx, contentPath)
 	ret0, _ := ret[0].(driver.FileInfo)
 	ret1, _ := ret[1].(error)
 	return ret0, ret1
 }
 
 // ReplicateInPrimary indicates an expected call of ReplicateInPrimary.
-func (mr *MockMultiDriverMockRecorder) ReplicateInPrimary(contentPath interface{}) *gomock.Call {
+func (mr *MockMultiDriverMockRecorder) ReplicateInPrimary(ctx, contentPath interface{}) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ReplicateInPrimary", reflect.TypeOf((*MockMultiDriver)(nil).ReplicateInPrimary), contentPath)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ReplicateInPrimary", reflect.TypeOf((*MockMultiDriver)(nil).ReplicateInPrimary), ctx, contentPath)
 }
 
 // ReplicateInSecondary mocks base method.
-func (m *MockMultiDriver) ReplicateInSecondary(contentPath string) (driver.FileInfo, error) {
+func (m *MockMultiDriver) ReplicateInSecondary(ctx context.Context, contentPath string) (driver.FileInfo, error) {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "ReplicateInSecondary", contentPath)
+	ret := m.ctrl.Call(m, "ReplicateInSecondary", ctx, contentPath)
 	ret0, _ := ret[0].(driver.FileInfo)
 	ret1, _ := ret[1].(error)
 	return ret0, ret1
 }
 
 // ReplicateInSecondary indicates an expected call of ReplicateInSecondary.
-func (mr *MockMultiDriverMockRecorder) ReplicateInSecondary(contentPath interface{}) *gomock.Call {
+func (mr *MockMultiDriverMockRecorder) ReplicateInSecondary(ctx, contentPath interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ReplicateInSecondary", reflect.TypeOf((*MockMultiDriver)(nil).ReplicateInSecondary), ctx, contentPath)
+}
+
+// Secondary mocks base method.
+func (m *MockMultiDriver) Secondary() driver.StorageDriver {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Secondary")
+	ret0, _ := ret[0].(driver.StorageDriver)
+	return ret0
+}
+
+// Secondary indicates an expected call of Secondary.
+func (mr *MockMultiDriverMockRecorder) Secondary() *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Secondary", reflect.TypeOf((*MockMultiDriver)(nil).Secondary))
+}
+
+// SetRedirectTo mocks base method.
+func (m *MockMultiDriver) SetRedirectTo(redirectTo *url.URL) {
+	m.ctrl.T.Helper()
+	m.ctrl.Call(m, "SetRedirectTo", redirectTo)
+}
+
+// SetRedirectTo indicates an expected call of SetRedirectTo.
+func (mr *MockMultiDriverMockRecorder) SetRedirectTo(redirectTo interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "SetRedirectTo", reflect.TypeOf((*MockMultiDriver)(nil).SetRedirectTo), redirectTo)
+}
+
+// SetReplicationTimeout mocks base method.
+func (m *MockMultiDriver) SetReplicationTimeout(timeout time.Duration) {
+	m.ctrl.T.Helper()
+	m.ctrl.Call(m, "SetReplicationTimeout", timeout)
+}
+
+// SetReplicationTimeout indicates an expected call of SetReplicationTimeout.
+func (mr *MockMultiDriverMockRecorder) SetReplicationTimeout(timeout interface{}) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ReplicateInSecondary", reflect.TypeOf((*MockMultiDriver)(nil).ReplicateInSecondary), contentPath)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "SetReplicationTimeout", reflect.TypeOf((*MockMultiDriver)(nil).SetReplicationTimeout), timeout)
 }
 
 // Stat mocks base method.
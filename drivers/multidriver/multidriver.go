@@ -5,19 +5,29 @@ import (
 	"fmt"
 	"io"
 	"net/url"
+	"regexp"
+	"strconv"
 	"strings"
+	"sync"
+	"time"
 
 	"path"
 
 	storagedriver "github.com/distribution/distribution/v3/registry/storage/driver"
 	"github.com/forta-network/disco/drivers/filewriter"
+	"github.com/forta-network/disco/utils"
 	log "github.com/sirupsen/logrus"
+	"go.opentelemetry.io/otel/trace"
 )
 
 // MultiDriver combines and deals with multiple drivers.
 type MultiDriver interface {
-	ReplicateInPrimary(contentPath string) (storagedriver.FileInfo, error)
-	ReplicateInSecondary(contentPath string) (storagedriver.FileInfo, error)
+	ReplicateInPrimary(ctx context.Context, contentPath string) (storagedriver.FileInfo, error)
+	ReplicateInSecondary(ctx context.Context, contentPath string) (storagedriver.FileInfo, error)
+	SetRedirectTo(redirectTo *url.URL)
+	SetReplicationTimeout(timeout time.Duration)
+	Primary() storagedriver.StorageDriver
+	Secondary() storagedriver.StorageDriver
 	storagedriver.StorageDriver
 }
 
@@ -25,9 +35,13 @@ type MultiDriver interface {
 // It writes to both destinations, fills primary if only found in secondary, prefers
 // reading from primary.
 type driver struct {
-	redirectTo *url.URL
-	primary    storagedriver.StorageDriver
-	secondary  storagedriver.StorageDriver
+	mu                 sync.RWMutex
+	redirectTo         *url.URL
+	replicationTimeout time.Duration
+	primary            storagedriver.StorageDriver
+	secondary          storagedriver.StorageDriver
+	// replicating has the paths which are replicated in the background
+	replicating sync.Map
 }
 
 // New creates a new multi-driver.
@@ -41,6 +55,44 @@ func Is(driver interface{}) (MultiDriver, bool) {
 	return d, ok
 }
 
+// SetRedirectTo sets the URL which the content URLs are built on.
+func (d *driver) SetRedirectTo(redirectTo *url.URL) {
+	d.mu.Lock()
+	d.redirectTo = redirectTo
+	d.mu.Unlock()
+}
+
+// SetReplicationTimeout sets the timeout of the replications. Zero disables the timeout.
+func (d *driver) SetReplicationTimeout(timeout time.Duration) {
+	d.mu.Lock()
+	d.replicationTimeout = timeout
+	d.mu.Unlock()
+}
+
+// replicationContext returns a context which is not cancelled by the callers, so that
+// the content is not replicated partially, but times out. The replications are traced in the
+// span of the caller.
+func (d *driver) replicationContext(parent context.Context) (context.Context, context.CancelFunc) {
+	d.mu.RLock()
+	timeout := d.replicationTimeout
+	d.mu.RUnlock()
+	ctx := trace.ContextWithSpan(context.Background(), trace.SpanFromContext(parent))
+	if timeout == 0 {
+		return context.WithCancel(ctx)
+	}
+	return context.WithTimeout(ctx, timeout)
+}
+
+// Primary returns the primary driver.
+func (d *driver) Primary() storagedriver.StorageDriver {
+	return d.primary
+}
+
+// Secondary returns the secondary driver.
+func (d *driver) Secondary() storagedriver.StorageDriver {
+	return d.secondary
+}
+
 // Name returns the name of the driver by implementing storagedriver.Storagedriver.
 func (d *driver) Name() string {
 	return fmt.Sprintf("%s+%s", d.primary.Name(), d.secondary.Name())
@@ -48,8 +100,9 @@ func (d *driver) Name() string {
 
 // ReplicateInPrimary ensures that a specific piece of content is replicated from the secondary
 // store to the primary.
-func (d *driver) ReplicateInPrimary(contentPath string) (storagedriver.FileInfo, error) {
-	ctx := context.Background() // should not be cancellable
+func (d *driver) ReplicateInPrimary(ctx context.Context, contentPath string) (storagedriver.FileInfo, error) {
+	ctx, cancel := d.replicationContext(ctx)
+	defer cancel()
 	_, err := Replicate(ctx, d.secondary, d.primary, contentPath, contentPath, false)
 	if err != nil {
 		return nil, err
@@ -60,8 +113,9 @@ func (d *driver) ReplicateInPrimary(contentPath string) (storagedriver.FileInfo,
 
 // ReplicateInSecondary ensures that a specific piece of content is replicated from the primary
 // store to the secondary.
-func (d *driver) ReplicateInSecondary(contentPath string) (storagedriver.FileInfo, error) {
-	ctx := context.Background() // should not be cancellable
+func (d *driver) ReplicateInSecondary(ctx context.Context, contentPath string) (storagedriver.FileInfo, error) {
+	ctx, cancel := d.replicationContext(ctx)
+	defer cancel()
 	_, err := Replicate(ctx, d.primary, d.secondary, contentPath, contentPath, false)
 	if err != nil {
 		return nil, err
@@ -112,42 +166,75 @@ func Replicate(ctx context.Context, d1, d2 storagedriver.StorageDriver, src, dst
 	})
 }
 
-func syncD1ToD2(ctx context.Context, d1, d2 storagedriver.StorageDriver, src, dst string) error {
+func syncD1ToD2(ctx context.Context, d1, d2 storagedriver.StorageDriver, src, dst string) (err error) {
+	name1, name2 := d1.Name(), d2.Name()
+	ctx, span := utils.StartSpan(ctx, "replicate", utils.SpanKindInternal,
+		"disco.src", src,
+		"disco.dst", dst,
+		"disco.driver1", name1,
+		"disco.driver2", name2,
+	)
+	defer func() {
+		span.End(err)
+	}()
+
 	d1r, err := d1.Reader(ctx, src, 0)
 	if err != nil {
 		return err
 	}
 	defer d1r.Close()
 
-	d2w, err := d2.Writer(ctx, dst, false)
+	fw, err := d2.Writer(ctx, dst, false)
 	if err != nil {
-		return fmt.Errorf("failed to create the '%s' writer: %v", d2.Name(), err)
+		return fmt.Errorf("failed to create the '%s' writer: %v", name2, err)
 	}
-	defer d2w.Close()
+	defer fw.Close()
+	// verify the blobs while copying
+	d2w := filewriter.WithDigest(fw)
 
 	n, err := io.Copy(d2w, d1r)
 	if err != nil {
-		return fmt.Errorf("failed to copy from '%s' to '%s': %v", d1.Name(), d2.Name(), err)
+		_ = d2w.Cancel()
+		return fmt.Errorf("failed to copy from '%s' to '%s': %v", name1, name2, err)
+	}
+	span.SetAttributes("disco.bytes", strconv.FormatInt(n, 10))
+	if digest, ok := blobDigest(dst); ok {
+		if err := d2w.Verify(digest); err != nil {
+			_ = d2w.Cancel()
+			return fmt.Errorf("failed to verify '%s' copied from '%s': %v", dst, name1, err)
+		}
 	}
 	if err := d2w.Commit(); err != nil {
 		_ = d2w.Cancel()
-		return fmt.Errorf("failed to commit '%s' writer: %v", d2.Name(), err)
+		return fmt.Errorf("failed to commit '%s' writer: %v", name2, err)
 	}
-	log.WithFields(log.Fields{
+	utils.Logger(ctx).WithFields(log.Fields{
 		"bytes":   n,
 		"src":     src,
 		"dst":     dst,
-		"driver1": d1.Name(),
-		"driver2": d2.Name(),
+		"driver1": name1,
+		"driver2": name2,
 	}).Debug("finished copying to the second driver")
 
 	return nil
 }
 
+// blobDataPathPattern matches the blob data paths and captures the digest.
+var blobDataPathPattern = regexp.MustCompile(`/blobs/sha256/[0-9a-f]{2}/([0-9a-f]{64})/data$`)
+
+// blobDigest returns the digest of the blob if the path is a blob data path.
+func blobDigest(path string) (string, bool) {
+	match := blobDataPathPattern.FindStringSubmatch(path)
+	if match == nil {
+		return "", false
+	}
+	return match[1], true
+}
+
 // GetContent retrieves the content stored at "path" as a []byte.
 // This should primarily be used for small objects.
 func (d *driver) GetContent(ctx context.Context, path string) ([]byte, error) {
-	if _, err := d.ReplicateInSecondary(path); err != nil {
+	if _, err := d.ReplicateInSecondary(ctx, path); err != nil {
 		return nil, err
 	}
 	return d.secondary.GetContent(ctx, path)
@@ -168,13 +255,45 @@ func (d *driver) PutContent(ctx context.Context, path string, content []byte) er
 // Reader retrieves an io.ReadCloser for the content stored at "path"
 // with a given byte offset.
 // May be used to resume reading a stream by providing a nonzero offset.
+// The content which is only in the primary is read from the primary at a nonzero offset and
+// replicated in the background, so that the resumed pulls do not wait for the whole content.
 func (d *driver) Reader(ctx context.Context, path string, offset int64) (io.ReadCloser, error) {
-	if _, err := d.ReplicateInSecondary(path); err != nil {
+	if offset > 0 {
+		_, err := d.secondary.Stat(ctx, path)
+		switch err.(type) {
+		case nil:
+			return d.secondary.Reader(ctx, path, offset)
+		case storagedriver.PathNotFoundError:
+			reader, err := d.primary.Reader(ctx, path, offset)
+			if err != nil {
+				return nil, err
+			}
+			d.replicateInBackground(ctx, path)
+			return reader, nil
+		default:
+			return nil, fmt.Errorf("failed to check in '%s' before reading: %v", d.secondary.Name(), err)
+		}
+	}
+	if _, err := d.ReplicateInSecondary(ctx, path); err != nil {
 		return nil, err
 	}
 	return d.secondary.Reader(ctx, path, offset)
 }
 
+// replicateInBackground replicates the content in the secondary unless it is being replicated
+// in the background already.
+func (d *driver) replicateInBackground(ctx context.Context, contentPath string) {
+	if _, replicating := d.replicating.LoadOrStore(contentPath, true); replicating {
+		return
+	}
+	go func() {
+		defer d.replicating.Delete(contentPath)
+		if _, err := d.ReplicateInSecondary(ctx, contentPath); err != nil {
+			log.WithError(err).WithField("path", contentPath).Warn("failed to replicate in secondary in the background")
+		}
+	}()
+}
+
 // Writer returns a FileWriter which will store the content written to it
 // at the location designated by "path" after the call to Commit.
 func (d *driver) Writer(ctx context.Context, path string, append bool) (storagedriver.FileWriter, error) {
@@ -187,15 +306,15 @@ func (d *driver) Writer(ctx context.Context, path string, append bool) (storaged
 		return nil, fmt.Errorf("Writer() secondary: %v", err)
 	}
 	return newMultiFileWriter(
-		filewriter.WithLogger(d.primary.Name(), path, priWriter),
-		filewriter.WithLogger(d.secondary.Name(), path, secWriter),
+		filewriter.WithMetrics(d.primary.Name(), path, priWriter),
+		filewriter.WithMetrics(d.secondary.Name(), path, secWriter),
 	), nil
 }
 
 // Stat retrieves the FileInfo for the given path, including the current
 // size in bytes and the creation time.
 func (d *driver) Stat(ctx context.Context, path string) (storagedriver.FileInfo, error) {
-	secStat, err := d.ReplicateInSecondary(path)
+	secStat, err := d.ReplicateInSecondary(ctx, path)
 	if err != nil {
 		return nil, err
 	}
@@ -209,21 +328,155 @@ func (d *driver) Stat(ctx context.Context, path string) (storagedriver.FileInfo,
 // List returns a list of the objects that are direct descendants of the
 // given path.
 func (d *driver) List(ctx context.Context, path string) ([]string, error) {
-	if _, err := d.ReplicateInSecondary(path); err != nil {
+	if _, err := d.ReplicateInSecondary(ctx, path); err != nil {
 		return nil, err
 	}
 	return d.secondary.List(ctx, path)
 }
 
+// nodeMover is implemented by the drivers which spread the content over multiple nodes, e.g. the
+// IPFS driver with the router. The content can be moved natively only on the same node.
+type nodeMover interface {
+	SameNode(ctx context.Context, sourcePath string, destPath string) (bool, error)
+}
+
+// movesNatively tells if the driver can move the content without copying it between its nodes.
+// The drivers of a single store always can.
+func movesNatively(ctx context.Context, d storagedriver.StorageDriver, sourcePath, destPath string) (bool, error) {
+	mover, ok := d.(nodeMover)
+	if !ok {
+		return true, nil
+	}
+	return mover.SameNode(ctx, sourcePath, destPath)
+}
+
+// moveStore is a store of the multi-driver which an object is moved in.
+type moveStore struct {
+	name   string
+	driver storagedriver.StorageDriver
+}
+
 // Move moves an object stored at sourcePath to destPath, removing the
-// original object.
+// original object. The object is moved natively in the stores which keep both
+// paths on the same node. In the others, it is copied and verified before the
+// source is deleted. The copies are made before the native moves, and they are
+// deleted and the moves are undone if any of them fails.
 func (d *driver) Move(ctx context.Context, sourcePath string, destPath string) error {
 	// do not replicate - we don't expect `Move()`s before any writes, which already ensure replication
-	if err := d.primary.Move(ctx, sourcePath, destPath); err != nil {
-		return fmt.Errorf("Move() primary: %v", err)
+	stores := []moveStore{
+		{name: "primary", driver: d.primary},
+		{name: "secondary", driver: d.secondary},
+	}
+	// copy to the destination in the stores which cannot move natively first so that a failure
+	// in one of them does not leave the source moved in the other
+	var (
+		created []storagedriver.StorageDriver
+		copied  []moveStore
+		native  []moveStore
+	)
+	for _, store := range stores {
+		ok, err := movesNatively(ctx, store.driver, sourcePath, destPath)
+		if err != nil {
+			d.undoMoveCopies(ctx, created, destPath)
+			return fmt.Errorf("Move() %s: failed to route the paths: %v", store.name, err)
+		}
+		if ok {
+			native = append(native, store)
+			continue
+		}
+		existed, err := pathExists(ctx, store.driver, destPath)
+		if err != nil {
+			d.undoMoveCopies(ctx, created, destPath)
+			return fmt.Errorf("Move() %s: %v", store.name, err)
+		}
+		if !existed {
+			created = append(created, store.driver)
+		}
+		if err := copyAndVerify(ctx, store.driver, sourcePath, destPath); err != nil {
+			d.undoMoveCopies(ctx, created, destPath)
+			return fmt.Errorf("Move() %s: %v", store.name, err)
+		}
+		copied = append(copied, store)
+	}
+	var moved []storagedriver.StorageDriver
+	for _, store := range native {
+		if err := store.driver.Move(ctx, sourcePath, destPath); err != nil {
+			d.undoMoves(ctx, moved, sourcePath, destPath)
+			d.undoMoveCopies(ctx, created, destPath)
+			return fmt.Errorf("Move() %s: %v", store.name, err)
+		}
+		moved = append(moved, store.driver)
+	}
+	// the destination is complete in all of the stores now and the copied sources are only
+	// garbage, so failing to delete them should not fail the move
+	for _, store := range copied {
+		if err := store.driver.Delete(ctx, sourcePath); err != nil {
+			utils.Logger(ctx).WithError(err).WithFields(log.Fields{
+				"driver": store.name,
+				"src":    sourcePath,
+				"dst":    destPath,
+			}).Warn("failed to delete the source after moving")
+		}
+	}
+	return nil
+}
+
+// undoMoves moves the objects back to the source after a failed move.
+func (d *driver) undoMoves(ctx context.Context, moved []storagedriver.StorageDriver, sourcePath, destPath string) {
+	for _, store := range moved {
+		if err := store.Move(ctx, destPath, sourcePath); err != nil {
+			utils.Logger(ctx).WithError(err).WithFields(log.Fields{
+				"driver": store.Name(),
+				"src":    sourcePath,
+				"dst":    destPath,
+			}).Error("failed to move back the object of a failed move - the stores may have diverged")
+		}
+	}
+}
+
+// undoMoveCopies deletes the copies which were created by a failed move.
+func (d *driver) undoMoveCopies(ctx context.Context, created []storagedriver.StorageDriver, destPath string) {
+	for _, store := range created {
+		err := store.Delete(ctx, destPath)
+		if _, ok := err.(storagedriver.PathNotFoundError); err == nil || ok {
+			continue
+		}
+		utils.Logger(ctx).WithError(err).WithFields(log.Fields{
+			"driver": store.Name(),
+			"dst":    destPath,
+		}).Error("failed to delete the copy of a failed move - the stores may have diverged")
+	}
+}
+
+// pathExists tells if there is a file or a directory at the path.
+func pathExists(ctx context.Context, d storagedriver.StorageDriver, path string) (bool, error) {
+	_, err := d.Stat(ctx, path)
+	switch err.(type) {
+	case nil:
+		return true, nil
+	case storagedriver.PathNotFoundError:
+		return false, nil
+	default:
+		return false, fmt.Errorf("failed to check the destination: %v", err)
+	}
+}
+
+// copyAndVerify copies the source to the destination in the same driver and checks that the
+// copy has the same size. The blobs are also verified by their digests while copying.
+func copyAndVerify(ctx context.Context, d storagedriver.StorageDriver, src, dst string) error {
+	srcInfo, err := d.Stat(ctx, src)
+	if err != nil {
+		return err
+	}
+	if _, err := Replicate(ctx, d, d, src, dst, true); err != nil {
+		return err
+	}
+	dstInfo, err := d.Stat(ctx, dst)
+	if err != nil {
+		return fmt.Errorf("failed to verify the copy: %v", err)
 	}
-	if err := d.secondary.Move(ctx, sourcePath, destPath); err != nil {
-		return fmt.Errorf("Move() secondary: %v", err)
+	if !srcInfo.IsDir() && dstInfo.Size() != srcInfo.Size() {
+		return fmt.Errorf("copy has %d bytes instead of %d", dstInfo.Size(), srcInfo.Size())
 	}
 	return nil
 }
@@ -245,7 +498,10 @@ func (d *driver) Delete(ctx context.Context, path string) error {
 // May return an ErrUnsupportedMethod in certain StorageDriver
 // implementations.
 func (d *driver) URLFor(ctx context.Context, contentPath string, options map[string]interface{}) (string, error) {
-	if d.redirectTo == nil {
+	d.mu.RLock()
+	redirectTo := d.redirectTo
+	d.mu.RUnlock()
+	if redirectTo == nil {
 		return "", storagedriver.ErrUnsupportedMethod{}
 	}
 
@@ -257,9 +513,9 @@ func (d *driver) URLFor(ctx context.Context, contentPath string, options map[str
 		}
 	}
 
-	redirectURL := *d.redirectTo
+	redirectURL := *redirectTo
 	redirectURL.Path = path.Join(redirectURL.Path, contentPath)
-	log.WithField("redirectUrl", redirectURL.String()).Info("created redirect url")
+	utils.Logger(ctx).WithField("redirectUrl", redirectURL.String()).Info("created redirect url")
 	return redirectURL.String(), nil
 }
 
@@ -3,12 +3,14 @@ package multidriver
 import (
 	"bytes"
 	"context"
+	"errors"
 	"io"
 	"net/url"
 	"testing"
 	"time"
 
 	storagedriver "github.com/distribution/distribution/v3/registry/storage/driver"
+	"github.com/distribution/distribution/v3/registry/storage/driver/inmemory"
 	"github.com/forta-network/disco/drivers/filewriter"
 	mock_interfaces "github.com/forta-network/disco/interfaces/mocks"
 	"github.com/golang/mock/gomock"
@@ -137,13 +139,6 @@ func (s *DriverTestSuite) TestList() {
 	s.r.Empty(list)
 }
 
-func (s *DriverTestSuite) TestMove() {
-	s.primary.EXPECT().Move(gomock.Any(), testPath, testPath+"1").Return(nil)
-	s.secondary.EXPECT().Move(gomock.Any(), testPath, testPath+"1").Return(nil)
-
-	s.r.NoError(s.driver.Move(context.Background(), testPath, testPath+"1"))
-}
-
 func (s *DriverTestSuite) TestDelete() {
 	s.primary.EXPECT().Delete(gomock.Any(), testPath).Return(nil)
 	s.secondary.EXPECT().Delete(gomock.Any(), testPath).Return(nil)
@@ -173,7 +168,7 @@ func (s *DriverTestSuite) TestReplicateInPrimary() {
 		size: 1,
 	}, nil).Times(2)
 
-	info, err := s.driver.ReplicateInPrimary(testPath)
+	info, err := s.driver.ReplicateInPrimary(context.Background(), testPath)
 	s.r.NoError(err)
 	s.r.NotNil(info)
 }
@@ -183,7 +178,7 @@ func (s *DriverTestSuite) TestReplicateInSecondary() {
 		size: 1,
 	}, nil).Times(2)
 
-	info, err := s.driver.ReplicateInSecondary(testPath)
+	info, err := s.driver.ReplicateInSecondary(context.Background(), testPath)
 	s.r.NoError(err)
 	s.r.NotNil(info)
 }
@@ -225,3 +220,153 @@ func (s *DriverTestSuite) TestIs() {
 	s.r.True(ok)
 	s.r.NotNil(md)
 }
+
+// failingWriterDriver fails the writes.
+type failingWriterDriver struct {
+	storagedriver.StorageDriver
+}
+
+func (d *failingWriterDriver) Writer(ctx context.Context, path string, append bool) (storagedriver.FileWriter, error) {
+	return nil, errors.New("write failed")
+}
+
+func TestReader_Offset(t *testing.T) {
+	r := require.New(t)
+	ctx := context.Background()
+
+	// Given content which is only in the primary
+	primary := inmemory.New()
+	secondary := inmemory.New()
+	r.NoError(primary.PutContent(ctx, testPath, []byte("0123456789")))
+	d := New(nil, primary, secondary)
+
+	// When it is read from an offset
+	reader, err := d.Reader(ctx, testPath, 4)
+	r.NoError(err)
+	b, err := io.ReadAll(reader)
+	r.NoError(err)
+	reader.Close()
+
+	// Then only the rest should be read from the primary
+	r.Equal("456789", string(b))
+
+	// And the content should be replicated in the secondary in the background
+	r.Eventually(func() bool {
+		content, err := secondary.GetContent(ctx, testPath)
+		return err == nil && string(content) == "0123456789"
+	}, time.Second, 10*time.Millisecond)
+
+	// And the next ranges should be read from the secondary
+	r.NoError(primary.Delete(ctx, testPath))
+	reader, err = d.Reader(ctx, testPath, 8)
+	r.NoError(err)
+	b, err = io.ReadAll(reader)
+	r.NoError(err)
+	reader.Close()
+	r.Equal("89", string(b))
+}
+
+// nodeDriver has the paths on the same node or on different nodes and counts the native moves.
+type nodeDriver struct {
+	storagedriver.StorageDriver
+	sameNode bool
+	moves    int
+}
+
+func (d *nodeDriver) SameNode(ctx context.Context, sourcePath string, destPath string) (bool, error) {
+	return d.sameNode, nil
+}
+
+func (d *nodeDriver) Move(ctx context.Context, sourcePath string, destPath string) error {
+	d.moves++
+	return d.StorageDriver.Move(ctx, sourcePath, destPath)
+}
+
+// failingMoveDriver fails the native moves.
+type failingMoveDriver struct {
+	storagedriver.StorageDriver
+}
+
+func (d *failingMoveDriver) Move(ctx context.Context, sourcePath string, destPath string) error {
+	return errors.New("move failed")
+}
+
+func TestMove(t *testing.T) {
+	r := require.New(t)
+	ctx := context.Background()
+
+	for _, sameNode := range []bool{true, false} {
+		primary := &nodeDriver{StorageDriver: inmemory.New(), sameNode: sameNode}
+		secondary := &nodeDriver{StorageDriver: inmemory.New(), sameNode: true}
+		r.NoError(primary.PutContent(ctx, testPath, []byte("1")))
+		r.NoError(secondary.PutContent(ctx, testPath, []byte("1")))
+		d := New(nil, primary, secondary)
+
+		// When the object is moved
+		r.NoError(d.Move(ctx, testPath, testPath+"1"))
+
+		// Then it should be only at the destination in both drivers
+		for _, store := range []storagedriver.StorageDriver{primary, secondary} {
+			content, err := store.GetContent(ctx, testPath+"1")
+			r.NoError(err)
+			r.Equal("1", string(content))
+			_, err = store.Stat(ctx, testPath)
+			r.IsType(storagedriver.PathNotFoundError{}, err)
+		}
+
+		// And it should be moved natively only on the same node
+		if sameNode {
+			r.Equal(1, primary.moves)
+		} else {
+			r.Zero(primary.moves)
+		}
+		r.Equal(1, secondary.moves)
+	}
+}
+
+func TestMove_Compensation(t *testing.T) {
+	r := require.New(t)
+	ctx := context.Background()
+
+	type testCase struct {
+		name      string
+		primary   storagedriver.StorageDriver
+		secondary storagedriver.StorageDriver
+	}
+	for _, tc := range []testCase{
+		{
+			// the copy in the primary is deleted
+			name:      "native move fails after the copy",
+			primary:   &nodeDriver{StorageDriver: inmemory.New()},
+			secondary: &failingMoveDriver{StorageDriver: inmemory.New()},
+		},
+		{
+			// the native move in the primary is not made
+			name:      "copy fails before the native move",
+			primary:   &nodeDriver{StorageDriver: inmemory.New(), sameNode: true},
+			secondary: &nodeDriver{StorageDriver: &failingWriterDriver{StorageDriver: inmemory.New()}},
+		},
+		{
+			// the native move in the primary is moved back
+			name:      "native move fails after the native move",
+			primary:   &nodeDriver{StorageDriver: inmemory.New(), sameNode: true},
+			secondary: &failingMoveDriver{StorageDriver: inmemory.New()},
+		},
+	} {
+		r.NoError(tc.primary.PutContent(ctx, testPath, []byte("1")))
+		r.NoError(tc.secondary.PutContent(ctx, testPath, []byte("1")))
+		d := New(nil, tc.primary, tc.secondary)
+
+		// When the move fails in the secondary driver
+		r.Error(d.Move(ctx, testPath, testPath+"1"), tc.name)
+
+		// Then the source should stay and the destination should not be left in the primary
+		for _, store := range []storagedriver.StorageDriver{tc.primary, tc.secondary} {
+			content, err := store.GetContent(ctx, testPath)
+			r.NoError(err, tc.name)
+			r.Equal("1", string(content), tc.name)
+			_, err = store.Stat(ctx, testPath+"1")
+			r.IsType(storagedriver.PathNotFoundError{}, err, tc.name)
+		}
+	}
+}
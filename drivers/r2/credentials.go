@@ -0,0 +1,38 @@
+package r2
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/forta-network/disco/secrets"
+)
+
+// credentialsRefreshInterval is how long the credentials from the secret provider are used
+// before fetching them again.
+const credentialsRefreshInterval = time.Minute * 5
+
+// secretCredentialsProvider fetches the access key and the secret key from a secret provider.
+type secretCredentialsProvider struct {
+	ref string
+}
+
+// Retrieve implements aws.CredentialsProvider.
+func (scp *secretCredentialsProvider) Retrieve(ctx context.Context) (aws.Credentials, error) {
+	accessKey, err := secrets.Resolve(ctx, scp.ref+"#accesskey")
+	if err != nil {
+		return aws.Credentials{}, fmt.Errorf("failed to get r2 access key: %v", err)
+	}
+	secretKey, err := secrets.Resolve(ctx, scp.ref+"#secretkey")
+	if err != nil {
+		return aws.Credentials{}, fmt.Errorf("failed to get r2 secret key: %v", err)
+	}
+	return aws.Credentials{
+		AccessKeyID:     accessKey,
+		SecretAccessKey: secretKey,
+		Source:          "disco-secrets",
+		CanExpire:       true,
+		Expires:         time.Now().Add(credentialsRefreshInterval),
+	}, nil
+}
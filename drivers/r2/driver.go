@@ -7,6 +7,7 @@ package r2
 import (
 	"bytes"
 	"context"
+	"crypto/tls"
 	"errors"
 	"fmt"
 	"io"
@@ -22,12 +23,15 @@ import (
 
 	"github.com/aws/aws-sdk-go-v2/aws"
 	v4 "github.com/aws/aws-sdk-go-v2/aws/signer/v4"
+	awshttp "github.com/aws/aws-sdk-go-v2/aws/transport/http"
 	"github.com/aws/aws-sdk-go-v2/config"
 	"github.com/aws/aws-sdk-go-v2/credentials"
 	"github.com/aws/aws-sdk-go-v2/service/s3"
 	"github.com/aws/aws-sdk-go-v2/service/s3/types"
 	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/forta-network/disco/drivers"
 	"github.com/forta-network/disco/interfaces"
+	"github.com/forta-network/disco/utils"
 	"github.com/hashicorp/go-multierror"
 
 	dcontext "github.com/distribution/distribution/v3/context"
@@ -70,12 +74,14 @@ const listMax = 1000
 type DriverParameters struct {
 	AccessKey                   string
 	SecretKey                   string
+	CredentialsSecret           string
 	Bucket                      string
 	Region                      string
 	RegionEndpoint              string
 	ForcePathStyle              bool
 	Secure                      bool
 	SkipVerify                  bool
+	TLSConfig                   *tls.Config
 	ChunkSize                   int64
 	MultipartCopyChunkSize      int64
 	MultipartCopyMaxConcurrency int64
@@ -124,6 +130,9 @@ type Driver struct {
 // - region
 // - bucket
 // - regionendpoint
+// Optionally, credentialssecret can point to a secret which contains the accesskey
+// and secretkey fields, e.g. vault:secret/data/r2. The credentials are then fetched
+// from the secret provider and refreshed periodically to pick up the rotations.
 func FromParameters(parameters map[string]interface{}) (*Driver, error) {
 	// Providing no values for these is valid in case the user is authenticating
 	// with an IAM on an ec2 instance (in which case the instance credentials will
@@ -147,6 +156,11 @@ func FromParameters(parameters map[string]interface{}) (*Driver, error) {
 		secretKey = secretKeyEnv
 	}
 
+	credentialsSecret := parameters["credentialssecret"]
+	if credentialsSecret == nil {
+		credentialsSecret = ""
+	}
+
 	regionEndpoint := parameters["regionendpoint"]
 	if regionEndpoint == nil {
 		regionEndpoint = ""
@@ -239,15 +253,19 @@ func FromParameters(parameters map[string]interface{}) (*Driver, error) {
 		rootDirectory = ""
 	}
 
+	tlsConfig, _ := parameters[drivers.TLSConfigParameter].(*tls.Config)
+
 	params := DriverParameters{
 		AccessKey:                   fmt.Sprint(accessKey),
 		SecretKey:                   fmt.Sprint(secretKey),
+		CredentialsSecret:           fmt.Sprint(credentialsSecret),
 		Bucket:                      fmt.Sprint(bucket),
 		Region:                      region,
 		RegionEndpoint:              fmt.Sprint(regionEndpoint),
 		ForcePathStyle:              forcePathStyleBool,
 		Secure:                      secureBool,
 		SkipVerify:                  skipVerifyBool,
+		TLSConfig:                   tlsConfig,
 		ChunkSize:                   chunkSize,
 		MultipartCopyChunkSize:      multipartCopyChunkSize,
 		MultipartCopyMaxConcurrency: multipartCopyMaxConcurrency,
@@ -294,11 +312,37 @@ func New(params DriverParameters) (*Driver, error) {
 		}, nil
 	})
 
-	cfg, err := config.LoadDefaultConfig(context.TODO(),
+	var credentialsProvider aws.CredentialsProvider = credentials.NewStaticCredentialsProvider(params.AccessKey, params.SecretKey, "")
+	if len(params.CredentialsSecret) > 0 {
+		credentialsProvider = aws.NewCredentialsCache(&secretCredentialsProvider{ref: params.CredentialsSecret})
+	}
+
+	opts := []func(*config.LoadOptions) error{
 		config.WithEndpointResolverWithOptions(r2Resolver),
 		config.WithRegion("auto"),
-		config.WithCredentialsProvider(credentials.NewStaticCredentialsProvider(params.AccessKey, params.SecretKey, "")),
-	)
+		config.WithCredentialsProvider(credentialsProvider),
+	}
+	httpClient := awshttp.NewBuildableClient()
+	if params.TLSConfig != nil || params.SkipVerify {
+		httpClient = httpClient.WithTransportOptions(func(tr *http.Transport) {
+			if params.TLSConfig != nil {
+				tr.TLSClientConfig = params.TLSConfig.Clone()
+			}
+			if params.SkipVerify {
+				if tr.TLSClientConfig == nil {
+					tr.TLSClientConfig = &tls.Config{}
+				}
+				tr.TLSClientConfig.InsecureSkipVerify = true
+			}
+		})
+	}
+	// the requests are traced in the spans of the storage calls
+	opts = append(opts, config.WithHTTPClient(&http.Client{
+		Transport: utils.TraceTransport(httpClient.GetTransport()),
+		Timeout:   httpClient.GetTimeout(),
+	}))
+
+	cfg, err := config.LoadDefaultConfig(context.TODO(), opts...)
 	if err != nil {
 		return nil, err
 	}
@@ -1240,7 +1284,7 @@ func (w *writer) flushPart() error {
 	// Check if the ready part is less than the chunk size
 	if len(w.readyPart) < int(w.driver.ChunkSize) {
 		// If there's enough in the pending part to fill the ready part up to the chunk size
-		if len(w.pendingPart) + len(w.readyPart) >= int(w.driver.ChunkSize) {
+		if len(w.pendingPart)+len(w.readyPart) >= int(w.driver.ChunkSize) {
 			fillSize := int(w.driver.ChunkSize) - len(w.readyPart)
 			w.readyPart = append(w.readyPart, w.pendingPart[:fillSize]...)
 			w.pendingPart = w.pendingPart[fillSize:]
@@ -0,0 +1,327 @@
+package zstdcache
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"regexp"
+
+	storagedriver "github.com/distribution/distribution/v3/registry/storage/driver"
+	"github.com/klauspost/compress/zstd"
+)
+
+// blobDataPathPattern matches the blob data paths.
+var blobDataPathPattern = regexp.MustCompile(`/blobs/sha256/[0-9a-f]{2}/[0-9a-f]{64}/data$`)
+
+// The compressed blobs end with a zstd skippable frame which keeps the size of the blob, so that
+// the blobs can be stat'ed without decompressing them. The frame is skipped by the decoders.
+const (
+	trailerFrameMagic = 0x184D2A5E
+	trailerSize       = 24
+)
+
+// trailerMarker tells the compressed blobs apart from the blobs which are stored as they are.
+var trailerMarker = []byte("DISCOZST")
+
+// Magic numbers of the blobs which are compressed already.
+var (
+	gzipMagic = []byte{0x1f, 0x8b}
+	zstdMagic = []byte{0x28, 0xb5, 0x2f, 0xfd}
+)
+
+// driver compresses the blobs of the wrapped driver with zstd, e.g. the uncompressed layers
+// in the cache, and decompresses them when they are read. The blobs which are compressed already
+// are stored as they are, since recompressing them would change their digests. Only the blob
+// data is compressed, so the uploads can be appended to and the links are read as they are.
+type driver struct {
+	storagedriver.StorageDriver
+	level zstd.EncoderLevel
+}
+
+// New wraps the driver with the compression of the blobs.
+func New(wrapped storagedriver.StorageDriver, level zstd.EncoderLevel) storagedriver.StorageDriver {
+	return &driver{StorageDriver: wrapped, level: level}
+}
+
+func isBlobData(path string) bool {
+	return blobDataPathPattern.MatchString(path)
+}
+
+// isCompressed tells if the content starts like a compressed blob.
+func isCompressed(head []byte) bool {
+	return bytes.HasPrefix(head, gzipMagic) || bytes.HasPrefix(head, zstdMagic)
+}
+
+func (d *driver) newEncoder(w io.Writer) (*zstd.Encoder, error) {
+	return zstd.NewWriter(w, zstd.WithEncoderLevel(d.level), zstd.WithEncoderConcurrency(1))
+}
+
+func trailer(size int64) []byte {
+	b := make([]byte, trailerSize)
+	binary.LittleEndian.PutUint32(b[0:4], trailerFrameMagic)
+	binary.LittleEndian.PutUint32(b[4:8], trailerSize-8)
+	copy(b[8:16], trailerMarker)
+	binary.LittleEndian.PutUint64(b[16:24], uint64(size))
+	return b
+}
+
+// parseTrailer returns the size of the blob if the content ends with the trailer.
+func parseTrailer(b []byte) (int64, bool) {
+	if len(b) < trailerSize {
+		return 0, false
+	}
+	b = b[len(b)-trailerSize:]
+	if binary.LittleEndian.Uint32(b[0:4]) != trailerFrameMagic || !bytes.Equal(b[8:16], trailerMarker) {
+		return 0, false
+	}
+	return int64(binary.LittleEndian.Uint64(b[16:24])), true
+}
+
+// blobSize returns the size of the blob before it was compressed and tells if it was compressed.
+// The stored size is the size of the blob in the wrapped driver.
+func (d *driver) blobSize(ctx context.Context, path string, storedSize int64) (int64, bool, error) {
+	if storedSize < trailerSize {
+		return 0, false, nil
+	}
+	reader, err := d.StorageDriver.Reader(ctx, path, storedSize-trailerSize)
+	if err != nil {
+		return 0, false, err
+	}
+	defer reader.Close()
+	b := make([]byte, trailerSize)
+	if _, err := io.ReadFull(reader, b); err != nil {
+		return 0, false, fmt.Errorf("failed to read the end of the blob: %v", err)
+	}
+	size, ok := parseTrailer(b)
+	return size, ok, nil
+}
+
+// compress returns the compressed content unless it is compressed already.
+func (d *driver) compress(content []byte) ([]byte, error) {
+	if isCompressed(content) {
+		return content, nil
+	}
+	enc, err := d.newEncoder(nil)
+	if err != nil {
+		return nil, err
+	}
+	defer enc.Close()
+	return append(enc.EncodeAll(content, nil), trailer(int64(len(content)))...), nil
+}
+
+// GetContent retrieves the content stored at "path" as a []byte.
+func (d *driver) GetContent(ctx context.Context, path string) ([]byte, error) {
+	content, err := d.StorageDriver.GetContent(ctx, path)
+	if err != nil || !isBlobData(path) {
+		return content, err
+	}
+	if _, ok := parseTrailer(content); !ok {
+		return content, nil
+	}
+	dec, err := zstd.NewReader(nil, zstd.WithDecoderConcurrency(1))
+	if err != nil {
+		return nil, err
+	}
+	defer dec.Close()
+	content, err = dec.DecodeAll(content, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decompress the blob: %v", err)
+	}
+	return content, nil
+}
+
+// PutContent stores the []byte content at a location designated by "path".
+func (d *driver) PutContent(ctx context.Context, path string, content []byte) error {
+	if !isBlobData(path) {
+		return d.StorageDriver.PutContent(ctx, path, content)
+	}
+	compressed, err := d.compress(content)
+	if err != nil {
+		return fmt.Errorf("failed to compress the blob: %v", err)
+	}
+	return d.StorageDriver.PutContent(ctx, path, compressed)
+}
+
+// Reader retrieves an io.ReadCloser for the content stored at "path" with a given byte offset.
+// The compressed blobs are decompressed from the start up to the offset.
+func (d *driver) Reader(ctx context.Context, path string, offset int64) (io.ReadCloser, error) {
+	if !isBlobData(path) {
+		return d.StorageDriver.Reader(ctx, path, offset)
+	}
+	info, err := d.StorageDriver.Stat(ctx, path)
+	if err != nil {
+		return nil, err
+	}
+	size, compressed, err := d.blobSize(ctx, path, info.Size())
+	if err != nil {
+		return nil, err
+	}
+	if !compressed {
+		return d.StorageDriver.Reader(ctx, path, offset)
+	}
+	if offset >= size {
+		return io.NopCloser(bytes.NewReader(nil)), nil
+	}
+	reader, err := d.StorageDriver.Reader(ctx, path, 0)
+	if err != nil {
+		return nil, err
+	}
+	dec, err := zstd.NewReader(reader, zstd.WithDecoderConcurrency(1))
+	if err != nil {
+		reader.Close()
+		return nil, err
+	}
+	blobReader := &blobReader{Decoder: dec, reader: reader}
+	if _, err := io.CopyN(io.Discard, dec, offset); err != nil {
+		blobReader.Close()
+		return nil, fmt.Errorf("failed to decompress the blob up to the offset: %v", err)
+	}
+	return blobReader, nil
+}
+
+// Writer returns a FileWriter which will store the content written to it at the location
+// designated by "path" after the call to Commit.
+func (d *driver) Writer(ctx context.Context, path string, append bool) (storagedriver.FileWriter, error) {
+	writer, err := d.StorageDriver.Writer(ctx, path, append)
+	if err != nil || !isBlobData(path) || append {
+		return writer, err
+	}
+	return &fileWriter{FileWriter: writer, driver: d}, nil
+}
+
+// Stat retrieves the FileInfo for the given path, including the current size in bytes and the
+// creation time. The size of a compressed blob is its size before it was compressed.
+func (d *driver) Stat(ctx context.Context, path string) (storagedriver.FileInfo, error) {
+	info, err := d.StorageDriver.Stat(ctx, path)
+	if err != nil || !isBlobData(path) || info.IsDir() {
+		return info, err
+	}
+	size, compressed, err := d.blobSize(ctx, path, info.Size())
+	if err != nil {
+		return nil, err
+	}
+	if !compressed {
+		return info, nil
+	}
+	return storagedriver.FileInfoInternal{FileInfoFields: storagedriver.FileInfoFields{
+		Path:    info.Path(),
+		Size:    size,
+		ModTime: info.ModTime(),
+	}}, nil
+}
+
+// Move moves an object stored at sourcePath to destPath, removing the original object. The
+// uploads which are moved to the blob paths are compressed while they are copied.
+func (d *driver) Move(ctx context.Context, sourcePath string, destPath string) error {
+	if !isBlobData(destPath) {
+		return d.StorageDriver.Move(ctx, sourcePath, destPath)
+	}
+	reader, err := d.Reader(ctx, sourcePath, 0)
+	if err != nil {
+		return err
+	}
+	defer reader.Close()
+	writer, err := d.Writer(ctx, destPath, false)
+	if err != nil {
+		return err
+	}
+	defer writer.Close()
+	if _, err := io.Copy(writer, reader); err != nil {
+		_ = writer.Cancel()
+		return fmt.Errorf("failed to copy the blob: %v", err)
+	}
+	if err := writer.Commit(); err != nil {
+		_ = writer.Cancel()
+		return fmt.Errorf("failed to commit the blob: %v", err)
+	}
+	return d.StorageDriver.Delete(ctx, sourcePath)
+}
+
+// URLFor returns a URL which may be used to retrieve the content stored at the given path. The
+// blobs are not redirected to, since they may be compressed.
+func (d *driver) URLFor(ctx context.Context, path string, options map[string]interface{}) (string, error) {
+	if isBlobData(path) {
+		return "", storagedriver.ErrUnsupportedMethod{}
+	}
+	return d.StorageDriver.URLFor(ctx, path, options)
+}
+
+// blobReader decompresses a blob and closes the reader of the wrapped driver.
+type blobReader struct {
+	*zstd.Decoder
+	reader io.ReadCloser
+}
+
+func (br *blobReader) Close() error {
+	br.Decoder.Close()
+	return br.reader.Close()
+}
+
+// fileWriter compresses the blob unless it starts like a compressed blob.
+type fileWriter struct {
+	storagedriver.FileWriter
+	driver *driver
+
+	// head keeps the start of the blob until the blob can be told apart from the compressed
+	// blobs.
+	head []byte
+	out  io.Writer
+	enc  *zstd.Encoder
+	size int64
+}
+
+func (fw *fileWriter) Write(p []byte) (int, error) {
+	if fw.out != nil {
+		n, err := fw.out.Write(p)
+		fw.size += int64(n)
+		return n, err
+	}
+	fw.head = append(fw.head, p...)
+	if len(fw.head) >= len(zstdMagic) {
+		if err := fw.start(); err != nil {
+			return 0, err
+		}
+	}
+	fw.size += int64(len(p))
+	return len(p), nil
+}
+
+// start decides if the blob is compressed and writes the start of the blob.
+func (fw *fileWriter) start() error {
+	fw.out = fw.FileWriter
+	if !isCompressed(fw.head) {
+		enc, err := fw.driver.newEncoder(fw.FileWriter)
+		if err != nil {
+			return err
+		}
+		fw.enc, fw.out = enc, enc
+	}
+	head := fw.head
+	fw.head = nil
+	_, err := fw.out.Write(head)
+	return err
+}
+
+// Size returns the number of bytes written to the writer before they are compressed.
+func (fw *fileWriter) Size() int64 {
+	return fw.size
+}
+
+func (fw *fileWriter) Commit() error {
+	if fw.out == nil {
+		if err := fw.start(); err != nil {
+			return err
+		}
+	}
+	if fw.enc != nil {
+		if err := fw.enc.Close(); err != nil {
+			return fmt.Errorf("failed to compress the blob: %v", err)
+		}
+		if _, err := fw.FileWriter.Write(trailer(fw.size)); err != nil {
+			return err
+		}
+	}
+	return fw.FileWriter.Commit()
+}
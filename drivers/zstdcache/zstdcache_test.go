@@ -0,0 +1,141 @@
+package zstdcache
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"strings"
+	"testing"
+
+	storagedriver "github.com/distribution/distribution/v3/registry/storage/driver"
+	"github.com/distribution/distribution/v3/registry/storage/driver/inmemory"
+	"github.com/klauspost/compress/zstd"
+	"github.com/stretchr/testify/require"
+)
+
+const (
+	testBlobPath   = "/docker/registry/v2/blobs/sha256/12/1234567812345678123456781234567812345678123456781234567812345678/data"
+	testUploadPath = "/docker/registry/v2/repositories/myrepo/_uploads/1234/data"
+	testLinkPath   = "/docker/registry/v2/repositories/myrepo/_layers/sha256/1234/link"
+)
+
+func readBlob(r *require.Assertions, d storagedriver.StorageDriver, path string, offset int64) string {
+	reader, err := d.Reader(context.Background(), path, offset)
+	r.NoError(err)
+	defer reader.Close()
+	b, err := io.ReadAll(reader)
+	r.NoError(err)
+	return string(b)
+}
+
+func TestCompression(t *testing.T) {
+	r := require.New(t)
+	ctx := context.Background()
+
+	wrapped := inmemory.New()
+	d := New(wrapped, zstd.SpeedDefault)
+	blob := strings.Repeat("uncompressed layer ", 1000)
+
+	// When an uncompressed blob is written
+	writer, err := d.Writer(ctx, testBlobPath, false)
+	r.NoError(err)
+	// a byte at a time at the start
+	for i := 0; i < 8; i++ {
+		_, err = writer.Write([]byte{blob[i]})
+		r.NoError(err)
+	}
+	_, err = writer.Write([]byte(blob[8:]))
+	r.NoError(err)
+	r.Equal(int64(len(blob)), writer.Size())
+	r.NoError(writer.Commit())
+	r.NoError(writer.Close())
+
+	// Then it should be stored compressed
+	stored, err := wrapped.GetContent(ctx, testBlobPath)
+	r.NoError(err)
+	r.Less(len(stored), len(blob)/10)
+
+	// And it should be read as it was written
+	info, err := d.Stat(ctx, testBlobPath)
+	r.NoError(err)
+	r.Equal(int64(len(blob)), info.Size())
+	r.Equal(testBlobPath, info.Path())
+	r.Equal(blob, readBlob(r, d, testBlobPath, 0))
+	r.Equal(blob[1000:], readBlob(r, d, testBlobPath, 1000))
+	r.Empty(readBlob(r, d, testBlobPath, int64(len(blob))))
+	content, err := d.GetContent(ctx, testBlobPath)
+	r.NoError(err)
+	r.Equal(blob, string(content))
+
+	// And it should be readable by any zstd decoder
+	dec, err := zstd.NewReader(bytes.NewReader(stored))
+	r.NoError(err)
+	defer dec.Close()
+	b, err := io.ReadAll(dec)
+	r.NoError(err)
+	r.Equal(blob, string(b))
+
+	// And the blobs should not be redirected to
+	_, err = d.URLFor(ctx, testBlobPath, nil)
+	r.Error(err)
+}
+
+func TestCompression_Compressed(t *testing.T) {
+	r := require.New(t)
+	ctx := context.Background()
+
+	wrapped := inmemory.New()
+	d := New(wrapped, zstd.SpeedFastest)
+	blob := "\x1f\x8b" + strings.Repeat("gzip layer ", 100)
+
+	// When a compressed blob is written
+	r.NoError(d.PutContent(ctx, testBlobPath, []byte(blob)))
+
+	// Then it should be stored as it is
+	stored, err := wrapped.GetContent(ctx, testBlobPath)
+	r.NoError(err)
+	r.Equal(blob, string(stored))
+	info, err := d.Stat(ctx, testBlobPath)
+	r.NoError(err)
+	r.Equal(int64(len(blob)), info.Size())
+	r.Equal(blob[10:], readBlob(r, d, testBlobPath, 10))
+}
+
+func TestCompression_Move(t *testing.T) {
+	r := require.New(t)
+	ctx := context.Background()
+
+	wrapped := inmemory.New()
+	d := New(wrapped, zstd.SpeedDefault)
+	blob := strings.Repeat("pushed layer ", 1000)
+
+	// Given an upload which is appended to
+	writer, err := d.Writer(ctx, testUploadPath, false)
+	r.NoError(err)
+	_, err = writer.Write([]byte(blob[:100]))
+	r.NoError(err)
+	r.NoError(writer.Close())
+	writer, err = d.Writer(ctx, testUploadPath, true)
+	r.NoError(err)
+	_, err = writer.Write([]byte(blob[100:]))
+	r.NoError(err)
+	r.NoError(writer.Commit())
+	r.NoError(writer.Close())
+
+	// When it is moved to the blob path
+	r.NoError(d.Move(ctx, testUploadPath, testBlobPath))
+
+	// Then the blob should be compressed
+	stored, err := wrapped.GetContent(ctx, testBlobPath)
+	r.NoError(err)
+	r.Less(len(stored), len(blob)/10)
+	r.Equal(blob, readBlob(r, d, testBlobPath, 0))
+	_, err = wrapped.Stat(ctx, testUploadPath)
+	r.Error(err)
+
+	// And the other paths should not be compressed
+	r.NoError(d.PutContent(ctx, testLinkPath, []byte("sha256:1234")))
+	stored, err = wrapped.GetContent(ctx, testLinkPath)
+	r.NoError(err)
+	r.Equal("sha256:1234", string(stored))
+}
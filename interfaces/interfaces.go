@@ -13,6 +13,10 @@ import (
 // IPFSClient makes requests to an IPFS node.
 type IPFSClient interface {
 	GetClientFor(ctx context.Context, path string) (IPFSFilesAPI, error)
+	// GetClientForWrite is like GetClientFor but it also places the new repositories, so it
+	// should be used when the path is written with the client.
+	GetClientForWrite(ctx context.Context, path string) (IPFSFilesAPI, error)
+	NodeClients() []IPFSFilesAPI
 	IPFSFilesAPI
 }
 
@@ -26,6 +30,26 @@ type IPFSFilesAPI interface {
 	FilesMkdir(ctx context.Context, path string, options ...ipfsapi.FilesOpt) error
 	FilesLs(ctx context.Context, path string, options ...ipfsapi.FilesOpt) ([]*ipfsapi.MfsLsEntry, error)
 	FilesMv(ctx context.Context, src string, dest string) error
+	// Cat reads the content at the IPFS path from the network.
+	Cat(ctx context.Context, path string) (io.ReadCloser, error)
+	IPFSPinAPI
+	IPFSNameAPI
+}
+
+// IPFSPinAPI pins the content in an IPFS node and in the remote pinning services which are
+// configured in the node.
+type IPFSPinAPI interface {
+	Pin(ctx context.Context, path string) error
+	Unpin(ctx context.Context, path string) error
+	PinLs(ctx context.Context, path string) (map[string]string, error)
+	PinRemote(ctx context.Context, service, path, name string) error
+	UnpinRemote(ctx context.Context, service, cid string) error
+}
+
+// IPFSNameAPI publishes the content to IPNS with the keys of an IPFS node.
+type IPFSNameAPI interface {
+	// NamePublish publishes the IPFS path with the key and returns the IPNS name.
+	NamePublish(ctx context.Context, path, key string) (string, error)
 }
 
 // R2Client makes requests to an R2 API.
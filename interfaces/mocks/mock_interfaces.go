@@ -39,6 +39,21 @@ func (m *MockIPFSClient) EXPECT() *MockIPFSClientMockRecorder {
 	return m.recorder
 }
 
+// Cat mocks base method.
+func (m *MockIPFSClient) Cat(ctx context.Context, path string) (io.ReadCloser, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Cat", ctx, path)
+	ret0, _ := ret[0].(io.ReadCloser)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// Cat indicates an expected call of Cat.
+func (mr *MockIPFSClientMockRecorder) Cat(ctx, path interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Cat", reflect.TypeOf((*MockIPFSClient)(nil).Cat), ctx, path)
+}
+
 // FilesCp mocks base method.
 func (m *MockIPFSClient) FilesCp(ctx context.Context, src, dest string) error {
 	m.ctrl.T.Helper()
@@ -194,6 +209,121 @@ func (mr *MockIPFSClientMockRecorder) GetClientFor(ctx, path interface{}) *gomoc
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetClientFor", reflect.TypeOf((*MockIPFSClient)(nil).GetClientFor), ctx, path)
 }
 
+// GetClientForWrite mocks base method.
+func (m *MockIPFSClient) GetClientForWrite(ctx context.Context, path string) (interfaces.IPFSFilesAPI, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetClientForWrite", ctx, path)
+	ret0, _ := ret[0].(interfaces.IPFSFilesAPI)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetClientForWrite indicates an expected call of GetClientForWrite.
+func (mr *MockIPFSClientMockRecorder) GetClientForWrite(ctx, path interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetClientForWrite", reflect.TypeOf((*MockIPFSClient)(nil).GetClientForWrite), ctx, path)
+}
+
+// NamePublish mocks base method.
+func (m *MockIPFSClient) NamePublish(ctx context.Context, path, key string) (string, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "NamePublish", ctx, path, key)
+	ret0, _ := ret[0].(string)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// NamePublish indicates an expected call of NamePublish.
+func (mr *MockIPFSClientMockRecorder) NamePublish(ctx, path, key interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "NamePublish", reflect.TypeOf((*MockIPFSClient)(nil).NamePublish), ctx, path, key)
+}
+
+// NodeClients mocks base method.
+func (m *MockIPFSClient) NodeClients() []interfaces.IPFSFilesAPI {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "NodeClients")
+	ret0, _ := ret[0].([]interfaces.IPFSFilesAPI)
+	return ret0
+}
+
+// NodeClients indicates an expected call of NodeClients.
+func (mr *MockIPFSClientMockRecorder) NodeClients() *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "NodeClients", reflect.TypeOf((*MockIPFSClient)(nil).NodeClients))
+}
+
+// Pin mocks base method.
+func (m *MockIPFSClient) Pin(ctx context.Context, path string) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Pin", ctx, path)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// Pin indicates an expected call of Pin.
+func (mr *MockIPFSClientMockRecorder) Pin(ctx, path interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Pin", reflect.TypeOf((*MockIPFSClient)(nil).Pin), ctx, path)
+}
+
+// PinLs mocks base method.
+func (m *MockIPFSClient) PinLs(ctx context.Context, path string) (map[string]string, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "PinLs", ctx, path)
+	ret0, _ := ret[0].(map[string]string)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// PinLs indicates an expected call of PinLs.
+func (mr *MockIPFSClientMockRecorder) PinLs(ctx, path interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "PinLs", reflect.TypeOf((*MockIPFSClient)(nil).PinLs), ctx, path)
+}
+
+// PinRemote mocks base method.
+func (m *MockIPFSClient) PinRemote(ctx context.Context, service, path, name string) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "PinRemote", ctx, service, path, name)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// PinRemote indicates an expected call of PinRemote.
+func (mr *MockIPFSClientMockRecorder) PinRemote(ctx, service, path, name interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "PinRemote", reflect.TypeOf((*MockIPFSClient)(nil).PinRemote), ctx, service, path, name)
+}
+
+// Unpin mocks base method.
+func (m *MockIPFSClient) Unpin(ctx context.Context, path string) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Unpin", ctx, path)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// Unpin indicates an expected call of Unpin.
+func (mr *MockIPFSClientMockRecorder) Unpin(ctx, path interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Unpin", reflect.TypeOf((*MockIPFSClient)(nil).Unpin), ctx, path)
+}
+
+// UnpinRemote mocks base method.
+func (m *MockIPFSClient) UnpinRemote(ctx context.Context, service, cid string) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "UnpinRemote", ctx, service, cid)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// UnpinRemote indicates an expected call of UnpinRemote.
+func (mr *MockIPFSClientMockRecorder) UnpinRemote(ctx, service, cid interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "UnpinRemote", reflect.TypeOf((*MockIPFSClient)(nil).UnpinRemote), ctx, service, cid)
+}
+
 // MockIPFSFilesAPI is a mock of IPFSFilesAPI interface.
 type MockIPFSFilesAPI struct {
 	ctrl     *gomock.Controller
@@ -217,6 +347,21 @@ func (m *MockIPFSFilesAPI) EXPECT() *MockIPFSFilesAPIMockRecorder {
 	return m.recorder
 }
 
+// Cat mocks base method.
+func (m *MockIPFSFilesAPI) Cat(ctx context.Context, path string) (io.ReadCloser, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Cat", ctx, path)
+	ret0, _ := ret[0].(io.ReadCloser)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// Cat indicates an expected call of Cat.
+func (mr *MockIPFSFilesAPIMockRecorder) Cat(ctx, path interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Cat", reflect.TypeOf((*MockIPFSFilesAPI)(nil).Cat), ctx, path)
+}
+
 // FilesCp mocks base method.
 func (m *MockIPFSFilesAPI) FilesCp(ctx context.Context, src, dest string) error {
 	m.ctrl.T.Helper()
@@ -357,6 +502,224 @@ func (mr *MockIPFSFilesAPIMockRecorder) FilesWrite(ctx, path, data interface{},
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "FilesWrite", reflect.TypeOf((*MockIPFSFilesAPI)(nil).FilesWrite), varargs...)
 }
 
+// NamePublish mocks base method.
+func (m *MockIPFSFilesAPI) NamePublish(ctx context.Context, path, key string) (string, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "NamePublish", ctx, path, key)
+	ret0, _ := ret[0].(string)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// NamePublish indicates an expected call of NamePublish.
+func (mr *MockIPFSFilesAPIMockRecorder) NamePublish(ctx, path, key interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "NamePublish", reflect.TypeOf((*MockIPFSFilesAPI)(nil).NamePublish), ctx, path, key)
+}
+
+// Pin mocks base method.
+func (m *MockIPFSFilesAPI) Pin(ctx context.Context, path string) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Pin", ctx, path)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// Pin indicates an expected call of Pin.
+func (mr *MockIPFSFilesAPIMockRecorder) Pin(ctx, path interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Pin", reflect.TypeOf((*MockIPFSFilesAPI)(nil).Pin), ctx, path)
+}
+
+// PinLs mocks base method.
+func (m *MockIPFSFilesAPI) PinLs(ctx context.Context, path string) (map[string]string, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "PinLs", ctx, path)
+	ret0, _ := ret[0].(map[string]string)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// PinLs indicates an expected call of PinLs.
+func (mr *MockIPFSFilesAPIMockRecorder) PinLs(ctx, path interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "PinLs", reflect.TypeOf((*MockIPFSFilesAPI)(nil).PinLs), ctx, path)
+}
+
+// PinRemote mocks base method.
+func (m *MockIPFSFilesAPI) PinRemote(ctx context.Context, service, path, name string) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "PinRemote", ctx, service, path, name)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// PinRemote indicates an expected call of PinRemote.
+func (mr *MockIPFSFilesAPIMockRecorder) PinRemote(ctx, service, path, name interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "PinRemote", reflect.TypeOf((*MockIPFSFilesAPI)(nil).PinRemote), ctx, service, path, name)
+}
+
+// Unpin mocks base method.
+func (m *MockIPFSFilesAPI) Unpin(ctx context.Context, path string) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Unpin", ctx, path)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// Unpin indicates an expected call of Unpin.
+func (mr *MockIPFSFilesAPIMockRecorder) Unpin(ctx, path interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Unpin", reflect.TypeOf((*MockIPFSFilesAPI)(nil).Unpin), ctx, path)
+}
+
+// UnpinRemote mocks base method.
+func (m *MockIPFSFilesAPI) UnpinRemote(ctx context.Context, service, cid string) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "UnpinRemote", ctx, service, cid)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// UnpinRemote indicates an expected call of UnpinRemote.
+func (mr *MockIPFSFilesAPIMockRecorder) UnpinRemote(ctx, service, cid interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "UnpinRemote", reflect.TypeOf((*MockIPFSFilesAPI)(nil).UnpinRemote), ctx, service, cid)
+}
+
+// MockIPFSPinAPI is a mock of IPFSPinAPI interface.
+type MockIPFSPinAPI struct {
+	ctrl     *gomock.Controller
+	recorder *MockIPFSPinAPIMockRecorder
+}
+
+// MockIPFSPinAPIMockRecorder is the mock recorder for MockIPFSPinAPI.
+type MockIPFSPinAPIMockRecorder struct {
+	mock *MockIPFSPinAPI
+}
+
+// NewMockIPFSPinAPI creates a new mock instance.
+func NewMockIPFSPinAPI(ctrl *gomock.Controller) *MockIPFSPinAPI {
+	mock := &MockIPFSPinAPI{ctrl: ctrl}
+	mock.recorder = &MockIPFSPinAPIMockRecorder{mock}
+	return mock
+}
+
+// EXPECT returns an object that allows the caller to indicate expected use.
+func (m *MockIPFSPinAPI) EXPECT() *MockIPFSPinAPIMockRecorder {
+	return m.recorder
+}
+
+// Pin mocks base method.
+func (m *MockIPFSPinAPI) Pin(ctx context.Context, path string) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Pin", ctx, path)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// Pin indicates an expected call of Pin.
+func (mr *MockIPFSPinAPIMockRecorder) Pin(ctx, path interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Pin", reflect.TypeOf((*MockIPFSPinAPI)(nil).Pin), ctx, path)
+}
+
+// PinLs mocks base method.
+func (m *MockIPFSPinAPI) PinLs(ctx context.Context, path string) (map[string]string, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "PinLs", ctx, path)
+	ret0, _ := ret[0].(map[string]string)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// PinLs indicates an expected call of PinLs.
+func (mr *MockIPFSPinAPIMockRecorder) PinLs(ctx, path interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "PinLs", reflect.TypeOf((*MockIPFSPinAPI)(nil).PinLs), ctx, path)
+}
+
+// PinRemote mocks base method.
+func (m *MockIPFSPinAPI) PinRemote(ctx context.Context, service, path, name string) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "PinRemote", ctx, service, path, name)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// PinRemote indicates an expected call of PinRemote.
+func (mr *MockIPFSPinAPIMockRecorder) PinRemote(ctx, service, path, name interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "PinRemote", reflect.TypeOf((*MockIPFSPinAPI)(nil).PinRemote), ctx, service, path, name)
+}
+
+// Unpin mocks base method.
+func (m *MockIPFSPinAPI) Unpin(ctx context.Context, path string) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Unpin", ctx, path)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// Unpin indicates an expected call of Unpin.
+func (mr *MockIPFSPinAPIMockRecorder) Unpin(ctx, path interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Unpin", reflect.TypeOf((*MockIPFSPinAPI)(nil).Unpin), ctx, path)
+}
+
+// UnpinRemote mocks base method.
+func (m *MockIPFSPinAPI) UnpinRemote(ctx context.Context, service, cid string) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "UnpinRemote", ctx, service, cid)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// UnpinRemote indicates an expected call of UnpinRemote.
+func (mr *MockIPFSPinAPIMockRecorder) UnpinRemote(ctx, service, cid interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "UnpinRemote", reflect.TypeOf((*MockIPFSPinAPI)(nil).UnpinRemote), ctx, service, cid)
+}
+
+// MockIPFSNameAPI is a mock of IPFSNameAPI interface.
+type MockIPFSNameAPI struct {
+	ctrl     *gomock.Controller
+	recorder *MockIPFSNameAPIMockRecorder
+}
+
+// MockIPFSNameAPIMockRecorder is the mock recorder for MockIPFSNameAPI.
+type MockIPFSNameAPIMockRecorder struct {
+	mock *MockIPFSNameAPI
+}
+
+// NewMockIPFSNameAPI creates a new mock instance.
+func NewMockIPFSNameAPI(ctrl *gomock.Controller) *MockIPFSNameAPI {
+	mock := &MockIPFSNameAPI{ctrl: ctrl}
+	mock.recorder = &MockIPFSNameAPIMockRecorder{mock}
+	return mock
+}
+
+// EXPECT returns an object that allows the caller to indicate expected use.
+func (m *MockIPFSNameAPI) EXPECT() *MockIPFSNameAPIMockRecorder {
+	return m.recorder
+}
+
+// NamePublish mocks base method.
+func (m *MockIPFSNameAPI) NamePublish(ctx context.Context, path, key string) (string, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "NamePublish", ctx, path, key)
+	ret0, _ := ret[0].(string)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// NamePublish indicates an expected call of NamePublish.
+func (mr *MockIPFSNameAPIMockRecorder) NamePublish(ctx, path, key interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "NamePublish", reflect.TypeOf((*MockIPFSNameAPI)(nil).NamePublish), ctx, path, key)
+}
+
 // MockR2Client is a mock of R2Client interface.
 type MockR2Client struct {
 	ctrl     *gomock.Controller
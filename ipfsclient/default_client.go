@@ -2,6 +2,7 @@ package ipfsclient
 
 import (
 	"context"
+	"io"
 	"net/http"
 
 	"github.com/forta-network/disco/interfaces"
@@ -15,10 +16,127 @@ type Client struct {
 
 // NewClient creates a new client.
 func NewClient(apiURL string) *Client {
-	return &Client{*ipfsapi.NewShellWithClient(apiURL, http.DefaultClient)}
+	return NewClientWithHTTPClient(apiURL, http.DefaultClient)
+}
+
+// NewClientWithHTTPClient creates a new client which uses given HTTP client.
+func NewClientWithHTTPClient(apiURL string, httpClient *http.Client) *Client {
+	return &Client{*ipfsapi.NewShellWithClient(apiURL, httpClient)}
 }
 
 // GetClientFor returns the single client that is being used.
 func (client *Client) GetClientFor(ctx context.Context, path string) (interfaces.IPFSFilesAPI, error) {
-	return &client.Shell, nil
+	return client, nil
+}
+
+// GetClientForWrite returns the single client that is being used.
+func (client *Client) GetClientForWrite(ctx context.Context, path string) (interfaces.IPFSFilesAPI, error) {
+	return client, nil
+}
+
+// NodeClients returns the single client that is being used.
+func (client *Client) NodeClients() []interfaces.IPFSFilesAPI {
+	return []interfaces.IPFSFilesAPI{client}
+}
+
+// RepoStatObject contains the repo usage of an IPFS node.
+type RepoStatObject struct {
+	RepoSize   uint64
+	StorageMax uint64
+	NumObjects uint64
+}
+
+// RepoStat returns the repo usage of the node.
+func (client *Client) RepoStat(ctx context.Context) (*RepoStatObject, error) {
+	var stat RepoStatObject
+	if err := client.Request("repo/stat").Option("size-only", true).Exec(ctx, &stat); err != nil {
+		return nil, err
+	}
+	return &stat, nil
+}
+
+// Cat reads the content at the IPFS path, which is fetched from the network if the node does not
+// have it.
+func (client *Client) Cat(ctx context.Context, path string) (io.ReadCloser, error) {
+	resp, err := client.Request("cat", path).Send(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if resp.Error != nil {
+		resp.Close()
+		return nil, resp.Error
+	}
+	return resp.Output, nil
+}
+
+// Pin pins the content at the IPFS path recursively.
+func (client *Client) Pin(ctx context.Context, path string) error {
+	return client.Request("pin/add", path).Option("recursive", true).Exec(ctx, nil)
+}
+
+// Unpin removes the recursive pin of the content at the IPFS path.
+func (client *Client) Unpin(ctx context.Context, path string) error {
+	return client.Request("pin/rm", path).Option("recursive", true).Exec(ctx, nil)
+}
+
+// pinLsResult is the result of the pin/ls request.
+type pinLsResult struct {
+	Keys map[string]struct {
+		Type string
+	}
+}
+
+// PinLs returns the recursively pinned CIDs and their pin types. Only the pin of the content at
+// the IPFS path is returned unless the path is empty.
+func (client *Client) PinLs(ctx context.Context, path string) (map[string]string, error) {
+	req := client.Request("pin/ls")
+	if len(path) > 0 {
+		req = client.Request("pin/ls", path)
+	}
+	var result pinLsResult
+	if err := req.Option("type", "recursive").Exec(ctx, &result); err != nil {
+		return nil, err
+	}
+	pins := make(map[string]string, len(result.Keys))
+	for cid, pin := range result.Keys {
+		pins[cid] = pin.Type
+	}
+	return pins, nil
+}
+
+// PinRemote asks the remote pinning service, which is configured in the node, to pin the content
+// at the IPFS path. It does not wait until the content is pinned.
+func (client *Client) PinRemote(ctx context.Context, service, path, name string) error {
+	return client.Request("pin/remote/add", path).
+		Option("service", service).
+		Option("name", name).
+		Option("background", true).
+		Exec(ctx, nil)
+}
+
+// UnpinRemote removes the pins of the CID from the remote pinning service.
+func (client *Client) UnpinRemote(ctx context.Context, service, cid string) error {
+	return client.Request("pin/remote/rm").
+		Option("service", service).
+		Option("cid", cid).
+		Option("force", true).
+		Exec(ctx, nil)
+}
+
+// namePublishResult is the result of the name/publish request.
+type namePublishResult struct {
+	Name  string
+	Value string
+}
+
+// NamePublish publishes the IPFS path to IPNS with the key of the node and returns the IPNS name.
+func (client *Client) NamePublish(ctx context.Context, path, key string) (string, error) {
+	var result namePublishResult
+	if err := client.Request("name/publish", path).
+		Option("key", key).
+		Option("allow-offline", true).
+		Exec(ctx, &result); err != nil {
+		return "", err
+	}
+	return result.Name, nil
 }
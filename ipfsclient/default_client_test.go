@@ -13,5 +13,8 @@ func TestDefaultClient(t *testing.T) {
 	client := NewClient("http://foo.bar")
 	api, err := client.GetClientFor(context.Background(), "")
 	r.NoError(err)
-	r.Equal(&client.Shell, api)
+	r.Equal(client, api)
+	api, err = client.GetClientForWrite(context.Background(), "")
+	r.NoError(err)
+	r.Equal(client, api)
 }
@@ -0,0 +1,318 @@
+package ipfsclient
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/forta-network/disco/config"
+	"github.com/forta-network/disco/utils"
+	log "github.com/sirupsen/logrus"
+)
+
+// usageTTL is how long the node usage stats are trusted before asking the nodes again.
+const usageTTL = time.Minute
+
+// placementTimeout limits the requests to the shared placement index.
+const placementTimeout = 5 * time.Second
+
+const repositoriesBase = "/docker/registry/v2/repositories/"
+
+// placementKey is the key of the placement index in the shared state.
+const placementKey = "placements"
+
+// repoStater is implemented by the node clients which can report their repo usage.
+type repoStater interface {
+	RepoStat(ctx context.Context) (*RepoStatObject, error)
+}
+
+// placementIndex records the URLs of the nodes which the repositories were placed on.
+type placementIndex interface {
+	get(ctx context.Context, id string) (string, bool, error)
+	// add records the node of the repository if it has none and returns the recorded node.
+	add(ctx context.Context, id, nodeURL string) (string, error)
+}
+
+// placement assigns new repositories to the least-full eligible node and records
+// the decisions in an index so the repositories can be found again afterwards. The
+// repositories are placed only when they are written.
+type placement struct {
+	index    placementIndex
+	maxUsage float64
+
+	mu        sync.Mutex
+	usage     map[string]float64
+	usageTime time.Time
+}
+
+func newPlacement(index placementIndex, maxUsage float64) *placement {
+	return &placement{index: index, maxUsage: maxUsage}
+}
+
+func isRepositoryPath(path string) bool {
+	return strings.HasPrefix(path, repositoriesBase)
+}
+
+// route returns the node index for a repository. The placed repositories are routed to their
+// nodes and the rest by hashing. The new repositories which are written are placed on the
+// least-full eligible node and the repositories which already exist in the node suggested by
+// hashing stay there.
+func (p *placement) route(ctx context.Context, nodes []*ipfsNode, id string, hashed int, write bool) int {
+	nodeURL, ok, err := p.index.get(ctx, id)
+	if err != nil {
+		log.WithError(err).WithField("repository", id).Warn("failed to read the placement index - using hash routing")
+		return hashed
+	}
+	if ok {
+		if index, ok := nodeIndex(nodes, nodeURL); ok {
+			return index
+		}
+		log.WithFields(log.Fields{
+			"repository": id,
+			"node":       nodeURL,
+		}).Warn("repository was placed on a node which is not in the router - using hash routing")
+		return hashed
+	}
+	if !write {
+		return hashed
+	}
+
+	_, err = nodes[hashed].client.FilesStat(ctx, repositoriesBase+id)
+	switch {
+	case err == nil:
+		return p.record(ctx, nodes, id, hashed)
+
+	case strings.Contains(err.Error(), "does not exist"):
+		// new repository - continue placing
+
+	default:
+		log.WithError(err).WithField("repository", id).Warn("failed to check the repository before placing - using hash routing")
+		return hashed
+	}
+
+	index, usage, err := p.leastFull(ctx, nodes)
+	if err != nil {
+		log.WithError(err).WithField("repository", id).Warn("failed to place the repository - using hash routing")
+		return hashed
+	}
+	placed := p.record(ctx, nodes, id, index)
+	if placed == index {
+		log.WithFields(log.Fields{
+			"repository": id,
+			"nodeIndex":  index,
+			"nodeUsage":  usage,
+		}).Info("placed new repository")
+	}
+	return placed
+}
+
+// record records the node of the repository and returns the index of the recorded node, which
+// is another node if another replica placed the repository first.
+func (p *placement) record(ctx context.Context, nodes []*ipfsNode, id string, index int) int {
+	nodeURL, err := p.index.add(ctx, id, nodes[index].info.URL)
+	if err != nil {
+		log.WithError(err).WithField("repository", id).Error("failed to record the placement")
+		return index
+	}
+	if recorded, ok := nodeIndex(nodes, nodeURL); ok {
+		return recorded
+	}
+	return index
+}
+
+func nodeIndex(nodes []*ipfsNode, nodeURL string) (int, bool) {
+	for i, node := range nodes {
+		if node.info.URL == nodeURL {
+			return i, true
+		}
+	}
+	return 0, false
+}
+
+func (p *placement) leastFull(ctx context.Context, nodes []*ipfsNode) (int, float64, error) {
+	usage := p.nodeUsage(ctx, nodes)
+	best := -1
+	for i, node := range nodes {
+		nodeUsage, ok := usage[node.info.URL]
+		if !ok || nodeUsage < 0 {
+			continue // unknown
+		}
+		if p.maxUsage > 0 && nodeUsage >= p.maxUsage {
+			continue
+		}
+		if best < 0 || nodeUsage < usage[nodes[best].info.URL] {
+			best = i
+		}
+	}
+	if best < 0 {
+		return 0, 0, errors.New("no eligible nodes")
+	}
+	return best, usage[nodes[best].info.URL], nil
+}
+
+// nodeUsage returns the usage ratios of the nodes by their URLs. The nodes are asked without
+// holding the lock, so that the routing does not wait for them.
+func (p *placement) nodeUsage(ctx context.Context, nodes []*ipfsNode) map[string]float64 {
+	p.mu.Lock()
+	usage, usageTime := p.usage, p.usageTime
+	p.mu.Unlock()
+	if time.Since(usageTime) <= usageTTL && hasUsage(usage, nodes) {
+		return usage
+	}
+
+	usage = make(map[string]float64, len(nodes))
+	for i, node := range nodes {
+		usage[node.info.URL] = -1
+		if node.stater == nil {
+			continue
+		}
+		stat, err := node.stater.RepoStat(ctx)
+		if err != nil {
+			log.WithError(err).WithField("nodeIndex", i).Warn("failed to get repo stat")
+			continue
+		}
+		if stat.StorageMax == 0 {
+			continue
+		}
+		usage[node.info.URL] = float64(stat.RepoSize) / float64(stat.StorageMax)
+	}
+	p.mu.Lock()
+	p.usage, p.usageTime = usage, time.Now()
+	p.mu.Unlock()
+	return usage
+}
+
+// hasUsage tells if the usage was requested from all of the nodes.
+func hasUsage(usage map[string]float64, nodes []*ipfsNode) bool {
+	for _, node := range nodes {
+		if _, ok := usage[node.info.URL]; !ok {
+			return false
+		}
+	}
+	return true
+}
+
+// newPlacementIndex creates the placement index in the shared state, if any, or in the index
+// file otherwise.
+func newPlacementIndex(path string, stateCfg config.SharedStateConfig) (placementIndex, error) {
+	if stateCfg.Provider == config.ProviderRedis {
+		return &storeIndex{
+			client: utils.NewRedisClient(stateCfg.Addr, stateCfg.Password, stateCfg.DB),
+			key:    stateCfg.Prefix + placementKey,
+		}, nil
+	}
+	return newFileIndex(path)
+}
+
+// fileIndex keeps the placement index in memory and saves it to a file, if any, for a single
+// replica.
+type fileIndex struct {
+	path string
+
+	mu    sync.Mutex
+	nodes map[string]string
+}
+
+func newFileIndex(path string) (*fileIndex, error) {
+	index := &fileIndex{path: path, nodes: make(map[string]string)}
+	if len(path) == 0 {
+		return index, nil
+	}
+	b, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return index, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read the placement index: %v", err)
+	}
+	if err := json.Unmarshal(b, &index.nodes); err != nil {
+		return nil, fmt.Errorf("failed to decode the placement index: %v", err)
+	}
+	return index, nil
+}
+
+func (index *fileIndex) get(ctx context.Context, id string) (string, bool, error) {
+	index.mu.Lock()
+	defer index.mu.Unlock()
+	nodeURL, ok := index.nodes[id]
+	return nodeURL, ok, nil
+}
+
+func (index *fileIndex) add(ctx context.Context, id, nodeURL string) (string, error) {
+	index.mu.Lock()
+	defer index.mu.Unlock()
+	if recorded, ok := index.nodes[id]; ok {
+		return recorded, nil
+	}
+	index.nodes[id] = nodeURL
+	if len(index.path) == 0 {
+		return nodeURL, nil
+	}
+	if err := index.save(); err != nil {
+		return "", err
+	}
+	return nodeURL, nil
+}
+
+func (index *fileIndex) save() error {
+	b, err := json.Marshal(index.nodes)
+	if err != nil {
+		return err
+	}
+	tmpPath := index.path + ".tmp"
+	if err := os.MkdirAll(filepath.Dir(index.path), 0755); err != nil {
+		return err
+	}
+	if err := os.WriteFile(tmpPath, b, 0644); err != nil {
+		return err
+	}
+	return os.Rename(tmpPath, index.path)
+}
+
+// storeClient sends the commands to the shared store, e.g. utils.RedisClient.
+type storeClient interface {
+	Do(ctx context.Context, args ...string) (interface{}, error)
+}
+
+// storeIndex keeps the placement index in a hash in the shared store, so that the replicas
+// route the repositories to the same nodes.
+type storeIndex struct {
+	client storeClient
+	key    string
+}
+
+func (index *storeIndex) get(ctx context.Context, id string) (string, bool, error) {
+	ctx, cancel := context.WithTimeout(ctx, placementTimeout)
+	defer cancel()
+	reply, err := index.client.Do(ctx, "HGET", index.key, id)
+	if err != nil || reply == nil {
+		return "", false, err
+	}
+	nodeURL, _ := reply.(string)
+	return nodeURL, true, nil
+}
+
+func (index *storeIndex) add(ctx context.Context, id, nodeURL string) (string, error) {
+	ctx, cancel := context.WithTimeout(ctx, placementTimeout)
+	defer cancel()
+	reply, err := index.client.Do(ctx, "HSETNX", index.key, id, nodeURL)
+	if err != nil {
+		return "", err
+	}
+	if added, _ := reply.(int64); added == 1 {
+		return nodeURL, nil
+	}
+	// placed by another replica
+	reply, err = index.client.Do(ctx, "HGET", index.key, id)
+	if err != nil {
+		return "", err
+	}
+	recorded, _ := reply.(string)
+	return recorded, nil
+}
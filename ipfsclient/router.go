@@ -24,7 +24,10 @@ func NewRouter(nodeCount int) *Router {
 // load-balance/multiplex:
 //   - .../repositories/*
 //   - .../blobs/*
-//   - .../uploads/* (original path from distribution server: .../repositories/<repo>/_uploads/*)
+//   - .../uploads/repos/<repo>/* (original path from distribution server: .../repositories/<repo>/_uploads/*)
+//
+// The uploads are routed by their UUIDs. The uploads in .../uploads/<uuid>, which were started
+// before the uploads were kept per repository, are routed in the same way.
 func (router *Router) RouteContent(path string) (string, int, error) {
 	segments := strings.Split(path[1:], "/") // exclude leading slash
 	if len(segments) < 5 {
@@ -39,9 +42,15 @@ func (router *Router) RouteContent(path string) (string, int, error) {
 
 	var id string
 	switch segments[0] {
-	case "repositories", "uploads": // repository name, upload UUID
+	case "repositories": // repository name
 		id = segments[1]
 
+	case "uploads": // upload UUID after the repository dir e.g. .../uploads/repos/myrepo/<uuid>
+		id = segments[1]
+		if id == "repos" && len(segments) > 3 {
+			id = segments[3]
+		}
+
 	case "blobs": // blob hash after the bucket dir e.g. .../sha256/a8/a8b19f...
 		id = segments[3]
 
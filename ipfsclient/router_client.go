@@ -5,10 +5,12 @@ import (
 	"fmt"
 	"io"
 	"net/http"
+	"sync"
 
 	"github.com/forta-network/disco/config"
 	"github.com/forta-network/disco/interfaces"
 	"github.com/forta-network/disco/utils"
+	"github.com/hashicorp/go-multierror"
 	ipfsapi "github.com/ipfs/go-ipfs-api"
 	log "github.com/sirupsen/logrus"
 )
@@ -16,41 +18,98 @@ import (
 // RouterClient implements the client interface to route the requests to multiple
 // IPFS nodes.
 type RouterClient struct {
-	router *Router
-	nodes  []*ipfsNode
+	mu         sync.RWMutex
+	router     *Router
+	nodes      []*ipfsNode
+	placement  *placement
+	httpClient *http.Client
+	stats      *statCache
 }
 
 type ipfsNode struct {
 	info   *config.Node
 	client interfaces.IPFSFilesAPI
+	stater repoStater
 }
 
-// NewRouterClient creates a new router client. Files client implementation
-// methods look for a client for a specific content provider (node) at read operations in general.
-func NewRouterClient(routerCfg *config.RouterConfig) *RouterClient {
+// NewRouterClient creates a new router client which connects to the nodes with given HTTP client.
+// Files client implementation methods look for a client for a specific content provider (node)
+// at read operations in general. The placement index is kept in the shared state, if any.
+func NewRouterClient(routerCfg *config.RouterConfig, stateCfg config.SharedStateConfig, httpClient *http.Client) *RouterClient {
+	client := &RouterClient{httpClient: httpClient}
+	if statCfg := routerCfg.StatCache; !statCfg.Disabled {
+		client.stats = newStatCache(statCfg.TTL, statCfg.Size)
+	}
+	client.SetNodes(routerCfg.Nodes)
+	if routerCfg.Placement == config.PlacementUsage {
+		index, err := newPlacementIndex(routerCfg.PlacementIndex, stateCfg)
+		if err != nil {
+			log.WithError(err).Error("failed to initialize usage-aware placement - using hash routing")
+		} else {
+			client.placement = newPlacement(index, routerCfg.MaxUsage)
+		}
+	}
+	return client
+}
+
+// SetNodes replaces the nodes which the content is routed to. The clients which were
+// handed out before keep working with the previous nodes until their requests are done. The
+// config reload keeps the number and the positions of the nodes, so the content is routed to
+// the same positions.
+func (client *RouterClient) SetNodes(nodes []*config.Node) {
 	var ipfsNodes []*ipfsNode
-	for _, node := range routerCfg.Nodes {
+	for _, node := range nodes {
+		nodeClient := NewClientWithHTTPClient(node.URL, client.httpClient)
+		var filesClient interfaces.IPFSFilesAPI = nodeClient
+		if client.stats != nil {
+			filesClient = &statCachingClient{IPFSFilesAPI: nodeClient, cache: client.stats}
+		}
 		ipfsNodes = append(ipfsNodes, &ipfsNode{
 			info:   node,
-			client: ipfsapi.NewShellWithClient(node.URL, http.DefaultClient),
+			client: filesClient,
+			stater: nodeClient,
 		})
 	}
-	return &RouterClient{
-		router: NewRouter(len(ipfsNodes)),
-		nodes:  ipfsNodes,
+	// the paths can be routed to other nodes now
+	if client.stats != nil {
+		client.stats.clear()
 	}
+	client.mu.Lock()
+	client.router = NewRouter(len(ipfsNodes))
+	client.nodes = ipfsNodes
+	client.mu.Unlock()
 }
 
-// GetClientFor returns a client for a node which given content path should point to.
+// GetClientFor returns a client for a node which given content path should point to. The new
+// repositories are not placed, so the content should only be read with the client.
 func (client *RouterClient) GetClientFor(ctx context.Context, path string) (interfaces.IPFSFilesAPI, error) {
-	log.Debugf("GetClientFor(%s)", path)
+	utils.Logger(ctx).WithField("path", path).Debug("GetClientFor")
+	return client.route(ctx, path, false)
+}
 
-	id, index, err := client.router.RouteContent(path)
+// GetClientForWrite returns a client for a node which given content path should point to. The
+// content is written with the client, so the new repositories are placed.
+func (client *RouterClient) GetClientForWrite(ctx context.Context, path string) (interfaces.IPFSFilesAPI, error) {
+	utils.Logger(ctx).WithField("path", path).Debug("GetClientForWrite")
+	return client.route(ctx, path, true)
+}
+
+// route returns the client of the node which the path is routed to. The new repositories are
+// placed only if the path is written.
+func (client *RouterClient) route(ctx context.Context, path string, write bool) (interfaces.IPFSFilesAPI, error) {
+	client.mu.RLock()
+	router, nodes := client.router, client.nodes
+	client.mu.RUnlock()
+
+	id, index, err := router.RouteContent(path)
 	if err != nil {
 		return nil, err
 	}
-	node := client.nodes[index]
-	log.WithFields(log.Fields{
+	if client.placement != nil && isRepositoryPath(path) {
+		index = client.placement.route(ctx, nodes, id, index, write)
+	}
+	node := nodes[index]
+	utils.Logger(ctx).WithFields(log.Fields{
 		"mfsPath":           path,
 		"originalContentId": id,
 		"routedNodeIndex":   index,
@@ -59,10 +118,37 @@ func (client *RouterClient) GetClientFor(ctx context.Context, path string) (inte
 	return node.client, err
 }
 
+// SameNode tells if the source and the destination paths are routed to the same node, so that
+// the content is moved without copying it between the nodes.
+func (client *RouterClient) SameNode(ctx context.Context, src string, dest string) (bool, error) {
+	srcClient, err := client.route(ctx, src, false)
+	if err != nil {
+		return false, err
+	}
+	destClient, err := client.route(ctx, dest, true)
+	if err != nil {
+		return false, err
+	}
+	return srcClient == destClient, nil
+}
+
+// NodeClients returns the clients of all nodes.
+func (client *RouterClient) NodeClients() []interfaces.IPFSFilesAPI {
+	client.mu.RLock()
+	nodes := client.nodes
+	client.mu.RUnlock()
+
+	var clients []interfaces.IPFSFilesAPI
+	for _, node := range nodes {
+		clients = append(clients, node.client)
+	}
+	return clients
+}
+
 // FilesRead implements the interface.
 func (client *RouterClient) FilesRead(ctx context.Context, path string, options ...ipfsapi.FilesOpt) (io.ReadCloser, error) {
-	log.Debugf("FilesRead(%s, ...)", path)
-	c, err := client.GetClientFor(ctx, path)
+	utils.Logger(ctx).WithField("path", path).Debug("FilesRead")
+	c, err := client.route(ctx, path, false)
 	if err != nil {
 		return nil, err
 	}
@@ -71,8 +157,8 @@ func (client *RouterClient) FilesRead(ctx context.Context, path string, options
 
 // FilesWrite implements the interface.
 func (client *RouterClient) FilesWrite(ctx context.Context, path string, data io.Reader, options ...ipfsapi.FilesOpt) error {
-	log.Debugf("FilesWrite(%s, _, ...)", path)
-	c, err := client.GetClientFor(ctx, path)
+	utils.Logger(ctx).WithField("path", path).Debug("FilesWrite")
+	c, err := client.route(ctx, path, true)
 	if err != nil {
 		return err
 	}
@@ -81,8 +167,8 @@ func (client *RouterClient) FilesWrite(ctx context.Context, path string, data io
 
 // FilesRm implements the interface.
 func (client *RouterClient) FilesRm(ctx context.Context, path string, force bool) error {
-	log.Debugf("FilesRm(%s, %t)", path, force)
-	c, err := client.GetClientFor(ctx, path)
+	utils.Logger(ctx).WithFields(log.Fields{"path": path, "force": force}).Debug("FilesRm")
+	c, err := client.route(ctx, path, false)
 	if err != nil {
 		return err
 	}
@@ -91,7 +177,7 @@ func (client *RouterClient) FilesRm(ctx context.Context, path string, force bool
 
 // FilesCp implements the interface.
 func (client *RouterClient) FilesCp(ctx context.Context, src string, dest string) error {
-	log.Debugf("FilesCp(%s, %s)", src, dest)
+	utils.Logger(ctx).WithFields(log.Fields{"src": src, "dest": dest}).Debug("FilesCp")
 	// find the IPFS path if this is an fs path
 	if !utils.IsIPFSPath(src) {
 		stat, err := client.FilesStat(ctx, src)
@@ -101,7 +187,7 @@ func (client *RouterClient) FilesCp(ctx context.Context, src string, dest string
 		src = fmt.Sprintf("/ipfs/%s", stat.Hash)
 	}
 	// get dest node client and copy there
-	c, err := client.GetClientFor(ctx, dest)
+	c, err := client.route(ctx, dest, true)
 	if err != nil {
 		return err
 	}
@@ -110,8 +196,8 @@ func (client *RouterClient) FilesCp(ctx context.Context, src string, dest string
 
 // FilesStat implements the interface.
 func (client *RouterClient) FilesStat(ctx context.Context, path string, options ...ipfsapi.FilesOpt) (*ipfsapi.FilesStatObject, error) {
-	log.Debugf("FilesStat(%s, ...)", path)
-	c, err := client.GetClientFor(ctx, path)
+	utils.Logger(ctx).WithField("path", path).Debug("FilesStat")
+	c, err := client.route(ctx, path, false)
 	if err != nil {
 		return nil, err
 	}
@@ -120,8 +206,8 @@ func (client *RouterClient) FilesStat(ctx context.Context, path string, options
 
 // FilesMkdir implements the interface.
 func (client *RouterClient) FilesMkdir(ctx context.Context, path string, options ...ipfsapi.FilesOpt) error {
-	log.Debugf("FilesMkdir(%s, ...)", path)
-	c, err := client.GetClientFor(ctx, path)
+	utils.Logger(ctx).WithField("path", path).Debug("FilesMkdir")
+	c, err := client.route(ctx, path, true)
 	if err != nil {
 		return err
 	}
@@ -130,8 +216,8 @@ func (client *RouterClient) FilesMkdir(ctx context.Context, path string, options
 
 // FilesLs implements the interface.
 func (client *RouterClient) FilesLs(ctx context.Context, path string, options ...ipfsapi.FilesOpt) ([]*ipfsapi.MfsLsEntry, error) {
-	log.Debugf("FilesLs(%s, ...)", path)
-	c, err := client.GetClientFor(ctx, path)
+	utils.Logger(ctx).WithField("path", path).Debug("FilesLs")
+	c, err := client.route(ctx, path, false)
 	if err != nil {
 		return nil, err
 	}
@@ -144,13 +230,13 @@ func (client *RouterClient) FilesLs(ctx context.Context, path string, options ..
 // different. If they are the same, we can continue by doing FilesMv(). If not, we need to
 // copy from first to second and then remove from the first.
 func (client *RouterClient) FilesMv(ctx context.Context, src string, dest string) error {
-	log.Debugf("FilesMv(%s, %s)", src, dest)
+	utils.Logger(ctx).WithFields(log.Fields{"src": src, "dest": dest}).Debug("FilesMv")
 
-	srcClient, err := client.GetClientFor(ctx, src)
+	srcClient, err := client.route(ctx, src, false)
 	if err != nil {
 		return err
 	}
-	destClient, err := client.GetClientFor(ctx, dest)
+	destClient, err := client.route(ctx, dest, true)
 	if err != nil {
 		return err
 	}
@@ -165,3 +251,93 @@ func (client *RouterClient) FilesMv(ctx context.Context, src string, dest string
 	}
 	return srcClient.FilesRm(ctx, src, true)
 }
+
+// Cat implements the interface. The content is read from the network through the first node.
+func (client *RouterClient) Cat(ctx context.Context, path string) (io.ReadCloser, error) {
+	utils.Logger(ctx).WithField("path", path).Debug("Cat")
+	c, err := client.firstClient()
+	if err != nil {
+		return nil, err
+	}
+	return c.Cat(ctx, path)
+}
+
+// Pin implements the interface. The content is pinned in all of the nodes since the IPFS paths
+// cannot be routed.
+func (client *RouterClient) Pin(ctx context.Context, path string) error {
+	utils.Logger(ctx).WithField("path", path).Debug("Pin")
+	var errs *multierror.Error
+	for i, c := range client.NodeClients() {
+		if err := c.Pin(ctx, path); err != nil {
+			errs = multierror.Append(errs, fmt.Errorf("node #%d: %v", i, err))
+		}
+	}
+	return errs.ErrorOrNil()
+}
+
+// Unpin implements the interface. The pins are removed from all of the nodes.
+func (client *RouterClient) Unpin(ctx context.Context, path string) error {
+	utils.Logger(ctx).WithField("path", path).Debug("Unpin")
+	var errs *multierror.Error
+	for i, c := range client.NodeClients() {
+		if err := c.Unpin(ctx, path); err != nil {
+			errs = multierror.Append(errs, fmt.Errorf("node #%d: %v", i, err))
+		}
+	}
+	return errs.ErrorOrNil()
+}
+
+// PinLs implements the interface. It returns the pins from all of the nodes.
+func (client *RouterClient) PinLs(ctx context.Context, path string) (map[string]string, error) {
+	utils.Logger(ctx).WithField("path", path).Debug("PinLs")
+	pins := make(map[string]string)
+	for i, c := range client.NodeClients() {
+		nodePins, err := c.PinLs(ctx, path)
+		if err != nil {
+			return nil, fmt.Errorf("node #%d: %v", i, err)
+		}
+		for cid, pinType := range nodePins {
+			pins[cid] = pinType
+		}
+	}
+	return pins, nil
+}
+
+// PinRemote implements the interface. The remote service is requested through the first node.
+func (client *RouterClient) PinRemote(ctx context.Context, service, path, name string) error {
+	utils.Logger(ctx).WithFields(log.Fields{"service": service, "path": path, "name": name}).Debug("PinRemote")
+	c, err := client.firstClient()
+	if err != nil {
+		return err
+	}
+	return c.PinRemote(ctx, service, path, name)
+}
+
+// UnpinRemote implements the interface. The remote service is requested through the first node.
+func (client *RouterClient) UnpinRemote(ctx context.Context, service, cid string) error {
+	utils.Logger(ctx).WithFields(log.Fields{"service": service, "cid": cid}).Debug("UnpinRemote")
+	c, err := client.firstClient()
+	if err != nil {
+		return err
+	}
+	return c.UnpinRemote(ctx, service, cid)
+}
+
+// NamePublish implements the interface. The path is published through the first node, since each
+// node has its own keys.
+func (client *RouterClient) NamePublish(ctx context.Context, path, key string) (string, error) {
+	utils.Logger(ctx).WithFields(log.Fields{"path": path, "key": key}).Debug("NamePublish")
+	c, err := client.firstClient()
+	if err != nil {
+		return "", err
+	}
+	return c.NamePublish(ctx, path, key)
+}
+
+func (client *RouterClient) firstClient() (interfaces.IPFSFilesAPI, error) {
+	clients := client.NodeClients()
+	if len(clients) == 0 {
+		return nil, fmt.Errorf("no ipfs nodes")
+	}
+	return clients[0], nil
+}
@@ -3,9 +3,13 @@ package ipfsclient
 import (
 	"bytes"
 	"context"
+	"errors"
 	"io"
+	"sync"
 	"testing"
+	"time"
 
+	"github.com/forta-network/disco/config"
 	mock_interfaces "github.com/forta-network/disco/interfaces/mocks"
 	"github.com/golang/mock/gomock"
 	ipfsapi "github.com/ipfs/go-ipfs-api"
@@ -44,9 +48,11 @@ func (s *RouterTestSuite) SetupTest() {
 		router: NewRouter(2),
 		nodes: []*ipfsNode{
 			{
+				info:   &config.Node{URL: "http://localhost:5001"},
 				client: s.ipfsClient1,
 			},
 			{
+				info:   &config.Node{URL: "http://localhost:5002"},
 				client: s.ipfsClient2,
 			},
 		},
@@ -124,3 +130,254 @@ func (s *RouterTestSuite) TestFilesMv() {
 
 	s.r.NoError(s.routerClient.FilesMv(context.Background(), testPath1, testPath2))
 }
+
+func (s *RouterTestSuite) TestSameNode() {
+	sameNode, err := s.routerClient.SameNode(context.Background(), testPath1+"/_uploads/1234/data", testPath1+"/_layers/link")
+	s.r.NoError(err)
+	s.r.True(sameNode)
+
+	sameNode, err = s.routerClient.SameNode(context.Background(), testPath1, testPath2)
+	s.r.NoError(err)
+	s.r.False(sameNode)
+}
+
+type testStater struct {
+	stat *RepoStatObject
+}
+
+func (ts *testStater) RepoStat(ctx context.Context) (*RepoStatObject, error) {
+	return ts.stat, nil
+}
+
+func (s *RouterTestSuite) TestGetClientFor_UsagePlacement() {
+	index, err := newFileIndex("")
+	s.r.NoError(err)
+	s.routerClient.placement = newPlacement(index, 0.9)
+	s.routerClient.nodes[0].stater = &testStater{stat: &RepoStatObject{RepoSize: 80, StorageMax: 100}}
+	s.routerClient.nodes[1].stater = &testStater{stat: &RepoStatObject{RepoSize: 10, StorageMax: 100}}
+
+	// new repository is placed on the least-full node instead of the hashed one
+	s.ipfsClient1.EXPECT().FilesStat(gomock.Any(), testPath1).Return(nil, errors.New("file does not exist"))
+	client, err := s.routerClient.GetClientForWrite(context.Background(), testPath1+"/_manifests")
+	s.r.NoError(err)
+	s.r.Equal(s.ipfsClient2, client)
+
+	// and the decision is remembered
+	client, err = s.routerClient.GetClientFor(context.Background(), testPath1)
+	s.r.NoError(err)
+	s.r.Equal(s.ipfsClient2, client)
+
+	// blobs are still routed by hashing
+	client, err = s.routerClient.GetClientFor(context.Background(), "/docker/registry/v2/blobs/sha256/aa/aa/data")
+	s.r.NoError(err)
+	s.r.Equal(s.ipfsClient1, client)
+}
+
+func (s *RouterTestSuite) TestPin() {
+	s.ipfsClient1.EXPECT().Pin(gomock.Any(), testCidPath).Return(nil)
+	s.ipfsClient2.EXPECT().Pin(gomock.Any(), testCidPath).Return(nil)
+
+	s.r.NoError(s.routerClient.Pin(context.Background(), testCidPath))
+}
+
+func (s *RouterTestSuite) TestUnpin() {
+	s.ipfsClient1.EXPECT().Unpin(gomock.Any(), testCidPath).Return(nil)
+	s.ipfsClient2.EXPECT().Unpin(gomock.Any(), testCidPath).Return(errors.New("not pinned or pinned indirectly"))
+
+	s.r.Error(s.routerClient.Unpin(context.Background(), testCidPath))
+}
+
+func (s *RouterTestSuite) TestPinLs() {
+	s.ipfsClient1.EXPECT().PinLs(gomock.Any(), "").Return(map[string]string{testCid: "recursive"}, nil)
+	s.ipfsClient2.EXPECT().PinLs(gomock.Any(), "").Return(map[string]string{"bafy": "recursive"}, nil)
+
+	pins, err := s.routerClient.PinLs(context.Background(), "")
+	s.r.NoError(err)
+	s.r.Equal(map[string]string{testCid: "recursive", "bafy": "recursive"}, pins)
+}
+
+func (s *RouterTestSuite) TestPinRemote() {
+	s.ipfsClient1.EXPECT().PinRemote(gomock.Any(), "pinata", testCidPath, "myrepo").Return(nil)
+
+	s.r.NoError(s.routerClient.PinRemote(context.Background(), "pinata", testCidPath, "myrepo"))
+}
+
+func (s *RouterTestSuite) TestNamePublish() {
+	s.ipfsClient1.EXPECT().NamePublish(gomock.Any(), testCidPath, "disco").Return("k51", nil)
+
+	name, err := s.routerClient.NamePublish(context.Background(), testCidPath, "disco")
+	s.r.NoError(err)
+	s.r.Equal("k51", name)
+}
+
+func (s *RouterTestSuite) TestGetClientFor_UsagePlacementExisting() {
+	index, err := newFileIndex("")
+	s.r.NoError(err)
+	s.routerClient.placement = newPlacement(index, 0)
+
+	// existing repository stays on the hashed node
+	s.ipfsClient1.EXPECT().FilesStat(gomock.Any(), testPath1).Return(&ipfsapi.FilesStatObject{}, nil)
+	client, err := s.routerClient.GetClientForWrite(context.Background(), testPath1)
+	s.r.NoError(err)
+	s.r.Equal(s.ipfsClient1, client)
+}
+
+func (s *RouterTestSuite) TestFilesStat_UsagePlacementRead() {
+	index, err := newFileIndex("")
+	s.r.NoError(err)
+	s.routerClient.placement = newPlacement(index, 0)
+	s.routerClient.nodes[1].stater = &testStater{stat: &RepoStatObject{RepoSize: 10, StorageMax: 100}}
+
+	// reading a missing repository should not place it
+	s.ipfsClient1.EXPECT().FilesStat(gomock.Any(), testPath1).Return(nil, errors.New("file does not exist"))
+	_, err = s.routerClient.FilesStat(context.Background(), testPath1)
+	s.r.Error(err)
+	_, ok, err := index.get(context.Background(), "aa")
+	s.r.NoError(err)
+	s.r.False(ok)
+}
+
+func (s *RouterTestSuite) TestGetClientFor_UsagePlacementRead() {
+	index, err := newFileIndex("")
+	s.r.NoError(err)
+	s.routerClient.placement = newPlacement(index, 0)
+	s.routerClient.nodes[1].stater = &testStater{stat: &RepoStatObject{RepoSize: 10, StorageMax: 100}}
+
+	// the client for reading a new repository should be the hashed one without placing it
+	client, err := s.routerClient.GetClientFor(context.Background(), testPath1)
+	s.r.NoError(err)
+	s.r.Equal(s.ipfsClient1, client)
+	_, ok, err := index.get(context.Background(), "aa")
+	s.r.NoError(err)
+	s.r.False(ok)
+}
+
+// blockingStater reports the usage after it is released.
+type blockingStater struct {
+	called  chan struct{}
+	release chan struct{}
+}
+
+func (bs *blockingStater) RepoStat(ctx context.Context) (*RepoStatObject, error) {
+	close(bs.called)
+	<-bs.release
+	return &RepoStatObject{RepoSize: 10, StorageMax: 100}, nil
+}
+
+func (s *RouterTestSuite) TestGetClientFor_UsagePlacementConcurrent() {
+	index, err := newFileIndex("")
+	s.r.NoError(err)
+	s.routerClient.placement = newPlacement(index, 0)
+	stater := &blockingStater{called: make(chan struct{}), release: make(chan struct{})}
+	s.routerClient.nodes[1].stater = stater
+	_, err = index.add(context.Background(), "ac", "http://localhost:5002")
+	s.r.NoError(err)
+
+	// given that a new repository is being placed
+	s.ipfsClient1.EXPECT().FilesStat(gomock.Any(), testPath1).Return(nil, errors.New("file does not exist"))
+	placed := make(chan interface{})
+	go func() {
+		client, _ := s.routerClient.GetClientForWrite(context.Background(), testPath1)
+		placed <- client
+	}()
+	<-stater.called
+
+	// then the placed repositories should be routed while the nodes are asked for the usage
+	client, err := s.routerClient.GetClientFor(context.Background(), testPath2)
+	s.r.NoError(err)
+	s.r.Equal(s.ipfsClient2, client)
+
+	close(stater.release)
+	s.r.Equal(s.ipfsClient2, <-placed)
+}
+
+// testStore is a shared store which supports the hash commands of the placement index.
+type testStore struct {
+	mu     sync.Mutex
+	fields map[string]string
+}
+
+func (ts *testStore) Do(ctx context.Context, args ...string) (interface{}, error) {
+	ts.mu.Lock()
+	defer ts.mu.Unlock()
+	field := args[1] + "/" + args[2]
+	switch args[0] {
+	case "HGET":
+		value, ok := ts.fields[field]
+		if !ok {
+			return nil, nil
+		}
+		return value, nil
+	case "HSETNX":
+		if _, ok := ts.fields[field]; ok {
+			return int64(0), nil
+		}
+		ts.fields[field] = args[3]
+		return int64(1), nil
+	}
+	return nil, errors.New("unknown command")
+}
+
+func TestPlacement_SharedIndex(t *testing.T) {
+	r := require.New(t)
+
+	ctrl := gomock.NewController(t)
+	client1 := mock_interfaces.NewMockIPFSFilesAPI(ctrl)
+	client2 := mock_interfaces.NewMockIPFSFilesAPI(ctrl)
+	node1 := &config.Node{URL: "http://localhost:5001"}
+	node2 := &config.Node{URL: "http://localhost:5002"}
+	store := &testStore{fields: make(map[string]string)}
+
+	// given two replicas which share the placement index and list the nodes differently
+	replica1 := newPlacement(&storeIndex{client: store, key: "disco/placements"}, 0)
+	nodes1 := []*ipfsNode{
+		{info: node1, client: client1, stater: &testStater{stat: &RepoStatObject{RepoSize: 80, StorageMax: 100}}},
+		{info: node2, client: client2, stater: &testStater{stat: &RepoStatObject{RepoSize: 10, StorageMax: 100}}},
+	}
+	replica2 := newPlacement(&storeIndex{client: store, key: "disco/placements"}, 0)
+	nodes2 := []*ipfsNode{{info: node2, client: client2}, {info: node1, client: client1}}
+
+	// when a new repository is placed by the first replica
+	client1.EXPECT().FilesStat(gomock.Any(), testPath1).Return(nil, errors.New("file does not exist"))
+	r.Equal(1, replica1.route(context.Background(), nodes1, "aa", 0, true))
+	r.Equal("http://localhost:5002", store.fields["disco/placements/aa"])
+
+	// then the second replica should route it to the same node
+	r.Equal(0, replica2.route(context.Background(), nodes2, "aa", 1, false))
+	r.Equal(0, replica2.route(context.Background(), nodes2, "aa", 1, true))
+}
+
+func (s *RouterTestSuite) TestFilesStat_Cache() {
+	cache := newStatCache(time.Minute, 2)
+	for _, node := range s.routerClient.nodes {
+		node.client = &statCachingClient{IPFSFilesAPI: node.client, cache: cache}
+	}
+	ctx := context.Background()
+	filePath := testPath1 + "/_manifests/tags/latest/current/link"
+
+	// the stats should be served from the cache
+	s.ipfsClient1.EXPECT().FilesStat(gomock.Any(), testPath1).Return(&ipfsapi.FilesStatObject{Hash: testCid}, nil).Times(1)
+	for i := 0; i < 2; i++ {
+		stat, err := s.routerClient.FilesStat(ctx, testPath1)
+		s.r.NoError(err)
+		s.r.Equal(testCid, stat.Hash)
+	}
+
+	// and they should be invalidated when a child is written
+	s.ipfsClient1.EXPECT().FilesWrite(gomock.Any(), filePath, gomock.Any()).Return(nil)
+	s.r.NoError(s.routerClient.FilesWrite(ctx, filePath, bytes.NewBufferString("")))
+	s.ipfsClient1.EXPECT().FilesStat(gomock.Any(), testPath1).Return(&ipfsapi.FilesStatObject{Hash: "newcid"}, nil).Times(1)
+	stat, err := s.routerClient.FilesStat(ctx, testPath1)
+	s.r.NoError(err)
+	s.r.Equal("newcid", stat.Hash)
+
+	// and the least recently used stats should be evicted
+	s.ipfsClient2.EXPECT().FilesStat(gomock.Any(), testPath2).Return(&ipfsapi.FilesStatObject{}, nil).Times(1)
+	_, err = s.routerClient.FilesStat(ctx, testPath2)
+	s.r.NoError(err)
+	s.ipfsClient1.EXPECT().FilesStat(gomock.Any(), filePath).Return(&ipfsapi.FilesStatObject{}, nil).Times(1)
+	_, err = s.routerClient.FilesStat(ctx, filePath)
+	s.r.NoError(err)
+	_, ok := cache.get(testPath1)
+	s.r.False(ok)
+}
@@ -25,6 +25,12 @@ func TestRouter(t *testing.T) {
 	r.Equal(1, n)
 	r.Equal("ac", id)
 
+	// the uploads in the repository dirs are routed by their UUIDs, too
+	id, n, err = router.RouteContent("/docker/registry/v2/uploads/repos/myorg+myrepo/ac/data")
+	r.NoError(err)
+	r.Equal(1, n)
+	r.Equal("ac", id)
+
 	id, n, err = router.RouteContent(blobs)
 	r.NoError(err)
 	r.Equal(0, n)
@@ -0,0 +1,156 @@
+package ipfsclient
+
+import (
+	"container/list"
+	"context"
+	"io"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/forta-network/disco/interfaces"
+	ipfsapi "github.com/ipfs/go-ipfs-api"
+)
+
+// statCache keeps the recent stat results of the MFS paths in memory. The least recently used
+// results are evicted after the size is reached.
+type statCache struct {
+	ttl  time.Duration
+	size int
+
+	mu      sync.Mutex
+	entries map[string]*list.Element
+	order   *list.List
+}
+
+type statEntry struct {
+	path    string
+	stat    ipfsapi.FilesStatObject
+	expires time.Time
+}
+
+func newStatCache(ttl time.Duration, size int) *statCache {
+	return &statCache{
+		ttl:     ttl,
+		size:    size,
+		entries: make(map[string]*list.Element),
+		order:   list.New(),
+	}
+}
+
+func (cache *statCache) get(path string) (*ipfsapi.FilesStatObject, bool) {
+	cache.mu.Lock()
+	defer cache.mu.Unlock()
+	elem, ok := cache.entries[path]
+	if !ok {
+		return nil, false
+	}
+	entry := elem.Value.(*statEntry)
+	if time.Now().After(entry.expires) {
+		cache.remove(elem)
+		return nil, false
+	}
+	cache.order.MoveToFront(elem)
+	stat := entry.stat
+	return &stat, true
+}
+
+func (cache *statCache) set(path string, stat *ipfsapi.FilesStatObject) {
+	cache.mu.Lock()
+	defer cache.mu.Unlock()
+	entry := &statEntry{path: path, stat: *stat, expires: time.Now().Add(cache.ttl)}
+	if elem, ok := cache.entries[path]; ok {
+		elem.Value = entry
+		cache.order.MoveToFront(elem)
+		return
+	}
+	cache.entries[path] = cache.order.PushFront(entry)
+	for cache.order.Len() > cache.size {
+		cache.remove(cache.order.Back())
+	}
+}
+
+// invalidate removes the stat results of the path, its parents and its children, since the
+// hashes and the sizes of the parent directories change with their contents.
+func (cache *statCache) invalidate(path string) {
+	path = strings.TrimSuffix(path, "/")
+	cache.mu.Lock()
+	defer cache.mu.Unlock()
+	for cachedPath, elem := range cache.entries {
+		if cachedPath == path || cachedPath == "/" ||
+			strings.HasPrefix(path, cachedPath+"/") || strings.HasPrefix(cachedPath, path+"/") {
+			cache.remove(elem)
+		}
+	}
+}
+
+func (cache *statCache) clear() {
+	cache.mu.Lock()
+	defer cache.mu.Unlock()
+	cache.entries = make(map[string]*list.Element)
+	cache.order.Init()
+}
+
+// remove should be called with the lock.
+func (cache *statCache) remove(elem *list.Element) {
+	cache.order.Remove(elem)
+	delete(cache.entries, elem.Value.(*statEntry).path)
+}
+
+// uploadsPath is where the IPFS driver keeps the uploads.
+const uploadsPath = "/docker/registry/v2/uploads/"
+
+// statCachingClient serves the stats of a node from the cache and invalidates them with the
+// writes to the node. The stats with options are not cached, and neither are the stats of the
+// uploads, since their chunks can be written by the other replicas.
+type statCachingClient struct {
+	interfaces.IPFSFilesAPI
+	cache *statCache
+}
+
+// FilesStat implements the interface.
+func (client *statCachingClient) FilesStat(ctx context.Context, path string, options ...ipfsapi.FilesOpt) (*ipfsapi.FilesStatObject, error) {
+	if len(options) > 0 || strings.HasPrefix(path, uploadsPath) {
+		return client.IPFSFilesAPI.FilesStat(ctx, path, options...)
+	}
+	if stat, ok := client.cache.get(path); ok {
+		return stat, nil
+	}
+	stat, err := client.IPFSFilesAPI.FilesStat(ctx, path)
+	if err != nil {
+		return nil, err
+	}
+	client.cache.set(path, stat)
+	return stat, nil
+}
+
+// FilesWrite implements the interface.
+func (client *statCachingClient) FilesWrite(ctx context.Context, path string, data io.Reader, options ...ipfsapi.FilesOpt) error {
+	defer client.cache.invalidate(path)
+	return client.IPFSFilesAPI.FilesWrite(ctx, path, data, options...)
+}
+
+// FilesRm implements the interface.
+func (client *statCachingClient) FilesRm(ctx context.Context, path string, force bool) error {
+	defer client.cache.invalidate(path)
+	return client.IPFSFilesAPI.FilesRm(ctx, path, force)
+}
+
+// FilesCp implements the interface.
+func (client *statCachingClient) FilesCp(ctx context.Context, src string, dest string) error {
+	defer client.cache.invalidate(dest)
+	return client.IPFSFilesAPI.FilesCp(ctx, src, dest)
+}
+
+// FilesMkdir implements the interface.
+func (client *statCachingClient) FilesMkdir(ctx context.Context, path string, options ...ipfsapi.FilesOpt) error {
+	defer client.cache.invalidate(path)
+	return client.IPFSFilesAPI.FilesMkdir(ctx, path, options...)
+}
+
+// FilesMv implements the interface.
+func (client *statCachingClient) FilesMv(ctx context.Context, src string, dest string) error {
+	defer client.cache.invalidate(dest)
+	defer client.cache.invalidate(src)
+	return client.IPFSFilesAPI.FilesMv(ctx, src, dest)
+}
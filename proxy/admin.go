@@ -0,0 +1,445 @@
+package proxy
+
+import (
+	"context"
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/http/httputil"
+	"net/http/pprof"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/forta-network/disco/config"
+	"github.com/forta-network/disco/drivers"
+	"github.com/forta-network/disco/proxy/services"
+	"github.com/forta-network/disco/utils"
+	log "github.com/sirupsen/logrus"
+)
+
+const (
+	// adminPathPrefix is the path prefix of the admin API.
+	adminPathPrefix = "/disco/"
+	// defaultMetricsPath is the default metrics path of the registry.
+	defaultMetricsPath = "/metrics"
+)
+
+// adminService is the Disco service which the admin API uses.
+type adminService interface {
+	// Replicate replicates the content between the IPFS nodes and the cache.
+	Replicate(ctx context.Context, target, to string) ([]string, error)
+	// PrefetchRepos clones and replicates the CID v1 repositories in the background.
+	PrefetchRepos(repoNames []string) ([]string, error)
+	// CloneProgress returns the progress of the repositories which are being cloned.
+	CloneProgress() []*services.CloneProgress
+	// RepoStats computes the statistics of a repository.
+	RepoStats(ctx context.Context, repoName string) (*services.RepoStats, error)
+	// ListRepoStats computes the statistics of all of the global repositories.
+	ListRepoStats(ctx context.Context) ([]*services.RepoStats, error)
+	// DeleteGlobalRepo deletes the CID v1 repository and the blobs which only it references.
+	DeleteGlobalRepo(ctx context.Context, repoCid string) (*services.DeletedRepo, error)
+	// ListSnapshots returns the snapshots of the registry from the oldest to the latest.
+	ListSnapshots(ctx context.Context) ([]*services.Snapshot, error)
+	// Tree walks a store from the root and returns its files with their sizes.
+	Tree(ctx context.Context, store, root string) ([]*drivers.TreeFile, error)
+	// ListUploads returns the uploads in the IPFS nodes and the cache with their staleness.
+	ListUploads(ctx context.Context, olderThan time.Duration) ([]*services.Upload, error)
+	// BandwidthUsage returns the bytes served per repository and per client in the current month.
+	BandwidthUsage() *services.BandwidthUsage
+	// APIKeysEnabled tells if the requests should be authorized with the API keys.
+	APIKeysEnabled() bool
+	// CheckAPIKey checks that the secret key is valid and has the scope.
+	CheckAPIKey(secretKey, scope string) error
+	// CreateAPIKey creates an API key and returns it with its secret key.
+	CreateAPIKey(name string, scopes []string) (*services.APIKey, string, error)
+	// RevokeAPIKey deletes the API key with the ID.
+	RevokeAPIKey(id string) error
+	// ListAPIKeys returns the API keys without their secrets.
+	ListAPIKeys() []*services.APIKey
+	// SharedNoncesEnabled tells if the nonces of the signed requests are kept in the shared state.
+	SharedNoncesEnabled() bool
+	// UseNonce records the nonce of a signed request in the shared state until the TTL expires
+	// and returns false if it was used before.
+	UseNonce(nonce string, ttl time.Duration) (bool, error)
+}
+
+// replicateRequest is the body of the replication requests. Either the path or the CID should
+// be set.
+type replicateRequest struct {
+	Path string `json:"path"`
+	Cid  string `json:"cid"`
+	To   string `json:"to"`
+}
+
+// prefetchRequest is the body of the prefetch requests.
+type prefetchRequest struct {
+	Cids []string `json:"cids"`
+}
+
+// prefetchResponse is the body of the accepted prefetch requests.
+type prefetchResponse struct {
+	Prefetching []string `json:"prefetching"`
+}
+
+// replicateResponse is the body of the successful replication responses.
+type replicateResponse struct {
+	Replicated []string `json:"replicated"`
+}
+
+// NewAdmin creates the server which serves the admin API, the health check, the profiles and
+// the registry metrics on the dedicated admin address. It returns nil if the address is not
+// configured. Everything but the health check needs the same credentials as the admin API, so
+// the token, the HMAC secret or the API keys should be configured.
+func NewAdmin(cfg *config.Config, discoService *services.Disco) (*http.Server, error) {
+	if len(cfg.Admin.Addr) == 0 {
+		return nil, nil
+	}
+	if len(cfg.Admin.Token) == 0 && len(cfg.Admin.HMACSecret) == 0 && !discoService.APIKeysEnabled() {
+		return nil, errors.New("the admin address needs the admin token, the HMAC secret or the API keys")
+	}
+	authorize := newAdminAuth(cfg.Admin, discoService)
+	mux := http.NewServeMux()
+	mux.Handle(adminPathPrefix, authorize(newAdminMux(discoService)))
+	mux.HandleFunc("/health", func(rw http.ResponseWriter, r *http.Request) {
+		rw.WriteHeader(http.StatusOK)
+	})
+	debugMux := http.NewServeMux()
+	debugMux.HandleFunc("/debug/pprof/", pprof.Index)
+	debugMux.HandleFunc("/debug/pprof/cmdline", pprof.Cmdline)
+	debugMux.HandleFunc("/debug/pprof/profile", pprof.Profile)
+	debugMux.HandleFunc("/debug/pprof/symbol", pprof.Symbol)
+	debugMux.HandleFunc("/debug/pprof/trace", pprof.Trace)
+	mux.Handle("/debug/pprof/", authorize(debugMux))
+
+	// the metrics are collected by the debug server of the registry
+	debug := cfg.Distribution.HTTP.Debug
+	if len(debug.Addr) > 0 && debug.Prometheus.Enabled {
+		metricsPath := debug.Prometheus.Path
+		if len(metricsPath) == 0 {
+			metricsPath = defaultMetricsPath
+		}
+		metricsURL, err := debugURL(debug.Addr)
+		if err != nil {
+			return nil, fmt.Errorf("invalid debug address: %v", err)
+		}
+		mux.Handle(metricsPath, authorize(httputil.NewSingleHostReverseProxy(metricsURL)))
+	}
+
+	return &http.Server{
+		Addr:         cfg.Admin.Addr,
+		Handler:      mux,
+		ReadTimeout:  cfg.Timeouts.Read,
+		WriteTimeout: cfg.Timeouts.Write,
+		IdleTimeout:  cfg.Timeouts.Idle,
+	}, nil
+}
+
+// debugURL returns the local URL of the debug server of the registry from its listen address,
+// e.g. ":5001", "localhost:5001" or "0.0.0.0:5001".
+func debugURL(addr string) (*url.URL, error) {
+	_, port, err := net.SplitHostPort(addr)
+	if err != nil {
+		return nil, err
+	}
+	return &url.URL{Scheme: "http", Host: net.JoinHostPort("localhost", port)}, nil
+}
+
+// newAdminHandler creates the handler of the admin API which authorizes the requests.
+func newAdminHandler(adminCfg config.AdminConfig, disco adminService) http.Handler {
+	return newAdminAuth(adminCfg, disco)(newAdminMux(disco))
+}
+
+// newAdminMux creates the routes of the admin API.
+func newAdminMux(disco adminService) *http.ServeMux {
+	mux := http.NewServeMux()
+	mux.HandleFunc(adminPathPrefix+"replicate", func(rw http.ResponseWriter, r *http.Request) {
+		handleReplicate(rw, r, disco)
+	})
+	mux.HandleFunc(adminPathPrefix+"prefetch", func(rw http.ResponseWriter, r *http.Request) {
+		handlePrefetch(rw, r, disco)
+	})
+	mux.HandleFunc(adminPathPrefix+"clones", func(rw http.ResponseWriter, r *http.Request) {
+		handleClones(rw, r, disco)
+	})
+	mux.HandleFunc(adminPathPrefix+"repos", func(rw http.ResponseWriter, r *http.Request) {
+		handleRepoStats(rw, r, disco)
+	})
+	mux.HandleFunc(adminPathPrefix+"repos/", func(rw http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodDelete {
+			handleDeleteRepo(rw, r, disco)
+			return
+		}
+		handleRepoStats(rw, r, disco)
+	})
+	mux.HandleFunc(adminPathPrefix+"snapshots", func(rw http.ResponseWriter, r *http.Request) {
+		handleSnapshots(rw, r, disco)
+	})
+	mux.HandleFunc(adminPathPrefix+"tree", func(rw http.ResponseWriter, r *http.Request) {
+		handleTree(rw, r, disco)
+	})
+	mux.HandleFunc(adminPathPrefix+"uploads", func(rw http.ResponseWriter, r *http.Request) {
+		handleUploads(rw, r, disco)
+	})
+	mux.HandleFunc(adminPathPrefix+"bandwidth", func(rw http.ResponseWriter, r *http.Request) {
+		handleBandwidth(rw, r, disco)
+	})
+	mux.HandleFunc(adminPathPrefix+"apikeys", func(rw http.ResponseWriter, r *http.Request) {
+		handleAPIKeys(rw, r, disco)
+	})
+	mux.HandleFunc(adminPathPrefix+"apikeys/", func(rw http.ResponseWriter, r *http.Request) {
+		handleAPIKeys(rw, r, disco)
+	})
+	return mux
+}
+
+// newAdminAuth creates the middleware which authorizes the admin requests. The requests should
+// have the token or an API key with the admin scope as a bearer token if the token is not
+// empty, or they should be signed if the HMAC secret is configured. The handlers which are
+// wrapped with the same middleware share the used nonces of the signed requests.
+func newAdminAuth(adminCfg config.AdminConfig, disco adminService) func(handler http.Handler) http.Handler {
+	token := adminCfg.Token
+	verifier := newRequestVerifier(adminCfg, disco)
+	return func(handler http.Handler) http.Handler {
+		return http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+			if verifier != nil && utils.IsSignedRequest(r) {
+				if err := verifier.verify(r); err != nil {
+					writeRegistryError(rw, http.StatusUnauthorized, "UNAUTHORIZED", "invalid signed request: "+err.Error())
+					return
+				}
+				handler.ServeHTTP(rw, r)
+				return
+			}
+			// the admin API is open only if none of the credentials are configured
+			if len(token) == 0 && verifier == nil && !disco.APIKeysEnabled() {
+				handler.ServeHTTP(rw, r)
+				return
+			}
+			bearer := strings.TrimPrefix(r.Header.Get("Authorization"), "Bearer ")
+			if len(token) > 0 && subtle.ConstantTimeCompare([]byte(bearer), []byte(token)) == 1 {
+				handler.ServeHTTP(rw, r)
+				return
+			}
+			// the api keys with the scope can be used instead of the admin token
+			if disco.APIKeysEnabled() {
+				err := disco.CheckAPIKey(bearer, adminScope(r))
+				if err == nil {
+					handler.ServeHTTP(rw, r)
+					return
+				}
+				if errors.Is(err, services.ErrScopeDenied) {
+					writeRegistryError(rw, http.StatusForbidden, "DENIED", err.Error())
+					return
+				}
+			}
+			writeRegistryError(rw, http.StatusUnauthorized, "UNAUTHORIZED", "invalid admin token")
+		})
+	}
+}
+
+// handleReplicate replicates the requested path or CID v1 repository on demand.
+func handleReplicate(rw http.ResponseWriter, r *http.Request, disco adminService) {
+	if r.Method != http.MethodPost {
+		writeRegistryError(rw, http.StatusMethodNotAllowed, "UNSUPPORTED", "only POST is supported")
+		return
+	}
+	var req replicateRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeRegistryError(rw, http.StatusBadRequest, "BAD_REQUEST", "invalid request body: "+err.Error())
+		return
+	}
+	if (len(req.Path) == 0) == (len(req.Cid) == 0) {
+		writeRegistryError(rw, http.StatusBadRequest, "BAD_REQUEST", "either path or cid should be specified")
+		return
+	}
+	target := req.Path
+	if len(target) == 0 {
+		target = req.Cid
+	}
+	// the paths and the CIDs are told apart by the leading slash
+	if len(req.Path) > 0 != strings.HasPrefix(target, "/") {
+		writeRegistryError(rw, http.StatusBadRequest, "BAD_REQUEST", "the path should be absolute and the cid should not be a path")
+		return
+	}
+	replicated, err := disco.Replicate(r.Context(), target, req.To)
+	if status, code, ok := serviceErrorStatus(err); ok {
+		writeRegistryError(rw, status, code, err.Error())
+		return
+	}
+	if err != nil {
+		writeRegistryError(rw, http.StatusInternalServerError, "REPLICATION_FAILED", err.Error())
+		return
+	}
+	rw.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(rw).Encode(&replicateResponse{Replicated: replicated})
+}
+
+// handlePrefetch starts cloning and replicating the requested CID v1 repositories in the
+// background and responds without waiting for them.
+func handlePrefetch(rw http.ResponseWriter, r *http.Request, disco adminService) {
+	if r.Method != http.MethodPost {
+		writeRegistryError(rw, http.StatusMethodNotAllowed, "UNSUPPORTED", "only POST is supported")
+		return
+	}
+	var req prefetchRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeRegistryError(rw, http.StatusBadRequest, "BAD_REQUEST", "invalid request body: "+err.Error())
+		return
+	}
+	if len(req.Cids) == 0 {
+		writeRegistryError(rw, http.StatusBadRequest, "BAD_REQUEST", "cids should be specified")
+		return
+	}
+	prefetching, err := disco.PrefetchRepos(req.Cids)
+	if err != nil {
+		writeServiceError(rw, err)
+		return
+	}
+	log.WithField("repositories", len(prefetching)).Info("prefetching repositories")
+	rw.Header().Set("Content-Type", "application/json")
+	rw.WriteHeader(http.StatusAccepted)
+	_ = json.NewEncoder(rw).Encode(&prefetchResponse{Prefetching: prefetching})
+}
+
+// handleClones lists the progress of the repositories which are being cloned.
+func handleClones(rw http.ResponseWriter, r *http.Request, disco adminService) {
+	if r.Method != http.MethodGet {
+		writeRegistryError(rw, http.StatusMethodNotAllowed, "UNSUPPORTED", "only GET is supported")
+		return
+	}
+	rw.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(rw).Encode(map[string]interface{}{
+		"clones": disco.CloneProgress(),
+	})
+}
+
+// handleRepoStats returns the statistics of the repository in the path or of all of the global
+// repositories.
+func handleRepoStats(rw http.ResponseWriter, r *http.Request, disco adminService) {
+	if r.Method != http.MethodGet {
+		writeRegistryError(rw, http.StatusMethodNotAllowed, "UNSUPPORTED", "only GET is supported")
+		return
+	}
+	var (
+		resp interface{}
+		err  error
+	)
+	repoName := strings.Trim(strings.TrimPrefix(r.URL.Path, adminPathPrefix+"repos"), "/")
+	if len(repoName) == 0 {
+		var list []*services.RepoStats
+		list, err = disco.ListRepoStats(r.Context())
+		resp = map[string]interface{}{"repositories": list}
+	} else {
+		resp, err = disco.RepoStats(r.Context(), repoName)
+	}
+	if err != nil {
+		log.WithError(err).WithField("repository", repoName).Warn("failed to compute the repository stats")
+		writeServiceError(rw, err)
+		return
+	}
+	rw.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(rw).Encode(resp)
+}
+
+// handleDeleteRepo deletes the CID v1 repository in the path together with its digest repository
+// and the blobs which are not referenced by the other repositories.
+func handleDeleteRepo(rw http.ResponseWriter, r *http.Request, disco adminService) {
+	repoCid := strings.Trim(strings.TrimPrefix(r.URL.Path, adminPathPrefix+"repos"), "/")
+	deleted, err := disco.DeleteGlobalRepo(r.Context(), repoCid)
+	if err != nil {
+		log.WithError(err).WithField("repository", repoCid).Warn("failed to delete the global repository")
+		writeServiceError(rw, err)
+		return
+	}
+	rw.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(rw).Encode(deleted)
+}
+
+// handleSnapshots lists the snapshots of the registry which can be restored.
+func handleSnapshots(rw http.ResponseWriter, r *http.Request, disco adminService) {
+	if r.Method != http.MethodGet {
+		writeRegistryError(rw, http.StatusMethodNotAllowed, "UNSUPPORTED", "only GET is supported")
+		return
+	}
+	snapshots, err := disco.ListSnapshots(r.Context())
+	if err != nil {
+		log.WithError(err).Warn("failed to list the snapshots")
+		writeServiceError(rw, err)
+		return
+	}
+	rw.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(rw).Encode(map[string]interface{}{
+		"snapshots": snapshots,
+	})
+}
+
+// handleBandwidth responds with the bytes served in the current month.
+func handleBandwidth(rw http.ResponseWriter, r *http.Request, disco adminService) {
+	if r.Method != http.MethodGet {
+		writeRegistryError(rw, http.StatusMethodNotAllowed, "UNSUPPORTED", "only GET is supported")
+		return
+	}
+	rw.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(rw).Encode(disco.BandwidthUsage())
+}
+
+// handleTree lists the files of a store with their sizes, so that the stores of the replicas can
+// be compared with `disco diff`.
+func handleTree(rw http.ResponseWriter, r *http.Request, disco adminService) {
+	if r.Method != http.MethodGet {
+		writeRegistryError(rw, http.StatusMethodNotAllowed, "UNSUPPORTED", "only GET is supported")
+		return
+	}
+	query := r.URL.Query()
+	files, err := disco.Tree(r.Context(), query.Get("store"), query.Get("path"))
+	if err != nil {
+		log.WithError(err).Warn("failed to walk the store")
+		writeServiceError(rw, err)
+		return
+	}
+	rw.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(rw).Encode(map[string]interface{}{
+		"files": files,
+	})
+}
+
+// handleUploads lists the uploads in the stores. The uploads which were not written within the
+// age are stale and they are the only ones listed if the stale parameter is true.
+func handleUploads(rw http.ResponseWriter, r *http.Request, disco adminService) {
+	if r.Method != http.MethodGet {
+		writeRegistryError(rw, http.StatusMethodNotAllowed, "UNSUPPORTED", "only GET is supported")
+		return
+	}
+	query := r.URL.Query()
+	olderThan := config.DefaultPruneUploadsAge
+	if value := query.Get("olderThan"); len(value) > 0 {
+		var err error
+		olderThan, err = time.ParseDuration(value)
+		if err != nil || olderThan < 0 {
+			writeRegistryError(rw, http.StatusBadRequest, "BAD_REQUEST", "olderThan should be a positive duration")
+			return
+		}
+	}
+	uploads, err := disco.ListUploads(r.Context(), olderThan)
+	if err != nil {
+		log.WithError(err).Warn("failed to list the uploads")
+		writeServiceError(rw, err)
+		return
+	}
+	if query.Get("stale") == "true" {
+		var stale []*services.Upload
+		for _, upload := range uploads {
+			if upload.Stale {
+				stale = append(stale, upload)
+			}
+		}
+		uploads = stale
+	}
+	rw.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(rw).Encode(map[string]interface{}{
+		"uploads": uploads,
+	})
+}
@@ -0,0 +1,417 @@
+package proxy
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/distribution/distribution/v3/configuration"
+	"github.com/forta-network/disco/config"
+	"github.com/forta-network/disco/drivers"
+	"github.com/forta-network/disco/proxy/services"
+	"github.com/forta-network/disco/utils"
+	"github.com/stretchr/testify/require"
+)
+
+type testAdminService struct {
+	target, to string
+	clones     []*services.CloneProgress
+	prefetched []string
+	snapshots  []*services.Snapshot
+	// nonces are the shared nonces of the replicas, if they are enabled
+	nonces map[string]bool
+}
+
+func (tas *testAdminService) Replicate(ctx context.Context, target, to string) ([]string, error) {
+	tas.target, tas.to = target, to
+	return []string{target}, nil
+}
+
+func (tas *testAdminService) PrefetchRepos(repoNames []string) ([]string, error) {
+	for _, repoName := range repoNames {
+		if repoName != "bafy" {
+			return nil, fmt.Errorf("%w: %s", services.ErrNotCIDName, repoName)
+		}
+	}
+	tas.prefetched = repoNames
+	return repoNames, nil
+}
+
+func (tas *testAdminService) CloneProgress() []*services.CloneProgress {
+	return tas.clones
+}
+
+func (tas *testAdminService) RepoStats(ctx context.Context, repoName string) (*services.RepoStats, error) {
+	if repoName != "bafy" {
+		return nil, fmt.Errorf("%w: %s", services.ErrRepoNotFound, repoName)
+	}
+	return &services.RepoStats{Repository: repoName, BlobCount: 3}, nil
+}
+
+func (tas *testAdminService) ListRepoStats(ctx context.Context) ([]*services.RepoStats, error) {
+	return []*services.RepoStats{{Repository: "bafy", BlobCount: 3}}, nil
+}
+
+func (tas *testAdminService) DeleteGlobalRepo(ctx context.Context, repoCid string) (*services.DeletedRepo, error) {
+	if repoCid != "bafy" {
+		return nil, fmt.Errorf("%w: %s", services.ErrNotCIDName, repoCid)
+	}
+	return &services.DeletedRepo{Cid: repoCid, Digest: "sha256:1234", Blobs: []string{"1234"}, KeptBlobs: 2}, nil
+}
+
+func (tas *testAdminService) ListSnapshots(ctx context.Context) ([]*services.Snapshot, error) {
+	return tas.snapshots, nil
+}
+
+func (tas *testAdminService) Tree(ctx context.Context, store, root string) ([]*drivers.TreeFile, error) {
+	if store != "" {
+		return nil, fmt.Errorf("%w: unknown store '%s'", services.ErrInvalidTree, store)
+	}
+	return []*drivers.TreeFile{{Path: root + "/a", Size: 1}}, nil
+}
+
+func (tas *testAdminService) ListUploads(ctx context.Context, olderThan time.Duration) ([]*services.Upload, error) {
+	return []*services.Upload{
+		{ID: "old-upload", Repository: "myrepo", Store: "cache", Stale: olderThan <= time.Hour},
+		{ID: "new-upload", Repository: "myrepo", Store: "cache"},
+	}, nil
+}
+
+func (tas *testAdminService) BandwidthUsage() *services.BandwidthUsage {
+	return &services.BandwidthUsage{}
+}
+
+func (tas *testAdminService) APIKeysEnabled() bool {
+	return false
+}
+
+func (tas *testAdminService) CheckAPIKey(secretKey, scope string) error {
+	return services.ErrInvalidAPIKey
+}
+
+func (tas *testAdminService) CreateAPIKey(name string, scopes []string) (*services.APIKey, string, error) {
+	return nil, "", errors.New("api keys are not enabled")
+}
+
+func (tas *testAdminService) RevokeAPIKey(id string) error {
+	return errors.New("api keys are not enabled")
+}
+
+func (tas *testAdminService) ListAPIKeys() []*services.APIKey {
+	return nil
+}
+
+func (tas *testAdminService) SharedNoncesEnabled() bool {
+	return tas.nonces != nil
+}
+
+func (tas *testAdminService) UseNonce(nonce string, ttl time.Duration) (bool, error) {
+	if tas.nonces[nonce] {
+		return false, nil
+	}
+	tas.nonces[nonce] = true
+	return true, nil
+}
+
+func TestAdminReplicate(t *testing.T) {
+	r := require.New(t)
+
+	disco := &testAdminService{}
+	handler := newAdminHandler(config.AdminConfig{Token: "secret"}, disco)
+
+	doRequest := func(token, method, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, "/disco/replicate", strings.NewReader(body))
+		req.Header.Set("Authorization", "Bearer "+token)
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+		return rec
+	}
+
+	rec := doRequest("wrong", http.MethodPost, `{"cid":"bafy"}`)
+	r.Equal(http.StatusUnauthorized, rec.Code)
+
+	rec = doRequest("secret", http.MethodGet, "")
+	r.Equal(http.StatusMethodNotAllowed, rec.Code)
+
+	rec = doRequest("secret", http.MethodPost, `{"cid":"bafy","path":"/docker/registry/v2"}`)
+	r.Equal(http.StatusBadRequest, rec.Code)
+
+	rec = doRequest("secret", http.MethodPost, `{"path":"docker/registry/v2"}`)
+	r.Equal(http.StatusBadRequest, rec.Code)
+
+	rec = doRequest("secret", http.MethodPost, `{"cid":"bafy","to":"secondary"}`)
+	r.Equal(http.StatusOK, rec.Code)
+	r.Equal("bafy", disco.target)
+	r.Equal("secondary", disco.to)
+	var resp replicateResponse
+	r.NoError(json.NewDecoder(rec.Body).Decode(&resp))
+	r.Equal([]string{"bafy"}, resp.Replicated)
+}
+
+func TestAdminPrefetch(t *testing.T) {
+	r := require.New(t)
+
+	disco := &testAdminService{}
+	handler := newAdminHandler(config.AdminConfig{}, disco)
+	doRequest := func(method, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, "/disco/prefetch", strings.NewReader(body))
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+		return rec
+	}
+
+	r.Equal(http.StatusMethodNotAllowed, doRequest(http.MethodGet, "").Code)
+	r.Equal(http.StatusBadRequest, doRequest(http.MethodPost, `{"cids":[]}`).Code)
+	r.Equal(http.StatusBadRequest, doRequest(http.MethodPost, `{"cids":["myrepo"]}`).Code)
+
+	rec := doRequest(http.MethodPost, `{"cids":["bafy"]}`)
+	r.Equal(http.StatusAccepted, rec.Code)
+	r.Equal([]string{"bafy"}, disco.prefetched)
+	var resp prefetchResponse
+	r.NoError(json.NewDecoder(rec.Body).Decode(&resp))
+	r.Equal([]string{"bafy"}, resp.Prefetching)
+}
+
+func TestAdminClones(t *testing.T) {
+	r := require.New(t)
+
+	disco := &testAdminService{
+		clones: []*services.CloneProgress{{Repository: "bafy", BlobsTotal: 3, BlobsDone: 1}},
+	}
+	handler := newAdminHandler(config.AdminConfig{}, disco)
+
+	req := httptest.NewRequest(http.MethodGet, "/disco/clones", nil)
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+	r.Equal(http.StatusOK, rec.Code)
+	var resp struct {
+		Clones []*services.CloneProgress `json:"clones"`
+	}
+	r.NoError(json.NewDecoder(rec.Body).Decode(&resp))
+	r.Len(resp.Clones, 1)
+	r.Equal("bafy", resp.Clones[0].Repository)
+	r.Equal(int64(1), resp.Clones[0].BlobsDone)
+}
+
+func TestAdminRepoStats(t *testing.T) {
+	r := require.New(t)
+
+	handler := newAdminHandler(config.AdminConfig{}, &testAdminService{})
+	doRequest := func(path string) *httptest.ResponseRecorder {
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, path, nil))
+		return rec
+	}
+
+	rec := doRequest("/disco/repos/bafy")
+	r.Equal(http.StatusOK, rec.Code)
+	var stats services.RepoStats
+	r.NoError(json.NewDecoder(rec.Body).Decode(&stats))
+	r.Equal("bafy", stats.Repository)
+	r.Equal(3, stats.BlobCount)
+
+	rec = doRequest("/disco/repos")
+	r.Equal(http.StatusOK, rec.Code)
+	var list struct {
+		Repositories []*services.RepoStats `json:"repositories"`
+	}
+	r.NoError(json.NewDecoder(rec.Body).Decode(&list))
+	r.Len(list.Repositories, 1)
+
+	rec = doRequest("/disco/repos/unknown")
+	r.Equal(http.StatusNotFound, rec.Code)
+}
+
+func TestAdminDeleteRepo(t *testing.T) {
+	r := require.New(t)
+
+	handler := newAdminHandler(config.AdminConfig{}, &testAdminService{})
+
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest(http.MethodDelete, "/disco/repos/bafy", nil))
+	r.Equal(http.StatusOK, rec.Code)
+	var deleted services.DeletedRepo
+	r.NoError(json.NewDecoder(rec.Body).Decode(&deleted))
+	r.Equal("bafy", deleted.Cid)
+	r.Equal([]string{"1234"}, deleted.Blobs)
+	r.Equal(2, deleted.KeptBlobs)
+
+	rec = httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest(http.MethodDelete, "/disco/repos/myrepo", nil))
+	r.Equal(http.StatusBadRequest, rec.Code)
+}
+
+func TestAdminSignedRequests(t *testing.T) {
+	r := require.New(t)
+
+	const secret = "0123456789abcdef0123456789abcdef"
+	disco := &testAdminService{}
+	handler := newAdminHandler(config.AdminConfig{HMACSecret: secret, HMACMaxSkew: time.Minute}, disco)
+	serve := func(req *http.Request) int {
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+		return rec.Code
+	}
+
+	// the signed requests are accepted only once
+	req := httptest.NewRequest(http.MethodPost, "/disco/replicate", strings.NewReader(`{"cid":"bafy"}`))
+	r.NoError(utils.SignRequest(req, secret))
+	replayed := req.Clone(req.Context())
+	r.Equal(http.StatusOK, serve(req))
+	r.Equal("bafy", disco.target)
+	replayed.Body = io.NopCloser(strings.NewReader(`{"cid":"bafy"}`))
+	r.Equal(http.StatusUnauthorized, serve(replayed))
+
+	// the unsigned requests are denied without a token
+	r.Equal(http.StatusUnauthorized, serve(httptest.NewRequest(http.MethodGet, "/disco/clones", nil)))
+	req = httptest.NewRequest(http.MethodGet, "/disco/clones", nil)
+	r.NoError(utils.SignRequest(req, secret+"0"))
+	r.Equal(http.StatusUnauthorized, serve(req))
+}
+
+func TestAdminSignedRequests_SharedNonces(t *testing.T) {
+	r := require.New(t)
+
+	// Given two replicas which share the nonces
+	const secret = "0123456789abcdef0123456789abcdef"
+	adminCfg := config.AdminConfig{HMACSecret: secret, HMACMaxSkew: time.Minute}
+	disco := &testAdminService{nonces: make(map[string]bool)}
+	replica1, replica2 := newAdminHandler(adminCfg, disco), newAdminHandler(adminCfg, disco)
+
+	// When a signed request is accepted by one of them
+	req := httptest.NewRequest(http.MethodGet, "/disco/clones", nil)
+	r.NoError(utils.SignRequest(req, secret))
+	replayed := req.Clone(req.Context())
+	rec := httptest.NewRecorder()
+	replica1.ServeHTTP(rec, req)
+	r.Equal(http.StatusOK, rec.Code)
+
+	// Then it should not be replayed against the other one
+	rec = httptest.NewRecorder()
+	replica2.ServeHTTP(rec, replayed)
+	r.Equal(http.StatusUnauthorized, rec.Code)
+}
+
+func TestAdminSnapshots(t *testing.T) {
+	r := require.New(t)
+
+	disco := &testAdminService{
+		snapshots: []*services.Snapshot{{ID: "20221010T000000Z", Nodes: []*services.SnapshotRoot{{Node: 0, Cid: "bafy"}}}},
+	}
+	handler := newAdminHandler(config.AdminConfig{}, disco)
+
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/disco/snapshots", nil))
+	r.Equal(http.StatusOK, rec.Code)
+	var resp struct {
+		Snapshots []*services.Snapshot `json:"snapshots"`
+	}
+	r.NoError(json.NewDecoder(rec.Body).Decode(&resp))
+	r.Len(resp.Snapshots, 1)
+	r.Equal("bafy", resp.Snapshots[0].Nodes[0].Cid)
+
+	rec = httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/disco/snapshots", nil))
+	r.Equal(http.StatusMethodNotAllowed, rec.Code)
+}
+
+func TestAdminTree(t *testing.T) {
+	r := require.New(t)
+
+	handler := newAdminHandler(config.AdminConfig{}, &testAdminService{})
+
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/disco/tree?path=/docker/registry/v2/blobs", nil))
+	r.Equal(http.StatusOK, rec.Code)
+	var resp struct {
+		Files []*drivers.TreeFile `json:"files"`
+	}
+	r.NoError(json.NewDecoder(rec.Body).Decode(&resp))
+	r.Equal([]*drivers.TreeFile{{Path: "/docker/registry/v2/blobs/a", Size: 1}}, resp.Files)
+
+	rec = httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/disco/tree?store=other", nil))
+	r.Equal(http.StatusBadRequest, rec.Code)
+}
+
+func TestAdminUploads(t *testing.T) {
+	r := require.New(t)
+
+	handler := newAdminHandler(config.AdminConfig{}, &testAdminService{})
+	doRequest := func(query string) ([]*services.Upload, int) {
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/disco/uploads"+query, nil))
+		var resp struct {
+			Uploads []*services.Upload `json:"uploads"`
+		}
+		_ = json.NewDecoder(rec.Body).Decode(&resp)
+		return resp.Uploads, rec.Code
+	}
+
+	uploads, code := doRequest("")
+	r.Equal(http.StatusOK, code)
+	r.Len(uploads, 2)
+
+	uploads, code = doRequest("?olderThan=1h&stale=true")
+	r.Equal(http.StatusOK, code)
+	r.Len(uploads, 1)
+	r.Equal("old-upload", uploads[0].ID)
+
+	_, code = doRequest("?olderThan=yesterday")
+	r.Equal(http.StatusBadRequest, code)
+}
+
+func TestNewAdmin(t *testing.T) {
+	r := require.New(t)
+
+	cfg := &config.Config{Distribution: &configuration.Configuration{}}
+	server, err := NewAdmin(cfg, &services.Disco{})
+	r.NoError(err)
+	r.Nil(server, "should not create the server without an address")
+
+	// the dedicated listener needs a credential
+	cfg.Admin.Addr = "127.0.0.1:1971"
+	_, err = NewAdmin(cfg, &services.Disco{})
+	r.Error(err)
+
+	cfg.Admin.Token = "secret"
+	server, err = NewAdmin(cfg, &services.Disco{})
+	r.NoError(err)
+	r.Equal(cfg.Admin.Addr, server.Addr)
+
+	serve := func(path, token string) int {
+		req := httptest.NewRequest(http.MethodGet, path, nil)
+		if len(token) > 0 {
+			req.Header.Set("Authorization", "Bearer "+token)
+		}
+		rec := httptest.NewRecorder()
+		server.Handler.ServeHTTP(rec, req)
+		return rec.Code
+	}
+	r.Equal(http.StatusOK, serve("/health", ""))
+
+	// the admin api and the profiles need the token
+	r.Equal(http.StatusUnauthorized, serve("/disco/replicate", ""))
+	r.Equal(http.StatusMethodNotAllowed, serve("/disco/replicate", "secret"))
+	r.Equal(http.StatusUnauthorized, serve("/debug/pprof/", ""))
+	r.Equal(http.StatusOK, serve("/debug/pprof/", "secret"))
+}
+
+func TestDebugURL(t *testing.T) {
+	r := require.New(t)
+
+	for _, addr := range []string{":5001", "localhost:5001", "0.0.0.0:5001", "[::]:5001"} {
+		u, err := debugURL(addr)
+		r.NoError(err, addr)
+		r.Equal("http://localhost:5001", u.String(), addr)
+	}
+	_, err := debugURL("localhost")
+	r.Error(err)
+}
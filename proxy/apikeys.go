@@ -0,0 +1,132 @@
+package proxy
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strings"
+
+	"github.com/forta-network/disco/proxy/services"
+	log "github.com/sirupsen/logrus"
+)
+
+// requestAPIKey returns the API key from the bearer token or from the basic auth password, which
+// docker login sends.
+func requestAPIKey(r *http.Request) string {
+	if _, password, ok := r.BasicAuth(); ok {
+		return password
+	}
+	authorization := r.Header.Get("Authorization")
+	if !strings.HasPrefix(authorization, "Bearer ") {
+		return ""
+	}
+	return strings.TrimPrefix(authorization, "Bearer ")
+}
+
+// registryScope returns the scope which a registry request needs. The version check needs only
+// a valid key.
+func registryScope(r *http.Request) string {
+	switch {
+	case r.URL.Path == "/v2/":
+		return ""
+	case r.Method == http.MethodGet || r.Method == http.MethodHead:
+		return services.ScopePull
+	default:
+		return services.ScopePush
+	}
+}
+
+// adminScope returns the scope which an admin API request needs.
+func adminScope(r *http.Request) string {
+	if r.URL.Path == adminPathPrefix+"replicate" || r.URL.Path == adminPathPrefix+"prefetch" {
+		return services.ScopeReplicate
+	}
+	return services.ScopeAdmin
+}
+
+// checkAPIKey denies the registry requests which do not have an API key with their scope, if
+// the API keys are enabled.
+func checkAPIKey(rw http.ResponseWriter, r *http.Request, disco *services.Disco) bool {
+	if !disco.APIKeysEnabled() || !strings.HasPrefix(r.URL.Path, "/v2/") {
+		return false
+	}
+	err := disco.CheckAPIKey(requestAPIKey(r), registryScope(r))
+	switch {
+	case err == nil:
+		return false
+	case errors.Is(err, services.ErrScopeDenied):
+		writeRegistryError(rw, http.StatusForbidden, "DENIED", err.Error())
+	default:
+		rw.Header().Set("WWW-Authenticate", `Basic realm="disco"`)
+		writeRegistryError(rw, http.StatusUnauthorized, "UNAUTHORIZED", "a valid api key is required")
+	}
+	return true
+}
+
+// apiKeyRequest is the body of the requests which create the API keys.
+type apiKeyRequest struct {
+	Name   string   `json:"name"`
+	Scopes []string `json:"scopes"`
+}
+
+// handleAPIKeys lists and creates the API keys on /disco/apikeys and revokes them on
+// /disco/apikeys/<id>.
+func handleAPIKeys(rw http.ResponseWriter, r *http.Request, disco adminService) {
+	if !disco.APIKeysEnabled() {
+		writeRegistryError(rw, http.StatusNotFound, "UNSUPPORTED", "api keys are not enabled")
+		return
+	}
+	id := strings.TrimPrefix(strings.TrimPrefix(r.URL.Path, adminPathPrefix+"apikeys"), "/")
+	switch {
+	case len(id) == 0 && r.Method == http.MethodGet:
+		rw.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(rw).Encode(map[string]interface{}{
+			"apikeys": disco.ListAPIKeys(),
+		})
+
+	case len(id) == 0 && r.Method == http.MethodPost:
+		var req apiKeyRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			writeRegistryError(rw, http.StatusBadRequest, "BAD_REQUEST", "invalid request body: "+err.Error())
+			return
+		}
+		key, secretKey, err := disco.CreateAPIKey(req.Name, req.Scopes)
+		if errors.Is(err, services.ErrInvalidScope) {
+			writeRegistryError(rw, http.StatusBadRequest, "BAD_REQUEST", err.Error())
+			return
+		}
+		if err != nil {
+			log.WithError(err).Error("failed to create the api key")
+			writeServiceError(rw, err)
+			return
+		}
+		log.WithFields(log.Fields{
+			"id":     key.ID,
+			"name":   key.Name,
+			"scopes": key.Scopes,
+		}).Info("created api key")
+		rw.Header().Set("Content-Type", "application/json")
+		rw.WriteHeader(http.StatusCreated)
+		_ = json.NewEncoder(rw).Encode(map[string]interface{}{
+			"apikey": key,
+			"key":    secretKey,
+		})
+
+	case len(id) > 0 && r.Method == http.MethodDelete:
+		err := disco.RevokeAPIKey(id)
+		if errors.Is(err, services.ErrAPIKeyNotFound) {
+			writeRegistryError(rw, http.StatusNotFound, "NOT_FOUND", err.Error())
+			return
+		}
+		if err != nil {
+			log.WithError(err).Error("failed to revoke the api key")
+			writeServiceError(rw, err)
+			return
+		}
+		log.WithField("id", id).Info("revoked api key")
+		rw.WriteHeader(http.StatusNoContent)
+
+	default:
+		writeRegistryError(rw, http.StatusMethodNotAllowed, "UNSUPPORTED", "unsupported method")
+	}
+}
@@ -0,0 +1,89 @@
+package proxy
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/forta-network/disco/config"
+	"github.com/forta-network/disco/proxy/services"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAPIKeys(t *testing.T) {
+	r := require.New(t)
+
+	cfg := &config.Config{APIKeys: config.APIKeysConfig{Enabled: true, File: filepath.Join(t.TempDir(), "apikeys.json")}}
+	disco := services.NewDiscoService(cfg, nil)
+	admin := newAdminHandler(config.AdminConfig{Token: "secret"}, disco)
+	doAdmin := func(token, method, path, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, path, strings.NewReader(body))
+		req.Header.Set("Authorization", "Bearer "+token)
+		rec := httptest.NewRecorder()
+		admin.ServeHTTP(rec, req)
+		return rec
+	}
+	createKey := func(scopes string) (string, string) {
+		rec := doAdmin("secret", http.MethodPost, "/disco/apikeys", `{"name":"ci","scopes":`+scopes+`}`)
+		r.Equal(http.StatusCreated, rec.Code, rec.Body.String())
+		var resp struct {
+			APIKey *services.APIKey `json:"apikey"`
+			Key    string           `json:"key"`
+		}
+		r.NoError(json.NewDecoder(rec.Body).Decode(&resp))
+		r.Empty(resp.APIKey.Hash)
+		return resp.APIKey.ID, resp.Key
+	}
+	checkRegistry := func(method, path, key string, basic bool) int {
+		req := httptest.NewRequest(method, path, nil)
+		if basic {
+			req.SetBasicAuth("ci", key)
+		} else if len(key) > 0 {
+			req.Header.Set("Authorization", "Bearer "+key)
+		}
+		rec := httptest.NewRecorder()
+		if checkAPIKey(rec, req, disco) {
+			return rec.Code
+		}
+		return http.StatusOK
+	}
+
+	// Given the keys which are created with the admin token
+	pullID, pullKey := createKey(`["pull"]`)
+	_, adminKey := createKey(`["admin"]`)
+	r.Equal(http.StatusBadRequest, doAdmin("secret", http.MethodPost, "/disco/apikeys", `{"scopes":["root"]}`).Code)
+
+	// Then the registry requests should be authorized with their scopes
+	r.Equal(http.StatusUnauthorized, checkRegistry(http.MethodGet, "/v2/", "", false))
+	r.Equal(http.StatusOK, checkRegistry(http.MethodGet, "/v2/", pullKey, true))
+	r.Equal(http.StatusOK, checkRegistry(http.MethodGet, "/v2/myrepo/manifests/latest", pullKey, false))
+	r.Equal(http.StatusForbidden, checkRegistry(http.MethodPut, "/v2/myrepo/manifests/latest", pullKey, true))
+	r.Equal(http.StatusUnauthorized, checkRegistry(http.MethodGet, "/v2/myrepo/manifests/latest", pullKey+"0", false))
+
+	// And the admin API should accept the admin keys only
+	r.Equal(http.StatusOK, doAdmin(adminKey, http.MethodGet, "/disco/apikeys", "").Code)
+	r.Equal(http.StatusForbidden, doAdmin(pullKey, http.MethodGet, "/disco/apikeys", "").Code)
+
+	// When the pull key is revoked
+	r.Equal(http.StatusNoContent, doAdmin(adminKey, http.MethodDelete, "/disco/apikeys/"+pullID, "").Code)
+	r.Equal(http.StatusNotFound, doAdmin(adminKey, http.MethodDelete, "/disco/apikeys/"+pullID, "").Code)
+
+	// Then it should not be accepted anymore, also after a restart
+	r.Equal(http.StatusUnauthorized, checkRegistry(http.MethodGet, "/v2/myrepo/manifests/latest", pullKey, false))
+	disco = services.NewDiscoService(cfg, nil)
+	r.Len(disco.ListAPIKeys(), 1)
+	r.Equal(http.StatusForbidden, checkRegistry(http.MethodPut, "/v2/myrepo/manifests/latest", adminKey, false), "admin should not imply push")
+
+	// Given an admin API without a token, e.g. on the dedicated listener
+	admin = newAdminHandler(config.AdminConfig{}, disco)
+	_, pushKey, err := disco.CreateAPIKey("ci", []string{services.ScopePush})
+	r.NoError(err)
+
+	// Then it should still require the keys with the scopes
+	r.Equal(http.StatusUnauthorized, doAdmin("", http.MethodPost, "/disco/apikeys", `{"scopes":["admin"]}`).Code)
+	r.Equal(http.StatusForbidden, doAdmin(pushKey, http.MethodPost, "/disco/replicate", `{"path":"/a"}`).Code)
+	r.Equal(http.StatusOK, doAdmin(adminKey, http.MethodGet, "/disco/apikeys", "").Code)
+}
@@ -0,0 +1,42 @@
+package proxy
+
+import (
+	"sync"
+
+	"github.com/forta-network/disco/config"
+)
+
+// bufferPool reuses the buffers which the reverse proxy copies the responses with. The copy
+// writes each buffer to the client before it reads the next one from the registry, so a slow
+// client slows the reads from the stores down instead of the response piling up in the memory,
+// and the memory of the copies is bounded by the responses in progress.
+type bufferPool struct {
+	size int
+	pool sync.Pool
+}
+
+func newBufferPool(size int) *bufferPool {
+	if size <= 0 {
+		size = config.DefaultProxyBufferSize
+	}
+	bp := &bufferPool{size: size}
+	bp.pool.New = func() interface{} {
+		b := make([]byte, size)
+		return &b
+	}
+	return bp
+}
+
+// Get implements httputil.BufferPool.
+func (bp *bufferPool) Get() []byte {
+	return *bp.pool.Get().(*[]byte)
+}
+
+// Put implements httputil.BufferPool.
+func (bp *bufferPool) Put(b []byte) {
+	if cap(b) < bp.size {
+		return
+	}
+	b = b[:bp.size]
+	bp.pool.Put(&b)
+}
@@ -0,0 +1,24 @@
+package proxy
+
+import (
+	"testing"
+
+	"github.com/forta-network/disco/config"
+	"github.com/stretchr/testify/require"
+)
+
+func TestBufferPool(t *testing.T) {
+	r := require.New(t)
+
+	bp := newBufferPool(1024)
+	b := bp.Get()
+	r.Len(b, 1024)
+
+	// the resliced buffers are given back in full size and the smaller ones are dropped
+	bp.Put(b[:10])
+	r.Len(bp.Get(), 1024)
+	bp.Put(make([]byte, 10))
+	r.Len(bp.Get(), 1024)
+
+	r.Len(newBufferPool(0).Get(), config.DefaultProxyBufferSize)
+}
@@ -0,0 +1,62 @@
+package proxy
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httputil"
+	"net/url"
+	"strconv"
+
+	"github.com/forta-network/disco/proxy/services"
+	"github.com/forta-network/disco/utils"
+)
+
+// catalogPath is the path of the catalog of the global repositories.
+const catalogPath = "/v2/_disco/catalog"
+
+// repoCatalog lists the global repositories page by page.
+type repoCatalog interface {
+	Catalog(ctx context.Context, n int, last string) ([]*services.CatalogEntry, string, error)
+}
+
+// handleCatalog responds to /v2/_disco/catalog with the CID v1 repositories and their manifest
+// digests, sizes and push times. It is paginated with the n and last parameters and the Link
+// header in the same way as /v2/_catalog. The request is authorized by the registry.
+func handleCatalog(rw http.ResponseWriter, r *http.Request, rp *httputil.ReverseProxy, catalog repoCatalog) {
+	if r.Method != http.MethodGet {
+		writeRegistryError(rw, http.StatusMethodNotAllowed, "UNSUPPORTED", "method not allowed")
+		return
+	}
+	query := r.URL.Query()
+	var n int
+	if value := query.Get("n"); len(value) > 0 {
+		var err error
+		n, err = strconv.Atoi(value)
+		if err != nil || n < 0 {
+			writeRegistryError(rw, http.StatusBadRequest, "PAGINATION_NUMBER_INVALID", "invalid number of results requested")
+			return
+		}
+	}
+	if done := authorizeWithRegistry(rw, r, rp); done {
+		return
+	}
+	entries, next, err := catalog.Catalog(r.Context(), n, query.Get("last"))
+	if err != nil {
+		utils.Logger(r.Context()).WithError(err).Warn("failed to list the catalog")
+		writeServiceError(rw, err)
+		return
+	}
+	if len(next) > 0 {
+		nextQuery := url.Values{"last": {next}}
+		if n > 0 {
+			nextQuery.Set("n", strconv.Itoa(n))
+		}
+		rw.Header().Set("Link", fmt.Sprintf(`<%s?%s>; rel="next"`, catalogPath, nextQuery.Encode()))
+	}
+	rw.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(rw).Encode(map[string]interface{}{
+		"repositories": entries,
+	})
+}
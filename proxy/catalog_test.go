@@ -0,0 +1,68 @@
+package proxy
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/http/httputil"
+	"net/url"
+	"sort"
+	"testing"
+
+	"github.com/forta-network/disco/proxy/services"
+	"github.com/stretchr/testify/require"
+)
+
+type testCatalog []*services.CatalogEntry
+
+func (catalog testCatalog) Catalog(ctx context.Context, n int, last string) ([]*services.CatalogEntry, string, error) {
+	start := sort.Search(len(catalog), func(i int) bool { return catalog[i].Cid > last })
+	end := len(catalog)
+	if n > 0 && start+n < end {
+		end = start + n
+	}
+	var next string
+	if end < len(catalog) {
+		next = catalog[end-1].Cid
+	}
+	return catalog[start:end], next, nil
+}
+
+func TestHandleCatalog(t *testing.T) {
+	r := require.New(t)
+
+	// Given a registry which accepts all requests
+	registry := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {}))
+	defer registry.Close()
+	registryURL, err := url.Parse(registry.URL)
+	r.NoError(err)
+	rp := httputil.NewSingleHostReverseProxy(registryURL)
+	catalog := testCatalog{{Cid: "bafy1", Size: 1}, {Cid: "bafy2", Size: 2}}
+	list := func(query string) (*httptest.ResponseRecorder, []*services.CatalogEntry) {
+		rec := httptest.NewRecorder()
+		handleCatalog(rec, httptest.NewRequest(http.MethodGet, catalogPath+query, nil), rp, catalog)
+		var resp struct {
+			Repositories []*services.CatalogEntry `json:"repositories"`
+		}
+		_ = json.NewDecoder(rec.Body).Decode(&resp)
+		return rec, resp.Repositories
+	}
+
+	// When the first page is listed
+	// Then the next page should be linked
+	rec, entries := list("?n=1")
+	r.Equal(http.StatusOK, rec.Code)
+	r.Equal([]*services.CatalogEntry{{Cid: "bafy1", Size: 1}}, entries)
+	r.Equal(`</v2/_disco/catalog?last=bafy1&n=1>; rel="next"`, rec.Header().Get("Link"))
+
+	// And the last page should not link further
+	rec, entries = list("?n=1&last=bafy1")
+	r.Equal(http.StatusOK, rec.Code)
+	r.Equal([]*services.CatalogEntry{{Cid: "bafy2", Size: 2}}, entries)
+	r.Empty(rec.Header().Get("Link"))
+
+	// And the invalid page sizes should be rejected
+	rec, _ = list("?n=many")
+	r.Equal(http.StatusBadRequest, rec.Code)
+}
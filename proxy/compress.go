@@ -0,0 +1,94 @@
+package proxy
+
+import (
+	"compress/gzip"
+	"net/http"
+	"strings"
+)
+
+// compressResponses compresses the metadata responses with gzip if the clients accept it.
+// The blobs are never compressed since the layers are already compressed and the clients
+// verify their sizes.
+func compressResponses(handler http.Handler) http.Handler {
+	return http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+		if !isCompressible(r) || !acceptsGzip(r) {
+			handler.ServeHTTP(rw, r)
+			return
+		}
+		gw := &gzipResponseWriter{ResponseWriter: rw}
+		defer gw.Close()
+		handler.ServeHTTP(gw, r)
+	})
+}
+
+// isCompressible tells if the response of the request is metadata: a manifest, the catalog,
+// the tags of a repository or an admin response.
+func isCompressible(r *http.Request) bool {
+	if r.Method == http.MethodHead {
+		return false
+	}
+	p := r.URL.Path
+	return strings.Contains(p, "/manifests/") ||
+		p == "/v2/_catalog" ||
+		strings.HasSuffix(p, "/tags/list") ||
+		strings.HasPrefix(p, adminPathPrefix)
+}
+
+func acceptsGzip(r *http.Request) bool {
+	for _, encoding := range strings.Split(r.Header.Get("Accept-Encoding"), ",") {
+		encoding = strings.TrimSpace(strings.Split(encoding, ";")[0])
+		if encoding == "gzip" || encoding == "*" {
+			return true
+		}
+	}
+	return false
+}
+
+// gzipResponseWriter compresses the body unless the response is already encoded or has no body.
+type gzipResponseWriter struct {
+	http.ResponseWriter
+	gz          *gzip.Writer
+	wroteHeader bool
+}
+
+func (gw *gzipResponseWriter) WriteHeader(status int) {
+	if gw.wroteHeader {
+		return
+	}
+	gw.wroteHeader = true
+	header := gw.Header()
+	header.Add("Vary", "Accept-Encoding")
+	if len(header.Get("Content-Encoding")) == 0 && status != http.StatusNoContent && status != http.StatusNotModified {
+		header.Set("Content-Encoding", "gzip")
+		header.Del("Content-Length")
+		gw.gz = gzip.NewWriter(gw.ResponseWriter)
+	}
+	gw.ResponseWriter.WriteHeader(status)
+}
+
+func (gw *gzipResponseWriter) Write(b []byte) (int, error) {
+	if !gw.wroteHeader {
+		gw.WriteHeader(http.StatusOK)
+	}
+	if gw.gz == nil {
+		return gw.ResponseWriter.Write(b)
+	}
+	return gw.gz.Write(b)
+}
+
+func (gw *gzipResponseWriter) Flush() {
+	if gw.gz != nil {
+		_ = gw.gz.Flush()
+	}
+	if flusher, ok := gw.ResponseWriter.(http.Flusher); ok {
+		flusher.Flush()
+	}
+}
+
+// Close writes the rest of the compressed body.
+func (gw *gzipResponseWriter) Close() error {
+	if gw.gz == nil {
+		return nil
+	}
+	return gw.gz.Close()
+}
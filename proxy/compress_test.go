@@ -0,0 +1,46 @@
+package proxy
+
+import (
+	"compress/gzip"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestCompressResponses(t *testing.T) {
+	r := require.New(t)
+
+	handler := compressResponses(http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+		rw.Header().Set("Content-Length", "8")
+		_, _ = rw.Write([]byte("response"))
+	}))
+
+	doRequest := func(method, path, acceptEncoding string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, path, nil)
+		req.Header.Set("Accept-Encoding", acceptEncoding)
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+		return rec
+	}
+
+	rec := doRequest(http.MethodGet, "/v2/myrepo/manifests/latest", "zstd, gzip;q=0.8")
+	r.Equal("gzip", rec.Header().Get("Content-Encoding"))
+	r.Empty(rec.Header().Get("Content-Length"))
+	gz, err := gzip.NewReader(rec.Body)
+	r.NoError(err)
+	b, err := io.ReadAll(gz)
+	r.NoError(err)
+	r.Equal("response", string(b))
+
+	for _, rec := range []*httptest.ResponseRecorder{
+		doRequest(http.MethodGet, "/v2/myrepo/blobs/sha256:abc", "gzip"),
+		doRequest(http.MethodHead, "/v2/myrepo/manifests/latest", "gzip"),
+		doRequest(http.MethodGet, "/v2/_catalog", ""),
+	} {
+		r.Empty(rec.Header().Get("Content-Encoding"))
+		r.Equal("response", rec.Body.String())
+	}
+}
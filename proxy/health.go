@@ -0,0 +1,101 @@
+package proxy
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httputil"
+	"time"
+
+	"github.com/forta-network/disco/proxy/services"
+	"github.com/forta-network/disco/utils"
+)
+
+const (
+	// healthzPath is the path of the liveness check.
+	healthzPath = "/healthz"
+	// readyzPath is the path of the readiness check.
+	readyzPath = "/readyz"
+	// registryCheckTimeout is the time limit of checking the registry.
+	registryCheckTimeout = time.Second * 5
+)
+
+// dependencyChecker checks the dependencies of the registry other than the registry itself.
+type dependencyChecker interface {
+	CheckDependencies(ctx context.Context) []*services.DependencyStatus
+}
+
+// healthResponse is the body of the health and the readiness responses.
+type healthResponse struct {
+	Status       string                       `json:"status"`
+	Dependencies []*services.DependencyStatus `json:"dependencies,omitempty"`
+}
+
+// handleHealth responds to /healthz as long as the proxy is serving and to /readyz with the
+// status of each dependency. The readiness check fails with 503 if any of the IPFS nodes, the
+// cache or the registry cannot be reached. The checks are not authorized so that the load
+// balancers and the probes can call them.
+func handleHealth(rw http.ResponseWriter, r *http.Request, rp *httputil.ReverseProxy, checker dependencyChecker) {
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		writeRegistryError(rw, http.StatusMethodNotAllowed, "UNSUPPORTED", "method not allowed")
+		return
+	}
+	rw.Header().Set("Content-Type", "application/json")
+	rw.Header().Set("Cache-Control", "no-store")
+	if r.URL.Path == healthzPath {
+		_ = json.NewEncoder(rw).Encode(&healthResponse{Status: "ok"})
+		return
+	}
+
+	registry := make(chan *services.DependencyStatus, 1)
+	go func() {
+		registry <- checkRegistry(r.Context(), rp)
+	}()
+	statuses := append(checker.CheckDependencies(r.Context()), <-registry)
+	resp := &healthResponse{Status: "ok", Dependencies: statuses}
+	status := http.StatusOK
+	for _, dependency := range statuses {
+		dependency.Error = utils.Redact(dependency.Error)
+		if !dependency.OK {
+			utils.Logger(r.Context()).WithField("dependency", dependency.Name).Warnf("not ready: %s", dependency.Error)
+			resp.Status = "unavailable"
+			status = http.StatusServiceUnavailable
+		}
+	}
+	rw.WriteHeader(status)
+	_ = json.NewEncoder(rw).Encode(resp)
+}
+
+// checkRegistry checks that the registry behind the proxy responds to the API version check.
+func checkRegistry(ctx context.Context, rp *httputil.ReverseProxy) *services.DependencyStatus {
+	ctx, cancel := context.WithTimeout(ctx, registryCheckTimeout)
+	defer cancel()
+	dependency := &services.DependencyStatus{Name: "registry"}
+	start := time.Now()
+	defer func() {
+		dependency.DurationMs = time.Since(start).Milliseconds()
+	}()
+	probe, err := http.NewRequestWithContext(ctx, http.MethodGet, "/v2/", nil)
+	if err != nil {
+		dependency.Error = err.Error()
+		return dependency
+	}
+	rp.Director(probe)
+	transport := rp.Transport
+	if transport == nil {
+		transport = http.DefaultTransport
+	}
+	resp, err := transport.RoundTrip(probe)
+	if err != nil {
+		dependency.Error = err.Error()
+		return dependency
+	}
+	resp.Body.Close()
+	// the registry responds with 401 if the auth is enabled
+	dependency.OK = resp.StatusCode == http.StatusOK || resp.StatusCode == http.StatusUnauthorized
+	if !dependency.OK {
+		dependency.Error = fmt.Sprintf("responded with %d", resp.StatusCode)
+	}
+	return dependency
+}
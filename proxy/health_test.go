@@ -0,0 +1,74 @@
+package proxy
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/http/httputil"
+	"net/url"
+	"testing"
+
+	"github.com/forta-network/disco/proxy/services"
+	"github.com/stretchr/testify/require"
+)
+
+// testDependencyChecker reports the statuses of the dependencies.
+type testDependencyChecker []*services.DependencyStatus
+
+func (checker testDependencyChecker) CheckDependencies(ctx context.Context) []*services.DependencyStatus {
+	return checker
+}
+
+func TestHandleHealth(t *testing.T) {
+	r := require.New(t)
+
+	// Given a registry which requires auth
+	registry := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		r.Equal("/v2/", req.URL.Path)
+		rw.WriteHeader(http.StatusUnauthorized)
+	}))
+	defer registry.Close()
+	registryURL, err := url.Parse(registry.URL)
+	r.NoError(err)
+	rp := httputil.NewSingleHostReverseProxy(registryURL)
+	serve := func(path string, checker dependencyChecker) (*httptest.ResponseRecorder, *healthResponse) {
+		rec := httptest.NewRecorder()
+		handleHealth(rec, httptest.NewRequest(http.MethodGet, path, nil), rp, checker)
+		var resp healthResponse
+		r.NoError(json.NewDecoder(rec.Body).Decode(&resp))
+		return rec, &resp
+	}
+
+	// When the liveness is checked
+	// Then the dependencies should not be checked
+	rec, resp := serve(healthzPath, nil)
+	r.Equal(http.StatusOK, rec.Code)
+	r.Equal("ok", resp.Status)
+	r.Empty(resp.Dependencies)
+
+	// When the readiness is checked with the dependencies which are up
+	// Then it should be ready with the status of each dependency
+	rec, resp = serve(readyzPath, testDependencyChecker{{Name: "ipfs node #0", OK: true}, {Name: "cache", OK: true}})
+	r.Equal(http.StatusOK, rec.Code)
+	r.Equal("ok", resp.Status)
+	r.Len(resp.Dependencies, 3)
+	r.Equal("registry", resp.Dependencies[2].Name)
+	r.True(resp.Dependencies[2].OK)
+
+	// When an ipfs node is down
+	// Then it should not be ready
+	rec, resp = serve(readyzPath, testDependencyChecker{{Name: "ipfs node #0", Error: "connection refused"}})
+	r.Equal(http.StatusServiceUnavailable, rec.Code)
+	r.Equal("unavailable", resp.Status)
+	r.False(resp.Dependencies[0].OK)
+
+	// When the registry is down
+	// Then it should not be ready
+	registry.Close()
+	rec, resp = serve(readyzPath, testDependencyChecker{})
+	r.Equal(http.StatusServiceUnavailable, rec.Code)
+	r.Len(resp.Dependencies, 1)
+	r.False(resp.Dependencies[0].OK)
+	r.NotEmpty(resp.Dependencies[0].Error)
+}
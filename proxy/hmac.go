@@ -0,0 +1,105 @@
+package proxy
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/forta-network/disco/config"
+	"github.com/forta-network/disco/utils"
+)
+
+// nonceStore remembers the nonces of the signed requests for as long as the requests are valid,
+// so that the requests cannot be replayed.
+type nonceStore interface {
+	// add records the nonce and returns false if it was seen before.
+	add(nonce string) (bool, error)
+}
+
+// sharedNonceService records the nonces in the shared state of the replicas.
+type sharedNonceService interface {
+	SharedNoncesEnabled() bool
+	UseNonce(nonce string, ttl time.Duration) (bool, error)
+}
+
+// sharedNonces keeps the nonces in the shared state, so that a request which one replica
+// accepted cannot be replayed against the others.
+type sharedNonces struct {
+	ttl     time.Duration
+	service sharedNonceService
+}
+
+func (nonces *sharedNonces) add(nonce string) (bool, error) {
+	// the timestamps can be in the future by the skew, too
+	return nonces.service.UseNonce(nonce, 2*nonces.ttl)
+}
+
+// nonceCache keeps the nonces in memory when the shared state is not configured.
+type nonceCache struct {
+	ttl time.Duration
+
+	mu   sync.Mutex
+	seen map[string]time.Time
+}
+
+func newNonceCache(ttl time.Duration) *nonceCache {
+	return &nonceCache{ttl: ttl, seen: make(map[string]time.Time)}
+}
+
+func (cache *nonceCache) add(nonce string) (bool, error) {
+	cache.mu.Lock()
+	defer cache.mu.Unlock()
+	now := time.Now()
+	for seenNonce, expires := range cache.seen {
+		if now.After(expires) {
+			delete(cache.seen, seenNonce)
+		}
+	}
+	if _, ok := cache.seen[nonce]; ok {
+		return false, nil
+	}
+	// the timestamps can be in the future by the skew, too
+	cache.seen[nonce] = now.Add(2 * cache.ttl)
+	return true, nil
+}
+
+// requestVerifier verifies the signed admin requests.
+type requestVerifier struct {
+	secret  string
+	maxSkew time.Duration
+	nonces  nonceStore
+}
+
+// newRequestVerifier creates a verifier if the HMAC secret is configured. The nonces are kept
+// in the shared state if it is configured.
+func newRequestVerifier(adminCfg config.AdminConfig, service sharedNonceService) *requestVerifier {
+	if len(adminCfg.HMACSecret) == 0 {
+		return nil
+	}
+	var nonces nonceStore = newNonceCache(adminCfg.HMACMaxSkew)
+	if service.SharedNoncesEnabled() {
+		nonces = &sharedNonces{ttl: adminCfg.HMACMaxSkew, service: service}
+	}
+	return &requestVerifier{
+		secret:  adminCfg.HMACSecret,
+		maxSkew: adminCfg.HMACMaxSkew,
+		nonces:  nonces,
+	}
+}
+
+func (verifier *requestVerifier) verify(r *http.Request) error {
+	nonce, err := utils.VerifyRequestSignature(r, verifier.secret, verifier.maxSkew)
+	if err != nil {
+		return err
+	}
+	added, err := verifier.nonces.add(nonce)
+	if err != nil {
+		return fmt.Errorf("failed to check the nonce: %v", err)
+	}
+	if !added {
+		return errors.New("nonce is used already")
+	}
+	return nil
+}
@@ -0,0 +1,151 @@
+package proxy
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"strings"
+	"sync"
+
+	"github.com/forta-network/disco/utils"
+	log "github.com/sirupsen/logrus"
+)
+
+// retryAfterSeconds is how long the clients should wait before retrying the rejected requests.
+const retryAfterSeconds = "5"
+
+// inflightLimiter limits the bytes of the uploads which are streamed through the proxy at
+// the same time. The new uploads wait while the uploads in progress exceed the limit.
+type inflightLimiter struct {
+	max int64
+
+	mu       sync.Mutex
+	inflight int64
+	released chan struct{}
+}
+
+func newInflightLimiter(max int64) *inflightLimiter {
+	return &inflightLimiter{max: max, released: make(chan struct{})}
+}
+
+// wait blocks until there is room for the expected bytes. An upload is always let in when
+// there are no others, so that the uploads larger than the limit can be streamed, too.
+func (l *inflightLimiter) wait(ctx context.Context, expected int64) error {
+	for {
+		l.mu.Lock()
+		if l.inflight == 0 || l.inflight+expected <= l.max {
+			l.mu.Unlock()
+			return nil
+		}
+		released := l.released
+		l.mu.Unlock()
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-released:
+		}
+	}
+}
+
+func (l *inflightLimiter) add(n int64) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.inflight += n
+	if n < 0 {
+		close(l.released)
+		l.released = make(chan struct{})
+	}
+}
+
+// limitedBody counts the bytes which are read from the request body as in flight
+// until the body is closed.
+type limitedBody struct {
+	body    io.ReadCloser
+	limiter *inflightLimiter
+
+	mu   sync.Mutex
+	read int64
+}
+
+func (b *limitedBody) Read(p []byte) (int, error) {
+	n, err := b.body.Read(p)
+	b.mu.Lock()
+	b.read += int64(n)
+	b.mu.Unlock()
+	b.limiter.add(int64(n))
+	return n, err
+}
+
+func (b *limitedBody) Close() error {
+	b.mu.Lock()
+	read := b.read
+	b.read = 0
+	b.mu.Unlock()
+	if read > 0 {
+		b.limiter.add(-read)
+	}
+	return b.body.Close()
+}
+
+// limitUploads makes the blob uploads wait while the uploads in progress exceed the max bytes.
+// The request bodies are streamed to the registry without buffering.
+func limitUploads(handler http.Handler, limiter *inflightLimiter) http.Handler {
+	return http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+		if !isBlobUpload(r) {
+			handler.ServeHTTP(rw, r)
+			return
+		}
+		expected := r.ContentLength
+		if expected < 0 {
+			expected = 0 // streamed in chunks
+		}
+		if err := limiter.wait(r.Context(), expected); err != nil {
+			log.WithError(err).Warn("upload was cancelled while waiting for other uploads")
+			rw.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		body := &limitedBody{body: r.Body, limiter: limiter}
+		defer body.Close()
+		r.Body = body
+		handler.ServeHTTP(rw, r)
+	})
+}
+
+func isBlobUpload(r *http.Request) bool {
+	switch r.Method {
+	case http.MethodPatch, http.MethodPut, http.MethodPost:
+		return r.Body != nil && r.Body != http.NoBody && strings.Contains(r.URL.Path, "/blobs/uploads/")
+	}
+	return false
+}
+
+// limitConcurrentUploads lets a limited number of blob upload requests in at the same time and
+// responds with 429 when the queue of the waiting requests is full.
+func limitConcurrentUploads(handler http.Handler, limiter *utils.ConcurrencyLimiter) http.Handler {
+	return http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+		if !isBlobUpload(r) {
+			handler.ServeHTTP(rw, r)
+			return
+		}
+		release, err := limiter.Acquire(r.Context())
+		if err != nil {
+			writeTooManyRequests(rw, err)
+			return
+		}
+		defer release()
+		handler.ServeHTTP(rw, r)
+	})
+}
+
+// writeTooManyRequests responds with 429 if the queue is full and with 503 if the request
+// was cancelled while waiting.
+func writeTooManyRequests(rw http.ResponseWriter, err error) {
+	if !errors.Is(err, utils.ErrQueueFull) {
+		log.WithError(err).Warn("request was cancelled while waiting in the queue")
+		rw.WriteHeader(http.StatusServiceUnavailable)
+		return
+	}
+	rw.Header().Set("Retry-After", retryAfterSeconds)
+	writeRegistryError(rw, http.StatusTooManyRequests, "TOOMANYREQUESTS", err.Error())
+}
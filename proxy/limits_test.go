@@ -0,0 +1,100 @@
+package proxy
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/forta-network/disco/utils"
+	"github.com/stretchr/testify/require"
+)
+
+func TestInflightLimiter(t *testing.T) {
+	r := require.New(t)
+	ctx := context.Background()
+
+	limiter := newInflightLimiter(10)
+	r.NoError(limiter.wait(ctx, 100), "should let the first upload in")
+	limiter.add(8)
+	r.NoError(limiter.wait(ctx, 2))
+
+	waitCtx, cancel := context.WithTimeout(ctx, time.Millisecond*50)
+	defer cancel()
+	r.ErrorIs(limiter.wait(waitCtx, 3), context.DeadlineExceeded)
+
+	done := make(chan error)
+	go func() {
+		done <- limiter.wait(ctx, 3)
+	}()
+	limiter.add(-8)
+	r.NoError(<-done)
+}
+
+func TestLimitUploads(t *testing.T) {
+	r := require.New(t)
+
+	limiter := newInflightLimiter(10)
+	var inflight int64
+	handler := limitUploads(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		b, err := io.ReadAll(req.Body)
+		r.NoError(err)
+		r.Equal("blob", string(b))
+		inflight = limiter.inflight
+	}), limiter)
+
+	req := httptest.NewRequest(http.MethodPatch, "/v2/myrepo/blobs/uploads/some-uuid", strings.NewReader("blob"))
+	handler.ServeHTTP(httptest.NewRecorder(), req)
+	r.Equal(int64(4), inflight, "should count the bytes while streaming")
+	r.Equal(int64(0), limiter.inflight, "should release the bytes after the upload")
+
+	// the uploads wait while the others are in progress
+	limiter.add(20)
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	req = httptest.NewRequest(http.MethodPatch, "/v2/myrepo/blobs/uploads/some-uuid", strings.NewReader("blob")).WithContext(ctx)
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+	r.Equal(http.StatusServiceUnavailable, rec.Code)
+}
+
+func TestLimitConcurrentUploads(t *testing.T) {
+	r := require.New(t)
+
+	started := make(chan struct{})
+	unblock := make(chan struct{})
+	handler := limitConcurrentUploads(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		if !isBlobUpload(req) {
+			return
+		}
+		close(started)
+		<-unblock
+	}), utils.NewConcurrencyLimiter(1, 0))
+
+	done := make(chan struct{})
+	go func() {
+		req := httptest.NewRequest(http.MethodPatch, "/v2/myrepo/blobs/uploads/some-uuid", strings.NewReader("blob"))
+		handler.ServeHTTP(httptest.NewRecorder(), req)
+		close(done)
+	}()
+	<-started
+
+	// the queue is full while the first upload is in progress
+	req := httptest.NewRequest(http.MethodPatch, "/v2/myrepo/blobs/uploads/other-uuid", strings.NewReader("blob"))
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+	r.Equal(http.StatusTooManyRequests, rec.Code)
+	r.Equal(retryAfterSeconds, rec.Header().Get("Retry-After"))
+
+	// the other requests are not limited
+	req = httptest.NewRequest(http.MethodGet, "/v2/myrepo/manifests/latest", nil)
+	rec = httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+	r.Equal(http.StatusOK, rec.Code)
+
+	close(unblock)
+	<-done
+}
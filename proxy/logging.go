@@ -0,0 +1,48 @@
+package proxy
+
+import (
+	"net/http"
+	"strings"
+
+	"github.com/forta-network/disco/utils"
+	log "github.com/sirupsen/logrus"
+)
+
+// maxRequestIDLength limits the request IDs which are accepted from the clients.
+const maxRequestIDLength = 128
+
+// withRequestLogger attaches a logger with the request ID and the repository fields of the
+// request to its context. The request ID is taken from the client or generated, passed to the
+// registry so that the driver logs have it, too, and returned to the client.
+func withRequestLogger(rw http.ResponseWriter, r *http.Request) *http.Request {
+	requestID := r.Header.Get(utils.RequestIDHeader)
+	if len(requestID) == 0 || len(requestID) > maxRequestIDLength {
+		requestID = utils.NewRequestID()
+		r.Header.Set(utils.RequestIDHeader, requestID)
+	}
+	rw.Header().Set(utils.RequestIDHeader, requestID)
+	logger := log.WithFields(requestLogFields(r.URL.Path)).WithField("requestId", requestID)
+	return r.WithContext(utils.WithLogger(r.Context(), logger))
+}
+
+// requestLogFields returns the repository, the digest or the tag and the CID fields of a
+// /v2/<name>/(manifests|blobs)/<reference> path.
+func requestLogFields(urlPath string) log.Fields {
+	fields := log.Fields{}
+	repoName, rest, ok := parsePullPath(urlPath)
+	if !ok {
+		return fields
+	}
+	reference := strings.TrimPrefix(strings.TrimPrefix(rest, "/manifests/"), "/blobs/")
+	fields["repository"] = repoName
+	switch {
+	case strings.HasPrefix(reference, "sha256:"):
+		fields["digest"] = reference
+	case len(reference) > 0 && !strings.Contains(reference, "/"):
+		fields["tag"] = reference
+	}
+	if utils.IsCIDv1(repoName) {
+		fields["cid"] = repoName
+	}
+	return fields
+}
@@ -0,0 +1,53 @@
+package proxy
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/forta-network/disco/utils"
+	log "github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/require"
+)
+
+func TestWithRequestLogger(t *testing.T) {
+	r := require.New(t)
+
+	const testCid = "bafybeielvnt5apaxbk6chthc4dc3p6vscpx3ai4uvti7gwh253j7facsxu"
+
+	// Given a manifest request without a request ID
+	rec := httptest.NewRecorder()
+	req := withRequestLogger(rec, httptest.NewRequest(http.MethodGet, "/v2/"+testCid+"/manifests/latest", nil))
+
+	// Then the request ID should be generated and passed to the registry and the client
+	requestID := rec.Header().Get(utils.RequestIDHeader)
+	r.Len(requestID, 16)
+	r.Equal(requestID, req.Header.Get(utils.RequestIDHeader))
+
+	// And the logs of the request should have the fields of the request
+	r.Equal(log.Fields{
+		"requestId":  requestID,
+		"repository": testCid,
+		"tag":        "latest",
+		"cid":        testCid,
+	}, utils.Logger(req.Context()).Data)
+
+	// When the client sends a request ID
+	rec = httptest.NewRecorder()
+	req = httptest.NewRequest(http.MethodGet, "/v2/myorg/myrepo/blobs/sha256:1234", nil)
+	req.Header.Set(utils.RequestIDHeader, "client-id")
+	req = withRequestLogger(rec, req)
+
+	// Then it should be kept
+	r.Equal("client-id", rec.Header().Get(utils.RequestIDHeader))
+	r.Equal(log.Fields{
+		"requestId":  "client-id",
+		"repository": "myorg/myrepo",
+		"digest":     "sha256:1234",
+	}, utils.Logger(req.Context()).Data)
+
+	// And the uploads and the other paths should not have the reference fields
+	r.Equal(log.Fields{"repository": "myrepo"}, requestLogFields("/v2/myrepo/blobs/uploads/1234"))
+	r.Empty(requestLogFields("/v2/"))
+	r.Empty(requestLogFields("/disco/repos"))
+}
@@ -0,0 +1,45 @@
+package proxy
+
+import (
+	"context"
+	"net/http"
+	"strings"
+
+	"github.com/forta-network/disco/proxy/services"
+	"github.com/forta-network/disco/utils"
+)
+
+// mediaTypeResolver resolves the recorded media types of the global repositories.
+type mediaTypeResolver interface {
+	ManifestMediaType(ctx context.Context, repoName string) (string, error)
+}
+
+// serveMediaType sets the Content-Type of the manifests of the global repositories to the media
+// types which were recorded while they were made global, so that the clients which are strict
+// about the media types can pull the cloned repositories.
+func serveMediaType(resolver mediaTypeResolver) func(resp *http.Response) error {
+	return func(resp *http.Response) error {
+		method := resp.Request.Method
+		if method != http.MethodGet && method != http.MethodHead || resp.StatusCode != http.StatusOK {
+			return nil
+		}
+		if !strings.Contains(resp.Request.URL.Path, "/manifests/") {
+			return nil
+		}
+		repoName, reference := parseManifestPath(resp.Request.URL.Path)
+		mediaType, err := resolver.ManifestMediaType(resp.Request.Context(), repoName)
+		if err != nil {
+			utils.Logger(resp.Request.Context()).WithError(err).Warn("failed to resolve the manifest media type")
+			return nil
+		}
+		// the manifests which a manifest list or an image index lists are pulled by their
+		// digests and have their own media types
+		if services.IsIndexMediaType(mediaType) && strings.HasPrefix(reference, "sha256:") {
+			return nil
+		}
+		if len(mediaType) > 0 {
+			resp.Header.Set("Content-Type", mediaType)
+		}
+		return nil
+	}
+}
@@ -0,0 +1,55 @@
+package proxy
+
+import (
+	"context"
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+type testMediaTypes map[string]string
+
+func (mediaTypes testMediaTypes) ManifestMediaType(ctx context.Context, repoName string) (string, error) {
+	return mediaTypes[repoName], nil
+}
+
+func TestServeMediaType(t *testing.T) {
+	r := require.New(t)
+
+	const ociManifest = "application/vnd.oci.image.manifest.v1+json"
+	setMediaType := serveMediaType(testMediaTypes{"bafybei": ociManifest})
+
+	resp := testResponse("/v2/bafybei/manifests/latest", "", "{}")
+	resp.Header.Set("Content-Type", "application/json")
+	r.NoError(setMediaType(resp))
+	r.Equal(ociManifest, resp.Header.Get("Content-Type"))
+
+	// the manifests of the platforms of a manifest list
+	const manifestList = "application/vnd.docker.distribution.manifest.list.v2+json"
+	setMediaType = serveMediaType(testMediaTypes{"bafybei": ociManifest, "bafybeilist": manifestList})
+	resp = testResponse("/v2/bafybeilist/manifests/latest", "", "{}")
+	r.NoError(setMediaType(resp))
+	r.Equal(manifestList, resp.Header.Get("Content-Type"))
+	resp = testResponse("/v2/bafybeilist/manifests/sha256:abc", "", "{}")
+	resp.Header.Set("Content-Type", ociManifest)
+	r.NoError(setMediaType(resp))
+	r.Equal(ociManifest, resp.Header.Get("Content-Type"))
+
+	// the media type is not recorded
+	resp = testResponse("/v2/myrepo/manifests/latest", "", "{}")
+	resp.Header.Set("Content-Type", "application/json")
+	r.NoError(setMediaType(resp))
+	r.Equal("application/json", resp.Header.Get("Content-Type"))
+
+	// not a manifest
+	resp = testResponse("/v2/bafybei/blobs/sha256:abc", "", "{}")
+	r.NoError(setMediaType(resp))
+	r.Empty(resp.Header.Get("Content-Type"))
+
+	// not found
+	resp = testResponse("/v2/bafybei/manifests/latest", "", "{}")
+	resp.StatusCode = http.StatusNotFound
+	r.NoError(setMediaType(resp))
+	r.Empty(resp.Header.Get("Content-Type"))
+}
@@ -0,0 +1,41 @@
+package proxy
+
+import (
+	"context"
+	"net/http"
+
+	"github.com/forta-network/disco/utils"
+)
+
+// blobMounter links the known blobs to the repositories.
+type blobMounter interface {
+	MountBlob(ctx context.Context, repoName, digest string) (bool, error)
+}
+
+// mountBlob responds to the cross-repository blob mounts of the blobs which Disco knows, like
+// the registry does after a mount, instead of letting the registry start a new upload when the
+// blob is not in the storage yet. The other mounts are left to the registry.
+func mountBlob(rw http.ResponseWriter, r *http.Request, mounter blobMounter) bool {
+	digest := r.URL.Query().Get("mount")
+	if r.Method != http.MethodPost || len(digest) == 0 {
+		return false
+	}
+	repoName, id, ok := parseUploadPath(r.URL.Path)
+	if !ok || len(id) > 0 {
+		return false
+	}
+	mounted, err := mounter.MountBlob(r.Context(), repoName, digest)
+	if err != nil {
+		// the client can still upload the blob
+		utils.Logger(r.Context()).WithError(err).Warn("failed to mount the blob")
+		return false
+	}
+	if !mounted {
+		return false
+	}
+	rw.Header().Set("Location", "/v2/"+repoName+"/blobs/"+digest)
+	rw.Header().Set("Content-Length", "0")
+	rw.Header().Set(digestHeader, digest)
+	rw.WriteHeader(http.StatusCreated)
+	return true
+}
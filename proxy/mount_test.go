@@ -0,0 +1,52 @@
+package proxy
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+// testBlobMounter mounts the known blobs.
+type testBlobMounter map[string]bool
+
+func (mounter testBlobMounter) MountBlob(ctx context.Context, repoName, digest string) (bool, error) {
+	if digest == "sha256:broken" {
+		return false, errors.New("failed")
+	}
+	return mounter[digest], nil
+}
+
+func TestMountBlob(t *testing.T) {
+	r := require.New(t)
+
+	mounter := testBlobMounter{"sha256:known": true}
+	serve := func(method, path string) (*httptest.ResponseRecorder, bool) {
+		rec := httptest.NewRecorder()
+		done := mountBlob(rec, httptest.NewRequest(method, path, nil), mounter)
+		return rec, done
+	}
+
+	// When a known blob is mounted
+	// Then it should be created in the repository
+	rec, done := serve(http.MethodPost, "/v2/myrepo/blobs/uploads/?mount=sha256:known&from=bafybeirepo")
+	r.True(done)
+	r.Equal(http.StatusCreated, rec.Code)
+	r.Equal("/v2/myrepo/blobs/sha256:known", rec.Header().Get("Location"))
+	r.Equal("sha256:known", rec.Header().Get(digestHeader))
+
+	// And the unknown blobs, the failures and the uploads should be left to the registry
+	for _, path := range []string{
+		"/v2/myrepo/blobs/uploads/?mount=sha256:unknown&from=bafybeirepo",
+		"/v2/myrepo/blobs/uploads/?mount=sha256:broken&from=bafybeirepo",
+		"/v2/myrepo/blobs/uploads/",
+	} {
+		_, done = serve(http.MethodPost, path)
+		r.False(done, path)
+	}
+	_, done = serve(http.MethodPatch, "/v2/myrepo/blobs/uploads/uuid?mount=sha256:known")
+	r.False(done)
+}
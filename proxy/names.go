@@ -0,0 +1,52 @@
+package proxy
+
+import (
+	"context"
+	"net/http"
+	"strings"
+
+	"github.com/forta-network/disco/utils"
+)
+
+// repoNameResolver resolves the repository names to the CID v1 repositories.
+type repoNameResolver interface {
+	ResolveRepoName(ctx context.Context, name string) (string, error)
+}
+
+// resolveRepoName rewrites the repository name in the path of the manifest and the blob pulls
+// with the CID v1 repository which the name resolves to, so that the repository is cloned and
+// checked by the CID.
+func resolveRepoName(rw http.ResponseWriter, r *http.Request, resolver repoNameResolver) bool {
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		return false
+	}
+	repoName, rest, ok := parsePullPath(r.URL.Path)
+	if !ok {
+		return false
+	}
+	repoCid, err := resolver.ResolveRepoName(r.Context(), repoName)
+	if err != nil {
+		utils.Logger(r.Context()).WithError(err).Warn("failed to resolve the repository name")
+		writeServiceError(rw, err)
+		return true
+	}
+	if repoCid != repoName {
+		r.URL.Path = "/v2/" + repoCid + rest
+		r.URL.RawPath = ""
+	}
+	return false
+}
+
+// parsePullPath returns the repository name and the rest of a /v2/<name>/manifests/<reference>
+// or a /v2/<name>/blobs/<digest> path.
+func parsePullPath(urlPath string) (repoName, rest string, ok bool) {
+	if !strings.HasPrefix(urlPath, "/v2/") {
+		return "", "", false
+	}
+	for _, kind := range []string{"/manifests/", "/blobs/"} {
+		if i := strings.LastIndex(urlPath, kind); i > len("/v2/") {
+			return urlPath[len("/v2/"):i], urlPath[i:], true
+		}
+	}
+	return "", "", false
+}
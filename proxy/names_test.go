@@ -0,0 +1,56 @@
+package proxy
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/forta-network/disco/proxy/services"
+	"github.com/stretchr/testify/require"
+)
+
+type testRepoNameResolver map[string]string
+
+func (resolver testRepoNameResolver) ResolveRepoName(ctx context.Context, name string) (string, error) {
+	if name == "failing.eth" {
+		return "", fmt.Errorf("%w: %s", services.ErrNameResolution, name)
+	}
+	if repoCid, ok := resolver[name]; ok {
+		return repoCid, nil
+	}
+	return name, nil
+}
+
+func TestResolveRepoName(t *testing.T) {
+	r := require.New(t)
+
+	const testCid = "bafybeielvnt5apaxbk6chthc4dc3p6vscpx3ai4uvti7gwh253j7facsxu"
+	resolver := testRepoNameResolver{"myimage.eth": testCid}
+	resolve := func(method, urlPath string) (*httptest.ResponseRecorder, *http.Request, bool) {
+		req := httptest.NewRequest(method, urlPath, nil)
+		rec := httptest.NewRecorder()
+		return rec, req, resolveRepoName(rec, req, resolver)
+	}
+
+	// When the manifest and the blobs of a known name are pulled
+	// Then the paths should have the CID instead
+	_, req, done := resolve(http.MethodGet, "/v2/myimage.eth/manifests/latest")
+	r.False(done)
+	r.Equal("/v2/"+testCid+"/manifests/latest", req.URL.Path)
+	_, req, done = resolve(http.MethodHead, "/v2/myimage.eth/blobs/sha256:1234")
+	r.False(done)
+	r.Equal("/v2/"+testCid+"/blobs/sha256:1234", req.URL.Path)
+
+	// And the unknown names and the pushes should be kept as they are
+	_, req, _ = resolve(http.MethodGet, "/v2/otherimage/manifests/latest")
+	r.Equal("/v2/otherimage/manifests/latest", req.URL.Path)
+	_, req, _ = resolve(http.MethodPut, "/v2/myimage.eth/manifests/latest")
+	r.Equal("/v2/myimage.eth/manifests/latest", req.URL.Path)
+
+	// And the failures should be unavailable
+	rec, _, done := resolve(http.MethodGet, "/v2/failing.eth/manifests/latest")
+	r.True(done)
+	r.Equal(http.StatusServiceUnavailable, rec.Code)
+}
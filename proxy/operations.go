@@ -0,0 +1,50 @@
+package proxy
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httputil"
+	"strings"
+
+	"github.com/forta-network/disco/proxy/services"
+	"github.com/forta-network/disco/utils"
+)
+
+// operationsPath is the path of the statuses of the clones and the globalizations.
+const operationsPath = "/v2/_disco/operations"
+
+// operationTracker reports the statuses of the running and the recently finished operations.
+type operationTracker interface {
+	Operation(id string) (*services.Operation, error)
+	Operations(repoName string) []*services.Operation
+}
+
+// handleOperations responds to /v2/_disco/operations with the running and the recently finished
+// clones and globalizations, optionally of the repository in the repository parameter, and to
+// /v2/_disco/operations/<id> with the status of one of them. The request is authorized by the
+// registry.
+func handleOperations(rw http.ResponseWriter, r *http.Request, rp *httputil.ReverseProxy, tracker operationTracker) {
+	if r.Method != http.MethodGet {
+		writeRegistryError(rw, http.StatusMethodNotAllowed, "UNSUPPORTED", "method not allowed")
+		return
+	}
+	if done := authorizeWithRegistry(rw, r, rp); done {
+		return
+	}
+	id := strings.TrimPrefix(strings.TrimPrefix(r.URL.Path, operationsPath), "/")
+	if len(id) == 0 {
+		rw.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(rw).Encode(map[string]interface{}{
+			"operations": tracker.Operations(r.URL.Query().Get("repository")),
+		})
+		return
+	}
+	op, err := tracker.Operation(id)
+	if err != nil {
+		utils.Logger(r.Context()).WithError(err).Debug("failed to get the operation")
+		writeServiceError(rw, err)
+		return
+	}
+	rw.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(rw).Encode(op)
+}
@@ -0,0 +1,76 @@
+package proxy
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/http/httputil"
+	"net/url"
+	"testing"
+
+	"github.com/forta-network/disco/proxy/services"
+	"github.com/stretchr/testify/require"
+)
+
+type testOperationTracker []*services.Operation
+
+func (tracker testOperationTracker) Operation(id string) (*services.Operation, error) {
+	for _, op := range tracker {
+		if op.ID == id {
+			return op, nil
+		}
+	}
+	return nil, fmt.Errorf("%w: %s", services.ErrOperationNotFound, id)
+}
+
+func (tracker testOperationTracker) Operations(repoName string) []*services.Operation {
+	ops := []*services.Operation{}
+	for _, op := range tracker {
+		if len(repoName) == 0 || op.Repository == repoName {
+			ops = append(ops, op)
+		}
+	}
+	return ops
+}
+
+func TestHandleOperations(t *testing.T) {
+	r := require.New(t)
+
+	registry := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {}))
+	defer registry.Close()
+	registryURL, err := url.Parse(registry.URL)
+	r.NoError(err)
+	rp := httputil.NewSingleHostReverseProxy(registryURL)
+	tracker := testOperationTracker{
+		{ID: "1", Kind: "clone", Repository: "bafy", State: services.OperationRunning, BytesCopied: 10,
+			Blobs: []*services.OperationBlob{{Digest: "sha256:1234", Cid: "bafkreiabc", State: "copied"}}},
+		{ID: "2", Kind: "make_global", Repository: "myrepo", State: "success"},
+	}
+	request := func(urlPath string) *httptest.ResponseRecorder {
+		rec := httptest.NewRecorder()
+		handleOperations(rec, httptest.NewRequest(http.MethodGet, urlPath, nil), rp, tracker)
+		return rec
+	}
+
+	// When an operation is requested
+	// Then its progress should be returned
+	rec := request(operationsPath + "/1")
+	r.Equal(http.StatusOK, rec.Code)
+	var op services.Operation
+	r.NoError(json.NewDecoder(rec.Body).Decode(&op))
+	r.Equal(tracker[0], &op)
+
+	// And the operations should be listed by repository
+	rec = request(operationsPath + "?repository=myrepo")
+	r.Equal(http.StatusOK, rec.Code)
+	var list struct {
+		Operations []*services.Operation `json:"operations"`
+	}
+	r.NoError(json.NewDecoder(rec.Body).Decode(&list))
+	r.Len(list.Operations, 1)
+	r.Equal("2", list.Operations[0].ID)
+
+	// And the unknown operations should not be found
+	r.Equal(http.StatusNotFound, request(operationsPath+"/3").Code)
+}
@@ -0,0 +1,66 @@
+package proxy
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"strings"
+
+	"github.com/forta-network/disco/proxy/services"
+	"github.com/forta-network/disco/utils"
+)
+
+// pullChecker evaluates the pull policies.
+type pullChecker interface {
+	CheckPull(ctx context.Context, req *services.PullRequest) error
+}
+
+// checkPullPolicies denies the manifest and the blob pulls which the pull policies deny. The
+// policies are checked before the repositories are cloned and the blobs are streamed, so that
+// the layers of a denied repository cannot be pulled by their digests either.
+func checkPullPolicies(rw http.ResponseWriter, r *http.Request, checker pullChecker) bool {
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		return false
+	}
+	repoName, rest, ok := parsePullPath(r.URL.Path)
+	if !ok {
+		return false
+	}
+	var req *services.PullRequest
+	switch {
+	case strings.HasPrefix(rest, "/manifests/"):
+		req = newPullRequest(r, services.PullKindManifest, repoName, strings.TrimPrefix(rest, "/manifests/"))
+	case strings.HasPrefix(rest, "/blobs/sha256:"):
+		req = newPullRequest(r, services.PullKindBlob, repoName, strings.TrimPrefix(rest, "/blobs/"))
+	default:
+		return false
+	}
+	err := checker.CheckPull(r.Context(), req)
+	if denied, ok := err.(*services.PullDeniedError); ok {
+		writeRegistryError(rw, http.StatusForbidden, "DENIED", denied.Message)
+		return true
+	}
+	if err != nil {
+		utils.Logger(r.Context()).WithError(err).Error("failed to check pull policies")
+		writeServiceError(rw, err)
+		return true
+	}
+	return false
+}
+
+// newPullRequest describes the manifest or the blob pull for the pull policies.
+func newPullRequest(r *http.Request, kind, repoName, reference string) *services.PullRequest {
+	clientIP := r.RemoteAddr
+	if host, _, err := net.SplitHostPort(clientIP); err == nil {
+		clientIP = host
+	}
+	return &services.PullRequest{
+		Kind:       kind,
+		Repository: repoName,
+		Reference:  reference,
+		Method:     r.Method,
+		Host:       r.Host,
+		ClientIP:   clientIP,
+		UserAgent:  r.UserAgent(),
+	}
+}
@@ -0,0 +1,62 @@
+package proxy
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/forta-network/disco/proxy/services"
+	"github.com/stretchr/testify/require"
+)
+
+// testPullChecker denies the pulls of a repository and records the pulls.
+type testPullChecker struct {
+	denied string
+	pulls  []*services.PullRequest
+}
+
+func (checker *testPullChecker) CheckPull(ctx context.Context, req *services.PullRequest) error {
+	checker.pulls = append(checker.pulls, req)
+	if req.Repository == checker.denied {
+		return &services.PullDeniedError{Message: "pulling " + req.Repository + " is not allowed"}
+	}
+	return nil
+}
+
+func TestCheckPullPolicies(t *testing.T) {
+	r := require.New(t)
+
+	checker := &testPullChecker{denied: "bafybeidenied"}
+	serve := func(method, path string) (*httptest.ResponseRecorder, bool) {
+		rec := httptest.NewRecorder()
+		done := checkPullPolicies(rec, httptest.NewRequest(method, path, nil), checker)
+		return rec, done
+	}
+
+	// When the manifest and the blobs of a denied repository are pulled
+	// Then they should be denied
+	for _, path := range []string{
+		"/v2/bafybeidenied/manifests/latest",
+		"/v2/bafybeidenied/blobs/sha256:1234",
+	} {
+		rec, done := serve(http.MethodGet, path)
+		r.True(done, path)
+		r.Equal(http.StatusForbidden, rec.Code, path)
+	}
+	_, done := serve(http.MethodHead, "/v2/bafybeidenied/blobs/sha256:1234")
+	r.True(done)
+	r.Equal(services.PullKindManifest, checker.pulls[0].Kind)
+	r.Equal(services.PullKindBlob, checker.pulls[1].Kind)
+	r.Equal("sha256:1234", checker.pulls[1].Reference)
+
+	// And the other pulls, the pushes and the uploads should be left to the registry
+	_, done = serve(http.MethodGet, "/v2/myorg/myrepo/blobs/sha256:1234")
+	r.False(done)
+	r.Equal("myorg/myrepo", checker.pulls[3].Repository)
+	_, done = serve(http.MethodPut, "/v2/bafybeidenied/manifests/latest")
+	r.False(done)
+	_, done = serve(http.MethodGet, "/v2/bafybeidenied/blobs/uploads/1234")
+	r.False(done)
+	r.Len(checker.pulls, 4)
+}
@@ -1,67 +1,383 @@
 package proxy
 
 import (
+	"bytes"
+	"encoding/json"
+	"errors"
 	"fmt"
+	"io"
+	"net"
 	"net/http"
 	"net/http/httputil"
 	"net/url"
 	"strings"
-	"time"
 
-	log "github.com/sirupsen/logrus"
+	"golang.org/x/net/http2"
+	"golang.org/x/net/http2/h2c"
 
 	"github.com/forta-network/disco/config"
 	"github.com/forta-network/disco/proxy/services"
+	"github.com/forta-network/disco/utils"
 )
 
-const requestTimeout = time.Hour
+// maxManifestSize is the size limit of the manifests which are read by the proxy.
+const maxManifestSize = 4 << 20
+
+// VirtualHost is a registry which is served by the proxy for the requests to its host.
+type VirtualHost struct {
+	Host   string
+	Config *config.Config
+	Disco  *services.Disco
+}
 
 // New creates a new Disco proxy which executes pre and post hooks before/after communication
-// with the distribution server is done.
-func New() (*http.Server, error) {
-	distrUrl, err := url.Parse(fmt.Sprintf("http://localhost%s", config.DistributionConfig.HTTP.Addr))
+// with the distribution server is done. The requests to the virtual hosts and the namespaces of
+// the tenants are proxied to their own registries.
+func New(cfg *config.Config, discoService *services.Disco, vhosts []*VirtualHost, tenants []*Tenant) (*http.Server, error) {
+	handler, err := newRegistryHandler(cfg, discoService, "")
 	if err != nil {
 		return nil, err
 	}
-
-	rp := httputil.NewSingleHostReverseProxy(distrUrl)
+	if len(tenants) > 0 {
+		tenantHandlers := make(map[string]*tenantHandler)
+		for _, tenant := range tenants {
+			namespace := tenant.Settings.Namespace
+			registryHandler, err := newRegistryHandler(tenant.Config, tenant.Disco, namespace)
+			if err != nil {
+				return nil, fmt.Errorf("failed to create the handler of namespace %s: %v", namespace, err)
+			}
+			tenantHandlers[namespace] = &tenantHandler{Tenant: tenant, handler: registryHandler}
+		}
+		handler = routeTenants(handler, tenantHandlers)
+	}
+	if len(vhosts) > 0 {
+		hostHandlers := make(map[string]http.Handler)
+		for _, vhost := range vhosts {
+			hostHandler, err := newRegistryHandler(vhost.Config, vhost.Disco, "")
+			if err != nil {
+				return nil, fmt.Errorf("failed to create the handler of virtual host %s: %v", vhost.Host, err)
+			}
+			hostHandlers[strings.ToLower(vhost.Host)] = hostHandler
+		}
+		handler = routeVirtualHosts(handler, hostHandlers)
+	}
+	if cfg.Proxy.Compression {
+		handler = compressResponses(handler)
+	}
+	if cfg.Limits.MaxUploads > 0 {
+		handler = limitConcurrentUploads(handler, utils.NewConcurrencyLimiter(cfg.Limits.MaxUploads, cfg.Limits.QueueSize))
+	}
+	if cfg.Limits.MaxInflightBytes > 0 {
+		handler = limitUploads(handler, newInflightLimiter(cfg.Limits.MaxInflightBytes))
+	}
+	if cfg.Proxy.HTTP2 && cfg.ServerTLS == nil {
+		handler = h2c.NewHandler(handler, &http2.Server{})
+	}
 
 	return &http.Server{
-		Addr:         fmt.Sprintf(":%d", config.Vars.DiscoPort),
-		Handler:      newHandler(rp, services.NewDiscoService()),
-		ReadTimeout:  requestTimeout,
-		WriteTimeout: requestTimeout,
-		IdleTimeout:  time.Second * 30,
+		Addr:         fmt.Sprintf(":%d", cfg.Vars.DiscoPort),
+		Handler:      handler,
+		TLSConfig:    cfg.ServerTLS,
+		ReadTimeout:  cfg.Timeouts.Read,
+		WriteTimeout: cfg.Timeouts.Write,
+		IdleTimeout:  cfg.Timeouts.Idle,
 	}, nil
 }
 
-// newHandler creates a new handler which consumes Disco service.
-func newHandler(rp *httputil.ReverseProxy, disco *services.Disco) http.Handler {
+// newRegistryHandler creates the handler which proxies the requests to the registry of the config.
+// The registry is mounted under the namespace if it is not empty.
+func newRegistryHandler(cfg *config.Config, discoService *services.Disco, namespace string) (http.Handler, error) {
+	scheme := "http"
+	if len(cfg.Distribution.HTTP.TLS.Certificate) > 0 {
+		scheme = "https"
+	}
+	distrUrl, err := url.Parse(fmt.Sprintf("%s://localhost%s", scheme, cfg.Distribution.HTTP.Addr))
+	if err != nil {
+		return nil, err
+	}
+
+	rp := httputil.NewSingleHostReverseProxy(distrUrl)
+	rp.Transport = utils.TraceTransport(cfg.ProxyTransport())
+	rp.FlushInterval = cfg.Proxy.FlushInterval
+	rp.BufferPool = newBufferPool(cfg.Proxy.BufferSize)
+	setMediaType := serveMediaType(discoService)
+	rp.ModifyResponse = func(resp *http.Response) error {
+		if len(namespace) > 0 {
+			mountLocation(resp, namespace)
+		}
+		if err := setMediaType(resp); err != nil {
+			return err
+		}
+		if err := redactErrorBody(resp); err != nil {
+			return err
+		}
+		return verifyDigest(resp)
+	}
+
+	// the admin API is served on the proxy port unless it has a dedicated listener
+	var admin http.Handler
+	if (len(cfg.Admin.Token) > 0 || len(cfg.Admin.HMACSecret) > 0) && len(cfg.Admin.Addr) == 0 {
+		admin = newAdminHandler(cfg.Admin, discoService)
+	}
+	// the federation peers can sign the resolve requests with the HMAC secret of the admin API
+	verifier := newRequestVerifier(cfg.Admin, discoService)
+	return traceRequests(newHandler(rp, discoService, admin, verifier)), nil
+}
+
+// routeVirtualHosts routes the requests to the handlers of their hosts and the other requests
+// to the default handler.
+func routeVirtualHosts(defaultHandler http.Handler, hostHandlers map[string]http.Handler) http.Handler {
+	return http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+		host := r.Host
+		if h, _, err := net.SplitHostPort(host); err == nil {
+			host = h
+		}
+		if handler, ok := hostHandlers[strings.ToLower(host)]; ok {
+			handler.ServeHTTP(rw, r)
+			return
+		}
+		defaultHandler.ServeHTTP(rw, r)
+	})
+}
+
+// newHandler creates a new handler which consumes Disco service. The admin API is served
+// only if the admin handler is not nil.
+func newHandler(rp *httputil.ReverseProxy, disco *services.Disco, admin http.Handler, verifier *requestVerifier) http.Handler {
 	return http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+		r = withRequestLogger(rw, r)
+		if r.URL.Path == healthzPath || r.URL.Path == readyzPath {
+			handleHealth(rw, r, rp, disco)
+			return
+		}
+		if admin != nil && strings.HasPrefix(r.URL.Path, adminPathPrefix) {
+			admin.ServeHTTP(rw, r)
+			return
+		}
+		if done := checkAPIKey(rw, r, disco); done {
+			return
+		}
+		if r.URL.Path == catalogPath {
+			handleCatalog(rw, r, rp, disco)
+			return
+		}
+		if strings.HasPrefix(r.URL.Path, resolvePathPrefix) {
+			handleResolve(rw, r, rp, verifier, disco)
+			return
+		}
+		if r.URL.Path == operationsPath || strings.HasPrefix(r.URL.Path, operationsPath+"/") {
+			handleOperations(rw, r, rp, disco)
+			return
+		}
+		if repoName, digest, ok := parseReferrersPath(r.URL.Path); ok {
+			handleReferrers(rw, r, rp, disco, repoName, digest)
+			return
+		}
+		if done := resolveRepoName(rw, r, disco); done {
+			return
+		}
+		if done := checkPullPolicies(rw, r, disco); done {
+			return
+		}
+		if done := checkBandwidth(rw, r, disco); done {
+			return
+		}
+		if done := streamBlob(rw, r, disco); done {
+			return
+		}
 		if done := preHandle(rw, r, disco); done {
 			return
 		}
-		rp.ServeHTTP(rw, r)
+		if r.Method == http.MethodDelete && strings.Contains(r.URL.Path, "/manifests/") {
+			handleDelete(rw, r, rp, disco)
+			return
+		}
+		if done := mountBlob(rw, r, disco); done {
+			return
+		}
+		if done := checkUploadOffset(rw, r, disco); done {
+			return
+		}
+		rw, recordUsage := measureUsage(rw, r, disco)
+		rw, recordUpload := trackUploads(rw, r, disco)
+		if isCanonicalPush(r, disco.CanonicalTag()) {
+			handleCanonicalPush(rw, r, rp, disco)
+		} else {
+			rp.ServeHTTP(rw, r)
+		}
+		recordUsage()
+		recordUpload()
 		postHandle(rw, r, disco)
 	})
 }
 
+// parseManifestPath returns the repository name and the reference from a /v2/<name>/manifests/<reference> path.
+func parseManifestPath(urlPath string) (repoName, reference string) {
+	i := strings.LastIndex(urlPath, "/manifests/")
+	return strings.TrimPrefix(urlPath[:i], "/v2/"), urlPath[i+len("/manifests/"):]
+}
+
+// writeRegistryError writes an error response in the format of the registry API. The secrets
+// are removed from the message.
+func writeRegistryError(rw http.ResponseWriter, status int, code, message string) {
+	rw.Header().Set("Content-Type", "application/json")
+	rw.WriteHeader(status)
+	_ = json.NewEncoder(rw).Encode(map[string]interface{}{
+		"errors": []map[string]string{{"code": code, "message": utils.Redact(message)}},
+	})
+}
+
+// serviceErrorStatus returns the status and the registry error code which match the error
+// from the Disco service, if it is a known error.
+func serviceErrorStatus(err error) (status int, code string, ok bool) {
+	switch {
+	case errors.Is(err, services.ErrRepoNotFound):
+		return http.StatusNotFound, "NAME_UNKNOWN", true
+	case errors.Is(err, services.ErrOperationNotFound):
+		return http.StatusNotFound, "NOT_FOUND", true
+	case errors.Is(err, services.ErrNotCIDName):
+		return http.StatusBadRequest, "NAME_INVALID", true
+	case errors.Is(err, services.ErrInvalidDigest):
+		return http.StatusBadRequest, "DIGEST_INVALID", true
+	case errors.Is(err, services.ErrInvalidPrefetch), errors.Is(err, services.ErrInvalidTree):
+		return http.StatusBadRequest, "BAD_REQUEST", true
+	case errors.Is(err, services.ErrAlreadyGlobal):
+		return http.StatusForbidden, "DENIED", true
+	case errors.Is(err, services.ErrInvalidManifest):
+		return http.StatusBadRequest, "MANIFEST_INVALID", true
+	case errors.Is(err, services.ErrCloneFailed), errors.Is(err, services.ErrNameResolution):
+		return http.StatusServiceUnavailable, "UNAVAILABLE", true
+	case errors.Is(err, services.ErrBandwidthExceeded):
+		return http.StatusTooManyRequests, "TOOMANYREQUESTS", true
+	}
+	return 0, "", false
+}
+
+// writeServiceError responds with the status which matches the error from the Disco service.
+// The details of the unknown errors are only logged.
+func writeServiceError(rw http.ResponseWriter, err error) {
+	if errors.Is(err, utils.ErrQueueFull) {
+		writeTooManyRequests(rw, err)
+		return
+	}
+	status, code, ok := serviceErrorStatus(err)
+	if !ok {
+		writeRegistryError(rw, http.StatusInternalServerError, "UNKNOWN", "internal error")
+		return
+	}
+	writeRegistryError(rw, status, code, err.Error())
+}
+
+// handleDelete removes the global repositories of the manifest after the registry deletes it.
+func handleDelete(rw http.ResponseWriter, r *http.Request, rp *httputil.ReverseProxy, disco *services.Disco) {
+	repoName, reference := parseManifestPath(r.URL.Path)
+	// resolve before deleting since the tag is deleted, too
+	manifestDigest, err := disco.ResolveManifestDigest(r.Context(), repoName, reference)
+	if err != nil {
+		utils.Logger(r.Context()).WithError(err).Warn("failed to resolve the deleted manifest")
+	}
+	sw := &statusWriter{ResponseWriter: rw}
+	rp.ServeHTTP(sw, r)
+	if sw.status != http.StatusAccepted || len(manifestDigest) == 0 {
+		return
+	}
+	if err := disco.DeleteGlobalRepos(r.Context(), manifestDigest); err != nil {
+		utils.Logger(r.Context()).WithError(err).Error("failed to delete global repos")
+	}
+}
+
+// statusWriter records the status code and the size of the response.
+type statusWriter struct {
+	http.ResponseWriter
+	status  int
+	written int64
+}
+
+func (sw *statusWriter) WriteHeader(status int) {
+	sw.status = status
+	sw.ResponseWriter.WriteHeader(status)
+}
+
+func (sw *statusWriter) Write(b []byte) (int, error) {
+	if sw.status == 0 {
+		sw.status = http.StatusOK
+	}
+	n, err := sw.ResponseWriter.Write(b)
+	sw.written += int64(n)
+	return n, err
+}
+
+func (sw *statusWriter) Flush() {
+	if flusher, ok := sw.ResponseWriter.(http.Flusher); ok {
+		flusher.Flush()
+	}
+}
+
 func preHandle(rw http.ResponseWriter, r *http.Request, disco *services.Disco) bool {
-	// Disallow overwriting to CID v1 and digest repos.
-	if r.Method == http.MethodPut && strings.Contains(r.URL.Path, "/manifests/latest") {
-		repoName := strings.Split(r.URL.Path[1:], "/")[1]
-		if disco.IsOnlyPullable(repoName) {
-			rw.WriteHeader(401)
+	if r.Method == http.MethodPut && strings.Contains(r.URL.Path, "/manifests/") {
+		repoName, reference := parseManifestPath(r.URL.Path)
+		err := disco.CheckPush(r.Context(), repoName, reference)
+		if denied, ok := err.(*services.PushDeniedError); ok {
+			writeRegistryError(rw, http.StatusForbidden, "DENIED", denied.Message)
+			return true
+		}
+		if err != nil {
+			utils.Logger(r.Context()).WithError(err).Error("failed to check push rules")
+			writeServiceError(rw, err)
+			return true
+		}
+		b, err := io.ReadAll(io.LimitReader(r.Body, maxManifestSize+1))
+		if err != nil {
+			utils.Logger(r.Context()).WithError(err).Error("failed to read the pushed manifest")
+			rw.WriteHeader(500)
+			return true
+		}
+		if len(b) > maxManifestSize {
+			writeRegistryError(rw, http.StatusBadRequest, "MANIFEST_INVALID", "manifest is too large")
+			return true
+		}
+		// give the manifest back to the registry
+		r.Body = io.NopCloser(bytes.NewReader(b))
+		if err := services.CheckManifestSchema(r.Header.Get("Content-Type"), b); err != nil {
+			writeServiceError(rw, err)
 			return true
 		}
+		if disco.PushSigningEnabled() {
+			err = disco.VerifyPushSignature(b, r.Header.Get(services.SignatureHeader))
+			if denied, ok := err.(*services.PushDeniedError); ok {
+				writeRegistryError(rw, http.StatusForbidden, "DENIED", denied.Message)
+				return true
+			}
+		}
 	}
 
 	if (r.Method == http.MethodHead || r.Method == http.MethodGet) && strings.Contains(r.URL.Path, "/manifests/") {
-		repoName := strings.Split(r.URL.Path[1:], "/")[1]
+		repoName, reference := parseManifestPath(r.URL.Path)
 		if err := disco.CloneGlobalRepo(r.Context(), repoName); err != nil {
-			log.WithError(err).Error("failed to clone global repo")
-			// TODO: Handle 404
-			rw.WriteHeader(500)
+			utils.Logger(r.Context()).WithError(err).Error("failed to clone global repo")
+			writeServiceError(rw, err)
+			return true
+		}
+		err := disco.VerifyPullSignature(r.Context(), repoName, reference)
+		if denied, ok := err.(*services.PullDeniedError); ok {
+			writeRegistryError(rw, http.StatusForbidden, "DENIED", denied.Message)
+			return true
+		}
+		if err != nil {
+			utils.Logger(r.Context()).WithError(err).Error("failed to verify the pull signature")
+			writeServiceError(rw, err)
+			return true
+		}
+		if r.Method == http.MethodGet && disco.IsOnlyPullable(repoName) {
+			disco.RecordPull(repoName)
+		}
+	}
+
+	if (r.Method == http.MethodHead || r.Method == http.MethodGet) && strings.Contains(r.URL.Path, "/blobs/sha256:") {
+		i := strings.LastIndex(r.URL.Path, "/blobs/")
+		repoName, digest := strings.TrimPrefix(r.URL.Path[:i], "/v2/"), r.URL.Path[i+len("/blobs/"):]
+		if err := disco.CloneBlob(r.Context(), repoName, digest); err != nil {
+			utils.Logger(r.Context()).WithError(err).Error("failed to clone the blob")
+			writeServiceError(rw, err)
 			return true
 		}
 	}
@@ -69,10 +385,10 @@ func preHandle(rw http.ResponseWriter, r *http.Request, disco *services.Disco) b
 }
 
 func postHandle(rw http.ResponseWriter, r *http.Request, disco *services.Disco) {
-	if r.Method == http.MethodPut && strings.Contains(r.URL.Path, "/manifests/latest") {
-		repoName := strings.Split(r.URL.Path[1:], "/")[1]
-		if err := disco.MakeGlobalRepo(r.Context(), repoName); err != nil {
-			log.WithError(err).Error("failed to make global repo")
-		}
+	if r.Method == http.MethodGet && strings.Contains(r.URL.Path, "/manifests/") {
+		disco.PrefetchBlobs(parseManifestPath(r.URL.Path))
+	}
+	if r.Method == http.MethodPut && strings.Contains(r.URL.Path, "/manifests/sha256:") {
+		disco.RecordDigestPush(parseManifestPath(r.URL.Path))
 	}
 }
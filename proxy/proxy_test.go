@@ -0,0 +1,65 @@
+package proxy
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/forta-network/disco/proxy/services"
+	"github.com/forta-network/disco/utils"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRouteVirtualHosts(t *testing.T) {
+	r := require.New(t)
+
+	namedHandler := func(name string) http.Handler {
+		return http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+			_, _ = rw.Write([]byte(name))
+		})
+	}
+	handler := routeVirtualHosts(namedHandler("default"), map[string]http.Handler{
+		"team-a.example.com": namedHandler("team-a"),
+	})
+
+	for host, expected := range map[string]string{
+		"team-a.example.com":      "team-a",
+		"Team-A.example.com:1970": "team-a",
+		"team-b.example.com":      "default",
+		"localhost:1970":          "default",
+	} {
+		req := httptest.NewRequest(http.MethodGet, "/v2/", nil)
+		req.Host = host
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+		r.Equal(expected, rec.Body.String(), host)
+	}
+}
+
+func TestWriteServiceError(t *testing.T) {
+	r := require.New(t)
+
+	for err, status := range map[error]int{
+		fmt.Errorf("%w: myrepo:v1", services.ErrRepoNotFound):         http.StatusNotFound,
+		fmt.Errorf("'myrepo' is %w", services.ErrNotCIDName):          http.StatusBadRequest,
+		fmt.Errorf("%w: %s", services.ErrAlreadyGlobal, "bafy"):       http.StatusForbidden,
+		fmt.Errorf("%w: timeout", services.ErrCloneFailed):            http.StatusServiceUnavailable,
+		fmt.Errorf("%w: missing config", services.ErrInvalidManifest): http.StatusBadRequest,
+		fmt.Errorf("failed to start cloning: %w", utils.ErrQueueFull): http.StatusTooManyRequests,
+		errors.New("ipfs node is down"):                               http.StatusInternalServerError,
+	} {
+		rec := httptest.NewRecorder()
+		writeServiceError(rec, err)
+		r.Equal(status, rec.Code, err.Error())
+	}
+}
+
+func TestParseManifestPath(t *testing.T) {
+	r := require.New(t)
+
+	repoName, reference := parseManifestPath("/v2/myorg/myrepo/manifests/latest")
+	r.Equal("myorg/myrepo", repoName)
+	r.Equal("latest", reference)
+}
@@ -0,0 +1,78 @@
+package proxy
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"net/http"
+	"strings"
+
+	"github.com/forta-network/disco/proxy/services"
+	"github.com/forta-network/disco/utils"
+)
+
+// globalRepoMaker makes the pushed repositories global.
+type globalRepoMaker interface {
+	MakeGlobalRepo(ctx context.Context, repoName string) error
+}
+
+// isCanonicalPush tells if the request pushes the manifest with the canonical tag.
+func isCanonicalPush(r *http.Request, canonicalTag string) bool {
+	return r.Method == http.MethodPut && strings.HasSuffix(r.URL.Path, "/manifests/"+canonicalTag)
+}
+
+// handleCanonicalPush holds the response of the registry to a manifest push with the canonical tag
+// until the repository is made global, so that the client is denied if the image fails the
+// security scan and is quarantined. The other failures are only logged, since the push succeeded.
+func handleCanonicalPush(rw http.ResponseWriter, r *http.Request, registry http.Handler, maker globalRepoMaker) {
+	bw := &bufferedWriter{header: make(http.Header)}
+	registry.ServeHTTP(bw, r)
+	if bw.status == http.StatusCreated {
+		repoName := strings.Split(r.URL.Path[1:], "/")[1]
+		err := maker.MakeGlobalRepo(r.Context(), repoName)
+		switch {
+		case errors.Is(err, services.ErrQuarantined):
+			utils.Logger(r.Context()).WithError(err).Warn("pushed image is quarantined")
+			writeRegistryError(rw, http.StatusForbidden, "DENIED", err.Error())
+			return
+		case err != nil:
+			utils.Logger(r.Context()).WithError(err).Error("failed to make global repo")
+		}
+	}
+	bw.writeTo(rw)
+}
+
+// bufferedWriter keeps the response in the memory until it is written to the client. It is used
+// only for the manifest responses, which are small.
+type bufferedWriter struct {
+	header http.Header
+	status int
+	body   bytes.Buffer
+}
+
+func (bw *bufferedWriter) Header() http.Header {
+	return bw.header
+}
+
+func (bw *bufferedWriter) WriteHeader(status int) {
+	if bw.status == 0 {
+		bw.status = status
+	}
+}
+
+func (bw *bufferedWriter) Write(b []byte) (int, error) {
+	if bw.status == 0 {
+		bw.status = http.StatusOK
+	}
+	return bw.body.Write(b)
+}
+
+func (bw *bufferedWriter) writeTo(rw http.ResponseWriter) {
+	for key, values := range bw.header {
+		rw.Header()[key] = values
+	}
+	if bw.status != 0 {
+		rw.WriteHeader(bw.status)
+	}
+	_, _ = rw.Write(bw.body.Bytes())
+}
@@ -0,0 +1,65 @@
+package proxy
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/forta-network/disco/proxy/services"
+	"github.com/stretchr/testify/require"
+)
+
+// testGlobalRepoMaker fails the repositories with the errors.
+type testGlobalRepoMaker map[string]error
+
+func (maker testGlobalRepoMaker) MakeGlobalRepo(ctx context.Context, repoName string) error {
+	return maker[repoName]
+}
+
+func TestHandleCanonicalPush(t *testing.T) {
+	r := require.New(t)
+
+	registry := http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/v2/invalid/manifests/latest" {
+			rw.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		rw.Header().Set(digestHeader, "sha256:1234")
+		rw.WriteHeader(http.StatusCreated)
+	})
+	maker := testGlobalRepoMaker{
+		"quarantined": fmt.Errorf("%w: malware found", services.ErrQuarantined),
+		"failing":     errors.New("ipfs node is down"),
+		"invalid":     errors.New("should not be called"),
+	}
+	serve := func(repoName string) *httptest.ResponseRecorder {
+		rec := httptest.NewRecorder()
+		req := httptest.NewRequest(http.MethodPut, "/v2/"+repoName+"/manifests/latest", nil)
+		r.True(isCanonicalPush(req, "latest"))
+		handleCanonicalPush(rec, req, registry, maker)
+		return rec
+	}
+
+	// When an image passes the scan
+	// Then the response of the registry should be sent
+	rec := serve("myrepo")
+	r.Equal(http.StatusCreated, rec.Code)
+	r.Equal("sha256:1234", rec.Header().Get(digestHeader))
+
+	// When an image is quarantined
+	// Then the push should be denied
+	rec = serve("quarantined")
+	r.Equal(http.StatusForbidden, rec.Code)
+	r.Contains(rec.Body.String(), "DENIED")
+	r.Contains(rec.Body.String(), "malware found")
+	r.Empty(rec.Header().Get(digestHeader))
+
+	// And the other failures should not fail the push
+	r.Equal(http.StatusCreated, serve("failing").Code)
+
+	// And the failed pushes should not be made global
+	r.Equal(http.StatusBadRequest, serve("invalid").Code)
+}
@@ -0,0 +1,34 @@
+package proxy
+
+import (
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/forta-network/disco/utils"
+)
+
+// maxRedactedBodySize limits the size of the error responses of the registry which are redacted.
+const maxRedactedBodySize = 64 << 10
+
+// redactErrorBody removes the secrets from the error responses of the registry, since the registry
+// puts the errors of the storage drivers in their details.
+func redactErrorBody(resp *http.Response) error {
+	if resp.StatusCode < http.StatusBadRequest || resp.Request.Method == http.MethodHead {
+		return nil
+	}
+	if resp.ContentLength < 0 || resp.ContentLength > maxRedactedBodySize {
+		return nil
+	}
+	b, err := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	if err != nil {
+		return err
+	}
+	redacted := utils.Redact(string(b))
+	resp.Body = io.NopCloser(strings.NewReader(redacted))
+	resp.ContentLength = int64(len(redacted))
+	resp.Header.Set("Content-Length", strconv.Itoa(len(redacted)))
+	return nil
+}
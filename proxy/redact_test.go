@@ -0,0 +1,29 @@
+package proxy
+
+import (
+	"io"
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestRedactErrorBody(t *testing.T) {
+	r := require.New(t)
+
+	const errBody = `{"errors":[{"code":"UNKNOWN","detail":"map[accesskey:AKIA123]"}]}`
+	resp := testResponse("/v2/myrepo/blobs/sha256:1234", "", errBody)
+	resp.StatusCode = http.StatusInternalServerError
+	r.NoError(redactErrorBody(resp))
+	b, err := io.ReadAll(resp.Body)
+	r.NoError(err)
+	r.Equal(`{"errors":[{"code":"UNKNOWN","detail":"map[accesskey:<redacted>]"}]}`, string(b))
+	r.Equal(int64(len(b)), resp.ContentLength)
+
+	// the successful responses are not touched
+	resp = testResponse("/v2/myrepo/blobs/sha256:1234", "", "accesskey:AKIA123")
+	r.NoError(redactErrorBody(resp))
+	b, err = io.ReadAll(resp.Body)
+	r.NoError(err)
+	r.Equal("accesskey:AKIA123", string(b))
+}
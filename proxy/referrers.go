@@ -0,0 +1,60 @@
+package proxy
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httputil"
+	"strings"
+
+	"github.com/forta-network/disco/proxy/services"
+	"github.com/forta-network/disco/utils"
+)
+
+const mediaTypeOCIIndex = "application/vnd.oci.image.index.v1+json"
+
+// referrersLister lists the artifacts which are attached to the manifests.
+type referrersLister interface {
+	Referrers(ctx context.Context, repoName, digest, artifactType string) (*services.ReferrersIndex, error)
+}
+
+// parseReferrersPath returns the repository name and the digest from a
+// /v2/<name>/referrers/<digest> path.
+func parseReferrersPath(urlPath string) (repoName, digest string, ok bool) {
+	i := strings.LastIndex(urlPath, "/referrers/")
+	if i < 0 || !strings.HasPrefix(urlPath, "/v2/") {
+		return "", "", false
+	}
+	repoName, digest = urlPath[len("/v2/"):i], urlPath[i+len("/referrers/"):]
+	if len(repoName) == 0 || len(digest) == 0 || strings.Contains(digest, "/") {
+		return "", "", false
+	}
+	return repoName, digest, true
+}
+
+// handleReferrers serves the OCI referrers API, which lists the signatures, the attestations and
+// the other artifacts which are attached to a manifest. The request is authorized by the registry.
+func handleReferrers(rw http.ResponseWriter, r *http.Request, rp *httputil.ReverseProxy, lister referrersLister, repoName, digest string) {
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		writeRegistryError(rw, http.StatusMethodNotAllowed, "UNSUPPORTED", "method not allowed")
+		return
+	}
+	if done := authorizeWithRegistry(rw, r, rp); done {
+		return
+	}
+	artifactType := r.URL.Query().Get("artifactType")
+	index, err := lister.Referrers(r.Context(), repoName, digest, artifactType)
+	if err != nil {
+		utils.Logger(r.Context()).WithError(err).Warn("failed to list the referrers")
+		writeServiceError(rw, err)
+		return
+	}
+	rw.Header().Set("Content-Type", mediaTypeOCIIndex)
+	if len(artifactType) > 0 {
+		rw.Header().Set("OCI-Filters-Applied", "artifactType")
+	}
+	if r.Method == http.MethodHead {
+		return
+	}
+	_ = json.NewEncoder(rw).Encode(index)
+}
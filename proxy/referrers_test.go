@@ -0,0 +1,86 @@
+package proxy
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/http/httputil"
+	"net/url"
+	"testing"
+
+	"github.com/forta-network/disco/proxy/services"
+	"github.com/stretchr/testify/require"
+)
+
+type testReferrers map[string][]*services.Referrer
+
+func (referrers testReferrers) Referrers(ctx context.Context, repoName, digest, artifactType string) (*services.ReferrersIndex, error) {
+	if digest == "latest" {
+		return nil, fmt.Errorf("%w: %s", services.ErrInvalidDigest, digest)
+	}
+	index := &services.ReferrersIndex{SchemaVersion: 2, MediaType: mediaTypeOCIIndex, Manifests: []*services.Referrer{}}
+	for _, referrer := range referrers[repoName+"@"+digest] {
+		if len(artifactType) == 0 || referrer.ArtifactType == artifactType {
+			index.Manifests = append(index.Manifests, referrer)
+		}
+	}
+	return index, nil
+}
+
+func TestParseReferrersPath(t *testing.T) {
+	r := require.New(t)
+
+	repoName, digest, ok := parseReferrersPath("/v2/myorg/myrepo/referrers/sha256:1234")
+	r.True(ok)
+	r.Equal("myorg/myrepo", repoName)
+	r.Equal("sha256:1234", digest)
+
+	_, _, ok = parseReferrersPath("/v2/myorg/referrers/manifests/latest")
+	r.False(ok, "should not take the manifests of a repo named referrers")
+	_, _, ok = parseReferrersPath("/v2/myrepo/manifests/latest")
+	r.False(ok)
+}
+
+func TestHandleReferrers(t *testing.T) {
+	r := require.New(t)
+
+	// Given a registry without auth
+	registry := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {}))
+	defer registry.Close()
+	registryURL, err := url.Parse(registry.URL)
+	r.NoError(err)
+	rp := httputil.NewSingleHostReverseProxy(registryURL)
+	lister := testReferrers{"myrepo@" + testResolveDigest: {
+		{MediaType: "application/vnd.oci.image.manifest.v1+json", ArtifactType: "application/spdx+json", Digest: "sha256:abcd", Size: 10},
+	}}
+	list := func(target string) *httptest.ResponseRecorder {
+		rec := httptest.NewRecorder()
+		req := httptest.NewRequest(http.MethodGet, target, nil)
+		repoName, digest, ok := parseReferrersPath(req.URL.Path)
+		r.True(ok)
+		handleReferrers(rec, req, rp, lister, repoName, digest)
+		return rec
+	}
+
+	// When the referrers of a manifest are listed
+	rec := list("/v2/myrepo/referrers/" + testResolveDigest)
+
+	// Then they should be returned as an image index
+	r.Equal(http.StatusOK, rec.Code)
+	r.Equal(mediaTypeOCIIndex, rec.Header().Get("Content-Type"))
+	var index services.ReferrersIndex
+	r.NoError(json.NewDecoder(rec.Body).Decode(&index))
+	r.Len(index.Manifests, 1)
+	r.Equal("sha256:abcd", index.Manifests[0].Digest)
+
+	// And the filter should be applied and reported
+	rec = list("/v2/myrepo/referrers/" + testResolveDigest + "?artifactType=application/vnd.example")
+	r.Equal("artifactType", rec.Header().Get("OCI-Filters-Applied"))
+	r.NoError(json.NewDecoder(rec.Body).Decode(&index))
+	r.Empty(index.Manifests)
+
+	// And the invalid digests should be rejected
+	r.Equal(http.StatusBadRequest, list("/v2/myrepo/referrers/latest").Code)
+}
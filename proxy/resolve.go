@@ -0,0 +1,85 @@
+package proxy
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httputil"
+	"strings"
+
+	"github.com/forta-network/disco/proxy/services"
+	"github.com/forta-network/disco/utils"
+)
+
+// resolvePathPrefix is the prefix of the route which resolves the manifest digests to their CIDs.
+const resolvePathPrefix = "/v2/_disco/resolve/"
+
+// repoResolver resolves the manifest digests to their CID v1 repositories.
+type repoResolver interface {
+	Resolve(ctx context.Context, digest string) (*services.ResolvedRepo, error)
+}
+
+// handleResolve responds to /v2/_disco/resolve/<digest> with the CID v1 repository of the
+// manifest digest and the CIDs of its blobs. The request is authorized by the registry unless it
+// is signed with the HMAC secret of the admin API, e.g. by a federation peer.
+func handleResolve(rw http.ResponseWriter, r *http.Request, rp *httputil.ReverseProxy, verifier *requestVerifier, resolver repoResolver) {
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		writeRegistryError(rw, http.StatusMethodNotAllowed, "UNSUPPORTED", "method not allowed")
+		return
+	}
+	if verifier != nil && utils.IsSignedRequest(r) {
+		if err := verifier.verify(r); err != nil {
+			writeRegistryError(rw, http.StatusUnauthorized, "UNAUTHORIZED", "invalid signed request: "+err.Error())
+			return
+		}
+	} else if done := authorizeWithRegistry(rw, r, rp); done {
+		return
+	}
+	resolved, err := resolver.Resolve(r.Context(), strings.TrimPrefix(r.URL.Path, resolvePathPrefix))
+	if err != nil {
+		utils.Logger(r.Context()).WithError(err).Warn("failed to resolve the manifest digest")
+		writeServiceError(rw, err)
+		return
+	}
+	rw.Header().Set("Content-Type", "application/json")
+	if r.Method == http.MethodHead {
+		return
+	}
+	_ = json.NewEncoder(rw).Encode(resolved)
+}
+
+// authorizeWithRegistry checks the credentials of the request with the base endpoint of the
+// registry, so that the routes of Disco under /v2/ need the same auth as the registry.
+func authorizeWithRegistry(rw http.ResponseWriter, r *http.Request, rp *httputil.ReverseProxy) bool {
+	probe, err := http.NewRequestWithContext(r.Context(), http.MethodGet, "/v2/", nil)
+	if err != nil {
+		writeRegistryError(rw, http.StatusInternalServerError, "UNKNOWN", "internal error")
+		return true
+	}
+	probe.Header = r.Header.Clone()
+	probe.Host = r.Host
+	rp.Director(probe)
+	transport := rp.Transport
+	if transport == nil {
+		transport = http.DefaultTransport
+	}
+	resp, err := transport.RoundTrip(probe)
+	if err != nil {
+		utils.Logger(r.Context()).WithError(err).Error("failed to check the auth with the registry")
+		writeRegistryError(rw, http.StatusBadGateway, "UNAVAILABLE", "registry is unavailable")
+		return true
+	}
+	resp.Body.Close()
+	switch resp.StatusCode {
+	case http.StatusOK:
+		return false
+	case http.StatusUnauthorized:
+		if challenge := resp.Header.Get("WWW-Authenticate"); len(challenge) > 0 {
+			rw.Header().Set("WWW-Authenticate", challenge)
+		}
+		writeRegistryError(rw, http.StatusUnauthorized, "UNAUTHORIZED", "authentication required")
+	default:
+		writeRegistryError(rw, http.StatusForbidden, "DENIED", "access denied")
+	}
+	return true
+}
@@ -0,0 +1,122 @@
+package proxy
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/http/httputil"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/forta-network/disco/config"
+	"github.com/forta-network/disco/proxy/services"
+	"github.com/forta-network/disco/utils"
+	"github.com/stretchr/testify/require"
+)
+
+const testResolveDigest = "sha256:46e0f9d2b6c5fb7d4d5e6f0a9f8b7c6d5e4f3a2b1c0d9e8f7a6b5c4d3e2f1a0b"
+
+type testResolver map[string]*services.ResolvedRepo
+
+func (resolver testResolver) Resolve(ctx context.Context, digest string) (*services.ResolvedRepo, error) {
+	resolved, ok := resolver[digest]
+	if !ok {
+		return nil, fmt.Errorf("%w: %s", services.ErrRepoNotFound, digest)
+	}
+	return resolved, nil
+}
+
+func TestHandleResolve(t *testing.T) {
+	r := require.New(t)
+
+	// Given a registry which needs a token
+	registry := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		r.Equal("/v2/", req.URL.Path)
+		if req.Header.Get("Authorization") != "Bearer token" {
+			rw.Header().Set("WWW-Authenticate", `Bearer realm="auth"`)
+			rw.WriteHeader(http.StatusUnauthorized)
+		}
+	}))
+	defer registry.Close()
+	registryURL, err := url.Parse(registry.URL)
+	r.NoError(err)
+	rp := httputil.NewSingleHostReverseProxy(registryURL)
+	resolver := testResolver{testResolveDigest: {
+		Digest: testResolveDigest,
+		Cid:    "bafybeibbkcck6lz37hcipp2mwtfdgstydizjq45z4fkqq4va73mp7qzutu",
+		Blobs:  []*services.ResolvedBlob{{Digest: testResolveDigest, Cid: "bafkreiabc"}},
+	}}
+	resolve := func(digest, token string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, resolvePathPrefix+digest, nil)
+		if len(token) > 0 {
+			req.Header.Set("Authorization", "Bearer "+token)
+		}
+		rec := httptest.NewRecorder()
+		handleResolve(rec, req, rp, nil, resolver)
+		return rec
+	}
+
+	// When the digest is resolved without the token
+	// Then it should be challenged like the registry does
+	rec := resolve(testResolveDigest, "")
+	r.Equal(http.StatusUnauthorized, rec.Code)
+	r.Equal(`Bearer realm="auth"`, rec.Header().Get("WWW-Authenticate"))
+
+	// When it is resolved with the token
+	// Then the CIDs should be returned
+	rec = resolve(testResolveDigest, "token")
+	r.Equal(http.StatusOK, rec.Code)
+	var resolved services.ResolvedRepo
+	r.NoError(json.NewDecoder(rec.Body).Decode(&resolved))
+	r.Equal(resolver[testResolveDigest], &resolved)
+
+	// And the unknown digests should not be found
+	r.Equal(http.StatusNotFound, resolve("sha256:1234", "token").Code)
+}
+
+func TestHandleResolve_SignedRequests(t *testing.T) {
+	r := require.New(t)
+
+	// Given a registry which denies the requests without a token
+	registry := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		rw.WriteHeader(http.StatusUnauthorized)
+	}))
+	defer registry.Close()
+	registryURL, err := url.Parse(registry.URL)
+	r.NoError(err)
+	rp := httputil.NewSingleHostReverseProxy(registryURL)
+	resolver := testResolver{testResolveDigest: {Digest: testResolveDigest, Cid: "bafy"}}
+
+	const secret = "0123456789abcdef0123456789abcdef"
+	verifier := newRequestVerifier(config.AdminConfig{HMACSecret: secret, HMACMaxSkew: time.Minute}, &testAdminService{})
+	serve := func(req *http.Request) *httptest.ResponseRecorder {
+		rec := httptest.NewRecorder()
+		handleResolve(rec, req, rp, verifier, resolver)
+		return rec
+	}
+
+	// When a federation peer signs the request
+	// Then it should be resolved without a token, only once
+	req := httptest.NewRequest(http.MethodGet, resolvePathPrefix+testResolveDigest, nil)
+	r.NoError(utils.SignRequest(req, secret))
+	replayed := req.Clone(req.Context())
+	r.Equal(http.StatusOK, serve(req).Code)
+	rec := serve(replayed)
+	r.Equal(http.StatusUnauthorized, rec.Code)
+	r.Contains(rec.Body.String(), "nonce is used already")
+
+	// And the requests with a bad signature should be denied
+	req = httptest.NewRequest(http.MethodGet, resolvePathPrefix+testResolveDigest, nil)
+	r.NoError(utils.SignRequest(req, secret+"0"))
+	rec = serve(req)
+	r.Equal(http.StatusUnauthorized, rec.Code)
+	r.Contains(rec.Body.String(), "invalid signed request")
+
+	// And the unsigned requests should be authorized by the registry
+	rec = serve(httptest.NewRequest(http.MethodGet, resolvePathPrefix+testResolveDigest, nil))
+	r.Equal(http.StatusUnauthorized, rec.Code)
+	r.Contains(rec.Body.String(), "authentication required")
+}
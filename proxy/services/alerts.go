@@ -0,0 +1,137 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/forta-network/disco/ipfsclient"
+	"github.com/hashicorp/go-multierror"
+	log "github.com/sirupsen/logrus"
+)
+
+// storeCache is the name of the cache in the usage alerts.
+const storeCache = "cache"
+
+// repoStater is implemented by the node clients which can report their repo usage.
+type repoStater interface {
+	RepoStat(ctx context.Context) (*ipfsclient.RepoStatObject, error)
+}
+
+// UsageAlert is fired when the usage of a store crosses its threshold and resolved when the
+// usage drops below the threshold again.
+type UsageAlert struct {
+	Time  time.Time `json:"time"`
+	Store string    `json:"store"`
+	// Usage and Threshold are in bytes.
+	Usage     int64 `json:"usage"`
+	Threshold int64 `json:"threshold"`
+	Resolved  bool  `json:"resolved"`
+}
+
+// storeUsage is the usage of a store and its threshold.
+type storeUsage struct {
+	store     string
+	usage     int64
+	threshold int64
+}
+
+// CheckStorageUsage compares the repo usage of the IPFS nodes and the size of the cache with the
+// thresholds in the config and returns the alerts for the stores which crossed them since the
+// last check. The alerts are logged and sent to the webhook, if it is configured.
+func (disco *Disco) CheckStorageUsage(ctx context.Context) ([]*UsageAlert, error) {
+	usages, err := disco.storeUsages(ctx)
+	disco.alertsMu.Lock()
+	defer disco.alertsMu.Unlock()
+	if disco.alerting == nil {
+		disco.alerting = make(map[string]bool)
+	}
+	var alerts []*UsageAlert
+	for _, usage := range usages {
+		storageUsage.WithValues(usage.store).Set(float64(usage.usage))
+		if usage.threshold == 0 {
+			continue
+		}
+		exceeded := usage.usage >= usage.threshold
+		if exceeded == disco.alerting[usage.store] {
+			continue
+		}
+		disco.alerting[usage.store] = exceeded
+		alert := &UsageAlert{
+			Time:      time.Now().UTC(),
+			Store:     usage.store,
+			Usage:     usage.usage,
+			Threshold: usage.threshold,
+			Resolved:  !exceeded,
+		}
+		logger := log.WithFields(log.Fields{
+			"store":     alert.Store,
+			"usage":     alert.Usage,
+			"threshold": alert.Threshold,
+		})
+		if alert.Resolved {
+			logger.Info("storage usage dropped below the threshold")
+		} else {
+			logger.Warn("storage usage exceeded the threshold")
+		}
+		alerts = append(alerts, alert)
+	}
+	if webhook := disco.cfg.UsageAlerts.Webhook; len(webhook) > 0 && len(alerts) > 0 {
+		if webhookErr := disco.postWebhook(ctx, webhook, map[string]interface{}{"alerts": alerts}); webhookErr != nil {
+			err = multierror.Append(err, fmt.Errorf("failed to send the usage alerts: %v", webhookErr))
+		}
+	}
+	return alerts, err
+}
+
+// storeUsages returns the usages of the stores which could be checked.
+func (disco *Disco) storeUsages(ctx context.Context) ([]*storeUsage, error) {
+	var (
+		usages []*storeUsage
+		errs   *multierror.Error
+	)
+	if !disco.cfg.CacheOnly {
+		for i, nodeClient := range disco.getIpfsClient().NodeClients() {
+			stater, ok := nodeClient.(repoStater)
+			if !ok {
+				continue
+			}
+			stat, err := stater.RepoStat(ctx)
+			if err != nil {
+				errs = multierror.Append(errs, fmt.Errorf("failed to get the repo stat of ipfs node #%d: %v", i, err))
+				continue
+			}
+			usage := &storeUsage{store: fmt.Sprintf("ipfs node #%d", i), usage: int64(stat.RepoSize)}
+			if ratio := disco.cfg.UsageAlerts.IPFSRatio; ratio > 0 && stat.StorageMax > 0 {
+				usage.threshold = int64(ratio * float64(stat.StorageMax))
+			}
+			usages = append(usages, usage)
+		}
+	}
+	if cacheDriver := disco.cacheDriver(); cacheDriver != nil {
+		size, err := walkSize(ctx, cacheDriver, registryBase)
+		if err != nil {
+			errs = multierror.Append(errs, fmt.Errorf("failed to compute the cache usage: %v", err))
+		} else {
+			usages = append(usages, &storeUsage{store: storeCache, usage: size, threshold: disco.cfg.UsageAlerts.CacheSize})
+		}
+	}
+	return usages, errs.ErrorOrNil()
+}
+
+// RunUsageAlerts checks the storage usage periodically by using the config, until the context
+// is done.
+func (disco *Disco) RunUsageAlerts(ctx context.Context) {
+	ticker := time.NewTicker(disco.cfg.UsageAlerts.Interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+		if _, err := disco.CheckStorageUsage(ctx); err != nil {
+			log.WithError(err).Error("failed to check the storage usage")
+		}
+	}
+}
@@ -0,0 +1,69 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+
+	"github.com/distribution/distribution/v3/registry/storage/driver/inmemory"
+	"github.com/forta-network/disco/config"
+	"github.com/forta-network/disco/interfaces"
+	mock_interfaces "github.com/forta-network/disco/interfaces/mocks"
+	"github.com/forta-network/disco/ipfsclient"
+)
+
+type testStater struct {
+	*mock_interfaces.MockIPFSFilesAPI
+	stat *ipfsclient.RepoStatObject
+}
+
+func (stater *testStater) RepoStat(ctx context.Context) (*ipfsclient.RepoStatObject, error) {
+	return stater.stat, nil
+}
+
+func (s *Suite) TestCheckStorageUsage() {
+	// Given an ipfs node which is 90% full and a cache with a blob
+	var received []*UsageAlert
+	webhook := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+		var body struct {
+			Alerts []*UsageAlert `json:"alerts"`
+		}
+		s.r.NoError(json.NewDecoder(r.Body).Decode(&body))
+		received = append(received, body.Alerts...)
+	}))
+	defer webhook.Close()
+	s.disco.cfg = &config.Config{UsageAlerts: config.UsageAlertsConfig{IPFSRatio: 0.8, CacheSize: 10, Webhook: webhook.URL}}
+	node := &testStater{MockIPFSFilesAPI: s.ipfsNode, stat: &ipfsclient.RepoStatObject{RepoSize: 90, StorageMax: 100}}
+	s.ipfsClient.EXPECT().NodeClients().Return([]interfaces.IPFSFilesAPI{node}).AnyTimes()
+	secondary := inmemory.New()
+	s.driver.EXPECT().Secondary().Return(secondary).AnyTimes()
+	blobPath := makeBlobPath(testLayerDigest)
+	s.r.NoError(secondary.PutContent(s.ctx, blobPath, []byte("more than ten bytes")))
+
+	// When the usage is checked
+	// Then both of the stores should fire alerts
+	alerts, err := s.disco.CheckStorageUsage(s.ctx)
+	s.r.NoError(err)
+	s.r.Len(alerts, 2)
+	s.r.Equal("ipfs node #0", alerts[0].Store)
+	s.r.Equal(int64(80), alerts[0].Threshold)
+	s.r.Equal(storeCache, alerts[1].Store)
+	s.r.Len(received, 2)
+
+	// When the usage is checked again
+	// Then the alerts should not fire again
+	alerts, err = s.disco.CheckStorageUsage(s.ctx)
+	s.r.NoError(err)
+	s.r.Empty(alerts)
+
+	// When the cache usage drops below the threshold
+	// Then the cache alert should be resolved
+	s.r.NoError(secondary.Delete(s.ctx, blobPath))
+	alerts, err = s.disco.CheckStorageUsage(s.ctx)
+	s.r.NoError(err)
+	s.r.Len(alerts, 1)
+	s.r.Equal(storeCache, alerts[0].Store)
+	s.r.True(alerts[0].Resolved)
+	s.r.Len(received, 3)
+}
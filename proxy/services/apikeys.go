@@ -0,0 +1,267 @@
+package services
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// The scopes of the API keys.
+const (
+	ScopePull      = "pull"
+	ScopePush      = "push"
+	ScopeAdmin     = "admin"
+	ScopeReplicate = "replicate"
+)
+
+const (
+	// apiKeyPrefix makes the keys recognizable, e.g. by the secret scanners.
+	apiKeyPrefix = "disco_"
+	// apiKeysCacheTTL is how long the keys from the shared store are used before they are read
+	// again, so that the revoked keys stop working on all of the replicas shortly.
+	apiKeysCacheTTL = 10 * time.Second
+)
+
+// APIKey is an API key without its secret.
+type APIKey struct {
+	ID      string    `json:"id"`
+	Name    string    `json:"name"`
+	Scopes  []string  `json:"scopes"`
+	Created time.Time `json:"created"`
+	// Hash is the SHA-256 hash of the secret.
+	Hash string `json:"hash,omitempty"`
+}
+
+func (key *APIKey) hasScope(scope string) bool {
+	for _, keyScope := range key.Scopes {
+		// the admin keys can replicate, too
+		if keyScope == scope || (keyScope == ScopeAdmin && scope == ScopeReplicate) {
+			return true
+		}
+	}
+	return false
+}
+
+// apiKeys stores the API keys in a file or in the shared store.
+type apiKeys struct {
+	path  string
+	store sharedStore
+
+	mu     sync.Mutex
+	keys   map[string]*APIKey
+	readAt time.Time
+}
+
+func newAPIKeys(path string, store sharedStore) *apiKeys {
+	keys := &apiKeys{path: path, store: store, keys: make(map[string]*APIKey)}
+	if store != nil {
+		return keys
+	}
+	b, err := os.ReadFile(path)
+	if err != nil && !os.IsNotExist(err) {
+		log.WithError(err).Error("failed to read the api keys")
+	}
+	if len(b) > 0 {
+		if err := json.Unmarshal(b, &keys.keys); err != nil {
+			log.WithError(err).Error("failed to decode the api keys")
+		}
+	}
+	return keys
+}
+
+// load reads the keys from the shared store again after the cache TTL. The keys which were read
+// before are kept if the store fails. It should be called with the lock.
+func (keys *apiKeys) load(force bool) error {
+	if keys.store == nil || (!force && time.Since(keys.readAt) < apiKeysCacheTTL) {
+		return nil
+	}
+	// the failures are not retried on every request either
+	keys.readAt = time.Now()
+	ctx, cancel := storeContext()
+	defer cancel()
+	value, ok, err := keys.store.get(ctx, storeKeyAPIKeys)
+	if err != nil {
+		return fmt.Errorf("failed to read the api keys: %v", err)
+	}
+	loaded := make(map[string]*APIKey)
+	if ok {
+		if err := json.Unmarshal([]byte(value), &loaded); err != nil {
+			return fmt.Errorf("failed to decode the api keys: %v", err)
+		}
+	}
+	keys.keys = loaded
+	return nil
+}
+
+func (keys *apiKeys) save() error {
+	b, err := json.Marshal(keys.keys)
+	if err != nil {
+		return err
+	}
+	if keys.store != nil {
+		ctx, cancel := storeContext()
+		defer cancel()
+		return keys.store.set(ctx, storeKeyAPIKeys, string(b), 0)
+	}
+	if err := os.MkdirAll(filepath.Dir(keys.path), 0755); err != nil {
+		return err
+	}
+	tmpPath := keys.path + ".tmp"
+	if err := os.WriteFile(tmpPath, b, 0600); err != nil {
+		return err
+	}
+	return os.Rename(tmpPath, keys.path)
+}
+
+// parseAPIKey returns the ID and the secret of a secret key.
+func parseAPIKey(secretKey string) (id, secret string, ok bool) {
+	return strings.Cut(strings.TrimPrefix(secretKey, apiKeyPrefix), "_")
+}
+
+// APIKeyID returns the ID of the API key in the secret key without checking the key.
+func APIKeyID(secretKey string) (string, bool) {
+	if !strings.HasPrefix(secretKey, apiKeyPrefix) {
+		return "", false
+	}
+	id, _, ok := parseAPIKey(secretKey)
+	return id, ok && len(id) > 0
+}
+
+func hashSecret(secret string) string {
+	hash := sha256.Sum256([]byte(secret))
+	return hex.EncodeToString(hash[:])
+}
+
+// APIKeysEnabled tells if the requests should be authorized with the API keys.
+func (disco *Disco) APIKeysEnabled() bool {
+	return disco.apiKeys != nil
+}
+
+// CreateAPIKey creates an API key with the scopes and returns it with its secret key, which is
+// not stored and cannot be read again.
+func (disco *Disco) CreateAPIKey(name string, scopes []string) (*APIKey, string, error) {
+	if disco.apiKeys == nil {
+		return nil, "", errors.New("api keys are not enabled")
+	}
+	if len(scopes) == 0 {
+		return nil, "", fmt.Errorf("%w: no scopes", ErrInvalidScope)
+	}
+	for _, scope := range scopes {
+		switch scope {
+		case ScopePull, ScopePush, ScopeAdmin, ScopeReplicate:
+		default:
+			return nil, "", fmt.Errorf("%w: %s", ErrInvalidScope, scope)
+		}
+	}
+	b := make([]byte, 40)
+	if _, err := rand.Read(b); err != nil {
+		return nil, "", err
+	}
+	id, secret := hex.EncodeToString(b[:8]), hex.EncodeToString(b[8:])
+	key := &APIKey{
+		ID:      id,
+		Name:    name,
+		Scopes:  scopes,
+		Created: time.Now().UTC(),
+		Hash:    hashSecret(secret),
+	}
+
+	keys := disco.apiKeys
+	keys.mu.Lock()
+	defer keys.mu.Unlock()
+	if err := keys.load(true); err != nil {
+		return nil, "", err
+	}
+	keys.keys[id] = key
+	if err := keys.save(); err != nil {
+		delete(keys.keys, id)
+		return nil, "", fmt.Errorf("failed to save the api keys: %v", err)
+	}
+	created := *key
+	created.Hash = ""
+	return &created, apiKeyPrefix + id + "_" + secret, nil
+}
+
+// RevokeAPIKey deletes the API key with the ID.
+func (disco *Disco) RevokeAPIKey(id string) error {
+	if disco.apiKeys == nil {
+		return errors.New("api keys are not enabled")
+	}
+	keys := disco.apiKeys
+	keys.mu.Lock()
+	defer keys.mu.Unlock()
+	if err := keys.load(true); err != nil {
+		return err
+	}
+	key, ok := keys.keys[id]
+	if !ok {
+		return fmt.Errorf("%w: %s", ErrAPIKeyNotFound, id)
+	}
+	delete(keys.keys, id)
+	if err := keys.save(); err != nil {
+		keys.keys[id] = key
+		return fmt.Errorf("failed to save the api keys: %v", err)
+	}
+	return nil
+}
+
+// ListAPIKeys returns the API keys without their hashes, sorted by their creation times.
+func (disco *Disco) ListAPIKeys() []*APIKey {
+	if disco.apiKeys == nil {
+		return nil
+	}
+	keys := disco.apiKeys
+	keys.mu.Lock()
+	defer keys.mu.Unlock()
+	if err := keys.load(false); err != nil {
+		log.WithError(err).Warn("using the api keys which were read before")
+	}
+	var list []*APIKey
+	for _, key := range keys.keys {
+		listed := *key
+		listed.Hash = ""
+		list = append(list, &listed)
+	}
+	sort.Slice(list, func(i, j int) bool {
+		return list[i].Created.Before(list[j].Created)
+	})
+	return list
+}
+
+// CheckAPIKey checks that the secret key is valid and has the scope. Any valid key is accepted if
+// the scope is empty.
+func (disco *Disco) CheckAPIKey(secretKey, scope string) error {
+	if disco.apiKeys == nil {
+		return nil
+	}
+	id, secret, ok := parseAPIKey(secretKey)
+	if !ok {
+		return ErrInvalidAPIKey
+	}
+	keys := disco.apiKeys
+	keys.mu.Lock()
+	if err := keys.load(false); err != nil {
+		log.WithError(err).Warn("using the api keys which were read before")
+	}
+	key, ok := keys.keys[id]
+	keys.mu.Unlock()
+	if !ok || subtle.ConstantTimeCompare([]byte(hashSecret(secret)), []byte(key.Hash)) != 1 {
+		return ErrInvalidAPIKey
+	}
+	if len(scope) > 0 && !key.hasScope(scope) {
+		return fmt.Errorf("%w: %s", ErrScopeDenied, scope)
+	}
+	return nil
+}
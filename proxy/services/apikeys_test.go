@@ -0,0 +1,115 @@
+package services
+
+import (
+	"errors"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/forta-network/disco/config"
+	"github.com/stretchr/testify/require"
+)
+
+func newAPIKeysService(file string, store sharedStore) *Disco {
+	return &Disco{
+		cfg:     &config.Config{APIKeys: config.APIKeysConfig{Enabled: true, File: file}},
+		apiKeys: newAPIKeys(file, store),
+	}
+}
+
+func TestAPIKeys_CreateAndCheck(t *testing.T) {
+	r := require.New(t)
+
+	disco := newAPIKeysService(filepath.Join(t.TempDir(), "apikeys.json"), nil)
+
+	// When a key is created
+	key, secretKey, err := disco.CreateAPIKey("ci", []string{ScopePull, ScopePush})
+	r.NoError(err)
+
+	// Then its secret should be returned only once
+	r.True(strings.HasPrefix(secretKey, apiKeyPrefix+key.ID+"_"))
+	r.Empty(key.Hash)
+	id, ok := APIKeyID(secretKey)
+	r.True(ok)
+	r.Equal(key.ID, id)
+	listed := disco.ListAPIKeys()
+	r.Len(listed, 1)
+	r.Equal("ci", listed[0].Name)
+	r.Empty(listed[0].Hash)
+
+	// And it should be checked with its scopes
+	r.NoError(disco.CheckAPIKey(secretKey, ""))
+	r.NoError(disco.CheckAPIKey(secretKey, ScopePull))
+	r.NoError(disco.CheckAPIKey(secretKey, ScopePush))
+	r.ErrorIs(disco.CheckAPIKey(secretKey, ScopeAdmin), ErrScopeDenied)
+	r.ErrorIs(disco.CheckAPIKey(secretKey+"0", ScopePull), ErrInvalidAPIKey)
+	r.ErrorIs(disco.CheckAPIKey("disco_unknown_secret", ScopePull), ErrInvalidAPIKey)
+	r.ErrorIs(disco.CheckAPIKey("", ScopePull), ErrInvalidAPIKey)
+
+	// And the invalid scopes should be rejected
+	_, _, err = disco.CreateAPIKey("root", []string{"root"})
+	r.ErrorIs(err, ErrInvalidScope)
+	_, _, err = disco.CreateAPIKey("none", nil)
+	r.ErrorIs(err, ErrInvalidScope)
+}
+
+func TestAPIKeys_Scopes(t *testing.T) {
+	r := require.New(t)
+
+	admin := &APIKey{Scopes: []string{ScopeAdmin}}
+	r.True(admin.hasScope(ScopeAdmin))
+	r.True(admin.hasScope(ScopeReplicate), "admin should imply replicate")
+	r.False(admin.hasScope(ScopePull))
+	r.False(admin.hasScope(ScopePush))
+
+	replicate := &APIKey{Scopes: []string{ScopeReplicate}}
+	r.True(replicate.hasScope(ScopeReplicate))
+	r.False(replicate.hasScope(ScopeAdmin))
+}
+
+func TestAPIKeys_Revoke(t *testing.T) {
+	r := require.New(t)
+
+	disco := newAPIKeysService(filepath.Join(t.TempDir(), "apikeys.json"), nil)
+	key, secretKey, err := disco.CreateAPIKey("ci", []string{ScopePull})
+	r.NoError(err)
+
+	// When the key is revoked
+	r.NoError(disco.RevokeAPIKey(key.ID))
+
+	// Then it should not be accepted anymore
+	r.ErrorIs(disco.CheckAPIKey(secretKey, ScopePull), ErrInvalidAPIKey)
+	r.Empty(disco.ListAPIKeys())
+	r.True(errors.Is(disco.RevokeAPIKey(key.ID), ErrAPIKeyNotFound))
+}
+
+func TestAPIKeys_Persistence(t *testing.T) {
+	r := require.New(t)
+
+	// Given a key which is saved to the file
+	file := filepath.Join(t.TempDir(), "apikeys.json")
+	key, secretKey, err := newAPIKeysService(file, nil).CreateAPIKey("ci", []string{ScopePull})
+	r.NoError(err)
+
+	// Then it should be read again after a restart
+	disco := newAPIKeysService(file, nil)
+	r.NoError(disco.CheckAPIKey(secretKey, ScopePull))
+	r.Equal(key.ID, disco.ListAPIKeys()[0].ID)
+
+	// Given two replicas which share a store
+	store := newTestStore()
+	disco1, disco2 := newAPIKeysService("", store), newAPIKeysService("", store)
+
+	// When a key is created through one of them
+	key, secretKey, err = disco1.CreateAPIKey("ci", []string{ScopePush})
+	r.NoError(err)
+
+	// Then the other one should accept it
+	r.NoError(disco2.CheckAPIKey(secretKey, ScopePush))
+
+	// And it should stop accepting it after it is revoked through the other one and the keys
+	// are read again
+	r.NoError(disco1.RevokeAPIKey(key.ID))
+	disco2.apiKeys.readAt = disco2.apiKeys.readAt.Add(-apiKeysCacheTTL)
+	r.ErrorIs(disco2.CheckAPIKey(secretKey, ScopePush), ErrInvalidAPIKey)
+}
@@ -0,0 +1,203 @@
+package services
+
+import (
+	"fmt"
+	"strconv"
+	"sync"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+)
+
+const (
+	// bandwidthMonthFormat is the format of the calendar months which the bandwidth is counted in.
+	bandwidthMonthFormat = "2006-01"
+	// bandwidthTTL is how long the counters of a month are kept in the shared store.
+	bandwidthTTL = 62 * 24 * time.Hour
+)
+
+// BandwidthUsage contains the bytes served per repository and per client in a month.
+type BandwidthUsage struct {
+	Month        string           `json:"month"`
+	Repositories map[string]int64 `json:"repositories"`
+	Clients      map[string]int64 `json:"clients"`
+}
+
+// bandwidthMeter counts the bytes served in the current month in the shared store, so that the
+// caps apply to all of the replicas together and survive the restarts, or in memory if there is
+// no shared store. It does nothing if it is nil.
+type bandwidthMeter struct {
+	store sharedStore
+
+	mu      sync.Mutex
+	month   string
+	repos   map[string]int64
+	clients map[string]int64
+}
+
+func newBandwidthMeter(store sharedStore) *bandwidthMeter {
+	return &bandwidthMeter{store: store}
+}
+
+// bandwidthKey returns the key of the hash of the repository or the client counters of the
+// month in the shared store.
+func bandwidthKey(month, kind string) string {
+	return storeKeyBandwidth + month + "/" + kind
+}
+
+// rollover starts counting from zero when the month changes.
+func (meter *bandwidthMeter) rollover() {
+	month := time.Now().UTC().Format(bandwidthMonthFormat)
+	if month == meter.month {
+		return
+	}
+	meter.month = month
+	meter.repos = make(map[string]int64)
+	meter.clients = make(map[string]int64)
+}
+
+func (meter *bandwidthMeter) add(repoName, client string, n int64) {
+	if meter == nil {
+		return
+	}
+	if meter.store != nil {
+		month := time.Now().UTC().Format(bandwidthMonthFormat)
+		meter.storeIncr(bandwidthKey(month, "repos"), repoName, n)
+		if len(client) > 0 {
+			meter.storeIncr(bandwidthKey(month, "clients"), client, n)
+		}
+		return
+	}
+	meter.mu.Lock()
+	defer meter.mu.Unlock()
+	meter.rollover()
+	meter.repos[repoName] += n
+	if len(client) > 0 {
+		meter.clients[client] += n
+	}
+}
+
+func (meter *bandwidthMeter) served(repoName, client string) (repoBytes, clientBytes int64) {
+	if meter == nil {
+		return
+	}
+	if meter.store != nil {
+		month := time.Now().UTC().Format(bandwidthMonthFormat)
+		repoBytes = meter.storeGet(bandwidthKey(month, "repos"), repoName)
+		if len(client) > 0 {
+			clientBytes = meter.storeGet(bandwidthKey(month, "clients"), client)
+		}
+		return
+	}
+	meter.mu.Lock()
+	defer meter.mu.Unlock()
+	meter.rollover()
+	return meter.repos[repoName], meter.clients[client]
+}
+
+func (meter *bandwidthMeter) usage() *BandwidthUsage {
+	if meter.store != nil {
+		month := time.Now().UTC().Format(bandwidthMonthFormat)
+		return &BandwidthUsage{
+			Month:        month,
+			Repositories: meter.storeGetAll(bandwidthKey(month, "repos")),
+			Clients:      meter.storeGetAll(bandwidthKey(month, "clients")),
+		}
+	}
+	meter.mu.Lock()
+	defer meter.mu.Unlock()
+	meter.rollover()
+	usage := &BandwidthUsage{
+		Month:        meter.month,
+		Repositories: make(map[string]int64),
+		Clients:      make(map[string]int64),
+	}
+	for repoName, n := range meter.repos {
+		usage.Repositories[repoName] = n
+	}
+	for client, n := range meter.clients {
+		usage.Clients[client] = n
+	}
+	return usage
+}
+
+// storeIncr adds the bytes to a counter in the shared store and logs the errors.
+func (meter *bandwidthMeter) storeIncr(key, field string, n int64) {
+	ctx, cancel := storeContext()
+	defer cancel()
+	if _, err := meter.store.incrField(ctx, key, field, n, bandwidthTTL); err != nil {
+		log.WithError(err).WithField("key", key).Error("failed to count the bandwidth in the shared store")
+	}
+}
+
+// storeGet reads a counter from the shared store. The errors are logged and treated as zero
+// bytes, so that the pulls are not denied while the store is down.
+func (meter *bandwidthMeter) storeGet(key, field string) int64 {
+	ctx, cancel := storeContext()
+	defer cancel()
+	value, ok, err := meter.store.getField(ctx, key, field)
+	if err != nil {
+		log.WithError(err).WithField("key", key).Warn("failed to read the bandwidth from the shared store")
+		return 0
+	}
+	if !ok {
+		return 0
+	}
+	n, _ := strconv.ParseInt(value, 10, 64)
+	return n
+}
+
+// storeGetAll reads the counters of a hash from the shared store.
+func (meter *bandwidthMeter) storeGetAll(key string) map[string]int64 {
+	ctx, cancel := storeContext()
+	defer cancel()
+	counters := make(map[string]int64)
+	fields, err := meter.store.getFields(ctx, key)
+	if err != nil {
+		log.WithError(err).WithField("key", key).Warn("failed to read the bandwidth from the shared store")
+		return counters
+	}
+	for field, value := range fields {
+		counters[field], _ = strconv.ParseInt(value, 10, 64)
+	}
+	return counters
+}
+
+// BandwidthEnabled tells if the bytes served per repository and per client are counted.
+func (disco *Disco) BandwidthEnabled() bool {
+	return disco.bandwidth != nil
+}
+
+// RecordBandwidth counts the bytes served from a repository to a client, if the bandwidth
+// accounting is enabled. The client can be empty.
+func (disco *Disco) RecordBandwidth(repoName, client string, n int64) {
+	if disco.bandwidth == nil || n == 0 {
+		return
+	}
+	disco.bandwidth.add(repoName, client, n)
+	repoBandwidth.WithValues(repoName).Inc(float64(n))
+	if len(client) > 0 {
+		clientBandwidth.WithValues(client).Inc(float64(n))
+	}
+}
+
+// CheckBandwidth checks that the monthly bandwidth caps of the repository and the client are
+// not exceeded yet.
+func (disco *Disco) CheckBandwidth(repoName, client string) error {
+	repoBytes, clientBytes := disco.bandwidth.served(repoName, client)
+	if limit := disco.cfg.Bandwidth.RepoCap; limit > 0 && repoBytes >= limit {
+		return fmt.Errorf("%w: repository %s", ErrBandwidthExceeded, repoName)
+	}
+	if limit := disco.cfg.Bandwidth.ClientCap; limit > 0 && len(client) > 0 && clientBytes >= limit {
+		return fmt.Errorf("%w: client %s", ErrBandwidthExceeded, client)
+	}
+	return nil
+}
+
+// BandwidthUsage returns the bytes served per repository and per client in the current month.
+func (disco *Disco) BandwidthUsage() *BandwidthUsage {
+	if disco.bandwidth == nil {
+		return &BandwidthUsage{}
+	}
+	return disco.bandwidth.usage()
+}
@@ -0,0 +1,28 @@
+package services
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestBandwidthMeter_SharedStore(t *testing.T) {
+	r := require.New(t)
+
+	// Given two replicas which share a store
+	store := newTestStore()
+	meter1, meter2 := newBandwidthMeter(store), newBandwidthMeter(store)
+
+	// When both of them serve a client
+	meter1.add("myorg/myrepo", "subject:alice", 3)
+	meter2.add("myorg/myrepo", "subject:alice", 4)
+	meter2.add("myorg/otherrepo", "", 5)
+
+	// Then the bytes should be counted together
+	repoBytes, clientBytes := meter1.served("myorg/myrepo", "subject:alice")
+	r.Equal(int64(7), repoBytes)
+	r.Equal(int64(7), clientBytes)
+	usage := meter2.usage()
+	r.Equal(map[string]int64{"myorg/myrepo": 7, "myorg/otherrepo": 5}, usage.Repositories)
+	r.Equal(map[string]int64{"subject:alice": 7}, usage.Clients)
+}
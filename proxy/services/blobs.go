@@ -0,0 +1,77 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"sync"
+
+	"github.com/forta-network/disco/interfaces"
+)
+
+// maxBlobChecksPerNode limits the blob existence checks which are made on a node at the same time.
+const maxBlobChecksPerNode = 4
+
+// blobCheck is the result of checking a blob in the node which it is routed to.
+type blobCheck struct {
+	blob   *blobCid
+	client interfaces.IPFSFilesAPI
+	exists bool
+}
+
+// checkBlobs checks if the blobs exist in the nodes which they are routed to. The blobs are
+// routed once and the checks are made concurrently on each node. The results are in the order
+// of the blobs.
+func (disco *Disco) checkBlobs(ctx context.Context, blobs []*blobCid) ([]*blobCheck, error) {
+	checks := make([]*blobCheck, len(blobs))
+	nodeChecks := make(map[interfaces.IPFSFilesAPI][]*blobCheck)
+	for i, blob := range blobs {
+		// get the client without the provider: causes blobs to be replicated after increasing the amount of IPFS nodes
+		client, err := disco.getIpfsClient().GetClientFor(ctx, makeBlobPath(blob.Digest))
+		if err != nil {
+			return nil, fmt.Errorf("failed to get blob node client: %v", err)
+		}
+		checks[i] = &blobCheck{blob: blob, client: client}
+		nodeChecks[client] = append(nodeChecks[client], checks[i])
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	var (
+		wg       sync.WaitGroup
+		errOnce  sync.Once
+		checkErr error
+	)
+	for client, clientChecks := range nodeChecks {
+		queue := make(chan *blobCheck, len(clientChecks))
+		for _, check := range clientChecks {
+			queue <- check
+		}
+		close(queue)
+		workers := maxBlobChecksPerNode
+		if len(clientChecks) < workers {
+			workers = len(clientChecks)
+		}
+		for i := 0; i < workers; i++ {
+			wg.Add(1)
+			go func(client interfaces.IPFSFilesAPI) {
+				defer wg.Done()
+				for check := range queue {
+					exists, err := disco.hasFile(ctx, client, makeBlobPath(check.blob.Digest))
+					if err != nil {
+						errOnce.Do(func() {
+							checkErr = fmt.Errorf("failed to check if blob %s exists: %v", check.blob.Digest, err)
+							cancel()
+						})
+						return
+					}
+					check.exists = exists
+				}
+			}(client)
+		}
+	}
+	wg.Wait()
+	if checkErr != nil {
+		return nil, checkErr
+	}
+	return checks, nil
+}
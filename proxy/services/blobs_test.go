@@ -0,0 +1,37 @@
+package services
+
+import (
+	"errors"
+
+	"github.com/golang/mock/gomock"
+	ipfsapi "github.com/ipfs/go-ipfs-api"
+)
+
+func (s *Suite) TestCheckBlobs() {
+	blobs := []*blobCid{
+		{Digest: testManifestDigest, Cid: testManifestCid},
+		{Digest: testConfigDigest, Cid: testConfigFileCid},
+		{Digest: testLayerDigest, Cid: testLayerCid},
+	}
+	s.ipfsNode.EXPECT().FilesStat(gomock.Any(), makeBlobPath(testManifestDigest)).Return(&ipfsapi.FilesStatObject{}, nil)
+	s.ipfsNode.EXPECT().FilesStat(gomock.Any(), makeBlobPath(testConfigDigest)).Return(nil, errors.New("does not exist"))
+	s.ipfsNode.EXPECT().FilesStat(gomock.Any(), makeBlobPath(testLayerDigest)).Return(&ipfsapi.FilesStatObject{}, nil)
+
+	checks, err := s.disco.checkBlobs(s.ctx, blobs)
+	s.r.NoError(err)
+	s.r.Len(checks, 3)
+	for i, exists := range []bool{true, false, true} {
+		s.r.Equal(blobs[i], checks[i].blob)
+		s.r.Equal(exists, checks[i].exists)
+	}
+}
+
+func (s *Suite) TestCheckBlobs_Error() {
+	blobs := []*blobCid{
+		{Digest: testManifestDigest, Cid: testManifestCid},
+	}
+	s.ipfsNode.EXPECT().FilesStat(gomock.Any(), makeBlobPath(testManifestDigest)).Return(nil, errors.New("connection refused"))
+
+	_, err := s.disco.checkBlobs(s.ctx, blobs)
+	s.r.Error(err)
+}
@@ -0,0 +1,72 @@
+package services
+
+import (
+	"context"
+	"sort"
+	"time"
+
+	"github.com/forta-network/disco/utils"
+)
+
+// Page sizes of the catalog.
+const (
+	DefaultCatalogPageSize = 100
+	MaxCatalogPageSize     = 1000
+)
+
+// CatalogEntry is a global repository in the catalog.
+type CatalogEntry struct {
+	Digest string `json:"digest"`
+	Cid    string `json:"cid"`
+	// Size is the cumulative size of the blobs.
+	Size int64 `json:"size"`
+	// PushedAt is the last push time of the manifest, if the CID index is configured.
+	PushedAt *time.Time `json:"pushedAt,omitempty"`
+}
+
+// Catalog lists up to n of the CID v1 repositories after the last one, in the order of their
+// CIDs like the registry catalog, with their manifest digests, sizes and push times. The next
+// page starts after the returned CID, which is empty on the last page. The repositories which
+// cannot be inspected are skipped.
+func (disco *Disco) Catalog(ctx context.Context, n int, last string) ([]*CatalogEntry, string, error) {
+	if n <= 0 {
+		n = DefaultCatalogPageSize
+	}
+	if n > MaxCatalogPageSize {
+		n = MaxCatalogPageSize
+	}
+	repos, err := disco.ListGlobalRepos(ctx)
+	if err != nil {
+		return nil, "", err
+	}
+	start := sort.SearchStrings(repos, last)
+	if start < len(repos) && repos[start] == last {
+		start++
+	}
+	end := start + n
+	if end > len(repos) {
+		end = len(repos)
+	}
+
+	entries := []*CatalogEntry{}
+	for _, repoCid := range repos[start:end] {
+		result, err := disco.Inspect(ctx, repoCid)
+		if err != nil {
+			utils.Logger(ctx).WithError(err).WithField("repository", repoCid).Warn("failed to inspect the repository for the catalog")
+			continue
+		}
+		entry := &CatalogEntry{Digest: "sha256:" + result.ManifestDigest, Cid: repoCid}
+		for _, blob := range result.Blobs {
+			entry.Size += blob.Size
+		}
+		if pushedAt, ok := disco.cids.lastPush(result.ManifestDigest); ok {
+			entry.PushedAt = &pushedAt
+		}
+		entries = append(entries, entry)
+	}
+	var next string
+	if end < len(repos) {
+		next = repos[end-1]
+	}
+	return entries, next, nil
+}
@@ -0,0 +1,58 @@
+package services
+
+import (
+	"encoding/json"
+	"path"
+
+	storagedriver "github.com/distribution/distribution/v3/registry/storage/driver"
+	"github.com/distribution/distribution/v3/registry/storage/driver/inmemory"
+	"github.com/forta-network/disco/config"
+	"github.com/forta-network/disco/utils"
+)
+
+func (s *Suite) TestCatalog() {
+	const otherManifest = "1111111111111111111111111111111111111111111111111111111111111111"
+	otherCid, err := utils.ConvertSHA256HexToCIDv1(otherManifest)
+	s.r.NoError(err)
+
+	// Given two global repos in the cache
+	driver := inmemory.New()
+	s.disco.cfg = &config.Config{CacheOnly: true, CidIndex: path.Join(s.T().TempDir(), "cids.db")}
+	s.disco.getDriver = func() storagedriver.StorageDriver {
+		return driver
+	}
+	s.disco.cids = newCidIndex(s.disco.cfg.CidIndex, nil)
+	addRepo := func(repoCid, manifestDigest string, blobDigests ...string) {
+		file := &discoFile{Version: discoFileVersion, Digest: manifestDigest}
+		for _, digest := range append([]string{manifestDigest}, blobDigests...) {
+			file.Blobs = append(file.Blobs, &blobCid{Digest: digest, Cid: "bafy" + digest[:8]})
+			s.r.NoError(driver.PutContent(s.ctx, makeBlobPath(digest), []byte(digest[:10])))
+		}
+		b, err := json.Marshal(file)
+		s.r.NoError(err)
+		s.r.NoError(driver.PutContent(s.ctx, makeDiscoFilePath(repoCid), b))
+		s.r.NoError(driver.PutContent(s.ctx, s.disco.makeManifestLinkPath(repoCid), []byte("sha256:"+manifestDigest)))
+	}
+	addRepo(testCidv1, testManifestDigest, testConfigDigest, testLayerDigest)
+	addRepo(otherCid, otherManifest, testConfigDigest)
+	s.disco.cids.recordPush(testManifestDigest)
+
+	// When the catalog is listed one repo at a time
+	first, next, err := s.disco.Catalog(s.ctx, 1, "")
+	s.r.NoError(err)
+	s.r.Len(first, 1)
+	s.r.Equal(first[0].Cid, next)
+	second, next, err := s.disco.Catalog(s.ctx, 1, next)
+	s.r.NoError(err)
+	s.r.Len(second, 1)
+	s.r.Empty(next)
+
+	// Then both repos should be listed with their digests, sizes and push times
+	entries := map[string]*CatalogEntry{first[0].Cid: first[0], second[0].Cid: second[0]}
+	s.r.Equal("sha256:"+testManifestDigest, entries[testCidv1].Digest)
+	s.r.Equal(int64(30), entries[testCidv1].Size)
+	s.r.NotNil(entries[testCidv1].PushedAt)
+	s.r.Equal("sha256:"+otherManifest, entries[otherCid].Digest)
+	s.r.Equal(int64(20), entries[otherCid].Size)
+	s.r.Nil(entries[otherCid].PushedAt)
+}
@@ -0,0 +1,370 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+	bolt "go.etcd.io/bbolt"
+)
+
+// cidIndex maps the manifest digests to the CID v1 repositories and the blob digests to the
+// blob CIDs in a bbolt database file or in the shared store, so that they can be resolved
+// without traversing the storage. It also keeps the blobs which the manifests reference, so that
+// the references of a blob can be counted before it is deleted.
+type cidIndex struct {
+	store sharedStore
+	db    *bolt.DB
+}
+
+// Buckets of the index file. The cids bucket is the reverse of the repos bucket, and the
+// blobrefs bucket has a bucket of the referencing manifests for each blob.
+var (
+	cidIndexRepos    = []byte("repos")
+	cidIndexCids     = []byte("cids")
+	cidIndexBlobs    = []byte("blobs")
+	cidIndexRefs     = []byte("refs")
+	cidIndexBlobRefs = []byte("blobrefs")
+	cidIndexPushes   = []byte("pushes")
+)
+
+// cidIndexOpenTimeout limits waiting for the lock of the index file, which only one process can
+// open at a time.
+const cidIndexOpenTimeout = time.Second * 5
+
+func newCidIndex(path string, store sharedStore) *cidIndex {
+	if store != nil {
+		return &cidIndex{store: store}
+	}
+	db, err := openCidIndex(path)
+	if err != nil {
+		log.WithError(err).Error("failed to open the cid index - running without it")
+		return nil
+	}
+	return &cidIndex{db: db}
+}
+
+// openCidIndex opens the index file and creates its buckets.
+func openCidIndex(path string) (*bolt.DB, error) {
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return nil, err
+	}
+	db, err := bolt.Open(path, 0644, &bolt.Options{Timeout: cidIndexOpenTimeout})
+	if err != nil {
+		return nil, fmt.Errorf("failed to open %s: %v", path, err)
+	}
+	err = db.Update(func(tx *bolt.Tx) error {
+		for _, bucket := range [][]byte{cidIndexRepos, cidIndexCids, cidIndexBlobs, cidIndexRefs, cidIndexBlobRefs, cidIndexPushes} {
+			if _, err := tx.CreateBucketIfNotExists(bucket); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		db.Close()
+		return nil, fmt.Errorf("failed to create the buckets: %v", err)
+	}
+	return db, nil
+}
+
+// close closes the index file.
+func (idx *cidIndex) close() error {
+	if idx == nil || idx.db == nil {
+		return nil
+	}
+	return idx.db.Close()
+}
+
+// update changes the index file and logs the errors.
+func (idx *cidIndex) update(fn func(tx *bolt.Tx) error) {
+	if err := idx.db.Update(fn); err != nil {
+		log.WithError(err).Error("failed to update the cid index")
+	}
+}
+
+// get reads the key from a bucket of the index file.
+func (idx *cidIndex) get(bucket []byte, key string) (value string, ok bool) {
+	_ = idx.db.View(func(tx *bolt.Tx) error {
+		if b := tx.Bucket(bucket).Get([]byte(key)); b != nil {
+			value, ok = string(b), true
+		}
+		return nil
+	})
+	return
+}
+
+// addRepo records the CID of the repository with given manifest digest and the CIDs of its blobs.
+// The references of the manifest are kept as they are if the blobs are not known.
+func (idx *cidIndex) addRepo(manifestDigest, repoCid string, blobs []*blobCid) {
+	if idx == nil {
+		return
+	}
+	var refs []string
+	for _, blob := range blobs {
+		refs = append(refs, blob.Digest)
+	}
+	if idx.store != nil {
+		// the reverse mapping makes the manifest digest resolvable from the cid
+		storeSet(idx.store, storeKeyRepos+manifestDigest, repoCid, 0)
+		storeSet(idx.store, storeKeyCids+repoCid, manifestDigest, 0)
+		for _, blob := range blobs {
+			storeSet(idx.store, storeKeyBlobs+blob.Digest, blob.Cid, 0)
+		}
+		if len(refs) > 0 {
+			oldRefs, _ := idx.blobRefs(manifestDigest)
+			storeSet(idx.store, storeKeyRefs+manifestDigest, strings.Join(refs, ","), 0)
+			referenced := make(map[string]bool)
+			for _, digest := range refs {
+				storeAddMember(idx.store, storeKeyBlobRefs+digest, manifestDigest)
+				referenced[digest] = true
+			}
+			for _, digest := range oldRefs {
+				if !referenced[digest] {
+					storeRemoveMember(idx.store, storeKeyBlobRefs+digest, manifestDigest)
+				}
+			}
+		}
+		return
+	}
+	idx.update(func(tx *bolt.Tx) error {
+		repos, cids := tx.Bucket(cidIndexRepos), tx.Bucket(cidIndexCids)
+		if oldCid := repos.Get([]byte(manifestDigest)); oldCid != nil && string(oldCid) != repoCid {
+			if err := cids.Delete(oldCid); err != nil {
+				return err
+			}
+		}
+		if err := repos.Put([]byte(manifestDigest), []byte(repoCid)); err != nil {
+			return err
+		}
+		if err := cids.Put([]byte(repoCid), []byte(manifestDigest)); err != nil {
+			return err
+		}
+		for _, blob := range blobs {
+			if err := tx.Bucket(cidIndexBlobs).Put([]byte(blob.Digest), []byte(blob.Cid)); err != nil {
+				return err
+			}
+		}
+		if len(refs) == 0 {
+			return nil
+		}
+		if err := removeBlobRefs(tx, manifestDigest); err != nil {
+			return err
+		}
+		for _, digest := range refs {
+			manifests, err := tx.Bucket(cidIndexBlobRefs).CreateBucketIfNotExists([]byte(digest))
+			if err != nil {
+				return err
+			}
+			if err := manifests.Put([]byte(manifestDigest), []byte{}); err != nil {
+				return err
+			}
+		}
+		return tx.Bucket(cidIndexRefs).Put([]byte(manifestDigest), []byte(strings.Join(refs, ",")))
+	})
+}
+
+// removeBlobRefs removes the manifest from the referencing manifests of its blobs in the index
+// file, and the blobs which no manifest references anymore.
+func removeBlobRefs(tx *bolt.Tx, manifestDigest string) error {
+	refs := tx.Bucket(cidIndexRefs).Get([]byte(manifestDigest))
+	if refs == nil {
+		return nil
+	}
+	blobRefs := tx.Bucket(cidIndexBlobRefs)
+	for _, digest := range strings.Split(string(refs), ",") {
+		manifests := blobRefs.Bucket([]byte(digest))
+		if manifests == nil {
+			continue
+		}
+		if err := manifests.Delete([]byte(manifestDigest)); err != nil {
+			return err
+		}
+		if key, _ := manifests.Cursor().First(); key == nil {
+			if err := blobRefs.DeleteBucket([]byte(digest)); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// removeRepo removes the repository with given manifest digest and its references. The blob CIDs
+// are kept since the blobs can be shared with the other repositories.
+func (idx *cidIndex) removeRepo(manifestDigest string) {
+	if idx == nil {
+		return
+	}
+	if idx.store != nil {
+		if repoCid, ok := storeGet(idx.store, storeKeyRepos+manifestDigest); ok {
+			storeDelete(idx.store, storeKeyCids+repoCid)
+		}
+		refs, _ := idx.blobRefs(manifestDigest)
+		for _, digest := range refs {
+			storeRemoveMember(idx.store, storeKeyBlobRefs+digest, manifestDigest)
+		}
+		storeDelete(idx.store, storeKeyRepos+manifestDigest)
+		storeDelete(idx.store, storeKeyPushes+manifestDigest)
+		storeDelete(idx.store, storeKeyRefs+manifestDigest)
+		return
+	}
+	idx.update(func(tx *bolt.Tx) error {
+		key := []byte(manifestDigest)
+		if repoCid := tx.Bucket(cidIndexRepos).Get(key); repoCid != nil {
+			if err := tx.Bucket(cidIndexCids).Delete(repoCid); err != nil {
+				return err
+			}
+		}
+		if err := removeBlobRefs(tx, manifestDigest); err != nil {
+			return err
+		}
+		for _, bucket := range [][]byte{cidIndexRepos, cidIndexPushes, cidIndexRefs} {
+			if err := tx.Bucket(bucket).Delete(key); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// recordPush records the push time of the manifest.
+func (idx *cidIndex) recordPush(manifestDigest string) {
+	if idx == nil {
+		return
+	}
+	if idx.store != nil {
+		storeSetTime(idx.store, storeKeyPushes+manifestDigest, time.Now())
+		return
+	}
+	idx.update(func(tx *bolt.Tx) error {
+		return tx.Bucket(cidIndexPushes).Put([]byte(manifestDigest), []byte(time.Now().UTC().Format(time.RFC3339Nano)))
+	})
+}
+
+func (idx *cidIndex) lastPush(manifestDigest string) (time.Time, bool) {
+	if idx == nil {
+		return time.Time{}, false
+	}
+	if idx.store != nil {
+		return storeGetTime(idx.store, storeKeyPushes+manifestDigest)
+	}
+	value, ok := idx.get(cidIndexPushes, manifestDigest)
+	if !ok {
+		return time.Time{}, false
+	}
+	t, err := time.Parse(time.RFC3339Nano, value)
+	return t, err == nil
+}
+
+func (idx *cidIndex) repoCid(manifestDigest string) (string, bool) {
+	if idx == nil {
+		return "", false
+	}
+	if idx.store != nil {
+		return storeGet(idx.store, storeKeyRepos+manifestDigest)
+	}
+	return idx.get(cidIndexRepos, manifestDigest)
+}
+
+func (idx *cidIndex) manifestDigest(repoCid string) (string, bool) {
+	if idx == nil {
+		return "", false
+	}
+	if idx.store != nil {
+		return storeGet(idx.store, storeKeyCids+repoCid)
+	}
+	return idx.get(cidIndexCids, repoCid)
+}
+
+func (idx *cidIndex) blobCid(digest string) (string, bool) {
+	if idx == nil {
+		return "", false
+	}
+	if idx.store != nil {
+		return storeGet(idx.store, storeKeyBlobs+digest)
+	}
+	return idx.get(cidIndexBlobs, digest)
+}
+
+// blobRefs returns the digests of the blobs which the manifest references, if they are known.
+func (idx *cidIndex) blobRefs(manifestDigest string) ([]string, bool) {
+	if idx == nil {
+		return nil, false
+	}
+	var value string
+	var ok bool
+	if idx.store != nil {
+		value, ok = storeGet(idx.store, storeKeyRefs+manifestDigest)
+	} else {
+		value, ok = idx.get(cidIndexRefs, manifestDigest)
+	}
+	if !ok || len(value) == 0 {
+		return nil, false
+	}
+	return strings.Split(value, ","), true
+}
+
+// countRefs counts the references of the blobs by the manifests in the index, except the given
+// manifest. It fails if the references cannot be read, so that the blobs are not deleted then.
+func (idx *cidIndex) countRefs(digests []string, exceptManifest string) (map[string]int, error) {
+	refCounts := make(map[string]int)
+	if idx == nil {
+		return refCounts, nil
+	}
+	if idx.store != nil {
+		for _, digest := range digests {
+			ctx, cancel := storeContext()
+			manifests, err := idx.store.members(ctx, storeKeyBlobRefs+digest)
+			cancel()
+			if err != nil {
+				return nil, fmt.Errorf("failed to read the references of %s: %v", digest, err)
+			}
+			for _, manifestDigest := range manifests {
+				if manifestDigest != exceptManifest {
+					refCounts[digest]++
+				}
+			}
+		}
+		return refCounts, nil
+	}
+	err := idx.db.View(func(tx *bolt.Tx) error {
+		for _, digest := range digests {
+			manifests := tx.Bucket(cidIndexBlobRefs).Bucket([]byte(digest))
+			if manifests == nil {
+				continue
+			}
+			err := manifests.ForEach(func(manifestDigest, _ []byte) error {
+				if string(manifestDigest) != exceptManifest {
+					refCounts[digest]++
+				}
+				return nil
+			})
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to read the references: %v", err)
+	}
+	return refCounts, nil
+}
+
+// ResolveRepoCid returns the CID v1 repository of the manifest digest. It uses the cid index,
+// if configured, and finds the CID from the tags of the digest repository otherwise.
+func (disco *Disco) ResolveRepoCid(ctx context.Context, manifestDigest string) (string, error) {
+	if repoCid, ok := disco.cids.repoCid(manifestDigest); ok {
+		return repoCid, nil
+	}
+	repoCid, err := disco.cidForDigest(ctx, manifestDigest)
+	if err != nil {
+		return "", err
+	}
+	disco.cids.addRepo(manifestDigest, repoCid, nil)
+	return repoCid, nil
+}
@@ -0,0 +1,122 @@
+package services
+
+import (
+	"path"
+
+	storagedriver "github.com/distribution/distribution/v3/registry/storage/driver"
+	"github.com/distribution/distribution/v3/registry/storage/driver/inmemory"
+	"github.com/forta-network/disco/config"
+)
+
+func (s *Suite) TestCidIndex() {
+	indexPath := path.Join(s.T().TempDir(), "cids.db")
+
+	// Given that a repo is added to the index
+	idx := newCidIndex(indexPath, nil)
+	idx.addRepo(testManifestDigest, testCidv1, []*blobCid{{Digest: testConfigDigest, Cid: "config-cid"}})
+
+	// When the index is read again from the file
+	s.r.NoError(idx.close())
+	idx = newCidIndex(indexPath, nil)
+	defer idx.close()
+
+	// Then the digests should resolve to the cids
+	repoCid, ok := idx.repoCid(testManifestDigest)
+	s.r.True(ok)
+	s.r.Equal(testCidv1, repoCid)
+	manifestDigest, ok := idx.manifestDigest(testCidv1)
+	s.r.True(ok)
+	s.r.Equal(testManifestDigest, manifestDigest)
+	configCid, ok := idx.blobCid(testConfigDigest)
+	s.r.True(ok)
+	s.r.Equal("config-cid", configCid)
+	_, ok = idx.repoCid("unknown")
+	s.r.False(ok)
+
+	// And the references of the blobs should be known
+	refs, ok := idx.blobRefs(testManifestDigest)
+	s.r.True(ok)
+	s.r.Equal([]string{testConfigDigest}, refs)
+
+	// And they should be kept when the repo is added again without its blobs
+	idx.addRepo(testManifestDigest, testCidv1, nil)
+	_, ok = idx.blobRefs(testManifestDigest)
+	s.r.True(ok)
+
+	// And the old cid should not resolve after the manifest is added with another one
+	idx.addRepo(testManifestDigest, "bafybeiothercid", nil)
+	_, ok = idx.manifestDigest(testCidv1)
+	s.r.False(ok)
+	manifestDigest, ok = idx.manifestDigest("bafybeiothercid")
+	s.r.True(ok)
+	s.r.Equal(testManifestDigest, manifestDigest)
+
+	// And they should be removed with the repo
+	idx.removeRepo(testManifestDigest)
+	_, ok = idx.blobRefs(testManifestDigest)
+	s.r.False(ok)
+
+	s.testCountRefs(idx)
+}
+
+func (s *Suite) TestCidIndex_SharedStore() {
+	s.testCountRefs(newCidIndex("", newTestStore()))
+}
+
+// testCountRefs checks that the references of the blobs are counted per blob.
+func (s *Suite) testCountRefs(idx *cidIndex) {
+	const otherManifest = "1111111111111111111111111111111111111111111111111111111111111111"
+	digests := []string{testConfigDigest, testLayerDigest}
+
+	// Given two manifests which share a layer
+	idx.addRepo(testManifestDigest, testCidv1, []*blobCid{{Digest: testConfigDigest}, {Digest: testLayerDigest}})
+	idx.addRepo(otherManifest, "bafybeiotherrepo", []*blobCid{{Digest: testLayerDigest}})
+
+	// Then the references of each blob should be counted
+	refCounts, err := idx.countRefs(digests, "")
+	s.r.NoError(err)
+	s.r.Equal(map[string]int{testConfigDigest: 1, testLayerDigest: 2}, refCounts)
+	refCounts, err = idx.countRefs(digests, testManifestDigest)
+	s.r.NoError(err)
+	s.r.Equal(map[string]int{testLayerDigest: 1}, refCounts)
+
+	// And the blobs which a manifest does not reference anymore should not count
+	idx.addRepo(testManifestDigest, testCidv1, []*blobCid{{Digest: testLayerDigest}})
+	refCounts, err = idx.countRefs(digests, "")
+	s.r.NoError(err)
+	s.r.Equal(map[string]int{testLayerDigest: 2}, refCounts)
+
+	// And the references should be removed with the repos
+	idx.removeRepo(otherManifest)
+	idx.removeRepo(testManifestDigest)
+	refCounts, err = idx.countRefs(digests, "")
+	s.r.NoError(err)
+	s.r.Empty(refCounts)
+}
+
+func (s *Suite) TestResolveRepoCid() {
+	// Given a digest repo tagged with its cid
+	driver := inmemory.New()
+	s.r.NoError(driver.PutContent(s.ctx, makeTagPathFor(testManifestDigest, testCidv1)+"/current/link", []byte("sha256:"+testManifestDigest)))
+	s.disco.cfg = &config.Config{CacheOnly: true, CidIndex: path.Join(s.T().TempDir(), "cids.db")}
+	s.disco.getDriver = func() storagedriver.StorageDriver {
+		return driver
+	}
+	s.disco.cids = newCidIndex(s.disco.cfg.CidIndex, nil)
+
+	// When the cid is resolved from the digest
+	repoCid, err := s.disco.ResolveRepoCid(s.ctx, testManifestDigest)
+
+	// Then it should be found from the tags and recorded to the index
+	s.r.NoError(err)
+	s.r.Equal(testCidv1, repoCid)
+	repoCid, ok := s.disco.cids.repoCid(testManifestDigest)
+	s.r.True(ok)
+	s.r.Equal(testCidv1, repoCid)
+
+	// And it should be resolved from the index without the storage
+	s.r.NoError(driver.Delete(s.ctx, makeRepoPath(testManifestDigest)))
+	repoCid, err = s.disco.ResolveRepoCid(s.ctx, testManifestDigest)
+	s.r.NoError(err)
+	s.r.Equal(testCidv1, repoCid)
+}
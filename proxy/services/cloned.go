@@ -0,0 +1,82 @@
+package services
+
+import (
+	"sync"
+	"time"
+)
+
+// clonedRepos remembers the CID v1 repositories which are known to be present in the storage
+// for a while, so that the hot repositories are not checked in the storage on every pull. The
+// repositories are remembered in the shared store instead of the memory if there is one.
+type clonedRepos struct {
+	ttl   time.Duration
+	store sharedStore
+
+	mu       sync.Mutex
+	expiries map[string]time.Time
+}
+
+func newClonedRepos(ttl time.Duration, store sharedStore) *clonedRepos {
+	return &clonedRepos{
+		ttl:      ttl,
+		store:    store,
+		expiries: make(map[string]time.Time),
+	}
+}
+
+// has tells if the repository was known to be present within the TTL.
+func (cloned *clonedRepos) has(repoName string) bool {
+	if cloned == nil {
+		return false
+	}
+	if cloned.store != nil {
+		_, ok := storeGet(cloned.store, storeKeyCloned+repoName)
+		return ok
+	}
+	cloned.mu.Lock()
+	defer cloned.mu.Unlock()
+	expiry, ok := cloned.expiries[repoName]
+	if !ok {
+		return false
+	}
+	if time.Now().After(expiry) {
+		delete(cloned.expiries, repoName)
+		return false
+	}
+	return true
+}
+
+// add records the repository as present until the TTL passes.
+func (cloned *clonedRepos) add(repoName string) {
+	if cloned == nil {
+		return
+	}
+	if cloned.store != nil {
+		storeSet(cloned.store, storeKeyCloned+repoName, "1", cloned.ttl)
+		return
+	}
+	cloned.mu.Lock()
+	defer cloned.mu.Unlock()
+	now := time.Now()
+	// drop the expired repositories while adding so that the set does not grow indefinitely
+	for name, expiry := range cloned.expiries {
+		if now.After(expiry) {
+			delete(cloned.expiries, name)
+		}
+	}
+	cloned.expiries[repoName] = now.Add(cloned.ttl)
+}
+
+// remove forgets the repository, e.g. after it is deleted.
+func (cloned *clonedRepos) remove(repoName string) {
+	if cloned == nil {
+		return
+	}
+	if cloned.store != nil {
+		storeDelete(cloned.store, storeKeyCloned+repoName)
+		return
+	}
+	cloned.mu.Lock()
+	defer cloned.mu.Unlock()
+	delete(cloned.expiries, repoName)
+}
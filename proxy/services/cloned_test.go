@@ -0,0 +1,27 @@
+package services
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestClonedRepos(t *testing.T) {
+	r := require.New(t)
+
+	cloned := newClonedRepos(time.Minute, nil)
+	r.False(cloned.has(testCidv1))
+	cloned.add(testCidv1)
+	r.True(cloned.has(testCidv1))
+	cloned.remove(testCidv1)
+	r.False(cloned.has(testCidv1))
+
+	cloned.ttl = -time.Second
+	cloned.add(testCidv1)
+	r.False(cloned.has(testCidv1), "should expire")
+
+	var disabled *clonedRepos
+	disabled.add(testCidv1)
+	r.False(disabled.has(testCidv1))
+}
@@ -0,0 +1,62 @@
+package services
+
+import (
+	"context"
+	"time"
+
+	"github.com/forta-network/disco/utils"
+)
+
+// operationContext returns the context of an operation which should not be aborted when the
+// client disconnects, e.g. making a repository global halfway. It keeps the values of the
+// parent context and times out after the operation timeout.
+func (disco *Disco) operationContext(parent context.Context) (context.Context, context.CancelFunc) {
+	ctx := detachedContext{parent: parent}
+	if disco.cfg.Timeouts.Operation == 0 {
+		return context.WithCancel(ctx)
+	}
+	return context.WithTimeout(ctx, disco.cfg.Timeouts.Operation)
+}
+
+// detachedContext is never cancelled but has the values of the parent.
+type detachedContext struct {
+	parent context.Context
+}
+
+func (detachedContext) Deadline() (time.Time, bool) {
+	return time.Time{}, false
+}
+
+func (detachedContext) Done() <-chan struct{} {
+	return nil
+}
+
+func (detachedContext) Err() error {
+	return nil
+}
+
+func (ctx detachedContext) Value(key interface{}) interface{} {
+	return ctx.parent.Value(key)
+}
+
+type clonePriorityKey struct{}
+
+// withBackgroundPriority marks the clones with the context as background work, which the high
+// priority clones can preempt.
+func withBackgroundPriority(ctx context.Context) context.Context {
+	return context.WithValue(ctx, clonePriorityKey{}, utils.PriorityBackground)
+}
+
+// clonePriority returns the priority of cloning the repository. The high priority repositories
+// in the config are cloned with the high priority even in the background.
+func (disco *Disco) clonePriority(ctx context.Context, repoName string) utils.Priority {
+	for _, highPriority := range disco.cfg.Limits.HighPriority {
+		if utils.CIDEquals(highPriority, repoName) {
+			return utils.PriorityHigh
+		}
+	}
+	if priority, ok := ctx.Value(clonePriorityKey{}).(utils.Priority); ok {
+		return priority
+	}
+	return utils.PriorityNormal
+}
@@ -0,0 +1,34 @@
+package services
+
+import (
+	"context"
+	"time"
+
+	"github.com/forta-network/disco/utils"
+)
+
+type testContextKey struct{}
+
+func (s *Suite) TestOperationContext() {
+	s.disco.cfg.Timeouts.Operation = time.Minute
+	parent, cancelParent := context.WithCancel(context.WithValue(s.ctx, testContextKey{}, "value"))
+
+	ctx, cancel := s.disco.operationContext(parent)
+	defer cancel()
+	cancelParent()
+
+	s.r.NoError(ctx.Err(), "should not be cancelled with the parent")
+	s.r.Equal("value", ctx.Value(testContextKey{}))
+	deadline, ok := ctx.Deadline()
+	s.r.True(ok)
+	s.r.WithinDuration(time.Now().Add(time.Minute), deadline, time.Second)
+}
+
+func (s *Suite) TestClonePriority() {
+	s.disco.cfg.Limits.HighPriority = []string{testCidv0}
+
+	s.r.Equal(utils.PriorityHigh, s.disco.clonePriority(s.ctx, testCidv1))
+	s.r.Equal(utils.PriorityHigh, s.disco.clonePriority(withBackgroundPriority(s.ctx), testCidv1))
+	s.r.Equal(utils.PriorityNormal, s.disco.clonePriority(s.ctx, testManifestDigest))
+	s.r.Equal(utils.PriorityBackground, s.disco.clonePriority(withBackgroundPriority(s.ctx), testManifestDigest))
+}
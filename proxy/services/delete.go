@@ -0,0 +1,228 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	storagedriver "github.com/distribution/distribution/v3/registry/storage/driver"
+	"github.com/forta-network/disco/drivers/multidriver"
+	"github.com/forta-network/disco/utils"
+	log "github.com/sirupsen/logrus"
+)
+
+// ResolveManifestDigest returns the manifest digest of the reference in the repository. The
+// reference can be a tag or a digest.
+func (disco *Disco) ResolveManifestDigest(ctx context.Context, repoName, reference string) (string, error) {
+	manifestDigest := strings.TrimPrefix(reference, "sha256:")
+	if utils.IsDigestHex(manifestDigest) {
+		return manifestDigest, nil
+	}
+	b, err := disco.getDriver().GetContent(ctx, makeTagLinkPath(repoName, reference))
+	if isPathNotFound(err) {
+		return "", fmt.Errorf("%w: %s:%s", ErrRepoNotFound, repoName, reference)
+	}
+	if err != nil {
+		return "", fmt.Errorf("failed to read the tag link: %v", err)
+	}
+	return strings.TrimPrefix(strings.TrimSpace(string(b)), "sha256:"), nil
+}
+
+// DeleteGlobalRepos removes the digest and the CID v1 repositories of the manifest from all of
+// the stores and the CID index after the manifest is deleted from the registry. The blobs which
+// are not referenced anymore are deleted later by the scheduled garbage collection.
+func (disco *Disco) DeleteGlobalRepos(ctx context.Context, manifestDigest string) error {
+	ctx, cancel := disco.operationContext(ctx)
+	defer cancel()
+	ctx, unlock, err := disco.lockRepos(ctx, manifestDigest)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	repoNames := []string{manifestDigest}
+	repoCid, err := disco.ResolveRepoCid(ctx, manifestDigest)
+	if err != nil {
+		utils.Logger(ctx).WithError(err).WithField("digest", manifestDigest).Warn("failed to find the cid repo of the deleted manifest")
+	} else {
+		repoNames = append(repoNames, repoCid)
+	}
+
+	for _, repoName := range repoNames {
+		if err := disco.deleteRepo(ctx, repoName); err != nil {
+			return err
+		}
+	}
+	disco.cids.removeRepo(manifestDigest)
+	utils.Logger(ctx).WithFields(log.Fields{
+		"digest":       manifestDigest,
+		"repositories": repoNames,
+	}).Info("deleted global repositories")
+
+	disco.scheduleGC()
+	return nil
+}
+
+// DeletedRepo is the result of deleting a CID v1 repository.
+type DeletedRepo struct {
+	Cid    string `json:"cid"`
+	Digest string `json:"digest"`
+	// Blobs are the digests of the blobs which were referenced only by the deleted repository.
+	Blobs []string `json:"blobs"`
+	// KeptBlobs is the number of the blobs which are still referenced by the other repositories.
+	KeptBlobs int `json:"keptBlobs"`
+}
+
+// DeleteGlobalRepo deletes the CID v1 repository, its digest repository and its disco file
+// from all of the stores. The blobs are deleted only if no other repository references them,
+// so that the space is reclaimed without waiting for the garbage collection. The references are
+// counted with the CID index, if it is configured. The blobs of the
+// pushes which have not linked a manifest yet are not known and are left to the GC age.
+func (disco *Disco) DeleteGlobalRepo(ctx context.Context, repoCid string) (*DeletedRepo, error) {
+	if !utils.IsCIDv1(repoCid) {
+		return nil, fmt.Errorf("%w: %s", ErrNotCIDName, repoCid)
+	}
+	ctx, cancel := disco.operationContext(ctx)
+	defer cancel()
+	manifestDigest, digests, err := disco.repoBlobDigests(ctx, repoCid)
+	if err != nil {
+		return nil, err
+	}
+	if len(manifestDigest) == 0 {
+		return nil, fmt.Errorf("%w: %s", ErrRepoNotFound, repoCid)
+	}
+	ctx, unlock, err := disco.lockRepos(ctx, manifestDigest)
+	if err != nil {
+		return nil, err
+	}
+	defer unlock()
+
+	// count the references before deleting anything so that a failure leaves the blobs alone
+	refCounts, err := disco.countBlobReferences(ctx, repoCid, manifestDigest, digests)
+	if err != nil {
+		return nil, err
+	}
+	for _, repoName := range []string{manifestDigest, repoCid} {
+		if err := disco.deleteRepo(ctx, repoName); err != nil {
+			return nil, err
+		}
+	}
+	disco.cids.removeRepo(manifestDigest)
+
+	deleted := &DeletedRepo{Cid: repoCid, Digest: "sha256:" + manifestDigest}
+	for _, digest := range digests {
+		if refCounts[digest] > 0 {
+			deleted.KeptBlobs++
+			continue
+		}
+		if err := disco.deleteFromStores(ctx, makeBlobDirPath(digest)); err != nil {
+			return deleted, fmt.Errorf("failed to delete blob %s: %v", digest, err)
+		}
+		deleted.Blobs = append(deleted.Blobs, digest)
+	}
+	utils.Logger(ctx).WithFields(log.Fields{
+		"cid":       repoCid,
+		"digest":    manifestDigest,
+		"blobs":     len(deleted.Blobs),
+		"keptBlobs": deleted.KeptBlobs,
+	}).Info("deleted global repository")
+	return deleted, nil
+}
+
+// countBlobReferences counts how many of the repositories reference each of the blobs, except
+// the repositories of the manifest. The blobs of the global repositories are taken from the CID
+// index if it has them, so that only the other repositories are read. The manifests in the index
+// which are not in the stores yet, e.g. the ones which are being made global, count, too.
+func (disco *Disco) countBlobReferences(ctx context.Context, repoCid, manifestDigest string, digests []string) (map[string]int, error) {
+	repos, err := disco.listRepos(ctx)
+	if err != nil {
+		return nil, err
+	}
+	refCounts, err := disco.cids.countRefs(digests, manifestDigest)
+	if err != nil {
+		return nil, err
+	}
+	for _, repoName := range repos {
+		if repoName == repoCid || repoName == manifestDigest {
+			continue
+		}
+		repoDigests, ok := disco.indexedBlobDigests(repoName)
+		if !ok {
+			_, repoDigests, err = disco.repoBlobDigests(ctx, repoName)
+			if err != nil {
+				return nil, fmt.Errorf("failed to count the blob references of %s: %v", repoName, err)
+			}
+		}
+		for _, digest := range repoDigests {
+			refCounts[digest]++
+		}
+	}
+	return refCounts, nil
+}
+
+// indexedBlobDigests returns the digests of the blobs which the digest or the CID v1 repository
+// references from the CID index, if the index has them.
+func (disco *Disco) indexedBlobDigests(repoName string) ([]string, bool) {
+	manifestDigest := repoName
+	if utils.IsCIDv1(repoName) {
+		var ok bool
+		if manifestDigest, ok = disco.cids.manifestDigest(repoName); !ok {
+			return nil, false
+		}
+	} else if !utils.IsDigestHex(repoName) {
+		return nil, false
+	}
+	return disco.cids.blobRefs(manifestDigest)
+}
+
+// repoBlobDigests returns the manifest digest and the blob digests of the repository from its
+// disco file. The repositories without a disco file, e.g. the ones in the cache-only mode and
+// the pushes in progress, are read from their manifests. The manifest digest is empty if the
+// repository has neither.
+func (disco *Disco) repoBlobDigests(ctx context.Context, repoName string) (string, []string, error) {
+	if b, err := disco.readFromStores(ctx, makeDiscoFilePath(repoName)); err == nil {
+		file, err := decodeDiscoFile(b)
+		if err != nil {
+			return "", nil, err
+		}
+		var digests []string
+		for _, blob := range file.Blobs {
+			digests = append(digests, blob.Digest)
+		}
+		return digests[0], digests, nil
+	}
+	digests, err := disco.referencedBlobs(ctx, repoName)
+	if err != nil || len(digests) == 0 {
+		return "", nil, err
+	}
+	return digests[0], digests, nil
+}
+
+// deleteRepo deletes the repository from all of the stores and forgets it.
+func (disco *Disco) deleteRepo(ctx context.Context, repoName string) error {
+	disco.cloned.remove(repoName)
+	disco.mediaTypes.Delete(repoName)
+	if err := disco.deleteFromStores(ctx, makeRepoPath(repoName)); err != nil {
+		return fmt.Errorf("failed to delete repo %s: %v", repoName, err)
+	}
+	return nil
+}
+
+// deleteFromStores deletes the path from all of the stores. The stores which do not have the
+// path are skipped.
+func (disco *Disco) deleteFromStores(ctx context.Context, contentPath string) error {
+	drivers := []storagedriver.StorageDriver{disco.getDriver()}
+	if multiDriver, ok := multidriver.Is(disco.getDriver()); ok {
+		drivers = []storagedriver.StorageDriver{multiDriver.Primary(), multiDriver.Secondary()}
+	}
+	for _, driver := range drivers {
+		err := driver.Delete(ctx, contentPath)
+		if _, ok := err.(storagedriver.PathNotFoundError); ok {
+			continue
+		}
+		if err != nil {
+			return fmt.Errorf("%s: %v", driver.Name(), err)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,176 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"path"
+
+	storagedriver "github.com/distribution/distribution/v3/registry/storage/driver"
+	"github.com/distribution/distribution/v3/registry/storage/driver/inmemory"
+	"github.com/forta-network/disco/config"
+)
+
+func (s *Suite) TestDeleteGlobalRepos() {
+	// Given the digest and the cid repos of a manifest
+	driver := inmemory.New()
+	s.r.NoError(driver.PutContent(s.ctx, s.disco.makeManifestLinkPath(testManifestDigest), []byte("sha256:"+testManifestDigest)))
+	s.r.NoError(driver.PutContent(s.ctx, makeTagLinkPath(testManifestDigest, testCidv1), []byte("sha256:"+testManifestDigest)))
+	s.r.NoError(driver.PutContent(s.ctx, s.disco.makeManifestLinkPath(testCidv1), []byte("sha256:"+testManifestDigest)))
+	s.disco.cfg = &config.Config{CacheOnly: true, CidIndex: path.Join(s.T().TempDir(), "cids.db")}
+	s.disco.getDriver = func() storagedriver.StorageDriver {
+		return driver
+	}
+	s.disco.cids = newCidIndex(s.disco.cfg.CidIndex, nil)
+	s.disco.cids.addRepo(testManifestDigest, testCidv1, nil)
+
+	// When the manifest is deleted by its tag
+	manifestDigest, err := s.disco.ResolveManifestDigest(s.ctx, testManifestDigest, testCidv1)
+	s.r.NoError(err)
+	s.r.Equal(testManifestDigest, manifestDigest)
+	s.r.NoError(s.disco.DeleteGlobalRepos(s.ctx, manifestDigest))
+
+	// Then both repos should be deleted
+	_, err = driver.Stat(s.ctx, makeRepoPath(testManifestDigest))
+	s.r.IsType(storagedriver.PathNotFoundError{}, err)
+	_, err = driver.Stat(s.ctx, makeRepoPath(testCidv1))
+	s.r.IsType(storagedriver.PathNotFoundError{}, err)
+	_, ok := s.disco.cids.repoCid(testManifestDigest)
+	s.r.False(ok)
+}
+
+func (s *Suite) TestDeleteGlobalRepo() {
+	const (
+		otherCid      = "bafybeiotherrepo"
+		otherManifest = "1111111111111111111111111111111111111111111111111111111111111111"
+	)
+	// Given a cid repo and another one which shares the config blob with it
+	driver := inmemory.New()
+	s.disco.cfg = &config.Config{CacheOnly: true}
+	s.disco.getDriver = func() storagedriver.StorageDriver {
+		return driver
+	}
+	writeDiscoFile := func(repoName string, digests ...string) {
+		file := &discoFile{Version: discoFileVersion, Digest: digests[0]}
+		for _, digest := range digests {
+			file.Blobs = append(file.Blobs, &blobCid{Digest: digest, Cid: "bafy" + digest[:8]})
+		}
+		b, err := json.Marshal(file)
+		s.r.NoError(err)
+		s.r.NoError(driver.PutContent(s.ctx, makeDiscoFilePath(repoName), b))
+	}
+	writeDiscoFile(testCidv1, testManifestDigest, testConfigDigest, testLayerDigest)
+	writeDiscoFile(otherCid, otherManifest, testConfigDigest)
+	s.r.NoError(driver.PutContent(s.ctx, makeTagLinkPath(testManifestDigest, testCidv1), []byte("sha256:"+testManifestDigest)))
+	for _, digest := range []string{testManifestDigest, testConfigDigest, testLayerDigest, otherManifest} {
+		s.r.NoError(driver.PutContent(s.ctx, makeBlobPath(digest), []byte(digest)))
+	}
+
+	// When the cid repo is deleted
+	deleted, err := s.disco.DeleteGlobalRepo(s.ctx, testCidv1)
+	s.r.NoError(err)
+
+	// Then only the blobs which are not referenced by the other repo should be deleted
+	s.r.Equal("sha256:"+testManifestDigest, deleted.Digest)
+	s.r.Equal([]string{testManifestDigest, testLayerDigest}, deleted.Blobs)
+	s.r.Equal(1, deleted.KeptBlobs)
+	for _, deletedPath := range []string{
+		makeRepoPath(testCidv1), makeRepoPath(testManifestDigest),
+		makeBlobDirPath(testManifestDigest), makeBlobDirPath(testLayerDigest),
+	} {
+		_, err = driver.Stat(s.ctx, deletedPath)
+		s.r.IsType(storagedriver.PathNotFoundError{}, err, deletedPath)
+	}
+	for _, keptPath := range []string{makeDiscoFilePath(otherCid), makeBlobPath(testConfigDigest), makeBlobPath(otherManifest)} {
+		_, err = driver.Stat(s.ctx, keptPath)
+		s.r.NoError(err, keptPath)
+	}
+
+	// And the unknown and the non-cid repos should not be deleted
+	_, err = s.disco.DeleteGlobalRepo(s.ctx, testCidv1)
+	s.r.ErrorIs(err, ErrRepoNotFound)
+	_, err = s.disco.DeleteGlobalRepo(s.ctx, "myrepo")
+	s.r.ErrorIs(err, ErrNotCIDName)
+}
+
+func (s *Suite) TestDeleteGlobalRepo_CidIndex() {
+	s.testDeleteGlobalRepoWithIndex(newCidIndex(path.Join(s.T().TempDir(), "cids.db"), nil))
+}
+
+func (s *Suite) TestDeleteGlobalRepo_SharedCidIndex() {
+	s.testDeleteGlobalRepoWithIndex(newCidIndex("", newTestStore()))
+}
+
+func (s *Suite) testDeleteGlobalRepoWithIndex(cids *cidIndex) {
+	const otherManifest = "1111111111111111111111111111111111111111111111111111111111111111"
+	// Given a cid repo which is in the cid index
+	driver := inmemory.New()
+	s.disco.cfg = &config.Config{CacheOnly: true}
+	s.disco.getDriver = func() storagedriver.StorageDriver {
+		return driver
+	}
+	s.disco.cids = cids
+	blobs := []*blobCid{
+		{Digest: testManifestDigest, Cid: testManifestCid},
+		{Digest: testConfigDigest, Cid: testConfigFileCid},
+		{Digest: testLayerDigest, Cid: testLayerCid},
+	}
+	b, err := json.Marshal(&discoFile{Version: discoFileVersion, Blobs: blobs, Digest: testManifestDigest})
+	s.r.NoError(err)
+	s.r.NoError(driver.PutContent(s.ctx, makeDiscoFilePath(testCidv1), b))
+	s.disco.cids.addRepo(testManifestDigest, testCidv1, blobs)
+	for _, blob := range blobs {
+		s.r.NoError(driver.PutContent(s.ctx, makeBlobPath(blob.Digest), []byte(blob.Digest)))
+	}
+
+	// And another manifest in the index which is not in the stores yet but shares the layer
+	s.disco.cids.addRepo(otherManifest, "bafybeiotherrepo", []*blobCid{{Digest: otherManifest}, {Digest: testLayerDigest}})
+
+	// When the cid repo is deleted
+	deleted, err := s.disco.DeleteGlobalRepo(s.ctx, testCidv1)
+	s.r.NoError(err)
+
+	// Then the layer should be kept for the other manifest
+	s.r.Equal([]string{testManifestDigest, testConfigDigest}, deleted.Blobs)
+	s.r.Equal(1, deleted.KeptBlobs)
+	_, err = driver.Stat(s.ctx, makeBlobPath(testLayerDigest))
+	s.r.NoError(err)
+	_, ok := s.disco.cids.blobRefs(testManifestDigest)
+	s.r.False(ok)
+}
+
+type testLocker struct {
+	locked   []string
+	unlocked []string
+}
+
+func (locker *testLocker) Lock(ctx context.Context, key string) (context.Context, func(), error) {
+	locker.locked = append(locker.locked, key)
+	return ctx, func() {
+		locker.unlocked = append(locker.unlocked, key)
+	}, nil
+}
+
+func (s *Suite) TestDeleteGlobalRepos_Locked() {
+	driver := inmemory.New()
+	s.disco.cfg = &config.Config{CacheOnly: true}
+	s.disco.getDriver = func() storagedriver.StorageDriver {
+		return driver
+	}
+	locker := &testLocker{}
+	s.disco.locker = locker
+
+	s.r.NoError(s.disco.DeleteGlobalRepos(s.ctx, testManifestDigest))
+
+	s.r.Equal([]string{testManifestDigest}, locker.locked)
+	s.r.Equal([]string{testManifestDigest}, locker.unlocked)
+}
+
+func (s *Suite) TestResolveManifestDigest_NotFound() {
+	driver := inmemory.New()
+	s.disco.getDriver = func() storagedriver.StorageDriver {
+		return driver
+	}
+
+	_, err := s.disco.ResolveManifestDigest(s.ctx, "myrepo", "v1")
+	s.r.ErrorIs(err, ErrRepoNotFound)
+}
@@ -0,0 +1,118 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	storagedriver "github.com/distribution/distribution/v3/registry/storage/driver"
+	"github.com/forta-network/disco/utils"
+	log "github.com/sirupsen/logrus"
+)
+
+// digestPush is the last manifest which was pushed by digest to a repository and the timer which
+// makes the repository global after the pushes stop.
+type digestPush struct {
+	digest string
+	timer  *time.Timer
+}
+
+// RecordDigestPush schedules making the repository global with the manifest which is pushed by
+// digest, if it is enabled. The manifest lists are pushed after their manifests, so each push
+// delays the globalization and the last pushed manifest wins.
+func (disco *Disco) RecordDigestPush(repoName, reference string) {
+	if !disco.cfg.DigestPushes.Enabled || utils.IsCIDv1(repoName) || utils.IsDigestHex(repoName) {
+		return
+	}
+	if !isSHA256Digest(reference) {
+		return
+	}
+	digest := reference[7:]
+	delay := disco.cfg.DigestPushes.Delay
+
+	disco.digestPushesMu.Lock()
+	defer disco.digestPushesMu.Unlock()
+	if push, ok := disco.digestPushes[repoName]; ok {
+		push.digest = digest
+		push.timer.Reset(delay)
+		return
+	}
+	if disco.digestPushes == nil {
+		disco.digestPushes = make(map[string]*digestPush)
+	}
+	disco.digestPushes[repoName] = &digestPush{
+		digest: digest,
+		timer: time.AfterFunc(delay, func() {
+			disco.makeGlobalDigestPush(repoName)
+		}),
+	}
+}
+
+func (disco *Disco) makeGlobalDigestPush(repoName string) {
+	disco.digestPushesMu.Lock()
+	push, ok := disco.digestPushes[repoName]
+	delete(disco.digestPushes, repoName)
+	disco.digestPushesMu.Unlock()
+	if !ok {
+		return
+	}
+	logger := log.WithFields(log.Fields{
+		"repository": repoName,
+		"digest":     push.digest,
+	})
+	err := disco.MakeGlobalDigestRepo(context.Background(), repoName, push.digest)
+	switch {
+	case errors.Is(err, ErrQuarantined):
+		logger.WithError(err).Warn("image pushed by digest is quarantined")
+	case err != nil:
+		logger.WithError(err).Error("failed to make global repo from the digest push")
+	}
+}
+
+// MakeGlobalDigestRepo makes the repository which was pushed by digest global, by tagging the
+// manifest with the canonical tag as the tag pushes do. An artifact which is attached to a
+// manifest, e.g. a signature pushed after the image, makes the manifest it is attached to global.
+// The repositories which were tagged or made global meanwhile are left alone.
+func (disco *Disco) MakeGlobalDigestRepo(ctx context.Context, repoName, manifestDigest string) error {
+	driver := disco.getDriver()
+
+	_, err := driver.Stat(ctx, disco.makeManifestLinkPath(repoName))
+	switch err.(type) {
+	case nil:
+		return nil
+	case storagedriver.PathNotFoundError:
+	default:
+		return fmt.Errorf("failed to check the canonical tag: %v", err)
+	}
+
+	for {
+		_, err := driver.Stat(ctx, makeRevisionsPath(repoName)+"/"+manifestDigest+"/link")
+		switch err.(type) {
+		case nil:
+		case storagedriver.PathNotFoundError:
+			return nil
+		default:
+			return fmt.Errorf("failed to check the manifest: %v", err)
+		}
+		manifest, err := disco.readManifestUsingDriver(ctx, driver, manifestDigest)
+		if err != nil {
+			return fmt.Errorf("failed to read the manifest: %v", err)
+		}
+		if manifest.Subject == nil || !isSHA256Digest(manifest.Subject.Digest) {
+			break
+		}
+		manifestDigest = manifest.Subject.Digest[7:]
+	}
+
+	link := []byte("sha256:" + manifestDigest)
+	for _, linkPath := range []string{
+		fmt.Sprintf("%s/index/sha256/%s/link", makeTagPathFor(repoName, disco.CanonicalTag()), manifestDigest),
+		disco.makeManifestLinkPath(repoName),
+	} {
+		if err := driver.PutContent(ctx, linkPath, link); err != nil {
+			return fmt.Errorf("failed to tag the manifest: %v", err)
+		}
+	}
+	return disco.MakeGlobalRepo(ctx, repoName)
+}
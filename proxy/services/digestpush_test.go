@@ -0,0 +1,77 @@
+package services
+
+import (
+	"fmt"
+	"strings"
+
+	storagedriver "github.com/distribution/distribution/v3/registry/storage/driver"
+	"github.com/distribution/distribution/v3/registry/storage/driver/inmemory"
+	"github.com/forta-network/disco/config"
+	"github.com/forta-network/disco/utils"
+)
+
+func (s *Suite) TestMakeGlobalDigestRepo() {
+	// Given a cache-only storage
+	driver := inmemory.New()
+	s.disco.cfg = &config.Config{CacheOnly: true}
+	s.disco.getDriver = func() storagedriver.StorageDriver {
+		return driver
+	}
+	revisionLinkPath := func(digest string) string {
+		return fmt.Sprintf("%s/%s/link", makeRevisionsPath("myrepo"), digest)
+	}
+
+	// When a manifest which was not pushed is made global
+	// Then nothing should be made global
+	s.r.NoError(s.disco.MakeGlobalDigestRepo(s.ctx, "myrepo", testManifestDigest))
+	repos, err := driver.List(s.ctx, repositoriesBase)
+	s.r.Error(err)
+	s.r.Empty(repos)
+
+	// Given a manifest and a signature which is attached to it, pushed by digest
+	signatureDigest := strings.Repeat("a", 64)
+	signature := fmt.Sprintf(`{"schemaVersion":2,"subject":{"digest":"sha256:%s"}}`, testManifestDigest)
+	s.r.NoError(driver.PutContent(s.ctx, makeBlobPath(testManifestDigest), []byte(testManifest)))
+	s.r.NoError(driver.PutContent(s.ctx, makeBlobPath(signatureDigest), []byte(signature)))
+	s.r.NoError(driver.PutContent(s.ctx, revisionLinkPath(testManifestDigest), []byte("sha256:"+testManifestDigest)))
+	s.r.NoError(driver.PutContent(s.ctx, revisionLinkPath(signatureDigest), []byte("sha256:"+signatureDigest)))
+
+	// When the repo is made global with the last pushed manifest, which is the signature
+	s.r.NoError(s.disco.MakeGlobalDigestRepo(s.ctx, "myrepo", signatureDigest))
+
+	// Then the manifest should be made global
+	repoCid, err := utils.ConvertSHA256HexToCIDv1(testManifestDigest)
+	s.r.NoError(err)
+	b, err := driver.GetContent(s.ctx, s.disco.makeManifestLinkPath(repoCid))
+	s.r.NoError(err)
+	s.r.Equal("sha256:"+testManifestDigest, string(b))
+	// And the pushed repo should be removed
+	_, err = driver.Stat(s.ctx, makeRepoPath("myrepo"))
+	s.r.IsType(storagedriver.PathNotFoundError{}, err)
+
+	// Given a repo which is tagged with the canonical tag
+	s.r.NoError(driver.PutContent(s.ctx, s.disco.makeManifestLinkPath("otherrepo"), []byte("sha256:"+signatureDigest)))
+
+	// When it is made global with the digest
+	// Then it should be left to the tag push
+	s.r.NoError(s.disco.MakeGlobalDigestRepo(s.ctx, "otherrepo", signatureDigest))
+	_, err = driver.Stat(s.ctx, makeRepoPath("otherrepo"))
+	s.r.NoError(err)
+}
+
+func (s *Suite) TestRecordDigestPush() {
+	s.disco.cfg = &config.Config{DigestPushes: config.DigestPushesConfig{Enabled: true, Delay: config.DefaultDigestPushesDelay}}
+
+	// When the manifests are pushed by digest
+	s.disco.RecordDigestPush("myrepo", "sha256:"+testLayerDigest)
+	s.disco.RecordDigestPush("myrepo", "sha256:"+testManifestDigest)
+	s.disco.RecordDigestPush("myrepo", "latest")
+	s.disco.RecordDigestPush(testCidv1, "sha256:"+testManifestDigest)
+
+	// Then the last manifest of the pushed repo should wait to be made global
+	s.disco.digestPushesMu.Lock()
+	defer s.disco.digestPushesMu.Unlock()
+	s.r.Len(s.disco.digestPushes, 1)
+	s.r.Equal(testManifestDigest, s.disco.digestPushes["myrepo"].digest)
+	s.disco.digestPushes["myrepo"].timer.Stop()
+}
@@ -3,36 +3,143 @@ package services
 import (
 	"context"
 	"fmt"
+	"net/http"
 	"strings"
+	"sync"
+	"time"
 
 	storagedriver "github.com/distribution/distribution/v3/registry/storage/driver"
 	"github.com/forta-network/disco/config"
-	"github.com/forta-network/disco/deps"
 	"github.com/forta-network/disco/drivers"
 	"github.com/forta-network/disco/drivers/ipfs"
 	"github.com/forta-network/disco/drivers/multidriver"
 	"github.com/forta-network/disco/interfaces"
 	"github.com/forta-network/disco/utils"
 	ipfsapi "github.com/ipfs/go-ipfs-api"
-	log "github.com/sirupsen/logrus"
 )
 
 // Disco service allows us to do Disco things on top of the
 // Distribution server.
 type Disco struct {
+	cfg           *config.Config
 	getIpfsClient getIpfsClientFunc
 	getDriver     getDriverFunc
+	pulls         *pullIndex
+	cids          *cidIndex
+	prefetching   sync.Map
+	mediaTypes    sync.Map
+	cloning       sync.Map
+	warmPinned    sync.Map
+	clones        *utils.ConcurrencyLimiter
+	cloned        *clonedRepos
+	locker        utils.Locker
+	usageStats    *usageStats
+	bandwidth     *bandwidthMeter
+	apiKeys       *apiKeys
+	uploads       *uploadSessions
+	nonces        sharedStore
+	pullPolicies  []PullPolicy
+	nameResolvers []NameResolver
+	names         *nameCache
+	// httpClient requests the external endpoints with the client TLS config.
+	httpClient *http.Client
+
+	// clonesInFlight makes the concurrent clones of a repository wait for one clone.
+	clonesInFlight utils.SingleFlight
+	// blobsInFlight does the same for the lazily cloned blobs.
+	blobsInFlight utils.SingleFlight
+	// operations has the statuses of the clones and the globalizations.
+	operations operationRegistry
+
+	gcMu        sync.Mutex
+	gcScheduled bool
+
+	// digestPushes has the repositories which are waiting to be made global after their
+	// pushes by digest.
+	digestPushesMu sync.Mutex
+	digestPushes   map[string]*digestPush
+
+	usageMu sync.Mutex
+	usage   int64
+	usageAt time.Time
+
+	alertsMu sync.Mutex
+	alerting map[string]bool
 }
 
 type getIpfsClientFunc func() interfaces.IPFSClient
 type getDriverFunc func() storagedriver.StorageDriver
 
 // NewDiscoService creates a new Disco service.
-func NewDiscoService() *Disco {
-	return &Disco{
-		getIpfsClient: deps.Get,
-		getDriver:     ipfs.Get,
+func NewDiscoService(cfg *config.Config, ipfsClient interfaces.IPFSClient) *Disco {
+	// the replicas which share a store share the indexes, too
+	store := newSharedStore(cfg.SharedState)
+	var pulls *pullIndex
+	if len(cfg.PullIndex) > 0 || store != nil {
+		pulls = newPullIndex(cfg.PullIndex, store)
+	}
+	var cids *cidIndex
+	if len(cfg.CidIndex) > 0 || store != nil {
+		cids = newCidIndex(cfg.CidIndex, store)
+	}
+	var clones *utils.ConcurrencyLimiter
+	if cfg.Limits.MaxClones > 0 {
+		clones = utils.NewConcurrencyLimiter(cfg.Limits.MaxClones, cfg.Limits.QueueSize)
+	}
+	var cloned *clonedRepos
+	if !cfg.CloneCache.Disabled {
+		cloned = newClonedRepos(cfg.CloneCache.TTL, store)
+	}
+	var stats *usageStats
+	if cfg.UsageStats.Enabled {
+		stats = newUsageStats()
+	}
+	var keys *apiKeys
+	if cfg.APIKeys.Enabled {
+		keys = newAPIKeys(cfg.APIKeys.File, store)
 	}
+	var bandwidth *bandwidthMeter
+	if cfg.Bandwidth.Enabled {
+		bandwidth = newBandwidthMeter(store)
+	}
+	httpClient := cfg.HTTPClient()
+	disco := &Disco{
+		cfg: cfg,
+		getIpfsClient: func() interfaces.IPFSClient {
+			return ipfsClient
+		},
+		getDriver: func() storagedriver.StorageDriver {
+			return ipfs.GetFor(cfg)
+		},
+		pulls:        pulls,
+		cids:         cids,
+		clones:       clones,
+		cloned:       cloned,
+		locker:       newLocker(cfg.Lock),
+		usageStats:   stats,
+		bandwidth:    bandwidth,
+		apiKeys:      keys,
+		uploads:      newUploadSessions(store),
+		nonces:       store,
+		pullPolicies: newPullPolicies(cfg.PullPolicy, httpClient),
+		names:        newNameCache(cfg.Names.TTL),
+		httpClient:   httpClient,
+	}
+	disco.nameResolvers = disco.newNameResolvers(cfg.Names)
+	return disco
+}
+
+// CanonicalTag returns the tag which the repositories are made global with.
+func (disco *Disco) CanonicalTag() string {
+	if len(disco.cfg.CanonicalTag) == 0 {
+		return config.DefaultCanonicalTag
+	}
+	return disco.cfg.CanonicalTag
+}
+
+// RecordPull records the pull time of the repository, if the pull index is configured.
+func (disco *Disco) RecordPull(repoName string) {
+	disco.pulls.record(repoName)
 }
 
 // MakeGlobalRepo makes the repo a globally addressable one. We achieve this by
@@ -72,7 +179,14 @@ func NewDiscoService() *Disco {
 //	    /tags
 //	      /latest
 //	      /<cidv1(QmWhatever2)>
-func (disco *Disco) MakeGlobalRepo(ctx context.Context, repoName string) error {
+func (disco *Disco) MakeGlobalRepo(ctx context.Context, repoName string) (err error) {
+	ctx, cancel := disco.operationContext(ctx)
+	defer cancel()
+	ctx, timer := disco.startOperation(ctx, operationMakeGlobal, repoName)
+	defer func() {
+		timer.done(err)
+	}()
+
 	ipfsClient := disco.getIpfsClient()
 	driver := disco.getDriver()
 
@@ -88,12 +202,31 @@ func (disco *Disco) MakeGlobalRepo(ctx context.Context, repoName string) error {
 	// cache-only mode produces the cid v1 repo by converting
 	// the manifest digest into a cid v1 hash and keeps the compatibility
 	// of the references
-	if config.CacheOnly {
-		b, err := driver.GetContent(ctx, makeManifestLinkPath(repoName))
+	if disco.cfg.CacheOnly {
+		b, err := driver.GetContent(ctx, disco.makeManifestLinkPath(repoName))
 		if err != nil {
 			return fmt.Errorf("failed to get manifest digest from cache-only driver: %v", err)
 		}
 		manifestDigest := string(b)[7:]
+		ctx, unlock, err := disco.lockRepos(ctx, manifestDigest)
+		if err != nil {
+			return err
+		}
+		defer unlock()
+		if len(disco.cfg.Scanner.Endpoint) > 0 {
+			manifest, err := disco.readManifestUsingDriver(ctx, driver, manifestDigest)
+			if err != nil {
+				return fmt.Errorf("failed to read the manifest: %v", err)
+			}
+			children, err := readChildManifests(ctx, disco.driverManifestReader(driver), manifest)
+			if err != nil {
+				return err
+			}
+			if err := disco.scanPush(ctx, driver, repoName, manifestDigest, manifest, children, nil); err != nil {
+				return err
+			}
+			timer.step("scan")
+		}
 		cacheCid, err := utils.ConvertSHA256HexToCIDv1(manifestDigest)
 		if err != nil {
 			return fmt.Errorf("failed to create cache-only cid: %v", err)
@@ -104,44 +237,93 @@ func (disco *Disco) MakeGlobalRepo(ctx context.Context, repoName string) error {
 		if _, err = drivers.Copy(ctx, driver, uploadRepoPath, makeRepoPath(cacheCid)); err != nil {
 			return fmt.Errorf("failed to create cache-only cid repo: %v", err)
 		}
-		if _, err = drivers.Copy(ctx, driver, makeTagPathFor(manifestDigest, "latest"), makeTagPathFor(manifestDigest, cacheCid)); err != nil {
+		if _, err = drivers.Copy(ctx, driver, makeTagPathFor(manifestDigest, disco.CanonicalTag()), makeTagPathFor(manifestDigest, cacheCid)); err != nil {
 			return fmt.Errorf("failed to create manifest digest tag in cid repo: %v", err)
 		}
+		if !disco.IsOnlyPullable(repoName) {
+			if err := driver.PutContent(ctx, makeOriginFilePath(manifestDigest), []byte(repoName)); err != nil {
+				utils.Logger(ctx).WithError(err).WithField("repository", repoName).Warn("failed to write the origin file")
+			}
+		}
+		disco.cids.addRepo(manifestDigest, cacheCid, nil)
+		timer.step("cache_copy")
 		return err
 	}
 
 	// Step #1
-	manifestDigest, err := disco.digestFromLink(ctx, makeManifestLinkPath(repoName))
+	manifestDigest, err := disco.digestFromLink(ctx, disco.makeManifestLinkPath(repoName))
 	if err != nil {
 		return fmt.Errorf("failed to read the digest from the link: %v", err)
 	}
+	// another replica may be making the same manifest global or deleting it
+	ctx, unlock, err := disco.lockRepos(ctx, manifestDigest)
+	if err != nil {
+		return err
+	}
+	defer unlock()
 	manifestDigestRepoPath := makeRepoPath(manifestDigest)
 	stat, err := driver.Stat(ctx, manifestDigestRepoPath)
 	if err == nil && stat.Size() > 0 {
-		log.Info("already made globally accessible - skipping")
+		utils.Logger(ctx).WithField("digest", manifestDigest).Info("already made globally accessible - skipping")
+		timer.skip()
 		return nil
 	}
 
-	// pushes can be successful after checks on the secondary driver
-	// so ensure that primary storage is up-to-date and avoid false positives
-	contentPaths, err := disco.populateBlobFilePaths(ctx, driver, manifestDigest)
+	// fail before duplicating the repo if the disco file cannot be produced from the manifest
+	manifest, err := disco.readManifestUsingDriver(ctx, driver, manifestDigest)
 	if err != nil {
-		return fmt.Errorf("failed to populate blob file paths: %v", err)
+		return fmt.Errorf("failed to read the manifest: %v", err)
+	}
+	if err := manifest.validate(); err != nil {
+		return err
 	}
+	// the manifest lists and the image indexes bring the blobs of all of their platforms
+	children, err := readChildManifests(ctx, disco.driverManifestReader(driver), manifest)
+	if err != nil {
+		return err
+	}
+	// the artifacts which are attached to the manifest, e.g. the signatures, travel with it
+	referrers, err := disco.attachedArtifacts(ctx, driver, repoName, manifestDigest, children)
+	if err != nil {
+		return err
+	}
+	blobs := imageBlobs(manifestDigest, manifest, append(children, referrers...))
+	// the manifest is read already
+	if err := disco.checkManifestBlobs(ctx, driver, blobs[1:]); err != nil {
+		return err
+	}
+	timer.step("validate")
+
+	// pushes can be successful after checks on the secondary driver
+	// so ensure that primary storage is up-to-date and avoid false positives
+	contentPaths := populateBlobFilePaths(blobs)
 	contentPaths = append(contentPaths, uploadRepoPath)
-	if err := disco.replicateInPrimary(driver, contentPaths); err != nil {
+	if err := disco.replicateInPrimary(ctx, driver, contentPaths); err != nil {
 		return nil
 	}
+	timer.step("replicate_primary")
 
-	blobs, err := disco.populateBlobsWithCids(ctx, manifestDigest)
-	if err != nil {
+	if err := disco.populateBlobsWithCids(ctx, blobs); err != nil {
 		return fmt.Errorf("failed to populate blobs: %v", err)
 	}
+	if err := disco.scanPush(ctx, driver, repoName, manifestDigest, manifest, children, blobs); err != nil {
+		return err
+	}
+	timer.step("scan")
+	root, err := disco.getCid(ctx, uploadRepoPath)
+	if err != nil {
+		return fmt.Errorf("failed to get the root cid: %v", err)
+	}
 	if err := disco.writeDiscoFile(ctx, repoName, &discoFile{
-		Blobs: blobs,
+		Version:   discoFileVersion,
+		Blobs:     blobs,
+		MediaType: manifest.mediaType(),
+		Digest:    manifestDigest,
+		Root:      root,
 	}); err != nil {
 		return fmt.Errorf("failed to write the disco file: %v", err)
 	}
+	timer.step("disco_file")
 
 	// Step #2
 	repoCid, err := disco.getCid(ctx, uploadRepoPath)
@@ -153,7 +335,7 @@ func (disco *Disco) MakeGlobalRepo(ctx context.Context, repoName string) error {
 		return fmt.Errorf("failed to convert cid v0 '%s' to v1: %v", repoCid, err)
 	}
 	ipfsCidRepoPath := makeRepoPath(repoCidV1)
-	cidRepoClient, err := ipfsClient.GetClientFor(ctx, ipfsCidRepoPath)
+	cidRepoClient, err := ipfsClient.GetClientForWrite(ctx, ipfsCidRepoPath)
 	if err != nil {
 		return fmt.Errorf("failed to find client for cid repo (to copy after upload is done): %v", err)
 	}
@@ -163,10 +345,11 @@ func (disco *Disco) MakeGlobalRepo(ctx context.Context, repoName string) error {
 	if err != nil && !strings.Contains(err.Error(), "already has entry") {
 		return fmt.Errorf("failed while duplicating with base32 cid: %v", err)
 	}
+	timer.step("cid_copy")
 
 	// Step #3
 	// make blob digest hex multiplexing logic work
-	manifestRepoClient, err := ipfsClient.GetClientFor(ctx, manifestDigestRepoPath)
+	manifestRepoClient, err := ipfsClient.GetClientForWrite(ctx, manifestDigestRepoPath)
 	if err != nil {
 		return fmt.Errorf("failed to find client for destination repo provider (before copying digest-name repo): %v", err)
 	}
@@ -175,17 +358,31 @@ func (disco *Disco) MakeGlobalRepo(ctx context.Context, repoName string) error {
 	if err := manifestRepoClient.FilesCp(ctx, fmt.Sprintf("/ipfs/%s", repoCid), manifestDigestRepoPath); err != nil {
 		return fmt.Errorf("failed while duplicating with digest: %v", err)
 	}
+	timer.step("digest_copy")
 
 	// Step #4
-	if err := disco.createTagForLatest(ctx, manifestDigest, repoCidV1); err != nil {
-		return fmt.Errorf("failed to create tag for latest")
+	if err := disco.createTagFromCanonical(ctx, manifestDigest, repoCidV1); err != nil {
+		return fmt.Errorf("failed to create tag from %s: %v", disco.CanonicalTag(), err)
+	}
+
+	disco.cids.addRepo(manifestDigest, repoCidV1, blobs)
+	disco.cids.recordPush(manifestDigest)
+	// the retention of the digest repo counts from the push
+	disco.pulls.record(manifestDigest)
+
+	// remember the pushed name in the digest repo so the repo can be listed with it
+	if !disco.IsOnlyPullable(repoName) {
+		if err := disco.getIpfsClient().FilesWrite(ctx, makeOriginFilePath(manifestDigest), strings.NewReader(repoName), ipfsapi.FilesWrite.Create(true)); err != nil {
+			utils.Logger(ctx).WithError(err).WithField("repository", repoName).Warn("failed to write the origin file")
+		}
 	}
 
 	// replicate repo definitions in secondary (blobs are already written)
 	contentPaths = []string{manifestDigestRepoPath, ipfsCidRepoPath}
-	if err := disco.replicateInSecondary(driver, contentPaths); err != nil {
+	if err := disco.replicateInSecondary(ctx, driver, contentPaths); err != nil {
 		return err
 	}
+	timer.step("replicate_secondary")
 	return nil
 }
 
@@ -201,75 +398,134 @@ func (disco *Disco) IsOnlyPullable(repoName string) bool {
 //  3. Use disco.json inside the repo files to copy the blobs over the network.
 //
 // The end result in the IPFS node's MFS should look like the one from MakeGlobalRepo and all CIDs should match.
-func (disco *Disco) CloneGlobalRepo(ctx context.Context, repoName string) error {
-	if config.CacheOnly {
+func (disco *Disco) CloneGlobalRepo(ctx context.Context, repoName string) (err error) {
+	if disco.cfg.CacheOnly {
 		return nil
 	}
 
 	// Step #1
 	if !utils.IsCIDv1(repoName) {
-		log.WithField("repository", repoName).Debugf("not a cidv1 name - not attempting to clone from ipfs")
+		utils.Logger(ctx).WithField("repository", repoName).Debug("not a cidv1 name - not attempting to clone from ipfs")
+		return nil
+	}
+	if disco.cloned.has(repoName) {
 		return nil
 	}
+	return disco.clonesInFlight.Do(ctx, repoName, func() error {
+		return disco.cloneGlobalRepo(ctx, repoName)
+	})
+}
+
+func (disco *Disco) cloneGlobalRepo(ctx context.Context, repoName string) (err error) {
+	// a clone may have finished right before this one started
+	if disco.cloned.has(repoName) {
+		return nil
+	}
+
+	// the request context is used only for waiting in the clone queue
+	reqCtx := ctx
+	ctx, cancel := disco.operationContext(reqCtx)
+	defer cancel()
 
-	ipfsClient := disco.getIpfsClient()
 	driver := disco.getDriver()
 
 	stat, err := driver.Stat(ctx, makeDiscoFilePath(repoName))
 	switch err.(type) {
 	case nil:
 		if !stat.IsDir() && stat.Size() > 0 {
-			log.WithField("repository", repoName).Debug("found in storage - not attempting to clone from ipfs")
+			utils.Logger(ctx).WithField("repository", repoName).Debug("found in storage - not attempting to clone from ipfs")
+			disco.cloned.add(repoName)
 			return nil
 		}
 
 	case storagedriver.PathNotFoundError:
-		log.WithField("repository", repoName).Info("not found in secondary - replicating from primary before pull")
+		utils.Logger(ctx).WithField("repository", repoName).Info("not found in secondary - replicating from primary before pull")
 		err = disco.tryReplicateInSecondary(ctx, makeRepoPath(repoName))
 		if err == nil {
+			disco.cloned.add(repoName)
 			return nil
 		}
-		log.WithField("repository", repoName).WithError(err).Warn("failed to replicate in secondary before pull")
+		utils.Logger(ctx).WithField("repository", repoName).WithError(err).Warn("failed to replicate in secondary before pull")
 		// continue cloning
 
 	default:
 		return fmt.Errorf("failed to check disco file using the driver: %v", err)
 	}
 
-	if config.NoClone {
+	if disco.cfg.NoClone {
 		return nil
 	}
 
+	// the background clones are cancelled when a high priority clone needs the slot
+	release, err := disco.clones.AcquireWithPriority(reqCtx, disco.clonePriority(ctx, repoName), cancel)
+	if err != nil {
+		return fmt.Errorf("failed to start cloning: %w", err)
+	}
+	defer release()
+	ctx, timer := disco.startOperation(ctx, operationClone, repoName)
+	defer func() {
+		timer.done(err)
+	}()
+
 	// Step #2 and #3
 	file, err := disco.readDiscoFile(ctx, repoName)
 	if err != nil {
-		return fmt.Errorf("failed to read the disco file: %v", err)
+		return fmt.Errorf("%w: failed to read the disco file: %v", ErrCloneFailed, err)
 	}
-	for _, blobCid := range file.Blobs {
-		// get the client without the provider: causes blobs to be replicated after increasing the amountof IPFS nodes
-		blobNodeClient, err := ipfsClient.GetClientFor(ctx, makeBlobPath(blobCid.Digest))
-		if err != nil {
-			return fmt.Errorf("failed to get blob node client: %v", err)
-		}
-		hasFile, err := disco.hasFile(ctx, blobNodeClient, makeBlobPath(blobCid.Digest))
-		if err != nil {
-			return fmt.Errorf("failed to check if blob exists: %v", err)
+	timer.step("disco_file")
+	// the layers are copied on their first requests when cloning lazily
+	blobs := disco.cloneBlobs(file.Blobs)
+	checks, err := disco.checkBlobs(ctx, blobs)
+	if err != nil {
+		return err
+	}
+	timer.step("blob_checks")
+	var existing int
+	for _, check := range checks {
+		if check.exists {
+			existing++
 		}
-		if hasFile {
+	}
+	progress := disco.startCloneProgress(repoName, len(checks), existing)
+	defer disco.finishCloneProgress(progress)
+	for _, check := range checks {
+		timer.op.addBlob(check.blob, check.exists)
+	}
+	for _, check := range checks {
+		if check.exists {
 			continue
 		}
-		_ = blobNodeClient.FilesMkdir(ctx, makeBlobDirPath(blobCid.Digest), ipfsapi.FilesMkdir.Parents(true))
-		if err := blobNodeClient.FilesCp(ctx, fmt.Sprintf("/ipfs/%s", blobCid.Cid), makeBlobPath(blobCid.Digest)); err != nil {
-			return fmt.Errorf("failed while copying blob %s (%s) from the network: %v", blobCid.Digest, blobCid.Cid, err)
+		blobCid := check.blob
+		_ = check.client.FilesMkdir(ctx, makeBlobDirPath(blobCid.Digest), ipfsapi.FilesMkdir.Parents(true))
+		if err := check.client.FilesCp(ctx, fmt.Sprintf("/ipfs/%s", blobCid.Cid), makeBlobPath(blobCid.Digest)); err != nil {
+			timer.op.blobFailed(blobCid.Digest, err)
+			return fmt.Errorf("%w: failed while copying blob %s (%s) from the network: %v", ErrCloneFailed, blobCid.Digest, blobCid.Cid, err)
+		}
+		var size int64
+		if stat, err := check.client.FilesStat(ctx, makeBlobPath(blobCid.Digest)); err == nil {
+			size = int64(stat.Size)
+		}
+		progress.blobCopied(size, blobCid.MediaType)
+		timer.op.blobCopied(blobCid.Digest, size)
+	}
+
+	timer.step("blob_copies")
+	if disco.cids != nil {
+		if manifestDigest, err := disco.digestFromLink(ctx, disco.makeManifestLinkPath(repoName)); err == nil {
+			disco.cids.addRepo(manifestDigest, repoName, file.Blobs)
 		}
 	}
 
 	// replicate repo definitions and blobs in secondary
 	contentPaths := []string{makeRepoPath(repoName)}
-	for _, blob := range file.Blobs {
+	for _, blob := range blobs {
 		contentPaths = append(contentPaths, makeBlobPath(blob.Digest))
 	}
-	return disco.replicateInSecondary(driver, contentPaths)
+	if err := disco.replicateInSecondary(ctx, driver, contentPaths); err != nil {
+		return err
+	}
+	disco.cloned.add(repoName)
+	return nil
 }
 
 func (disco *Disco) tryReplicateInSecondary(ctx context.Context, contentPath string) error {
@@ -277,17 +533,17 @@ func (disco *Disco) tryReplicateInSecondary(ctx context.Context, contentPath str
 	if !ok {
 		return nil
 	}
-	_, err := multiDriver.ReplicateInSecondary(contentPath)
+	_, err := multiDriver.ReplicateInSecondary(ctx, contentPath)
 	return err
 }
 
-func (disco *Disco) replicateInSecondary(driver storagedriver.StorageDriver, contentPaths []string) error {
+func (disco *Disco) replicateInSecondary(ctx context.Context, driver storagedriver.StorageDriver, contentPaths []string) error {
 	multiDriver, ok := multidriver.Is(driver)
 	if !ok {
 		return nil
 	}
 	for _, contentPath := range contentPaths {
-		_, err := multiDriver.ReplicateInSecondary(contentPath)
+		_, err := multiDriver.ReplicateInSecondary(ctx, contentPath)
 		if err != nil {
 			return fmt.Errorf("failed to replicate '%s' in secondary: %v", contentPath, err)
 		}
@@ -296,13 +552,13 @@ func (disco *Disco) replicateInSecondary(driver storagedriver.StorageDriver, con
 	return nil
 }
 
-func (disco *Disco) replicateInPrimary(driver storagedriver.StorageDriver, contentPaths []string) error {
+func (disco *Disco) replicateInPrimary(ctx context.Context, driver storagedriver.StorageDriver, contentPaths []string) error {
 	multiDriver, ok := multidriver.Is(driver)
 	if !ok {
 		return nil
 	}
 	for _, contentPath := range contentPaths {
-		_, err := multiDriver.ReplicateInPrimary(contentPath)
+		_, err := multiDriver.ReplicateInPrimary(ctx, contentPath)
 		if err != nil {
 			return fmt.Errorf("failed to replicate '%s' in primary: %v", contentPath, err)
 		}
@@ -6,6 +6,7 @@ import (
 	"errors"
 	"fmt"
 	"io"
+	"net/http"
 	"testing"
 	"time"
 
@@ -45,7 +46,10 @@ const (
 	testManifestCid   = "QmZFwJdqgfMKCK4by7nsTRCmQiPWJbVrvup62jjBhmgRP9"
 	testConfigFileCid = "QmXjXzaQbKkz8D8T1fHy6C3JeWX7Ez6JqTsJrRyzqW1cMS"
 	testLayerCid      = "QmZDpp1fytMpa7YJKR1CQcjM1vDbkA7K3giL7vTyEwjFdN"
-	testDiscoFile     = `{"blobs":[{"digest":"dca71257cd2e72840a21f0323234bb2e33fea6d949fa0f21c5102146f583486b","cid":"QmZFwJdqgfMKCK4by7nsTRCmQiPWJbVrvup62jjBhmgRP9"},{"digest":"69593048aa3acfee0f75f20b77acb549de2472063053f6730c4091b53f2dfb02","cid":"QmXjXzaQbKkz8D8T1fHy6C3JeWX7Ez6JqTsJrRyzqW1cMS"},{"digest":"b71f96345d44b237decc0c2d6c2f9ad0d17fde83dad7579608f1f0764d9686f2","cid":"QmZDpp1fytMpa7YJKR1CQcjM1vDbkA7K3giL7vTyEwjFdN"}]}
+	testRootCid       = "QmYwAPJzv5CZsnA625s3Xf2nemtYgPpHdWEz79ojWnPbdG"
+	testDiscoFile     = `{"version":2,"blobs":[{"digest":"dca71257cd2e72840a21f0323234bb2e33fea6d949fa0f21c5102146f583486b","cid":"QmZFwJdqgfMKCK4by7nsTRCmQiPWJbVrvup62jjBhmgRP9","size":528},{"digest":"69593048aa3acfee0f75f20b77acb549de2472063053f6730c4091b53f2dfb02","cid":"QmXjXzaQbKkz8D8T1fHy6C3JeWX7Ez6JqTsJrRyzqW1cMS","size":1457},{"digest":"b71f96345d44b237decc0c2d6c2f9ad0d17fde83dad7579608f1f0764d9686f2","cid":"QmZDpp1fytMpa7YJKR1CQcjM1vDbkA7K3giL7vTyEwjFdN","mediaType":"application/vnd.docker.image.rootfs.diff.tar.gzip","size":766607}],"mediaType":"application/vnd.docker.distribution.manifest.v2+json","digest":"dca71257cd2e72840a21f0323234bb2e33fea6d949fa0f21c5102146f583486b","root":"QmYwAPJzv5CZsnA625s3Xf2nemtYgPpHdWEz79ojWnPbdG"}
+`
+	testDiscoFileV1 = `{"version":1,"blobs":[{"digest":"dca71257cd2e72840a21f0323234bb2e33fea6d949fa0f21c5102146f583486b","cid":"QmZFwJdqgfMKCK4by7nsTRCmQiPWJbVrvup62jjBhmgRP9"},{"digest":"69593048aa3acfee0f75f20b77acb549de2472063053f6730c4091b53f2dfb02","cid":"QmXjXzaQbKkz8D8T1fHy6C3JeWX7Ez6JqTsJrRyzqW1cMS"},{"digest":"b71f96345d44b237decc0c2d6c2f9ad0d17fde83dad7579608f1f0764d9686f2","cid":"QmZDpp1fytMpa7YJKR1CQcjM1vDbkA7K3giL7vTyEwjFdN"}],"mediaType":"application/vnd.docker.distribution.manifest.v2+json"}
 `
 )
 
@@ -76,14 +80,17 @@ func (s *Suite) SetupTest() {
 	s.ipfsClient = mock_interfaces.NewMockIPFSClient(ctrl)
 	s.ipfsNode = mock_interfaces.NewMockIPFSFilesAPI(ctrl)
 	s.ipfsClient.EXPECT().GetClientFor(gomock.Any(), gomock.Any()).Return(s.ipfsNode, nil).AnyTimes()
+	s.ipfsClient.EXPECT().GetClientForWrite(gomock.Any(), gomock.Any()).Return(s.ipfsNode, nil).AnyTimes()
 	s.driver = mock_multidriver.NewMockMultiDriver(ctrl)
 	s.disco = &Disco{
+		cfg: &config.Config{},
 		getIpfsClient: func() interfaces.IPFSClient {
 			return s.ipfsClient
 		},
 		getDriver: func() storagedriver.StorageDriver {
 			return s.driver
 		},
+		httpClient: http.DefaultClient,
 	}
 }
 
@@ -141,68 +148,92 @@ func (s *Suite) TestMakeGlobalRepo() {
 	}, nil)
 	// And it should find the manifest digest
 	s.driver.EXPECT().Reader(gomock.Any(), makeBlobPath(testManifestDigest), int64(0)).Return(io.NopCloser(bytes.NewBufferString(testManifest)), nil)
+	// And find no artifacts which are attached to the manifest
+	s.driver.EXPECT().List(gomock.Any(), makeRevisionsPath("myrepo")).
+		Return([]string{makeRevisionsPath("myrepo") + "/" + testManifestDigest}, nil)
+	// And make sure that the blobs of the manifest exist
+	s.driver.EXPECT().Stat(gomock.Any(), makeBlobPath(testConfigDigest)).Return(&fileInfo{size: 1457}, nil)
+	s.driver.EXPECT().Stat(gomock.Any(), makeBlobPath(testLayerDigest)).Return(&fileInfo{size: 766607}, nil)
 	// And replicate each blob and the uploaded repository in primary
-	s.driver.EXPECT().ReplicateInPrimary(gomock.Any()).Times(3) // manifest, config and layer
-	s.driver.EXPECT().ReplicateInPrimary(makeRepoPath("myrepo"))
+	s.driver.EXPECT().ReplicateInPrimary(gomock.Any(), gomock.Any()).Times(3) // manifest, config and layer
+	s.driver.EXPECT().ReplicateInPrimary(gomock.Any(), makeRepoPath("myrepo"))
 
 	// And find the manifest link for the upload
-	s.ipfsClient.EXPECT().FilesRead(s.ctx, registryBase+"/repositories/myrepo/_manifests/tags/latest/current/link").
+	s.ipfsClient.EXPECT().FilesRead(gomock.Any(), registryBase+"/repositories/myrepo/_manifests/tags/latest/current/link").
 		Return(io.NopCloser(bytes.NewBuffer([]byte("sha256:"+testManifestDigest))), nil)
-	// And find the manifest blob from the repo
-	s.ipfsClient.EXPECT().FilesRead(s.ctx, registryBase+"/blobs/sha256/"+testManifestDigest[:2]+"/"+testManifestDigest+"/data").
-		Return(io.NopCloser(bytes.NewBufferString(testManifest)), nil)
 	// And find the CIDs for all of the blobs
-	s.ipfsClient.EXPECT().FilesStat(s.ctx, registryBase+"/blobs/sha256/"+testLayerDigest[:2]+"/"+testLayerDigest+"/data").
-		Return(&ipfsapi.FilesStatObject{Hash: testLayerCid}, nil)
-	s.ipfsClient.EXPECT().FilesStat(s.ctx, registryBase+"/blobs/sha256/"+testConfigDigest[:2]+"/"+testConfigDigest+"/data").
-		Return(&ipfsapi.FilesStatObject{Hash: testConfigFileCid}, nil)
-	s.ipfsClient.EXPECT().FilesStat(s.ctx, registryBase+"/blobs/sha256/"+testManifestDigest[:2]+"/"+testManifestDigest+"/data").
-		Return(&ipfsapi.FilesStatObject{Hash: testManifestCid}, nil)
+	s.ipfsClient.EXPECT().FilesStat(gomock.Any(), registryBase+"/blobs/sha256/"+testLayerDigest[:2]+"/"+testLayerDigest+"/data").
+		Return(&ipfsapi.FilesStatObject{Hash: testLayerCid, Size: 766607}, nil)
+	s.ipfsClient.EXPECT().FilesStat(gomock.Any(), registryBase+"/blobs/sha256/"+testConfigDigest[:2]+"/"+testConfigDigest+"/data").
+		Return(&ipfsapi.FilesStatObject{Hash: testConfigFileCid, Size: 1457}, nil)
+	s.ipfsClient.EXPECT().FilesStat(gomock.Any(), registryBase+"/blobs/sha256/"+testManifestDigest[:2]+"/"+testManifestDigest+"/data").
+		Return(&ipfsapi.FilesStatObject{Hash: testManifestCid, Size: 528}, nil)
+	// And get the root CID of the repo before the Disco file is added
+	s.ipfsClient.EXPECT().FilesStat(gomock.Any(), registryBase+"/repositories/myrepo").
+		Return(&ipfsapi.FilesStatObject{Hash: testRootCid}, nil)
 	// And write a Disco file
-	s.ipfsClient.EXPECT().FilesWrite(s.ctx, registryBase+"/repositories/myrepo/disco.json", (*bufferMatcher)(bytes.NewBufferString(testDiscoFile)), gomock.Any()).
+	s.ipfsClient.EXPECT().FilesWrite(gomock.Any(), registryBase+"/repositories/myrepo/disco.json", (*bufferMatcher)(bytes.NewBufferString(testDiscoFile)), gomock.Any()).
 		Return(nil)
 
 	// And get the CID for the repo and duplicate with the base32 CID v1
-	s.ipfsClient.EXPECT().FilesStat(s.ctx, registryBase+"/repositories/myrepo").
+	s.ipfsClient.EXPECT().FilesStat(gomock.Any(), registryBase+"/repositories/myrepo").
 		Return(&ipfsapi.FilesStatObject{Hash: testCidv0}, nil)
-	s.ipfsNode.EXPECT().FilesMkdir(s.ctx, repositoriesBase, gomock.Any()).Return(nil)
-	s.ipfsNode.EXPECT().FilesRm(s.ctx, makeRepoPath(testCidv1), true).Return(nil)
-	s.ipfsNode.EXPECT().FilesCp(s.ctx, fmt.Sprintf("/ipfs/%s", testCidv0), makeRepoPath(testCidv1)).
+	s.ipfsNode.EXPECT().FilesMkdir(gomock.Any(), repositoriesBase, gomock.Any()).Return(nil)
+	s.ipfsNode.EXPECT().FilesRm(gomock.Any(), makeRepoPath(testCidv1), true).Return(nil)
+	s.ipfsNode.EXPECT().FilesCp(gomock.Any(), fmt.Sprintf("/ipfs/%s", testCidv0), makeRepoPath(testCidv1)).
 		Return(nil)
 	// And duplicate the repo with digest name
-	s.ipfsNode.EXPECT().FilesMkdir(s.ctx, repositoriesBase, gomock.Any()).Return(nil)
-	s.ipfsNode.EXPECT().FilesRm(s.ctx, makeRepoPath(testManifestDigest), true).Return(nil)
-	s.ipfsNode.EXPECT().FilesCp(s.ctx, fmt.Sprintf("/ipfs/%s", testCidv0), makeRepoPath(testManifestDigest)).
+	s.ipfsNode.EXPECT().FilesMkdir(gomock.Any(), repositoriesBase, gomock.Any()).Return(nil)
+	s.ipfsNode.EXPECT().FilesRm(gomock.Any(), makeRepoPath(testManifestDigest), true).Return(nil)
+	s.ipfsNode.EXPECT().FilesCp(gomock.Any(), fmt.Sprintf("/ipfs/%s", testCidv0), makeRepoPath(testManifestDigest)).
 		Return(nil)
 	// And copy the "latest" tag as CID in the digest repo
-	s.ipfsClient.EXPECT().FilesCp(s.ctx, registryBase+"/repositories/"+testManifestDigest+"/_manifests/tags/latest",
+	s.ipfsClient.EXPECT().FilesCp(gomock.Any(), registryBase+"/repositories/"+testManifestDigest+"/_manifests/tags/latest",
 		registryBase+"/repositories/"+testManifestDigest+"/_manifests/tags/"+testCidv1).
 		Return(nil)
+	// And write the pushed name to the digest repo
+	s.ipfsClient.EXPECT().FilesWrite(gomock.Any(), makeOriginFilePath(testManifestDigest), gomock.Any(), gomock.Any()).Return(nil)
 	// And remove the pushed repo from MFS
-	s.driver.EXPECT().Delete(s.ctx, makeRepoPath("myrepo")).Return(nil)
+	s.driver.EXPECT().Delete(gomock.Any(), makeRepoPath("myrepo")).Return(nil)
 	// And replicate the files in the secondary storage
-	s.driver.EXPECT().ReplicateInSecondary(makeRepoPath(testManifestDigest)).Return(nil, nil)
-	s.driver.EXPECT().ReplicateInSecondary(makeRepoPath(testCidv1)).Return(nil, nil)
+	s.driver.EXPECT().ReplicateInSecondary(gomock.Any(), makeRepoPath(testManifestDigest)).Return(nil, nil)
+	s.driver.EXPECT().ReplicateInSecondary(gomock.Any(), makeRepoPath(testCidv1)).Return(nil, nil)
 
 	s.r.NoError(s.disco.MakeGlobalRepo(s.ctx, "myrepo"))
 }
 
+func (s *Suite) TestMakeGlobalRepo_MissingBlob() {
+	// Given that a repo was pushed successfully
+	// When the repo is intended to be made global automatically
+	// But a blob of the manifest is missing
+	// Then it should fail before producing the disco file
+	s.ipfsClient.EXPECT().FilesRead(gomock.Any(), s.disco.makeManifestLinkPath("myrepo")).
+		Return(io.NopCloser(bytes.NewBuffer([]byte("sha256:"+testManifestDigest))), nil)
+	s.driver.EXPECT().Stat(gomock.Any(), makeRepoPath(testManifestDigest)).Return(nil, storagedriver.PathNotFoundError{})
+	s.driver.EXPECT().Reader(gomock.Any(), makeBlobPath(testManifestDigest), int64(0)).Return(io.NopCloser(bytes.NewBufferString(testManifest)), nil)
+	s.driver.EXPECT().List(gomock.Any(), makeRevisionsPath("myrepo")).Return(nil, storagedriver.PathNotFoundError{})
+	s.driver.EXPECT().Stat(gomock.Any(), makeBlobPath(testConfigDigest)).Return(nil, storagedriver.PathNotFoundError{})
+	s.driver.EXPECT().Delete(gomock.Any(), makeRepoPath("myrepo")).Return(nil)
+
+	s.r.ErrorIs(s.disco.MakeGlobalRepo(s.ctx, "myrepo"), ErrInvalidManifest)
+}
+
 func (s *Suite) TestMakeGlobalRepo_AlreadyMadeGlobal() {
 	// Given that a repo was pushed successfully
 	// And made global previously
 	// When the repo is inteded to be made global automatically again
 	// Then it should find the manifest digest from the storage
-	s.ipfsClient.EXPECT().FilesRead(s.ctx, makeRepoPath("myrepo")+"/_manifests/tags/latest/current/link").
+	s.ipfsClient.EXPECT().FilesRead(gomock.Any(), makeRepoPath("myrepo")+"/_manifests/tags/latest/current/link").
 		Return(io.NopCloser(bytes.NewBuffer([]byte("sha256:"+testManifestDigest))), nil)
 	// And expect that there is a repo with digest as the name
-	s.driver.EXPECT().Stat(s.ctx, makeRepoPath(testManifestDigest)).
+	s.driver.EXPECT().Stat(gomock.Any(), makeRepoPath(testManifestDigest)).
 		Return(&fileInfo{
 			path:  makeRepoPath(testManifestDigest),
 			size:  1,
 			isDir: false,
 		}, nil)
 	// And finally remove the pushed repo from MFS
-	s.driver.EXPECT().Delete(s.ctx, makeRepoPath("myrepo")).Return(nil)
+	s.driver.EXPECT().Delete(gomock.Any(), makeRepoPath("myrepo")).Return(nil)
 
 	s.r.NoError(s.disco.MakeGlobalRepo(s.ctx, "myrepo"))
 }
@@ -214,7 +245,7 @@ func (s *Suite) TestCloneGlobalRepo() {
 	s.driver.EXPECT().Stat(gomock.Any(), makeDiscoFilePath(testCidv1)).Return(nil, storagedriver.PathNotFoundError{
 		Path: makeDiscoFilePath(testCidv1),
 	})
-	s.driver.EXPECT().ReplicateInSecondary(makeRepoPath(testCidv1)).Return(nil, storagedriver.PathNotFoundError{
+	s.driver.EXPECT().ReplicateInSecondary(gomock.Any(), makeRepoPath(testCidv1)).Return(nil, storagedriver.PathNotFoundError{
 		Path: makeRepoPath(testCidv1),
 	})
 	// And clone the image repository from the ipfs network to the local ipfs node
@@ -231,22 +262,26 @@ func (s *Suite) TestCloneGlobalRepo() {
 	s.ipfsNode.EXPECT().FilesStat(gomock.Any(), makeBlobPath(testManifestDigest)).Return(nil, errors.New("does not exist"))
 	s.ipfsNode.EXPECT().FilesMkdir(gomock.Any(), makeBlobDirPath(testManifestDigest), gomock.Any())
 	s.ipfsNode.EXPECT().FilesCp(gomock.Any(), fmt.Sprintf("/ipfs/%s", testManifestCid), makeBlobPath(testManifestDigest))
+	s.ipfsNode.EXPECT().FilesStat(gomock.Any(), makeBlobPath(testManifestDigest)).Return(&ipfsapi.FilesStatObject{Size: 10}, nil)
 
 	s.ipfsNode.EXPECT().FilesStat(gomock.Any(), makeBlobPath(testConfigDigest)).Return(nil, errors.New("does not exist"))
 	s.ipfsNode.EXPECT().FilesMkdir(gomock.Any(), makeBlobDirPath(testConfigDigest), gomock.Any())
 	s.ipfsNode.EXPECT().FilesCp(gomock.Any(), fmt.Sprintf("/ipfs/%s", testConfigFileCid), makeBlobPath(testConfigDigest))
+	s.ipfsNode.EXPECT().FilesStat(gomock.Any(), makeBlobPath(testConfigDigest)).Return(&ipfsapi.FilesStatObject{Size: 10}, nil)
 
 	s.ipfsNode.EXPECT().FilesStat(gomock.Any(), makeBlobPath(testLayerDigest)).Return(nil, errors.New("does not exist"))
 	s.ipfsNode.EXPECT().FilesMkdir(gomock.Any(), makeBlobDirPath(testLayerDigest), gomock.Any())
 	s.ipfsNode.EXPECT().FilesCp(gomock.Any(), fmt.Sprintf("/ipfs/%s", testLayerCid), makeBlobPath(testLayerDigest))
+	s.ipfsNode.EXPECT().FilesStat(gomock.Any(), makeBlobPath(testLayerDigest)).Return(&ipfsapi.FilesStatObject{Size: 10}, nil)
 
 	// And replicate the cloned files to the secondary storage
-	s.driver.EXPECT().ReplicateInSecondary(makeRepoPath(testCidv1)).Return(nil, nil)
-	s.driver.EXPECT().ReplicateInSecondary(makeBlobPath(testManifestDigest)).Return(nil, nil)
-	s.driver.EXPECT().ReplicateInSecondary(makeBlobPath(testConfigDigest)).Return(nil, nil)
-	s.driver.EXPECT().ReplicateInSecondary(makeBlobPath(testLayerDigest)).Return(nil, nil)
+	s.driver.EXPECT().ReplicateInSecondary(gomock.Any(), makeRepoPath(testCidv1)).Return(nil, nil)
+	s.driver.EXPECT().ReplicateInSecondary(gomock.Any(), makeBlobPath(testManifestDigest)).Return(nil, nil)
+	s.driver.EXPECT().ReplicateInSecondary(gomock.Any(), makeBlobPath(testConfigDigest)).Return(nil, nil)
+	s.driver.EXPECT().ReplicateInSecondary(gomock.Any(), makeBlobPath(testLayerDigest)).Return(nil, nil)
 
 	s.r.NoError(s.disco.CloneGlobalRepo(s.ctx, testCidv1))
+	s.r.Empty(s.disco.CloneProgress(), "should stop tracking the progress")
 }
 
 func (s *Suite) TestCloneGlobalRepo_AlreadyCloned() {
@@ -263,6 +298,21 @@ func (s *Suite) TestCloneGlobalRepo_AlreadyCloned() {
 	s.r.NoError(s.disco.CloneGlobalRepo(s.ctx, testCidv1))
 }
 
+func (s *Suite) TestCloneGlobalRepo_Memoized() {
+	// Given that a repo was found in the storage recently
+	// When the repo is pulled with base32 CID v1 again
+	// Then it should not check the storage again
+	s.disco.cloned = newClonedRepos(time.Minute, nil)
+	s.driver.EXPECT().Stat(gomock.Any(), makeDiscoFilePath(testCidv1)).Return(&fileInfo{
+		path:  makeDiscoFilePath(testCidv1),
+		size:  1,
+		isDir: false,
+	}, nil).Times(1)
+
+	s.r.NoError(s.disco.CloneGlobalRepo(s.ctx, testCidv1))
+	s.r.NoError(s.disco.CloneGlobalRepo(s.ctx, testCidv1))
+}
+
 func (s *Suite) TestCloneGlobalRepo_ExistsInPrimary() {
 	// Given that a repo was made global previously
 	// And already cloned and pulled
@@ -270,7 +320,7 @@ func (s *Suite) TestCloneGlobalRepo_ExistsInPrimary() {
 	// But the disco file doesn't exist and the repo exists
 	// Then it should stat repo the and abort cloning again
 	s.driver.EXPECT().Stat(gomock.Any(), makeDiscoFilePath(testCidv1)).Return(nil, storagedriver.PathNotFoundError{})
-	s.driver.EXPECT().ReplicateInSecondary(makeRepoPath(testCidv1)).Return(&fileInfo{
+	s.driver.EXPECT().ReplicateInSecondary(gomock.Any(), makeRepoPath(testCidv1)).Return(&fileInfo{
 		path:  makeDiscoFilePath(testCidv1),
 		size:  1,
 		isDir: false,
@@ -283,7 +333,7 @@ func (s *Suite) TestCloneGlobalRepo_NoClone() {
 	// Given that a repo is to be cloned
 	// When "no clone" setting is true
 	// Then cloning should be a no-op
-	config.NoClone = true
+	s.disco.cfg.NoClone = true
 	s.driver.EXPECT().Stat(gomock.Any(), makeDiscoFilePath(testCidv1)).Return(&fileInfo{
 		path:  makeDiscoFilePath(testCidv1),
 		size:  1,
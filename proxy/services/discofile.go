@@ -0,0 +1,107 @@
+package services
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/forta-network/disco/utils"
+	log "github.com/sirupsen/logrus"
+)
+
+// discoFileVersion is the version of the disco files which are written. The files without a
+// version are version 1 files which were written before the version was recorded.
+const discoFileVersion = 2
+
+// discoFileDecoders decode the disco files by their versions.
+var discoFileDecoders = map[int]func(b []byte) (*discoFile, error){
+	1: decodeDiscoFileV1,
+	2: decodeDiscoFileV2,
+}
+
+// discoFileFields are the fields which the current version knows.
+var discoFileFields = map[string]bool{
+	"version":   true,
+	"blobs":     true,
+	"mediaType": true,
+	"digest":    true,
+	"root":      true,
+}
+
+// decodeDiscoFile decodes the disco file with the decoder of its version. The files of the newer
+// versions are decoded with the latest decoder, so that the older nodes can pull the newer content
+// as long as the fields which they know are compatible.
+func decodeDiscoFile(b []byte) (*discoFile, error) {
+	var header struct {
+		Version int `json:"version"`
+	}
+	if err := json.Unmarshal(b, &header); err != nil {
+		return nil, fmt.Errorf("failed to decode disco file: %v", err)
+	}
+	version := header.Version
+	if version == 0 {
+		version = 1
+	}
+	decode, ok := discoFileDecoders[version]
+	if !ok && version < discoFileVersion {
+		return nil, fmt.Errorf("unsupported disco file version %d", version)
+	}
+	if !ok {
+		log.WithField("version", version).Warn("decoding newer disco file version with the compatible fields")
+		decode = discoFileDecoders[discoFileVersion]
+	}
+	file, err := decode(b)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode disco file version %d: %v", version, err)
+	}
+	file.Version = version
+	logUnknownDiscoFileFields(b)
+	return file, nil
+}
+
+// decodeDiscoFileV1 decodes the blob list and the manifest media type.
+func decodeDiscoFileV1(b []byte) (*discoFile, error) {
+	var file discoFile
+	if err := json.Unmarshal(b, &file); err != nil {
+		return nil, err
+	}
+	if len(file.Blobs) == 0 {
+		return nil, fmt.Errorf("no blobs")
+	}
+	for i, blob := range file.Blobs {
+		if blob == nil || len(blob.Digest) == 0 || len(blob.Cid) == 0 {
+			return nil, fmt.Errorf("blob %d should have a digest and a cid", i)
+		}
+	}
+	return &file, nil
+}
+
+// decodeDiscoFileV2 decodes the version 1 fields, the manifest digest, the root CID and the blob
+// sizes.
+func decodeDiscoFileV2(b []byte) (*discoFile, error) {
+	file, err := decodeDiscoFileV1(b)
+	if err != nil {
+		return nil, err
+	}
+	if !utils.IsDigestHex(file.Digest) {
+		return nil, fmt.Errorf("invalid manifest digest '%s'", file.Digest)
+	}
+	for i, blob := range file.Blobs {
+		if blob.Size < 0 {
+			return nil, fmt.Errorf("blob %d has a negative size", i)
+		}
+	}
+	return file, nil
+}
+
+// logUnknownDiscoFileFields logs the fields which were added by the newer versions.
+func logUnknownDiscoFileFields(b []byte) {
+	var fields map[string]json.RawMessage
+	if err := json.Unmarshal(b, &fields); err != nil {
+		return
+	}
+	for field := range fields {
+		if !discoFileFields[field] {
+			log.WithField("field", field).Debug("ignoring unknown disco file field")
+		}
+	}
+}
@@ -0,0 +1,52 @@
+package services
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestDecodeDiscoFile(t *testing.T) {
+	r := require.New(t)
+
+	file, err := decodeDiscoFile([]byte(testDiscoFile))
+	r.NoError(err)
+	r.Equal(discoFileVersion, file.Version)
+	r.Len(file.Blobs, 3)
+	r.Equal(mediaTypeDockerManifest, file.MediaType)
+	r.Equal(testManifestDigest, file.Digest)
+	r.Equal(testRootCid, file.Root)
+	r.Equal(int64(766607), file.Blobs[2].Size)
+
+	// written by the version 1
+	file, err = decodeDiscoFile([]byte(testDiscoFileV1))
+	r.NoError(err)
+	r.Equal(1, file.Version)
+	r.Len(file.Blobs, 3)
+	r.Empty(file.Digest)
+
+	// written before the version was recorded
+	file, err = decodeDiscoFile([]byte(`{"blobs":[{"digest":"` + testLayerDigest + `","cid":"` + testLayerCid + `"}]}`))
+	r.NoError(err)
+	r.Equal(1, file.Version)
+	r.Equal(testLayerCid, file.Blobs[0].Cid)
+
+	// written by a newer version with an unknown field
+	file, err = decodeDiscoFile([]byte(`{"version":3,"blobs":[{"digest":"` + testLayerDigest + `","cid":"` + testLayerCid + `"}],"digest":"` + testManifestDigest + `","signature":"abc"}`))
+	r.NoError(err)
+	r.Equal(3, file.Version)
+	r.Len(file.Blobs, 1)
+
+	for name, b := range map[string]string{
+		"not json":      `blobs`,
+		"no blobs":      `{"version":1}`,
+		"missing cid":   `{"blobs":[{"digest":"` + testLayerDigest + `"}]}`,
+		"incompatible":  `{"version":3,"blobs":"bafy"}`,
+		"wrong version": `{"version":-1,"blobs":[]}`,
+		"no digest":     `{"version":2,"blobs":[{"digest":"` + testLayerDigest + `","cid":"` + testLayerCid + `"}]}`,
+		"negative size": `{"version":2,"blobs":[{"digest":"` + testLayerDigest + `","cid":"` + testLayerCid + `","size":-1}],"digest":"` + testManifestDigest + `"}`,
+	} {
+		_, err := decodeDiscoFile([]byte(b))
+		r.Error(err, name)
+	}
+}
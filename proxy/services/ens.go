@@ -0,0 +1,80 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/ipfs/go-cid"
+)
+
+const (
+	ensSuffix = ".eth"
+	// ipfsContentHashPrefix is the multicodec prefix of the IPFS content hashes (EIP-1577).
+	ipfsContentHashPrefix = "\xe3\x01"
+	zeroAddress           = "0x0000000000000000000000000000000000000000"
+)
+
+// ensResolver resolves the ENS names to the IPFS content hashes which are set in their resolvers.
+type ensResolver struct {
+	endpoint   string
+	registry   string
+	httpClient *http.Client
+}
+
+func (resolver *ensResolver) ResolveName(ctx context.Context, name string) (string, bool, error) {
+	if !strings.HasSuffix(name, ensSuffix) {
+		return "", false, nil
+	}
+	node := ensNamehash(name)
+	result, err := ethCall(ctx, resolver.httpClient, resolver.endpoint, resolver.registry, append(abiSelector("resolver(bytes32)"), node...))
+	if err != nil {
+		return "", false, fmt.Errorf("failed to get the resolver of %s: %v", name, err)
+	}
+	resolverAddr, err := abiAddress(result, 0)
+	if err != nil {
+		return "", false, fmt.Errorf("invalid resolver of %s: %v", name, err)
+	}
+	if resolverAddr == zeroAddress {
+		return "", false, nil
+	}
+	result, err = ethCall(ctx, resolver.httpClient, resolver.endpoint, resolverAddr, append(abiSelector("contenthash(bytes32)"), node...))
+	if err != nil {
+		return "", false, fmt.Errorf("failed to get the content hash of %s: %v", name, err)
+	}
+	contentHash, err := abiDynamicBytes(result, 0)
+	if err != nil {
+		return "", false, fmt.Errorf("invalid content hash of %s: %v", name, err)
+	}
+	if len(contentHash) == 0 {
+		return "", false, nil
+	}
+	repoCid, err := decodeIPFSContentHash(contentHash)
+	if err != nil {
+		return "", false, fmt.Errorf("invalid content hash of %s: %v", name, err)
+	}
+	return repoCid, true, nil
+}
+
+// ensNamehash hashes the name as in EIP-137.
+func ensNamehash(name string) []byte {
+	node := make([]byte, 32)
+	labels := strings.Split(name, ".")
+	for i := len(labels) - 1; i >= 0; i-- {
+		node = keccak256(node, keccak256([]byte(labels[i])))
+	}
+	return node
+}
+
+// decodeIPFSContentHash decodes the IPFS content hash as a CID v1.
+func decodeIPFSContentHash(contentHash []byte) (string, error) {
+	if !strings.HasPrefix(string(contentHash), ipfsContentHashPrefix) {
+		return "", fmt.Errorf("not an ipfs content hash")
+	}
+	c, err := cid.Cast(contentHash[len(ipfsContentHashPrefix):])
+	if err != nil {
+		return "", err
+	}
+	return cid.NewCidV1(c.Type(), c.Hash()).String(), nil
+}
@@ -0,0 +1,44 @@
+package services
+
+import "errors"
+
+// Errors which the Disco service wraps, so that the callers can handle them with errors.Is.
+var (
+	// ErrRepoNotFound is returned when a repository is not found in the stores.
+	ErrRepoNotFound = errors.New("repository not found")
+	// ErrNotCIDName is returned when a CID v1 repository name is expected.
+	ErrNotCIDName = errors.New("not a cid v1 repository")
+	// ErrInvalidDigest is returned when a manifest digest is not a sha256 digest.
+	ErrInvalidDigest = errors.New("invalid digest")
+	// ErrInvalidTree is returned when a tree is requested from an unknown store or path.
+	ErrInvalidTree = errors.New("invalid tree request")
+	// ErrInvalidPrefetch is returned when the repositories cannot be prefetched with one request.
+	ErrInvalidPrefetch = errors.New("invalid prefetch request")
+	// ErrCloneFailed is returned when a repository cannot be cloned from the IPFS network.
+	ErrCloneFailed = errors.New("failed to clone the repository")
+	// ErrAlreadyGlobal is returned when a CID v1 or digest repository, which is made global
+	// by Disco, is pushed to.
+	ErrAlreadyGlobal = errors.New("repository is already global")
+	// ErrInvalidManifest is returned when a pushed manifest cannot be made global.
+	ErrInvalidManifest = errors.New("invalid manifest")
+	// ErrQuarantined is returned when a pushed image fails the security scan and is quarantined
+	// instead of being made global.
+	ErrQuarantined = errors.New("image is quarantined")
+	// ErrBandwidthExceeded is returned when the monthly bandwidth cap of a repository or a client
+	// is exceeded.
+	ErrBandwidthExceeded = errors.New("monthly bandwidth cap is exceeded")
+	// ErrAPIKeyNotFound is returned when an API key to revoke is not found.
+	ErrAPIKeyNotFound = errors.New("api key not found")
+	// ErrInvalidScope is returned when an API key is created with an unknown scope.
+	ErrInvalidScope = errors.New("invalid scope")
+	// ErrInvalidAPIKey is returned when a request is authorized with an unknown API key.
+	ErrInvalidAPIKey = errors.New("invalid api key")
+	// ErrNameResolution is returned when a repository name cannot be resolved to a CID v1
+	// repository because a resolver failed.
+	ErrNameResolution = errors.New("failed to resolve the repository name")
+	// ErrOperationNotFound is returned when the status of an unknown or an old operation is
+	// requested.
+	ErrOperationNotFound = errors.New("operation not found")
+	// ErrScopeDenied is returned when the API key of a request does not have the needed scope.
+	ErrScopeDenied = errors.New("api key does not have the scope")
+)
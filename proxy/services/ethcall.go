@@ -0,0 +1,113 @@
+package services
+
+import (
+	"bytes"
+	"context"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"net/http"
+	"strings"
+
+	"golang.org/x/crypto/sha3"
+)
+
+// ethCall calls the contract with the ABI-encoded data over the JSON-RPC endpoint with the HTTP
+// client and returns the result bytes.
+func ethCall(ctx context.Context, httpClient *http.Client, endpoint, to string, data []byte) ([]byte, error) {
+	b, err := json.Marshal(map[string]interface{}{
+		"jsonrpc": "2.0",
+		"id":      1,
+		"method":  "eth_call",
+		"params": []interface{}{
+			map[string]string{
+				"to":   to,
+				"data": "0x" + hex.EncodeToString(data),
+			},
+			"latest",
+		},
+	})
+	if err != nil {
+		return nil, err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, endpoint, bytes.NewReader(b))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("endpoint responded with status %d", resp.StatusCode)
+	}
+	var body struct {
+		Result string `json:"result"`
+		Error  *struct {
+			Code    int    `json:"code"`
+			Message string `json:"message"`
+		} `json:"error"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&body); err != nil {
+		return nil, fmt.Errorf("failed to decode the response: %v", err)
+	}
+	if body.Error != nil {
+		return nil, fmt.Errorf("eth_call failed with code %d: %s", body.Error.Code, body.Error.Message)
+	}
+	result, err := hex.DecodeString(strings.TrimPrefix(body.Result, "0x"))
+	if err != nil {
+		return nil, fmt.Errorf("invalid eth_call result: %v", err)
+	}
+	return result, nil
+}
+
+func keccak256(data ...[]byte) []byte {
+	hash := sha3.NewLegacyKeccak256()
+	for _, b := range data {
+		hash.Write(b)
+	}
+	return hash.Sum(nil)
+}
+
+// abiSelector returns the 4-byte selector of the function signature.
+func abiSelector(signature string) []byte {
+	return keccak256([]byte(signature))[:4]
+}
+
+// abiWord returns the 32-byte word at the index of the ABI-encoded result.
+func abiWord(result []byte, i int) ([]byte, error) {
+	if len(result) < (i+1)*32 {
+		return nil, fmt.Errorf("result is too short: %d bytes", len(result))
+	}
+	return result[i*32 : (i+1)*32], nil
+}
+
+// abiDynamicBytes decodes the bytes or the string which the word at the index points to.
+func abiDynamicBytes(result []byte, i int) ([]byte, error) {
+	word, err := abiWord(result, i)
+	if err != nil {
+		return nil, err
+	}
+	offset := new(big.Int).SetBytes(word)
+	if !offset.IsInt64() || offset.Int64()+32 > int64(len(result)) {
+		return nil, fmt.Errorf("invalid offset %s", offset)
+	}
+	start := int(offset.Int64())
+	length := new(big.Int).SetBytes(result[start : start+32])
+	if !length.IsInt64() || int64(start+32)+length.Int64() > int64(len(result)) {
+		return nil, fmt.Errorf("invalid length %s", length)
+	}
+	return result[start+32 : start+32+int(length.Int64())], nil
+}
+
+// abiAddress decodes the address from the word at the index.
+func abiAddress(result []byte, i int) (string, error) {
+	word, err := abiWord(result, i)
+	if err != nil {
+		return "", err
+	}
+	return "0x" + hex.EncodeToString(word[12:]), nil
+}
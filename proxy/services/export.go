@@ -0,0 +1,154 @@
+package services
+
+import (
+	"archive/tar"
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"time"
+
+	storagedriver "github.com/distribution/distribution/v3/registry/storage/driver"
+)
+
+// Export formats.
+const (
+	ExportFormatDocker = "docker"
+	ExportFormatOCI    = "oci"
+)
+
+// Export clones the repository from IPFS or the cache, if necessary, and writes it to w as
+// a tarball in given format: "docker" for docker load or "oci" for an OCI image layout.
+func (disco *Disco) Export(ctx context.Context, repoName, format string, w io.Writer) error {
+	if format != ExportFormatDocker && format != ExportFormatOCI {
+		return fmt.Errorf("unknown export format '%s'", format)
+	}
+	if err := disco.cloneFullRepo(ctx, repoName); err != nil {
+		return fmt.Errorf("failed to clone the repo: %v", err)
+	}
+	driver := disco.getDriver()
+	b, err := driver.GetContent(ctx, disco.makeManifestLinkPath(repoName))
+	if err != nil {
+		return fmt.Errorf("failed to read manifest link: %v", err)
+	}
+	manifestDigest := string(b)[7:]
+	manifestBytes, err := driver.GetContent(ctx, makeBlobPath(manifestDigest))
+	if err != nil {
+		return fmt.Errorf("failed to read manifest: %v", err)
+	}
+	var manifest imageManifest
+	if err := json.Unmarshal(manifestBytes, &manifest); err != nil {
+		return fmt.Errorf("failed to decode manifest: %v", err)
+	}
+	if manifest.isIndex() {
+		return fmt.Errorf("exporting the manifest lists and the image indexes is not supported")
+	}
+	configDigest := manifest.Config.Digest[7:]
+
+	tw := tar.NewWriter(w)
+	switch format {
+	case ExportFormatDocker:
+		dockerManifest := []struct {
+			Config   string
+			RepoTags []string
+			Layers   []string
+		}{
+			{
+				Config:   configDigest + ".json",
+				RepoTags: []string{repoName + ":" + disco.CanonicalTag()},
+			},
+		}
+		if err := exportBlob(ctx, tw, driver, configDigest, configDigest+".json"); err != nil {
+			return err
+		}
+		for _, layer := range manifest.Layers {
+			layerDigest := layer.Digest[7:]
+			layerPath := layerDigest + "/layer.tar"
+			if err := exportBlob(ctx, tw, driver, layerDigest, layerPath); err != nil {
+				return err
+			}
+			dockerManifest[0].Layers = append(dockerManifest[0].Layers, layerPath)
+		}
+		if err := writeTarJSON(tw, "manifest.json", dockerManifest); err != nil {
+			return err
+		}
+
+	case ExportFormatOCI:
+		if err := writeTarJSON(tw, "oci-layout", map[string]string{"imageLayoutVersion": "1.0.0"}); err != nil {
+			return err
+		}
+		mediaType := manifest.MediaType
+		if len(mediaType) == 0 {
+			mediaType = mediaTypeOCIManifest
+		}
+		index := map[string]interface{}{
+			"schemaVersion": 2,
+			"manifests": []map[string]interface{}{
+				{
+					"mediaType": mediaType,
+					"digest":    "sha256:" + manifestDigest,
+					"size":      len(manifestBytes),
+					"annotations": map[string]string{
+						ociRefNameAnnotation: disco.CanonicalTag(),
+					},
+				},
+			},
+		}
+		if err := writeTarJSON(tw, "index.json", index); err != nil {
+			return err
+		}
+		digests := []string{manifestDigest, configDigest}
+		for _, layer := range manifest.Layers {
+			digests = append(digests, layer.Digest[7:])
+		}
+		for _, digest := range digests {
+			if err := exportBlob(ctx, tw, driver, digest, "blobs/sha256/"+digest); err != nil {
+				return err
+			}
+		}
+	}
+	return tw.Close()
+}
+
+func exportBlob(ctx context.Context, tw *tar.Writer, driver storagedriver.StorageDriver, digest, name string) error {
+	blobPath := makeBlobPath(digest)
+	stat, err := driver.Stat(ctx, blobPath)
+	if err != nil {
+		return fmt.Errorf("failed to stat blob %s: %v", digest, err)
+	}
+	r, err := driver.Reader(ctx, blobPath, 0)
+	if err != nil {
+		return fmt.Errorf("failed to read blob %s: %v", digest, err)
+	}
+	defer r.Close()
+	if err := tw.WriteHeader(&tar.Header{
+		Name:    name,
+		Mode:    0644,
+		Size:    stat.Size(),
+		ModTime: time.Unix(0, 0),
+	}); err != nil {
+		return err
+	}
+	if _, err := io.Copy(tw, r); err != nil {
+		return fmt.Errorf("failed to export blob %s: %v", digest, err)
+	}
+	return nil
+}
+
+func writeTarJSON(tw *tar.Writer, name string, v interface{}) error {
+	var buf bytes.Buffer
+	if err := json.NewEncoder(&buf).Encode(v); err != nil {
+		return err
+	}
+	if err := tw.WriteHeader(&tar.Header{
+		Name:    name,
+		Mode:    0644,
+		Size:    int64(buf.Len()),
+		ModTime: time.Unix(0, 0),
+	}); err != nil {
+		return err
+	}
+	_, err := tw.Write(buf.Bytes())
+	return err
+}
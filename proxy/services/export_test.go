@@ -0,0 +1,67 @@
+package services
+
+import (
+	"archive/tar"
+	"bytes"
+	"encoding/json"
+	"io"
+
+	storagedriver "github.com/distribution/distribution/v3/registry/storage/driver"
+	"github.com/distribution/distribution/v3/registry/storage/driver/inmemory"
+	"github.com/forta-network/disco/config"
+)
+
+func (s *Suite) TestExport() {
+	// Given that a repo is in the cache-only storage
+	driver := inmemory.New()
+	s.r.NoError(driver.PutContent(s.ctx, s.disco.makeManifestLinkPath(testCidv1), []byte("sha256:"+testManifestDigest)))
+	s.r.NoError(driver.PutContent(s.ctx, makeBlobPath(testManifestDigest), []byte(testManifest)))
+	s.r.NoError(driver.PutContent(s.ctx, makeBlobPath(testConfigDigest), []byte("config")))
+	s.r.NoError(driver.PutContent(s.ctx, makeBlobPath(testLayerDigest), []byte("layer")))
+	s.disco.cfg = &config.Config{CacheOnly: true}
+	s.disco.getDriver = func() storagedriver.StorageDriver {
+		return driver
+	}
+
+	for _, format := range []string{ExportFormatDocker, ExportFormatOCI} {
+		// When the repo is exported
+		var buf bytes.Buffer
+		s.r.NoError(s.disco.Export(s.ctx, testCidv1, format, &buf))
+
+		// Then the tarball should contain the blobs and the metadata of the format
+		files := make(map[string][]byte)
+		tr := tar.NewReader(&buf)
+		for {
+			hdr, err := tr.Next()
+			if err == io.EOF {
+				break
+			}
+			s.r.NoError(err)
+			b, err := io.ReadAll(tr)
+			s.r.NoError(err)
+			files[hdr.Name] = b
+		}
+		switch format {
+		case ExportFormatDocker:
+			s.r.Equal("config", string(files[testConfigDigest+".json"]))
+			s.r.Equal("layer", string(files[testLayerDigest+"/layer.tar"]))
+			var manifest []struct {
+				Config   string
+				RepoTags []string
+				Layers   []string
+			}
+			s.r.NoError(json.Unmarshal(files["manifest.json"], &manifest))
+			s.r.Len(manifest, 1)
+			s.r.Equal([]string{testCidv1 + ":latest"}, manifest[0].RepoTags)
+			s.r.Equal([]string{testLayerDigest + "/layer.tar"}, manifest[0].Layers)
+
+		case ExportFormatOCI:
+			s.r.Contains(files, "oci-layout")
+			s.r.Contains(string(files["index.json"]), "sha256:"+testManifestDigest)
+			s.r.Equal(testManifest, string(files["blobs/sha256/"+testManifestDigest]))
+			s.r.Equal("layer", string(files["blobs/sha256/"+testLayerDigest]))
+		}
+	}
+
+	s.r.Error(s.disco.Export(s.ctx, testCidv1, "zip", io.Discard))
+}
@@ -6,12 +6,11 @@ import (
 	"encoding/json"
 	"fmt"
 	"io"
-	"strings"
 
 	storagedriver "github.com/distribution/distribution/v3/registry/storage/driver"
 	"github.com/forta-network/disco/interfaces"
+	"github.com/forta-network/disco/utils"
 	ipfsapi "github.com/ipfs/go-ipfs-api"
-	log "github.com/sirupsen/logrus"
 )
 
 func (disco *Disco) digestFromLink(ctx context.Context, path string) (string, error) {
@@ -28,21 +27,30 @@ func (disco *Disco) digestFromLink(ctx context.Context, path string) (string, er
 }
 
 type imageManifest struct {
-	Config struct {
-		Digest string `json:"digest"`
+	SchemaVersion int    `json:"schemaVersion"`
+	MediaType     string `json:"mediaType"`
+	ArtifactType  string `json:"artifactType"`
+	Config        struct {
+		MediaType string `json:"mediaType"`
+		Digest    string `json:"digest"`
+		Size      int64  `json:"size"`
 	} `json:"config"`
 	Layers []struct {
-		Digest string `json:"digest"`
+		MediaType string `json:"mediaType"`
+		Digest    string `json:"digest"`
+		Size      int64  `json:"size"`
 	} `json:"layers"`
-}
-
-func (disco *Disco) readManifestFromIPFS(ctx context.Context, digest string) (*imageManifest, error) {
-	r, err := disco.getIpfsClient().FilesRead(ctx, makeBlobPath(digest))
-	if err != nil {
-		return nil, err
-	}
-	var manifest imageManifest
-	return &manifest, json.NewDecoder(r).Decode(&manifest)
+	// Manifests are the per-platform manifests of a manifest list or an image index.
+	Manifests []struct {
+		MediaType string `json:"mediaType"`
+		Digest    string `json:"digest"`
+		Size      int64  `json:"size"`
+	} `json:"manifests"`
+	// Subject is the manifest which an artifact, e.g. a signature or an SBOM, is attached to.
+	Subject *struct {
+		Digest string `json:"digest"`
+	} `json:"subject"`
+	Annotations map[string]string `json:"annotations"`
 }
 
 func (disco *Disco) getCid(ctx context.Context, path string) (string, error) {
@@ -53,48 +61,18 @@ func (disco *Disco) getCid(ctx context.Context, path string) (string, error) {
 	return stat.Hash, nil
 }
 
-func (disco *Disco) getBlobCid(ctx context.Context, digest string) (string, error) {
-	return disco.getCid(ctx, makeBlobPath(digest))
-}
-
-func (disco *Disco) populateBlobsWithCids(ctx context.Context, manifestDigest string) ([]*blobCid, error) {
-	manifest, err := disco.readManifestFromIPFS(ctx, manifestDigest)
-	if err != nil {
-		return nil, err
-	}
-	configDigest := manifest.Config.Digest[7:]
-
-	manifestCid, err := disco.getBlobCid(ctx, manifestDigest)
-	if err != nil {
-		return nil, err
-	}
-	configCid, err := disco.getBlobCid(ctx, configDigest)
-	if err != nil {
-		return nil, err
-	}
-
-	blobs := []*blobCid{
-		{
-			Digest: manifestDigest,
-			Cid:    manifestCid,
-		},
-		{
-			Digest: configDigest,
-			Cid:    configCid,
-		},
-	}
-	for _, layer := range manifest.Layers {
-		layerDigest := layer.Digest[7:]
-		layerCid, err := disco.getBlobCid(ctx, layerDigest)
+// populateBlobsWithCids sets the CIDs and the sizes of the blobs from the IPFS node.
+func (disco *Disco) populateBlobsWithCids(ctx context.Context, blobs []*blobCid) error {
+	for _, blob := range blobs {
+		blobPath := makeBlobPath(blob.Digest)
+		stat, err := disco.getIpfsClient().FilesStat(ctx, blobPath)
 		if err != nil {
-			return nil, err
+			return fmt.Errorf("failed to get cid for %s: %v", blobPath, err)
 		}
-		blobs = append(blobs, &blobCid{
-			Digest: layerDigest,
-			Cid:    layerCid,
-		})
+		blob.Cid = stat.Hash
+		blob.Size = int64(stat.Size)
 	}
-	return blobs, nil
+	return nil
 }
 
 func (disco *Disco) readManifestUsingDriver(ctx context.Context, driver storagedriver.StorageDriver, digest string) (*imageManifest, error) {
@@ -107,14 +85,28 @@ func (disco *Disco) readManifestUsingDriver(ctx context.Context, driver storaged
 	return &manifest, json.NewDecoder(r).Decode(&manifest)
 }
 
-func (disco *Disco) populateBlobFilePaths(ctx context.Context, driver storagedriver.StorageDriver, manifestDigest string) (blobs []string, err error) {
-	manifest, err := disco.readManifestUsingDriver(ctx, driver, manifestDigest)
-	if err != nil {
-		return nil, err
+// driverManifestReader reads the manifests with the driver.
+func (disco *Disco) driverManifestReader(driver storagedriver.StorageDriver) manifestReader {
+	return func(ctx context.Context, digest string) (*imageManifest, error) {
+		return disco.readManifestUsingDriver(ctx, driver, digest)
 	}
-	blobs = append(blobs, makeBlobPath(manifestDigest), makeBlobPath(manifest.Config.Digest[7:]))
-	for _, layer := range manifest.Layers {
-		blobs = append(blobs, makeBlobPath(layer.Digest[7:]))
+}
+
+// storesManifestReader reads the manifests from any of the stores.
+func (disco *Disco) storesManifestReader() manifestReader {
+	return func(ctx context.Context, digest string) (*imageManifest, error) {
+		b, err := disco.readFromStores(ctx, makeBlobPath(digest))
+		if err != nil {
+			return nil, err
+		}
+		var manifest imageManifest
+		return &manifest, json.Unmarshal(b, &manifest)
+	}
+}
+
+func populateBlobFilePaths(blobs []*blobCid) (paths []string) {
+	for _, blob := range blobs {
+		paths = append(paths, makeBlobPath(blob.Digest))
 	}
 	return
 }
@@ -122,10 +114,27 @@ func (disco *Disco) populateBlobFilePaths(ctx context.Context, driver storagedri
 type blobCid struct {
 	Digest string `json:"digest"`
 	Cid    string `json:"cid"`
+	// MediaType is the media type of a layer, so that the compression of the layers is known
+	// without the manifest. It is empty for the other blobs and in the older disco files.
+	MediaType string `json:"mediaType,omitempty"`
+	// Size is the size of the blob, so that the downloads can be pre-allocated and verified. It
+	// is empty in the version 1 disco files.
+	Size int64 `json:"size,omitempty"`
 }
 
 type discoFile struct {
-	Blobs []*blobCid `json:"blobs"`
+	// Version is the version of the disco file. See decodeDiscoFile.
+	Version int        `json:"version,omitempty"`
+	Blobs   []*blobCid `json:"blobs"`
+	// MediaType is the media type of the manifest. It is empty in the disco files which were
+	// produced before it was recorded.
+	MediaType string `json:"mediaType,omitempty"`
+	// Digest is the manifest digest. It is empty in the version 1 disco files.
+	Digest string `json:"digest,omitempty"`
+	// Root is the CID of the repository directory before the disco file was added to it, since
+	// the file cannot contain the CID of the directory which contains it. It is empty in the
+	// version 1 disco files.
+	Root string `json:"root,omitempty"`
 }
 
 func (disco *Disco) writeDiscoFile(ctx context.Context, repoName string, discoFile *discoFile) error {
@@ -140,7 +149,7 @@ func (disco *Disco) writeDiscoFile(ctx context.Context, repoName string, discoFi
 }
 
 func (disco *Disco) readDiscoFile(ctx context.Context, repoName string) (*discoFile, error) {
-	nodeClient, err := disco.getIpfsClient().GetClientFor(ctx, makeRepoPath(repoName))
+	nodeClient, err := disco.getIpfsClient().GetClientForWrite(ctx, makeRepoPath(repoName))
 	if err != nil {
 		return nil, fmt.Errorf("failed to route to provider client (before cloning global): %v", err)
 	}
@@ -154,20 +163,21 @@ func (disco *Disco) readDiscoFile(ctx context.Context, repoName string) (*discoF
 			return nil, fmt.Errorf("failed while copying the repo from the network: %v", err)
 		}
 	}
-	log.WithError(err).Debugf("disco.json path: %s", makeDiscoFilePath(repoName))
+	utils.Logger(ctx).WithField("path", makeDiscoFilePath(repoName)).Debug("reading the disco file")
 	r, err := nodeClient.FilesRead(ctx, makeDiscoFilePath(repoName))
 	if err != nil {
 		return nil, err
 	}
-	var file discoFile
-	if err := json.NewDecoder(r).Decode(&file); err != nil {
-		return nil, fmt.Errorf("failed to decode disco file: %v", err)
+	defer r.Close()
+	b, err := io.ReadAll(r)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read disco file: %v", err)
 	}
-	return &file, nil
+	return decodeDiscoFile(b)
 }
 
-func (disco *Disco) createTagForLatest(ctx context.Context, repoName, tag string) error {
-	return disco.getIpfsClient().FilesCp(ctx, makeTagPathFor(repoName, "latest"), makeTagPathFor(repoName, tag))
+func (disco *Disco) createTagFromCanonical(ctx context.Context, repoName, tag string) error {
+	return disco.getIpfsClient().FilesCp(ctx, makeTagPathFor(repoName, disco.CanonicalTag()), makeTagPathFor(repoName, tag))
 }
 
 func (disco *Disco) hasFile(ctx context.Context, client interfaces.IPFSFilesAPI, path string) (bool, error) {
@@ -176,7 +186,7 @@ func (disco *Disco) hasFile(ctx context.Context, client interfaces.IPFSFilesAPI,
 	case err == nil:
 		return true, nil
 
-	case err != nil && strings.Contains(err.Error(), "does not exist"):
+	case isNotExistErr(err):
 		return false, nil
 
 	default:
@@ -0,0 +1,93 @@
+package services
+
+import (
+	"context"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"path"
+	"regexp"
+	"strings"
+
+	"github.com/forta-network/disco/utils"
+	ipfsapi "github.com/ipfs/go-ipfs-api"
+)
+
+// fortaAgentIDPattern matches the Forta agent IDs.
+var fortaAgentIDPattern = regexp.MustCompile(`^0x[0-9a-f]{64}$`)
+
+// fortaResolver resolves the Forta agent IDs to the image repositories in the agent manifests.
+// The manifest CID is read from the agent registry and the manifest is read from IPFS.
+type fortaResolver struct {
+	endpoint string
+	registry string
+	disco    *Disco
+}
+
+type fortaAgentManifest struct {
+	Manifest struct {
+		ImageReference string `json:"imageReference"`
+	} `json:"manifest"`
+}
+
+func (resolver *fortaResolver) ResolveName(ctx context.Context, name string) (string, bool, error) {
+	if !fortaAgentIDPattern.MatchString(name) {
+		return "", false, nil
+	}
+	agentID, _ := hex.DecodeString(strings.TrimPrefix(name, "0x"))
+	result, err := ethCall(ctx, resolver.disco.httpClient, resolver.endpoint, resolver.registry, append(abiSelector("getAgent(uint256)"), agentID...))
+	if err != nil {
+		return "", false, fmt.Errorf("failed to get agent %s: %v", name, err)
+	}
+	metadata, err := abiDynamicBytes(result, 3)
+	if err != nil {
+		return "", false, fmt.Errorf("invalid metadata of agent %s: %v", name, err)
+	}
+	if len(metadata) == 0 {
+		return "", false, nil
+	}
+	manifest, err := resolver.readManifest(ctx, string(metadata))
+	if err != nil {
+		return "", false, fmt.Errorf("failed to read the manifest of agent %s: %v", name, err)
+	}
+	repoCid := path.Base(strings.Split(manifest.Manifest.ImageReference, "@")[0])
+	if !utils.IsCIDv1(repoCid) {
+		return "", false, fmt.Errorf("agent %s has an invalid image reference '%s'", name, manifest.Manifest.ImageReference)
+	}
+	return repoCid, true, nil
+}
+
+// readManifest copies the manifest to MFS to read it.
+func (resolver *fortaResolver) readManifest(ctx context.Context, manifestCid string) (*fortaAgentManifest, error) {
+	if !utils.IsCID(manifestCid) {
+		return nil, fmt.Errorf("invalid manifest cid '%s'", manifestCid)
+	}
+	manifestPath := fmt.Sprintf(agentManifestPathFormat, manifestCid)
+	// any node can read the manifest from the network and the path is not routed
+	nodeClients := resolver.disco.getIpfsClient().NodeClients()
+	if len(nodeClients) == 0 {
+		return nil, fmt.Errorf("no ipfs nodes")
+	}
+	client := nodeClients[0]
+	_ = client.FilesMkdir(ctx, path.Dir(manifestPath), ipfsapi.FilesMkdir.Parents(true))
+	err := client.FilesCp(ctx, "/ipfs/"+manifestCid, manifestPath)
+	if err != nil && !strings.Contains(err.Error(), "already has entry") {
+		return nil, err
+	}
+	defer client.FilesRm(ctx, manifestPath, true)
+	r, err := client.FilesRead(ctx, manifestPath)
+	if err != nil {
+		return nil, err
+	}
+	defer r.Close()
+	b, err := io.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+	var manifest fortaAgentManifest
+	if err := json.Unmarshal(b, &manifest); err != nil {
+		return nil, fmt.Errorf("invalid manifest: %v", err)
+	}
+	return &manifest, nil
+}
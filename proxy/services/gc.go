@@ -0,0 +1,256 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"path"
+	"time"
+
+	storagedriver "github.com/distribution/distribution/v3/registry/storage/driver"
+	log "github.com/sirupsen/logrus"
+)
+
+// DefaultGCOlderThan is the default minimum age of the unreferenced blobs to delete.
+const DefaultGCOlderThan = time.Hour
+
+// gcDelay is how long the garbage collection waits after it is scheduled, so that
+// multiple deletions are collected at once.
+const gcDelay = time.Minute
+
+// GCOptions contains the garbage collection options.
+type GCOptions struct {
+	// DryRun only reports the blobs which would be deleted.
+	DryRun bool
+	// OlderThan is the minimum age of the unreferenced blobs to delete. IPFS does not
+	// record modification times so the age of a blob in IPFS is known only if the blob
+	// is also in the cache. Otherwise the blob is deleted only if this is zero.
+	OlderThan time.Duration
+}
+
+// GCResult contains the results of a garbage collection.
+type GCResult struct {
+	// Repositories is the number of repositories which the blobs were marked from.
+	Repositories int
+	// Blobs are the unreferenced blobs which were deleted (or would be deleted in a dry run).
+	Blobs []*GCBlob
+}
+
+// GCBlob is an unreferenced blob in a store.
+type GCBlob struct {
+	Digest string
+	Store  string
+	Size   int64
+}
+
+// GarbageCollect deletes the blobs which are not referenced by any repository from the IPFS
+// nodes and the cache. The references of the global repositories are taken from the CID index,
+// if it has them. The blobs of the pushes in progress are not referenced by a manifest yet so
+// OlderThan should be long enough to protect them.
+func (disco *Disco) GarbageCollect(ctx context.Context, opts GCOptions) (*GCResult, error) {
+	repos, err := disco.listRepos(ctx)
+	if err != nil {
+		return nil, err
+	}
+	marked := make(map[string]bool)
+	for _, repo := range repos {
+		digests, ok := disco.indexedBlobDigests(repo)
+		if !ok {
+			digests, err = disco.referencedBlobs(ctx, repo)
+			if err != nil {
+				return nil, fmt.Errorf("failed to mark the blobs of %s: %v", repo, err)
+			}
+		}
+		for _, digest := range digests {
+			marked[digest] = true
+		}
+	}
+	log.WithFields(log.Fields{
+		"repositories": len(repos),
+		"blobs":        len(marked),
+	}).Info("marked referenced blobs")
+
+	result := &GCResult{Repositories: len(repos)}
+	cutoff := time.Now().Add(-opts.OlderThan)
+
+	// sweep the cache first so the modification times there can be used for IPFS
+	cacheModTimes := make(map[string]time.Time)
+	if cacheDriver := disco.cacheDriver(); cacheDriver != nil {
+		blobs, err := unreferencedDriverBlobs(ctx, cacheDriver, marked)
+		if err != nil {
+			return nil, fmt.Errorf("failed to find unreferenced blobs in cache: %v", err)
+		}
+		var digests []string
+		for digest := range blobs {
+			digests = append(digests, digest)
+		}
+		if err := disco.markIndexedRefs(marked, digests); err != nil {
+			return nil, err
+		}
+		for digest, stat := range blobs {
+			if marked[digest] {
+				continue
+			}
+			cacheModTimes[digest] = stat.ModTime()
+			if stat.ModTime().After(cutoff) {
+				continue
+			}
+			if !opts.DryRun {
+				if err := cacheDriver.Delete(ctx, makeBlobDirPath(digest)); err != nil {
+					return result, fmt.Errorf("failed to delete blob %s from cache: %v", digest, err)
+				}
+			}
+			result.Blobs = append(result.Blobs, &GCBlob{Digest: digest, Store: "cache", Size: stat.Size()})
+		}
+	}
+
+	if disco.cfg.CacheOnly {
+		return result, nil
+	}
+	for i, nodeClient := range disco.getIpfsClient().NodeClients() {
+		store := fmt.Sprintf("ipfs node #%d", i)
+		prefixes, err := nodeClient.FilesLs(ctx, blobsBase)
+		if err != nil && isNotExistErr(err) {
+			continue
+		}
+		if err != nil {
+			return result, fmt.Errorf("failed to list blobs in %s: %v", store, err)
+		}
+		for _, prefix := range prefixes {
+			entries, err := nodeClient.FilesLs(ctx, blobsBase+"/"+prefix.Name)
+			if err != nil {
+				return result, fmt.Errorf("failed to list blobs in %s: %v", store, err)
+			}
+			var digests []string
+			for _, entry := range entries {
+				if !marked[entry.Name] {
+					digests = append(digests, entry.Name)
+				}
+			}
+			if err := disco.markIndexedRefs(marked, digests); err != nil {
+				return result, err
+			}
+			for _, entry := range entries {
+				digest := entry.Name
+				if marked[digest] {
+					continue
+				}
+				modTime, ok := cacheModTimes[digest]
+				if opts.OlderThan > 0 && (!ok || modTime.After(cutoff)) {
+					continue
+				}
+				stat, err := nodeClient.FilesStat(ctx, makeBlobPath(digest))
+				if err != nil && isNotExistErr(err) {
+					continue
+				}
+				if err != nil {
+					return result, fmt.Errorf("failed to stat blob %s in %s: %v", digest, store, err)
+				}
+				if !opts.DryRun {
+					if err := nodeClient.FilesRm(ctx, makeBlobDirPath(digest), true); err != nil {
+						return result, fmt.Errorf("failed to delete blob %s from %s: %v", digest, store, err)
+					}
+				}
+				result.Blobs = append(result.Blobs, &GCBlob{Digest: digest, Store: store, Size: int64(stat.Size)})
+			}
+		}
+	}
+	return result, nil
+}
+
+// markIndexedRefs marks the blobs which a manifest in the CID index references. The blobs of the
+// manifests which are being made global are in the index before the stores.
+func (disco *Disco) markIndexedRefs(marked map[string]bool, digests []string) error {
+	refCounts, err := disco.cids.countRefs(digests, "")
+	if err != nil {
+		return fmt.Errorf("failed to count the blob references in the cid index: %v", err)
+	}
+	for digest, count := range refCounts {
+		if count > 0 {
+			marked[digest] = true
+		}
+	}
+	return nil
+}
+
+// referencedBlobs returns the digests of the manifest, the config and the layers of the repository,
+// and of the manifests which it lists.
+func (disco *Disco) referencedBlobs(ctx context.Context, repoName string) ([]string, error) {
+	driver := disco.getDriver()
+	b, err := driver.GetContent(ctx, disco.makeManifestLinkPath(repoName))
+	if _, ok := err.(storagedriver.PathNotFoundError); ok {
+		// the push is not complete yet
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read manifest link: %v", err)
+	}
+	manifestDigest := string(b)[7:]
+	manifest, err := disco.readManifestUsingDriver(ctx, driver, manifestDigest)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read manifest: %v", err)
+	}
+	children, err := readChildManifests(ctx, disco.driverManifestReader(driver), manifest)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read the listed manifests: %v", err)
+	}
+	var digests []string
+	for _, blob := range imageBlobs(manifestDigest, manifest, children) {
+		digests = append(digests, blob.Digest)
+	}
+	return digests, nil
+}
+
+// unreferencedDriverBlobs finds the blobs which are not marked in the store of the driver.
+func unreferencedDriverBlobs(ctx context.Context, driver storagedriver.StorageDriver, marked map[string]bool) (map[string]storagedriver.FileInfo, error) {
+	blobs := make(map[string]storagedriver.FileInfo)
+	prefixes, err := driver.List(ctx, blobsBase)
+	if _, ok := err.(storagedriver.PathNotFoundError); ok {
+		return blobs, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	for _, prefix := range prefixes {
+		blobDirs, err := driver.List(ctx, prefix)
+		if err != nil {
+			return nil, err
+		}
+		for _, blobDir := range blobDirs {
+			digest := path.Base(blobDir)
+			if marked[digest] {
+				continue
+			}
+			stat, err := driver.Stat(ctx, makeBlobPath(digest))
+			if _, ok := err.(storagedriver.PathNotFoundError); ok {
+				continue
+			}
+			if err != nil {
+				return nil, err
+			}
+			blobs[digest] = stat
+		}
+	}
+	return blobs, nil
+}
+
+// scheduleGC runs the garbage collection in the background after a delay, unless it is
+// already scheduled.
+func (disco *Disco) scheduleGC() {
+	disco.gcMu.Lock()
+	defer disco.gcMu.Unlock()
+	if disco.gcScheduled {
+		return
+	}
+	disco.gcScheduled = true
+	time.AfterFunc(gcDelay, func() {
+		disco.gcMu.Lock()
+		disco.gcScheduled = false
+		disco.gcMu.Unlock()
+		result, err := disco.GarbageCollect(context.Background(), GCOptions{OlderThan: DefaultGCOlderThan})
+		if err != nil {
+			log.WithError(err).Error("scheduled garbage collection failed")
+			return
+		}
+		log.WithField("blobs", len(result.Blobs)).Info("scheduled garbage collection done")
+	})
+}
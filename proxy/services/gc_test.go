@@ -0,0 +1,50 @@
+package services
+
+import (
+	"bytes"
+	"io"
+
+	storagedriver "github.com/distribution/distribution/v3/registry/storage/driver"
+	"github.com/distribution/distribution/v3/registry/storage/driver/inmemory"
+	"github.com/forta-network/disco/interfaces"
+	"github.com/golang/mock/gomock"
+	ipfsapi "github.com/ipfs/go-ipfs-api"
+)
+
+func (s *Suite) TestGarbageCollect() {
+	// Given that a repo references the layer blob
+	secondary := inmemory.New()
+	s.r.NoError(secondary.PutContent(s.ctx, s.disco.makeManifestLinkPath(testCidv1), []byte("sha256:"+testManifestDigest)))
+	s.driver.EXPECT().Secondary().Return(secondary).AnyTimes()
+	s.ipfsClient.EXPECT().NodeClients().Return([]interfaces.IPFSFilesAPI{s.ipfsNode}).AnyTimes()
+	s.ipfsNode.EXPECT().FilesLs(gomock.Any(), repositoriesBase).Return([]*ipfsapi.MfsLsEntry{{Name: testCidv1}}, nil)
+	s.driver.EXPECT().GetContent(gomock.Any(), s.disco.makeManifestLinkPath(testCidv1)).Return([]byte("sha256:"+testManifestDigest), nil)
+	s.driver.EXPECT().Reader(gomock.Any(), makeBlobPath(testManifestDigest), int64(0)).
+		Return(io.NopCloser(bytes.NewBufferString(testManifest)), nil)
+
+	// And that another blob is not referenced by any repo
+	s.r.NoError(secondary.PutContent(s.ctx, makeBlobPath(testLayerDigest), []byte("layer")))
+	s.r.NoError(secondary.PutContent(s.ctx, makeBlobPath(testBlobDigest), []byte(testBlobContent)))
+	prefix := blobsBase + "/" + testBlobDigest[:2]
+	s.ipfsNode.EXPECT().FilesLs(gomock.Any(), blobsBase).Return([]*ipfsapi.MfsLsEntry{{Name: testBlobDigest[:2]}}, nil)
+	s.ipfsNode.EXPECT().FilesLs(gomock.Any(), prefix).
+		Return([]*ipfsapi.MfsLsEntry{{Name: testBlobDigest}, {Name: testLayerDigest}}, nil)
+	s.ipfsNode.EXPECT().FilesStat(gomock.Any(), makeBlobPath(testBlobDigest)).
+		Return(&ipfsapi.FilesStatObject{Size: uint64(len(testBlobContent))}, nil)
+
+	// When the garbage is collected
+	// Then only the unreferenced blob should be deleted from IPFS and the cache
+	s.ipfsNode.EXPECT().FilesRm(gomock.Any(), makeBlobDirPath(testBlobDigest), true)
+
+	result, err := s.disco.GarbageCollect(s.ctx, GCOptions{})
+	s.r.NoError(err)
+	s.r.Equal(1, result.Repositories)
+	s.r.Len(result.Blobs, 2)
+	for _, blob := range result.Blobs {
+		s.r.Equal(testBlobDigest, blob.Digest)
+	}
+	_, err = secondary.Stat(s.ctx, makeBlobPath(testBlobDigest))
+	s.r.IsType(storagedriver.PathNotFoundError{}, err)
+	_, err = secondary.Stat(s.ctx, makeBlobPath(testLayerDigest))
+	s.r.NoError(err)
+}
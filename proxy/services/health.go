@@ -0,0 +1,68 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	storagedriver "github.com/distribution/distribution/v3/registry/storage/driver"
+)
+
+// dependencyCheckTimeout is the time limit of checking each of the dependencies.
+const dependencyCheckTimeout = time.Second * 5
+
+// DependencyStatus is the status of a dependency in the readiness checks.
+type DependencyStatus struct {
+	Name       string `json:"name"`
+	OK         bool   `json:"ok"`
+	Error      string `json:"error,omitempty"`
+	DurationMs int64  `json:"durationMs"`
+}
+
+// CheckDependencies checks concurrently that the IPFS nodes of the router and the cache can be
+// reached. The IPFS nodes are not checked in cache-only mode.
+func (disco *Disco) CheckDependencies(ctx context.Context) []*DependencyStatus {
+	var checks []func(ctx context.Context) error
+	var statuses []*DependencyStatus
+	if !disco.cfg.CacheOnly {
+		for i, nodeClient := range disco.getIpfsClient().NodeClients() {
+			nodeClient := nodeClient
+			statuses = append(statuses, &DependencyStatus{Name: fmt.Sprintf("ipfs node #%d", i)})
+			checks = append(checks, func(ctx context.Context) error {
+				_, err := nodeClient.FilesStat(ctx, "/")
+				return err
+			})
+		}
+	}
+	if cacheDriver := disco.cacheDriver(); cacheDriver != nil {
+		statuses = append(statuses, &DependencyStatus{Name: storeCache})
+		checks = append(checks, func(ctx context.Context) error {
+			// the registry root does not exist until the first push
+			_, err := cacheDriver.Stat(ctx, registryBase)
+			if _, ok := err.(storagedriver.PathNotFoundError); ok {
+				return nil
+			}
+			return err
+		})
+	}
+
+	var wg sync.WaitGroup
+	for i := range checks {
+		wg.Add(1)
+		go func(status *DependencyStatus, check func(ctx context.Context) error) {
+			defer wg.Done()
+			checkCtx, cancel := context.WithTimeout(ctx, dependencyCheckTimeout)
+			defer cancel()
+			start := time.Now()
+			err := check(checkCtx)
+			status.DurationMs = time.Since(start).Milliseconds()
+			status.OK = err == nil
+			if err != nil {
+				status.Error = err.Error()
+			}
+		}(statuses[i], checks[i])
+	}
+	wg.Wait()
+	return statuses
+}
@@ -0,0 +1,33 @@
+package services
+
+import (
+	"errors"
+
+	"github.com/distribution/distribution/v3/registry/storage/driver/inmemory"
+	"github.com/forta-network/disco/interfaces"
+	mock_interfaces "github.com/forta-network/disco/interfaces/mocks"
+	"github.com/golang/mock/gomock"
+	ipfsapi "github.com/ipfs/go-ipfs-api"
+)
+
+func (s *Suite) TestCheckDependencies() {
+	// Given an ipfs node which is up, an ipfs node which is down and an empty cache
+	downNode := mock_interfaces.NewMockIPFSFilesAPI(gomock.NewController(s.T()))
+	s.ipfsClient.EXPECT().NodeClients().Return([]interfaces.IPFSFilesAPI{s.ipfsNode, downNode})
+	s.ipfsNode.EXPECT().FilesStat(gomock.Any(), "/").Return(&ipfsapi.FilesStatObject{}, nil)
+	downNode.EXPECT().FilesStat(gomock.Any(), "/").Return(nil, errors.New("connection refused"))
+	s.driver.EXPECT().Secondary().Return(inmemory.New())
+
+	// When the dependencies are checked
+	statuses := s.disco.CheckDependencies(s.ctx)
+
+	// Then the node which is down should fail and the cache should be reachable
+	s.r.Len(statuses, 3)
+	s.r.Equal("ipfs node #0", statuses[0].Name)
+	s.r.True(statuses[0].OK)
+	s.r.Equal("ipfs node #1", statuses[1].Name)
+	s.r.False(statuses[1].OK)
+	s.r.Equal("connection refused", statuses[1].Error)
+	s.r.Equal(storeCache, statuses[2].Name)
+	s.r.True(statuses[2].OK)
+}
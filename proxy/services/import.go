@@ -0,0 +1,281 @@
+package services
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"path"
+	"time"
+
+	storagedriver "github.com/distribution/distribution/v3/registry/storage/driver"
+	"github.com/forta-network/disco/utils"
+)
+
+const (
+	ociRefNameAnnotation = "org.opencontainers.image.ref.name"
+	importRepoNamePrefix = "disco-import-"
+)
+
+type descriptor struct {
+	MediaType   string            `json:"mediaType"`
+	Digest      string            `json:"digest"`
+	Size        int64             `json:"size"`
+	Annotations map[string]string `json:"annotations,omitempty"`
+}
+
+// Import writes the image in the OCI image layout or the docker save layout to the storage,
+// makes it global and returns the CID v1 of the repository. If the layout has multiple
+// images, ref selects the one with the matching ref name annotation.
+func (disco *Disco) Import(ctx context.Context, layout fs.FS, ref string) (string, error) {
+	repoName := fmt.Sprintf("%s%d", importRepoNamePrefix, time.Now().UnixNano())
+	driver := disco.getDriver()
+
+	var (
+		manifestDigest string
+		blobDigests    []string
+		err            error
+	)
+	if _, statErr := fs.Stat(layout, "index.json"); statErr == nil {
+		manifestDigest, blobDigests, err = disco.importOCILayout(ctx, driver, layout, ref)
+	} else {
+		manifestDigest, blobDigests, err = disco.importDockerSave(ctx, driver, layout)
+	}
+	if err != nil {
+		return "", err
+	}
+
+	// link the blobs and the manifest to the repository as the registry does
+	repoPath := makeRepoPath(repoName)
+	link := []byte("sha256:" + manifestDigest)
+	for _, digest := range blobDigests {
+		if err := driver.PutContent(ctx, fmt.Sprintf("%s/_layers/sha256/%s/link", repoPath, digest), []byte("sha256:"+digest)); err != nil {
+			return "", fmt.Errorf("failed to link blob %s: %v", digest, err)
+		}
+	}
+	for _, linkPath := range []string{
+		fmt.Sprintf("%s/_manifests/revisions/sha256/%s/link", repoPath, manifestDigest),
+		fmt.Sprintf("%s/_manifests/tags/%s/index/sha256/%s/link", repoPath, disco.CanonicalTag(), manifestDigest),
+		disco.makeManifestLinkPath(repoName),
+	} {
+		if err := driver.PutContent(ctx, linkPath, link); err != nil {
+			return "", fmt.Errorf("failed to link manifest: %v", err)
+		}
+	}
+
+	if err := disco.MakeGlobalRepo(ctx, repoName); err != nil {
+		return "", fmt.Errorf("failed to make global repo: %v", err)
+	}
+	return disco.ResolveRepoCid(ctx, manifestDigest)
+}
+
+// cidForDigest finds the CID v1 repository from the tags of the digest repository.
+func (disco *Disco) cidForDigest(ctx context.Context, manifestDigest string) (string, error) {
+	tagPaths, err := disco.getDriver().List(ctx, makeRepoPath(manifestDigest)+"/_manifests/tags")
+	if err != nil {
+		return "", fmt.Errorf("failed to list the tags of the digest repo: %v", err)
+	}
+	for _, tagPath := range tagPaths {
+		if tag := path.Base(tagPath); utils.IsCIDv1(tag) {
+			return tag, nil
+		}
+	}
+	return "", fmt.Errorf("digest repo %s has no cid tag", manifestDigest)
+}
+
+func (disco *Disco) importOCILayout(ctx context.Context, driver storagedriver.StorageDriver, layout fs.FS, ref string) (string, []string, error) {
+	var index struct {
+		Manifests []*descriptor `json:"manifests"`
+	}
+	if err := readLayoutJSON(layout, "index.json", &index); err != nil {
+		return "", nil, err
+	}
+	var manifestDesc *descriptor
+	for _, desc := range index.Manifests {
+		if len(ref) == 0 || desc.Annotations[ociRefNameAnnotation] == ref {
+			manifestDesc = desc
+			break
+		}
+	}
+	switch {
+	case manifestDesc == nil:
+		return "", nil, fmt.Errorf("no image with ref '%s' in index.json", ref)
+	case len(ref) == 0 && len(index.Manifests) > 1:
+		return "", nil, errors.New("index.json has multiple images - specify the ref")
+	case manifestDesc.MediaType == mediaTypeOCIIndex || manifestDesc.MediaType == mediaTypeDockerList:
+		return "", nil, errors.New("image indexes are not supported")
+	}
+
+	manifestDigest := manifestDesc.Digest[7:]
+	manifestBytes, err := fs.ReadFile(layout, ociBlobPath(manifestDigest))
+	if err != nil {
+		return "", nil, fmt.Errorf("failed to read manifest: %v", err)
+	}
+	var manifest struct {
+		Config *descriptor   `json:"config"`
+		Layers []*descriptor `json:"layers"`
+	}
+	if err := json.Unmarshal(manifestBytes, &manifest); err != nil {
+		return "", nil, fmt.Errorf("failed to decode manifest: %v", err)
+	}
+	if manifest.Config == nil {
+		return "", nil, errors.New("manifest has no config")
+	}
+
+	var blobDigests []string
+	for _, desc := range append([]*descriptor{manifest.Config}, manifest.Layers...) {
+		digest, _, err := importBlob(ctx, driver, layout, ociBlobPath(desc.Digest[7:]))
+		if err != nil {
+			return "", nil, err
+		}
+		if digest != desc.Digest[7:] {
+			return "", nil, fmt.Errorf("blob %s has digest %s", desc.Digest, digest)
+		}
+		blobDigests = append(blobDigests, digest)
+	}
+	if _, err := putBlob(ctx, driver, manifestBytes); err != nil {
+		return "", nil, err
+	}
+	return manifestDigest, blobDigests, nil
+}
+
+func (disco *Disco) importDockerSave(ctx context.Context, driver storagedriver.StorageDriver, layout fs.FS) (string, []string, error) {
+	var saved []struct {
+		Config string
+		Layers []string
+	}
+	if err := readLayoutJSON(layout, "manifest.json", &saved); err != nil {
+		return "", nil, err
+	}
+	if len(saved) != 1 {
+		return "", nil, fmt.Errorf("expected one image in manifest.json but found %d", len(saved))
+	}
+
+	// docker save has the uncompressed layers and no registry manifest so build an OCI manifest
+	manifest := struct {
+		SchemaVersion int           `json:"schemaVersion"`
+		MediaType     string        `json:"mediaType"`
+		Config        *descriptor   `json:"config"`
+		Layers        []*descriptor `json:"layers"`
+	}{
+		SchemaVersion: 2,
+		MediaType:     mediaTypeOCIManifest,
+	}
+	configDigest, configSize, err := importBlob(ctx, driver, layout, saved[0].Config)
+	if err != nil {
+		return "", nil, err
+	}
+	manifest.Config = &descriptor{MediaType: mediaTypeOCIConfig, Digest: "sha256:" + configDigest, Size: configSize}
+	blobDigests := []string{configDigest}
+	for _, layerPath := range saved[0].Layers {
+		layerDigest, layerSize, err := importBlob(ctx, driver, layout, layerPath)
+		if err != nil {
+			return "", nil, err
+		}
+		mediaType, err := layerMediaType(layout, layerPath)
+		if err != nil {
+			return "", nil, err
+		}
+		manifest.Layers = append(manifest.Layers, &descriptor{MediaType: mediaType, Digest: "sha256:" + layerDigest, Size: layerSize})
+		blobDigests = append(blobDigests, layerDigest)
+	}
+	manifestBytes, err := json.Marshal(&manifest)
+	if err != nil {
+		return "", nil, err
+	}
+	manifestDigest, err := putBlob(ctx, driver, manifestBytes)
+	if err != nil {
+		return "", nil, err
+	}
+	return manifestDigest, blobDigests, nil
+}
+
+func ociBlobPath(digest string) string {
+	return "blobs/sha256/" + digest
+}
+
+func readLayoutJSON(layout fs.FS, name string, v interface{}) error {
+	b, err := fs.ReadFile(layout, name)
+	if err != nil {
+		return fmt.Errorf("failed to read %s: %v", name, err)
+	}
+	if err := json.Unmarshal(b, v); err != nil {
+		return fmt.Errorf("failed to decode %s: %v", name, err)
+	}
+	return nil
+}
+
+// layerMediaType finds the OCI media type of the layer file from its magic number.
+func layerMediaType(layout fs.FS, name string) (string, error) {
+	f, err := layout.Open(name)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+	magic := make([]byte, 4)
+	if _, err := io.ReadFull(f, magic); err != nil {
+		return mediaTypeOCILayer, nil
+	}
+	switch {
+	case bytes.HasPrefix(magic, []byte{0x1f, 0x8b}):
+		return mediaTypeOCILayerGzip, nil
+	case bytes.Equal(magic, []byte{0x28, 0xb5, 0x2f, 0xfd}):
+		return mediaTypeOCILayerZstd, nil
+	}
+	return mediaTypeOCILayer, nil
+}
+
+// importBlob computes the digest of the file in the layout and writes it to the storage
+// as a blob, unless the blob exists.
+func importBlob(ctx context.Context, driver storagedriver.StorageDriver, layout fs.FS, name string) (string, int64, error) {
+	f, err := layout.Open(name)
+	if err != nil {
+		return "", 0, fmt.Errorf("failed to open %s: %v", name, err)
+	}
+	hash := sha256.New()
+	size, err := io.Copy(hash, f)
+	f.Close()
+	if err != nil {
+		return "", 0, fmt.Errorf("failed to read %s: %v", name, err)
+	}
+	digest := hex.EncodeToString(hash.Sum(nil))
+	if stat, err := driver.Stat(ctx, makeBlobPath(digest)); err == nil && stat.Size() == size {
+		return digest, size, nil
+	}
+
+	f, err = layout.Open(name)
+	if err != nil {
+		return "", 0, fmt.Errorf("failed to open %s: %v", name, err)
+	}
+	defer f.Close()
+	w, err := driver.Writer(ctx, makeBlobPath(digest), false)
+	if err != nil {
+		return "", 0, fmt.Errorf("failed to create blob %s: %v", digest, err)
+	}
+	if _, err := io.Copy(w, f); err != nil {
+		_ = w.Cancel()
+		return "", 0, fmt.Errorf("failed to write blob %s: %v", digest, err)
+	}
+	if err := w.Commit(); err != nil {
+		return "", 0, fmt.Errorf("failed to commit blob %s: %v", digest, err)
+	}
+	if err := w.Close(); err != nil {
+		return "", 0, fmt.Errorf("failed to close blob %s: %v", digest, err)
+	}
+	return digest, size, nil
+}
+
+// putBlob writes the content as a blob and returns the digest.
+func putBlob(ctx context.Context, driver storagedriver.StorageDriver, content []byte) (string, error) {
+	sum := sha256.Sum256(content)
+	digest := hex.EncodeToString(sum[:])
+	if err := driver.PutContent(ctx, makeBlobPath(digest), content); err != nil {
+		return "", fmt.Errorf("failed to write blob %s: %v", digest, err)
+	}
+	return digest, nil
+}
@@ -0,0 +1,59 @@
+package services
+
+import (
+	"testing/fstest"
+
+	storagedriver "github.com/distribution/distribution/v3/registry/storage/driver"
+	"github.com/distribution/distribution/v3/registry/storage/driver/inmemory"
+	"github.com/forta-network/disco/config"
+	"github.com/forta-network/disco/utils"
+)
+
+func (s *Suite) TestImport_DockerSave() {
+	// Given a docker save layout and a cache-only storage
+	driver := inmemory.New()
+	s.disco.cfg = &config.Config{CacheOnly: true}
+	s.disco.getDriver = func() storagedriver.StorageDriver {
+		return driver
+	}
+	layout := fstest.MapFS{
+		"manifest.json":   {Data: []byte(`[{"Config":"config.json","RepoTags":["test:latest"],"Layers":["layer/layer.tar"]}]`)},
+		"config.json":     {Data: []byte(`{"architecture":"amd64"}`)},
+		"layer/layer.tar": {Data: []byte(testBlobContent)},
+	}
+
+	// When the layout is imported
+	repoCid, err := s.disco.Import(s.ctx, layout, "")
+	s.r.NoError(err)
+
+	// Then the repo should be made global with the blobs
+	b, err := driver.GetContent(s.ctx, s.disco.makeManifestLinkPath(repoCid))
+	s.r.NoError(err)
+	manifestDigest := string(b)[7:]
+	expectedCid, err := utils.ConvertSHA256HexToCIDv1(manifestDigest)
+	s.r.NoError(err)
+	s.r.Equal(expectedCid, repoCid)
+	content, err := driver.GetContent(s.ctx, makeBlobPath(testBlobDigest))
+	s.r.NoError(err)
+	s.r.Equal(testBlobContent, string(content))
+	repos, err := driver.List(s.ctx, repositoriesBase)
+	s.r.NoError(err)
+	s.r.Len(repos, 2)
+}
+
+func (s *Suite) TestLayerMediaType() {
+	layout := fstest.MapFS{
+		"gzip": {Data: []byte{0x1f, 0x8b, 0x08, 0x00}},
+		"zstd": {Data: []byte{0x28, 0xb5, 0x2f, 0xfd, 0x00}},
+		"tar":  {Data: []byte(testBlobContent)},
+	}
+	for name, expected := range map[string]string{
+		"gzip": mediaTypeOCILayerGzip,
+		"zstd": mediaTypeOCILayerZstd,
+		"tar":  mediaTypeOCILayer,
+	} {
+		mediaType, err := layerMediaType(layout, name)
+		s.r.NoError(err)
+		s.r.Equal(expected, mediaType, name)
+	}
+}
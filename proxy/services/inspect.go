@@ -0,0 +1,160 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+
+	storagedriver "github.com/distribution/distribution/v3/registry/storage/driver"
+	"github.com/forta-network/disco/utils"
+)
+
+// InspectResult describes a repository and the stores which hold its content.
+type InspectResult struct {
+	Repository     string          `json:"repository"`
+	ManifestDigest string          `json:"manifestDigest,omitempty"`
+	Cid            string          `json:"cid,omitempty"`
+	Stores         []string        `json:"stores"`
+	DiscoFile      json.RawMessage `json:"discoFile,omitempty"`
+	Blobs          []*InspectBlob  `json:"blobs"`
+}
+
+// InspectBlob describes a blob of a repository and the stores which hold it.
+type InspectBlob struct {
+	Digest string `json:"digest"`
+	Cid    string `json:"cid,omitempty"`
+	// MediaType is the media type of a layer.
+	MediaType string   `json:"mediaType,omitempty"`
+	Size      int64    `json:"size"`
+	Stores    []string `json:"stores"`
+}
+
+// Inspect finds the manifest digest, the disco.json and the blobs of the repository and
+// the stores which currently hold them. The repository can also be specified with a CID v0.
+// Nothing is copied between the stores while inspecting.
+func (disco *Disco) Inspect(ctx context.Context, repoName string) (*InspectResult, error) {
+	repoName = strings.TrimPrefix(repoName, "sha256:")
+	if strings.HasPrefix(repoName, "Qm") {
+		cidV1, err := utils.ToCIDv1(repoName)
+		if err != nil {
+			return nil, fmt.Errorf("invalid cid v0 '%s': %v", repoName, err)
+		}
+		repoName = cidV1
+	}
+	result := &InspectResult{Repository: repoName}
+	result.Stores, result.Cid, _ = disco.locate(ctx, makeRepoPath(repoName))
+	if len(result.Stores) == 0 {
+		return nil, fmt.Errorf("%w in any store: %s", ErrRepoNotFound, repoName)
+	}
+
+	b, err := disco.readFromStores(ctx, disco.makeManifestLinkPath(repoName))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read manifest link: %v", err)
+	}
+	result.ManifestDigest = strings.TrimPrefix(strings.TrimSpace(string(b)), "sha256:")
+
+	var blobs []*blobCid
+	if b, err := disco.readFromStores(ctx, makeDiscoFilePath(repoName)); err == nil {
+		result.DiscoFile = b
+		file, err := decodeDiscoFile(b)
+		if err != nil {
+			return nil, err
+		}
+		blobs = file.Blobs
+	} else {
+		// not globalized yet: find the blobs from the manifest
+		b, err := disco.readFromStores(ctx, makeBlobPath(result.ManifestDigest))
+		if err != nil {
+			return nil, fmt.Errorf("failed to read manifest: %v", err)
+		}
+		var manifest imageManifest
+		if err := json.Unmarshal(b, &manifest); err != nil {
+			return nil, fmt.Errorf("failed to decode manifest: %v", err)
+		}
+		children, err := readChildManifests(ctx, disco.storesManifestReader(), &manifest)
+		if err != nil {
+			return nil, err
+		}
+		blobs = imageBlobs(result.ManifestDigest, &manifest, children)
+	}
+
+	for _, blob := range blobs {
+		stores, cid, size := disco.locate(ctx, makeBlobPath(blob.Digest))
+		if len(blob.Cid) > 0 {
+			cid = blob.Cid
+		}
+		if len(cid) == 0 {
+			cid, _ = disco.cids.blobCid(blob.Digest)
+		}
+		result.Blobs = append(result.Blobs, &InspectBlob{
+			Digest:    blob.Digest,
+			Cid:       cid,
+			MediaType: blob.MediaType,
+			Size:      size,
+			Stores:    stores,
+		})
+	}
+	return result, nil
+}
+
+// locate finds the stores which hold the content at the path and returns the IPFS CID and
+// the size of the content.
+func (disco *Disco) locate(ctx context.Context, contentPath string) (stores []string, cid string, size int64) {
+	if !disco.cfg.CacheOnly {
+		for i, nodeClient := range disco.getIpfsClient().NodeClients() {
+			stat, err := nodeClient.FilesStat(ctx, contentPath)
+			if err != nil {
+				continue
+			}
+			stores = append(stores, fmt.Sprintf("ipfs node #%d", i))
+			cid = stat.Hash
+			if stat.Type != "directory" {
+				size = int64(stat.Size)
+			}
+		}
+	}
+	if cacheDriver := disco.cacheDriver(); cacheDriver != nil {
+		stat, err := cacheDriver.Stat(ctx, contentPath)
+		if err == nil {
+			stores = append(stores, "cache")
+			if !stat.IsDir() {
+				size = stat.Size()
+			}
+		}
+	}
+	return
+}
+
+// readFromStores reads the content from the IPFS nodes or the cache, whichever has it first.
+func (disco *Disco) readFromStores(ctx context.Context, contentPath string) ([]byte, error) {
+	var lastErr error
+	if !disco.cfg.CacheOnly {
+		for _, nodeClient := range disco.getIpfsClient().NodeClients() {
+			r, err := nodeClient.FilesRead(ctx, contentPath)
+			if err != nil {
+				lastErr = err
+				continue
+			}
+			b, err := io.ReadAll(r)
+			r.Close()
+			if err != nil {
+				lastErr = err
+				continue
+			}
+			return b, nil
+		}
+	}
+	if cacheDriver := disco.cacheDriver(); cacheDriver != nil {
+		b, err := cacheDriver.GetContent(ctx, contentPath)
+		if err == nil {
+			return b, nil
+		}
+		lastErr = err
+	}
+	if lastErr == nil {
+		lastErr = storagedriver.PathNotFoundError{Path: contentPath}
+	}
+	return nil, lastErr
+}
@@ -0,0 +1,41 @@
+package services
+
+import (
+	"bytes"
+	"io"
+
+	"github.com/distribution/distribution/v3/registry/storage/driver/inmemory"
+	"github.com/forta-network/disco/interfaces"
+	"github.com/golang/mock/gomock"
+	ipfsapi "github.com/ipfs/go-ipfs-api"
+)
+
+func (s *Suite) TestInspect() {
+	// Given that a global repo is in IPFS and only the manifest blob is in the cache
+	secondary := inmemory.New()
+	s.r.NoError(secondary.PutContent(s.ctx, makeBlobPath(testManifestDigest), []byte(testManifest)))
+	s.driver.EXPECT().Secondary().Return(secondary).AnyTimes()
+	s.ipfsClient.EXPECT().NodeClients().Return([]interfaces.IPFSFilesAPI{s.ipfsNode}).AnyTimes()
+	s.ipfsNode.EXPECT().FilesStat(gomock.Any(), makeRepoPath(testCidv1)).
+		Return(&ipfsapi.FilesStatObject{Hash: testCidv0, Type: "directory"}, nil)
+	s.ipfsNode.EXPECT().FilesRead(gomock.Any(), s.disco.makeManifestLinkPath(testCidv1)).
+		Return(io.NopCloser(bytes.NewBufferString("sha256:"+testManifestDigest)), nil)
+	s.ipfsNode.EXPECT().FilesRead(gomock.Any(), makeDiscoFilePath(testCidv1)).
+		Return(io.NopCloser(bytes.NewBufferString(testDiscoFile)), nil)
+	s.ipfsNode.EXPECT().FilesStat(gomock.Any(), gomock.Any()).
+		Return(&ipfsapi.FilesStatObject{Size: 10}, nil).Times(3)
+
+	// When the repo is inspected with its cid v0
+	result, err := s.disco.Inspect(s.ctx, testCidv0)
+
+	// Then the content and the stores should be reported
+	s.r.NoError(err)
+	s.r.Equal(testCidv1, result.Repository)
+	s.r.Equal(testManifestDigest, result.ManifestDigest)
+	s.r.Equal([]string{"ipfs node #0"}, result.Stores)
+	s.r.Len(result.Blobs, 3)
+	s.r.Equal(testManifestCid, result.Blobs[0].Cid)
+	s.r.Equal([]string{"ipfs node #0", "cache"}, result.Blobs[0].Stores)
+	s.r.Equal(int64(len(testManifest)), result.Blobs[0].Size)
+	s.r.Equal([]string{"ipfs node #0"}, result.Blobs[2].Stores)
+}
@@ -0,0 +1,204 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"strings"
+
+	storagedriver "github.com/distribution/distribution/v3/registry/storage/driver"
+	"github.com/forta-network/disco/utils"
+	ipfsapi "github.com/ipfs/go-ipfs-api"
+	log "github.com/sirupsen/logrus"
+)
+
+// isLazyBlob tells if the blob is copied on its first request when the repositories are cloned
+// lazily. Only the layers have media types in the disco files, so the manifests and the configs,
+// and all of the blobs of the disco files without the media types, are cloned with the
+// repository.
+func isLazyBlob(blob *blobCid) bool {
+	return len(blob.MediaType) > 0
+}
+
+// cloneBlobs returns the blobs which are cloned with the repository.
+func (disco *Disco) cloneBlobs(blobs []*blobCid) []*blobCid {
+	if !disco.cfg.LazyClone {
+		return blobs
+	}
+	var eager []*blobCid
+	for _, blob := range blobs {
+		if !isLazyBlob(blob) {
+			eager = append(eager, blob)
+		}
+	}
+	return eager
+}
+
+// CloneBlob copies the layer of the CID v1 repository from the IPFS network on its first request,
+// when the repositories are cloned lazily. The digest can have the sha256: prefix.
+func (disco *Disco) CloneBlob(ctx context.Context, repoName, digest string) error {
+	if !disco.cfg.LazyClone || disco.cfg.CacheOnly || disco.cfg.NoClone || !utils.IsCIDv1(repoName) {
+		return nil
+	}
+	digest = strings.TrimPrefix(digest, "sha256:")
+	if !utils.IsDigestHex(digest) {
+		return nil
+	}
+	return disco.blobsInFlight.Do(ctx, digest, func() error {
+		return disco.cloneBlob(ctx, repoName, digest)
+	})
+}
+
+func (disco *Disco) cloneBlob(ctx context.Context, repoName, digest string) (err error) {
+	reqCtx := ctx
+	ctx, cancel := disco.operationContext(reqCtx)
+	defer cancel()
+
+	blob, err := disco.findLazyBlob(ctx, repoName, digest)
+	// the registry responds to the blobs which exist and to the blobs of the other repositories
+	if err != nil || blob == nil {
+		return err
+	}
+
+	release, err := disco.clones.AcquireWithPriority(reqCtx, disco.clonePriority(ctx, repoName), cancel)
+	if err != nil {
+		return fmt.Errorf("failed to start cloning: %w", err)
+	}
+	defer release()
+	ctx, timer := disco.startOperation(ctx, operationCloneBlob, repoName)
+	defer func() {
+		timer.done(err)
+	}()
+	return disco.copyBlob(ctx, timer, blob)
+}
+
+// copyBlob copies the blob from the IPFS network to the storage with its CID.
+func (disco *Disco) copyBlob(ctx context.Context, timer *operationTimer, blob *blobCid) error {
+	timer.op.addBlob(blob, false)
+
+	blobPath := makeBlobPath(blob.Digest)
+	client, err := disco.getIpfsClient().GetClientFor(ctx, blobPath)
+	if err != nil {
+		return fmt.Errorf("failed to get blob node client: %v", err)
+	}
+	_ = client.FilesMkdir(ctx, makeBlobDirPath(blob.Digest), ipfsapi.FilesMkdir.Parents(true))
+	if err := client.FilesCp(ctx, fmt.Sprintf("/ipfs/%s", blob.Cid), blobPath); err != nil && !strings.Contains(err.Error(), "already has entry") {
+		timer.op.blobFailed(blob.Digest, err)
+		return fmt.Errorf("%w: failed while copying blob %s (%s) from the network: %v", ErrCloneFailed, blob.Digest, blob.Cid, err)
+	}
+	timer.op.blobCopied(blob.Digest, blob.Size)
+	timer.step("blob_copy")
+	return disco.replicateInSecondary(ctx, disco.getDriver(), []string{blobPath})
+}
+
+// findLazyBlob returns the blob of the repository from its disco file if the blob is not in the
+// storage yet. It returns nil if the blob exists or if it belongs to another repository.
+func (disco *Disco) findLazyBlob(ctx context.Context, repoName, digest string) (*blobCid, error) {
+	_, err := disco.getDriver().Stat(ctx, makeBlobPath(digest))
+	switch err.(type) {
+	case nil:
+		return nil, nil
+	case storagedriver.PathNotFoundError:
+	default:
+		return nil, fmt.Errorf("failed to check the blob: %v", err)
+	}
+	b, err := disco.readFromStores(ctx, makeDiscoFilePath(repoName))
+	if err != nil {
+		return nil, fmt.Errorf("%w: failed to read the disco file: %v", ErrCloneFailed, err)
+	}
+	file, err := decodeDiscoFile(b)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrCloneFailed, err)
+	}
+	for _, blob := range file.Blobs {
+		if blob.Digest == digest {
+			return blob, nil
+		}
+	}
+	return nil, nil
+}
+
+// StreamBlob returns the layer of the CID v1 repository which should be streamed from the IPFS
+// network, since it is not copied yet, and starts copying it in the background. It returns nil if
+// the registry should respond, e.g. when the layer exists or its size is not known. The digest
+// can have the sha256: prefix.
+func (disco *Disco) StreamBlob(ctx context.Context, repoName, digest string) (*ResolvedBlob, error) {
+	if !disco.cfg.StreamBlobs || !disco.cfg.LazyClone || disco.cfg.CacheOnly || disco.cfg.NoClone || !utils.IsCIDv1(repoName) {
+		return nil, nil
+	}
+	digest = strings.TrimPrefix(digest, "sha256:")
+	if !utils.IsDigestHex(digest) {
+		return nil, nil
+	}
+	blob, err := disco.findLazyBlob(ctx, repoName, digest)
+	// the disco files before v2 do not have the sizes
+	if err != nil || blob == nil || !isLazyBlob(blob) || blob.Size == 0 {
+		return nil, err
+	}
+	go disco.copyStreamedBlob(repoName, digest)
+	return &ResolvedBlob{
+		Digest:    "sha256:" + digest,
+		Cid:       blob.Cid,
+		MediaType: blob.MediaType,
+		Size:      blob.Size,
+	}, nil
+}
+
+// copyStreamedBlob copies the streamed layer, so that the next requests are served from the
+// storage.
+func (disco *Disco) copyStreamedBlob(repoName, digest string) {
+	if err := disco.CloneBlob(withBackgroundPriority(context.Background()), repoName, digest); err != nil {
+		log.WithFields(log.Fields{
+			"repository": repoName,
+			"digest":     digest,
+		}).WithError(err).Warn("failed to copy the streamed blob")
+	}
+}
+
+// ReadBlob reads the streamed layer from the IPFS network. It is read through the node which the
+// layer is copied to, so that the copy finds the content in the node.
+func (disco *Disco) ReadBlob(ctx context.Context, blob *ResolvedBlob) (io.ReadCloser, error) {
+	digest := strings.TrimPrefix(blob.Digest, "sha256:")
+	client, err := disco.getIpfsClient().GetClientFor(ctx, makeBlobPath(digest))
+	if err != nil {
+		return nil, fmt.Errorf("failed to get blob node client: %v", err)
+	}
+	r, err := client.Cat(ctx, fmt.Sprintf("/ipfs/%s", blob.Cid))
+	if err != nil {
+		return nil, fmt.Errorf("%w: failed while reading blob %s (%s) from the network: %v", ErrCloneFailed, digest, blob.Cid, err)
+	}
+	return r, nil
+}
+
+// cloneLazyBlobs copies the layers of the repository which were not requested yet, for the
+// operations which need all of the blobs.
+func (disco *Disco) cloneLazyBlobs(ctx context.Context, repoName string) error {
+	if !disco.cfg.LazyClone || disco.cfg.CacheOnly || disco.cfg.NoClone || !utils.IsCIDv1(repoName) {
+		return nil
+	}
+	b, err := disco.readFromStores(ctx, makeDiscoFilePath(repoName))
+	if err != nil {
+		return fmt.Errorf("%w: failed to read the disco file: %v", ErrCloneFailed, err)
+	}
+	file, err := decodeDiscoFile(b)
+	if err != nil {
+		return fmt.Errorf("%w: %v", ErrCloneFailed, err)
+	}
+	for _, blob := range file.Blobs {
+		if !isLazyBlob(blob) {
+			continue
+		}
+		if err := disco.CloneBlob(ctx, repoName, blob.Digest); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// cloneFullRepo clones the repository with all of its blobs.
+func (disco *Disco) cloneFullRepo(ctx context.Context, repoName string) error {
+	if err := disco.CloneGlobalRepo(ctx, repoName); err != nil {
+		return err
+	}
+	return disco.cloneLazyBlobs(ctx, repoName)
+}
@@ -0,0 +1,95 @@
+package services
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"strings"
+
+	storagedriver "github.com/distribution/distribution/v3/registry/storage/driver"
+	"github.com/forta-network/disco/config"
+	"github.com/forta-network/disco/interfaces"
+	"github.com/golang/mock/gomock"
+	ipfsapi "github.com/ipfs/go-ipfs-api"
+)
+
+func (s *Suite) TestCloneBlobs_Lazy() {
+	file, err := decodeDiscoFile([]byte(testDiscoFile))
+	s.r.NoError(err)
+
+	s.r.Len(s.disco.cloneBlobs(file.Blobs), 3)
+	s.disco.cfg = &config.Config{LazyClone: true}
+	blobs := s.disco.cloneBlobs(file.Blobs)
+	s.r.Len(blobs, 2)
+	s.r.Equal(testManifestDigest, blobs[0].Digest)
+	s.r.Equal(testConfigDigest, blobs[1].Digest)
+}
+
+func (s *Suite) TestCloneBlob() {
+	s.disco.cfg = &config.Config{LazyClone: true}
+	s.ipfsClient.EXPECT().NodeClients().Return([]interfaces.IPFSFilesAPI{s.ipfsNode}).AnyTimes()
+
+	// Given a layer which is not cloned yet
+	s.driver.EXPECT().Stat(gomock.Any(), makeBlobPath(testLayerDigest)).Return(nil, storagedriver.PathNotFoundError{})
+	s.ipfsNode.EXPECT().FilesRead(gomock.Any(), makeDiscoFilePath(testCidv1)).
+		Return(io.NopCloser(bytes.NewBufferString(testDiscoFile)), nil)
+
+	// When it is requested
+	// Then it should be copied from the network
+	s.ipfsNode.EXPECT().FilesMkdir(gomock.Any(), makeBlobDirPath(testLayerDigest), gomock.Any()).Return(nil)
+	s.ipfsNode.EXPECT().FilesCp(gomock.Any(), fmt.Sprintf("/ipfs/%s", testLayerCid), makeBlobPath(testLayerDigest)).Return(nil)
+	s.driver.EXPECT().ReplicateInSecondary(gomock.Any(), makeBlobPath(testLayerDigest)).Return(nil, nil)
+	s.r.NoError(s.disco.CloneBlob(s.ctx, testCidv1, "sha256:"+testLayerDigest))
+
+	// And the existing blobs and the other repositories should be left to the registry
+	s.driver.EXPECT().Stat(gomock.Any(), makeBlobPath(testConfigDigest)).Return(&fileInfo{size: 1457}, nil)
+	s.r.NoError(s.disco.CloneBlob(s.ctx, testCidv1, "sha256:"+testConfigDigest))
+	s.r.NoError(s.disco.CloneBlob(s.ctx, "myrepo", "sha256:"+testLayerDigest))
+}
+
+func (s *Suite) TestStreamBlob() {
+	s.disco.cfg = &config.Config{LazyClone: true, StreamBlobs: true}
+	s.ipfsClient.EXPECT().NodeClients().Return([]interfaces.IPFSFilesAPI{s.ipfsNode}).AnyTimes()
+
+	// Given a layer which is not cloned yet
+	s.driver.EXPECT().Stat(gomock.Any(), makeBlobPath(testLayerDigest)).Return(nil, storagedriver.PathNotFoundError{}).Times(2)
+	s.ipfsNode.EXPECT().FilesRead(gomock.Any(), makeDiscoFilePath(testCidv1)).
+		DoAndReturn(func(ctx context.Context, path string, options ...ipfsapi.FilesOpt) (io.ReadCloser, error) {
+			return io.NopCloser(bytes.NewBufferString(testDiscoFile)), nil
+		}).Times(2)
+
+	// When it is requested
+	// Then it should be streamed from the network
+	// And it should be copied in the background
+	copied := make(chan struct{})
+	s.ipfsNode.EXPECT().FilesMkdir(gomock.Any(), makeBlobDirPath(testLayerDigest), gomock.Any()).Return(nil)
+	s.ipfsNode.EXPECT().FilesCp(gomock.Any(), fmt.Sprintf("/ipfs/%s", testLayerCid), makeBlobPath(testLayerDigest)).Return(nil)
+	s.driver.EXPECT().ReplicateInSecondary(gomock.Any(), makeBlobPath(testLayerDigest)).
+		DoAndReturn(func(ctx context.Context, contentPath string) (storagedriver.FileInfo, error) {
+			close(copied)
+			return nil, nil
+		})
+	blob, err := s.disco.StreamBlob(s.ctx, testCidv1, "sha256:"+testLayerDigest)
+	s.r.NoError(err)
+	s.r.NotNil(blob)
+	s.r.Equal("sha256:"+testLayerDigest, blob.Digest)
+	s.r.Equal(int64(766607), blob.Size)
+
+	s.ipfsNode.EXPECT().Cat(gomock.Any(), fmt.Sprintf("/ipfs/%s", testLayerCid)).Return(io.NopCloser(strings.NewReader("layer")), nil)
+	r, err := s.disco.ReadBlob(s.ctx, blob)
+	s.r.NoError(err)
+	b, err := io.ReadAll(r)
+	s.r.NoError(err)
+	s.r.Equal("layer", string(b))
+	<-copied
+
+	// And the existing blobs and the other repositories should be left to the registry
+	s.driver.EXPECT().Stat(gomock.Any(), makeBlobPath(testConfigDigest)).Return(&fileInfo{size: 1457}, nil)
+	blob, err = s.disco.StreamBlob(s.ctx, testCidv1, "sha256:"+testConfigDigest)
+	s.r.NoError(err)
+	s.r.Nil(blob)
+	blob, err = s.disco.StreamBlob(s.ctx, "myrepo", "sha256:"+testLayerDigest)
+	s.r.NoError(err)
+	s.r.Nil(blob)
+}
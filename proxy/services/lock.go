@@ -0,0 +1,34 @@
+package services
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/forta-network/disco/config"
+	"github.com/forta-network/disco/utils"
+)
+
+// newLocker creates the locker of the configured lock provider, if any.
+func newLocker(lockCfg config.LockConfig) utils.Locker {
+	switch lockCfg.Provider {
+	case config.ProviderRedis:
+		return utils.NewRedisLocker(utils.NewRedisClient(lockCfg.Addr, lockCfg.Password, lockCfg.DB), lockCfg.TTL, lockCfg.Prefix)
+	default:
+		return nil
+	}
+}
+
+// lockRepos locks the global repositories of the manifest across the Disco replicas, so that
+// they are not made global and deleted at the same time. The repositories should be changed
+// under the returned context, which is cancelled if the lock is lost. It does nothing if there
+// is no lock provider.
+func (disco *Disco) lockRepos(ctx context.Context, manifestDigest string) (lockCtx context.Context, unlock func(), err error) {
+	if disco.locker == nil {
+		return ctx, func() {}, nil
+	}
+	lockCtx, unlock, err = disco.locker.Lock(ctx, manifestDigest)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to lock the repositories of %s: %w", manifestDigest, err)
+	}
+	return lockCtx, unlock, nil
+}
@@ -0,0 +1,284 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	storagedriver "github.com/distribution/distribution/v3/registry/storage/driver"
+	"github.com/forta-network/disco/utils"
+)
+
+// Manifest media types which Disco can make global.
+const (
+	mediaTypeDockerManifest = "application/vnd.docker.distribution.manifest.v2+json"
+	mediaTypeOCIManifest    = "application/vnd.oci.image.manifest.v1+json"
+)
+
+// Image media types.
+const (
+	mediaTypeOCIConfig  = "application/vnd.oci.image.config.v1+json"
+	mediaTypeOCIIndex   = "application/vnd.oci.image.index.v1+json"
+	mediaTypeDockerList = "application/vnd.docker.distribution.manifest.list.v2+json"
+)
+
+// Layer media types. The layers of the newer builders can be compressed with zstd.
+const (
+	mediaTypeOCILayer       = "application/vnd.oci.image.layer.v1.tar"
+	mediaTypeOCILayerGzip   = "application/vnd.oci.image.layer.v1.tar+gzip"
+	mediaTypeOCILayerZstd   = "application/vnd.oci.image.layer.v1.tar+zstd"
+	mediaTypeDockerLayer    = "application/vnd.docker.image.rootfs.diff.tar.gzip"
+	mediaTypeDockerLayerRaw = "application/vnd.docker.image.rootfs.diff.tar"
+)
+
+// Layer compressions.
+const (
+	compressionNone = "none"
+	compressionGzip = "gzip"
+	compressionZstd = "zstd"
+)
+
+// layerCompression returns the compression of the layers with the media type or an empty
+// string if the media type is not a known layer type, e.g. an artifact.
+func layerCompression(mediaType string) string {
+	switch mediaType {
+	case mediaTypeOCILayer, mediaTypeDockerLayerRaw:
+		return compressionNone
+	case mediaTypeOCILayerGzip, mediaTypeDockerLayer:
+		return compressionGzip
+	case mediaTypeOCILayerZstd:
+		return compressionZstd
+	}
+	return ""
+}
+
+// Legacy schema1 manifest media types.
+const (
+	mediaTypeSchema1Manifest       = "application/vnd.docker.distribution.manifest.v1+json"
+	mediaTypeSchema1SignedManifest = "application/vnd.docker.distribution.manifest.v1+prettyjws"
+)
+
+// errSchema1 explains how to push the images which have legacy schema1 manifests.
+var errSchema1 = fmt.Errorf("%w: schema1 manifests are not supported - rebuild and push the image with a client which produces schema2 or OCI manifests (e.g. docker 1.10+)", ErrInvalidManifest)
+
+// CheckManifestSchema rejects the legacy schema1 manifests before they are pushed, since the
+// disco file cannot be produced from them.
+func CheckManifestSchema(contentType string, b []byte) error {
+	switch contentType {
+	case mediaTypeSchema1Manifest, mediaTypeSchema1SignedManifest:
+		return errSchema1
+	}
+	var manifest struct {
+		SchemaVersion int `json:"schemaVersion"`
+	}
+	// the registry rejects the manifests which cannot be decoded
+	if err := json.Unmarshal(b, &manifest); err == nil && manifest.SchemaVersion == 1 {
+		return errSchema1
+	}
+	return nil
+}
+
+// validate checks that the manifest is an image manifest which the disco file can be produced from.
+func (manifest *imageManifest) validate() error {
+	if manifest.SchemaVersion == 1 {
+		return errSchema1
+	}
+	if manifest.SchemaVersion != 2 {
+		return fmt.Errorf("%w: unsupported schema version %d", ErrInvalidManifest, manifest.SchemaVersion)
+	}
+	switch manifest.MediaType {
+	case mediaTypeDockerManifest, mediaTypeOCIManifest, mediaTypeDockerList, mediaTypeOCIIndex, "":
+	default:
+		return fmt.Errorf("%w: unsupported media type '%s'", ErrInvalidManifest, manifest.MediaType)
+	}
+	if manifest.isIndex() {
+		return manifest.validateIndex()
+	}
+	if len(manifest.Config.Digest) == 0 {
+		return fmt.Errorf("%w: missing config", ErrInvalidManifest)
+	}
+	if !isSHA256Digest(manifest.Config.Digest) {
+		return fmt.Errorf("%w: invalid config digest '%s'", ErrInvalidManifest, manifest.Config.Digest)
+	}
+	for i, layer := range manifest.Layers {
+		if !isSHA256Digest(layer.Digest) {
+			return fmt.Errorf("%w: invalid digest '%s' of layer %d", ErrInvalidManifest, layer.Digest, i)
+		}
+		// the docker manifests cannot have the zstd layers which only the OCI spec defines
+		if layer.MediaType == mediaTypeOCILayerZstd && manifest.MediaType == mediaTypeDockerManifest {
+			return fmt.Errorf("%w: zstd layer %d in a docker manifest", ErrInvalidManifest, i)
+		}
+	}
+	return nil
+}
+
+// validateIndex checks the manifests which the manifest list or the image index lists.
+func (manifest *imageManifest) validateIndex() error {
+	if len(manifest.Manifests) == 0 {
+		return fmt.Errorf("%w: empty manifest list", ErrInvalidManifest)
+	}
+	for i, child := range manifest.Manifests {
+		if !isSHA256Digest(child.Digest) {
+			return fmt.Errorf("%w: invalid digest '%s' of manifest %d", ErrInvalidManifest, child.Digest, i)
+		}
+		switch child.MediaType {
+		case mediaTypeDockerManifest, mediaTypeOCIManifest, mediaTypeDockerList, mediaTypeOCIIndex:
+		default:
+			return fmt.Errorf("%w: unsupported media type '%s' of manifest %d", ErrInvalidManifest, child.MediaType, i)
+		}
+	}
+	return nil
+}
+
+// isIndex tells if the manifest is a manifest list or an image index. The image indexes may
+// not have a media type.
+func (manifest *imageManifest) isIndex() bool {
+	switch manifest.MediaType {
+	case mediaTypeDockerList, mediaTypeOCIIndex:
+		return true
+	case "":
+		return len(manifest.Manifests) > 0 && len(manifest.Config.Digest) == 0
+	}
+	return false
+}
+
+// mediaType returns the media type of the manifest. The manifests without a media type are
+// OCI manifests or OCI image indexes.
+func (manifest *imageManifest) mediaType() string {
+	if len(manifest.MediaType) > 0 {
+		return manifest.MediaType
+	}
+	if manifest.isIndex() {
+		return mediaTypeOCIIndex
+	}
+	return mediaTypeOCIManifest
+}
+
+// IsIndexMediaType tells if the media type is of a manifest list or an image index.
+func IsIndexMediaType(mediaType string) bool {
+	return mediaType == mediaTypeDockerList || mediaType == mediaTypeOCIIndex
+}
+
+// maxManifestDepth limits the nesting of the image indexes.
+const maxManifestDepth = 2
+
+// manifestReader reads the manifest with the digest.
+type manifestReader func(ctx context.Context, digest string) (*imageManifest, error)
+
+// childManifest is a manifest which a manifest list or an image index lists, or which refers to
+// another manifest as its subject.
+type childManifest struct {
+	Digest   string
+	Size     int64
+	Manifest *imageManifest
+}
+
+// readChildManifests reads and validates the manifests which the manifest lists, and the
+// manifests which they list in turn. It returns nothing for an image manifest.
+func readChildManifests(ctx context.Context, read manifestReader, manifest *imageManifest) ([]*childManifest, error) {
+	return readChildManifestsAt(ctx, read, manifest, 1)
+}
+
+func readChildManifestsAt(ctx context.Context, read manifestReader, manifest *imageManifest, depth int) ([]*childManifest, error) {
+	if !manifest.isIndex() {
+		return nil, nil
+	}
+	if depth > maxManifestDepth {
+		return nil, fmt.Errorf("%w: manifest lists are nested too deep", ErrInvalidManifest)
+	}
+	var children []*childManifest
+	for _, desc := range manifest.Manifests {
+		digest := desc.Digest[7:]
+		child, err := read(ctx, digest)
+		if _, ok := err.(storagedriver.PathNotFoundError); ok {
+			return nil, fmt.Errorf("%w: manifest %s is not found", ErrInvalidManifest, desc.Digest)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to read manifest %s: %v", desc.Digest, err)
+		}
+		if err := child.validate(); err != nil {
+			return nil, fmt.Errorf("manifest %s: %w", desc.Digest, err)
+		}
+		children = append(children, &childManifest{Digest: digest, Size: desc.Size, Manifest: child})
+		grandChildren, err := readChildManifestsAt(ctx, read, child, depth+1)
+		if err != nil {
+			return nil, err
+		}
+		children = append(children, grandChildren...)
+	}
+	return children, nil
+}
+
+// imageBlobs returns the blobs of the manifest and the manifests which it lists: the manifests,
+// the configs and the layers, without the duplicates. The CIDs are not set.
+func imageBlobs(manifestDigest string, manifest *imageManifest, children []*childManifest) []*blobCid {
+	var blobs []*blobCid
+	added := make(map[string]bool)
+	add := func(digest, mediaType string) {
+		if added[digest] {
+			return
+		}
+		added[digest] = true
+		blobs = append(blobs, &blobCid{Digest: digest, MediaType: mediaType})
+	}
+	addManifest := func(digest string, image *imageManifest) {
+		add(digest, "")
+		if image.isIndex() {
+			return
+		}
+		add(image.Config.Digest[7:], "")
+		for _, layer := range image.Layers {
+			add(layer.Digest[7:], layer.MediaType)
+		}
+	}
+	addManifest(manifestDigest, manifest)
+	for _, child := range children {
+		addManifest(child.Digest, child.Manifest)
+	}
+	return blobs
+}
+
+// ManifestMediaType returns the media type of the manifest of a CID v1 or digest repository from
+// its disco file. It returns an empty string if the media type is not recorded.
+func (disco *Disco) ManifestMediaType(ctx context.Context, repoName string) (string, error) {
+	if !disco.IsOnlyPullable(repoName) {
+		return "", nil
+	}
+	// the global repositories never change
+	if mediaType, ok := disco.mediaTypes.Load(repoName); ok {
+		return mediaType.(string), nil
+	}
+	b, err := disco.getDriver().GetContent(ctx, makeDiscoFilePath(repoName))
+	if _, ok := err.(storagedriver.PathNotFoundError); ok {
+		return "", nil
+	}
+	if err != nil {
+		return "", fmt.Errorf("failed to read the disco file: %v", err)
+	}
+	file, err := decodeDiscoFile(b)
+	if err != nil {
+		return "", err
+	}
+	disco.mediaTypes.Store(repoName, file.MediaType)
+	return file.MediaType, nil
+}
+
+// isSHA256Digest checks if the digest is in sha256:<hex> format.
+func isSHA256Digest(digest string) bool {
+	return strings.HasPrefix(digest, "sha256:") && utils.IsDigestHex(digest[7:])
+}
+
+// checkManifestBlobs makes sure that the blobs which the manifest references exist in the storage.
+func (disco *Disco) checkManifestBlobs(ctx context.Context, driver storagedriver.StorageDriver, blobs []*blobCid) error {
+	for _, blob := range blobs {
+		_, err := driver.Stat(ctx, makeBlobPath(blob.Digest))
+		if _, ok := err.(storagedriver.PathNotFoundError); ok {
+			return fmt.Errorf("%w: blob sha256:%s is not found", ErrInvalidManifest, blob.Digest)
+		}
+		if err != nil {
+			return fmt.Errorf("failed to check blob sha256:%s: %v", blob.Digest, err)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,123 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+
+	storagedriver "github.com/distribution/distribution/v3/registry/storage/driver"
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/require"
+)
+
+func TestImageManifestValidate(t *testing.T) {
+	r := require.New(t)
+
+	var manifest imageManifest
+	r.NoError(json.Unmarshal([]byte(testManifest), &manifest))
+	r.NoError(manifest.validate())
+
+	for name, manifestJSON := range map[string]string{
+		"schema1":        `{"schemaVersion":1,"name":"myrepo","tag":"latest","fsLayers":[]}`,
+		"empty list":     `{"schemaVersion":2,"mediaType":"application/vnd.docker.distribution.manifest.list.v2+json","manifests":[]}`,
+		"bad list entry": `{"schemaVersion":2,"mediaType":"` + mediaTypeOCIIndex + `","manifests":[{"mediaType":"` + mediaTypeOCIManifest + `","digest":"sha256:abc"}]}`,
+		"missing config": `{"schemaVersion":2,"layers":[]}`,
+		"bad config":     `{"schemaVersion":2,"config":{"digest":"sha256:abc"}}`,
+		"bad layer":      `{"schemaVersion":2,"config":{"digest":"sha256:` + testConfigDigest + `"},"layers":[{"digest":"md5:abc"}]}`,
+		"docker zstd":    `{"schemaVersion":2,"mediaType":"` + mediaTypeDockerManifest + `","config":{"digest":"sha256:` + testConfigDigest + `"},"layers":[{"mediaType":"` + mediaTypeOCILayerZstd + `","digest":"sha256:` + testLayerDigest + `"}]}`,
+	} {
+		var manifest imageManifest
+		r.NoError(json.Unmarshal([]byte(manifestJSON), &manifest), name)
+		r.ErrorIs(manifest.validate(), ErrInvalidManifest, name)
+	}
+}
+
+func TestManifestList(t *testing.T) {
+	r := require.New(t)
+
+	// Given a manifest list of an image manifest and an image index without a media type
+	const (
+		imageDigest = "1111111111111111111111111111111111111111111111111111111111111111"
+		indexDigest = "2222222222222222222222222222222222222222222222222222222222222222"
+	)
+	listJSON := `{"schemaVersion":2,"mediaType":"` + mediaTypeDockerList + `","manifests":[` +
+		`{"mediaType":"` + mediaTypeDockerManifest + `","digest":"sha256:` + imageDigest + `","platform":{"architecture":"amd64","os":"linux"}},` +
+		`{"mediaType":"` + mediaTypeOCIIndex + `","digest":"sha256:` + indexDigest + `"}]}`
+	manifests := map[string]string{
+		imageDigest: testManifest,
+		indexDigest: `{"schemaVersion":2,"manifests":[{"mediaType":"` + mediaTypeDockerManifest + `","digest":"sha256:` + imageDigest + `"}]}`,
+	}
+	read := func(ctx context.Context, digest string) (*imageManifest, error) {
+		manifestJSON, ok := manifests[digest]
+		if !ok {
+			return nil, storagedriver.PathNotFoundError{}
+		}
+		var manifest imageManifest
+		return &manifest, json.Unmarshal([]byte(manifestJSON), &manifest)
+	}
+	var list imageManifest
+	r.NoError(json.Unmarshal([]byte(listJSON), &list))
+	r.NoError(list.validate())
+	r.True(list.isIndex())
+	r.Equal(mediaTypeDockerList, list.mediaType())
+
+	// When the listed manifests are read
+	children, err := readChildManifests(context.Background(), read, &list)
+	r.NoError(err)
+	r.Len(children, 3)
+	r.Equal(mediaTypeOCIIndex, children[1].Manifest.mediaType())
+
+	// Then the blobs of all platforms should be collected once
+	var digests []string
+	for _, blob := range imageBlobs(testManifestDigest, &list, children) {
+		digests = append(digests, blob.Digest)
+	}
+	r.Equal([]string{testManifestDigest, imageDigest, testConfigDigest, testLayerDigest, indexDigest}, digests)
+
+	// And the missing manifests should make the list invalid
+	delete(manifests, imageDigest)
+	_, err = readChildManifests(context.Background(), read, &list)
+	r.ErrorIs(err, ErrInvalidManifest)
+
+	// And the image manifests should not have children
+	var manifest imageManifest
+	r.NoError(json.Unmarshal([]byte(testManifest), &manifest))
+	children, err = readChildManifests(context.Background(), read, &manifest)
+	r.NoError(err)
+	r.Empty(children)
+}
+
+func TestLayerCompression(t *testing.T) {
+	r := require.New(t)
+
+	var manifest imageManifest
+	r.NoError(json.Unmarshal([]byte(`{"schemaVersion":2,"mediaType":"`+mediaTypeOCIManifest+`","config":{"digest":"sha256:`+testConfigDigest+`"},"layers":[{"mediaType":"`+mediaTypeOCILayerZstd+`","digest":"sha256:`+testLayerDigest+`"}]}`), &manifest))
+	r.NoError(manifest.validate())
+	r.Equal(compressionZstd, layerCompression(manifest.Layers[0].MediaType))
+	r.Equal(compressionGzip, layerCompression(mediaTypeDockerLayer))
+	r.Equal(compressionNone, layerCompression(mediaTypeOCILayer))
+	r.Empty(layerCompression("application/vnd.example.artifact"))
+}
+
+func TestCheckManifestSchema(t *testing.T) {
+	r := require.New(t)
+
+	r.NoError(CheckManifestSchema(mediaTypeDockerManifest, []byte(testManifest)))
+	r.ErrorIs(CheckManifestSchema(mediaTypeSchema1SignedManifest, []byte(`{}`)), ErrInvalidManifest)
+	r.ErrorIs(CheckManifestSchema("application/json", []byte(`{"schemaVersion":1,"fsLayers":[]}`)), ErrInvalidManifest)
+	r.NoError(CheckManifestSchema("application/json", []byte(`not json`)), "should leave it to the registry")
+}
+
+func (s *Suite) TestManifestMediaType() {
+	s.driver.EXPECT().GetContent(gomock.Any(), makeDiscoFilePath(testCidv1)).Return([]byte(testDiscoFile), nil).Times(1)
+
+	for i := 0; i < 2; i++ {
+		mediaType, err := s.disco.ManifestMediaType(s.ctx, testCidv1)
+		s.r.NoError(err)
+		s.r.Equal(mediaTypeDockerManifest, mediaType)
+	}
+
+	mediaType, err := s.disco.ManifestMediaType(s.ctx, "myrepo")
+	s.r.NoError(err)
+	s.r.Empty(mediaType)
+}
@@ -0,0 +1,118 @@
+package services
+
+import (
+	"time"
+
+	metrics "github.com/docker/go-metrics"
+	"github.com/forta-network/disco/utils"
+	log "github.com/sirupsen/logrus"
+)
+
+// Operations which are measured.
+const (
+	operationMakeGlobal = "make_global"
+	operationClone      = "clone"
+	operationCloneBlob  = "clone_blob"
+	operationMountBlob  = "mount_blob"
+)
+
+// Operation outcomes.
+const (
+	outcomeSuccess = "success"
+	outcomeFailure = "failure"
+	outcomeSkipped = "skipped"
+)
+
+var (
+	metricsNamespace = metrics.NewNamespace("disco", "service", nil)
+	// the metrics are exposed with the registry metrics
+	stepDuration    = metricsNamespace.NewLabeledTimer("step_duration", "The duration of the steps of the Disco operations", "operation", "step")
+	operationsTotal = metricsNamespace.NewLabeledCounter("operations", "The outcomes of the Disco operations", "operation", "outcome")
+	storageUsage    = metricsNamespace.NewLabeledGauge("storage_usage", "The storage usage of the IPFS nodes and the cache", metrics.Bytes, "store")
+	repoBandwidth   = metricsNamespace.NewLabeledCounter("repository_bytes_served", "The bytes served from the repositories", "repository")
+	clientBandwidth = metricsNamespace.NewLabeledCounter("client_bytes_served", "The bytes served to the clients", "client")
+)
+
+func init() {
+	metrics.Register(metricsNamespace)
+}
+
+// operationTimer measures the steps of an operation and records its outcome.
+type operationTimer struct {
+	operation string
+	repoName  string
+	start     time.Time
+	stepStart time.Time
+	steps     []string
+	durations map[string]time.Duration
+	skipped   bool
+	// op tracks the status of the operation for the status requests, if it is not nil.
+	op *operation
+	// span traces the operation and its steps, if the tracing is enabled.
+	span *utils.Span
+}
+
+func newOperationTimer(operation, repoName string) *operationTimer {
+	now := time.Now()
+	return &operationTimer{
+		operation: operation,
+		repoName:  repoName,
+		start:     now,
+		stepStart: now,
+		durations: make(map[string]time.Duration),
+	}
+}
+
+// step records the duration of the step which ended now.
+func (timer *operationTimer) step(name string) {
+	now := time.Now()
+	duration := now.Sub(timer.stepStart)
+	timer.stepStart = now
+	timer.steps = append(timer.steps, name)
+	timer.durations[name] += duration
+	stepDuration.WithValues(timer.operation, name).Update(duration)
+	timer.op.setStep(name)
+	timer.span.AddEvent(name)
+}
+
+// skip marks the operation as skipped, e.g. when the repository is already global.
+func (timer *operationTimer) skip() {
+	timer.skipped = true
+}
+
+// done records the outcome and the total duration of the operation.
+func (timer *operationTimer) done(err error) string {
+	outcome := outcomeSuccess
+	switch {
+	case err != nil:
+		outcome = outcomeFailure
+	case timer.skipped:
+		outcome = outcomeSkipped
+	}
+	total := time.Since(timer.start)
+	stepDuration.WithValues(timer.operation, "total").Update(total)
+	operationsTotal.WithValues(timer.operation, outcome).Inc(1)
+	timer.op.finish(outcome, err)
+	timer.span.SetAttributes("disco.outcome", outcome)
+	timer.span.End(err)
+
+	fields := log.Fields{
+		"operation":  timer.operation,
+		"repository": timer.repoName,
+		"outcome":    outcome,
+		"total":      total.String(),
+	}
+	for _, step := range timer.steps {
+		fields["step_"+step] = timer.durations[step].String()
+	}
+	if timer.op != nil {
+		fields["id"] = timer.op.status.ID
+	}
+	logger := log.WithFields(fields)
+	if err != nil {
+		logger.WithError(err).Warn("operation failed")
+	} else {
+		logger.Debug("operation finished")
+	}
+	return outcome
+}
@@ -0,0 +1,21 @@
+package services
+
+import (
+	"errors"
+	"time"
+)
+
+func (s *Suite) TestOperationTimer() {
+	timer := newOperationTimer(operationMakeGlobal, testCidv1)
+	timer.step("disco_file")
+	time.Sleep(time.Millisecond)
+	timer.step("cid_copy")
+
+	s.r.Equal([]string{"disco_file", "cid_copy"}, timer.steps)
+	s.r.GreaterOrEqual(timer.durations["cid_copy"], time.Millisecond)
+	s.r.Equal(outcomeSuccess, timer.done(nil))
+	s.r.Equal(outcomeFailure, timer.done(errors.New("failed")))
+
+	timer.skip()
+	s.r.Equal(outcomeSkipped, timer.done(nil))
+}
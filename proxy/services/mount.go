@@ -0,0 +1,57 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	storagedriver "github.com/distribution/distribution/v3/registry/storage/driver"
+	"github.com/forta-network/disco/utils"
+)
+
+// MountBlob links the blob of a global repository to the repository for a cross-repository blob
+// mount, so that the pushes do not upload the blobs which Disco knows again. The blobs which are
+// not in the storage, e.g. the layers of the lazily cloned repositories, are copied from the IPFS
+// network with their CIDs from the CID index. It returns false if the registry should handle the
+// mount. The digest can have the sha256: prefix.
+func (disco *Disco) MountBlob(ctx context.Context, repoName, digest string) (bool, error) {
+	if disco.cfg.CacheOnly || disco.cfg.NoClone || disco.IsOnlyPullable(repoName) {
+		return false, nil
+	}
+	digest = strings.TrimPrefix(digest, "sha256:")
+	if !utils.IsDigestHex(digest) {
+		return false, nil
+	}
+	cid, ok := disco.cids.blobCid(digest)
+	if !ok {
+		return false, nil
+	}
+	ctx, cancel := disco.operationContext(ctx)
+	defer cancel()
+
+	driver := disco.getDriver()
+	_, err := driver.Stat(ctx, makeBlobPath(digest))
+	switch err.(type) {
+	case nil:
+	case storagedriver.PathNotFoundError:
+		err = disco.blobsInFlight.Do(ctx, digest, func() (err error) {
+			ctx, timer := disco.startOperation(ctx, operationMountBlob, repoName)
+			defer func() {
+				timer.done(err)
+			}()
+			return disco.copyBlob(ctx, timer, &blobCid{Digest: digest, Cid: cid})
+		})
+		if err != nil {
+			return false, err
+		}
+	default:
+		return false, fmt.Errorf("failed to check the blob: %v", err)
+	}
+
+	// link the blob to the repository as the registry does
+	linkPath := fmt.Sprintf("%s/_layers/sha256/%s/link", makeRepoPath(repoName), digest)
+	if err := driver.PutContent(ctx, linkPath, []byte("sha256:"+digest)); err != nil {
+		return false, fmt.Errorf("failed to link the blob: %v", err)
+	}
+	return true, nil
+}
@@ -0,0 +1,35 @@
+package services
+
+import (
+	"fmt"
+
+	storagedriver "github.com/distribution/distribution/v3/registry/storage/driver"
+	"github.com/golang/mock/gomock"
+)
+
+func (s *Suite) TestMountBlob() {
+	// Given that a manifest is made global and its layer is not in the storage
+	s.disco.cids = newCidIndex("", newTestStore())
+	s.disco.cids.addRepo(testManifestDigest, testCidv1, []*blobCid{{Digest: testLayerDigest, Cid: testLayerCid}})
+	s.driver.EXPECT().Stat(gomock.Any(), makeBlobPath(testLayerDigest)).Return(nil, storagedriver.PathNotFoundError{})
+
+	// When the layer is mounted to another repository
+	// Then it should be copied from the network
+	s.ipfsNode.EXPECT().FilesMkdir(gomock.Any(), makeBlobDirPath(testLayerDigest), gomock.Any()).Return(nil)
+	s.ipfsNode.EXPECT().FilesCp(gomock.Any(), fmt.Sprintf("/ipfs/%s", testLayerCid), makeBlobPath(testLayerDigest)).Return(nil)
+	s.driver.EXPECT().ReplicateInSecondary(gomock.Any(), makeBlobPath(testLayerDigest)).Return(nil, nil)
+	// And it should be linked to the repository
+	linkPath := fmt.Sprintf("%s/_layers/sha256/%s/link", makeRepoPath("myrepo"), testLayerDigest)
+	s.driver.EXPECT().PutContent(gomock.Any(), linkPath, []byte("sha256:"+testLayerDigest)).Return(nil)
+	mounted, err := s.disco.MountBlob(s.ctx, "myrepo", "sha256:"+testLayerDigest)
+	s.r.NoError(err)
+	s.r.True(mounted)
+
+	// And the unknown blobs and the global repositories should be left to the registry
+	mounted, err = s.disco.MountBlob(s.ctx, "myrepo", "sha256:"+testConfigDigest)
+	s.r.NoError(err)
+	s.r.False(mounted)
+	mounted, err = s.disco.MountBlob(s.ctx, testCidv1, "sha256:"+testLayerDigest)
+	s.r.NoError(err)
+	s.r.False(mounted)
+}
@@ -0,0 +1,157 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/forta-network/disco/config"
+	"github.com/forta-network/disco/utils"
+)
+
+// NameResolver resolves a repository name to a CID v1 repository. It returns false when it
+// does not know the name, so that the next resolver in the chain can try.
+type NameResolver interface {
+	ResolveName(ctx context.Context, name string) (repoCid string, ok bool, err error)
+}
+
+// newNameResolvers creates the resolvers in the config in the configured order.
+func (disco *Disco) newNameResolvers(namesCfg config.NamesConfig) []NameResolver {
+	var resolvers []NameResolver
+	for _, resolverCfg := range namesCfg.Resolvers {
+		switch resolverCfg.Kind {
+		case config.NameResolverIdentity:
+			resolvers = append(resolvers, identityResolver{})
+		case config.NameResolverDigest:
+			resolvers = append(resolvers, &digestResolver{disco: disco})
+		case config.NameResolverENS:
+			resolvers = append(resolvers, &ensResolver{
+				endpoint:   resolverCfg.Endpoint,
+				registry:   resolverCfg.Registry,
+				httpClient: disco.httpClient,
+			})
+		case config.NameResolverForta:
+			resolvers = append(resolvers, &fortaResolver{
+				endpoint: resolverCfg.Endpoint,
+				registry: resolverCfg.Registry,
+				disco:    disco,
+			})
+		}
+	}
+	return resolvers
+}
+
+// AddNameResolver adds a resolver which is tried after the resolvers in the config.
+func (disco *Disco) AddNameResolver(resolver NameResolver) {
+	disco.nameResolvers = append(disco.nameResolvers, resolver)
+}
+
+// ResolveRepoName resolves the repository name with the resolvers in order and returns the CID
+// v1 repository from the first one which knows the name. The name is returned as is when no
+// resolver knows it. The results are cached for the configured TTL.
+func (disco *Disco) ResolveRepoName(ctx context.Context, name string) (string, error) {
+	if len(disco.nameResolvers) == 0 {
+		return name, nil
+	}
+	if repoCid, ok := disco.names.get(name); ok {
+		return repoCid, nil
+	}
+	timeout := disco.cfg.Names.Timeout
+	if timeout == 0 {
+		timeout = config.DefaultNamesTimeout
+	}
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+	resolved := name
+	for _, resolver := range disco.nameResolvers {
+		repoCid, ok, err := resolver.ResolveName(ctx, name)
+		if err != nil {
+			return "", fmt.Errorf("%w: %s: %v", ErrNameResolution, name, err)
+		}
+		if ok {
+			resolved = repoCid
+			break
+		}
+	}
+	disco.names.set(name, resolved)
+	if resolved != name {
+		utils.Logger(ctx).WithField("name", name).WithField("repository", resolved).Debug("resolved the repository name")
+	}
+	return resolved, nil
+}
+
+// identityResolver resolves the CID v1 names to themselves.
+type identityResolver struct{}
+
+func (identityResolver) ResolveName(ctx context.Context, name string) (string, bool, error) {
+	if !utils.IsCIDv1(name) {
+		return "", false, nil
+	}
+	return name, true, nil
+}
+
+// digestResolver resolves the manifest digests of the global repositories to their CID v1
+// repositories.
+type digestResolver struct {
+	disco *Disco
+}
+
+func (resolver *digestResolver) ResolveName(ctx context.Context, name string) (string, bool, error) {
+	if !utils.IsDigestHex(name) {
+		return "", false, nil
+	}
+	// the digests which are not global yet are left to the next resolvers
+	repoCid, err := resolver.disco.ResolveRepoCid(ctx, name)
+	if err != nil {
+		return "", false, nil
+	}
+	return repoCid, true, nil
+}
+
+// nameCache caches the resolved names, including the names which no resolver knows.
+type nameCache struct {
+	ttl     time.Duration
+	mu      sync.Mutex
+	entries map[string]*cachedName
+}
+
+type cachedName struct {
+	repoCid   string
+	expiresAt time.Time
+}
+
+func newNameCache(ttl time.Duration) *nameCache {
+	if ttl <= 0 {
+		ttl = config.DefaultNamesTTL
+	}
+	return &nameCache{ttl: ttl, entries: make(map[string]*cachedName)}
+}
+
+func (cache *nameCache) get(name string) (string, bool) {
+	if cache == nil {
+		return "", false
+	}
+	cache.mu.Lock()
+	defer cache.mu.Unlock()
+	entry, ok := cache.entries[name]
+	if !ok || time.Now().After(entry.expiresAt) {
+		return "", false
+	}
+	return entry.repoCid, true
+}
+
+func (cache *nameCache) set(name, repoCid string) {
+	if cache == nil {
+		return
+	}
+	cache.mu.Lock()
+	defer cache.mu.Unlock()
+	now := time.Now()
+	for key, entry := range cache.entries {
+		if now.After(entry.expiresAt) {
+			delete(cache.entries, key)
+		}
+	}
+	cache.entries[name] = &cachedName{repoCid: repoCid, expiresAt: now.Add(cache.ttl)}
+}
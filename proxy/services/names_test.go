@@ -0,0 +1,131 @@
+package services
+
+import (
+	"context"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"time"
+
+	"github.com/forta-network/disco/config"
+	"github.com/ipfs/go-cid"
+)
+
+type testNameResolver struct {
+	names map[string]string
+	calls int
+	err   error
+}
+
+func (resolver *testNameResolver) ResolveName(ctx context.Context, name string) (string, bool, error) {
+	resolver.calls++
+	if resolver.err != nil {
+		return "", false, resolver.err
+	}
+	repoCid, ok := resolver.names[name]
+	return repoCid, ok, nil
+}
+
+func (s *Suite) TestResolveRepoName() {
+	// Given a chain of the identity resolver and a resolver which knows a name
+	known := &testNameResolver{names: map[string]string{"myimage": testCidv1}}
+	s.disco.names = newNameCache(time.Minute)
+	s.disco.nameResolvers = s.disco.newNameResolvers(config.NamesConfig{
+		Resolvers: []*config.NameResolverConfig{{Kind: config.NameResolverIdentity}},
+	})
+	s.disco.AddNameResolver(known)
+
+	// Then the CIDs should resolve to themselves without asking the next resolver
+	repoCid, err := s.disco.ResolveRepoName(s.ctx, testCidv1)
+	s.r.NoError(err)
+	s.r.Equal(testCidv1, repoCid)
+	s.r.Equal(0, known.calls)
+
+	// And the known name should resolve to the CID once within the TTL
+	for i := 0; i < 2; i++ {
+		repoCid, err = s.disco.ResolveRepoName(s.ctx, "myimage")
+		s.r.NoError(err)
+		s.r.Equal(testCidv1, repoCid)
+	}
+	s.r.Equal(1, known.calls)
+
+	// And the unknown names should be kept as they are
+	repoCid, err = s.disco.ResolveRepoName(s.ctx, "otherimage")
+	s.r.NoError(err)
+	s.r.Equal("otherimage", repoCid)
+
+	// And the failures should not be cached
+	known.err = errors.New("failed")
+	_, err = s.disco.ResolveRepoName(s.ctx, "failingimage")
+	s.r.ErrorIs(err, ErrNameResolution)
+	known.err = nil
+	repoCid, err = s.disco.ResolveRepoName(s.ctx, "failingimage")
+	s.r.NoError(err)
+	s.r.Equal("failingimage", repoCid)
+}
+
+func (s *Suite) TestResolveName_ENS() {
+	// Given an Ethereum endpoint with TLS which has a resolver with the content hash of the CID
+	parsed, err := cid.Decode(testCidv1)
+	s.r.NoError(err)
+	contentHash := append([]byte(ipfsContentHashPrefix), parsed.Bytes()...)
+	resolverAddr := "0x0000000000000000000000000000000000000abc"
+	node := ensNamehash("myimage.eth")
+	endpoint := httptest.NewTLSServer(http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+		var req struct {
+			Params []json.RawMessage `json:"params"`
+		}
+		s.r.NoError(json.NewDecoder(r.Body).Decode(&req))
+		var call struct {
+			To   string `json:"to"`
+			Data string `json:"data"`
+		}
+		s.r.NoError(json.Unmarshal(req.Params[0], &call))
+		data, err := hex.DecodeString(strings.TrimPrefix(call.Data, "0x"))
+		s.r.NoError(err)
+		s.r.Equal(node, data[4:])
+		var result []byte
+		switch hex.EncodeToString(data[:4]) {
+		case hex.EncodeToString(abiSelector("resolver(bytes32)")):
+			s.r.Equal(config.DefaultENSRegistry, call.To)
+			addr, _ := hex.DecodeString(strings.TrimPrefix(resolverAddr, "0x"))
+			result = append(make([]byte, 12), addr...)
+		case hex.EncodeToString(abiSelector("contenthash(bytes32)")):
+			s.r.Equal(resolverAddr, call.To)
+			result = testABIBytes(contentHash)
+		default:
+			s.T().Fatalf("unexpected call %s", call.Data)
+		}
+		_ = json.NewEncoder(rw).Encode(map[string]interface{}{
+			"jsonrpc": "2.0",
+			"id":      1,
+			"result":  "0x" + hex.EncodeToString(result),
+		})
+	}))
+	defer endpoint.Close()
+	resolver := &ensResolver{endpoint: endpoint.URL, registry: config.DefaultENSRegistry, httpClient: endpoint.Client()}
+
+	// Then the name should resolve to the CID v1
+	repoCid, ok, err := resolver.ResolveName(s.ctx, "myimage.eth")
+	s.r.NoError(err)
+	s.r.True(ok)
+	s.r.Equal(testCidv1, repoCid)
+
+	// And the other names should be left to the next resolvers
+	_, ok, err = resolver.ResolveName(s.ctx, "myimage")
+	s.r.NoError(err)
+	s.r.False(ok)
+}
+
+// testABIBytes encodes the bytes as the only return value.
+func testABIBytes(b []byte) []byte {
+	encoded := make([]byte, 64)
+	encoded[31] = 32
+	encoded[63] = byte(len(b))
+	padded := make([]byte, (len(b)+31)/32*32)
+	copy(padded, b)
+	return append(encoded, padded...)
+}
@@ -0,0 +1,20 @@
+package services
+
+import (
+	"time"
+)
+
+// SharedNoncesEnabled tells if the nonces of the signed admin requests are recorded in the
+// shared state, so that a request which one replica accepted cannot be replayed against the
+// others.
+func (disco *Disco) SharedNoncesEnabled() bool {
+	return disco.nonces != nil
+}
+
+// UseNonce records the nonce of a signed admin request in the shared state until the TTL
+// expires and returns false if any of the replicas recorded it before.
+func (disco *Disco) UseNonce(nonce string, ttl time.Duration) (bool, error) {
+	ctx, cancel := storeContext()
+	defer cancel()
+	return disco.nonces.add(ctx, storeKeyNonces+nonce, "1", ttl)
+}
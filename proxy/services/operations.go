@@ -0,0 +1,258 @@
+package services
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/forta-network/disco/utils"
+)
+
+// maxFinishedOperations is the number of the finished operations which are kept, so that their
+// outcomes can be requested after they finish.
+const maxFinishedOperations = 100
+
+// OperationRunning is the state of the running operations. The finished operations have their
+// outcomes as their states: success, failure or skipped.
+const OperationRunning = "running"
+
+// Blob states in the operations.
+const (
+	blobPending  = "pending"
+	blobExisting = "existing"
+	blobCopied   = "copied"
+	blobFailed   = "failed"
+)
+
+// Operation is the status of a clone or a globalization which Disco runs or ran recently.
+type Operation struct {
+	ID          string           `json:"id"`
+	Kind        string           `json:"kind"`
+	Repository  string           `json:"repository"`
+	State       string           `json:"state"`
+	Step        string           `json:"step,omitempty"`
+	StartedAt   time.Time        `json:"startedAt"`
+	FinishedAt  *time.Time       `json:"finishedAt,omitempty"`
+	BytesCopied int64            `json:"bytesCopied"`
+	Blobs       []*OperationBlob `json:"blobs,omitempty"`
+	Error       string           `json:"error,omitempty"`
+}
+
+// OperationBlob is the progress of a blob which is copied by an operation.
+type OperationBlob struct {
+	Digest string `json:"digest"`
+	Cid    string `json:"cid"`
+	Size   int64  `json:"size,omitempty"`
+	State  string `json:"state"`
+	Error  string `json:"error,omitempty"`
+}
+
+// operationRegistry keeps the running operations and the last finished ones.
+type operationRegistry struct {
+	mu       sync.Mutex
+	running  map[string]*operation
+	finished []*operation
+}
+
+// operation tracks the status of an operation in the registry.
+type operation struct {
+	registry *operationRegistry
+
+	mu     sync.Mutex
+	status Operation
+}
+
+func newOperationID() string {
+	b := make([]byte, 8)
+	if _, err := rand.Read(b); err != nil {
+		return fmt.Sprintf("%016x", time.Now().UnixNano())
+	}
+	return hex.EncodeToString(b)
+}
+
+func (registry *operationRegistry) start(kind, repoName string) *operation {
+	op := &operation{
+		registry: registry,
+		status: Operation{
+			ID:         newOperationID(),
+			Kind:       kind,
+			Repository: repoName,
+			State:      OperationRunning,
+			StartedAt:  time.Now(),
+		},
+	}
+	registry.mu.Lock()
+	defer registry.mu.Unlock()
+	if registry.running == nil {
+		registry.running = make(map[string]*operation)
+	}
+	registry.running[op.status.ID] = op
+	return op
+}
+
+// get returns the status of the running or the recently finished operation.
+func (registry *operationRegistry) get(id string) (*Operation, bool) {
+	registry.mu.Lock()
+	defer registry.mu.Unlock()
+	if op, ok := registry.running[id]; ok {
+		return op.snapshot(), true
+	}
+	for _, op := range registry.finished {
+		if op.status.ID == id {
+			return op.snapshot(), true
+		}
+	}
+	return nil, false
+}
+
+// list returns the statuses of the operations of the repository, or of all operations if the
+// repository is empty, in the order of their start times.
+func (registry *operationRegistry) list(repoName string) []*Operation {
+	registry.mu.Lock()
+	ops := make([]*operation, 0, len(registry.running)+len(registry.finished))
+	for _, op := range registry.running {
+		ops = append(ops, op)
+	}
+	ops = append(ops, registry.finished...)
+	registry.mu.Unlock()
+
+	statuses := []*Operation{}
+	for _, op := range ops {
+		if len(repoName) == 0 || op.status.Repository == repoName {
+			statuses = append(statuses, op.snapshot())
+		}
+	}
+	sort.Slice(statuses, func(i, j int) bool {
+		return statuses[i].StartedAt.Before(statuses[j].StartedAt)
+	})
+	return statuses
+}
+
+func (op *operation) snapshot() *Operation {
+	op.mu.Lock()
+	defer op.mu.Unlock()
+	status := op.status
+	status.Blobs = make([]*OperationBlob, 0, len(op.status.Blobs))
+	for _, blob := range op.status.Blobs {
+		blobCopy := *blob
+		status.Blobs = append(status.Blobs, &blobCopy)
+	}
+	return &status
+}
+
+// setStep records the last step which the operation finished.
+func (op *operation) setStep(step string) {
+	if op == nil {
+		return
+	}
+	op.mu.Lock()
+	defer op.mu.Unlock()
+	op.status.Step = step
+}
+
+// addBlob adds a blob which the operation copies, unless it exists already.
+func (op *operation) addBlob(blob *blobCid, exists bool) {
+	if op == nil {
+		return
+	}
+	state := blobPending
+	if exists {
+		state = blobExisting
+	}
+	op.mu.Lock()
+	defer op.mu.Unlock()
+	op.status.Blobs = append(op.status.Blobs, &OperationBlob{
+		Digest: "sha256:" + blob.Digest,
+		Cid:    blob.Cid,
+		Size:   blob.Size,
+		State:  state,
+	})
+}
+
+// blobCopied records the copied blob and its size.
+func (op *operation) blobCopied(digest string, size int64) {
+	op.updateBlob(digest, func(blob *OperationBlob) {
+		blob.State = blobCopied
+		if size > 0 {
+			blob.Size = size
+		}
+		op.status.BytesCopied += blob.Size
+	})
+}
+
+// blobFailed records the error of the blob.
+func (op *operation) blobFailed(digest string, err error) {
+	op.updateBlob(digest, func(blob *OperationBlob) {
+		blob.State = blobFailed
+		blob.Error = utils.Redact(err.Error())
+	})
+}
+
+func (op *operation) updateBlob(digest string, update func(blob *OperationBlob)) {
+	if op == nil {
+		return
+	}
+	op.mu.Lock()
+	defer op.mu.Unlock()
+	for _, blob := range op.status.Blobs {
+		if blob.Digest == "sha256:"+digest {
+			update(blob)
+			return
+		}
+	}
+}
+
+// finish records the outcome of the operation and moves it to the finished operations.
+func (op *operation) finish(outcome string, err error) {
+	if op == nil {
+		return
+	}
+	now := time.Now()
+	op.mu.Lock()
+	op.status.State = outcome
+	op.status.FinishedAt = &now
+	if err != nil {
+		op.status.Error = utils.Redact(err.Error())
+	}
+	op.mu.Unlock()
+
+	registry := op.registry
+	registry.mu.Lock()
+	defer registry.mu.Unlock()
+	delete(registry.running, op.status.ID)
+	registry.finished = append(registry.finished, op)
+	if len(registry.finished) > maxFinishedOperations {
+		registry.finished = registry.finished[len(registry.finished)-maxFinishedOperations:]
+	}
+}
+
+// startOperation starts measuring the operation, tracks its status in the operations and traces
+// it in a span of the context.
+func (disco *Disco) startOperation(ctx context.Context, operation, repoName string) (context.Context, *operationTimer) {
+	timer := newOperationTimer(operation, repoName)
+	timer.op = disco.operations.start(operation, repoName)
+	ctx, timer.span = utils.StartSpan(ctx, operation, utils.SpanKindInternal,
+		"disco.repository", repoName,
+		"disco.operation_id", timer.op.status.ID,
+	)
+	return ctx, timer
+}
+
+// Operation returns the status of the running or the recently finished operation.
+func (disco *Disco) Operation(id string) (*Operation, error) {
+	op, ok := disco.operations.get(id)
+	if !ok {
+		return nil, fmt.Errorf("%w: %s", ErrOperationNotFound, id)
+	}
+	return op, nil
+}
+
+// Operations returns the statuses of the running and the recently finished operations of the
+// repository, or of all repositories if it is empty.
+func (disco *Disco) Operations(repoName string) []*Operation {
+	return disco.operations.list(repoName)
+}
@@ -0,0 +1,63 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+)
+
+func (s *Suite) TestOperations() {
+	file, err := decodeDiscoFile([]byte(testDiscoFile))
+	s.r.NoError(err)
+
+	// Given a clone which copies two of the three blobs
+	_, timer := s.disco.startOperation(s.ctx, operationClone, testCidv1)
+	timer.op.addBlob(file.Blobs[0], true)
+	timer.op.addBlob(file.Blobs[1], false)
+	timer.op.addBlob(file.Blobs[2], false)
+	timer.step("disco_file")
+	timer.op.blobCopied(testConfigDigest, 0)
+
+	// When its status is requested
+	// Then the progress of the blobs should be returned
+	op, err := s.disco.Operation(timer.op.status.ID)
+	s.r.NoError(err)
+	s.r.Equal(OperationRunning, op.State)
+	s.r.Equal("disco_file", op.Step)
+	s.r.Equal(int64(1457), op.BytesCopied)
+	s.r.Equal([]string{blobExisting, blobCopied, blobPending}, []string{op.Blobs[0].State, op.Blobs[1].State, op.Blobs[2].State})
+
+	// When the clone fails
+	// Then the errors should be kept after it finishes
+	timer.op.blobFailed(testLayerDigest, errors.New("no providers"))
+	timer.done(fmt.Errorf("%w: no providers", ErrCloneFailed))
+	op, err = s.disco.Operation(timer.op.status.ID)
+	s.r.NoError(err)
+	s.r.Equal(outcomeFailure, op.State)
+	s.r.NotNil(op.FinishedAt)
+	s.r.Contains(op.Error, "no providers")
+	s.r.Equal("no providers", op.Blobs[2].Error)
+
+	// And the operations should be listed by repository
+	_, other := s.disco.startOperation(s.ctx, operationMakeGlobal, "myrepo")
+	s.r.Len(s.disco.Operations(""), 2)
+	s.r.Len(s.disco.Operations("myrepo"), 1)
+	s.r.Equal(other.op.status.ID, s.disco.Operations("myrepo")[0].ID)
+
+	// And the unknown operations should not be found
+	_, err = s.disco.Operation("1234")
+	s.r.ErrorIs(err, ErrOperationNotFound)
+}
+
+func (s *Suite) TestOperations_Limit() {
+	var first string
+	for i := 0; i < maxFinishedOperations+1; i++ {
+		_, timer := s.disco.startOperation(s.ctx, operationCloneBlob, testCidv1)
+		if i == 0 {
+			first = timer.op.status.ID
+		}
+		timer.done(nil)
+	}
+	s.r.Len(s.disco.Operations(testCidv1), maxFinishedOperations)
+	_, err := s.disco.Operation(first)
+	s.r.ErrorIs(err, ErrOperationNotFound)
+}
@@ -6,22 +6,36 @@ const (
 	registryBase     = "/docker/registry/v2"
 	repositoriesBase = registryBase + "/repositories"
 
-	discoFilePathFormat = repositoriesBase + "/%s/disco.json"
+	discoFilePathFormat  = repositoriesBase + "/%s/disco.json"
+	originFilePathFormat = repositoriesBase + "/%s/origin" // the name which the digest repo was pushed with
 
-	manifestLinkPath = "/_manifests/tags/latest/current/link" // "link" is a file which contains the digest in sha256:<digest> format
-	tagPathFormat    = "/_manifests/tags/%s"
+	tagPathFormat       = "/_manifests/tags/%s"
+	revisionsPathFormat = repositoriesBase + "/%s/_manifests/revisions/sha256"
+
+	uploadsBase         = registryBase + "/uploads" // see drivers.FixUploadPath
+	repoUploadsBase     = uploadsBase + "/repos"    // see drivers.RepoUploadsPath
+	uploadsDirName      = "_uploads"
+	uploadStartedAtName = "startedat"
 
 	blobsBase         = registryBase + "/blobs/sha256"
 	blobDirPathFormat = blobsBase + "/%s/%s"
 	blobPathFormat    = blobDirPathFormat + "/data" // "data" is a file which contains the blob bytes
+
+	agentManifestPathFormat = "/disco/agents/%s" // see fortaResolver
 )
 
 func makeRepoPath(repoName string) string {
 	return repositoriesBase + "/" + repoName
 }
 
-func makeManifestLinkPath(repoName string) string {
-	return makeRepoPath(repoName) + manifestLinkPath
+// makeManifestLinkPath returns the path of the link of the manifest with the canonical tag.
+func (disco *Disco) makeManifestLinkPath(repoName string) string {
+	return makeTagLinkPath(repoName, disco.CanonicalTag())
+}
+
+// makeRevisionsPath returns the directory which has a directory for each manifest in the repo.
+func makeRevisionsPath(repoName string) string {
+	return fmt.Sprintf(revisionsPathFormat, repoName)
 }
 
 func makeBlobDirPath(digest string) string {
@@ -36,6 +50,16 @@ func makeDiscoFilePath(repoName string) string {
 	return fmt.Sprintf(discoFilePathFormat, repoName)
 }
 
+func makeOriginFilePath(digest string) string {
+	return fmt.Sprintf(originFilePathFormat, digest)
+}
+
 func makeTagPathFor(repoName, tag string) string {
 	return fmt.Sprintf("%s/%s"+tagPathFormat, repositoriesBase, repoName, tag)
 }
+
+// makeTagLinkPath returns the path of the link of the tag. "link" is a file which contains the
+// digest in sha256:<digest> format.
+func makeTagLinkPath(repoName, tag string) string {
+	return makeTagPathFor(repoName, tag) + "/current/link"
+}
@@ -0,0 +1,100 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/forta-network/disco/utils"
+	"github.com/hashicorp/go-multierror"
+	log "github.com/sirupsen/logrus"
+)
+
+// Pin pins the CID v1 repository and its blobs in all of the IPFS nodes and in the remote
+// pinning services so that the nodes keep hosting the repository. It returns the pinned CIDs.
+func (disco *Disco) Pin(ctx context.Context, repoName string) ([]string, error) {
+	cids, err := disco.repoCids(ctx, repoName)
+	if err != nil {
+		return nil, err
+	}
+	var errs *multierror.Error
+	for i, pinner := range disco.getIpfsClient().NodeClients() {
+		for _, cid := range cids {
+			if err := pinner.Pin(ctx, "/ipfs/"+cid); err != nil {
+				errs = multierror.Append(errs, fmt.Errorf("failed to pin %s in ipfs node #%d: %v", cid, i, err))
+			}
+		}
+		// the remote services are requested once through the first node
+		if i > 0 {
+			continue
+		}
+		for _, service := range disco.cfg.Pinning.RemoteServices {
+			for _, cid := range cids {
+				if err := pinner.PinRemote(ctx, service, "/ipfs/"+cid, repoName); err != nil {
+					errs = multierror.Append(errs, fmt.Errorf("failed to pin %s in remote service %s: %v", cid, service, err))
+				}
+			}
+		}
+	}
+	utils.Logger(ctx).WithFields(log.Fields{
+		"repository": repoName,
+		"cids":       len(cids),
+	}).Info("pinned repository")
+	return cids, errs.ErrorOrNil()
+}
+
+// Unpin removes the pins of the CID v1 repository and its blobs from all of the IPFS nodes and
+// from the remote pinning services. The blobs which are shared with other pinned repositories
+// are unpinned, too.
+func (disco *Disco) Unpin(ctx context.Context, repoName string) ([]string, error) {
+	cids, err := disco.repoCids(ctx, repoName)
+	if err != nil {
+		return nil, err
+	}
+	var errs *multierror.Error
+	for i, pinner := range disco.getIpfsClient().NodeClients() {
+		for _, cid := range cids {
+			if err := pinner.Unpin(ctx, "/ipfs/"+cid); err != nil && !isNotPinnedErr(err) {
+				errs = multierror.Append(errs, fmt.Errorf("failed to unpin %s in ipfs node #%d: %v", cid, i, err))
+			}
+		}
+		if i > 0 {
+			continue
+		}
+		for _, service := range disco.cfg.Pinning.RemoteServices {
+			for _, cid := range cids {
+				if err := pinner.UnpinRemote(ctx, service, cid); err != nil {
+					errs = multierror.Append(errs, fmt.Errorf("failed to unpin %s in remote service %s: %v", cid, service, err))
+				}
+			}
+		}
+	}
+	utils.Logger(ctx).WithFields(log.Fields{
+		"repository": repoName,
+		"cids":       len(cids),
+	}).Info("unpinned repository")
+	return cids, errs.ErrorOrNil()
+}
+
+// repoCids returns the CIDs of the repository and its blobs.
+func (disco *Disco) repoCids(ctx context.Context, repoName string) ([]string, error) {
+	if disco.cfg.CacheOnly {
+		return nil, fmt.Errorf("pinning is not supported in cache-only mode")
+	}
+	if !utils.IsCIDv1(repoName) {
+		return nil, fmt.Errorf("'%s' is %w", repoName, ErrNotCIDName)
+	}
+	file, err := disco.readDiscoFile(ctx, repoName)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read the disco file: %v", err)
+	}
+	cids := []string{repoName}
+	for _, blob := range file.Blobs {
+		cids = append(cids, blob.Cid)
+	}
+	return cids, nil
+}
+
+func isNotPinnedErr(err error) bool {
+	return strings.Contains(err.Error(), "not pinned")
+}
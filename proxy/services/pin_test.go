@@ -0,0 +1,60 @@
+package services
+
+import (
+	"bytes"
+	"context"
+	"io"
+
+	"github.com/forta-network/disco/config"
+	"github.com/forta-network/disco/interfaces"
+	mock_interfaces "github.com/forta-network/disco/interfaces/mocks"
+	"github.com/golang/mock/gomock"
+	ipfsapi "github.com/ipfs/go-ipfs-api"
+)
+
+type testPinner struct {
+	*mock_interfaces.MockIPFSFilesAPI
+	pinned       []string
+	remotePinned []string
+}
+
+func (p *testPinner) Pin(ctx context.Context, path string) error {
+	p.pinned = append(p.pinned, path)
+	return nil
+}
+
+func (p *testPinner) Unpin(ctx context.Context, path string) error {
+	return nil
+}
+
+func (p *testPinner) PinRemote(ctx context.Context, service, path, name string) error {
+	p.remotePinned = append(p.remotePinned, service+":"+path)
+	return nil
+}
+
+func (p *testPinner) UnpinRemote(ctx context.Context, service, cid string) error {
+	return nil
+}
+
+func (s *Suite) TestPin() {
+	// Given that there are two nodes and a remote pinning service
+	s.disco.cfg = &config.Config{Pinning: config.PinningConfig{RemoteServices: []string{"pinata"}}}
+	node1 := &testPinner{MockIPFSFilesAPI: s.ipfsNode}
+	node2 := &testPinner{MockIPFSFilesAPI: s.ipfsNode}
+	s.ipfsClient.EXPECT().NodeClients().Return([]interfaces.IPFSFilesAPI{node1, node2})
+	s.ipfsNode.EXPECT().FilesStat(gomock.Any(), makeDiscoFilePath(testCidv1)).Return(&ipfsapi.FilesStatObject{}, nil)
+	s.ipfsNode.EXPECT().FilesRead(gomock.Any(), makeDiscoFilePath(testCidv1)).
+		Return(io.NopCloser(bytes.NewBufferString(testDiscoFile)), nil)
+
+	// When the repo is pinned
+	cids, err := s.disco.Pin(s.ctx, testCidv1)
+
+	// Then the repo and the blobs should be pinned in both nodes and once in the remote service
+	s.r.NoError(err)
+	s.r.Equal([]string{testCidv1, testManifestCid, testConfigFileCid, testLayerCid}, cids)
+	s.r.Len(node1.pinned, 4)
+	s.r.Len(node2.pinned, 4)
+	s.r.Len(node1.remotePinned, 4)
+	s.r.Empty(node2.remotePinned)
+	s.r.Equal("pinata:/ipfs/"+testCidv1, node1.remotePinned[0])
+}
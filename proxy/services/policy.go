@@ -0,0 +1,175 @@
+package services
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/forta-network/disco/config"
+	"github.com/forta-network/disco/utils"
+)
+
+// PullDeniedError is returned when a pull policy denies a pull.
+type PullDeniedError struct {
+	Message string
+}
+
+// Error implements the error interface.
+func (e *PullDeniedError) Error() string {
+	return e.Message
+}
+
+// The kinds of the pulls which the policies decide on.
+const (
+	PullKindManifest = "manifest"
+	PullKindBlob     = "blob"
+)
+
+// PullRequest contains the details of a manifest or a blob pull which the policies decide on.
+type PullRequest struct {
+	Kind       string `json:"kind"`
+	Repository string `json:"repository"`
+	Reference  string `json:"reference"`
+	Method     string `json:"method"`
+	Host       string `json:"host"`
+	ClientIP   string `json:"clientIP"`
+	UserAgent  string `json:"userAgent"`
+}
+
+// PullPolicy decides whether a manifest or a blob can be pulled. It returns a *PullDeniedError to deny
+// the pull and other errors when it cannot decide.
+type PullPolicy interface {
+	CheckPull(ctx context.Context, req *PullRequest) error
+}
+
+// newPullPolicies creates the pull policies in the config. The endpoint is requested with the
+// HTTP client.
+func newPullPolicies(policyCfg config.PullPolicyConfig, httpClient *http.Client) []PullPolicy {
+	var policies []PullPolicy
+	if len(policyCfg.Deny) > 0 {
+		policies = append(policies, denyListPolicy(policyCfg.Deny))
+	}
+	if len(policyCfg.Endpoint) > 0 {
+		policies = append(policies, &endpointPolicy{
+			endpoint: policyCfg.Endpoint,
+			token:    policyCfg.Token,
+			timeout:  policyCfg.Timeout,
+			failOpen: policyCfg.FailOpen,
+
+			httpClient: httpClient,
+		})
+	}
+	return policies
+}
+
+// AddPullPolicy adds a policy which is evaluated after the policies in the config.
+func (disco *Disco) AddPullPolicy(policy PullPolicy) {
+	disco.pullPolicies = append(disco.pullPolicies, policy)
+}
+
+// CheckPull evaluates the pull policies in order and stops at the first one which denies the
+// pull or fails.
+func (disco *Disco) CheckPull(ctx context.Context, req *PullRequest) error {
+	for _, policy := range disco.pullPolicies {
+		if err := policy.CheckPull(ctx, req); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// denyListPolicy denies the pulls of the listed repositories. The CIDs are matched by their
+// hashes, so that a CID v0 in the list denies its CID v1 repository, too.
+type denyListPolicy []string
+
+func (denied denyListPolicy) CheckPull(ctx context.Context, req *PullRequest) error {
+	for _, repoName := range denied {
+		if utils.CIDEquals(repoName, req.Repository) {
+			return &PullDeniedError{Message: fmt.Sprintf("pulling %s is not allowed", req.Repository)}
+		}
+	}
+	return nil
+}
+
+// endpointPolicy asks an external policy endpoint, e.g. Open Policy Agent, to decide the pulls.
+// The request is sent as the input and the result is either a boolean or an object with the
+// allow and the reason fields.
+type endpointPolicy struct {
+	endpoint string
+	token    string
+	timeout  time.Duration
+	failOpen bool
+
+	httpClient *http.Client
+}
+
+type policyResult struct {
+	Allow  bool   `json:"allow"`
+	Reason string `json:"reason"`
+}
+
+func (policy *endpointPolicy) CheckPull(ctx context.Context, req *PullRequest) error {
+	result, err := policy.evaluate(ctx, req)
+	if err != nil && policy.failOpen {
+		utils.Logger(ctx).WithError(err).WithField("repository", req.Repository).Warn("pull policy failed - allowing the pull")
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("pull policy failed: %v", err)
+	}
+	if result.Allow {
+		return nil
+	}
+	message := result.Reason
+	if len(message) == 0 {
+		message = fmt.Sprintf("pulling %s is not allowed by the policy", req.Repository)
+	}
+	return &PullDeniedError{Message: message}
+}
+
+func (policy *endpointPolicy) evaluate(ctx context.Context, req *PullRequest) (*policyResult, error) {
+	b, err := json.Marshal(map[string]interface{}{"input": req})
+	if err != nil {
+		return nil, err
+	}
+	ctx, cancel := context.WithTimeout(ctx, policy.timeout)
+	defer cancel()
+	httpReq, err := http.NewRequestWithContext(ctx, http.MethodPost, policy.endpoint, bytes.NewReader(b))
+	if err != nil {
+		return nil, err
+	}
+	httpReq.Header.Set("Content-Type", "application/json")
+	if len(policy.token) > 0 {
+		httpReq.Header.Set("Authorization", "Bearer "+policy.token)
+	}
+	resp, err := policy.httpClient.Do(httpReq)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("endpoint responded with status %d", resp.StatusCode)
+	}
+	var body struct {
+		Result json.RawMessage `json:"result"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&body); err != nil {
+		return nil, fmt.Errorf("failed to decode the response: %v", err)
+	}
+	// an undefined result denies, like an OPA rule without a default
+	var result policyResult
+	var allow bool
+	switch {
+	case len(body.Result) == 0:
+	case json.Unmarshal(body.Result, &allow) == nil:
+		result.Allow = allow
+	default:
+		if err := json.Unmarshal(body.Result, &result); err != nil {
+			return nil, fmt.Errorf("invalid result: %v", err)
+		}
+	}
+	return &result, nil
+}
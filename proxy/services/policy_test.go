@@ -0,0 +1,62 @@
+package services
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"time"
+
+	"github.com/forta-network/disco/config"
+)
+
+func (s *Suite) TestCheckPull_DenyList() {
+	s.disco.pullPolicies = newPullPolicies(config.PullPolicyConfig{Deny: []string{testCidv1, "myrepo"}}, s.disco.httpClient)
+
+	s.r.IsType(&PullDeniedError{}, s.disco.CheckPull(s.ctx, &PullRequest{Repository: testCidv1}))
+	s.r.IsType(&PullDeniedError{}, s.disco.CheckPull(s.ctx, &PullRequest{Repository: "myrepo"}))
+	s.r.NoError(s.disco.CheckPull(s.ctx, &PullRequest{Repository: "otherrepo"}))
+}
+
+func (s *Suite) TestCheckPull_Endpoint() {
+	// Given a policy endpoint with TLS which answers with the results for the repositories
+	results := map[string]string{
+		"allowed":   `{"result": true}`,
+		"denied":    `{"result": {"allow": false, "reason": "denied in this region"}}`,
+		"undefined": `{}`,
+	}
+	var input PullRequest
+	endpoint := httptest.NewTLSServer(http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+		s.r.Equal("Bearer secret", r.Header.Get("Authorization"))
+		var body struct {
+			Input PullRequest `json:"input"`
+		}
+		s.r.NoError(json.NewDecoder(r.Body).Decode(&body))
+		input = body.Input
+		result, ok := results[body.Input.Repository]
+		if !ok {
+			rw.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		_, _ = rw.Write([]byte(result))
+	}))
+	defer endpoint.Close()
+	policyCfg := config.PullPolicyConfig{Endpoint: endpoint.URL, Token: "secret", Timeout: time.Second}
+	s.disco.pullPolicies = newPullPolicies(policyCfg, endpoint.Client())
+
+	// Then the pulls should be decided by the results
+	s.r.NoError(s.disco.CheckPull(s.ctx, &PullRequest{Repository: "allowed", ClientIP: "10.0.0.1"}))
+	s.r.Equal("10.0.0.1", input.ClientIP)
+	err := s.disco.CheckPull(s.ctx, &PullRequest{Repository: "denied"})
+	s.r.IsType(&PullDeniedError{}, err)
+	s.r.Equal("denied in this region", err.Error())
+	s.r.IsType(&PullDeniedError{}, s.disco.CheckPull(s.ctx, &PullRequest{Repository: "undefined"}))
+
+	// And the failures should deny unless the policy fails open
+	err = s.disco.CheckPull(s.ctx, &PullRequest{Repository: "failing"})
+	s.r.Error(err)
+	_, denied := err.(*PullDeniedError)
+	s.r.False(denied)
+	policyCfg.FailOpen = true
+	s.disco.pullPolicies = newPullPolicies(policyCfg, endpoint.Client())
+	s.r.NoError(s.disco.CheckPull(s.ctx, &PullRequest{Repository: "failing"}))
+}
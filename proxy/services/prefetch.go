@@ -0,0 +1,136 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	storagedriver "github.com/distribution/distribution/v3/registry/storage/driver"
+	"github.com/forta-network/disco/drivers/multidriver"
+	"github.com/forta-network/disco/utils"
+	log "github.com/sirupsen/logrus"
+)
+
+// PrefetchBlobs copies the config and the layers of the manifest to the IPFS node and the cache
+// in the background, so that the blob requests which follow the manifest request hit the warm
+// storage. The reference can be a tag or a digest.
+func (disco *Disco) PrefetchBlobs(repoName, reference string) {
+	if disco.cfg.NoPrefetch {
+		return
+	}
+	multiDriver, ok := multidriver.Is(disco.getDriver())
+	if !ok {
+		return
+	}
+	go func() {
+		ctx, cancel := disco.operationContext(context.Background())
+		defer cancel()
+		disco.prefetchBlobs(ctx, multiDriver, repoName, reference)
+	}()
+}
+
+func (disco *Disco) prefetchBlobs(ctx context.Context, driver multidriver.MultiDriver, repoName, reference string) {
+	logger := log.WithFields(log.Fields{
+		"repository": repoName,
+		"reference":  reference,
+	})
+
+	manifestDigest := strings.TrimPrefix(reference, "sha256:")
+	if !utils.IsDigestHex(manifestDigest) {
+		b, err := driver.GetContent(ctx, makeTagLinkPath(repoName, reference))
+		if err != nil {
+			logger.WithError(err).Debug("failed to read the tag link - not prefetching")
+			return
+		}
+		manifestDigest = strings.TrimPrefix(strings.TrimSpace(string(b)), "sha256:")
+	}
+	// the clients usually request the same manifest more than once
+	if _, prefetching := disco.prefetching.LoadOrStore(manifestDigest, struct{}{}); prefetching {
+		return
+	}
+	defer disco.prefetching.Delete(manifestDigest)
+
+	b, err := driver.GetContent(ctx, makeBlobPath(manifestDigest))
+	if err != nil {
+		logger.WithError(err).Debug("failed to read the manifest - not prefetching")
+		return
+	}
+	var manifest imageManifest
+	if err := json.Unmarshal(b, &manifest); err != nil {
+		logger.WithError(err).Debug("failed to decode the manifest - not prefetching")
+		return
+	}
+	var digests []string
+	if len(manifest.Config.Digest) > 0 {
+		digests = append(digests, manifest.Config.Digest)
+	}
+	for _, layer := range manifest.Layers {
+		digests = append(digests, layer.Digest)
+	}
+	for _, digest := range digests {
+		blobPath := makeBlobPath(strings.TrimPrefix(digest, "sha256:"))
+		if _, err := driver.ReplicateInPrimary(ctx, blobPath); err != nil && !isPathNotFound(err) {
+			logger.WithError(err).WithField("blob", digest).Warn("failed to prefetch blob in primary")
+		}
+		if _, err := driver.ReplicateInSecondary(ctx, blobPath); err != nil && !isPathNotFound(err) {
+			logger.WithError(err).WithField("blob", digest).Warn("failed to prefetch blob in secondary")
+		}
+	}
+	logger.WithField("blobs", len(digests)).Debug("prefetched blobs")
+}
+
+// MaxPrefetchRepos limits the repositories which can be prefetched with one request.
+const MaxPrefetchRepos = 1000
+
+// PrefetchRepos clones the CID v1 repositories and replicates them in the cache in the
+// background, so that the fleets can warm the storage before they pull the images, e.g. ahead of
+// an upgrade. The clones wait in the clone queue like the ones of the pulls. It returns the
+// repositories without the duplicates after checking their names.
+func (disco *Disco) PrefetchRepos(repoNames []string) ([]string, error) {
+	if len(repoNames) > MaxPrefetchRepos {
+		return nil, fmt.Errorf("%w: more than %d repositories", ErrInvalidPrefetch, MaxPrefetchRepos)
+	}
+	var accepted []string
+	seen := make(map[string]bool)
+	for _, repoName := range repoNames {
+		if !utils.IsCIDv1(repoName) {
+			return nil, fmt.Errorf("%w: %s", ErrNotCIDName, repoName)
+		}
+		if !seen[repoName] {
+			seen[repoName] = true
+			accepted = append(accepted, repoName)
+		}
+	}
+	go func() {
+		for _, repoName := range accepted {
+			disco.prefetchRepo(repoName)
+		}
+	}()
+	return accepted, nil
+}
+
+func (disco *Disco) prefetchRepo(repoName string) {
+	logger := log.WithField("repository", repoName)
+	if err := disco.warmRepo(withBackgroundPriority(context.Background()), repoName); err != nil {
+		logger.WithError(err).Warn("failed to prefetch the repository")
+		return
+	}
+	logger.Info("prefetched repository")
+}
+
+// warmRepo clones the CID v1 repository and replicates it in the cache, if there is one.
+func (disco *Disco) warmRepo(ctx context.Context, repoName string) error {
+	if _, ok := multidriver.Is(disco.getDriver()); ok {
+		_, err := disco.Replicate(ctx, repoName, ReplicateToBoth)
+		return err
+	}
+	ctx, cancel := disco.operationContext(ctx)
+	defer cancel()
+	return disco.cloneFullRepo(ctx, repoName)
+}
+
+func isPathNotFound(err error) bool {
+	_, ok := err.(storagedriver.PathNotFoundError)
+	return ok
+}
@@ -0,0 +1,50 @@
+package services
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+
+	"github.com/distribution/distribution/v3/registry/storage/driver/inmemory"
+	"github.com/forta-network/disco/drivers/multidriver"
+)
+
+func (s *Suite) TestPrefetchBlobs() {
+	// Given that a tagged image is only in the secondary store
+	primary := inmemory.New()
+	secondary := inmemory.New()
+	configDigest := sha256Hex("config")
+	layerDigest := sha256Hex("layer")
+	manifest := fmt.Sprintf(`{"config":{"digest":"sha256:%s"},"layers":[{"digest":"sha256:%s"}]}`, configDigest, layerDigest)
+	manifestDigest := sha256Hex(manifest)
+	s.r.NoError(secondary.PutContent(s.ctx, makeTagLinkPath("myrepo", "v1"), []byte("sha256:"+manifestDigest)))
+	s.r.NoError(secondary.PutContent(s.ctx, makeBlobPath(manifestDigest), []byte(manifest)))
+	s.r.NoError(secondary.PutContent(s.ctx, makeBlobPath(configDigest), []byte("config")))
+	s.r.NoError(secondary.PutContent(s.ctx, makeBlobPath(layerDigest), []byte("layer")))
+	driver, ok := multidriver.Is(multidriver.New(nil, primary, secondary))
+	s.r.True(ok)
+
+	// When the blobs of the tag are prefetched
+	s.disco.prefetchBlobs(s.ctx, driver, "myrepo", "v1")
+
+	// Then the blobs should be copied to the primary store
+	b, err := primary.GetContent(s.ctx, makeBlobPath(configDigest))
+	s.r.NoError(err)
+	s.r.Equal("config", string(b))
+	b, err = primary.GetContent(s.ctx, makeBlobPath(layerDigest))
+	s.r.NoError(err)
+	s.r.Equal("layer", string(b))
+}
+
+func (s *Suite) TestPrefetchRepos_Invalid() {
+	_, err := s.disco.PrefetchRepos([]string{testCidv1, "myrepo"})
+	s.r.ErrorIs(err, ErrNotCIDName)
+
+	_, err = s.disco.PrefetchRepos(make([]string, MaxPrefetchRepos+1))
+	s.r.ErrorIs(err, ErrInvalidPrefetch)
+}
+
+func sha256Hex(content string) string {
+	sum := sha256.Sum256([]byte(content))
+	return hex.EncodeToString(sum[:])
+}
@@ -0,0 +1,89 @@
+package services
+
+import (
+	"sort"
+	"sync/atomic"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// CloneProgress is the progress of a repository which is being cloned from the IPFS network.
+type CloneProgress struct {
+	Repository  string    `json:"repository"`
+	StartedAt   time.Time `json:"startedAt"`
+	BlobsTotal  int       `json:"blobsTotal"`
+	BlobsDone   int64     `json:"blobsDone"`
+	BytesCopied int64     `json:"bytesCopied"`
+}
+
+// cloneProgress tracks the progress of a clone while the blobs are copied.
+type cloneProgress struct {
+	repoName    string
+	startedAt   time.Time
+	blobsTotal  int
+	blobsDone   int64
+	bytesCopied int64
+}
+
+// startCloneProgress starts tracking the clone of the repository, which has some of its blobs
+// already in the IPFS nodes.
+func (disco *Disco) startCloneProgress(repoName string, blobsTotal, blobsDone int) *cloneProgress {
+	progress := &cloneProgress{
+		repoName:   repoName,
+		startedAt:  time.Now(),
+		blobsTotal: blobsTotal,
+		blobsDone:  int64(blobsDone),
+	}
+	disco.cloning.Store(repoName, progress)
+	log.WithFields(log.Fields{
+		"repository": repoName,
+		"blobs":      blobsTotal,
+		"missing":    blobsTotal - blobsDone,
+	}).Info("started copying the blobs from the network")
+	return progress
+}
+
+// blobCopied records a copied blob and logs the progress with the compression of the blob, if
+// it is a layer.
+func (progress *cloneProgress) blobCopied(size int64, mediaType string) {
+	blobsDone := atomic.AddInt64(&progress.blobsDone, 1)
+	bytesCopied := atomic.AddInt64(&progress.bytesCopied, size)
+	fields := log.Fields{
+		"repository": progress.repoName,
+		"blobs":      progress.blobsTotal,
+		"done":       blobsDone,
+		"bytes":      bytesCopied,
+		"elapsed":    time.Since(progress.startedAt).String(),
+	}
+	if compression := layerCompression(mediaType); len(compression) > 0 {
+		fields["compression"] = compression
+	}
+	log.WithFields(fields).Info("copied a blob from the network")
+}
+
+// finishCloneProgress stops tracking the clone of the repository.
+func (disco *Disco) finishCloneProgress(progress *cloneProgress) {
+	disco.cloning.Delete(progress.repoName)
+}
+
+// CloneProgress returns the progress of the repositories which are being cloned, in the order
+// of their start times.
+func (disco *Disco) CloneProgress() []*CloneProgress {
+	clones := []*CloneProgress{}
+	disco.cloning.Range(func(key, value interface{}) bool {
+		progress := value.(*cloneProgress)
+		clones = append(clones, &CloneProgress{
+			Repository:  progress.repoName,
+			StartedAt:   progress.startedAt,
+			BlobsTotal:  progress.blobsTotal,
+			BlobsDone:   atomic.LoadInt64(&progress.blobsDone),
+			BytesCopied: atomic.LoadInt64(&progress.bytesCopied),
+		})
+		return true
+	})
+	sort.Slice(clones, func(i, j int) bool {
+		return clones[i].StartedAt.Before(clones[j].StartedAt)
+	})
+	return clones
+}
@@ -0,0 +1,16 @@
+package services
+
+func (s *Suite) TestCloneProgress() {
+	progress := s.disco.startCloneProgress(testCidv1, 3, 1)
+	progress.blobCopied(10, mediaTypeOCILayerZstd)
+
+	clones := s.disco.CloneProgress()
+	s.r.Len(clones, 1)
+	s.r.Equal(testCidv1, clones[0].Repository)
+	s.r.Equal(3, clones[0].BlobsTotal)
+	s.r.Equal(int64(2), clones[0].BlobsDone)
+	s.r.Equal(int64(10), clones[0].BytesCopied)
+
+	s.disco.finishCloneProgress(progress)
+	s.r.Empty(s.disco.CloneProgress())
+}
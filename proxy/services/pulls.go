@@ -0,0 +1,129 @@
+package services
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// pullIndexResolution is how often the last pull time of a repository is updated in the index.
+const pullIndexResolution = time.Minute
+
+// pullIndex records the last pull times of the repositories in a file or in the shared store.
+type pullIndex struct {
+	path  string
+	store sharedStore
+
+	mu    sync.Mutex
+	times map[string]time.Time
+}
+
+func newPullIndex(path string, store sharedStore) *pullIndex {
+	idx := &pullIndex{
+		path:  path,
+		store: store,
+		times: make(map[string]time.Time),
+	}
+	// the times of the shared store are only cached to limit the writes
+	if store != nil {
+		return idx
+	}
+	times, err := readPullIndex(path)
+	if err != nil {
+		log.WithError(err).Warn("failed to read the pull index - starting with an empty one")
+		return idx
+	}
+	idx.times = times
+	return idx
+}
+
+// readPullIndex reads the last pull times from the index file.
+func readPullIndex(path string) (map[string]time.Time, error) {
+	times := make(map[string]time.Time)
+	b, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return times, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read the pull index: %v", err)
+	}
+	if err := json.Unmarshal(b, &times); err != nil {
+		return nil, fmt.Errorf("failed to decode the pull index: %v", err)
+	}
+	return times, nil
+}
+
+func (idx *pullIndex) record(repoName string) {
+	if idx == nil {
+		return
+	}
+	idx.mu.Lock()
+	defer idx.mu.Unlock()
+	now := time.Now().UTC()
+	if now.Sub(idx.times[repoName]) < pullIndexResolution {
+		return
+	}
+	idx.times[repoName] = now
+	if idx.store != nil {
+		storeSetTime(idx.store, storeKeyPulls+repoName, now)
+		return
+	}
+	if err := idx.save(); err != nil {
+		log.WithError(err).Error("failed to save the pull index")
+	}
+}
+
+// lastPull returns the last pull time of the repository.
+func (idx *pullIndex) lastPull(repoName string) (time.Time, bool) {
+	if idx == nil {
+		return time.Time{}, false
+	}
+	if idx.store != nil {
+		return storeGetTime(idx.store, storeKeyPulls+repoName)
+	}
+	idx.mu.Lock()
+	defer idx.mu.Unlock()
+	t, ok := idx.times[repoName]
+	return t, ok
+}
+
+// remove forgets the repository, e.g. after it is deleted.
+func (idx *pullIndex) remove(repoName string) {
+	if idx == nil {
+		return
+	}
+	idx.mu.Lock()
+	defer idx.mu.Unlock()
+	if idx.store != nil {
+		delete(idx.times, repoName)
+		storeDelete(idx.store, storeKeyPulls+repoName)
+		return
+	}
+	if _, ok := idx.times[repoName]; !ok {
+		return
+	}
+	delete(idx.times, repoName)
+	if err := idx.save(); err != nil {
+		log.WithError(err).Error("failed to save the pull index")
+	}
+}
+
+func (idx *pullIndex) save() error {
+	b, err := json.Marshal(idx.times)
+	if err != nil {
+		return err
+	}
+	tmpPath := idx.path + ".tmp"
+	if err := os.MkdirAll(filepath.Dir(idx.path), 0755); err != nil {
+		return err
+	}
+	if err := os.WriteFile(tmpPath, b, 0644); err != nil {
+		return err
+	}
+	return os.Rename(tmpPath, idx.path)
+}
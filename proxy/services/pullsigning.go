@@ -0,0 +1,117 @@
+package services
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"strings"
+)
+
+// cosignSignatureAnnotation carries the base64-encoded signature of the payload layer in the
+// cosign signature manifests.
+const cosignSignatureAnnotation = "dev.cosignproject.cosign/signature"
+
+// PullSigningEnabled tells if the manifests of the CID repositories should be signed to be served.
+func (disco *Disco) PullSigningEnabled() bool {
+	return len(disco.cfg.PullKeys) > 0
+}
+
+// VerifyPullSignature checks that the manifest of a CID repository is signed with one of the
+// trusted keys, either with the signature annotation of the signed pushes or with a cosign
+// signature in the cosign repository. The manifest should be in the registry already.
+func (disco *Disco) VerifyPullSignature(ctx context.Context, repoName, reference string) error {
+	if !disco.PullSigningEnabled() || !disco.IsOnlyPullable(repoName) {
+		return nil
+	}
+	manifestDigest, err := disco.ResolveManifestDigest(ctx, repoName, reference)
+	if err != nil {
+		return err
+	}
+	manifest, err := disco.getDriver().GetContent(ctx, makeBlobPath(manifestDigest))
+	if err != nil {
+		return fmt.Errorf("failed to read the manifest: %v", err)
+	}
+	if disco.annotationSigned(manifest) {
+		return nil
+	}
+	if len(disco.cfg.PullSigning.CosignRepository) > 0 {
+		signed, err := disco.cosignSigned(ctx, manifestDigest)
+		if err != nil {
+			return fmt.Errorf("failed to check the cosign signature: %v", err)
+		}
+		if signed {
+			return nil
+		}
+	}
+	return &PullDeniedError{Message: fmt.Sprintf("%s is not signed by a trusted key", repoName)}
+}
+
+// annotationSigned checks the signature of the unsigned manifest digest in the manifest
+// annotations, like the signed pushes.
+func (disco *Disco) annotationSigned(manifest []byte) bool {
+	message, encodedSignature, err := annotationSignature(manifest)
+	if err != nil || len(encodedSignature) == 0 {
+		return false
+	}
+	signature, err := base64.StdEncoding.DecodeString(encodedSignature)
+	if err != nil || len(signature) == 0 {
+		return false
+	}
+	return signedBy(disco.cfg.PullKeys, message, signature)
+}
+
+// cosignSigned looks for the cosign signature manifest of the digest in the cosign repository and
+// checks the signatures of its payloads which refer to the digest.
+func (disco *Disco) cosignSigned(ctx context.Context, manifestDigest string) (bool, error) {
+	driver := disco.getDriver()
+	signatureTag := fmt.Sprintf("sha256-%s.sig", manifestDigest)
+	link, err := driver.GetContent(ctx, makeTagLinkPath(disco.cfg.PullSigning.CosignRepository, signatureTag))
+	if isPathNotFound(err) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	b, err := driver.GetContent(ctx, makeBlobPath(strings.TrimPrefix(strings.TrimSpace(string(link)), "sha256:")))
+	if err != nil {
+		return false, fmt.Errorf("failed to read the signature manifest: %v", err)
+	}
+	var signatureManifest struct {
+		Layers []struct {
+			Digest      string            `json:"digest"`
+			Annotations map[string]string `json:"annotations"`
+		} `json:"layers"`
+	}
+	if err := json.Unmarshal(b, &signatureManifest); err != nil {
+		return false, fmt.Errorf("failed to decode the signature manifest: %v", err)
+	}
+	for _, layer := range signatureManifest.Layers {
+		signature, err := base64.StdEncoding.DecodeString(layer.Annotations[cosignSignatureAnnotation])
+		if err != nil || len(signature) == 0 || !strings.HasPrefix(layer.Digest, "sha256:") {
+			continue
+		}
+		payload, err := driver.GetContent(ctx, makeBlobPath(layer.Digest[7:]))
+		if err != nil {
+			return false, fmt.Errorf("failed to read the signature payload: %v", err)
+		}
+		var simpleSigning struct {
+			Critical struct {
+				Image struct {
+					DockerManifestDigest string `json:"docker-manifest-digest"`
+				} `json:"image"`
+			} `json:"critical"`
+		}
+		if err := json.Unmarshal(payload, &simpleSigning); err != nil {
+			continue
+		}
+		// the signature of another image can be copied next to this one
+		if simpleSigning.Critical.Image.DockerManifestDigest != "sha256:"+manifestDigest {
+			continue
+		}
+		if signedBy(disco.cfg.PullKeys, payload, signature) {
+			return true, nil
+		}
+	}
+	return false, nil
+}
@@ -0,0 +1,85 @@
+package services
+
+import (
+	"crypto"
+	"crypto/ed25519"
+	"crypto/rand"
+	"encoding/base64"
+	"fmt"
+
+	storagedriver "github.com/distribution/distribution/v3/registry/storage/driver"
+	"github.com/distribution/distribution/v3/registry/storage/driver/inmemory"
+	"github.com/forta-network/disco/config"
+)
+
+func (s *Suite) TestVerifyPullSignature() {
+	// Given a trusted key and an unsigned manifest in a cid repo
+	pub, priv, err := ed25519.GenerateKey(rand.Reader)
+	s.r.NoError(err)
+	s.disco.cfg = &config.Config{
+		PullKeys:    []crypto.PublicKey{pub},
+		PullSigning: config.PullSigningConfig{CosignRepository: "signatures"},
+	}
+	driver := inmemory.New()
+	s.disco.getDriver = func() storagedriver.StorageDriver {
+		return driver
+	}
+	s.r.NoError(driver.PutContent(s.ctx, makeBlobPath(testManifestDigest), []byte(testManifest)))
+	s.r.NoError(driver.PutContent(s.ctx, makeTagLinkPath(testCidv1, "latest"), []byte("sha256:"+testManifestDigest)))
+
+	// Then the pulls of the cid repo should be denied
+	s.r.IsType(&PullDeniedError{}, s.disco.VerifyPullSignature(s.ctx, testCidv1, "latest"))
+	// And the other repos should not be checked
+	s.r.NoError(s.disco.VerifyPullSignature(s.ctx, "myrepo", "latest"))
+
+	// When the manifest is signed with cosign
+	payload := []byte(fmt.Sprintf(`{"critical":{"identity":{"docker-reference":"disco.forta.network/signatures"},"image":{"docker-manifest-digest":"sha256:%s"},"type":"cosign container image signature"},"optional":null}`, testManifestDigest))
+	payloadDigest := sha256Hex(string(payload))
+	s.r.NoError(driver.PutContent(s.ctx, makeBlobPath(payloadDigest), payload))
+	signatureManifest := []byte(fmt.Sprintf(`{"schemaVersion":2,"layers":[{"digest":"sha256:%s","annotations":{"%s":"%s"}}]}`,
+		payloadDigest, cosignSignatureAnnotation, base64.StdEncoding.EncodeToString(ed25519.Sign(priv, payload))))
+	signatureManifestDigest := sha256Hex(string(signatureManifest))
+	s.r.NoError(driver.PutContent(s.ctx, makeBlobPath(signatureManifestDigest), signatureManifest))
+	signatureTag := fmt.Sprintf("sha256-%s.sig", testManifestDigest)
+	s.r.NoError(driver.PutContent(s.ctx, makeTagLinkPath("signatures", signatureTag), []byte("sha256:"+signatureManifestDigest)))
+
+	// Then the pulls should be allowed
+	s.r.NoError(s.disco.VerifyPullSignature(s.ctx, testCidv1, "latest"))
+	s.r.NoError(s.disco.VerifyPullSignature(s.ctx, testCidv1, "sha256:"+testManifestDigest))
+
+	// And the signatures from untrusted keys should be denied
+	otherPub, _, err := ed25519.GenerateKey(rand.Reader)
+	s.r.NoError(err)
+	s.disco.cfg.PullKeys = []crypto.PublicKey{otherPub}
+	s.r.IsType(&PullDeniedError{}, s.disco.VerifyPullSignature(s.ctx, testCidv1, "latest"))
+}
+
+func (s *Suite) TestVerifyPullSignature_Annotation() {
+	// Given a trusted key and the signature of an unsigned manifest
+	pub, priv, err := ed25519.GenerateKey(rand.Reader)
+	s.r.NoError(err)
+	s.disco.cfg = &config.Config{PullKeys: []crypto.PublicKey{pub}}
+	driver := inmemory.New()
+	s.disco.getDriver = func() storagedriver.StorageDriver {
+		return driver
+	}
+	unsigned := fmt.Sprintf(`{"config":{"digest":"sha256:%s"},"layers":[{"digest":"sha256:%s"}]}`, testConfigDigest, testLayerDigest)
+	signature := base64.StdEncoding.EncodeToString(ed25519.Sign(priv, []byte("sha256:"+sha256Hex(unsigned))))
+	putManifest := func(layerDigest string) {
+		manifest := fmt.Sprintf(`{"config":{"digest":"sha256:%s"},"layers":[{"digest":"sha256:%s"}],"annotations":{"%s":"%s"}}`,
+			testConfigDigest, layerDigest, SignatureAnnotation, signature)
+		manifestDigest := sha256Hex(manifest)
+		s.r.NoError(driver.PutContent(s.ctx, makeBlobPath(manifestDigest), []byte(manifest)))
+		s.r.NoError(driver.PutContent(s.ctx, makeTagLinkPath(testCidv1, "latest"), []byte("sha256:"+manifestDigest)))
+	}
+
+	// When the signed manifest is in a cid repo
+	putManifest(testLayerDigest)
+	// Then the pulls should be allowed
+	s.r.NoError(s.disco.VerifyPullSignature(s.ctx, testCidv1, "latest"))
+
+	// When the signature is copied to a manifest with other layers
+	putManifest(testManifestDigest)
+	// Then the pulls should be denied
+	s.r.IsType(&PullDeniedError{}, s.disco.VerifyPullSignature(s.ctx, testCidv1, "latest"))
+}
@@ -0,0 +1,143 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"path"
+
+	storagedriver "github.com/distribution/distribution/v3/registry/storage/driver"
+	"github.com/forta-network/disco/utils"
+)
+
+// Referrer is a manifest which is attached to another manifest with its subject, e.g. a
+// signature, an attestation or an SBOM.
+type Referrer struct {
+	MediaType    string            `json:"mediaType"`
+	ArtifactType string            `json:"artifactType,omitempty"`
+	Digest       string            `json:"digest"`
+	Size         int64             `json:"size"`
+	Annotations  map[string]string `json:"annotations,omitempty"`
+}
+
+// ReferrersIndex lists the referrers of a manifest as an image index, as the OCI referrers API
+// responds with.
+type ReferrersIndex struct {
+	SchemaVersion int         `json:"schemaVersion"`
+	MediaType     string      `json:"mediaType"`
+	Manifests     []*Referrer `json:"manifests"`
+}
+
+// Referrers returns the manifests in the repository which refer to the manifest with the
+// digest, only with the artifact type if it is not empty. The global repositories are cloned
+// before their manifests are read.
+func (disco *Disco) Referrers(ctx context.Context, repoName, digest, artifactType string) (*ReferrersIndex, error) {
+	if !isSHA256Digest(digest) {
+		return nil, fmt.Errorf("%w: '%s'", ErrInvalidDigest, digest)
+	}
+	if disco.IsOnlyPullable(repoName) {
+		if err := disco.CloneGlobalRepo(ctx, repoName); err != nil {
+			return nil, err
+		}
+	}
+	referrers, err := disco.findReferrers(ctx, disco.getDriver(), repoName, map[string]bool{digest[7:]: true})
+	if err != nil {
+		return nil, err
+	}
+	index := &ReferrersIndex{
+		SchemaVersion: 2,
+		MediaType:     mediaTypeOCIIndex,
+		Manifests:     []*Referrer{},
+	}
+	for _, referrer := range referrers {
+		referrerType := referrer.Manifest.artifactType()
+		if len(artifactType) > 0 && referrerType != artifactType {
+			continue
+		}
+		index.Manifests = append(index.Manifests, &Referrer{
+			MediaType:    referrer.Manifest.mediaType(),
+			ArtifactType: referrerType,
+			Digest:       "sha256:" + referrer.Digest,
+			Size:         referrer.Size,
+			Annotations:  referrer.Manifest.Annotations,
+		})
+	}
+	return index, nil
+}
+
+// artifactType returns the artifact type of the manifest, which is the media type of the config
+// if it is not set.
+func (manifest *imageManifest) artifactType() string {
+	if len(manifest.ArtifactType) > 0 {
+		return manifest.ArtifactType
+	}
+	return manifest.Config.MediaType
+}
+
+// findReferrers reads the manifests of the repository and returns the ones which have one of the
+// manifests with the digests as their subject.
+func (disco *Disco) findReferrers(
+	ctx context.Context, driver storagedriver.StorageDriver, repoName string, subjects map[string]bool,
+) ([]*childManifest, error) {
+	revisions, err := driver.List(ctx, makeRevisionsPath(repoName))
+	if _, ok := err.(storagedriver.PathNotFoundError); ok {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to list the manifests: %v", err)
+	}
+	var referrers []*childManifest
+	for _, revision := range revisions {
+		revisionDigest := path.Base(revision)
+		if subjects[revisionDigest] || !utils.IsDigestHex(revisionDigest) {
+			continue
+		}
+		b, err := driver.GetContent(ctx, makeBlobPath(revisionDigest))
+		if err != nil {
+			return nil, fmt.Errorf("failed to read manifest sha256:%s: %v", revisionDigest, err)
+		}
+		var manifest imageManifest
+		if err := json.Unmarshal(b, &manifest); err != nil {
+			// the registry validates the manifests, so this is a blob with another format
+			continue
+		}
+		if manifest.Subject == nil || !isSHA256Digest(manifest.Subject.Digest) || !subjects[manifest.Subject.Digest[7:]] {
+			continue
+		}
+		referrers = append(referrers, &childManifest{
+			Digest:   revisionDigest,
+			Size:     int64(len(b)),
+			Manifest: &manifest,
+		})
+	}
+	return referrers, nil
+}
+
+// attachedArtifacts returns the valid referrers of the manifest or the manifests which it lists
+// from the pushed repository, so that their blobs can be made global with the image.
+func (disco *Disco) attachedArtifacts(
+	ctx context.Context, driver storagedriver.StorageDriver, repoName, manifestDigest string, children []*childManifest,
+) ([]*childManifest, error) {
+	subjects := map[string]bool{manifestDigest: true}
+	for _, child := range children {
+		subjects[child.Digest] = true
+	}
+	referrers, err := disco.findReferrers(ctx, driver, repoName, subjects)
+	if err != nil {
+		return nil, fmt.Errorf("failed to find the referrers: %v", err)
+	}
+	var artifacts []*childManifest
+	for _, referrer := range referrers {
+		err := referrer.Manifest.validate()
+		if err == nil && referrer.Manifest.isIndex() {
+			err = fmt.Errorf("%w: attached index", ErrInvalidManifest)
+		}
+		if err != nil {
+			utils.Logger(ctx).WithError(err).WithField("referrer", referrer.Digest).
+				Warn("skipping the attached artifact which cannot be made global")
+			continue
+		}
+		artifacts = append(artifacts, referrer)
+	}
+	return artifacts, nil
+}
@@ -0,0 +1,73 @@
+package services
+
+import (
+	"strings"
+
+	"github.com/golang/mock/gomock"
+)
+
+const (
+	testSignatureDigest   = "3333333333333333333333333333333333333333333333333333333333333333"
+	testUnrelatedDigest   = "4444444444444444444444444444444444444444444444444444444444444444"
+	testSignatureType     = "application/vnd.dev.cosign.artifact.sig.v1+json"
+	testSignatureManifest = `{"schemaVersion":2,"mediaType":"application/vnd.oci.image.manifest.v1+json",` +
+		`"artifactType":"` + testSignatureType + `",` +
+		`"config":{"mediaType":"application/vnd.oci.empty.v1+json","digest":"sha256:` + testConfigDigest + `","size":2},` +
+		`"layers":[{"mediaType":"application/vnd.dev.cosign.simplesigning.v1+json","digest":"sha256:` + testLayerDigest + `"}],` +
+		`"subject":{"digest":"sha256:` + testManifestDigest + `"},"annotations":{"signed":"true"}}`
+)
+
+func (s *Suite) expectRevisions() {
+	revisionsPath := makeRevisionsPath("myrepo")
+	s.driver.EXPECT().List(gomock.Any(), revisionsPath).Return([]string{
+		revisionsPath + "/" + testManifestDigest,
+		revisionsPath + "/" + testSignatureDigest,
+		revisionsPath + "/" + testUnrelatedDigest,
+	}, nil)
+	s.driver.EXPECT().GetContent(gomock.Any(), makeBlobPath(testSignatureDigest)).Return([]byte(testSignatureManifest), nil)
+	s.driver.EXPECT().GetContent(gomock.Any(), makeBlobPath(testUnrelatedDigest)).
+		Return([]byte(strings.Replace(testSignatureManifest, testManifestDigest, testUnrelatedDigest, 1)), nil)
+}
+
+func (s *Suite) TestReferrers() {
+	// Given that a signature is attached to the manifest
+	s.expectRevisions()
+
+	// When the referrers of the manifest are requested
+	index, err := s.disco.Referrers(s.ctx, "myrepo", "sha256:"+testManifestDigest, "")
+	s.r.NoError(err)
+
+	// Then the signature should be listed
+	s.r.Equal(mediaTypeOCIIndex, index.MediaType)
+	s.r.Len(index.Manifests, 1)
+	s.r.Equal(&Referrer{
+		MediaType:    mediaTypeOCIManifest,
+		ArtifactType: testSignatureType,
+		Digest:       "sha256:" + testSignatureDigest,
+		Size:         int64(len(testSignatureManifest)),
+		Annotations:  map[string]string{"signed": "true"},
+	}, index.Manifests[0])
+
+	// And the other artifact types should be filtered out
+	s.expectRevisions()
+	index, err = s.disco.Referrers(s.ctx, "myrepo", "sha256:"+testManifestDigest, "application/spdx+json")
+	s.r.NoError(err)
+	s.r.Empty(index.Manifests)
+
+	// And the invalid digests should be rejected
+	_, err = s.disco.Referrers(s.ctx, "myrepo", "latest", "")
+	s.r.ErrorIs(err, ErrInvalidDigest)
+}
+
+func (s *Suite) TestAttachedArtifacts() {
+	// Given that a signature is attached to the pushed manifest
+	s.expectRevisions()
+
+	// When the artifacts are collected while making the repo global
+	artifacts, err := s.disco.attachedArtifacts(s.ctx, s.driver, "myrepo", testManifestDigest, nil)
+	s.r.NoError(err)
+
+	// Then the blobs of the signature should travel with the image
+	s.r.Len(artifacts, 1)
+	s.r.Equal(testSignatureDigest, artifacts[0].Digest)
+}
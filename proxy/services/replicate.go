@@ -0,0 +1,88 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/forta-network/disco/drivers/multidriver"
+	"github.com/forta-network/disco/utils"
+	log "github.com/sirupsen/logrus"
+)
+
+// Replication targets: the IPFS nodes are the primary store and the cache is the secondary.
+const (
+	ReplicateToPrimary   = "primary"
+	ReplicateToSecondary = "secondary"
+	ReplicateToBoth      = "both"
+)
+
+// Replicate copies the content between the IPFS nodes and the cache on demand, so that the
+// caches can be seeded before the content is pulled. The target is either a content path under
+// the registry root or a CID v1 repository, in which case the repository and its blobs are
+// replicated. The content is replicated to the given store or to both if it is empty. It returns
+// the replicated content paths.
+func (disco *Disco) Replicate(ctx context.Context, target, to string) ([]string, error) {
+	multiDriver, ok := multidriver.Is(disco.getDriver())
+	if !ok {
+		return nil, fmt.Errorf("replication needs both the ipfs nodes and the cache")
+	}
+	if len(to) == 0 {
+		to = ReplicateToBoth
+	}
+	if to != ReplicateToPrimary && to != ReplicateToSecondary && to != ReplicateToBoth {
+		return nil, fmt.Errorf("invalid replication target '%s'", to)
+	}
+
+	ctx, cancel := disco.operationContext(ctx)
+	defer cancel()
+	contentPaths, err := disco.replicationPaths(ctx, target)
+	if err != nil {
+		return nil, err
+	}
+	for _, contentPath := range contentPaths {
+		// replicating in primary first makes the content available to replicate in secondary
+		if to != ReplicateToSecondary {
+			if _, err := multiDriver.ReplicateInPrimary(ctx, contentPath); err != nil {
+				return nil, fmt.Errorf("failed to replicate '%s' in primary: %v", contentPath, err)
+			}
+		}
+		if to != ReplicateToPrimary {
+			if _, err := multiDriver.ReplicateInSecondary(ctx, contentPath); err != nil {
+				return nil, fmt.Errorf("failed to replicate '%s' in secondary: %v", contentPath, err)
+			}
+		}
+	}
+	utils.Logger(ctx).WithFields(log.Fields{
+		"target": target,
+		"to":     to,
+		"paths":  len(contentPaths),
+	}).Info("replicated content")
+	return contentPaths, nil
+}
+
+// replicationPaths returns the content paths to replicate for the target.
+func (disco *Disco) replicationPaths(ctx context.Context, target string) ([]string, error) {
+	if strings.HasPrefix(target, "/") {
+		if target != registryBase && !strings.HasPrefix(target, registryBase+"/") || strings.Contains(target, "..") {
+			return nil, fmt.Errorf("'%s' is not a content path under %s", target, registryBase)
+		}
+		return []string{target}, nil
+	}
+	if !utils.IsCIDv1(target) {
+		return nil, fmt.Errorf("'%s' is neither a content path nor a cid v1 repository: %w", target, ErrNotCIDName)
+	}
+	// make sure that the repository is in the IPFS node before reading the blobs from it
+	if err := disco.cloneFullRepo(ctx, target); err != nil {
+		return nil, fmt.Errorf("failed to clone the repository: %v", err)
+	}
+	file, err := disco.readDiscoFile(ctx, target)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read the disco file: %v", err)
+	}
+	contentPaths := []string{makeRepoPath(target)}
+	for _, blob := range file.Blobs {
+		contentPaths = append(contentPaths, makeBlobPath(blob.Digest))
+	}
+	return contentPaths, nil
+}
@@ -0,0 +1,43 @@
+package services
+
+import (
+	storagedriver "github.com/distribution/distribution/v3/registry/storage/driver"
+	"github.com/golang/mock/gomock"
+)
+
+func (s *Suite) TestReplicate_Path() {
+	blobPath := makeBlobPath(testLayerDigest)
+	gomock.InOrder(
+		s.driver.EXPECT().ReplicateInPrimary(gomock.Any(), blobPath).Return(nil, nil),
+		s.driver.EXPECT().ReplicateInSecondary(gomock.Any(), blobPath).Return(nil, nil),
+	)
+
+	paths, err := s.disco.Replicate(s.ctx, blobPath, "")
+	s.r.NoError(err)
+	s.r.Equal([]string{blobPath}, paths)
+}
+
+func (s *Suite) TestReplicate_OnlySecondary() {
+	blobPath := makeBlobPath(testLayerDigest)
+	s.driver.EXPECT().ReplicateInSecondary(gomock.Any(), blobPath).Return(nil, nil)
+
+	_, err := s.disco.Replicate(s.ctx, blobPath, ReplicateToSecondary)
+	s.r.NoError(err)
+}
+
+func (s *Suite) TestReplicate_NotFound() {
+	blobPath := makeBlobPath(testLayerDigest)
+	s.driver.EXPECT().ReplicateInPrimary(gomock.Any(), blobPath).Return(nil, storagedriver.PathNotFoundError{Path: blobPath})
+
+	_, err := s.disco.Replicate(s.ctx, blobPath, ReplicateToPrimary)
+	s.r.Error(err)
+}
+
+func (s *Suite) TestReplicate_InvalidTarget() {
+	for _, target := range []string{"/etc/passwd", registryBase + "/../secret", "myrepo"} {
+		_, err := s.disco.Replicate(s.ctx, target, "")
+		s.r.Error(err, target)
+	}
+	_, err := s.disco.Replicate(s.ctx, makeBlobPath(testLayerDigest), "elsewhere")
+	s.r.Error(err)
+}
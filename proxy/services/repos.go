@@ -0,0 +1,76 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"strings"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// RepoInfo describes a global repository.
+type RepoInfo struct {
+	Cid    string `json:"cid"`
+	Digest string `json:"digest,omitempty"`
+	// Name is the name which the repository was pushed with, if known.
+	Name string `json:"name,omitempty"`
+	// Size is the total size of the manifest, the config and the layers.
+	Size     int64      `json:"size"`
+	LastPull *time.Time `json:"lastPull,omitempty"`
+}
+
+// ListRepoInfos lists the CID v1 repositories with their digests, names, sizes and last pull times.
+// The repositories which cannot be read are listed only with their CIDs.
+func (disco *Disco) ListRepoInfos(ctx context.Context) ([]*RepoInfo, error) {
+	repos, err := disco.ListGlobalRepos(ctx)
+	if err != nil {
+		return nil, err
+	}
+	var pullTimes map[string]time.Time
+	if len(disco.cfg.PullIndex) > 0 {
+		pullTimes, err = readPullIndex(disco.cfg.PullIndex)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	var infos []*RepoInfo
+	for _, repo := range repos {
+		info := &RepoInfo{Cid: repo}
+		infos = append(infos, info)
+		if pullTime, ok := pullTimes[repo]; ok {
+			info.LastPull = &pullTime
+		}
+		logger := log.WithField("repository", repo)
+
+		if manifestDigest, ok := disco.cids.manifestDigest(repo); ok {
+			info.Digest = manifestDigest
+		} else {
+			b, err := disco.readFromStores(ctx, disco.makeManifestLinkPath(repo))
+			if err != nil {
+				logger.WithError(err).Warn("failed to read manifest link")
+				continue
+			}
+			info.Digest = strings.TrimPrefix(strings.TrimSpace(string(b)), "sha256:")
+		}
+		if b, err := disco.readFromStores(ctx, makeOriginFilePath(info.Digest)); err == nil {
+			info.Name = string(b)
+		}
+		b, err := disco.readFromStores(ctx, makeBlobPath(info.Digest))
+		if err != nil {
+			logger.WithError(err).Warn("failed to read manifest")
+			continue
+		}
+		var manifest imageManifest
+		if err := json.Unmarshal(b, &manifest); err != nil {
+			logger.WithError(err).Warn("failed to decode manifest")
+			continue
+		}
+		info.Size = int64(len(b)) + manifest.Config.Size
+		for _, layer := range manifest.Layers {
+			info.Size += layer.Size
+		}
+	}
+	return infos, nil
+}
@@ -0,0 +1,35 @@
+package services
+
+import (
+	"path"
+
+	storagedriver "github.com/distribution/distribution/v3/registry/storage/driver"
+	"github.com/distribution/distribution/v3/registry/storage/driver/inmemory"
+	"github.com/forta-network/disco/config"
+)
+
+func (s *Suite) TestListRepoInfos() {
+	// Given that a repo was pushed with a name and pulled with its cid
+	driver := inmemory.New()
+	s.r.NoError(driver.PutContent(s.ctx, s.disco.makeManifestLinkPath(testCidv1), []byte("sha256:"+testManifestDigest)))
+	s.r.NoError(driver.PutContent(s.ctx, makeBlobPath(testManifestDigest), []byte(testManifest)))
+	s.r.NoError(driver.PutContent(s.ctx, makeOriginFilePath(testManifestDigest), []byte("myrepo")))
+	s.disco.cfg = &config.Config{CacheOnly: true, PullIndex: path.Join(s.T().TempDir(), "pulls.json")}
+	s.disco.getDriver = func() storagedriver.StorageDriver {
+		return driver
+	}
+	s.disco.pulls = newPullIndex(s.disco.cfg.PullIndex, nil)
+	s.disco.RecordPull(testCidv1)
+
+	// When the repos are listed
+	infos, err := s.disco.ListRepoInfos(s.ctx)
+
+	// Then the repo should be listed with its digest, name, size and pull time
+	s.r.NoError(err)
+	s.r.Len(infos, 1)
+	s.r.Equal(testCidv1, infos[0].Cid)
+	s.r.Equal(testManifestDigest, infos[0].Digest)
+	s.r.Equal("myrepo", infos[0].Name)
+	s.r.Equal(int64(len(testManifest)+1457+766607), infos[0].Size)
+	s.r.NotNil(infos[0].LastPull)
+}
@@ -0,0 +1,56 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/forta-network/disco/utils"
+)
+
+// ResolvedRepo is the CID v1 repository of a manifest digest together with the CIDs of the
+// blobs from its disco file.
+type ResolvedRepo struct {
+	Digest string          `json:"digest"`
+	Cid    string          `json:"cid"`
+	Blobs  []*ResolvedBlob `json:"blobs"`
+}
+
+// ResolvedBlob is a blob of a resolved repository.
+type ResolvedBlob struct {
+	Digest    string `json:"digest"`
+	Cid       string `json:"cid"`
+	MediaType string `json:"mediaType,omitempty"`
+	Size      int64  `json:"size,omitempty"`
+}
+
+// Resolve returns the CID v1 repository of the manifest digest, which is known after the
+// manifest is made global, and the CIDs of its blobs. The digest can have the sha256: prefix.
+func (disco *Disco) Resolve(ctx context.Context, digest string) (*ResolvedRepo, error) {
+	manifestDigest := strings.TrimPrefix(digest, "sha256:")
+	if !utils.IsDigestHex(manifestDigest) {
+		return nil, fmt.Errorf("%w: %s", ErrInvalidDigest, digest)
+	}
+	repoCid, err := disco.ResolveRepoCid(ctx, manifestDigest)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %s is not global: %v", ErrRepoNotFound, digest, err)
+	}
+	b, err := disco.readFromStores(ctx, makeDiscoFilePath(repoCid))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read the disco file of %s: %v", repoCid, err)
+	}
+	file, err := decodeDiscoFile(b)
+	if err != nil {
+		return nil, err
+	}
+	resolved := &ResolvedRepo{Digest: "sha256:" + manifestDigest, Cid: repoCid}
+	for _, blob := range file.Blobs {
+		resolved.Blobs = append(resolved.Blobs, &ResolvedBlob{
+			Digest:    "sha256:" + blob.Digest,
+			Cid:       blob.Cid,
+			MediaType: blob.MediaType,
+			Size:      blob.Size,
+		})
+	}
+	return resolved, nil
+}
@@ -0,0 +1,35 @@
+package services
+
+import (
+	"bytes"
+	"errors"
+	"io"
+
+	"github.com/forta-network/disco/interfaces"
+	"github.com/golang/mock/gomock"
+)
+
+func (s *Suite) TestResolve() {
+	// Given that a manifest is made global
+	s.disco.cids = newCidIndex("", newTestStore())
+	s.disco.cids.addRepo(testManifestDigest, testCidv1, nil)
+	s.ipfsClient.EXPECT().NodeClients().Return([]interfaces.IPFSFilesAPI{s.ipfsNode}).AnyTimes()
+	s.ipfsNode.EXPECT().FilesRead(gomock.Any(), makeDiscoFilePath(testCidv1)).
+		Return(io.NopCloser(bytes.NewBufferString(testDiscoFile)), nil)
+
+	// When its digest is resolved
+	resolved, err := s.disco.Resolve(s.ctx, "sha256:"+testManifestDigest)
+
+	// Then the CID v1 repository and the blob CIDs should be returned
+	s.r.NoError(err)
+	s.r.Equal("sha256:"+testManifestDigest, resolved.Digest)
+	s.r.Equal(testCidv1, resolved.Cid)
+	s.r.Len(resolved.Blobs, 3)
+	s.r.Equal("sha256:"+testManifestDigest, resolved.Blobs[0].Digest)
+	s.r.Equal(testManifestCid, resolved.Blobs[0].Cid)
+	s.r.Equal(int64(766607), resolved.Blobs[2].Size)
+
+	// And the invalid digests should be rejected
+	_, err = s.disco.Resolve(s.ctx, "sha256:1234")
+	s.r.True(errors.Is(err, ErrInvalidDigest))
+}
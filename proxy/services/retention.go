@@ -0,0 +1,87 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/forta-network/disco/utils"
+	log "github.com/sirupsen/logrus"
+)
+
+// PrunedRepo is a digest repository which was deleted by PruneDigestRepos.
+type PrunedRepo struct {
+	Digest   string
+	LastPull time.Time
+}
+
+// PruneDigestRepos deletes the digest repositories which were not pulled with <digest>:<tag>
+// within the given age. The CID v1 repositories are kept. The pushes count as pulls and the
+// digest repositories which are not in the pull index yet are recorded as pulled now, so that
+// they are kept for the given age.
+func (disco *Disco) PruneDigestRepos(ctx context.Context, olderThan time.Duration, dryRun bool) ([]*PrunedRepo, error) {
+	if disco.pulls == nil {
+		return nil, fmt.Errorf("pruning the digest repositories needs the pull index")
+	}
+	repos, err := disco.listRepos(ctx)
+	if err != nil {
+		return nil, err
+	}
+	cutoff := time.Now().Add(-olderThan)
+	var pruned []*PrunedRepo
+	for _, repo := range repos {
+		if !utils.IsDigestHex(repo) {
+			continue
+		}
+		lastPull, ok := disco.pulls.lastPull(repo)
+		if !ok {
+			disco.pulls.record(repo)
+			continue
+		}
+		if lastPull.After(cutoff) {
+			continue
+		}
+		if !dryRun {
+			if err := disco.pruneDigestRepo(ctx, repo); err != nil {
+				return pruned, err
+			}
+		}
+		pruned = append(pruned, &PrunedRepo{Digest: repo, LastPull: lastPull})
+	}
+	return pruned, nil
+}
+
+// pruneDigestRepo deletes the digest repository while it is locked.
+func (disco *Disco) pruneDigestRepo(ctx context.Context, repo string) error {
+	ctx, unlock, err := disco.lockRepos(ctx, repo)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+	if err := disco.deleteRepo(ctx, repo); err != nil {
+		return err
+	}
+	disco.pulls.remove(repo)
+	return nil
+}
+
+// RunDigestRepoPruner prunes the digest repositories periodically by using the config, until the
+// context is done.
+func (disco *Disco) RunDigestRepoPruner(ctx context.Context) {
+	ticker := time.NewTicker(disco.cfg.DigestRetention.Interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+		pruned, err := disco.PruneDigestRepos(ctx, disco.cfg.DigestRetention.Age, false)
+		if err != nil {
+			log.WithError(err).Error("failed to prune digest repositories")
+		}
+		if len(pruned) > 0 {
+			log.WithField("repositories", len(pruned)).Info("pruned digest repositories")
+		}
+	}
+}
@@ -0,0 +1,46 @@
+package services
+
+import (
+	"path"
+	"time"
+
+	storagedriver "github.com/distribution/distribution/v3/registry/storage/driver"
+	"github.com/distribution/distribution/v3/registry/storage/driver/inmemory"
+	"github.com/forta-network/disco/config"
+)
+
+func (s *Suite) TestPruneDigestRepos() {
+	// Given the digest and the cid repos of a manifest which was not pulled for a while
+	// And the digest repo of a manifest which was pushed before the pull index
+	const otherDigest = "1111111111111111111111111111111111111111111111111111111111111111"
+	driver := inmemory.New()
+	s.r.NoError(driver.PutContent(s.ctx, s.disco.makeManifestLinkPath(testManifestDigest), []byte("sha256:"+testManifestDigest)))
+	s.r.NoError(driver.PutContent(s.ctx, s.disco.makeManifestLinkPath(testCidv1), []byte("sha256:"+testManifestDigest)))
+	s.r.NoError(driver.PutContent(s.ctx, s.disco.makeManifestLinkPath(otherDigest), []byte("sha256:"+otherDigest)))
+	s.disco.cfg = &config.Config{CacheOnly: true, PullIndex: path.Join(s.T().TempDir(), "pulls.json")}
+	s.disco.getDriver = func() storagedriver.StorageDriver {
+		return driver
+	}
+	s.disco.pulls = newPullIndex(s.disco.cfg.PullIndex, nil)
+	s.disco.pulls.times[testManifestDigest] = time.Now().Add(-time.Hour * 48)
+
+	// When the digest repos which were not pulled for a day are pruned
+	pruned, err := s.disco.PruneDigestRepos(s.ctx, time.Hour*24, false)
+
+	// Then only the digest repo which was not pulled should be deleted
+	s.r.NoError(err)
+	s.r.Len(pruned, 1)
+	s.r.Equal(testManifestDigest, pruned[0].Digest)
+	_, err = driver.Stat(s.ctx, makeRepoPath(testManifestDigest))
+	s.r.IsType(storagedriver.PathNotFoundError{}, err)
+	_, err = driver.Stat(s.ctx, makeRepoPath(testCidv1))
+	s.r.NoError(err)
+	_, err = driver.Stat(s.ctx, makeRepoPath(otherDigest))
+	s.r.NoError(err)
+
+	// And the other digest repo should be kept from now on
+	_, ok := s.disco.pulls.lastPull(otherDigest)
+	s.r.True(ok)
+	_, ok = s.disco.pulls.lastPull(testManifestDigest)
+	s.r.False(ok)
+}
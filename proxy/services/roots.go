@@ -0,0 +1,92 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/hashicorp/go-multierror"
+	log "github.com/sirupsen/logrus"
+)
+
+// RootPublication contains the MFS roots of the registry in the IPFS nodes at a point in time.
+type RootPublication struct {
+	Time  time.Time       `json:"time"`
+	Roots []*SnapshotRoot `json:"roots"`
+	// Converged is true if all of the nodes have the same root.
+	Converged bool `json:"converged"`
+	// IPNSNames are the names which the roots are published with, in the order of the roots.
+	IPNSNames []string `json:"ipnsNames,omitempty"`
+}
+
+// PublishRoots logs the current MFS roots of the registry in the IPFS nodes and publishes them to
+// IPNS and to the webhook, if they are configured, so that the nodes can be checked externally
+// to have converged to the same content.
+func (disco *Disco) PublishRoots(ctx context.Context) (*RootPublication, error) {
+	roots, err := disco.registryRoots(ctx)
+	if err != nil {
+		return nil, err
+	}
+	publication := &RootPublication{
+		Time:      time.Now().UTC(),
+		Roots:     roots,
+		Converged: rootsConverged(roots),
+	}
+	for _, root := range roots {
+		log.WithFields(log.Fields{
+			"node":      root.Node,
+			"cid":       root.Cid,
+			"converged": publication.Converged,
+		}).Info("registry root")
+	}
+
+	var errs *multierror.Error
+	if key := disco.cfg.PublishRoot.IPNSKey; len(key) > 0 {
+		nodeClients := disco.getIpfsClient().NodeClients()
+		for _, root := range roots {
+			name, err := nodeClients[root.Node].NamePublish(ctx, "/ipfs/"+root.Cid, key)
+			if err != nil {
+				errs = multierror.Append(errs, fmt.Errorf("failed to publish the root of ipfs node #%d to ipns: %v", root.Node, err))
+			} else {
+				log.WithFields(log.Fields{
+					"node": root.Node,
+					"name": name,
+				}).Info("published registry root to ipns")
+			}
+			publication.IPNSNames = append(publication.IPNSNames, name)
+		}
+	}
+	if webhook := disco.cfg.PublishRoot.Webhook; len(webhook) > 0 {
+		if err := disco.postWebhook(ctx, webhook, publication); err != nil {
+			errs = multierror.Append(errs, err)
+		}
+	}
+	return publication, errs.ErrorOrNil()
+}
+
+// rootsConverged checks if all of the roots are the same.
+func rootsConverged(roots []*SnapshotRoot) bool {
+	for _, root := range roots {
+		if root.Cid != roots[0].Cid {
+			return false
+		}
+	}
+	return true
+}
+
+// RunRootPublisher publishes the registry roots periodically by using the config, until the
+// context is done.
+func (disco *Disco) RunRootPublisher(ctx context.Context) {
+	ticker := time.NewTicker(disco.cfg.PublishRoot.Interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+		if _, err := disco.PublishRoots(ctx); err != nil {
+			log.WithError(err).Error("failed to publish the registry roots")
+		}
+	}
+}
@@ -0,0 +1,47 @@
+package services
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+
+	"github.com/forta-network/disco/config"
+	"github.com/forta-network/disco/interfaces"
+	mock_interfaces "github.com/forta-network/disco/interfaces/mocks"
+	"github.com/golang/mock/gomock"
+	ipfsapi "github.com/ipfs/go-ipfs-api"
+)
+
+func (s *Suite) TestPublishRoots() {
+	// Given two nodes with different registry roots
+	// And a webhook with TLS and an ipns key
+	var received RootPublication
+	webhook := httptest.NewTLSServer(http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+		s.r.NoError(json.NewDecoder(r.Body).Decode(&received))
+	}))
+	defer webhook.Close()
+	s.disco.cfg = &config.Config{PublishRoot: config.PublishRootConfig{Webhook: webhook.URL, IPNSKey: "disco"}}
+	s.disco.httpClient = webhook.Client()
+	otherNode := mock_interfaces.NewMockIPFSFilesAPI(gomock.NewController(s.T()))
+	s.ipfsClient.EXPECT().NodeClients().Return([]interfaces.IPFSFilesAPI{s.ipfsNode, otherNode}).AnyTimes()
+	s.ipfsNode.EXPECT().FilesStat(gomock.Any(), registryBase).Return(&ipfsapi.FilesStatObject{Hash: "root-1"}, nil)
+	otherNode.EXPECT().FilesStat(gomock.Any(), registryBase).Return(&ipfsapi.FilesStatObject{Hash: "root-2"}, nil)
+
+	// When the roots are published
+	// Then they should be published to ipns from each node and to the webhook as not converged
+	s.ipfsNode.EXPECT().NamePublish(gomock.Any(), "/ipfs/root-1", "disco").Return("k51-1", nil)
+	otherNode.EXPECT().NamePublish(gomock.Any(), "/ipfs/root-2", "disco").Return("k51-2", nil)
+	publication, err := s.disco.PublishRoots(s.ctx)
+	s.r.NoError(err)
+	s.r.False(publication.Converged)
+	s.r.Equal([]string{"k51-1", "k51-2"}, publication.IPNSNames)
+	s.r.Len(received.Roots, 2)
+	s.r.Equal("root-2", received.Roots[1].Cid)
+	s.r.False(received.Converged)
+}
+
+func (s *Suite) TestRootsConverged() {
+	s.r.True(rootsConverged(nil))
+	s.r.True(rootsConverged([]*SnapshotRoot{{Node: 0, Cid: "root"}, {Node: 1, Cid: "root"}}))
+	s.r.False(rootsConverged([]*SnapshotRoot{{Node: 0, Cid: "root"}, {Node: 1, Cid: "other"}}))
+}
@@ -0,0 +1,64 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	storagedriver "github.com/distribution/distribution/v3/registry/storage/driver"
+	"github.com/forta-network/disco/config"
+	"github.com/forta-network/disco/utils"
+)
+
+// PushDeniedError is returned when a push rule denies a push.
+type PushDeniedError struct {
+	Message string
+}
+
+// Error implements the error interface.
+func (e *PushDeniedError) Error() string {
+	return e.Message
+}
+
+// CheckPush checks the manifest push against the push rules in the config. The reference can
+// be a tag or a digest and the digest pushes are matched with an empty tag. The canonical tags
+// of the CID v1 and digest repositories cannot be overwritten.
+func (disco *Disco) CheckPush(ctx context.Context, repoName, reference string) error {
+	if reference == disco.CanonicalTag() && disco.IsOnlyPullable(repoName) {
+		return fmt.Errorf("%w: %s", ErrAlreadyGlobal, repoName)
+	}
+	tag := reference
+	if utils.IsDigestHex(strings.TrimPrefix(reference, "sha256:")) {
+		tag = ""
+	}
+	rule := config.MatchPushRule(disco.cfg.PushRules, repoName, tag)
+	if rule == nil {
+		return nil
+	}
+	message := rule.Message
+	switch rule.Action {
+	case config.PushActionDeny:
+		if len(message) == 0 {
+			message = fmt.Sprintf("pushing to %s:%s is not allowed", repoName, reference)
+		}
+		return &PushDeniedError{Message: message}
+
+	case config.PushActionImmutable:
+		if len(tag) == 0 {
+			return nil
+		}
+		_, err := disco.getDriver().Stat(ctx, makeTagLinkPath(repoName, tag))
+		switch err.(type) {
+		case nil:
+			if len(message) == 0 {
+				message = fmt.Sprintf("tag %s:%s is immutable", repoName, tag)
+			}
+			return &PushDeniedError{Message: message}
+		case storagedriver.PathNotFoundError:
+			return nil
+		default:
+			return fmt.Errorf("failed to check the tag: %v", err)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,38 @@
+package services
+
+import (
+	storagedriver "github.com/distribution/distribution/v3/registry/storage/driver"
+	"github.com/distribution/distribution/v3/registry/storage/driver/inmemory"
+	"github.com/forta-network/disco/config"
+)
+
+func (s *Suite) TestCheckPush() {
+	// Given an immutable tag rule and a pushed tag
+	driver := inmemory.New()
+	s.r.NoError(driver.PutContent(s.ctx, makeTagLinkPath("myrepo", "v1"), []byte("sha256:"+testManifestDigest)))
+	s.disco.getDriver = func() storagedriver.StorageDriver {
+		return driver
+	}
+	rule := &config.PushRule{Tag: "^v[0-9]+$", Action: config.PushActionImmutable}
+	s.r.NoError(rule.Compile())
+	s.disco.cfg = &config.Config{PushRules: []*config.PushRule{rule}}
+
+	// Then only the new tags should be allowed
+	err := s.disco.CheckPush(s.ctx, "myrepo", "v1")
+	s.r.IsType(&PushDeniedError{}, err)
+	s.r.NoError(s.disco.CheckPush(s.ctx, "myrepo", "v2"))
+	s.r.NoError(s.disco.CheckPush(s.ctx, "myrepo", "latest"))
+	s.r.NoError(s.disco.CheckPush(s.ctx, "myrepo", "sha256:"+testManifestDigest))
+}
+
+func (s *Suite) TestCheckPush_AlreadyGlobal() {
+	s.r.ErrorIs(s.disco.CheckPush(s.ctx, testCidv1, "latest"), ErrAlreadyGlobal)
+	s.r.ErrorIs(s.disco.CheckPush(s.ctx, testManifestDigest, "latest"), ErrAlreadyGlobal)
+}
+
+func (s *Suite) TestCheckPush_CanonicalTag() {
+	s.disco.cfg.CanonicalTag = "release"
+	s.r.ErrorIs(s.disco.CheckPush(s.ctx, testCidv1, "release"), ErrAlreadyGlobal)
+	s.r.NoError(s.disco.CheckPush(s.ctx, testCidv1, "latest"))
+	s.r.Equal(makeTagLinkPath("myrepo", "release"), s.disco.makeManifestLinkPath("myrepo"))
+}
@@ -0,0 +1,174 @@
+package services
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"path"
+	"time"
+
+	storagedriver "github.com/distribution/distribution/v3/registry/storage/driver"
+	"github.com/forta-network/disco/utils"
+	log "github.com/sirupsen/logrus"
+)
+
+// quarantineBase is where the repositories which failed the security scan are moved to. It is
+// under the repositories so that the router can route it, but the repository names cannot start
+// with an underscore, so the quarantined repositories cannot be pulled.
+const quarantineBase = repositoriesBase + "/" + quarantineDirName
+
+const quarantineDirName = "_quarantine"
+
+// ScanRequest contains the image which the scanner should scan before it is made global.
+type ScanRequest struct {
+	Repository     string       `json:"repository"`
+	ManifestDigest string       `json:"manifestDigest"`
+	ConfigDigest   string       `json:"configDigest"`
+	Layers         []*ScanLayer `json:"layers"`
+}
+
+// ScanLayer is a layer of the scanned image. The CID is empty in cache-only mode.
+type ScanLayer struct {
+	Digest    string `json:"digest"`
+	Cid       string `json:"cid,omitempty"`
+	Size      int64  `json:"size"`
+	MediaType string `json:"mediaType,omitempty"`
+}
+
+// ScanResult is the response of the scanner.
+type ScanResult struct {
+	Passed bool   `json:"passed"`
+	Reason string `json:"reason,omitempty"`
+	// Findings are recorded with the quarantined repository as they are.
+	Findings json.RawMessage `json:"findings,omitempty"`
+}
+
+// quarantineRecord is written next to the quarantined repository.
+type quarantineRecord struct {
+	Time    time.Time    `json:"time"`
+	Request *ScanRequest `json:"request"`
+	Result  *ScanResult  `json:"result"`
+}
+
+func makeQuarantinePath(manifestDigest string) string {
+	return path.Join(quarantineBase, manifestDigest)
+}
+
+// scanPush sends the pushed image to the scanner, if it is configured, and quarantines the
+// repository if the image does not pass the scan. The blobs are used for the layer CIDs and the
+// children are the manifests which a manifest list or an image index lists.
+func (disco *Disco) scanPush(
+	ctx context.Context, driver storagedriver.StorageDriver, repoName, manifestDigest string,
+	manifest *imageManifest, children []*childManifest, blobs []*blobCid,
+) error {
+	scannerCfg := disco.cfg.Scanner
+	if len(scannerCfg.Endpoint) == 0 {
+		return nil
+	}
+	cids := make(map[string]string)
+	for _, blob := range blobs {
+		cids[blob.Digest] = blob.Cid
+	}
+	req := &ScanRequest{
+		Repository:     repoName,
+		ManifestDigest: "sha256:" + manifestDigest,
+		ConfigDigest:   manifest.Config.Digest,
+	}
+	// the layers of all platforms are scanned for the manifest lists and the image indexes
+	images := []*imageManifest{manifest}
+	for _, child := range children {
+		images = append(images, child.Manifest)
+	}
+	scanned := make(map[string]bool)
+	for _, image := range images {
+		for _, layer := range image.Layers {
+			if scanned[layer.Digest] {
+				continue
+			}
+			scanned[layer.Digest] = true
+			req.Layers = append(req.Layers, &ScanLayer{
+				Digest:    layer.Digest,
+				Cid:       cids[layer.Digest[7:]],
+				Size:      layer.Size,
+				MediaType: layer.MediaType,
+			})
+		}
+	}
+
+	logger := utils.Logger(ctx).WithFields(log.Fields{
+		"repository": repoName,
+		"digest":     manifestDigest,
+	})
+	result, err := disco.requestScan(ctx, req)
+	if err != nil && scannerCfg.FailOpen {
+		logger.WithError(err).Warn("security scan failed - making the image global")
+		return nil
+	}
+	if err != nil {
+		result = &ScanResult{Reason: fmt.Sprintf("scan failed: %v", err)}
+	}
+	if result.Passed {
+		logger.Info("image passed the security scan")
+		return nil
+	}
+
+	if err := quarantineRepo(ctx, driver, repoName, manifestDigest, &quarantineRecord{
+		Time:    time.Now().UTC(),
+		Request: req,
+		Result:  result,
+	}); err != nil {
+		return fmt.Errorf("failed to quarantine the repo after the security scan (%s): %v", result.Reason, err)
+	}
+	logger.WithField("reason", result.Reason).Warn("quarantined the image after the security scan")
+	return fmt.Errorf("%w: %s", ErrQuarantined, result.Reason)
+}
+
+func (disco *Disco) requestScan(ctx context.Context, scanReq *ScanRequest) (*ScanResult, error) {
+	b, err := json.Marshal(scanReq)
+	if err != nil {
+		return nil, err
+	}
+	ctx, cancel := context.WithTimeout(ctx, disco.cfg.Scanner.Timeout)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, disco.cfg.Scanner.Endpoint, bytes.NewReader(b))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if token := disco.cfg.Scanner.Token; len(token) > 0 {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+	resp, err := disco.httpClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("scanner responded with status %d", resp.StatusCode)
+	}
+	var result ScanResult
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return nil, fmt.Errorf("failed to decode the response: %v", err)
+	}
+	return &result, nil
+}
+
+// quarantineRepo moves the uploaded repository out of the registry and records the scan next to
+// it. The blobs of the image are left to the garbage collection.
+func quarantineRepo(
+	ctx context.Context, driver storagedriver.StorageDriver, repoName, manifestDigest string,
+	record *quarantineRecord,
+) error {
+	quarantinePath := makeQuarantinePath(manifestDigest)
+	_ = driver.Delete(ctx, quarantinePath)
+	if err := driver.Move(ctx, makeRepoPath(repoName), path.Join(quarantinePath, "repository")); err != nil {
+		return err
+	}
+	b, err := json.Marshal(record)
+	if err != nil {
+		return err
+	}
+	return driver.PutContent(ctx, path.Join(quarantinePath, "scan.json"), b)
+}
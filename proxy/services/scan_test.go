@@ -0,0 +1,106 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"time"
+
+	storagedriver "github.com/distribution/distribution/v3/registry/storage/driver"
+	"github.com/distribution/distribution/v3/registry/storage/driver/inmemory"
+	"github.com/forta-network/disco/config"
+	"github.com/forta-network/disco/ipfsclient"
+)
+
+// routedDriver fails the writes to the paths which the IPFS router cannot route, like the IPFS
+// driver does.
+type routedDriver struct {
+	storagedriver.StorageDriver
+	router *ipfsclient.Router
+}
+
+func (d *routedDriver) PutContent(ctx context.Context, path string, content []byte) error {
+	if _, _, err := d.router.RouteContent(path); err != nil {
+		return err
+	}
+	return d.StorageDriver.PutContent(ctx, path, content)
+}
+
+func (d *routedDriver) Move(ctx context.Context, sourcePath string, destPath string) error {
+	for _, path := range []string{sourcePath, destPath} {
+		if _, _, err := d.router.RouteContent(path); err != nil {
+			return err
+		}
+	}
+	return d.StorageDriver.Move(ctx, sourcePath, destPath)
+}
+
+func (d *routedDriver) Delete(ctx context.Context, path string) error {
+	if _, _, err := d.router.RouteContent(path); err != nil {
+		return err
+	}
+	return d.StorageDriver.Delete(ctx, path)
+}
+
+func (s *Suite) TestScanPush() {
+	// Given a scanner with TLS which fails the images with more than one layer
+	var scanReq ScanRequest
+	scanner := httptest.NewTLSServer(http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+		s.r.Equal("Bearer secret", r.Header.Get("Authorization"))
+		s.r.NoError(json.NewDecoder(r.Body).Decode(&scanReq))
+		if len(scanReq.Layers) > 1 {
+			_, _ = rw.Write([]byte(`{"passed": false, "reason": "malware found", "findings": ["eicar"]}`))
+			return
+		}
+		_, _ = rw.Write([]byte(`{"passed": true}`))
+	}))
+	defer scanner.Close()
+	s.disco.cfg = &config.Config{Scanner: config.ScannerConfig{Endpoint: scanner.URL, Token: "secret", Timeout: time.Second}}
+	s.disco.httpClient = scanner.Client()
+	driver := &routedDriver{StorageDriver: inmemory.New(), router: ipfsclient.NewRouter(2)}
+	s.r.NoError(driver.PutContent(s.ctx, makeRepoPath("myrepo")+"/_manifests/link", []byte("sha256:"+testManifestDigest)))
+
+	var manifest imageManifest
+	s.r.NoError(json.Unmarshal([]byte(testManifest), &manifest))
+	blobs := []*blobCid{{Digest: testLayerDigest, Cid: testLayerCid}}
+
+	// When an image with one layer is scanned
+	// Then it should pass with the layer cids
+	s.r.NoError(s.disco.scanPush(s.ctx, driver, "myrepo", testManifestDigest, &manifest, nil, blobs))
+	s.r.Equal("sha256:"+testManifestDigest, scanReq.ManifestDigest)
+	s.r.Equal(testLayerCid, scanReq.Layers[0].Cid)
+
+	// When an image with two layers is scanned
+	manifest.Layers = append(manifest.Layers, manifest.Layers[0])
+	manifest.Layers[1].Digest = "sha256:" + testConfigDigest
+	err := s.disco.scanPush(s.ctx, driver, "myrepo", testManifestDigest, &manifest, nil, blobs)
+
+	// Then it should be quarantined
+	s.r.True(errors.Is(err, ErrQuarantined))
+	_, err = driver.Stat(s.ctx, makeRepoPath("myrepo"))
+	s.r.Error(err)
+	// the in-memory driver does not move the files under a moved directory
+	info, err := driver.Stat(s.ctx, makeQuarantinePath(testManifestDigest)+"/repository")
+	s.r.NoError(err)
+	s.r.True(info.IsDir())
+	b, err := driver.GetContent(s.ctx, makeQuarantinePath(testManifestDigest)+"/scan.json")
+	s.r.NoError(err)
+	var record quarantineRecord
+	s.r.NoError(json.Unmarshal(b, &record))
+	s.r.Equal("malware found", record.Result.Reason)
+	s.r.JSONEq(`["eicar"]`, string(record.Result.Findings))
+}
+
+func (s *Suite) TestScanPush_FailOpen() {
+	scanner := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+		rw.WriteHeader(http.StatusInternalServerError)
+	}))
+	defer scanner.Close()
+	s.disco.cfg = &config.Config{Scanner: config.ScannerConfig{Endpoint: scanner.URL, Timeout: time.Second, FailOpen: true}}
+	manifest := &imageManifest{}
+	manifest.Config.Digest = "sha256:" + testConfigDigest
+
+	s.r.NoError(s.disco.scanPush(s.ctx, inmemory.New(), "myrepo", testManifestDigest, manifest, nil, nil))
+}
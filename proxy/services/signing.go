@@ -0,0 +1,110 @@
+package services
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+)
+
+const (
+	// SignatureHeader carries the base64-encoded signature of the manifest in a push request.
+	SignatureHeader = "X-Disco-Signature"
+	// SignatureAnnotation carries the base64-encoded signature of the digest of the manifest
+	// without the annotation (e.g. "sha256:...") in the manifest annotations.
+	SignatureAnnotation = "network.forta.disco.signature"
+)
+
+// PushSigningEnabled tells if the pushes should be signed.
+func (disco *Disco) PushSigningEnabled() bool {
+	return len(disco.cfg.PushKeys) > 0
+}
+
+// VerifyPushSignature checks that the manifest is signed with one of the allowed keys. The
+// signature of the manifest can be in the header value, or the signature of the unsigned
+// manifest digest can be in the manifest annotations.
+func (disco *Disco) VerifyPushSignature(manifest []byte, headerSignature string) error {
+	if !disco.PushSigningEnabled() {
+		return nil
+	}
+	if len(headerSignature) > 0 {
+		return verifySignature(disco.cfg.PushKeys, manifest, headerSignature)
+	}
+	message, signature, err := annotationSignature(manifest)
+	if err != nil {
+		return &PushDeniedError{Message: "failed to decode the manifest to find the signature"}
+	}
+	if len(signature) == 0 {
+		return &PushDeniedError{Message: "the push is not signed"}
+	}
+	return verifySignature(disco.cfg.PushKeys, message, signature)
+}
+
+// annotationSignature returns the signature in the manifest annotations and the message which it
+// signs. The message is the digest of the manifest without the signature annotation, encoded
+// as compact JSON with the sorted keys, so that the signature covers the config and the layers
+// and it cannot be copied to another manifest.
+func annotationSignature(manifest []byte) (message []byte, signature string, err error) {
+	decoder := json.NewDecoder(bytes.NewReader(manifest))
+	decoder.UseNumber() // keep the sizes as they are
+	var fields map[string]interface{}
+	if err := decoder.Decode(&fields); err != nil {
+		return nil, "", err
+	}
+	annotations, _ := fields["annotations"].(map[string]interface{})
+	signature, _ = annotations[SignatureAnnotation].(string)
+	if len(signature) == 0 {
+		return nil, "", nil
+	}
+	delete(annotations, SignatureAnnotation)
+	if len(annotations) == 0 {
+		delete(fields, "annotations")
+	}
+	var b bytes.Buffer
+	encoder := json.NewEncoder(&b)
+	encoder.SetEscapeHTML(false)
+	if err := encoder.Encode(fields); err != nil {
+		return nil, "", err
+	}
+	digest := sha256.Sum256(bytes.TrimSuffix(b.Bytes(), []byte("\n")))
+	return []byte("sha256:" + hex.EncodeToString(digest[:])), signature, nil
+}
+
+func verifySignature(keys []crypto.PublicKey, message []byte, encodedSignature string) error {
+	signature, err := base64.StdEncoding.DecodeString(encodedSignature)
+	if err != nil {
+		return &PushDeniedError{Message: "the signature is not valid base64"}
+	}
+	if !signedBy(keys, message, signature) {
+		return &PushDeniedError{Message: "the push is not signed by an allowed key"}
+	}
+	return nil
+}
+
+// signedBy checks if the message is signed with one of the keys. The ECDSA and RSA signatures
+// are made over the SHA-256 hash of the message.
+func signedBy(keys []crypto.PublicKey, message, signature []byte) bool {
+	digest := sha256.Sum256(message)
+	for _, key := range keys {
+		switch key := key.(type) {
+		case ed25519.PublicKey:
+			if ed25519.Verify(key, message, signature) {
+				return true
+			}
+		case *ecdsa.PublicKey:
+			if ecdsa.VerifyASN1(key, digest[:], signature) {
+				return true
+			}
+		case *rsa.PublicKey:
+			if rsa.VerifyPKCS1v15(key, crypto.SHA256, digest[:], signature) == nil {
+				return true
+			}
+		}
+	}
+	return false
+}
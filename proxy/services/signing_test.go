@@ -0,0 +1,53 @@
+package services
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"fmt"
+
+	"github.com/forta-network/disco/config"
+)
+
+func (s *Suite) TestVerifyPushSignature() {
+	// Given the allowed keys
+	edPub, edPriv, err := ed25519.GenerateKey(rand.Reader)
+	s.r.NoError(err)
+	ecPriv, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	s.r.NoError(err)
+	s.disco.cfg = &config.Config{PushKeys: []crypto.PublicKey{edPub, &ecPriv.PublicKey}}
+	s.r.True(s.disco.PushSigningEnabled())
+
+	// Then the manifests signed in the header should be accepted
+	manifest := []byte(`{"config":{"digest":"sha256:` + testConfigDigest + `"}}`)
+	headerSig := base64.StdEncoding.EncodeToString(ed25519.Sign(edPriv, manifest))
+	s.r.NoError(s.disco.VerifyPushSignature(manifest, headerSig))
+
+	// And the manifests with the signed digest of the unsigned manifest in the annotations
+	// should be accepted
+	unsigned := fmt.Sprintf(`{"config":{"digest":"sha256:%s","size":1457},"layers":[{"digest":"sha256:%s"}]}`, testConfigDigest, testLayerDigest)
+	digest := sha256.Sum256([]byte("sha256:" + sha256Hex(unsigned)))
+	ecSig, err := ecdsa.SignASN1(rand.Reader, ecPriv, digest[:])
+	s.r.NoError(err)
+	annotations := fmt.Sprintf(`"annotations":{"%s":"%s"}`, SignatureAnnotation, base64.StdEncoding.EncodeToString(ecSig))
+	annotated := []byte(fmt.Sprintf(`{"layers": [{"digest": "sha256:%s"}], %s, "config": {"size": 1457, "digest": "sha256:%s"}}`,
+		testLayerDigest, annotations, testConfigDigest))
+	s.r.NoError(s.disco.VerifyPushSignature(annotated, ""))
+
+	// And the signature should not be accepted on a manifest with other layers
+	swapped := []byte(fmt.Sprintf(`{"config":{"digest":"sha256:%s","size":1457},"layers":[{"digest":"sha256:%s"}],%s}`,
+		testConfigDigest, testManifestDigest, annotations))
+	s.r.IsType(&PushDeniedError{}, s.disco.VerifyPushSignature(swapped, ""))
+
+	// And the rest should be denied
+	s.r.IsType(&PushDeniedError{}, s.disco.VerifyPushSignature(manifest, ""))
+	s.r.IsType(&PushDeniedError{}, s.disco.VerifyPushSignature([]byte(`{"changed":true}`), headerSig))
+	_, otherPriv, err := ed25519.GenerateKey(rand.Reader)
+	s.r.NoError(err)
+	otherSig := base64.StdEncoding.EncodeToString(ed25519.Sign(otherPriv, manifest))
+	s.r.IsType(&PushDeniedError{}, s.disco.VerifyPushSignature(manifest, otherSig))
+}
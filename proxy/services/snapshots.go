@@ -0,0 +1,304 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"path"
+	"sort"
+	"strings"
+	"time"
+
+	storagedriver "github.com/distribution/distribution/v3/registry/storage/driver"
+	"github.com/forta-network/disco/interfaces"
+	"github.com/forta-network/disco/utils"
+	"github.com/hashicorp/go-multierror"
+	ipfsapi "github.com/ipfs/go-ipfs-api"
+	log "github.com/sirupsen/logrus"
+)
+
+const (
+	// snapshotsBase is where the snapshots are recorded in the cache, outside of the registry.
+	snapshotsBase = "/disco/snapshots"
+	// snapshotIDFormat sorts the snapshot IDs by time.
+	snapshotIDFormat = "20060102T150405Z"
+)
+
+// Snapshot records the MFS roots of the registry in the IPFS nodes at a point in time. The
+// roots are pinned while the snapshot is kept, so that the registry can be restored from them.
+type Snapshot struct {
+	ID    string          `json:"id"`
+	Time  time.Time       `json:"time"`
+	Nodes []*SnapshotRoot `json:"nodes"`
+}
+
+// SnapshotRoot is the MFS root of the registry in an IPFS node.
+type SnapshotRoot struct {
+	Node int    `json:"node"`
+	Cid  string `json:"cid"`
+}
+
+func makeSnapshotPath(id string) string {
+	return path.Join(snapshotsBase, id+".json")
+}
+
+// registryRoots returns the current MFS roots of the registry in the IPFS nodes. The nodes which
+// do not have the registry yet are skipped.
+func (disco *Disco) registryRoots(ctx context.Context) ([]*SnapshotRoot, error) {
+	var roots []*SnapshotRoot
+	for i, nodeClient := range disco.getIpfsClient().NodeClients() {
+		stat, err := nodeClient.FilesStat(ctx, registryBase)
+		if err != nil && isNotExistErr(err) {
+			continue
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to stat the registry root in ipfs node #%d: %v", i, err)
+		}
+		roots = append(roots, &SnapshotRoot{Node: i, Cid: stat.Hash})
+	}
+	return roots, nil
+}
+
+// TakeSnapshot records the current MFS roots of the registry in the IPFS nodes to the cache.
+func (disco *Disco) TakeSnapshot(ctx context.Context) (*Snapshot, error) {
+	cacheDriver := disco.cacheDriver()
+	if cacheDriver == nil || disco.cfg.CacheOnly {
+		return nil, fmt.Errorf("snapshots need both the ipfs nodes and the cache")
+	}
+	now := time.Now().UTC()
+	snapshot := &Snapshot{ID: now.Format(snapshotIDFormat), Time: now}
+	roots, err := disco.registryRoots(ctx)
+	if err != nil {
+		return nil, err
+	}
+	nodeClients := disco.getIpfsClient().NodeClients()
+	for _, root := range roots {
+		// the files which are removed from MFS later are garbage collected unless they are pinned
+		if err := nodeClients[root.Node].Pin(ctx, "/ipfs/"+root.Cid); err != nil {
+			return nil, fmt.Errorf("failed to pin the registry root in ipfs node #%d: %v", root.Node, err)
+		}
+	}
+	snapshot.Nodes = roots
+	b, err := json.Marshal(snapshot)
+	if err != nil {
+		return nil, err
+	}
+	if err := cacheDriver.PutContent(ctx, makeSnapshotPath(snapshot.ID), b); err != nil {
+		return nil, fmt.Errorf("failed to write the snapshot: %v", err)
+	}
+	log.WithFields(log.Fields{
+		"snapshot": snapshot.ID,
+		"nodes":    len(snapshot.Nodes),
+	}).Info("took registry snapshot")
+	return snapshot, nil
+}
+
+// ListSnapshots returns the snapshots from the oldest to the latest.
+func (disco *Disco) ListSnapshots(ctx context.Context) ([]*Snapshot, error) {
+	cacheDriver := disco.cacheDriver()
+	if cacheDriver == nil {
+		return nil, nil
+	}
+	snapshotPaths, err := cacheDriver.List(ctx, snapshotsBase)
+	if _, ok := err.(storagedriver.PathNotFoundError); ok {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to list the snapshots: %v", err)
+	}
+	sort.Strings(snapshotPaths)
+	var snapshots []*Snapshot
+	for _, snapshotPath := range snapshotPaths {
+		if !strings.HasSuffix(snapshotPath, ".json") {
+			continue
+		}
+		b, err := cacheDriver.GetContent(ctx, snapshotPath)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read snapshot %s: %v", path.Base(snapshotPath), err)
+		}
+		var snapshot Snapshot
+		if err := json.Unmarshal(b, &snapshot); err != nil {
+			log.WithError(err).WithField("snapshot", path.Base(snapshotPath)).Warn("invalid snapshot - skipping")
+			continue
+		}
+		snapshots = append(snapshots, &snapshot)
+	}
+	return snapshots, nil
+}
+
+// PruneSnapshots deletes the snapshots which are older than the retention and unpins their roots,
+// unless the roots are shared with the kept snapshots. The latest snapshot is always kept.
+func (disco *Disco) PruneSnapshots(ctx context.Context, retention time.Duration) ([]*Snapshot, error) {
+	snapshots, err := disco.ListSnapshots(ctx)
+	if err != nil || len(snapshots) == 0 {
+		return nil, err
+	}
+	cutoff := time.Now().Add(-retention)
+	var expired []*Snapshot
+	keptRoots := make(map[SnapshotRoot]bool)
+	for i, snapshot := range snapshots {
+		if snapshot.Time.Before(cutoff) && i < len(snapshots)-1 {
+			expired = append(expired, snapshot)
+			continue
+		}
+		for _, root := range snapshot.Nodes {
+			keptRoots[*root] = true
+		}
+	}
+
+	nodeClients := disco.getIpfsClient().NodeClients()
+	var errs *multierror.Error
+	var pruned []*Snapshot
+	for _, snapshot := range expired {
+		for _, root := range snapshot.Nodes {
+			if keptRoots[*root] || root.Node >= len(nodeClients) {
+				continue
+			}
+			err := nodeClients[root.Node].Unpin(ctx, "/ipfs/"+root.Cid)
+			if err != nil && !isNotPinnedErr(err) {
+				errs = multierror.Append(errs, fmt.Errorf("failed to unpin %s in ipfs node #%d: %v", root.Cid, root.Node, err))
+			}
+		}
+		if err := disco.cacheDriver().Delete(ctx, makeSnapshotPath(snapshot.ID)); err != nil {
+			errs = multierror.Append(errs, fmt.Errorf("failed to delete snapshot %s: %v", snapshot.ID, err))
+			continue
+		}
+		pruned = append(pruned, snapshot)
+	}
+	return pruned, errs.ErrorOrNil()
+}
+
+// RunSnapshots takes the snapshots and prunes the expired ones periodically by using the config,
+// until the context is done.
+func (disco *Disco) RunSnapshots(ctx context.Context) {
+	ticker := time.NewTicker(disco.cfg.Snapshots.Interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+		if _, err := disco.TakeSnapshot(ctx); err != nil {
+			log.WithError(err).Error("failed to take registry snapshot")
+			continue
+		}
+		pruned, err := disco.PruneSnapshots(ctx, disco.cfg.Snapshots.Retention)
+		if err != nil {
+			log.WithError(err).Error("failed to prune registry snapshots")
+		}
+		if len(pruned) > 0 {
+			log.WithField("snapshots", len(pruned)).Info("pruned registry snapshots")
+		}
+	}
+}
+
+// RestoreOptions contains the options of a restore.
+type RestoreOptions struct {
+	// Node restores only the IPFS node with this index, unless it is negative.
+	Node int
+	// WarmCache replicates the global repositories in the cache after the registry is restored.
+	WarmCache bool
+}
+
+// RestoreResult contains the results of a restore.
+type RestoreResult struct {
+	// Roots are the restored MFS roots of the registry.
+	Roots []*SnapshotRoot
+	// Warmed lists the repositories which are replicated in the cache.
+	Warmed []string
+}
+
+// Restore replaces the registry in the IPFS nodes with the roots in a snapshot or with a root CID,
+// e.g. to recover a node which lost its IPFS repository. The root content is fetched from the
+// IPFS network if the node does not have it.
+func (disco *Disco) Restore(ctx context.Context, target string, opts RestoreOptions) (*RestoreResult, error) {
+	if disco.cfg.CacheOnly {
+		return nil, errors.New("restoring is not supported in cache-only mode")
+	}
+	nodeClients := disco.getIpfsClient().NodeClients()
+	if opts.Node >= len(nodeClients) {
+		return nil, fmt.Errorf("there is no ipfs node #%d", opts.Node)
+	}
+	roots, err := disco.restoreRoots(ctx, target, len(nodeClients))
+	if err != nil {
+		return nil, err
+	}
+
+	result := &RestoreResult{}
+	for _, root := range roots {
+		if opts.Node >= 0 && root.Node != opts.Node {
+			continue
+		}
+		if root.Node >= len(nodeClients) {
+			return result, fmt.Errorf("there is no ipfs node #%d in snapshot %s", root.Node, target)
+		}
+		if err := restoreRoot(ctx, nodeClients[root.Node], root.Cid); err != nil {
+			return result, fmt.Errorf("failed to restore ipfs node #%d: %v", root.Node, err)
+		}
+		log.WithFields(log.Fields{
+			"node": root.Node,
+			"cid":  root.Cid,
+		}).Info("restored registry root")
+		result.Roots = append(result.Roots, root)
+	}
+	if len(result.Roots) == 0 {
+		return result, fmt.Errorf("no ipfs node to restore from %s", target)
+	}
+	if !opts.WarmCache {
+		return result, nil
+	}
+	repos, err := disco.ListGlobalRepos(ctx)
+	if err != nil {
+		return result, err
+	}
+	for _, repo := range repos {
+		if _, err := disco.Replicate(ctx, repo, ReplicateToSecondary); err != nil {
+			return result, err
+		}
+		result.Warmed = append(result.Warmed, repo)
+	}
+	return result, nil
+}
+
+// restoreRoots finds the roots of the snapshot with the target ID or uses the target as the root
+// of all of the nodes if it is a CID.
+func (disco *Disco) restoreRoots(ctx context.Context, target string, nodeCount int) ([]*SnapshotRoot, error) {
+	if utils.IsCID(target) {
+		var roots []*SnapshotRoot
+		for i := 0; i < nodeCount; i++ {
+			roots = append(roots, &SnapshotRoot{Node: i, Cid: target})
+		}
+		return roots, nil
+	}
+	snapshots, err := disco.ListSnapshots(ctx)
+	if err != nil {
+		return nil, err
+	}
+	for _, snapshot := range snapshots {
+		if snapshot.ID == target {
+			return snapshot.Nodes, nil
+		}
+	}
+	return nil, fmt.Errorf("'%s' is neither a snapshot nor a cid", target)
+}
+
+// restoreRoot replaces the registry in the node with the root. The root is checked before the
+// registry is removed, so that a missing root does not leave the node empty.
+func restoreRoot(ctx context.Context, nodeClient interfaces.IPFSFilesAPI, rootCid string) error {
+	rootPath := "/ipfs/" + rootCid
+	if _, err := nodeClient.FilesStat(ctx, rootPath); err != nil {
+		return fmt.Errorf("failed to find the root %s: %v", rootCid, err)
+	}
+	if err := nodeClient.FilesRm(ctx, registryBase, true); err != nil && !isNotExistErr(err) {
+		return fmt.Errorf("failed to remove the registry: %v", err)
+	}
+	if err := nodeClient.FilesMkdir(ctx, path.Dir(registryBase), ipfsapi.FilesMkdir.Parents(true)); err != nil {
+		return fmt.Errorf("failed to create the registry parent: %v", err)
+	}
+	if err := nodeClient.FilesCp(ctx, rootPath, registryBase); err != nil {
+		return fmt.Errorf("failed to copy the root %s: %v", rootCid, err)
+	}
+	return nil
+}
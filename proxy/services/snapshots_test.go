@@ -0,0 +1,81 @@
+package services
+
+import (
+	"encoding/json"
+	"time"
+
+	storagedriver "github.com/distribution/distribution/v3/registry/storage/driver"
+	"github.com/distribution/distribution/v3/registry/storage/driver/inmemory"
+	"github.com/forta-network/disco/interfaces"
+	"github.com/golang/mock/gomock"
+	ipfsapi "github.com/ipfs/go-ipfs-api"
+)
+
+func (s *Suite) TestSnapshots() {
+	// Given that there are old snapshots in the cache
+	// And one of them has the same root as the current registry
+	secondary := inmemory.New()
+	s.driver.EXPECT().Secondary().Return(secondary).AnyTimes()
+	oldTime := time.Now().Add(-time.Hour * 2).UTC()
+	for _, snapshot := range []*Snapshot{
+		{ID: oldTime.Format(snapshotIDFormat), Time: oldTime, Nodes: []*SnapshotRoot{{Node: 0, Cid: "old-root"}}},
+		{ID: oldTime.Add(time.Minute).Format(snapshotIDFormat), Time: oldTime.Add(time.Minute), Nodes: []*SnapshotRoot{{Node: 0, Cid: "new-root"}}},
+	} {
+		b, err := json.Marshal(snapshot)
+		s.r.NoError(err)
+		s.r.NoError(secondary.PutContent(s.ctx, makeSnapshotPath(snapshot.ID), b))
+	}
+	s.ipfsClient.EXPECT().NodeClients().Return([]interfaces.IPFSFilesAPI{s.ipfsNode}).AnyTimes()
+
+	// When a snapshot is taken
+	// Then the registry root should be pinned and recorded
+	s.ipfsNode.EXPECT().FilesStat(gomock.Any(), registryBase).Return(&ipfsapi.FilesStatObject{Hash: "new-root"}, nil)
+	s.ipfsNode.EXPECT().Pin(gomock.Any(), "/ipfs/new-root")
+	snapshot, err := s.disco.TakeSnapshot(s.ctx)
+	s.r.NoError(err)
+	s.r.Len(snapshot.Nodes, 1)
+	s.r.Equal("new-root", snapshot.Nodes[0].Cid)
+	snapshots, err := s.disco.ListSnapshots(s.ctx)
+	s.r.NoError(err)
+	s.r.Len(snapshots, 3)
+	s.r.Equal(snapshot.ID, snapshots[2].ID)
+
+	// When the snapshots older than an hour are pruned
+	// Then only the root which is not in the latest snapshot should be unpinned
+	s.ipfsNode.EXPECT().Unpin(gomock.Any(), "/ipfs/old-root")
+	pruned, err := s.disco.PruneSnapshots(s.ctx, time.Hour)
+	s.r.NoError(err)
+	s.r.Len(pruned, 2)
+	_, err = secondary.Stat(s.ctx, makeSnapshotPath(pruned[0].ID))
+	s.r.IsType(storagedriver.PathNotFoundError{}, err)
+	snapshots, err = s.disco.ListSnapshots(s.ctx)
+	s.r.NoError(err)
+	s.r.Len(snapshots, 1)
+}
+
+func (s *Suite) TestRestore() {
+	// Given a snapshot in the cache
+	secondary := inmemory.New()
+	s.driver.EXPECT().Secondary().Return(secondary).AnyTimes()
+	snapshot := &Snapshot{ID: "20221010T000000Z", Time: time.Now(), Nodes: []*SnapshotRoot{{Node: 0, Cid: testCidv1}}}
+	b, err := json.Marshal(snapshot)
+	s.r.NoError(err)
+	s.r.NoError(secondary.PutContent(s.ctx, makeSnapshotPath(snapshot.ID), b))
+	s.ipfsClient.EXPECT().NodeClients().Return([]interfaces.IPFSFilesAPI{s.ipfsNode}).AnyTimes()
+
+	// When the snapshot is restored
+	// Then the registry should be replaced with the root
+	s.ipfsNode.EXPECT().FilesStat(gomock.Any(), "/ipfs/"+testCidv1).Return(&ipfsapi.FilesStatObject{Hash: testCidv1}, nil)
+	s.ipfsNode.EXPECT().FilesRm(gomock.Any(), registryBase, true)
+	s.ipfsNode.EXPECT().FilesMkdir(gomock.Any(), "/docker/registry", gomock.Any())
+	s.ipfsNode.EXPECT().FilesCp(gomock.Any(), "/ipfs/"+testCidv1, registryBase)
+	result, err := s.disco.Restore(s.ctx, snapshot.ID, RestoreOptions{Node: -1})
+	s.r.NoError(err)
+	s.r.Len(result.Roots, 1)
+	s.r.Equal(testCidv1, result.Roots[0].Cid)
+
+	// When an unknown snapshot is restored
+	// Then it should fail before touching the registry
+	_, err = s.disco.Restore(s.ctx, "20221011T000000Z", RestoreOptions{Node: -1})
+	s.r.Error(err)
+}
@@ -0,0 +1,130 @@
+package services
+
+import (
+	"context"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// RepoStats contains the statistics of a global repository.
+type RepoStats struct {
+	Repository     string `json:"repository"`
+	ManifestDigest string `json:"manifestDigest,omitempty"`
+	Cid            string `json:"cid,omitempty"`
+	// Size is the cumulative size of the blobs.
+	Size      int64               `json:"size"`
+	BlobCount int                 `json:"blobCount"`
+	Blobs     []*InspectBlob      `json:"blobs"`
+	Stores    []*StoreReplication `json:"stores"`
+	// Replicated tells if the IPFS nodes and the cache hold the repository and all of its blobs.
+	Replicated bool       `json:"replicated"`
+	LastPush   *time.Time `json:"lastPush,omitempty"`
+	LastPull   *time.Time `json:"lastPull,omitempty"`
+}
+
+// Store kinds of the replication status. The content is routed to one of the IPFS nodes, so the
+// IPFS nodes are counted as one store.
+const (
+	storeKindIPFS  = "ipfs"
+	storeKindCache = "cache"
+)
+
+// StoreReplication is the replication status of a repository in a kind of store.
+type StoreReplication struct {
+	Store      string `json:"store"`
+	Repository bool   `json:"repository"`
+	Blobs      int    `json:"blobs"`
+}
+
+// RepoStats computes the statistics of the repository from the stores and the indexes. The
+// last pull time is the latest pull with the CID or the digest name.
+func (disco *Disco) RepoStats(ctx context.Context, repoName string) (*RepoStats, error) {
+	result, err := disco.Inspect(ctx, repoName)
+	if err != nil {
+		return nil, err
+	}
+	stats := &RepoStats{
+		Repository:     result.Repository,
+		ManifestDigest: result.ManifestDigest,
+		Cid:            result.Cid,
+		BlobCount:      len(result.Blobs),
+		Blobs:          result.Blobs,
+	}
+
+	replications := make(map[string]*StoreReplication)
+	var storeKinds []string
+	if !disco.cfg.CacheOnly {
+		storeKinds = append(storeKinds, storeKindIPFS)
+	}
+	if disco.cacheDriver() != nil {
+		storeKinds = append(storeKinds, storeKindCache)
+	}
+	for _, kind := range storeKinds {
+		replication := &StoreReplication{Store: kind}
+		replications[kind] = replication
+		stats.Stores = append(stats.Stores, replication)
+	}
+	for kind := range storeKindsOf(result.Stores) {
+		if replication, ok := replications[kind]; ok {
+			replication.Repository = true
+		}
+	}
+	for _, blob := range result.Blobs {
+		stats.Size += blob.Size
+		for kind := range storeKindsOf(blob.Stores) {
+			if replication, ok := replications[kind]; ok {
+				replication.Blobs++
+			}
+		}
+	}
+	stats.Replicated = true
+	for _, replication := range stats.Stores {
+		if !replication.Repository || replication.Blobs < stats.BlobCount {
+			stats.Replicated = false
+		}
+	}
+
+	if lastPush, ok := disco.cids.lastPush(stats.ManifestDigest); ok {
+		stats.LastPush = &lastPush
+	}
+	for _, name := range []string{stats.Repository, stats.ManifestDigest} {
+		lastPull, ok := disco.pulls.lastPull(name)
+		if ok && (stats.LastPull == nil || lastPull.After(*stats.LastPull)) {
+			stats.LastPull = &lastPull
+		}
+	}
+	return stats, nil
+}
+
+// storeKindsOf returns the kinds of the stores which are listed by locate.
+func storeKindsOf(stores []string) map[string]bool {
+	kinds := make(map[string]bool)
+	for _, store := range stores {
+		if store == storeKindCache {
+			kinds[storeKindCache] = true
+		} else {
+			kinds[storeKindIPFS] = true
+		}
+	}
+	return kinds
+}
+
+// ListRepoStats computes the statistics of all of the CID v1 repositories. The repositories
+// which cannot be inspected are skipped.
+func (disco *Disco) ListRepoStats(ctx context.Context) ([]*RepoStats, error) {
+	repos, err := disco.ListGlobalRepos(ctx)
+	if err != nil {
+		return nil, err
+	}
+	list := []*RepoStats{}
+	for _, repo := range repos {
+		stats, err := disco.RepoStats(ctx, repo)
+		if err != nil {
+			log.WithError(err).WithField("repository", repo).Warn("failed to compute the repository stats")
+			continue
+		}
+		list = append(list, stats)
+	}
+	return list, nil
+}
@@ -0,0 +1,45 @@
+package services
+
+import (
+	"path"
+
+	storagedriver "github.com/distribution/distribution/v3/registry/storage/driver"
+	"github.com/distribution/distribution/v3/registry/storage/driver/inmemory"
+	"github.com/forta-network/disco/config"
+)
+
+func (s *Suite) TestRepoStats() {
+	// Given a global repo in the cache which is missing its layer
+	// And was pushed and pulled with its digest
+	driver := inmemory.New()
+	s.r.NoError(driver.PutContent(s.ctx, s.disco.makeManifestLinkPath(testCidv1), []byte("sha256:"+testManifestDigest)))
+	s.r.NoError(driver.PutContent(s.ctx, makeDiscoFilePath(testCidv1), []byte(testDiscoFile)))
+	s.r.NoError(driver.PutContent(s.ctx, makeBlobPath(testManifestDigest), []byte(testManifest)))
+	s.r.NoError(driver.PutContent(s.ctx, makeBlobPath(testConfigDigest), []byte("config")))
+	s.disco.cfg = &config.Config{
+		CacheOnly: true,
+		PullIndex: path.Join(s.T().TempDir(), "pulls.json"),
+		CidIndex:  path.Join(s.T().TempDir(), "cids.db"),
+	}
+	s.disco.getDriver = func() storagedriver.StorageDriver {
+		return driver
+	}
+	s.disco.pulls = newPullIndex(s.disco.cfg.PullIndex, nil)
+	s.disco.cids = newCidIndex(s.disco.cfg.CidIndex, nil)
+	s.disco.cids.recordPush(testManifestDigest)
+	s.disco.RecordPull(testManifestDigest)
+
+	// When the stats are computed
+	stats, err := s.disco.RepoStats(s.ctx, testCidv1)
+
+	// Then they should show the missing layer and the push and pull times
+	s.r.NoError(err)
+	s.r.Equal(testManifestDigest, stats.ManifestDigest)
+	s.r.Equal(3, stats.BlobCount)
+	s.r.Equal(int64(len(testManifest)+len("config")), stats.Size)
+	s.r.Len(stats.Stores, 1)
+	s.r.Equal(&StoreReplication{Store: storeKindCache, Repository: true, Blobs: 2}, stats.Stores[0])
+	s.r.False(stats.Replicated)
+	s.r.NotNil(stats.LastPush)
+	s.r.NotNil(stats.LastPull)
+}
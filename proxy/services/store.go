@@ -0,0 +1,247 @@
+package services
+
+import (
+	"context"
+	"strconv"
+	"time"
+
+	"github.com/forta-network/disco/config"
+	"github.com/forta-network/disco/utils"
+	log "github.com/sirupsen/logrus"
+)
+
+// storeTimeout limits the requests to the shared store, since the indexes are updated and read
+// on the request paths.
+const storeTimeout = 5 * time.Second
+
+// Key prefixes of the shared state.
+const (
+	storeKeyCloned  = "cloned/"
+	storeKeyPulls   = "pulls/"
+	storeKeyRepos   = "repos/"
+	storeKeyCids    = "cids/"
+	storeKeyBlobs   = "blobs/"
+	storeKeyPushes  = "pushes/"
+	storeKeyUploads = "uploads/"
+	storeKeyRefs    = "refs/"
+	storeKeyNonces  = "nonces/"
+	// storeKeyBlobRefs keeps a set of the manifests which reference each blob, so that the
+	// references of a blob can be counted without listing the keys.
+	storeKeyBlobRefs = "blobrefs/"
+	// storeKeyBandwidth keeps a hash of the bytes served per repository and per client in
+	// each month.
+	storeKeyBandwidth = "bandwidth/"
+	// storeKeyAPIKeys keeps all of the API keys in one value since they are rarely changed.
+	storeKeyAPIKeys = "apikeys"
+	// storeKeyHTTPSecret keeps the HTTP secret which the registries of the replicas share.
+	storeKeyHTTPSecret = "httpsecret"
+)
+
+// sharedStore keeps the run-time state which the Disco replicas share, e.g. the indexes and
+// the clone cache, so that any replica can serve any request.
+type sharedStore interface {
+	get(ctx context.Context, key string) (string, bool, error)
+	// set sets the value of the key, which expires after the TTL unless it is zero.
+	set(ctx context.Context, key, value string, ttl time.Duration) error
+	// add sets the value of the key only if it is missing and tells if it was set. The key
+	// expires after the TTL unless it is zero.
+	add(ctx context.Context, key, value string, ttl time.Duration) (bool, error)
+	delete(ctx context.Context, key string) error
+	// incrField adds n to the field of the hash in the key and returns the new value. The key
+	// expires after the TTL unless it is zero.
+	incrField(ctx context.Context, key, field string, n int64, ttl time.Duration) (int64, error)
+	getField(ctx context.Context, key, field string) (string, bool, error)
+	getFields(ctx context.Context, key string) (map[string]string, error)
+	// addMember adds the member to the set in the key.
+	addMember(ctx context.Context, key, member string) error
+	removeMember(ctx context.Context, key, member string) error
+	members(ctx context.Context, key string) ([]string, error)
+}
+
+// newSharedStore creates the store of the configured provider, if any.
+func newSharedStore(stateCfg config.SharedStateConfig) sharedStore {
+	switch stateCfg.Provider {
+	case config.ProviderRedis:
+		return &redisStore{
+			client: utils.NewRedisClient(stateCfg.Addr, stateCfg.Password, stateCfg.DB),
+			prefix: stateCfg.Prefix,
+		}
+	default:
+		return nil
+	}
+}
+
+// storeContext returns the context of a request to the shared store.
+func storeContext() (context.Context, context.CancelFunc) {
+	return context.WithTimeout(context.Background(), storeTimeout)
+}
+
+// storeGet reads the key from the store. The errors are logged and treated as a missing key,
+// like a missing index entry.
+func storeGet(store sharedStore, key string) (string, bool) {
+	ctx, cancel := storeContext()
+	defer cancel()
+	value, ok, err := store.get(ctx, key)
+	if err != nil {
+		log.WithError(err).WithField("key", key).Warn("failed to read from the shared store")
+		return "", false
+	}
+	return value, ok
+}
+
+// storeSet writes the key to the store and logs the errors.
+func storeSet(store sharedStore, key, value string, ttl time.Duration) {
+	ctx, cancel := storeContext()
+	defer cancel()
+	if err := store.set(ctx, key, value, ttl); err != nil {
+		log.WithError(err).WithField("key", key).Error("failed to write to the shared store")
+	}
+}
+
+// storeDelete deletes the key from the store and logs the errors.
+func storeDelete(store sharedStore, key string) {
+	ctx, cancel := storeContext()
+	defer cancel()
+	if err := store.delete(ctx, key); err != nil {
+		log.WithError(err).WithField("key", key).Error("failed to delete from the shared store")
+	}
+}
+
+// storeAddMember adds the member to the set in the key and logs the errors.
+func storeAddMember(store sharedStore, key, member string) {
+	ctx, cancel := storeContext()
+	defer cancel()
+	if err := store.addMember(ctx, key, member); err != nil {
+		log.WithError(err).WithField("key", key).Error("failed to add to a set in the shared store")
+	}
+}
+
+// storeRemoveMember removes the member from the set in the key and logs the errors.
+func storeRemoveMember(store sharedStore, key, member string) {
+	ctx, cancel := storeContext()
+	defer cancel()
+	if err := store.removeMember(ctx, key, member); err != nil {
+		log.WithError(err).WithField("key", key).Error("failed to remove from a set in the shared store")
+	}
+}
+
+// storeGetTime reads a time which was written with storeSetTime.
+func storeGetTime(store sharedStore, key string) (time.Time, bool) {
+	value, ok := storeGet(store, key)
+	if !ok {
+		return time.Time{}, false
+	}
+	t, err := time.Parse(time.RFC3339Nano, value)
+	if err != nil {
+		log.WithError(err).WithField("key", key).Warn("invalid time in the shared store")
+		return time.Time{}, false
+	}
+	return t, true
+}
+
+func storeSetTime(store sharedStore, key string, t time.Time) {
+	storeSet(store, key, t.UTC().Format(time.RFC3339Nano), 0)
+}
+
+// redisStore keeps the shared state in Redis.
+type redisStore struct {
+	client *utils.RedisClient
+	prefix string
+}
+
+func (store *redisStore) get(ctx context.Context, key string) (string, bool, error) {
+	reply, err := store.client.Do(ctx, "GET", store.prefix+key)
+	if err != nil || reply == nil {
+		return "", false, err
+	}
+	value, _ := reply.(string)
+	return value, true, nil
+}
+
+func (store *redisStore) set(ctx context.Context, key, value string, ttl time.Duration) error {
+	args := []string{"SET", store.prefix + key, value}
+	if ttl > 0 {
+		args = append(args, "PX", strconv.FormatInt(ttl.Milliseconds(), 10))
+	}
+	_, err := store.client.Do(ctx, args...)
+	return err
+}
+
+func (store *redisStore) add(ctx context.Context, key, value string, ttl time.Duration) (bool, error) {
+	args := []string{"SET", store.prefix + key, value, "NX"}
+	if ttl > 0 {
+		args = append(args, "PX", strconv.FormatInt(ttl.Milliseconds(), 10))
+	}
+	reply, err := store.client.Do(ctx, args...)
+	if err != nil {
+		return false, err
+	}
+	return reply != nil, nil
+}
+
+func (store *redisStore) delete(ctx context.Context, key string) error {
+	_, err := store.client.Do(ctx, "DEL", store.prefix+key)
+	return err
+}
+
+func (store *redisStore) incrField(ctx context.Context, key, field string, n int64, ttl time.Duration) (int64, error) {
+	reply, err := store.client.Do(ctx, "HINCRBY", store.prefix+key, field, strconv.FormatInt(n, 10))
+	if err != nil {
+		return 0, err
+	}
+	value, _ := reply.(int64)
+	if ttl > 0 {
+		if _, err := store.client.Do(ctx, "PEXPIRE", store.prefix+key, strconv.FormatInt(ttl.Milliseconds(), 10)); err != nil {
+			return 0, err
+		}
+	}
+	return value, nil
+}
+
+func (store *redisStore) getField(ctx context.Context, key, field string) (string, bool, error) {
+	reply, err := store.client.Do(ctx, "HGET", store.prefix+key, field)
+	if err != nil || reply == nil {
+		return "", false, err
+	}
+	value, _ := reply.(string)
+	return value, true, nil
+}
+
+func (store *redisStore) getFields(ctx context.Context, key string) (map[string]string, error) {
+	reply, err := store.client.Do(ctx, "HGETALL", store.prefix+key)
+	if err != nil {
+		return nil, err
+	}
+	items, _ := reply.([]interface{})
+	fields := make(map[string]string, len(items)/2)
+	for i := 0; i+1 < len(items); i += 2 {
+		field, _ := items[i].(string)
+		value, _ := items[i+1].(string)
+		fields[field] = value
+	}
+	return fields, nil
+}
+
+func (store *redisStore) addMember(ctx context.Context, key, member string) error {
+	_, err := store.client.Do(ctx, "SADD", store.prefix+key, member)
+	return err
+}
+
+func (store *redisStore) removeMember(ctx context.Context, key, member string) error {
+	_, err := store.client.Do(ctx, "SREM", store.prefix+key, member)
+	return err
+}
+
+func (store *redisStore) members(ctx context.Context, key string) ([]string, error) {
+	reply, err := store.client.Do(ctx, "SMEMBERS", store.prefix+key)
+	if err != nil {
+		return nil, err
+	}
+	items, _ := reply.([]interface{})
+	members := make([]string, 0, len(items))
+	for _, item := range items {
+		member, _ := item.(string)
+		members = append(members, member)
+	}
+	return members, nil
+}
@@ -0,0 +1,178 @@
+package services
+
+import (
+	"context"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+// testStore is an in-memory shared store which ignores the TTLs.
+type testStore struct {
+	mu     sync.Mutex
+	keys   map[string]string
+	hashes map[string]map[string]string
+	sets   map[string]map[string]bool
+}
+
+func newTestStore() *testStore {
+	return &testStore{
+		keys:   make(map[string]string),
+		hashes: make(map[string]map[string]string),
+		sets:   make(map[string]map[string]bool),
+	}
+}
+
+func (store *testStore) get(ctx context.Context, key string) (string, bool, error) {
+	store.mu.Lock()
+	defer store.mu.Unlock()
+	value, ok := store.keys[key]
+	return value, ok, nil
+}
+
+func (store *testStore) set(ctx context.Context, key, value string, ttl time.Duration) error {
+	store.mu.Lock()
+	defer store.mu.Unlock()
+	store.keys[key] = value
+	return nil
+}
+
+func (store *testStore) add(ctx context.Context, key, value string, ttl time.Duration) (bool, error) {
+	store.mu.Lock()
+	defer store.mu.Unlock()
+	if _, ok := store.keys[key]; ok {
+		return false, nil
+	}
+	store.keys[key] = value
+	return true, nil
+}
+
+func (store *testStore) delete(ctx context.Context, key string) error {
+	store.mu.Lock()
+	defer store.mu.Unlock()
+	delete(store.keys, key)
+	return nil
+}
+
+func (store *testStore) incrField(ctx context.Context, key, field string, n int64, ttl time.Duration) (int64, error) {
+	store.mu.Lock()
+	defer store.mu.Unlock()
+	if store.hashes[key] == nil {
+		store.hashes[key] = make(map[string]string)
+	}
+	value, _ := strconv.ParseInt(store.hashes[key][field], 10, 64)
+	value += n
+	store.hashes[key][field] = strconv.FormatInt(value, 10)
+	return value, nil
+}
+
+func (store *testStore) getField(ctx context.Context, key, field string) (string, bool, error) {
+	store.mu.Lock()
+	defer store.mu.Unlock()
+	value, ok := store.hashes[key][field]
+	return value, ok, nil
+}
+
+func (store *testStore) getFields(ctx context.Context, key string) (map[string]string, error) {
+	store.mu.Lock()
+	defer store.mu.Unlock()
+	fields := make(map[string]string)
+	for field, value := range store.hashes[key] {
+		fields[field] = value
+	}
+	return fields, nil
+}
+
+func (store *testStore) addMember(ctx context.Context, key, member string) error {
+	store.mu.Lock()
+	defer store.mu.Unlock()
+	if store.sets[key] == nil {
+		store.sets[key] = make(map[string]bool)
+	}
+	store.sets[key][member] = true
+	return nil
+}
+
+func (store *testStore) removeMember(ctx context.Context, key, member string) error {
+	store.mu.Lock()
+	defer store.mu.Unlock()
+	delete(store.sets[key], member)
+	return nil
+}
+
+func (store *testStore) members(ctx context.Context, key string) ([]string, error) {
+	store.mu.Lock()
+	defer store.mu.Unlock()
+	var members []string
+	for member := range store.sets[key] {
+		members = append(members, member)
+	}
+	return members, nil
+}
+
+func TestSharedStore(t *testing.T) {
+	r := require.New(t)
+
+	// Given two replicas which share a store
+	store := newTestStore()
+	cids1, cids2 := newCidIndex("", store), newCidIndex("", store)
+	pulls1, pulls2 := newPullIndex("", store), newPullIndex("", store)
+	cloned1, cloned2 := newClonedRepos(time.Minute, store), newClonedRepos(time.Minute, store)
+
+	// When one of them updates the state
+	cids1.addRepo(testManifestDigest, testCidv1, []*blobCid{{Digest: testLayerDigest, Cid: testLayerCid}})
+	cids1.recordPush(testManifestDigest)
+	pulls1.record(testManifestDigest)
+	cloned1.add(testCidv1)
+
+	// Then the other one should see it
+	repoCid, ok := cids2.repoCid(testManifestDigest)
+	r.True(ok)
+	r.Equal(testCidv1, repoCid)
+	manifestDigest, ok := cids2.manifestDigest(testCidv1)
+	r.True(ok)
+	r.Equal(testManifestDigest, manifestDigest)
+	layerCid, ok := cids2.blobCid(testLayerDigest)
+	r.True(ok)
+	r.Equal(testLayerCid, layerCid)
+	_, ok = cids2.lastPush(testManifestDigest)
+	r.True(ok)
+	_, ok = pulls2.lastPull(testManifestDigest)
+	r.True(ok)
+	r.True(cloned2.has(testCidv1))
+
+	// And the removals should be shared, too
+	cids2.removeRepo(testManifestDigest)
+	pulls2.remove(testManifestDigest)
+	cloned2.remove(testCidv1)
+	_, ok = cids1.repoCid(testManifestDigest)
+	r.False(ok)
+	_, ok = cids1.manifestDigest(testCidv1)
+	r.False(ok)
+	_, ok = pulls1.lastPull(testManifestDigest)
+	r.False(ok)
+	r.False(cloned1.has(testCidv1))
+}
+
+func TestUseNonce(t *testing.T) {
+	r := require.New(t)
+
+	// Given two replicas which share a store
+	store := newTestStore()
+	disco1, disco2 := &Disco{nonces: store}, &Disco{nonces: store}
+	r.True(disco1.SharedNoncesEnabled())
+	r.False((&Disco{}).SharedNoncesEnabled())
+
+	// When one of them uses a nonce
+	added, err := disco1.UseNonce("some-nonce", time.Minute)
+	r.NoError(err)
+	r.True(added)
+
+	// Then the other one should not accept it again
+	added, err = disco2.UseNonce("some-nonce", time.Minute)
+	r.NoError(err)
+	r.False(added)
+}
@@ -0,0 +1,52 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	storagedriver "github.com/distribution/distribution/v3/registry/storage/driver"
+	"github.com/forta-network/disco/drivers"
+	"github.com/forta-network/disco/drivers/multidriver"
+)
+
+// Stores which the trees are walked in: the IPFS nodes are the primary store and the cache is
+// the secondary.
+const (
+	StorePrimary   = "primary"
+	StoreSecondary = "secondary"
+)
+
+// Tree walks a store from the root and returns its files with their sizes, so that the stores of
+// the replicas can be compared. The root should be under the registry root, which is the default.
+// The primary store is walked by default.
+func (disco *Disco) Tree(ctx context.Context, store, root string) ([]*drivers.TreeFile, error) {
+	if len(root) == 0 {
+		root = registryBase
+	}
+	if root != registryBase && !strings.HasPrefix(root, registryBase+"/") || strings.Contains(root, "..") {
+		return nil, fmt.Errorf("%w: '%s' is not a path under %s", ErrInvalidTree, root, registryBase)
+	}
+	driver, err := disco.storeDriver(store)
+	if err != nil {
+		return nil, err
+	}
+	return drivers.WalkTree(ctx, driver, root)
+}
+
+// storeDriver returns the driver of the store. The only store of the registry is both the
+// primary and the secondary store.
+func (disco *Disco) storeDriver(store string) (storagedriver.StorageDriver, error) {
+	driver := disco.getDriver()
+	multiDriver, ok := multidriver.Is(driver)
+	switch {
+	case store != "" && store != StorePrimary && store != StoreSecondary:
+		return nil, fmt.Errorf("%w: unknown store '%s'", ErrInvalidTree, store)
+	case !ok:
+		return driver, nil
+	case store == StoreSecondary:
+		return multiDriver.Secondary(), nil
+	default:
+		return multiDriver.Primary(), nil
+	}
+}
@@ -0,0 +1,31 @@
+package services
+
+import (
+	"github.com/distribution/distribution/v3/registry/storage/driver/inmemory"
+	"github.com/forta-network/disco/drivers"
+)
+
+func (s *Suite) TestTree() {
+	// Given that the stores have the blobs
+	primary := inmemory.New()
+	secondary := inmemory.New()
+	s.r.NoError(primary.PutContent(s.ctx, makeBlobPath(testLayerDigest), []byte("layer")))
+	s.r.NoError(secondary.PutContent(s.ctx, makeBlobPath(testConfigDigest), []byte("config")))
+	s.driver.EXPECT().Primary().Return(primary).AnyTimes()
+	s.driver.EXPECT().Secondary().Return(secondary).AnyTimes()
+
+	// When the trees are walked
+	// Then each store should have its own files
+	tree, err := s.disco.Tree(s.ctx, "", "")
+	s.r.NoError(err)
+	s.r.Equal([]*drivers.TreeFile{{Path: makeBlobPath(testLayerDigest), Size: 5}}, tree)
+	tree, err = s.disco.Tree(s.ctx, StoreSecondary, blobsBase)
+	s.r.NoError(err)
+	s.r.Equal([]*drivers.TreeFile{{Path: makeBlobPath(testConfigDigest), Size: 6}}, tree)
+
+	// And the unknown stores and the paths outside of the registry should be rejected
+	_, err = s.disco.Tree(s.ctx, "other", "")
+	s.r.ErrorIs(err, ErrInvalidTree)
+	_, err = s.disco.Tree(s.ctx, "", "/etc")
+	s.r.ErrorIs(err, ErrInvalidTree)
+}
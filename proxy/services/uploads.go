@@ -0,0 +1,230 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"path"
+	"strings"
+	"time"
+
+	storagedriver "github.com/distribution/distribution/v3/registry/storage/driver"
+	"github.com/forta-network/disco/drivers"
+	"github.com/forta-network/disco/interfaces"
+	log "github.com/sirupsen/logrus"
+)
+
+// Upload is an upload in an IPFS node or in the cache.
+type Upload struct {
+	ID string `json:"id"`
+	// Repository is empty for the uploads which were kept in IPFS before the uploads were
+	// namespaced per repository, unless the session of the upload is known.
+	Repository string    `json:"repository,omitempty"`
+	Store      string    `json:"store"`
+	StartedAt  time.Time `json:"startedAt"`
+	// UpdatedAt is when a chunk of the upload was written last, if the session is known.
+	UpdatedAt *time.Time `json:"updatedAt,omitempty"`
+	// Stale tells if the upload was started and written last before the age.
+	Stale bool `json:"stale"`
+}
+
+// storedUpload is an upload with the func which deletes it from its store.
+type storedUpload struct {
+	*Upload
+	delete func(ctx context.Context) error
+}
+
+// ListUploads returns the uploads in the IPFS nodes and the cache. The uploads which were
+// started before the given age and have no chunks written within it are stale.
+func (disco *Disco) ListUploads(ctx context.Context, olderThan time.Duration) ([]*Upload, error) {
+	var uploads []*Upload
+	err := disco.walkUploads(ctx, olderThan, func(upload *storedUpload) error {
+		uploads = append(uploads, upload.Upload)
+		return nil
+	})
+	return uploads, err
+}
+
+// PruneUploads deletes the uploads which were started before the given age from the IPFS nodes
+// and the cache. The uploads of the pushes in progress are kept as long as they are not older
+// or a chunk of them was written within the age.
+func (disco *Disco) PruneUploads(ctx context.Context, olderThan time.Duration, dryRun bool) ([]*Upload, error) {
+	var pruned []*Upload
+	err := disco.walkUploads(ctx, olderThan, func(upload *storedUpload) error {
+		if !upload.Stale {
+			return nil
+		}
+		if !dryRun {
+			if err := upload.delete(ctx); err != nil {
+				return fmt.Errorf("failed to delete upload %s from %s: %v", upload.ID, upload.Store, err)
+			}
+		}
+		pruned = append(pruned, upload.Upload)
+		return nil
+	})
+	return pruned, err
+}
+
+// walkUploads visits the uploads in the IPFS nodes and then the ones in the cache.
+func (disco *Disco) walkUploads(ctx context.Context, olderThan time.Duration, visit func(*storedUpload) error) error {
+	cutoff := time.Now().Add(-olderThan)
+
+	// the IPFS driver keeps the uploads per repository, see drivers.FixUploadPath
+	if !disco.cfg.CacheOnly {
+		for i, nodeClient := range disco.getIpfsClient().NodeClients() {
+			store := fmt.Sprintf("ipfs node #%d", i)
+			// the uploads which were started before the namespacing
+			if err := disco.walkNodeUploads(ctx, nodeClient, store, uploadsBase, "", cutoff, visit); err != nil {
+				return err
+			}
+			keys, err := nodeClient.FilesLs(ctx, repoUploadsBase)
+			if err != nil && isNotExistErr(err) {
+				continue
+			}
+			if err != nil {
+				return fmt.Errorf("failed to list uploads in %s: %v", store, err)
+			}
+			for _, key := range keys {
+				dir := repoUploadsBase + "/" + key.Name
+				repoName := drivers.RepoNameFromUploadsKey(key.Name)
+				if err := disco.walkNodeUploads(ctx, nodeClient, store, dir, repoName, cutoff, visit); err != nil {
+					return err
+				}
+			}
+		}
+	}
+
+	// the other drivers keep the uploads under the repositories
+	cacheDriver := disco.cacheDriver()
+	if cacheDriver == nil {
+		return nil
+	}
+	repoPaths, err := cacheDriver.List(ctx, repositoriesBase)
+	if _, ok := err.(storagedriver.PathNotFoundError); ok {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("failed to list repositories in cache: %v", err)
+	}
+	for _, repoPath := range repoPaths {
+		uploadPaths, err := cacheDriver.List(ctx, repoPath+"/"+uploadsDirName)
+		if _, ok := err.(storagedriver.PathNotFoundError); ok {
+			continue
+		}
+		if err != nil {
+			return fmt.Errorf("failed to list uploads in cache: %v", err)
+		}
+		repoName := strings.TrimPrefix(repoPath, repositoriesBase+"/")
+		for _, uploadPath := range uploadPaths {
+			uploadPath := uploadPath
+			id := path.Base(uploadPath)
+			b, err := cacheDriver.GetContent(ctx, uploadPath+"/"+uploadStartedAtName)
+			if err != nil {
+				log.WithError(err).WithField("upload", id).Warn("failed to read upload start time - skipping")
+				continue
+			}
+			startedAt, err := parseStartedAt(b)
+			if err != nil {
+				log.WithError(err).WithField("upload", id).Warn("failed to parse upload start time - skipping")
+				continue
+			}
+			err = visit(&storedUpload{
+				Upload: disco.newUpload(id, repoName, "cache", startedAt, cutoff),
+				delete: func(ctx context.Context) error {
+					return cacheDriver.Delete(ctx, uploadPath)
+				},
+			})
+			if err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// walkNodeUploads visits the uploads in a dir of an IPFS node.
+func (disco *Disco) walkNodeUploads(ctx context.Context, nodeClient interfaces.IPFSFilesAPI, store, dir, repoName string,
+	cutoff time.Time, visit func(*storedUpload) error) error {
+	entries, err := nodeClient.FilesLs(ctx, dir)
+	if err != nil && isNotExistErr(err) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("failed to list uploads in %s: %v", store, err)
+	}
+	for _, entry := range entries {
+		// the namespaced uploads are visited separately
+		if dir == uploadsBase && uploadsBase+"/"+entry.Name == repoUploadsBase {
+			continue
+		}
+		uploadPath := dir + "/" + entry.Name
+		r, err := nodeClient.FilesRead(ctx, uploadPath+"/"+uploadStartedAtName)
+		if err != nil {
+			log.WithError(err).WithField("upload", entry.Name).Warn("failed to read upload start time - skipping")
+			continue
+		}
+		startedAt, err := readStartedAt(r)
+		if err != nil {
+			log.WithError(err).WithField("upload", entry.Name).Warn("failed to parse upload start time - skipping")
+			continue
+		}
+		err = visit(&storedUpload{
+			Upload: disco.newUpload(entry.Name, repoName, store, startedAt, cutoff),
+			delete: func(ctx context.Context) error {
+				return nodeClient.FilesRm(ctx, uploadPath, true)
+			},
+		})
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// newUpload makes the upload with its session, if any. The upload is stale if it was
+// started and written last before the cutoff.
+func (disco *Disco) newUpload(id, repoName, store string, startedAt, cutoff time.Time) *Upload {
+	upload := &Upload{ID: id, Repository: repoName, Store: store, StartedAt: startedAt}
+	if session, ok := disco.uploads.get(id); ok {
+		updatedAt := session.UpdatedAt
+		upload.UpdatedAt = &updatedAt
+		if len(upload.Repository) == 0 {
+			upload.Repository = session.Repository
+		}
+	}
+	upload.Stale = !startedAt.After(cutoff) && (upload.UpdatedAt == nil || !upload.UpdatedAt.After(cutoff))
+	return upload
+}
+
+// RunUploadPruner prunes the uploads periodically by using the config, until the context is done.
+func (disco *Disco) RunUploadPruner(ctx context.Context) {
+	ticker := time.NewTicker(disco.cfg.PruneUploads.Interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+		pruned, err := disco.PruneUploads(ctx, disco.cfg.PruneUploads.Age, false)
+		if err != nil {
+			log.WithError(err).Error("failed to prune uploads")
+		}
+		if len(pruned) > 0 {
+			log.WithField("uploads", len(pruned)).Info("pruned aborted uploads")
+		}
+	}
+}
+
+func readStartedAt(r io.ReadCloser) (time.Time, error) {
+	defer r.Close()
+	b, err := io.ReadAll(r)
+	if err != nil {
+		return time.Time{}, err
+	}
+	return parseStartedAt(b)
+}
+
+func parseStartedAt(b []byte) (time.Time, error) {
+	return time.Parse(time.RFC3339, strings.TrimSpace(string(b)))
+}
@@ -0,0 +1,88 @@
+package services
+
+import (
+	"bytes"
+	"io"
+	"time"
+
+	"github.com/distribution/distribution/v3/registry/storage/driver/inmemory"
+	"github.com/forta-network/disco/config"
+	"github.com/forta-network/disco/interfaces"
+	"github.com/golang/mock/gomock"
+	ipfsapi "github.com/ipfs/go-ipfs-api"
+)
+
+func (s *Suite) TestPruneUploads() {
+	oldStartedAt := time.Now().Add(-time.Hour * 2).UTC().Format(time.RFC3339)
+	newStartedAt := time.Now().UTC().Format(time.RFC3339)
+
+	// Given that there are old and new uploads in IPFS and in the cache
+	s.ipfsClient.EXPECT().NodeClients().Return([]interfaces.IPFSFilesAPI{s.ipfsNode})
+	s.ipfsNode.EXPECT().FilesLs(gomock.Any(), uploadsBase).
+		Return([]*ipfsapi.MfsLsEntry{{Name: "old-upload"}, {Name: "new-upload"}, {Name: "repos"}}, nil)
+	s.ipfsNode.EXPECT().FilesRead(gomock.Any(), uploadsBase+"/old-upload/startedat").
+		Return(io.NopCloser(bytes.NewBufferString(oldStartedAt)), nil)
+	s.ipfsNode.EXPECT().FilesRead(gomock.Any(), uploadsBase+"/new-upload/startedat").
+		Return(io.NopCloser(bytes.NewBufferString(newStartedAt)), nil)
+	s.ipfsNode.EXPECT().FilesLs(gomock.Any(), repoUploadsBase).
+		Return([]*ipfsapi.MfsLsEntry{{Name: "myorg+myrepo"}}, nil)
+	s.ipfsNode.EXPECT().FilesLs(gomock.Any(), repoUploadsBase+"/myorg+myrepo").
+		Return([]*ipfsapi.MfsLsEntry{{Name: "old-repo-upload"}}, nil)
+	s.ipfsNode.EXPECT().FilesRead(gomock.Any(), repoUploadsBase+"/myorg+myrepo/old-repo-upload/startedat").
+		Return(io.NopCloser(bytes.NewBufferString(oldStartedAt)), nil)
+	secondary := inmemory.New()
+	s.driver.EXPECT().Secondary().Return(secondary).AnyTimes()
+	oldCacheUpload := makeRepoPath("myrepo") + "/_uploads/old-cache-upload"
+	newCacheUpload := makeRepoPath("myrepo") + "/_uploads/new-cache-upload"
+	s.r.NoError(secondary.PutContent(s.ctx, oldCacheUpload+"/startedat", []byte(oldStartedAt)))
+	s.r.NoError(secondary.PutContent(s.ctx, newCacheUpload+"/startedat", []byte(newStartedAt)))
+
+	// When the uploads older than an hour are pruned
+	// Then only the old uploads should be deleted
+	s.ipfsNode.EXPECT().FilesRm(gomock.Any(), uploadsBase+"/old-upload", true)
+	s.ipfsNode.EXPECT().FilesRm(gomock.Any(), repoUploadsBase+"/myorg+myrepo/old-repo-upload", true)
+
+	pruned, err := s.disco.PruneUploads(s.ctx, time.Hour, false)
+	s.r.NoError(err)
+	s.r.Len(pruned, 3)
+	s.r.Equal("old-upload", pruned[0].ID)
+	s.r.Empty(pruned[0].Repository)
+	s.r.Equal("old-repo-upload", pruned[1].ID)
+	s.r.Equal("myorg/myrepo", pruned[1].Repository)
+	s.r.Equal("old-cache-upload", pruned[2].ID)
+	s.r.Equal("myrepo", pruned[2].Repository)
+	_, err = secondary.Stat(s.ctx, oldCacheUpload)
+	s.r.Error(err)
+	_, err = secondary.Stat(s.ctx, newCacheUpload)
+	s.r.NoError(err)
+}
+
+func (s *Suite) TestListUploads() {
+	startedAt := time.Now().Add(-time.Hour * 2).UTC()
+
+	// Given that there is an old upload in the cache which was written recently
+	s.disco.cfg = &config.Config{CacheOnly: true}
+	s.disco.uploads = newUploadSessions(nil)
+	secondary := inmemory.New()
+	s.driver.EXPECT().Secondary().Return(secondary).AnyTimes()
+	s.r.NoError(secondary.PutContent(s.ctx, makeRepoPath("myrepo")+"/_uploads/some-uuid/startedat",
+		[]byte(startedAt.Format(time.RFC3339))))
+	s.disco.RecordUploadSession("myrepo", "some-uuid", 10)
+
+	// When the uploads are listed
+	uploads, err := s.disco.ListUploads(s.ctx, time.Hour)
+
+	// Then the upload should not be stale
+	s.r.NoError(err)
+	s.r.Len(uploads, 1)
+	s.r.Equal("some-uuid", uploads[0].ID)
+	s.r.Equal("myrepo", uploads[0].Repository)
+	s.r.NotNil(uploads[0].UpdatedAt)
+	s.r.False(uploads[0].Stale)
+
+	// And it should be stale after the session is ended
+	s.disco.EndUploadSession("some-uuid")
+	uploads, err = s.disco.ListUploads(s.ctx, time.Hour)
+	s.r.NoError(err)
+	s.r.True(uploads[0].Stale)
+}
@@ -0,0 +1,158 @@
+package services
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/forta-network/disco/drivers"
+	log "github.com/sirupsen/logrus"
+)
+
+// uploadSessionTTL is how long an upload session is kept after its last chunk.
+const uploadSessionTTL = 24 * time.Hour
+
+// UploadSession is the state of a chunked blob upload. It is kept in the shared store, if there
+// is one, so that the chunks of an upload can be sent to any Disco replica.
+type UploadSession struct {
+	ID         string `json:"id"`
+	Repository string `json:"repository"`
+	// Path is where the upload is kept in the storage.
+	Path string `json:"path"`
+	// Offset is the size of the chunks which are written so far.
+	Offset    int64     `json:"offset"`
+	UpdatedAt time.Time `json:"updatedAt"`
+}
+
+// uploadSessions keeps the upload sessions in the shared store or in the memory.
+type uploadSessions struct {
+	store sharedStore
+
+	mu       sync.Mutex
+	sessions map[string]*UploadSession
+}
+
+func newUploadSessions(store sharedStore) *uploadSessions {
+	return &uploadSessions{
+		store:    store,
+		sessions: make(map[string]*UploadSession),
+	}
+}
+
+func (uploads *uploadSessions) get(id string) (*UploadSession, bool) {
+	if uploads == nil {
+		return nil, false
+	}
+	if uploads.store != nil {
+		value, ok := storeGet(uploads.store, storeKeyUploads+id)
+		if !ok {
+			return nil, false
+		}
+		var session UploadSession
+		if err := json.Unmarshal([]byte(value), &session); err != nil {
+			log.WithError(err).WithField("upload", id).Warn("invalid upload session in the shared store")
+			return nil, false
+		}
+		return &session, true
+	}
+	uploads.mu.Lock()
+	defer uploads.mu.Unlock()
+	session, ok := uploads.sessions[id]
+	if !ok || time.Since(session.UpdatedAt) > uploadSessionTTL {
+		return nil, false
+	}
+	copied := *session
+	return &copied, true
+}
+
+func (uploads *uploadSessions) set(session *UploadSession) {
+	if uploads == nil {
+		return
+	}
+	if uploads.store != nil {
+		b, _ := json.Marshal(session)
+		storeSet(uploads.store, storeKeyUploads+session.ID, string(b), uploadSessionTTL)
+		return
+	}
+	uploads.mu.Lock()
+	defer uploads.mu.Unlock()
+	// drop the expired sessions while setting so that the sessions do not grow indefinitely
+	for id, other := range uploads.sessions {
+		if time.Since(other.UpdatedAt) > uploadSessionTTL {
+			delete(uploads.sessions, id)
+		}
+	}
+	uploads.sessions[session.ID] = session
+}
+
+func (uploads *uploadSessions) remove(id string) {
+	if uploads == nil {
+		return
+	}
+	if uploads.store != nil {
+		storeDelete(uploads.store, storeKeyUploads+id)
+		return
+	}
+	uploads.mu.Lock()
+	defer uploads.mu.Unlock()
+	delete(uploads.sessions, id)
+}
+
+// RecordUploadSession records the offset of the upload after a chunk of it is written.
+func (disco *Disco) RecordUploadSession(repoName, id string, offset int64) {
+	uploadPath := makeRepoPath(repoName) + "/" + uploadsDirName + "/" + id
+	if !disco.cfg.CacheOnly {
+		uploadPath = repoUploadsBase + "/" + drivers.RepoUploadsKey(repoName) + "/" + id // see drivers.FixUploadPath
+	}
+	disco.uploads.set(&UploadSession{
+		ID:         id,
+		Repository: repoName,
+		Path:       uploadPath,
+		Offset:     offset,
+		UpdatedAt:  time.Now().UTC(),
+	})
+}
+
+// EndUploadSession forgets the upload after it is completed or canceled.
+func (disco *Disco) EndUploadSession(id string) {
+	disco.uploads.remove(id)
+}
+
+// UploadSession returns the state of the upload if it is in progress.
+func (disco *Disco) UploadSession(id string) (*UploadSession, bool) {
+	return disco.uploads.get(id)
+}
+
+// ShareHTTPSecret makes the replicas which share a store use the same HTTP secret, since the
+// registry signs the state of the uploads with it and rejects the chunks of the uploads which
+// were started on the replicas with other secrets. The secret is created by the first replica.
+// It does nothing if the secret is configured or there is no shared store.
+func (disco *Disco) ShareHTTPSecret(ctx context.Context) error {
+	distrConfig := disco.cfg.Distribution
+	if distrConfig == nil || len(distrConfig.HTTP.Secret) > 0 || disco.uploads == nil || disco.uploads.store == nil {
+		return nil
+	}
+	store := disco.uploads.store
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return fmt.Errorf("failed to generate the http secret: %v", err)
+	}
+	ctx, cancel := context.WithTimeout(ctx, storeTimeout)
+	defer cancel()
+	if _, err := store.add(ctx, storeKeyHTTPSecret, hex.EncodeToString(b), 0); err != nil {
+		return fmt.Errorf("failed to create the shared http secret: %v", err)
+	}
+	secret, ok, err := store.get(ctx, storeKeyHTTPSecret)
+	if err != nil {
+		return fmt.Errorf("failed to read the shared http secret: %v", err)
+	}
+	if !ok {
+		return fmt.Errorf("shared http secret is missing")
+	}
+	distrConfig.HTTP.Secret = secret
+	return nil
+}
@@ -0,0 +1,52 @@
+package services
+
+import (
+	"context"
+	"testing"
+
+	"github.com/distribution/distribution/v3/configuration"
+	"github.com/forta-network/disco/config"
+	"github.com/stretchr/testify/require"
+)
+
+func TestUploadSessions(t *testing.T) {
+	r := require.New(t)
+
+	// Given two replicas which share a store
+	store := newTestStore()
+	newReplica := func() *Disco {
+		return &Disco{
+			cfg:     &config.Config{Distribution: &configuration.Configuration{}},
+			uploads: newUploadSessions(store),
+		}
+	}
+	disco1, disco2 := newReplica(), newReplica()
+
+	// When an upload is started and continued in one of them
+	disco1.RecordUploadSession("myrepo", "some-uuid", 0)
+	disco1.RecordUploadSession("myrepo", "some-uuid", 1024)
+
+	// Then the other one should see its offset
+	session, ok := disco2.UploadSession("some-uuid")
+	r.True(ok)
+	r.Equal("myrepo", session.Repository)
+	r.Equal(repoUploadsBase+"/myrepo/some-uuid", session.Path)
+	r.Equal(int64(1024), session.Offset)
+
+	// And it should be forgotten after it is completed in the other one
+	disco2.EndUploadSession("some-uuid")
+	_, ok = disco1.UploadSession("some-uuid")
+	r.False(ok)
+
+	// And the replicas should sign the upload states with the same secret
+	r.NoError(disco1.ShareHTTPSecret(context.Background()))
+	r.NoError(disco2.ShareHTTPSecret(context.Background()))
+	r.NotEmpty(disco1.cfg.Distribution.HTTP.Secret)
+	r.Equal(disco1.cfg.Distribution.HTTP.Secret, disco2.cfg.Distribution.HTTP.Secret)
+
+	// And the configured secret should be kept
+	disco3 := newReplica()
+	disco3.cfg.Distribution.HTTP.Secret = "configured"
+	r.NoError(disco3.ShareHTTPSecret(context.Background()))
+	r.Equal("configured", disco3.cfg.Distribution.HTTP.Secret)
+}
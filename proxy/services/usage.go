@@ -0,0 +1,45 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	storagedriver "github.com/distribution/distribution/v3/registry/storage/driver"
+)
+
+// usageCacheTTL is how long the computed storage usage is reused, since walking the storage is slow.
+const usageCacheTTL = time.Minute * 5
+
+// StorageUsage returns the total size of the blobs in the storage.
+func (disco *Disco) StorageUsage(ctx context.Context) (int64, error) {
+	disco.usageMu.Lock()
+	defer disco.usageMu.Unlock()
+	if !disco.usageAt.IsZero() && time.Since(disco.usageAt) < usageCacheTTL {
+		return disco.usage, nil
+	}
+	size, err := walkSize(ctx, disco.getDriver(), blobsBase)
+	if err != nil {
+		return 0, fmt.Errorf("failed to compute the storage usage: %v", err)
+	}
+	disco.usage = size
+	disco.usageAt = time.Now()
+	return size, nil
+}
+
+// walkSize returns the total size of the files under the path.
+func walkSize(ctx context.Context, driver storagedriver.StorageDriver, path string) (int64, error) {
+	var size int64
+	err := driver.Walk(ctx, path, func(fileInfo storagedriver.FileInfo) error {
+		if !fileInfo.IsDir() {
+			size += fileInfo.Size()
+		}
+		return nil
+	})
+	switch err.(type) {
+	case nil, storagedriver.PathNotFoundError:
+		return size, nil
+	default:
+		return 0, err
+	}
+}
@@ -0,0 +1,32 @@
+package services
+
+import (
+	"time"
+
+	storagedriver "github.com/distribution/distribution/v3/registry/storage/driver"
+	"github.com/distribution/distribution/v3/registry/storage/driver/inmemory"
+)
+
+func (s *Suite) TestStorageUsage() {
+	driver := inmemory.New()
+	s.disco.getDriver = func() storagedriver.StorageDriver {
+		return driver
+	}
+
+	usage, err := s.disco.StorageUsage(s.ctx)
+	s.r.NoError(err)
+	s.r.Zero(usage)
+	s.disco.usageAt = time.Time{}
+
+	s.r.NoError(driver.PutContent(s.ctx, makeBlobPath(testConfigDigest), []byte("config")))
+	s.r.NoError(driver.PutContent(s.ctx, makeBlobPath(testLayerDigest), []byte("layer")))
+	usage, err = s.disco.StorageUsage(s.ctx)
+	s.r.NoError(err)
+	s.r.Equal(int64(11), usage)
+
+	// should reuse the computed usage
+	s.r.NoError(driver.PutContent(s.ctx, makeBlobPath(testManifestDigest), []byte("manifest")))
+	usage, err = s.disco.StorageUsage(s.ctx)
+	s.r.NoError(err)
+	s.r.Equal(int64(11), usage)
+}
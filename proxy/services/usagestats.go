@@ -0,0 +1,200 @@
+package services
+
+import (
+	"bytes"
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/forta-network/disco/config"
+	"github.com/hashicorp/go-multierror"
+	log "github.com/sirupsen/logrus"
+)
+
+// usageStatsBase is where the usage reports are written in the cache, outside of the registry.
+const usageStatsBase = "/disco/usage"
+
+// RepoUsage contains the usage of a repository through the proxy.
+type RepoUsage struct {
+	Repository    string `json:"repository"`
+	Pulls         int64  `json:"pulls"`
+	Pushes        int64  `json:"pushes"`
+	BytesServed   int64  `json:"bytesServed"`
+	BytesReceived int64  `json:"bytesReceived"`
+}
+
+func (usage *RepoUsage) add(other *RepoUsage) {
+	usage.Pulls += other.Pulls
+	usage.Pushes += other.Pushes
+	usage.BytesServed += other.BytesServed
+	usage.BytesReceived += other.BytesReceived
+}
+
+// UsageReport contains the usage of the repositories in a period.
+type UsageReport struct {
+	Instance     string       `json:"instance"`
+	From         time.Time    `json:"from"`
+	To           time.Time    `json:"to"`
+	Repositories []*RepoUsage `json:"repositories"`
+}
+
+// usageStats counts the usage of the repositories in memory until it is exported. It does nothing
+// if it is nil.
+type usageStats struct {
+	mu    sync.Mutex
+	from  time.Time
+	repos map[string]*RepoUsage
+}
+
+func newUsageStats() *usageStats {
+	return &usageStats{
+		from:  time.Now().UTC(),
+		repos: make(map[string]*RepoUsage),
+	}
+}
+
+func (stats *usageStats) record(usage *RepoUsage) {
+	if stats == nil {
+		return
+	}
+	stats.mu.Lock()
+	defer stats.mu.Unlock()
+	repoUsage, ok := stats.repos[usage.Repository]
+	if !ok {
+		repoUsage = &RepoUsage{Repository: usage.Repository}
+		stats.repos[usage.Repository] = repoUsage
+	}
+	repoUsage.add(usage)
+}
+
+// drain returns the report of the usage since the last drain and starts counting again.
+func (stats *usageStats) drain() *UsageReport {
+	stats.mu.Lock()
+	defer stats.mu.Unlock()
+	report := &UsageReport{From: stats.from, To: time.Now().UTC()}
+	for _, usage := range stats.repos {
+		report.Repositories = append(report.Repositories, usage)
+	}
+	sort.Slice(report.Repositories, func(i, j int) bool {
+		return report.Repositories[i].Repository < report.Repositories[j].Repository
+	})
+	stats.from = report.To
+	stats.repos = make(map[string]*RepoUsage)
+	return report
+}
+
+// restore counts the usage in the report again, e.g. after the report failed to be exported.
+func (stats *usageStats) restore(report *UsageReport) {
+	stats.mu.Lock()
+	stats.from = report.From
+	stats.mu.Unlock()
+	for _, usage := range report.Repositories {
+		stats.record(usage)
+	}
+}
+
+// UsageStatsEnabled tells if the usage of the repositories is counted.
+func (disco *Disco) UsageStatsEnabled() bool {
+	return disco.usageStats != nil
+}
+
+// RecordUsage counts the usage of a repository, if the usage statistics are enabled.
+func (disco *Disco) RecordUsage(usage *RepoUsage) {
+	disco.usageStats.record(usage)
+}
+
+// ExportUsageStats writes the report of the usage since the last export to the cache and sends
+// it to the webhook, if they are configured. The usage is counted again for the next export if
+// the report cannot be exported.
+func (disco *Disco) ExportUsageStats(ctx context.Context) (*UsageReport, error) {
+	if disco.usageStats == nil {
+		return nil, nil
+	}
+	report := disco.usageStats.drain()
+	report.Instance, _ = os.Hostname()
+	var (
+		errs     *multierror.Error
+		exported bool
+	)
+	if cacheDriver := disco.cacheDriver(); cacheDriver != nil {
+		format := disco.cfg.UsageStats.Format
+		b, err := encodeUsageReport(report, format)
+		if err == nil {
+			err = cacheDriver.PutContent(ctx, makeUsageReportPath(report, format), b)
+		}
+		if err != nil {
+			errs = multierror.Append(errs, fmt.Errorf("failed to write the usage report: %v", err))
+		} else {
+			exported = true
+		}
+	}
+	if webhook := disco.cfg.UsageStats.Webhook; len(webhook) > 0 {
+		if err := disco.postWebhook(ctx, webhook, report); err != nil {
+			errs = multierror.Append(errs, fmt.Errorf("failed to send the usage report: %v", err))
+		} else {
+			exported = true
+		}
+	}
+	if !exported {
+		disco.usageStats.restore(report)
+	}
+	return report, errs.ErrorOrNil()
+}
+
+// makeUsageReportPath returns the path of the report, which is unique to the instance since the
+// replicas can write to the same cache.
+func makeUsageReportPath(report *UsageReport, format string) string {
+	name := report.From.Format(snapshotIDFormat)
+	if len(report.Instance) > 0 {
+		name += "-" + report.Instance
+	}
+	return path.Join(usageStatsBase, name+"."+format)
+}
+
+func encodeUsageReport(report *UsageReport, format string) ([]byte, error) {
+	if format != config.UsageStatsFormatCSV {
+		return json.Marshal(report)
+	}
+	var buf bytes.Buffer
+	w := csv.NewWriter(&buf)
+	_ = w.Write([]string{"from", "to", "repository", "pulls", "pushes", "bytes_served", "bytes_received"})
+	from, to := report.From.Format(time.RFC3339), report.To.Format(time.RFC3339)
+	for _, usage := range report.Repositories {
+		_ = w.Write([]string{
+			from, to, usage.Repository,
+			strconv.FormatInt(usage.Pulls, 10),
+			strconv.FormatInt(usage.Pushes, 10),
+			strconv.FormatInt(usage.BytesServed, 10),
+			strconv.FormatInt(usage.BytesReceived, 10),
+		})
+	}
+	w.Flush()
+	return buf.Bytes(), w.Error()
+}
+
+// RunUsageStatsExporter exports the usage statistics periodically by using the config, until the
+// context is done.
+func (disco *Disco) RunUsageStatsExporter(ctx context.Context) {
+	ticker := time.NewTicker(disco.cfg.UsageStats.Interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+		report, err := disco.ExportUsageStats(ctx)
+		if err != nil {
+			log.WithError(err).Error("failed to export the usage statistics")
+			continue
+		}
+		log.WithField("repositories", len(report.Repositories)).Info("exported usage statistics")
+	}
+}
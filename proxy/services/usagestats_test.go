@@ -0,0 +1,50 @@
+package services
+
+import (
+	"strings"
+
+	"github.com/distribution/distribution/v3/registry/storage/driver/inmemory"
+	"github.com/forta-network/disco/config"
+)
+
+func (s *Suite) TestExportUsageStats() {
+	// Given the usage of two repositories
+	s.disco.cfg = &config.Config{UsageStats: config.UsageStatsConfig{Format: config.UsageStatsFormatCSV}}
+	s.disco.usageStats = newUsageStats()
+	s.disco.RecordUsage(&RepoUsage{Repository: testCidv1, Pulls: 1, BytesServed: 100})
+	s.disco.RecordUsage(&RepoUsage{Repository: testCidv1, Pulls: 1, BytesServed: 50})
+	s.disco.RecordUsage(&RepoUsage{Repository: "myrepo", Pushes: 1, BytesReceived: 10})
+	secondary := inmemory.New()
+	s.driver.EXPECT().Secondary().Return(secondary).AnyTimes()
+
+	// When the usage is exported
+	report, err := s.disco.ExportUsageStats(s.ctx)
+
+	// Then the report should be written to the cache as csv
+	s.r.NoError(err)
+	s.r.Len(report.Repositories, 2)
+	b, err := secondary.GetContent(s.ctx, makeUsageReportPath(report, config.UsageStatsFormatCSV))
+	s.r.NoError(err)
+	lines := strings.Split(strings.TrimSpace(string(b)), "\n")
+	s.r.Len(lines, 3)
+	s.r.True(strings.HasSuffix(lines[1], ","+testCidv1+",2,0,150,0"), lines[1])
+	s.r.True(strings.HasSuffix(lines[2], ",myrepo,0,1,0,10"), lines[2])
+
+	// And the usage should be counted from zero again
+	report, err = s.disco.ExportUsageStats(s.ctx)
+	s.r.NoError(err)
+	s.r.Empty(report.Repositories)
+}
+
+func (s *Suite) TestUsageStatsRestore() {
+	stats := newUsageStats()
+	stats.record(&RepoUsage{Repository: "myrepo", Pulls: 1})
+	report := stats.drain()
+	stats.record(&RepoUsage{Repository: "myrepo", Pulls: 1})
+
+	stats.restore(report)
+	restored := stats.drain()
+	s.r.Equal(report.From, restored.From)
+	s.r.Equal(int64(2), restored.Repositories[0].Pulls)
+	s.r.True(restored.To.After(report.To) || restored.To.Equal(report.To))
+}
@@ -0,0 +1,272 @@
+package services
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"path"
+	"sort"
+	"strings"
+
+	storagedriver "github.com/distribution/distribution/v3/registry/storage/driver"
+	"github.com/forta-network/disco/drivers/multidriver"
+	"github.com/forta-network/disco/interfaces"
+	"github.com/forta-network/disco/utils"
+	ipfsapi "github.com/ipfs/go-ipfs-api"
+)
+
+// VerifyResult contains the problems found in a repository by Verify.
+type VerifyResult struct {
+	Repository string
+	// Problems are the problems which were not repaired.
+	Problems []string
+	// Repaired are the problems which were repaired.
+	Repaired []string
+}
+
+// ListGlobalRepos lists the CID v1 repositories in all of the IPFS nodes and the cache.
+func (disco *Disco) ListGlobalRepos(ctx context.Context) ([]string, error) {
+	repos, err := disco.listRepos(ctx)
+	if err != nil {
+		return nil, err
+	}
+	var list []string
+	for _, repo := range repos {
+		if utils.IsCIDv1(repo) {
+			list = append(list, repo)
+		}
+	}
+	return list, nil
+}
+
+// listRepos lists all of the repositories in the IPFS nodes and the cache.
+func (disco *Disco) listRepos(ctx context.Context) ([]string, error) {
+	repos := make(map[string]bool)
+	if !disco.cfg.CacheOnly {
+		for _, nodeClient := range disco.getIpfsClient().NodeClients() {
+			entries, err := nodeClient.FilesLs(ctx, repositoriesBase)
+			if err != nil && isNotExistErr(err) {
+				continue
+			}
+			if err != nil {
+				return nil, fmt.Errorf("failed to list repositories: %v", err)
+			}
+			for _, entry := range entries {
+				if isRepoName(entry.Name) {
+					repos[entry.Name] = true
+				}
+			}
+		}
+	}
+
+	if cacheDriver := disco.cacheDriver(); cacheDriver != nil {
+		repoPaths, err := cacheDriver.List(ctx, repositoriesBase)
+		switch err.(type) {
+		case nil, storagedriver.PathNotFoundError:
+		default:
+			return nil, fmt.Errorf("failed to list repositories in cache: %v", err)
+		}
+		for _, repoPath := range repoPaths {
+			if repoName := path.Base(repoPath); isRepoName(repoName) {
+				repos[repoName] = true
+			}
+		}
+	}
+
+	var list []string
+	for repo := range repos {
+		list = append(list, repo)
+	}
+	sort.Strings(list)
+	return list, nil
+}
+
+// isRepoName tells if the directory under the repositories is a repository and not e.g. the
+// quarantine.
+func isRepoName(name string) bool {
+	return !strings.HasPrefix(name, "_")
+}
+
+// cacheDriver returns the driver of the cache or nil if there is no cache.
+func (disco *Disco) cacheDriver() storagedriver.StorageDriver {
+	if multiDriver, ok := multidriver.Is(disco.getDriver()); ok {
+		return multiDriver.Secondary()
+	}
+	if disco.cfg.CacheOnly {
+		return disco.getDriver()
+	}
+	return nil
+}
+
+// Verify checks that the repository and the blobs listed in its disco.json have the expected
+// CIDs and digests in the IPFS nodes and in the cache. If repair is true, the content with
+// problems is copied again from the IPFS network or the other store.
+func (disco *Disco) Verify(ctx context.Context, repoName string, repair bool) (*VerifyResult, error) {
+	if disco.cfg.CacheOnly {
+		return nil, errors.New("verification is not supported in cache-only mode")
+	}
+	if !utils.IsCIDv1(repoName) {
+		return nil, fmt.Errorf("'%s' is %w", repoName, ErrNotCIDName)
+	}
+	result := &VerifyResult{Repository: repoName}
+
+	repoPath := makeRepoPath(repoName)
+	repoClient, err := disco.getIpfsClient().GetClientFor(ctx, repoPath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get repo node client: %v", err)
+	}
+	if problem := checkPrimaryRepo(ctx, repoClient, repoName); len(problem) > 0 {
+		result.add(fmt.Sprintf("repository in primary: %s", problem), repair, func() error {
+			_ = repoClient.FilesRm(ctx, repoPath, true)
+			_ = repoClient.FilesMkdir(ctx, repositoriesBase, ipfsapi.FilesMkdir.Parents(true))
+			if err := repoClient.FilesCp(ctx, fmt.Sprintf("/ipfs/%s", repoName), repoPath); err != nil {
+				return err
+			}
+			return checkResult(checkPrimaryRepo(ctx, repoClient, repoName))
+		})
+		if !repair {
+			return result, nil
+		}
+	}
+
+	r, err := repoClient.FilesRead(ctx, makeDiscoFilePath(repoName))
+	if err != nil {
+		result.Problems = append(result.Problems, fmt.Sprintf("failed to read disco.json: %v", err))
+		return result, nil
+	}
+	defer r.Close()
+	b, err := io.ReadAll(r)
+	if err != nil {
+		result.Problems = append(result.Problems, fmt.Sprintf("failed to read disco.json: %v", err))
+		return result, nil
+	}
+	file, err := decodeDiscoFile(b)
+	if err != nil {
+		result.Problems = append(result.Problems, fmt.Sprintf("failed to decode disco.json: %v", err))
+		return result, nil
+	}
+
+	multiDriver, hasSecondary := multidriver.Is(disco.getDriver())
+	for _, blob := range file.Blobs {
+		blob := blob
+		blobPath := makeBlobPath(blob.Digest)
+		blobClient, err := disco.getIpfsClient().GetClientFor(ctx, blobPath)
+		if err != nil {
+			return nil, fmt.Errorf("failed to get blob node client: %v", err)
+		}
+
+		if problem := checkPrimaryBlob(ctx, blobClient, blob); len(problem) > 0 {
+			result.add(fmt.Sprintf("blob %s in primary: %s", blob.Digest, problem), repair, func() error {
+				_ = blobClient.FilesRm(ctx, blobPath, true)
+				_ = blobClient.FilesMkdir(ctx, makeBlobDirPath(blob.Digest), ipfsapi.FilesMkdir.Parents(true))
+				err := blobClient.FilesCp(ctx, fmt.Sprintf("/ipfs/%s", blob.Cid), blobPath)
+				if err != nil && hasSecondary {
+					_, err = multiDriver.ReplicateInPrimary(ctx, blobPath)
+				}
+				if err != nil {
+					return err
+				}
+				return checkResult(checkPrimaryBlob(ctx, blobClient, blob))
+			})
+		}
+
+		if !hasSecondary {
+			continue
+		}
+		secondary := multiDriver.Secondary()
+		if problem := checkBlobDigest(ctx, secondary, blob.Digest); len(problem) > 0 {
+			result.add(fmt.Sprintf("blob %s in secondary: %s", blob.Digest, problem), repair, func() error {
+				_ = secondary.Delete(ctx, blobPath)
+				if _, err := multiDriver.ReplicateInSecondary(ctx, blobPath); err != nil {
+					return err
+				}
+				return checkResult(checkBlobDigest(ctx, secondary, blob.Digest))
+			})
+		}
+	}
+	return result, nil
+}
+
+// add records the problem and tries to repair it if necessary.
+func (result *VerifyResult) add(problem string, repair bool, repairFunc func() error) {
+	if !repair {
+		result.Problems = append(result.Problems, problem)
+		return
+	}
+	if err := repairFunc(); err != nil {
+		result.Problems = append(result.Problems, fmt.Sprintf("%s (failed to repair: %v)", problem, err))
+		return
+	}
+	result.Repaired = append(result.Repaired, problem)
+}
+
+func checkResult(problem string) error {
+	if len(problem) > 0 {
+		return errors.New(problem)
+	}
+	return nil
+}
+
+func checkPrimaryRepo(ctx context.Context, client interfaces.IPFSFilesAPI, repoName string) string {
+	stat, err := client.FilesStat(ctx, makeRepoPath(repoName))
+	if err != nil && isNotExistErr(err) {
+		return "missing"
+	}
+	if err != nil {
+		return fmt.Sprintf("failed to stat: %v", err)
+	}
+	if !utils.CIDEquals(stat.Hash, repoName) {
+		return fmt.Sprintf("content has cid %s", stat.Hash)
+	}
+	return ""
+}
+
+func checkPrimaryBlob(ctx context.Context, client interfaces.IPFSFilesAPI, blob *blobCid) string {
+	blobPath := makeBlobPath(blob.Digest)
+	stat, err := client.FilesStat(ctx, blobPath)
+	if err != nil && isNotExistErr(err) {
+		return "missing"
+	}
+	if err != nil {
+		return fmt.Sprintf("failed to stat: %v", err)
+	}
+	if !utils.CIDEquals(stat.Hash, blob.Cid) {
+		return fmt.Sprintf("has cid %s instead of %s", stat.Hash, blob.Cid)
+	}
+	r, err := client.FilesRead(ctx, blobPath)
+	if err != nil {
+		return fmt.Sprintf("failed to read: %v", err)
+	}
+	defer r.Close()
+	return checkDigest(r, blob.Digest)
+}
+
+func checkBlobDigest(ctx context.Context, driver storagedriver.StorageDriver, digest string) string {
+	r, err := driver.Reader(ctx, makeBlobPath(digest), 0)
+	if _, ok := err.(storagedriver.PathNotFoundError); ok {
+		return "missing"
+	}
+	if err != nil {
+		return fmt.Sprintf("failed to read: %v", err)
+	}
+	defer r.Close()
+	return checkDigest(r, digest)
+}
+
+func checkDigest(r io.Reader, digest string) string {
+	hash := sha256.New()
+	if _, err := io.Copy(hash, r); err != nil {
+		return fmt.Sprintf("failed to read: %v", err)
+	}
+	if actual := hex.EncodeToString(hash.Sum(nil)); actual != digest {
+		return fmt.Sprintf("content has digest %s", actual)
+	}
+	return ""
+}
+
+func isNotExistErr(err error) bool {
+	return strings.Contains(err.Error(), "does not exist")
+}
@@ -0,0 +1,67 @@
+package services
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+
+	storagedriver "github.com/distribution/distribution/v3/registry/storage/driver"
+	"github.com/distribution/distribution/v3/registry/storage/driver/inmemory"
+	"github.com/golang/mock/gomock"
+	ipfsapi "github.com/ipfs/go-ipfs-api"
+)
+
+const testBlobContent = "blob content"
+
+var testBlobDigest = func() string {
+	sum := sha256.Sum256([]byte(testBlobContent))
+	return hex.EncodeToString(sum[:])
+}()
+
+func (s *Suite) expectVerifiedRepo(blobContent string) {
+	s.ipfsNode.EXPECT().FilesStat(gomock.Any(), makeRepoPath(testCidv1)).
+		Return(&ipfsapi.FilesStatObject{Hash: testCidv1}, nil)
+	s.ipfsNode.EXPECT().FilesRead(gomock.Any(), makeDiscoFilePath(testCidv1)).
+		Return(io.NopCloser(bytes.NewBufferString(fmt.Sprintf(`{"blobs":[{"digest":"%s","cid":"%s"}]}`, testBlobDigest, testLayerCid))), nil)
+	s.ipfsNode.EXPECT().FilesStat(gomock.Any(), makeBlobPath(testBlobDigest)).
+		Return(&ipfsapi.FilesStatObject{Hash: testLayerCid}, nil)
+	s.ipfsNode.EXPECT().FilesRead(gomock.Any(), makeBlobPath(testBlobDigest)).
+		Return(io.NopCloser(bytes.NewBufferString(blobContent)), nil)
+}
+
+func (s *Suite) TestVerify_RepairSecondary() {
+	// Given that a blob is correct in primary but missing in secondary
+	s.expectVerifiedRepo(testBlobContent)
+	secondary := inmemory.New()
+	s.driver.EXPECT().Secondary().Return(secondary).AnyTimes()
+
+	// When the repo is verified with repair
+	// Then the blob should be replicated in secondary
+	s.driver.EXPECT().ReplicateInSecondary(gomock.Any(), makeBlobPath(testBlobDigest)).DoAndReturn(func(ctx context.Context, contentPath string) (storagedriver.FileInfo, error) {
+		return nil, secondary.PutContent(s.ctx, contentPath, []byte(testBlobContent))
+	})
+
+	result, err := s.disco.Verify(s.ctx, testCidv1, true)
+	s.r.NoError(err)
+	s.r.Empty(result.Problems)
+	s.r.Len(result.Repaired, 1)
+}
+
+func (s *Suite) TestVerify_CorruptPrimary() {
+	// Given that a blob has a different digest in primary
+	s.expectVerifiedRepo("corrupt content")
+	secondary := inmemory.New()
+	s.r.NoError(secondary.PutContent(s.ctx, makeBlobPath(testBlobDigest), []byte(testBlobContent)))
+	s.driver.EXPECT().Secondary().Return(secondary).AnyTimes()
+
+	// When the repo is verified without repair
+	// Then the problem should be reported
+	result, err := s.disco.Verify(s.ctx, testCidv1, false)
+	s.r.NoError(err)
+	s.r.Len(result.Problems, 1)
+	s.r.Contains(result.Problems[0], "in primary: content has digest")
+	s.r.Empty(result.Repaired)
+}
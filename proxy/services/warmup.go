@@ -0,0 +1,123 @@
+package services
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/forta-network/disco/utils"
+	"github.com/hashicorp/go-multierror"
+	log "github.com/sirupsen/logrus"
+)
+
+// warmupListTimeout limits the requests to the warm-up list URL.
+const warmupListTimeout = 30 * time.Second
+
+// WarmUp makes sure that the configured CID v1 repositories are cloned, pinned and cached, so
+// that they can always be served. The repositories are pinned once per run.
+func (disco *Disco) WarmUp(ctx context.Context) error {
+	repoNames, err := disco.warmupRepos(ctx)
+	if err != nil {
+		return err
+	}
+	var (
+		errs   *multierror.Error
+		failed int
+	)
+	for _, repoName := range repoNames {
+		if err := disco.warmRepo(withBackgroundPriority(ctx), repoName); err != nil {
+			errs = multierror.Append(errs, fmt.Errorf("failed to warm up %s: %v", repoName, err))
+			failed++
+			continue
+		}
+		if _, ok := disco.warmPinned.Load(repoName); ok {
+			continue
+		}
+		if _, err := disco.Pin(ctx, repoName); err != nil {
+			errs = multierror.Append(errs, fmt.Errorf("failed to pin %s: %v", repoName, err))
+			failed++
+			continue
+		}
+		disco.warmPinned.Store(repoName, true)
+	}
+	log.WithFields(log.Fields{
+		"repositories": len(repoNames),
+		"failed":       failed,
+	}).Info("warmed up repositories")
+	return errs.ErrorOrNil()
+}
+
+// warmupRepos returns the configured repositories and the ones from the list URL without the
+// duplicates.
+func (disco *Disco) warmupRepos(ctx context.Context) ([]string, error) {
+	repoNames := disco.cfg.Warmup.Cids
+	if url := disco.cfg.Warmup.URL; len(url) > 0 {
+		listed, err := disco.fetchWarmupList(ctx, url)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read the warm-up list: %v", err)
+		}
+		repoNames = append(append([]string{}, repoNames...), listed...)
+	}
+	var unique []string
+	seen := make(map[string]bool)
+	for _, repoName := range repoNames {
+		if seen[repoName] {
+			continue
+		}
+		seen[repoName] = true
+		if !utils.IsCIDv1(repoName) {
+			log.WithField("repository", repoName).Warn("skipping the warm-up of a repository which is not a cid v1")
+			continue
+		}
+		unique = append(unique, repoName)
+	}
+	return unique, nil
+}
+
+// fetchWarmupList reads the repositories from the URL, one per line.
+func (disco *Disco) fetchWarmupList(ctx context.Context, url string) ([]string, error) {
+	ctx, cancel := context.WithTimeout(ctx, warmupListTimeout)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := disco.httpClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("responded with status %d", resp.StatusCode)
+	}
+	var repoNames []string
+	scanner := bufio.NewScanner(resp.Body)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if len(line) == 0 || strings.HasPrefix(line, "#") {
+			continue
+		}
+		repoNames = append(repoNames, line)
+	}
+	return repoNames, scanner.Err()
+}
+
+// RunWarmup warms up the configured repositories at the start and periodically by using the
+// config, until the context is done.
+func (disco *Disco) RunWarmup(ctx context.Context) {
+	ticker := time.NewTicker(disco.cfg.Warmup.Interval)
+	defer ticker.Stop()
+	for {
+		if err := disco.WarmUp(ctx); err != nil {
+			log.WithError(err).Error("failed to warm up the repositories")
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
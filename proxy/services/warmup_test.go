@@ -0,0 +1,26 @@
+package services
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+
+	"github.com/forta-network/disco/config"
+)
+
+func (s *Suite) TestWarmupRepos() {
+	// Given a warm-up list with TLS which has a comment, a duplicate and an invalid name
+	list := httptest.NewTLSServer(http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+		fmt.Fprintf(rw, "# bots\n%s\n\nmyrepo\n", testCidv1)
+	}))
+	defer list.Close()
+	s.disco.cfg = &config.Config{Warmup: config.WarmupConfig{Cids: []string{testCidv1}, URL: list.URL}}
+	s.disco.httpClient = list.Client()
+
+	// When the repositories are listed
+	repoNames, err := s.disco.warmupRepos(s.ctx)
+
+	// Then only the valid repository should be warmed up once
+	s.r.NoError(err)
+	s.r.Equal([]string{testCidv1}, repoNames)
+}
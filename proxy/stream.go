@@ -0,0 +1,75 @@
+package proxy
+
+import (
+	"context"
+	"crypto/sha256"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/forta-network/disco/proxy/services"
+	"github.com/forta-network/disco/utils"
+)
+
+// streamBlob serves the first requests of the lazily cloned layers from the IPFS network, while
+// the layers are copied in the background. The range requests and the layers which cannot be
+// streamed are left to the registry.
+func streamBlob(rw http.ResponseWriter, r *http.Request, disco *services.Disco) bool {
+	if (r.Method != http.MethodGet && r.Method != http.MethodHead) || !strings.Contains(r.URL.Path, "/blobs/sha256:") {
+		return false
+	}
+	if len(r.Header.Get("Range")) > 0 {
+		return false
+	}
+	i := strings.LastIndex(r.URL.Path, "/blobs/")
+	repoName, digest := strings.TrimPrefix(r.URL.Path[:i], "/v2/"), r.URL.Path[i+len("/blobs/"):]
+	blob, err := disco.StreamBlob(r.Context(), repoName, digest)
+	if err != nil {
+		utils.Logger(r.Context()).WithError(err).Error("failed to find the blob to stream")
+		writeServiceError(rw, err)
+		return true
+	}
+	if blob == nil {
+		return false
+	}
+	rw, recordUsage := measureUsage(rw, r, disco)
+	serveStreamedBlob(rw, r, blob, disco.ReadBlob)
+	recordUsage()
+	return true
+}
+
+// serveStreamedBlob responds with the blob like the registry does. The content is verified while
+// it is streamed and the connection is aborted before the last bytes if the digest does not match.
+func serveStreamedBlob(rw http.ResponseWriter, r *http.Request, blob *services.ResolvedBlob, readBlob func(ctx context.Context, blob *services.ResolvedBlob) (io.ReadCloser, error)) {
+	var body io.ReadCloser
+	if r.Method == http.MethodGet {
+		var err error
+		body, err = readBlob(r.Context(), blob)
+		if err != nil {
+			utils.Logger(r.Context()).WithError(err).Error("failed to stream the blob")
+			writeServiceError(rw, err)
+			return
+		}
+		defer body.Close()
+	}
+	rw.Header().Set("Content-Type", "application/octet-stream")
+	rw.Header().Set("Content-Length", strconv.FormatInt(blob.Size, 10))
+	rw.Header().Set(digestHeader, blob.Digest)
+	rw.WriteHeader(http.StatusOK)
+	if body == nil {
+		return
+	}
+	verifier := &digestVerifier{
+		body:     body,
+		hash:     sha256.New(),
+		path:     r.URL.Path,
+		expected: strings.TrimPrefix(blob.Digest, sha256Prefix),
+		size:     blob.Size,
+		logger:   utils.Logger(r.Context()),
+	}
+	// the client gets less than the content length if the stream fails
+	if _, err := io.Copy(rw, verifier); err != nil {
+		utils.Logger(r.Context()).WithError(err).Warn("failed while streaming the blob")
+	}
+}
@@ -0,0 +1,57 @@
+package proxy
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/forta-network/disco/proxy/services"
+	"github.com/stretchr/testify/require"
+)
+
+func TestServeStreamedBlob(t *testing.T) {
+	r := require.New(t)
+
+	sum := sha256.Sum256([]byte("content"))
+	digest := "sha256:" + hex.EncodeToString(sum[:])
+	blob := &services.ResolvedBlob{Digest: digest, Cid: "bafybeiblob", Size: int64(len("content"))}
+	serve := func(method, content string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, "/v2/myrepo/blobs/"+digest, nil)
+		rec := httptest.NewRecorder()
+		serveStreamedBlob(rec, req, blob, func(ctx context.Context, blob *services.ResolvedBlob) (io.ReadCloser, error) {
+			if len(content) == 0 {
+				return nil, fmt.Errorf("%w: no providers", services.ErrCloneFailed)
+			}
+			return io.NopCloser(strings.NewReader(content)), nil
+		})
+		return rec
+	}
+
+	// When the blob is streamed
+	// Then it should be served like the registry serves it
+	rec := serve(http.MethodGet, "content")
+	r.Equal(http.StatusOK, rec.Code)
+	r.Equal("content", rec.Body.String())
+	r.Equal(digest, rec.Header().Get(digestHeader))
+	r.Equal("7", rec.Header().Get("Content-Length"))
+
+	// And the heads should not read the content
+	rec = serve(http.MethodHead, "")
+	r.Equal(http.StatusOK, rec.Code)
+	r.Empty(rec.Body.String())
+	r.Equal("7", rec.Header().Get("Content-Length"))
+
+	// And the corrupted content should be cut short
+	rec = serve(http.MethodGet, "corrupt")
+	r.Less(rec.Body.Len(), len("corrupt"))
+
+	// And the network failures should be unavailable
+	rec = serve(http.MethodGet, "")
+	r.Equal(http.StatusServiceUnavailable, rec.Code)
+}
@@ -0,0 +1,116 @@
+package proxy
+
+import (
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/forta-network/disco/config"
+	"github.com/forta-network/disco/proxy/services"
+	log "github.com/sirupsen/logrus"
+)
+
+// Tenant is a namespace which is served from its own registry.
+type Tenant struct {
+	Settings *config.TenantConfig
+	Config   *config.Config
+	Disco    *services.Disco
+}
+
+type tenantHandler struct {
+	*Tenant
+	handler http.Handler
+}
+
+// routeTenants routes the requests under the namespaces of the tenants to their registries
+// after removing the namespace from the path. The other requests are routed to the default
+// handler.
+func routeTenants(defaultHandler http.Handler, tenants map[string]*tenantHandler) http.Handler {
+	return http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+		namespace, subPath, ok := splitNamespace(r.URL.Path)
+		tenant, found := tenants[namespace]
+		if !ok || !found {
+			defaultHandler.ServeHTTP(rw, r)
+			return
+		}
+		if done := tenant.checkUser(rw, r); done {
+			return
+		}
+		if done := tenant.checkQuota(rw, r); done {
+			return
+		}
+		r.URL.Path = "/v2" + subPath
+		r.URL.RawPath = ""
+		tenant.handler.ServeHTTP(rw, r)
+	})
+}
+
+// splitNamespace splits a /v2/<namespace>/<path> path.
+func splitNamespace(p string) (namespace, subPath string, ok bool) {
+	if !strings.HasPrefix(p, "/v2/") {
+		return "", "", false
+	}
+	namespace, rest, found := strings.Cut(p[len("/v2/"):], "/")
+	if !found {
+		return "", "", false
+	}
+	return namespace, "/" + rest, true
+}
+
+// checkUser denies the requests of the users which are not allowed to access the namespace.
+// The passwords are checked by the tenant registry.
+func (tenant *tenantHandler) checkUser(rw http.ResponseWriter, r *http.Request) bool {
+	if len(tenant.Settings.Users) == 0 {
+		return false
+	}
+	user, _, ok := r.BasicAuth()
+	if !ok {
+		rw.Header().Set("WWW-Authenticate", `Basic realm="disco"`)
+		writeRegistryError(rw, http.StatusUnauthorized, "UNAUTHORIZED", "authentication required")
+		return true
+	}
+	for _, allowed := range tenant.Settings.Users {
+		if user == allowed {
+			return false
+		}
+	}
+	writeRegistryError(rw, http.StatusForbidden, "DENIED", "not allowed to access namespace "+tenant.Settings.Namespace)
+	return true
+}
+
+// checkQuota denies the uploads and the manifest pushes after the quota is exceeded.
+func (tenant *tenantHandler) checkQuota(rw http.ResponseWriter, r *http.Request) bool {
+	if tenant.Settings.Quota == 0 {
+		return false
+	}
+	isUploadStart := r.Method == http.MethodPost && strings.Contains(r.URL.Path, "/blobs/uploads/")
+	isManifestPush := r.Method == http.MethodPut && strings.Contains(r.URL.Path, "/manifests/")
+	if !isUploadStart && !isManifestPush {
+		return false
+	}
+	usage, err := tenant.Disco.StorageUsage(r.Context())
+	if err != nil {
+		log.WithError(err).WithField("namespace", tenant.Settings.Namespace).Warn("failed to check the quota - allowing")
+		return false
+	}
+	if usage < tenant.Settings.Quota {
+		return false
+	}
+	writeRegistryError(rw, http.StatusForbidden, "DENIED", "quota of namespace "+tenant.Settings.Namespace+" is exceeded")
+	return true
+}
+
+// mountLocation adds the namespace to the registry paths in the Location header.
+func mountLocation(resp *http.Response, namespace string) {
+	location := resp.Header.Get("Location")
+	if len(location) == 0 {
+		return
+	}
+	u, err := url.Parse(location)
+	if err != nil || !strings.HasPrefix(u.Path, "/v2/") {
+		return
+	}
+	u.Path = "/v2/" + namespace + strings.TrimPrefix(u.Path, "/v2")
+	u.RawPath = ""
+	resp.Header.Set("Location", u.String())
+}
@@ -0,0 +1,71 @@
+package proxy
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/forta-network/disco/config"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRouteTenants(t *testing.T) {
+	r := require.New(t)
+
+	pathHandler := func(name string) http.Handler {
+		return http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+			_, _ = rw.Write([]byte(name + " " + r.URL.Path))
+		})
+	}
+	handler := routeTenants(pathHandler("default"), map[string]*tenantHandler{
+		"team-a": {
+			Tenant: &Tenant{
+				Settings: &config.TenantConfig{Namespace: "team-a", Users: []string{"alice"}},
+			},
+			handler: pathHandler("team-a"),
+		},
+	})
+
+	doRequest := func(path, user string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, path, nil)
+		if len(user) > 0 {
+			req.SetBasicAuth(user, "password")
+		}
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+		return rec
+	}
+
+	rec := doRequest("/v2/team-a/myrepo/manifests/latest", "alice")
+	r.Equal(http.StatusOK, rec.Code)
+	r.Equal("team-a /v2/myrepo/manifests/latest", rec.Body.String())
+
+	rec = doRequest("/v2/team-a/", "alice")
+	r.Equal("team-a /v2/", rec.Body.String())
+
+	rec = doRequest("/v2/team-a/myrepo/manifests/latest", "")
+	r.Equal(http.StatusUnauthorized, rec.Code)
+	r.NotEmpty(rec.Header().Get("WWW-Authenticate"))
+
+	rec = doRequest("/v2/team-a/myrepo/manifests/latest", "bob")
+	r.Equal(http.StatusForbidden, rec.Code)
+
+	rec = doRequest("/v2/team-b/myrepo/manifests/latest", "")
+	r.Equal("default /v2/team-b/myrepo/manifests/latest", rec.Body.String())
+
+	rec = doRequest("/v2/_catalog", "")
+	r.Equal("default /v2/_catalog", rec.Body.String())
+}
+
+func TestMountLocation(t *testing.T) {
+	r := require.New(t)
+
+	resp := &http.Response{Header: make(http.Header)}
+	resp.Header.Set("Location", "http://localhost:1970/v2/myrepo/blobs/uploads/123?_state=abc")
+	mountLocation(resp, "team-a")
+	r.Equal("http://localhost:1970/v2/team-a/myrepo/blobs/uploads/123?_state=abc", resp.Header.Get("Location"))
+
+	resp.Header.Set("Location", "https://some.cdn/blob")
+	mountLocation(resp, "team-a")
+	r.Equal("https://some.cdn/blob", resp.Header.Get("Location"))
+}
@@ -0,0 +1,41 @@
+package proxy
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+
+	"go.opentelemetry.io/contrib/instrumentation/net/http/otelhttp"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// traceRequests starts a server span for each request, which continues the trace of the client
+// if the request has a traceparent header. The spans of Disco and of the registry are started
+// in the trace of the request.
+func traceRequests(next http.Handler) http.Handler {
+	return otelhttp.NewHandler(http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+		span := trace.SpanFromContext(r.Context())
+		for key, value := range requestLogFields(r.URL.Path) {
+			span.SetAttributes(attribute.String("disco."+key, fmt.Sprint(value)))
+		}
+		next.ServeHTTP(rw, r)
+	}), "proxy", otelhttp.WithSpanNameFormatter(func(_ string, r *http.Request) string {
+		return r.Method + " " + routeName(r.URL.Path)
+	}))
+}
+
+// routeName returns the route of the registry path without the names and the references, so
+// that the spans of the same kind of requests have the same name.
+func routeName(urlPath string) string {
+	for _, route := range []struct{ kind, name string }{
+		{"/blobs/uploads/", "/v2/{name}/blobs/uploads/{uuid}"},
+		{"/manifests/", "/v2/{name}/manifests/{reference}"},
+		{"/blobs/", "/v2/{name}/blobs/{digest}"},
+	} {
+		if strings.HasPrefix(urlPath, "/v2/") && strings.Contains(urlPath, route.kind) {
+			return route.name
+		}
+	}
+	return urlPath
+}
@@ -0,0 +1,59 @@
+package proxy
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/forta-network/disco/utils"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/propagation"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.opentelemetry.io/otel/trace"
+)
+
+func TestTraceRequests(t *testing.T) {
+	r := require.New(t)
+
+	recorder := tracetest.NewSpanRecorder()
+	otel.SetTracerProvider(sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder)))
+	otel.SetTextMapPropagator(propagation.TraceContext{})
+	defer utils.SetTracer(nil)
+
+	// Given a request which continues the trace of the client
+	req := httptest.NewRequest(http.MethodGet, "/v2/myrepo/manifests/latest", nil)
+	req.Header.Set("traceparent", "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01")
+
+	// When it is handled and fails
+	var sc trace.SpanContext
+	rec := httptest.NewRecorder()
+	traceRequests(http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+		sc = trace.SpanContextFromContext(r.Context())
+		rw.WriteHeader(http.StatusBadGateway)
+	})).ServeHTTP(rec, req)
+
+	// Then the handler should be in the trace of the client
+	r.Equal("4bf92f3577b34da6a3ce929d0e0e4736", sc.TraceID().String())
+	r.NotEqual("00f067aa0ba902b7", sc.SpanID().String())
+
+	// And the span should be named after the route and record the failure
+	spans := recorder.Ended()
+	r.Len(spans, 1)
+	r.Equal("GET /v2/{name}/manifests/{reference}", spans[0].Name())
+	r.Equal(trace.SpanKindServer, spans[0].SpanKind())
+	r.Equal(codes.Error, spans[0].Status().Code)
+	r.Contains(spans[0].Attributes(), attribute.String("disco.repository", "myrepo"))
+}
+
+func TestRouteName(t *testing.T) {
+	r := require.New(t)
+
+	r.Equal("/v2/{name}/manifests/{reference}", routeName("/v2/myorg/myrepo/manifests/latest"))
+	r.Equal("/v2/{name}/blobs/{digest}", routeName("/v2/myrepo/blobs/sha256:1234"))
+	r.Equal("/v2/{name}/blobs/uploads/{uuid}", routeName("/v2/myrepo/blobs/uploads/"))
+	r.Equal("/v2/_disco/operations", routeName("/v2/_disco/operations"))
+}
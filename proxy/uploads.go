@@ -0,0 +1,100 @@
+package proxy
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/forta-network/disco/proxy/services"
+)
+
+const uploadUUIDHeader = "Docker-Upload-UUID"
+
+// uploadSessions records and checks the upload sessions.
+type uploadSessions interface {
+	RecordUploadSession(repoName, id string, offset int64)
+	EndUploadSession(id string)
+	UploadSession(id string) (*services.UploadSession, bool)
+}
+
+// parseUploadPath returns the repository name and the upload ID from a
+// /v2/<name>/blobs/uploads/<uuid> path. The ID is empty when an upload is started.
+func parseUploadPath(urlPath string) (repoName, id string, ok bool) {
+	i := strings.LastIndex(urlPath, "/blobs/uploads/")
+	if i < 0 || !strings.HasPrefix(urlPath, "/v2/") {
+		return "", "", false
+	}
+	return urlPath[len("/v2/"):i], urlPath[i+len("/blobs/uploads/"):], true
+}
+
+// checkUploadOffset rejects the chunks which do not continue from the offset of the upload,
+// which may have been written by another replica, in the same way as the registry does.
+func checkUploadOffset(rw http.ResponseWriter, r *http.Request, sessions uploadSessions) bool {
+	contentRange := r.Header.Get("Content-Range")
+	if r.Method != http.MethodPatch || len(contentRange) == 0 {
+		return false
+	}
+	_, id, ok := parseUploadPath(r.URL.Path)
+	if !ok || len(id) == 0 {
+		return false
+	}
+	session, ok := sessions.UploadSession(id)
+	if !ok {
+		return false
+	}
+	start, err := strconv.ParseInt(strings.SplitN(contentRange, "-", 2)[0], 10, 64)
+	if err != nil || start == session.Offset {
+		return false
+	}
+	rw.Header().Set(uploadUUIDHeader, id)
+	rw.Header().Set("Range", uploadRange(session.Offset))
+	writeRegistryError(rw, http.StatusRequestedRangeNotSatisfiable, "BLOB_UPLOAD_INVALID",
+		fmt.Sprintf("chunk should start at %d", session.Offset))
+	return true
+}
+
+// trackUploads records the offsets of the uploads from the responses of the registry, so that
+// the other replicas can continue them, and forgets the completed uploads.
+func trackUploads(rw http.ResponseWriter, r *http.Request, sessions uploadSessions) (http.ResponseWriter, func()) {
+	repoName, id, ok := parseUploadPath(r.URL.Path)
+	if !ok {
+		return rw, func() {}
+	}
+	sw := &statusWriter{ResponseWriter: rw}
+	return sw, func() {
+		switch {
+		case sw.status == http.StatusAccepted && (r.Method == http.MethodPost || r.Method == http.MethodPatch):
+			uploadID := sw.Header().Get(uploadUUIDHeader)
+			if len(uploadID) == 0 {
+				return
+			}
+			var offset int64
+			if r.Method == http.MethodPatch {
+				offset = parseUploadRange(sw.Header().Get("Range"))
+			}
+			sessions.RecordUploadSession(repoName, uploadID, offset)
+
+		case len(id) > 0 && (sw.status == http.StatusCreated && r.Method == http.MethodPut ||
+			sw.status == http.StatusNoContent && r.Method == http.MethodDelete):
+			sessions.EndUploadSession(id)
+		}
+	}
+}
+
+// uploadRange returns the Range header of the registry for the offset.
+func uploadRange(offset int64) string {
+	if offset > 0 {
+		offset--
+	}
+	return fmt.Sprintf("0-%d", offset)
+}
+
+// parseUploadRange returns the offset from the Range header of the registry.
+func parseUploadRange(value string) int64 {
+	end, err := strconv.ParseInt(strings.TrimPrefix(value, "0-"), 10, 64)
+	if err != nil {
+		return 0
+	}
+	return end + 1
+}
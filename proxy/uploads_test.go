@@ -0,0 +1,67 @@
+package proxy
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/forta-network/disco/proxy/services"
+	"github.com/stretchr/testify/require"
+)
+
+// testUploadSessions keeps the offsets of the uploads.
+type testUploadSessions map[string]int64
+
+func (sessions testUploadSessions) RecordUploadSession(repoName, id string, offset int64) {
+	sessions[id] = offset
+}
+
+func (sessions testUploadSessions) EndUploadSession(id string) {
+	delete(sessions, id)
+}
+
+func (sessions testUploadSessions) UploadSession(id string) (*services.UploadSession, bool) {
+	offset, ok := sessions[id]
+	if !ok {
+		return nil, false
+	}
+	return &services.UploadSession{ID: id, Offset: offset}, true
+}
+
+func TestTrackUploads(t *testing.T) {
+	r := require.New(t)
+
+	sessions := testUploadSessions{}
+	serve := func(method, path string, status int, header http.Header) {
+		rw, record := trackUploads(httptest.NewRecorder(), httptest.NewRequest(method, path, nil), sessions)
+		for key, values := range header {
+			rw.Header().Set(key, values[0])
+		}
+		rw.WriteHeader(status)
+		record()
+	}
+
+	// the started uploads and the written chunks are recorded
+	serve(http.MethodPost, "/v2/myorg/myrepo/blobs/uploads/", http.StatusAccepted, http.Header{uploadUUIDHeader: {"some-uuid"}, "Range": {"0-0"}})
+	r.Equal(int64(0), sessions["some-uuid"])
+	serve(http.MethodPatch, "/v2/myorg/myrepo/blobs/uploads/some-uuid", http.StatusAccepted, http.Header{uploadUUIDHeader: {"some-uuid"}, "Range": {"0-1023"}})
+	r.Equal(int64(1024), sessions["some-uuid"])
+
+	// the chunks which do not continue from the offset are rejected
+	check := func(contentRange string) int {
+		req := httptest.NewRequest(http.MethodPatch, "/v2/myorg/myrepo/blobs/uploads/some-uuid", nil)
+		req.Header.Set("Content-Range", contentRange)
+		rec := httptest.NewRecorder()
+		if checkUploadOffset(rec, req, sessions) {
+			r.Equal("0-1023", rec.Header().Get("Range"))
+			return rec.Code
+		}
+		return http.StatusOK
+	}
+	r.Equal(http.StatusOK, check("1024-2047"))
+	r.Equal(http.StatusRequestedRangeNotSatisfiable, check("0-1023"))
+
+	// the completed uploads are forgotten
+	serve(http.MethodPut, "/v2/myorg/myrepo/blobs/uploads/some-uuid", http.StatusCreated, nil)
+	r.Empty(sessions)
+}
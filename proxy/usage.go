@@ -0,0 +1,119 @@
+package proxy
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"io"
+	"net/http"
+	"strings"
+
+	"github.com/forta-network/disco/proxy/services"
+)
+
+// countingReader counts the bytes which are read from the request body.
+type countingReader struct {
+	io.ReadCloser
+	read int64
+}
+
+func (cr *countingReader) Read(b []byte) (int, error) {
+	n, err := cr.ReadCloser.Read(b)
+	cr.read += int64(n)
+	return n, err
+}
+
+// measureUsage counts the bytes of the manifest and the blob requests of a repository, if the
+// usage statistics or the bandwidth accounting are enabled. It returns the writer to serve the
+// request with and the func which records the usage after the request is served.
+func measureUsage(rw http.ResponseWriter, r *http.Request, disco *services.Disco) (http.ResponseWriter, func()) {
+	if !(disco.UsageStatsEnabled() || disco.BandwidthEnabled()) {
+		return rw, func() {}
+	}
+	repoName, rest, ok := parsePullPath(r.URL.Path)
+	if !ok {
+		return rw, func() {}
+	}
+	isManifest := strings.HasPrefix(rest, "/manifests/")
+	sw := &statusWriter{ResponseWriter: rw}
+	body := &countingReader{ReadCloser: r.Body}
+	r.Body = body
+	return sw, func() {
+		usage := &services.RepoUsage{
+			Repository:    repoName,
+			BytesServed:   sw.written,
+			BytesReceived: body.read,
+		}
+		switch {
+		case isManifest && r.Method == http.MethodGet && sw.status == http.StatusOK:
+			usage.Pulls = 1
+		case isManifest && r.Method == http.MethodPut && sw.status == http.StatusCreated:
+			usage.Pushes = 1
+		}
+		disco.RecordUsage(usage)
+		// the subjects of the tokens which the registry rejected are not trusted
+		var client string
+		if sw.status < http.StatusBadRequest {
+			client = clientID(r, disco)
+		}
+		disco.RecordBandwidth(repoName, client, sw.written)
+	}
+}
+
+// checkBandwidth denies the manifest and the blob pulls after the monthly bandwidth cap of the
+// repository or the client is exceeded.
+func checkBandwidth(rw http.ResponseWriter, r *http.Request, disco *services.Disco) bool {
+	if !disco.BandwidthEnabled() || r.Method != http.MethodGet {
+		return false
+	}
+	repoName, _, ok := parsePullPath(r.URL.Path)
+	if !ok {
+		return false
+	}
+	if err := disco.CheckBandwidth(repoName, clientID(r, disco)); err != nil {
+		writeServiceError(rw, err)
+		return true
+	}
+	return false
+}
+
+// clientID identifies the client with its API key, its user name or the subject of its token, which
+// stay the same when the tokens are refreshed, so that the tokens themselves are not exposed in
+// the metrics. The anonymous clients and the clients with opaque tokens are not identified.
+func clientID(r *http.Request, disco *services.Disco) string {
+	if disco.APIKeysEnabled() {
+		if id, ok := services.APIKeyID(requestAPIKey(r)); ok {
+			return "apikey:" + id
+		}
+	}
+	if user, _, ok := r.BasicAuth(); ok {
+		return "user:" + user
+	}
+	authorization := r.Header.Get("Authorization")
+	if !strings.HasPrefix(authorization, "Bearer ") {
+		return ""
+	}
+	if subject := tokenSubject(strings.TrimPrefix(authorization, "Bearer ")); len(subject) > 0 {
+		return "subject:" + subject
+	}
+	return ""
+}
+
+// tokenSubject returns the subject claim of a JWT without verifying the token, since the
+// registry verifies it.
+func tokenSubject(token string) string {
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return ""
+	}
+	b, err := base64.RawURLEncoding.DecodeString(parts[1])
+	if err != nil {
+		return ""
+	}
+	var claims struct {
+		Subject string `json:"sub"`
+	}
+	if err := json.Unmarshal(b, &claims); err != nil {
+		return ""
+	}
+	return claims.Subject
+}
@@ -0,0 +1,103 @@
+package proxy
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/forta-network/disco/config"
+	"github.com/forta-network/disco/proxy/services"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMeasureUsage(t *testing.T) {
+	r := require.New(t)
+
+	disco := services.NewDiscoService(&config.Config{UsageStats: config.UsageStatsConfig{Enabled: true}}, nil)
+	serve := func(method, path, reqBody string, status int, respBody string) {
+		req := httptest.NewRequest(method, path, strings.NewReader(reqBody))
+		rw, recordUsage := measureUsage(httptest.NewRecorder(), req, disco)
+		_, _ = io.ReadAll(req.Body)
+		rw.WriteHeader(status)
+		_, _ = rw.Write([]byte(respBody))
+		recordUsage()
+	}
+
+	serve(http.MethodGet, "/v2/myorg/myrepo/manifests/latest", "", http.StatusOK, "manifest")
+	serve(http.MethodGet, "/v2/myorg/myrepo/manifests/unknown", "", http.StatusNotFound, "")
+	serve(http.MethodPatch, "/v2/myorg/myrepo/blobs/uploads/1", "data", http.StatusAccepted, "")
+	serve(http.MethodPut, "/v2/myorg/myrepo/manifests/latest", "manifest", http.StatusCreated, "")
+	serve(http.MethodGet, "/v2/_catalog", "", http.StatusOK, "catalog")
+
+	report, err := disco.ExportUsageStats(context.Background())
+	r.NoError(err)
+	r.Len(report.Repositories, 1)
+	usage := report.Repositories[0]
+	r.Equal("myorg/myrepo", usage.Repository)
+	r.Equal(int64(1), usage.Pulls)
+	r.Equal(int64(1), usage.Pushes)
+	r.Equal(int64(len("manifest")), usage.BytesServed)
+	r.Equal(int64(len("data")+len("manifest")), usage.BytesReceived)
+}
+
+// testToken creates an unsigned JWT with the subject and the token ID.
+func testToken(subject, id string) string {
+	claims, _ := json.Marshal(map[string]string{"sub": subject, "jti": id})
+	return "eyJhbGciOiJub25lIn0." + base64.RawURLEncoding.EncodeToString(claims) + ".sig"
+}
+
+func TestCheckBandwidth(t *testing.T) {
+	r := require.New(t)
+
+	cfg := &config.Config{Bandwidth: config.BandwidthConfig{Enabled: true, RepoCap: 10, ClientCap: 5}}
+	disco := services.NewDiscoService(cfg, nil)
+	pull := func(path, token string) int {
+		req := httptest.NewRequest(http.MethodGet, path, nil)
+		if len(token) > 0 {
+			req.Header.Set("Authorization", "Bearer "+token)
+		}
+		rec := httptest.NewRecorder()
+		if checkBandwidth(rec, req, disco) {
+			return rec.Code
+		}
+		rw, recordUsage := measureUsage(rec, req, disco)
+		_, _ = rw.Write([]byte("blob"))
+		recordUsage()
+		return rec.Code
+	}
+
+	// the client cap is exceeded after two pulls, even if the token of the client is refreshed
+	r.Equal(http.StatusOK, pull("/v2/myorg/myrepo/blobs/sha256:1", testToken("alice", "1")))
+	r.Equal(http.StatusOK, pull("/v2/myorg/myrepo/blobs/sha256:1", testToken("alice", "2")))
+	r.Equal(http.StatusTooManyRequests, pull("/v2/myorg/myrepo/blobs/sha256:1", testToken("alice", "3")))
+	// the repo cap is exceeded after three pulls
+	r.Equal(http.StatusOK, pull("/v2/myorg/myrepo/blobs/sha256:1", ""))
+	r.Equal(http.StatusTooManyRequests, pull("/v2/myorg/myrepo/blobs/sha256:1", ""))
+	r.Equal(http.StatusOK, pull("/v2/myorg/otherrepo/blobs/sha256:1", "opaque"))
+
+	usage := disco.BandwidthUsage()
+	r.Equal(int64(12), usage.Repositories["myorg/myrepo"])
+	r.Equal(int64(4), usage.Repositories["myorg/otherrepo"])
+	r.Len(usage.Clients, 1, "the anonymous clients and the opaque tokens should not be counted")
+	r.Equal(int64(8), usage.Clients["subject:alice"])
+}
+
+func TestClientID(t *testing.T) {
+	r := require.New(t)
+
+	disco := services.NewDiscoService(&config.Config{APIKeys: config.APIKeysConfig{Enabled: true, File: t.TempDir() + "/apikeys.json"}}, nil)
+	request := func(authorization string) *http.Request {
+		req := httptest.NewRequest(http.MethodGet, "/v2/myrepo/manifests/latest", nil)
+		req.Header.Set("Authorization", authorization)
+		return req
+	}
+
+	r.Equal("apikey:0123", clientID(request("Bearer disco_0123_secret"), disco))
+	r.Equal("subject:alice", clientID(request("Bearer "+testToken("alice", "1")), disco))
+	r.Equal("", clientID(request(""), disco))
+}
@@ -0,0 +1,103 @@
+package proxy
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"hash"
+	"io"
+	"net/http"
+	"strings"
+
+	"github.com/forta-network/disco/utils"
+	log "github.com/sirupsen/logrus"
+)
+
+const (
+	digestHeader = "Docker-Content-Digest"
+	sha256Prefix = "sha256:"
+)
+
+// verifyDigest makes sure that the manifests and the blobs which are served from the registry
+// match the digests in the URL or the response headers, so that the corrupted content from a
+// damaged store is not served. The manifests are verified before they are served. The blobs are
+// verified while they are streamed and the connection is aborted before the last bytes if the
+// digest does not match.
+func verifyDigest(resp *http.Response) error {
+	if resp.Request.Method != http.MethodGet || resp.StatusCode != http.StatusOK {
+		return nil
+	}
+	urlPath := resp.Request.URL.Path
+	isManifest := strings.Contains(urlPath, "/manifests/")
+	isBlob := strings.Contains(urlPath, "/blobs/") && !strings.Contains(urlPath, "/blobs/uploads/")
+	if !isManifest && !isBlob {
+		return nil
+	}
+	expected := urlPath[strings.LastIndex(urlPath, "/")+1:]
+	if !strings.HasPrefix(expected, sha256Prefix) {
+		expected = resp.Header.Get(digestHeader)
+	}
+	if !strings.HasPrefix(expected, sha256Prefix) {
+		return nil
+	}
+	expected = strings.TrimPrefix(expected, sha256Prefix)
+
+	if isManifest {
+		b, err := io.ReadAll(resp.Body)
+		resp.Body.Close()
+		if err != nil {
+			return fmt.Errorf("failed to read manifest: %v", err)
+		}
+		sum := sha256.Sum256(b)
+		if actual := hex.EncodeToString(sum[:]); actual != expected {
+			return fmt.Errorf("manifest %s has digest sha256:%s instead of sha256:%s", urlPath, actual, expected)
+		}
+		resp.Body = io.NopCloser(bytes.NewReader(b))
+		return nil
+	}
+
+	resp.Body = &digestVerifier{
+		body:     resp.Body,
+		hash:     sha256.New(),
+		path:     urlPath,
+		expected: expected,
+		size:     resp.ContentLength,
+		logger:   utils.Logger(resp.Request.Context()),
+	}
+	return nil
+}
+
+// digestVerifier computes the digest of the content while it is read and fails
+// the last read if the digest does not match.
+type digestVerifier struct {
+	body     io.ReadCloser
+	hash     hash.Hash
+	path     string
+	expected string
+	size     int64
+	read     int64
+	logger   *log.Entry
+}
+
+func (v *digestVerifier) Read(p []byte) (int, error) {
+	n, err := v.body.Read(p)
+	v.hash.Write(p[:n])
+	v.read += int64(n)
+	if err == io.EOF || (v.size >= 0 && v.read >= v.size) {
+		if actual := hex.EncodeToString(v.hash.Sum(nil)); actual != v.expected {
+			v.logger.WithFields(log.Fields{
+				"path":     v.path,
+				"expected": v.expected,
+				"actual":   actual,
+			}).Error("served content does not match the digest - aborting")
+			// hold back the last bytes so that the client does not receive all of the content
+			return 0, fmt.Errorf("blob %s has digest sha256:%s instead of sha256:%s", v.path, actual, v.expected)
+		}
+	}
+	return n, err
+}
+
+func (v *digestVerifier) Close() error {
+	return v.body.Close()
+}
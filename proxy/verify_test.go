@@ -0,0 +1,60 @@
+package proxy
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func testResponse(urlPath, digest, content string) *http.Response {
+	resp := &http.Response{
+		StatusCode:    http.StatusOK,
+		Header:        make(http.Header),
+		Body:          io.NopCloser(strings.NewReader(content)),
+		ContentLength: int64(len(content)),
+		Request:       httptest.NewRequest(http.MethodGet, urlPath, nil),
+	}
+	if len(digest) > 0 {
+		resp.Header.Set(digestHeader, digest)
+	}
+	return resp
+}
+
+func TestVerifyDigest(t *testing.T) {
+	r := require.New(t)
+
+	sum := sha256.Sum256([]byte("content"))
+	digest := "sha256:" + hex.EncodeToString(sum[:])
+
+	// manifests are verified before they are served
+	resp := testResponse("/v2/myrepo/manifests/latest", digest, "content")
+	r.NoError(verifyDigest(resp))
+	b, err := io.ReadAll(resp.Body)
+	r.NoError(err)
+	r.Equal("content", string(b))
+	r.Error(verifyDigest(testResponse("/v2/myrepo/manifests/"+digest, "", "corrupted")))
+
+	// blobs are verified while they are streamed
+	resp = testResponse("/v2/myrepo/blobs/"+digest, "", "content")
+	r.NoError(verifyDigest(resp))
+	b, err = io.ReadAll(resp.Body)
+	r.NoError(err)
+	r.Equal("content", string(b))
+	resp = testResponse("/v2/myrepo/blobs/"+digest, "", "corrupted")
+	r.NoError(verifyDigest(resp))
+	b, err = io.ReadAll(resp.Body)
+	r.Error(err)
+	r.Less(len(b), len("corrupted"), "should hold back the last bytes")
+
+	// the responses without digests are not touched
+	resp = testResponse("/v2/myrepo/manifests/latest", "", "content")
+	body := resp.Body
+	r.NoError(verifyDigest(resp))
+	r.Equal(body, resp.Body)
+}
@@ -0,0 +1,49 @@
+package secrets
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	awsconfig "github.com/aws/aws-sdk-go-v2/config"
+	"github.com/aws/aws-sdk-go-v2/service/secretsmanager"
+)
+
+// AWSSecretsManagerProviderName is the name which the AWS Secrets Manager provider is registered with.
+const AWSSecretsManagerProviderName = "awssm"
+
+type awsSecretsManagerProvider struct {
+	client *secretsmanager.Client
+}
+
+// NewAWSSecretsManagerProvider creates a provider which reads the secrets from AWS Secrets Manager
+// by using the default AWS credentials chain. The references are in <secret-id>[#<field>] format
+// and the field is read from the secret string as a JSON object.
+func NewAWSSecretsManagerProvider(ctx context.Context, region, endpoint string) (Provider, error) {
+	cfg, err := awsconfig.LoadDefaultConfig(ctx, awsconfig.WithRegion(region))
+	if err != nil {
+		return nil, fmt.Errorf("failed to load aws config: %v", err)
+	}
+	client := secretsmanager.NewFromConfig(cfg, func(o *secretsmanager.Options) {
+		if len(endpoint) > 0 {
+			o.BaseEndpoint = aws.String(endpoint)
+		}
+	})
+	return &awsSecretsManagerProvider{client: client}, nil
+}
+
+// GetSecret implements Provider.
+func (ap *awsSecretsManagerProvider) GetSecret(ctx context.Context, ref string) (string, error) {
+	secretID, field := splitRef(ref)
+	out, err := ap.client.GetSecretValue(ctx, &secretsmanager.GetSecretValueInput{
+		SecretId: aws.String(secretID),
+	})
+	if err != nil {
+		return "", fmt.Errorf("failed to get secret '%s': %v", secretID, err)
+	}
+	secret := aws.ToString(out.SecretString)
+	if len(field) == 0 {
+		return secret, nil
+	}
+	return fieldFromJSON(secret, field)
+}
@@ -0,0 +1,114 @@
+package secrets
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Provider fetches secrets from a secret store.
+type Provider interface {
+	GetSecret(ctx context.Context, ref string) (string, error)
+}
+
+var (
+	providersMu sync.RWMutex
+	providers   = make(map[string]Provider)
+)
+
+// Register makes a provider available with given name. The secrets from the provider
+// are cached for the refresh interval so the rotated values are picked up afterwards.
+func Register(name string, provider Provider, refreshInterval time.Duration) {
+	if refreshInterval > 0 {
+		provider = &cachedProvider{
+			provider: provider,
+			ttl:      refreshInterval,
+			values:   make(map[string]*cachedValue),
+		}
+	}
+	providersMu.Lock()
+	providers[name] = provider
+	providersMu.Unlock()
+}
+
+// Get returns the provider registered with given name.
+func Get(name string) (Provider, bool) {
+	providersMu.RLock()
+	defer providersMu.RUnlock()
+	provider, ok := providers[name]
+	return provider, ok
+}
+
+// Resolve fetches a secret by using a reference in <provider>:<path>#<field> format.
+func Resolve(ctx context.Context, ref string) (string, error) {
+	segments := strings.SplitN(ref, ":", 2)
+	if len(segments) != 2 {
+		return "", fmt.Errorf("invalid secret reference '%s': expected <provider>:<path>", ref)
+	}
+	provider, ok := Get(segments[0])
+	if !ok {
+		return "", fmt.Errorf("secret provider '%s' is not configured", segments[0])
+	}
+	return provider.GetSecret(ctx, segments[1])
+}
+
+// splitRef splits a reference to the path and the optional field in path#field format.
+func splitRef(ref string) (secretPath, field string) {
+	if i := strings.LastIndex(ref, "#"); i >= 0 {
+		return ref[:i], ref[i+1:]
+	}
+	return ref, ""
+}
+
+// fieldFromJSON extracts a field from a secret which contains a JSON object.
+func fieldFromJSON(secret, field string) (string, error) {
+	var values map[string]interface{}
+	if err := json.Unmarshal([]byte(secret), &values); err != nil {
+		return "", fmt.Errorf("failed to decode the secret to get field '%s': %v", field, err)
+	}
+	return fieldFromMap(values, field)
+}
+
+func fieldFromMap(values map[string]interface{}, field string) (string, error) {
+	value, ok := values[field]
+	if !ok {
+		return "", fmt.Errorf("field '%s' not found in the secret", field)
+	}
+	return fmt.Sprint(value), nil
+}
+
+type cachedValue struct {
+	value     string
+	fetchedAt time.Time
+}
+
+// cachedProvider caches the secrets from a provider for a while.
+type cachedProvider struct {
+	provider Provider
+	ttl      time.Duration
+
+	mu     sync.Mutex
+	values map[string]*cachedValue
+}
+
+// GetSecret implements Provider.
+func (cp *cachedProvider) GetSecret(ctx context.Context, ref string) (string, error) {
+	cp.mu.Lock()
+	cached, ok := cp.values[ref]
+	cp.mu.Unlock()
+	if ok && time.Since(cached.fetchedAt) < cp.ttl {
+		return cached.value, nil
+	}
+
+	value, err := cp.provider.GetSecret(ctx, ref)
+	if err != nil {
+		return "", err
+	}
+	cp.mu.Lock()
+	cp.values[ref] = &cachedValue{value: value, fetchedAt: time.Now()}
+	cp.mu.Unlock()
+	return value, nil
+}
@@ -0,0 +1,80 @@
+package secrets
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestVaultProvider(t *testing.T) {
+	r := require.New(t)
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		if req.Header.Get("X-Vault-Token") != "token" {
+			w.WriteHeader(http.StatusForbidden)
+			return
+		}
+		switch req.URL.Path {
+		case "/v1/secret/data/r2":
+			w.Write([]byte(`{"data":{"data":{"accesskey":"key-v2"},"metadata":{"version":1}}}`))
+		case "/v1/kv/r2":
+			w.Write([]byte(`{"data":{"accesskey":"key-v1"}}`))
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer server.Close()
+
+	provider := NewVaultProvider(server.URL, "token", "")
+	ctx := context.Background()
+
+	value, err := provider.GetSecret(ctx, "secret/data/r2#accesskey")
+	r.NoError(err)
+	r.Equal("key-v2", value)
+
+	value, err = provider.GetSecret(ctx, "kv/r2#accesskey")
+	r.NoError(err)
+	r.Equal("key-v1", value)
+
+	_, err = provider.GetSecret(ctx, "kv/r2#secretkey")
+	r.Error(err)
+	_, err = provider.GetSecret(ctx, "kv/missing#accesskey")
+	r.Error(err)
+	_, err = provider.GetSecret(ctx, "kv/r2")
+	r.Error(err)
+
+	_, err = NewVaultProvider(server.URL, "wrong", "").GetSecret(ctx, "kv/r2#accesskey")
+	r.Error(err)
+}
+
+type countingProvider struct {
+	calls int
+}
+
+func (cp *countingProvider) GetSecret(ctx context.Context, ref string) (string, error) {
+	cp.calls++
+	return ref, nil
+}
+
+func TestResolve_Cached(t *testing.T) {
+	r := require.New(t)
+
+	provider := &countingProvider{}
+	Register("counting", provider, time.Hour)
+
+	for i := 0; i < 3; i++ {
+		value, err := Resolve(context.Background(), "counting:path#field")
+		r.NoError(err)
+		r.Equal("path#field", value)
+	}
+	r.Equal(1, provider.calls)
+
+	_, err := Resolve(context.Background(), "unknown:path#field")
+	r.Error(err)
+	_, err = Resolve(context.Background(), "nocolon")
+	r.Error(err)
+}
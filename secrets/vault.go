@@ -0,0 +1,69 @@
+package secrets
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+)
+
+// VaultProviderName is the name which the Vault provider is registered with.
+const VaultProviderName = "vault"
+
+type vaultProvider struct {
+	address   string
+	token     string
+	namespace string
+	client    *http.Client
+}
+
+// NewVaultProvider creates a provider which reads the secrets from HashiCorp Vault.
+// The references are in <path>#<field> format, e.g. secret/data/disco#accesskey,
+// and work with both KV v1 and v2 engines.
+func NewVaultProvider(address, token, namespace string) Provider {
+	return &vaultProvider{
+		address:   strings.TrimSuffix(address, "/"),
+		token:     token,
+		namespace: namespace,
+		client:    http.DefaultClient,
+	}
+}
+
+// GetSecret implements Provider.
+func (vp *vaultProvider) GetSecret(ctx context.Context, ref string) (string, error) {
+	secretPath, field := splitRef(ref)
+	if len(field) == 0 {
+		return "", errors.New("vault secret references need a field: <path>#<field>")
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, vp.address+"/v1/"+strings.TrimPrefix(secretPath, "/"), nil)
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("X-Vault-Token", vp.token)
+	if len(vp.namespace) > 0 {
+		req.Header.Set("X-Vault-Namespace", vp.namespace)
+	}
+	resp, err := vp.client.Do(req)
+	if err != nil {
+		return "", fmt.Errorf("vault request failed: %v", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("vault responded with status %d for '%s'", resp.StatusCode, secretPath)
+	}
+
+	var body struct {
+		Data map[string]interface{} `json:"data"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&body); err != nil {
+		return "", fmt.Errorf("failed to decode vault response: %v", err)
+	}
+	values := body.Data
+	// KV v2 nests the values with the metadata
+	if nested, ok := values["data"].(map[string]interface{}); ok && values["metadata"] != nil {
+		values = nested
+	}
+	return fieldFromMap(values, field)
+}
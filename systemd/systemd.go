@@ -0,0 +1,68 @@
+// Package systemd implements the socket activation and the readiness notification protocols
+// of systemd without depending on libsystemd.
+package systemd
+
+import (
+	"fmt"
+	"net"
+	"os"
+	"strconv"
+)
+
+// listenFdsStart is the first file descriptor which systemd passes the sockets from.
+const listenFdsStart = 3
+
+// Listeners returns the sockets which systemd passes to the process with socket activation,
+// or nil if the process is not socket-activated. The LISTEN_* environment variables are
+// unset so that the child processes do not use the same sockets.
+func Listeners() ([]net.Listener, error) {
+	defer func() {
+		os.Unsetenv("LISTEN_PID")
+		os.Unsetenv("LISTEN_FDS")
+		os.Unsetenv("LISTEN_FDNAMES")
+	}()
+	pid, err := strconv.Atoi(os.Getenv("LISTEN_PID"))
+	if err != nil || pid != os.Getpid() {
+		return nil, nil
+	}
+	nfds, err := strconv.Atoi(os.Getenv("LISTEN_FDS"))
+	if err != nil || nfds <= 0 {
+		return nil, nil
+	}
+	var listeners []net.Listener
+	for fd := listenFdsStart; fd < listenFdsStart+nfds; fd++ {
+		file := os.NewFile(uintptr(fd), fmt.Sprintf("LISTEN_FD_%d", fd))
+		// the listener uses a duplicate of the descriptor
+		listener, err := net.FileListener(file)
+		file.Close()
+		if err != nil {
+			return nil, fmt.Errorf("failed to listen on socket fd %d: %v", fd, err)
+		}
+		listeners = append(listeners, listener)
+	}
+	return listeners, nil
+}
+
+// CanNotify tells if the process is started by systemd with a notification socket,
+// e.g. with Type=notify.
+func CanNotify() bool {
+	return len(os.Getenv("NOTIFY_SOCKET")) > 0
+}
+
+// Notify sends the state, like "READY=1", to the notification socket. It does nothing if
+// there is no notification socket.
+func Notify(state string) error {
+	socketPath := os.Getenv("NOTIFY_SOCKET")
+	if len(socketPath) == 0 {
+		return nil
+	}
+	conn, err := net.DialUnix("unixgram", nil, &net.UnixAddr{Name: socketPath, Net: "unixgram"})
+	if err != nil {
+		return fmt.Errorf("failed to connect to the notification socket: %v", err)
+	}
+	defer conn.Close()
+	if _, err := conn.Write([]byte(state)); err != nil {
+		return fmt.Errorf("failed to notify systemd: %v", err)
+	}
+	return nil
+}
@@ -0,0 +1,46 @@
+package systemd
+
+import (
+	"net"
+	"os"
+	"path"
+	"strconv"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestListeners_NotActivated(t *testing.T) {
+	r := require.New(t)
+
+	t.Setenv("LISTEN_PID", strconv.Itoa(os.Getpid()+1))
+	t.Setenv("LISTEN_FDS", "1")
+
+	listeners, err := Listeners()
+	r.NoError(err)
+	r.Nil(listeners)
+	_, ok := os.LookupEnv("LISTEN_FDS")
+	r.False(ok, "the environment should be unset")
+}
+
+func TestNotify(t *testing.T) {
+	r := require.New(t)
+
+	t.Setenv("NOTIFY_SOCKET", "")
+	r.False(CanNotify())
+	r.NoError(Notify("READY=1"))
+
+	socketPath := path.Join(t.TempDir(), "notify.sock")
+	conn, err := net.ListenUnixgram("unixgram", &net.UnixAddr{Name: socketPath, Net: "unixgram"})
+	r.NoError(err)
+	defer conn.Close()
+
+	t.Setenv("NOTIFY_SOCKET", socketPath)
+	r.True(CanNotify())
+	r.NoError(Notify("READY=1"))
+
+	b := make([]byte, 64)
+	n, err := conn.Read(b)
+	r.NoError(err)
+	r.Equal("READY=1", string(b[:n]))
+}
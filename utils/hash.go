@@ -1,6 +1,7 @@
 package utils
 
 import (
+	"bytes"
 	"encoding/hex"
 	"strings"
 
@@ -17,6 +18,28 @@ func ToCIDv1(cidV0 string) (string, error) {
 	return cid.NewCidV1(cid.DagProtobuf, parsed).String(), nil
 }
 
+// CIDEquals checks if two CIDs of any version address the same content.
+func CIDEquals(c1, c2 string) bool {
+	if c1 == c2 {
+		return true
+	}
+	parsed1, err := cid.Parse(c1)
+	if err != nil {
+		return false
+	}
+	parsed2, err := cid.Parse(c2)
+	if err != nil {
+		return false
+	}
+	return bytes.Equal(parsed1.Hash(), parsed2.Hash())
+}
+
+// IsCID checks if the hash is an IPFS CID of any version.
+func IsCID(h string) bool {
+	_, err := cid.Parse(h)
+	return err == nil
+}
+
 // IsCIDv1 checks if the hash is an IPFS CIDv1 hash.
 func IsCIDv1(h string) bool {
 	parsed, err := cid.Parse(h)
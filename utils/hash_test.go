@@ -24,6 +24,14 @@ func TestToCIDv1(t *testing.T) {
 	r.Error(err)
 }
 
+func TestIsCID(t *testing.T) {
+	r := require.New(t)
+
+	r.True(IsCID(testCidv1))
+	r.True(IsCID(testCidv0))
+	r.False(IsCID(""))
+}
+
 func TestIsCIDv1(t *testing.T) {
 	r := require.New(t)
 
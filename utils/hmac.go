@@ -0,0 +1,102 @@
+package utils
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// The headers of the signed requests.
+const (
+	HMACTimestampHeader = "X-Disco-Timestamp"
+	HMACNonceHeader     = "X-Disco-Nonce"
+	HMACSignatureHeader = "X-Disco-HMAC"
+)
+
+// maxSignedBodySize limits the bodies which are read to verify the signatures.
+const maxSignedBodySize = 1 << 20
+
+// IsSignedRequest tells if the request has an HMAC signature.
+func IsSignedRequest(r *http.Request) bool {
+	return len(r.Header.Get(HMACSignatureHeader)) > 0
+}
+
+// SignRequest signs the request with the secret by setting the timestamp, the nonce and the
+// signature headers. The body is read and given back to the request.
+func SignRequest(r *http.Request, secret string) error {
+	body, err := readBody(r)
+	if err != nil {
+		return err
+	}
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return err
+	}
+	timestamp := strconv.FormatInt(time.Now().Unix(), 10)
+	nonce := hex.EncodeToString(b)
+	r.Header.Set(HMACTimestampHeader, timestamp)
+	r.Header.Set(HMACNonceHeader, nonce)
+	r.Header.Set(HMACSignatureHeader, requestMAC(r, body, timestamp, nonce, secret))
+	return nil
+}
+
+// VerifyRequestSignature checks the signature of the request and that its timestamp is within
+// the max skew. It returns the nonce, which the caller should not accept again within the max
+// skew, so that the requests cannot be replayed.
+func VerifyRequestSignature(r *http.Request, secret string, maxSkew time.Duration) (string, error) {
+	timestamp := r.Header.Get(HMACTimestampHeader)
+	nonce := r.Header.Get(HMACNonceHeader)
+	signature := r.Header.Get(HMACSignatureHeader)
+	if len(timestamp) == 0 || len(nonce) == 0 || len(signature) == 0 {
+		return "", errors.New("missing signature headers")
+	}
+	unix, err := strconv.ParseInt(timestamp, 10, 64)
+	if err != nil {
+		return "", errors.New("invalid timestamp")
+	}
+	if skew := time.Since(time.Unix(unix, 0)); skew > maxSkew || skew < -maxSkew {
+		return "", fmt.Errorf("timestamp is off by %s", skew.Round(time.Second))
+	}
+	body, err := readBody(r)
+	if err != nil {
+		return "", err
+	}
+	expected := requestMAC(r, body, timestamp, nonce, secret)
+	if !hmac.Equal([]byte(signature), []byte(expected)) {
+		return "", errors.New("invalid signature")
+	}
+	return nonce, nil
+}
+
+// requestMAC computes the signature of the method, the path with the query, the timestamp, the
+// nonce and the body hash.
+func requestMAC(r *http.Request, body []byte, timestamp, nonce, secret string) string {
+	bodyHash := sha256.Sum256(body)
+	mac := hmac.New(sha256.New, []byte(secret))
+	fmt.Fprintf(mac, "%s\n%s\n%s\n%s\n%s", r.Method, r.URL.RequestURI(), timestamp, nonce, hex.EncodeToString(bodyHash[:]))
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// readBody reads the body and gives it back to the request.
+func readBody(r *http.Request) ([]byte, error) {
+	if r.Body == nil || r.Body == http.NoBody {
+		return nil, nil
+	}
+	body, err := io.ReadAll(io.LimitReader(r.Body, maxSignedBodySize+1))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read the body: %v", err)
+	}
+	if len(body) > maxSignedBodySize {
+		return nil, errors.New("body is too large to sign")
+	}
+	r.Body = io.NopCloser(bytes.NewReader(body))
+	return body, nil
+}
@@ -0,0 +1,58 @@
+package utils
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+const testHMACSecret = "0123456789abcdef0123456789abcdef"
+
+func TestSignRequest(t *testing.T) {
+	r := require.New(t)
+
+	newSignedRequest := func(body string) *http.Request {
+		req := httptest.NewRequest(http.MethodPost, "/disco/replicate?wait=1", strings.NewReader(body))
+		r.NoError(SignRequest(req, testHMACSecret))
+		r.True(IsSignedRequest(req))
+		return req
+	}
+
+	// the body is given back after signing and verifying
+	req := newSignedRequest(`{"cid":"bafy"}`)
+	nonce, err := VerifyRequestSignature(req, testHMACSecret, time.Minute)
+	r.NoError(err)
+	r.Equal(req.Header.Get(HMACNonceHeader), nonce)
+	body, err := io.ReadAll(req.Body)
+	r.NoError(err)
+	r.Equal(`{"cid":"bafy"}`, string(body))
+
+	// the requests with another secret, body or path are rejected
+	req = newSignedRequest(`{"cid":"bafy"}`)
+	_, err = VerifyRequestSignature(req, testHMACSecret+"0", time.Minute)
+	r.Error(err)
+	req = newSignedRequest(`{"cid":"bafy"}`)
+	req.Body = io.NopCloser(strings.NewReader(`{"cid":"other"}`))
+	_, err = VerifyRequestSignature(req, testHMACSecret, time.Minute)
+	r.Error(err)
+	req = newSignedRequest(`{"cid":"bafy"}`)
+	req.URL.RawQuery = ""
+	_, err = VerifyRequestSignature(req, testHMACSecret, time.Minute)
+	r.Error(err)
+
+	// the old requests are rejected
+	req = newSignedRequest("")
+	req.Header.Set(HMACTimestampHeader, strconv.FormatInt(time.Now().Add(-time.Hour).Unix(), 10))
+	_, err = VerifyRequestSignature(req, testHMACSecret, time.Minute)
+	r.Error(err)
+
+	// the unsigned requests are rejected
+	_, err = VerifyRequestSignature(httptest.NewRequest(http.MethodGet, "/disco/repos", nil), testHMACSecret, time.Minute)
+	r.Error(err)
+}
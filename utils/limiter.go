@@ -0,0 +1,165 @@
+package utils
+
+import (
+	"context"
+	"errors"
+	"sync"
+)
+
+// ErrQueueFull is returned when the wait queue of a concurrency limiter is full.
+var ErrQueueFull = errors.New("too many operations in progress")
+
+// Priority is the class of an operation in a concurrency limiter. The free slots are given to
+// the waiting operations of the higher classes first.
+type Priority int
+
+// Priority classes.
+const (
+	// PriorityBackground is for the work which nobody waits for, e.g. prefetching. The
+	// background operations can be preempted by the high priority ones.
+	PriorityBackground Priority = iota
+	PriorityNormal
+	PriorityHigh
+
+	priorityCount
+)
+
+// ConcurrencyLimiter limits the operations which run at the same time. The operations beyond
+// the limit wait in a bounded queue. A nil limiter does not limit.
+type ConcurrencyLimiter struct {
+	max       int
+	queueSize int
+
+	mu      sync.Mutex
+	running int
+	waiters [priorityCount][]chan struct{}
+	// preemptible contains the cancel funcs of the running background operations.
+	preemptible map[*limiterSlot]context.CancelFunc
+}
+
+type limiterSlot struct {
+	once sync.Once
+}
+
+// NewConcurrencyLimiter creates a new limiter which lets max operations run and queueSize
+// operations wait at the same time.
+func NewConcurrencyLimiter(max, queueSize int) *ConcurrencyLimiter {
+	return &ConcurrencyLimiter{
+		max:         max,
+		queueSize:   queueSize,
+		preemptible: make(map[*limiterSlot]context.CancelFunc),
+	}
+}
+
+// Acquire waits for a slot and returns the func which releases it. It fails immediately with
+// ErrQueueFull if the queue is full.
+func (l *ConcurrencyLimiter) Acquire(ctx context.Context) (release func(), err error) {
+	return l.AcquireWithPriority(ctx, PriorityNormal, nil)
+}
+
+// AcquireWithPriority waits for a slot in the priority class and returns the func which
+// releases it. The background operations can pass the cancel func of their work, so that a
+// high priority operation which would wait can preempt them.
+func (l *ConcurrencyLimiter) AcquireWithPriority(ctx context.Context, priority Priority, cancel context.CancelFunc) (release func(), err error) {
+	if l == nil {
+		return func() {}, nil
+	}
+	if priority < PriorityBackground || priority >= priorityCount {
+		priority = PriorityNormal
+	}
+	l.mu.Lock()
+	if l.running < l.max {
+		l.running++
+		release = l.holdSlot(priority, cancel)
+		l.mu.Unlock()
+		return release, nil
+	}
+	if l.waiting() >= l.queueSize {
+		l.mu.Unlock()
+		return nil, ErrQueueFull
+	}
+	ready := make(chan struct{})
+	l.waiters[priority] = append(l.waiters[priority], ready)
+	if priority == PriorityHigh {
+		l.preemptOne()
+	}
+	l.mu.Unlock()
+
+	select {
+	case <-ready:
+		l.mu.Lock()
+		release = l.holdSlot(priority, cancel)
+		l.mu.Unlock()
+		return release, nil
+	case <-ctx.Done():
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.removeWaiter(priority, ready) {
+		return nil, ctx.Err()
+	}
+	// the slot was given while the context was done
+	l.running--
+	l.handOver()
+	return nil, ctx.Err()
+}
+
+func (l *ConcurrencyLimiter) waiting() (count int) {
+	for _, waiters := range l.waiters {
+		count += len(waiters)
+	}
+	return
+}
+
+// holdSlot records the running operation and returns the func which releases its slot once.
+func (l *ConcurrencyLimiter) holdSlot(priority Priority, cancel context.CancelFunc) func() {
+	slot := &limiterSlot{}
+	if priority == PriorityBackground && cancel != nil {
+		l.preemptible[slot] = cancel
+	}
+	return func() {
+		slot.once.Do(func() {
+			l.mu.Lock()
+			defer l.mu.Unlock()
+			delete(l.preemptible, slot)
+			l.running--
+			l.handOver()
+		})
+	}
+}
+
+// handOver gives a free slot to the first waiting operation of the highest class.
+func (l *ConcurrencyLimiter) handOver() {
+	if l.running >= l.max {
+		return
+	}
+	for priority := priorityCount - 1; priority >= PriorityBackground; priority-- {
+		if len(l.waiters[priority]) == 0 {
+			continue
+		}
+		ready := l.waiters[priority][0]
+		l.waiters[priority] = l.waiters[priority][1:]
+		l.running++
+		close(ready)
+		return
+	}
+}
+
+// preemptOne cancels a running background operation, which releases its slot when it stops.
+func (l *ConcurrencyLimiter) preemptOne() {
+	for slot, cancel := range l.preemptible {
+		delete(l.preemptible, slot)
+		cancel()
+		return
+	}
+}
+
+func (l *ConcurrencyLimiter) removeWaiter(priority Priority, ready chan struct{}) bool {
+	for i, waiter := range l.waiters[priority] {
+		if waiter == ready {
+			l.waiters[priority] = append(l.waiters[priority][:i], l.waiters[priority][i+1:]...)
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,88 @@
+package utils
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestConcurrencyLimiter(t *testing.T) {
+	r := require.New(t)
+	ctx := context.Background()
+
+	limiter := NewConcurrencyLimiter(1, 1)
+	release, err := limiter.Acquire(ctx)
+	r.NoError(err)
+
+	acquired := make(chan func())
+	go func() {
+		release, err := limiter.Acquire(ctx)
+		if err == nil {
+			acquired <- release
+		}
+	}()
+	// wait for the second operation to be queued
+	r.Eventually(func() bool {
+		return testWaiting(limiter) == 1
+	}, time.Second, time.Millisecond*10)
+
+	_, err = limiter.Acquire(ctx)
+	r.ErrorIs(err, ErrQueueFull)
+
+	release()
+	(<-acquired)()
+
+	var nilLimiter *ConcurrencyLimiter
+	release, err = nilLimiter.Acquire(ctx)
+	r.NoError(err)
+	release()
+}
+
+func TestConcurrencyLimiter_Priority(t *testing.T) {
+	r := require.New(t)
+	ctx := context.Background()
+
+	// Given a background operation which holds the only slot
+	limiter := NewConcurrencyLimiter(1, 2)
+	backgroundCtx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	releaseBackground, err := limiter.AcquireWithPriority(ctx, PriorityBackground, cancel)
+	r.NoError(err)
+
+	// And a normal operation which waits
+	acquired := make(chan Priority, 2)
+	acquire := func(priority Priority) {
+		release, err := limiter.AcquireWithPriority(ctx, priority, nil)
+		if err == nil {
+			acquired <- priority
+			release()
+		}
+	}
+	go acquire(PriorityNormal)
+	r.Eventually(func() bool {
+		return testWaiting(limiter) == 1
+	}, time.Second, time.Millisecond*10)
+
+	// When a high priority operation waits
+	go acquire(PriorityHigh)
+	r.Eventually(func() bool {
+		return testWaiting(limiter) == 2
+	}, time.Second, time.Millisecond*10)
+
+	// Then the background operation should be preempted
+	<-backgroundCtx.Done()
+	releaseBackground()
+	releaseBackground()
+
+	// And the high priority operation should run before the normal one
+	r.Equal(PriorityHigh, <-acquired)
+	r.Equal(PriorityNormal, <-acquired)
+}
+
+func testWaiting(limiter *ConcurrencyLimiter) int {
+	limiter.mu.Lock()
+	defer limiter.mu.Unlock()
+	return limiter.waiting()
+}
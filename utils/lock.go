@@ -0,0 +1,18 @@
+package utils
+
+import (
+	"context"
+	"errors"
+)
+
+// ErrLockNotHeld is returned when a lock is released or refreshed after it was lost, e.g.
+// after it expired and another holder acquired it.
+var ErrLockNotHeld = errors.New("lock is not held")
+
+// Locker locks the keys across the processes which share the lock provider.
+type Locker interface {
+	// Lock waits until the key is locked and returns the func which unlocks it. The holder
+	// should run under the returned context, which is cancelled when the lock is lost, e.g.
+	// after it expired and another holder acquired it.
+	Lock(ctx context.Context, key string) (lockCtx context.Context, unlock func(), err error)
+}
@@ -0,0 +1,50 @@
+package utils
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"net/http"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// RequestIDHeader is the header which the ID of a request is read from and passed to the
+// registry in.
+const RequestIDHeader = "X-Request-Id"
+
+// distributionRequestKey is the context key which the registry keeps the request in.
+const distributionRequestKey = "http.request"
+
+type loggerKey struct{}
+
+// NewRequestID generates a random request ID.
+func NewRequestID() string {
+	b := make([]byte, 8)
+	_, _ = rand.Read(b)
+	return hex.EncodeToString(b)
+}
+
+// WithLogger returns a context which carries the logger, so that the logs of the request have
+// its fields.
+func WithLogger(ctx context.Context, logger *log.Entry) context.Context {
+	return context.WithValue(ctx, loggerKey{}, logger)
+}
+
+// Logger returns the logger of the request in the context. The drivers which are called by the
+// registry get the request ID from the request of the registry. It returns the standard logger
+// without fields otherwise.
+func Logger(ctx context.Context) *log.Entry {
+	if ctx == nil {
+		return log.NewEntry(log.StandardLogger())
+	}
+	if logger, ok := ctx.Value(loggerKey{}).(*log.Entry); ok {
+		return logger
+	}
+	if r, ok := ctx.Value(distributionRequestKey).(*http.Request); ok {
+		if requestID := r.Header.Get(RequestIDHeader); len(requestID) > 0 {
+			return log.WithField("requestId", requestID)
+		}
+	}
+	return log.NewEntry(log.StandardLogger())
+}
@@ -0,0 +1,85 @@
+package utils
+
+import (
+	"fmt"
+	"regexp"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// RedactedValue replaces the secrets in the logs and the error messages.
+const RedactedValue = "<redacted>"
+
+var (
+	// secretParamPattern matches the values of the secret parameters in the key=value and the
+	// key:value forms, e.g. in the printed parameter maps and JSON.
+	secretParamPattern = regexp.MustCompile(`(?i)\b(accesskey|secretkey|accountkey|password|privatekey|private_key)(["']?(?:\s*=\s*|:)["']?)([^\s"'&,;\]}]+)`)
+	// secretQueryPattern matches the values of the secret query parameters, e.g. in the
+	// presigned URLs.
+	secretQueryPattern = regexp.MustCompile(`(?i)([?&](?:token|access_token|x-amz-credential|x-amz-signature|x-amz-security-token|signature|sig)=)[^\s"'&]+`)
+	// authorizationPattern matches the credentials in the Authorization headers.
+	authorizationPattern = regexp.MustCompile(`\b(Bearer|Basic)(\s+)[A-Za-z0-9._~+/=-]{8,}`)
+	// userinfoPattern matches the passwords in the URLs.
+	userinfoPattern = regexp.MustCompile(`(://[^/\s:@]*:)[^/\s@]+@`)
+)
+
+// Redact replaces the access keys, the tokens, the signatures of the presigned URLs and the
+// passwords in the URLs with RedactedValue.
+func Redact(s string) string {
+	s = secretParamPattern.ReplaceAllString(s, "${1}${2}"+RedactedValue)
+	s = secretQueryPattern.ReplaceAllString(s, "${1}"+RedactedValue)
+	s = authorizationPattern.ReplaceAllString(s, "${1}${2}"+RedactedValue)
+	return userinfoPattern.ReplaceAllString(s, "${1}"+RedactedValue+"@")
+}
+
+// RedactError returns an error which has the message of the error without the secrets. The
+// error is still unwrapped to the original one.
+func RedactError(err error) error {
+	if err == nil {
+		return nil
+	}
+	message := Redact(err.Error())
+	if message == err.Error() {
+		return err
+	}
+	return &redactedError{message: message, err: err}
+}
+
+type redactedError struct {
+	message string
+	err     error
+}
+
+func (e *redactedError) Error() string {
+	return e.message
+}
+
+func (e *redactedError) Unwrap() error {
+	return e.err
+}
+
+// RedactHook removes the secrets from the messages and the fields of the logs.
+type RedactHook struct{}
+
+// Levels implements the log.Hook interface.
+func (RedactHook) Levels() []log.Level {
+	return log.AllLevels
+}
+
+// Fire implements the log.Hook interface.
+func (RedactHook) Fire(entry *log.Entry) error {
+	entry.Message = Redact(entry.Message)
+	for key, value := range entry.Data {
+		switch value := value.(type) {
+		case string:
+			entry.Data[key] = Redact(value)
+		case error:
+			entry.Data[key] = RedactError(value)
+		case fmt.Stringer:
+			if s := value.String(); Redact(s) != s {
+				entry.Data[key] = Redact(s)
+			}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,151 @@
+package utils
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"net"
+	"strconv"
+	"strings"
+)
+
+// redisMaxIdleConns is how many connections are kept open for the next commands.
+const redisMaxIdleConns = 8
+
+// RedisClient sends commands to a Redis server.
+type RedisClient struct {
+	addr     string
+	password string
+	db       int
+	idle     chan *redisConn
+}
+
+// redisConn is an authenticated connection with the selected database.
+type redisConn struct {
+	net.Conn
+	reader *bufio.Reader
+}
+
+// NewRedisClient creates a new Redis client.
+func NewRedisClient(addr, password string, db int) *RedisClient {
+	return &RedisClient{
+		addr:     addr,
+		password: password,
+		db:       db,
+		idle:     make(chan *redisConn, redisMaxIdleConns),
+	}
+}
+
+// Do sends the command and returns the reply. The replies are strings, integers, arrays or nil.
+// The connections are reused, since the commands can be sent on the request paths, e.g. by the
+// metadata cache, and closed after the failures.
+func (client *RedisClient) Do(ctx context.Context, args ...string) (interface{}, error) {
+	conn, err := client.conn(ctx)
+	if err != nil {
+		return nil, err
+	}
+	deadline, _ := ctx.Deadline()
+	_ = conn.SetDeadline(deadline)
+	reply, err := redisCommand(conn, conn.reader, args...)
+	// the error replies leave the connection usable
+	if err != nil && !strings.HasPrefix(err.Error(), "redis: ") {
+		conn.Close()
+		return nil, err
+	}
+	select {
+	case client.idle <- conn:
+	default:
+		conn.Close()
+	}
+	return reply, err
+}
+
+// conn returns an idle connection or a new one.
+func (client *RedisClient) conn(ctx context.Context) (*redisConn, error) {
+	select {
+	case conn := <-client.idle:
+		return conn, nil
+	default:
+	}
+	var dialer net.Dialer
+	netConn, err := dialer.DialContext(ctx, "tcp", client.addr)
+	if err != nil {
+		return nil, err
+	}
+	conn := &redisConn{Conn: netConn, reader: bufio.NewReader(netConn)}
+	if deadline, ok := ctx.Deadline(); ok {
+		_ = conn.SetDeadline(deadline)
+	}
+	if len(client.password) > 0 {
+		if _, err := redisCommand(conn, conn.reader, "AUTH", client.password); err != nil {
+			conn.Close()
+			return nil, fmt.Errorf("failed to authenticate: %v", err)
+		}
+	}
+	if client.db > 0 {
+		if _, err := redisCommand(conn, conn.reader, "SELECT", strconv.Itoa(client.db)); err != nil {
+			conn.Close()
+			return nil, fmt.Errorf("failed to select the database: %v", err)
+		}
+	}
+	return conn, nil
+}
+
+// redisCommand writes the command in the Redis protocol and reads the reply.
+func redisCommand(w io.Writer, r *bufio.Reader, args ...string) (interface{}, error) {
+	var b strings.Builder
+	fmt.Fprintf(&b, "*%d\r\n", len(args))
+	for _, arg := range args {
+		fmt.Fprintf(&b, "$%d\r\n%s\r\n", len(arg), arg)
+	}
+	if _, err := io.WriteString(w, b.String()); err != nil {
+		return nil, err
+	}
+	return readRedisReply(r)
+}
+
+// readRedisReply reads a reply in the Redis protocol. The nil replies are returned as nil and
+// the error replies as errors.
+func readRedisReply(r *bufio.Reader) (interface{}, error) {
+	line, err := r.ReadString('\n')
+	if err != nil {
+		return nil, err
+	}
+	line = strings.TrimSuffix(line, "\r\n")
+	if len(line) == 0 {
+		return nil, fmt.Errorf("empty reply")
+	}
+	switch line[0] {
+	case '+':
+		return line[1:], nil
+	case '-':
+		return nil, fmt.Errorf("redis: %s", line[1:])
+	case ':':
+		return strconv.ParseInt(line[1:], 10, 64)
+	case '$':
+		size, err := strconv.Atoi(line[1:])
+		if err != nil || size < 0 {
+			return nil, err
+		}
+		b := make([]byte, size+2)
+		if _, err := io.ReadFull(r, b); err != nil {
+			return nil, err
+		}
+		return string(b[:size]), nil
+	case '*':
+		count, err := strconv.Atoi(line[1:])
+		if err != nil || count < 0 {
+			return nil, err
+		}
+		items := make([]interface{}, count)
+		for i := range items {
+			if items[i], err = readRedisReply(r); err != nil {
+				return nil, err
+			}
+		}
+		return items, nil
+	default:
+		return nil, fmt.Errorf("unexpected reply '%s'", line)
+	}
+}
@@ -0,0 +1,119 @@
+package utils
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"net"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+// fakeRedis serves the commands which the Redis locker uses.
+type fakeRedis struct {
+	mu   sync.Mutex
+	keys map[string]string
+}
+
+func startFakeRedis(t *testing.T) string {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	t.Cleanup(func() {
+		listener.Close()
+	})
+	server := &fakeRedis{keys: make(map[string]string)}
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			go server.serve(conn)
+		}
+	}()
+	return listener.Addr().String()
+}
+
+func (server *fakeRedis) serve(conn net.Conn) {
+	defer conn.Close()
+	reader := bufio.NewReader(conn)
+	for {
+		reply, err := readRedisReply(reader)
+		if err != nil {
+			return
+		}
+		var args []string
+		for _, arg := range reply.([]interface{}) {
+			args = append(args, arg.(string))
+		}
+		_, _ = io.WriteString(conn, server.handle(args))
+	}
+}
+
+func (server *fakeRedis) handle(args []string) string {
+	server.mu.Lock()
+	defer server.mu.Unlock()
+	switch {
+	case args[0] == "SET":
+		if _, ok := server.keys[args[1]]; ok && len(args) > 3 && args[3] == "NX" {
+			return "$-1\r\n"
+		}
+		server.keys[args[1]] = args[2]
+		return "+OK\r\n"
+	case args[0] == "GET":
+		value, ok := server.keys[args[1]]
+		if !ok {
+			return "$-1\r\n"
+		}
+		return fmt.Sprintf("$%d\r\n%s\r\n", len(value), value)
+	case args[0] == "DEL":
+		delete(server.keys, args[1])
+		return ":1\r\n"
+	case args[0] == "EVAL" && server.keys[args[3]] != args[4]:
+		return ":0\r\n"
+	case args[0] == "EVAL" && strings.Contains(args[1], `"del"`):
+		delete(server.keys, args[3])
+		return ":1\r\n"
+	case args[0] == "EVAL":
+		return ":1\r\n"
+	default:
+		return "-ERR unknown command\r\n"
+	}
+}
+
+func TestRedisClient(t *testing.T) {
+	r := require.New(t)
+	ctx := context.Background()
+
+	client := NewRedisClient(startFakeRedis(t), "", 0)
+	reply, err := client.Do(ctx, "GET", "key1")
+	r.NoError(err)
+	r.Nil(reply)
+
+	reply, err = client.Do(ctx, "SET", "key1", "value1")
+	r.NoError(err)
+	r.Equal("OK", reply)
+
+	reply, err = client.Do(ctx, "GET", "key1")
+	r.NoError(err)
+	r.Equal("value1", reply)
+}
+
+func TestReadRedisReply(t *testing.T) {
+	r := require.New(t)
+
+	reply, err := readRedisReply(bufio.NewReader(strings.NewReader("*2\r\n$3\r\nfoo\r\n:1\r\n")))
+	r.NoError(err)
+	r.Equal([]interface{}{"foo", int64(1)}, reply)
+
+	reply, err = readRedisReply(bufio.NewReader(strings.NewReader("$-1\r\n")))
+	r.NoError(err)
+	r.Nil(reply)
+
+	_, err = readRedisReply(bufio.NewReader(strings.NewReader("-ERR wrong\r\n")))
+	r.Error(err)
+}
@@ -0,0 +1,127 @@
+package utils
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"strconv"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// lockRetryInterval is how often a locked key is tried again.
+const lockRetryInterval = 250 * time.Millisecond
+
+// the scripts make sure that only the holder of a lock can release or refresh it
+const (
+	unlockScript  = `if redis.call("get", KEYS[1]) == ARGV[1] then return redis.call("del", KEYS[1]) else return 0 end`
+	refreshScript = `if redis.call("get", KEYS[1]) == ARGV[1] then return redis.call("pexpire", KEYS[1], ARGV[2]) else return 0 end`
+)
+
+// RedisLocker locks the keys in a Redis server. The locks expire after the TTL unless they
+// are refreshed, so that the keys are not locked forever after the holder crashes.
+type RedisLocker struct {
+	client *RedisClient
+	ttl    time.Duration
+	prefix string
+}
+
+// NewRedisLocker creates a new Redis locker.
+func NewRedisLocker(client *RedisClient, ttl time.Duration, prefix string) *RedisLocker {
+	return &RedisLocker{
+		client: client,
+		ttl:    ttl,
+		prefix: prefix,
+	}
+}
+
+// Lock implements Locker. The lock is refreshed in the background until it is unlocked.
+func (locker *RedisLocker) Lock(ctx context.Context, key string) (context.Context, func(), error) {
+	key = locker.prefix + key
+	token, err := newLockToken()
+	if err != nil {
+		return nil, nil, err
+	}
+	for {
+		reply, err := locker.client.Do(ctx, "SET", key, token, "NX", "PX", strconv.FormatInt(locker.ttl.Milliseconds(), 10))
+		if err != nil {
+			return nil, nil, fmt.Errorf("failed to lock %s: %v", key, err)
+		}
+		if reply != nil {
+			break
+		}
+		select {
+		case <-time.After(lockRetryInterval):
+		case <-ctx.Done():
+			return nil, nil, fmt.Errorf("failed to lock %s: %w", key, ctx.Err())
+		}
+	}
+
+	lockCtx, lost := context.WithCancel(ctx)
+	stop := make(chan struct{})
+	go locker.refresh(key, token, stop, lost)
+	return lockCtx, func() {
+		close(stop)
+		lost()
+		if err := locker.release(key, token); err != nil {
+			log.WithError(err).WithField("key", key).Warn("failed to unlock")
+		}
+	}, nil
+}
+
+// refresh extends the lock until it is stopped. The lock is lost if another holder has it or if
+// it cannot be refreshed before it expires, and then the holder is stopped with the lost func.
+func (locker *RedisLocker) refresh(key, token string, stop chan struct{}, lost context.CancelFunc) {
+	interval := locker.ttl / 3
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	refreshedAt := time.Now()
+	for {
+		select {
+		case <-ticker.C:
+		case <-stop:
+			return
+		}
+		ctx, cancel := context.WithTimeout(context.Background(), interval)
+		reply, err := locker.client.Do(ctx, "EVAL", refreshScript, "1", key, token, strconv.FormatInt(locker.ttl.Milliseconds(), 10))
+		cancel()
+		switch {
+		case err == nil && reply == int64(0):
+			log.WithError(ErrLockNotHeld).WithField("key", key).Error("lost the lock - stopping the holder")
+			lost()
+			return
+		case err != nil && time.Since(refreshedAt)+interval >= locker.ttl:
+			log.WithError(err).WithField("key", key).Error("failed to refresh the lock before it expires - stopping the holder")
+			lost()
+			return
+		case err != nil:
+			log.WithError(err).WithField("key", key).Warn("failed to refresh the lock")
+		default:
+			refreshedAt = time.Now()
+		}
+	}
+}
+
+// release deletes the lock if it is still held.
+func (locker *RedisLocker) release(key, token string) error {
+	ctx, cancel := context.WithTimeout(context.Background(), locker.ttl)
+	defer cancel()
+	reply, err := locker.client.Do(ctx, "EVAL", unlockScript, "1", key, token)
+	if err != nil {
+		return err
+	}
+	if reply == int64(0) {
+		return ErrLockNotHeld
+	}
+	return nil
+}
+
+func newLockToken() (string, error) {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "", fmt.Errorf("failed to create the lock token: %v", err)
+	}
+	return hex.EncodeToString(b), nil
+}
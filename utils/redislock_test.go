@@ -0,0 +1,59 @@
+package utils
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestRedisLocker(t *testing.T) {
+	r := require.New(t)
+	ctx := context.Background()
+
+	locker := NewRedisLocker(NewRedisClient(startFakeRedis(t), "", 0), time.Second, "disco/lock/")
+	lockCtx, unlock, err := locker.Lock(ctx, "key1")
+	r.NoError(err)
+
+	// the locked key cannot be locked again until it is unlocked
+	timeoutCtx, cancel := context.WithTimeout(ctx, time.Millisecond*500)
+	defer cancel()
+	_, _, err = locker.Lock(timeoutCtx, "key1")
+	r.ErrorIs(err, context.DeadlineExceeded)
+
+	// the other keys can be locked
+	_, unlock2, err := locker.Lock(ctx, "key2")
+	r.NoError(err)
+	unlock2()
+
+	// the lock context is done after unlocking
+	r.NoError(lockCtx.Err())
+	unlock()
+	r.ErrorIs(lockCtx.Err(), context.Canceled)
+	_, unlock, err = locker.Lock(ctx, "key1")
+	r.NoError(err)
+	unlock()
+}
+
+func TestRedisLocker_Lost(t *testing.T) {
+	r := require.New(t)
+	ctx := context.Background()
+
+	client := NewRedisClient(startFakeRedis(t), "", 0)
+	locker := NewRedisLocker(client, time.Millisecond*300, "disco/lock/")
+	lockCtx, unlock, err := locker.Lock(ctx, "key1")
+	r.NoError(err)
+	defer unlock()
+
+	// when the lock expires and another holder acquires it
+	_, err = client.Do(ctx, "SET", "disco/lock/key1", "other")
+	r.NoError(err)
+
+	// then the lock context should be cancelled when the lock is refreshed
+	select {
+	case <-lockCtx.Done():
+	case <-time.After(time.Second):
+		r.Fail("the lock context was not cancelled")
+	}
+}
@@ -0,0 +1,70 @@
+package utils
+
+import (
+	"context"
+	"errors"
+	"sync"
+)
+
+// SingleFlight runs one call per key at a time. The callers of a key which is in flight wait
+// for the result of the running call instead of running it again.
+type SingleFlight struct {
+	mu    sync.Mutex
+	calls map[string]*flightCall
+}
+
+var errCallPanicked = errors.New("call panicked")
+
+type flightCall struct {
+	done    chan struct{}
+	err     error
+	waiters int
+}
+
+// Do runs fn for the key unless it is running already and returns its error. The callers which
+// wait for a call that fails with a context error run it again, since it was cancelled with the
+// context of another caller.
+func (group *SingleFlight) Do(ctx context.Context, key string, fn func() error) error {
+	for {
+		group.mu.Lock()
+		if group.calls == nil {
+			group.calls = make(map[string]*flightCall)
+		}
+		call, ok := group.calls[key]
+		if !ok {
+			call = &flightCall{done: make(chan struct{})}
+			group.calls[key] = call
+			group.mu.Unlock()
+			group.run(key, call, fn)
+			return call.err
+		}
+		call.waiters++
+		group.mu.Unlock()
+
+		select {
+		case <-call.done:
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+		if isContextErr(call.err) && ctx.Err() == nil {
+			continue
+		}
+		return call.err
+	}
+}
+
+func (group *SingleFlight) run(key string, call *flightCall, fn func() error) {
+	defer func() {
+		group.mu.Lock()
+		delete(group.calls, key)
+		group.mu.Unlock()
+		close(call.done)
+	}()
+	// the waiting callers should not see a success if fn panics
+	call.err = errCallPanicked
+	call.err = fn()
+}
+
+func isContextErr(err error) bool {
+	return errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded)
+}
@@ -0,0 +1,109 @@
+package utils
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestSingleFlight(t *testing.T) {
+	r := require.New(t)
+	ctx := context.Background()
+
+	// Given a call which blocks until it is released
+	var (
+		group   SingleFlight
+		calls   int32
+		wg      sync.WaitGroup
+		started = make(chan struct{})
+		finish  = make(chan struct{})
+	)
+	errFailed := errors.New("failed")
+	call := func() error {
+		if atomic.AddInt32(&calls, 1) == 1 {
+			close(started)
+		}
+		<-finish
+		return errFailed
+	}
+
+	// When it is done for the same key at the same time
+	errs := make(chan error, 3)
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		errs <- group.Do(ctx, "key", call)
+	}()
+	<-started
+	for i := 0; i < 2; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			errs <- group.Do(ctx, "key", call)
+		}()
+	}
+	r.Eventually(func() bool {
+		return testFlightWaiters(&group, "key") == 2
+	}, time.Second, time.Millisecond*10)
+	close(finish)
+	wg.Wait()
+	close(errs)
+
+	// Then all callers should get the result of the only call
+	for err := range errs {
+		r.ErrorIs(err, errFailed)
+	}
+	r.EqualValues(1, calls)
+
+	// And the key should be run again after the call is done
+	r.ErrorIs(group.Do(ctx, "key", call), errFailed)
+	r.EqualValues(2, calls)
+}
+
+func TestSingleFlight_CancelledCall(t *testing.T) {
+	r := require.New(t)
+
+	// Given a call which is cancelled with the context of its caller
+	var group SingleFlight
+	callerCtx, cancel := context.WithCancel(context.Background())
+	started := make(chan struct{})
+	done := make(chan error)
+	go func() {
+		done <- group.Do(callerCtx, "key", func() error {
+			close(started)
+			<-callerCtx.Done()
+			return callerCtx.Err()
+		})
+	}()
+	<-started
+
+	// When another caller waits for it
+	waited := make(chan error)
+	go func() {
+		waited <- group.Do(context.Background(), "key", func() error {
+			return nil
+		})
+	}()
+	r.Eventually(func() bool {
+		return testFlightWaiters(&group, "key") == 1
+	}, time.Second, time.Millisecond*10)
+	cancel()
+
+	// Then the call should be run again for the waiting caller
+	r.ErrorIs(<-done, context.Canceled)
+	r.NoError(<-waited)
+}
+
+func testFlightWaiters(group *SingleFlight, key string) int {
+	group.mu.Lock()
+	defer group.mu.Unlock()
+	if call, ok := group.calls[key]; ok {
+		return call.waiters
+	}
+	return 0
+}
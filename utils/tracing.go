@@ -0,0 +1,162 @@
+package utils
+
+import (
+	"context"
+	"crypto/tls"
+	"fmt"
+	"net/http"
+	"net/url"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+	"go.opentelemetry.io/contrib/instrumentation/net/http/otelhttp"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// Span kinds of the spans which are started by Disco.
+const (
+	SpanKindInternal = trace.SpanKindInternal
+	SpanKindServer   = trace.SpanKindServer
+	SpanKindClient   = trace.SpanKindClient
+)
+
+const (
+	tracerScope           = "github.com/forta-network/disco"
+	tracerShutdownTimeout = 5 * time.Second
+)
+
+// SetTracer sets the tracer which the spans are started with and propagates the traces in the
+// W3C trace context headers. The spans are not recorded if the tracer is nil.
+func SetTracer(t *Tracer) {
+	otel.SetTextMapPropagator(propagation.TraceContext{})
+	if t == nil {
+		otel.SetTracerProvider(trace.NewNoopTracerProvider())
+		return
+	}
+	otel.SetTracerProvider(t.provider)
+}
+
+// Span is an operation in a trace. The methods of the nil spans do nothing.
+type Span struct {
+	span trace.Span
+}
+
+// StartSpan starts a span as a child of the span in the context, with the attributes in key and
+// value pairs. The span should be ended by the caller.
+func StartSpan(ctx context.Context, name string, kind trace.SpanKind, attributes ...string) (context.Context, *Span) {
+	ctx, span := otel.Tracer(tracerScope).Start(ctx, name,
+		trace.WithSpanKind(kind),
+		trace.WithAttributes(stringAttributes(attributes)...),
+	)
+	return ctx, &Span{span: span}
+}
+
+// SetAttributes sets the attributes in key and value pairs.
+func (span *Span) SetAttributes(attributes ...string) {
+	if span == nil {
+		return
+	}
+	span.span.SetAttributes(stringAttributes(attributes)...)
+}
+
+// AddEvent records an event, e.g. a finished step, in the span.
+func (span *Span) AddEvent(name string) {
+	if span == nil {
+		return
+	}
+	span.span.AddEvent(name)
+}
+
+// End ends the span with the error of the operation, if any.
+func (span *Span) End(err error) {
+	if span == nil {
+		return
+	}
+	if err != nil {
+		span.span.SetStatus(codes.Error, Redact(err.Error()))
+	}
+	span.span.End()
+}
+
+func stringAttributes(attributes []string) []attribute.KeyValue {
+	var kvs []attribute.KeyValue
+	for i := 0; i+1 < len(attributes); i += 2 {
+		kvs = append(kvs, attribute.String(attributes[i], attributes[i+1]))
+	}
+	return kvs
+}
+
+// TracerOptions contains the settings of a tracer.
+type TracerOptions struct {
+	// Endpoint is the OTLP/HTTP traces endpoint of a collector, e.g.
+	// http://localhost:4318/v1/traces.
+	Endpoint    string
+	Headers     map[string]string
+	ServiceName string
+	// SampleRatio is the ratio (0-1) of the traces which are started by Disco and exported.
+	SampleRatio float64
+	TLSConfig   *tls.Config
+}
+
+// Tracer exports the spans to an OpenTelemetry collector in batches.
+type Tracer struct {
+	provider *sdktrace.TracerProvider
+}
+
+// NewTracer creates a new tracer which exports the spans with the OTLP/HTTP exporter.
+func NewTracer(opts TracerOptions) (*Tracer, error) {
+	endpoint, err := url.Parse(opts.Endpoint)
+	if err != nil {
+		return nil, fmt.Errorf("invalid traces endpoint: %v", err)
+	}
+	exporterOpts := []otlptracehttp.Option{
+		otlptracehttp.WithEndpoint(endpoint.Host),
+		otlptracehttp.WithURLPath(endpoint.Path),
+		otlptracehttp.WithHeaders(opts.Headers),
+	}
+	if endpoint.Scheme == "http" {
+		exporterOpts = append(exporterOpts, otlptracehttp.WithInsecure())
+	}
+	if opts.TLSConfig != nil {
+		exporterOpts = append(exporterOpts, otlptracehttp.WithTLSClientConfig(opts.TLSConfig))
+	}
+	exporter, err := otlptracehttp.New(context.Background(), exporterOpts...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create the traces exporter: %v", err)
+	}
+	return &Tracer{
+		provider: sdktrace.NewTracerProvider(
+			sdktrace.WithBatcher(exporter),
+			sdktrace.WithResource(resource.NewSchemaless(attribute.String("service.name", opts.ServiceName))),
+			// the traces of the callers are exported if the callers sampled them
+			sdktrace.WithSampler(sdktrace.ParentBased(sdktrace.TraceIDRatioBased(opts.SampleRatio))),
+		),
+	}, nil
+}
+
+// Run waits until the context is done and shuts the tracer down, which exports the last spans.
+func (t *Tracer) Run(ctx context.Context) {
+	<-ctx.Done()
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), tracerShutdownTimeout)
+	defer cancel()
+	if err := t.provider.Shutdown(shutdownCtx); err != nil {
+		log.WithError(err).Warn("failed to export the last spans")
+	}
+}
+
+// TraceTransport starts a client span for each request which is sent with the transport and
+// propagates the trace to the server.
+func TraceTransport(transport http.RoundTripper) http.RoundTripper {
+	return otelhttp.NewTransport(transport,
+		otelhttp.WithSpanNameFormatter(func(_ string, req *http.Request) string {
+			return "HTTP " + req.Method
+		}),
+	)
+}
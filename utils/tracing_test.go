@@ -0,0 +1,141 @@
+package utils
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/trace"
+	coltracepb "go.opentelemetry.io/proto/otlp/collector/trace/v1"
+	commonpb "go.opentelemetry.io/proto/otlp/common/v1"
+	tracepb "go.opentelemetry.io/proto/otlp/trace/v1"
+	"google.golang.org/protobuf/proto"
+)
+
+func TestTracer(t *testing.T) {
+	r := require.New(t)
+
+	// Given that the tracing is disabled
+	// Then the spans should not be recorded
+	SetTracer(nil)
+	_, span := StartSpan(context.Background(), "operation", SpanKindInternal)
+	r.False(span.span.IsRecording())
+	span.AddEvent("step")
+	span.End(nil)
+
+	// Given a collector and a server which is called in a trace
+	var (
+		mu            sync.Mutex
+		exported      []*coltracepb.ExportTraceServiceRequest
+		authorization string
+	)
+	collector := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		b, err := io.ReadAll(req.Body)
+		r.NoError(err)
+		var traces coltracepb.ExportTraceServiceRequest
+		r.NoError(proto.Unmarshal(b, &traces))
+		mu.Lock()
+		defer mu.Unlock()
+		authorization = req.Header.Get("Authorization")
+		exported = append(exported, &traces)
+	}))
+	defer collector.Close()
+	traceparents := make(chan trace.SpanContext, 1)
+	server := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		ctx := propagation.TraceContext{}.Extract(req.Context(), propagation.HeaderCarrier(req.Header))
+		traceparents <- trace.SpanContextFromContext(ctx)
+	}))
+	defer server.Close()
+
+	tracer, err := NewTracer(TracerOptions{
+		Endpoint:    collector.URL + "/v1/traces",
+		Headers:     map[string]string{"Authorization": "Bearer token"},
+		ServiceName: "disco",
+		SampleRatio: 1,
+	})
+	r.NoError(err)
+	SetTracer(tracer)
+	defer SetTracer(nil)
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		tracer.Run(ctx)
+		close(done)
+	}()
+
+	// When an operation calls the server
+	opCtx, span := StartSpan(context.Background(), "operation", SpanKindInternal, "disco.repository", "myrepo")
+	span.AddEvent("step")
+	req, err := http.NewRequestWithContext(opCtx, http.MethodGet, server.URL+"/api", nil)
+	r.NoError(err)
+	resp, err := (&http.Client{Transport: TraceTransport(http.DefaultTransport)}).Do(req)
+	r.NoError(err)
+	resp.Body.Close()
+	span.End(errors.New("failed"))
+
+	// Then the trace should be propagated to the server
+	sc := <-traceparents
+	r.True(sc.IsValid())
+	r.Equal(span.span.SpanContext().TraceID(), sc.TraceID())
+
+	// And the spans should be exported when the tracer stops
+	cancel()
+	<-done
+	mu.Lock()
+	defer mu.Unlock()
+	r.Equal("Bearer token", authorization)
+	var spans []*tracepb.Span
+	for _, traces := range exported {
+		for _, resourceSpans := range traces.ResourceSpans {
+			r.Contains(resourceSpans.Resource.Attributes, &commonpb.KeyValue{
+				Key:   "service.name",
+				Value: &commonpb.AnyValue{Value: &commonpb.AnyValue_StringValue{StringValue: "disco"}},
+			})
+			for _, scopeSpans := range resourceSpans.ScopeSpans {
+				spans = append(spans, scopeSpans.Spans...)
+			}
+		}
+	}
+	r.Len(spans, 2)
+	client, operation := spans[0], spans[1]
+	r.Equal("HTTP GET", client.Name)
+	r.Equal(tracepb.Span_SPAN_KIND_CLIENT, client.Kind)
+	r.Equal(operation.SpanId, client.ParentSpanId)
+	r.Equal(operation.TraceId, client.TraceId)
+	r.Equal("operation", operation.Name)
+	r.Empty(operation.ParentSpanId)
+	r.Len(operation.Events, 1)
+	r.Equal(tracepb.Status_STATUS_CODE_ERROR, operation.Status.Code)
+	r.Equal("failed", operation.Status.Message)
+}
+
+func TestTracer_Sample(t *testing.T) {
+	r := require.New(t)
+
+	// Given a tracer which samples none of the new traces
+	tracer, err := NewTracer(TracerOptions{Endpoint: "http://localhost:4318/v1/traces", SampleRatio: 0})
+	r.NoError(err)
+	SetTracer(tracer)
+	defer SetTracer(nil)
+
+	// Then the new traces should be propagated but not sampled
+	_, span := StartSpan(context.Background(), "operation", SpanKindInternal)
+	sc := span.span.SpanContext()
+	r.True(sc.IsValid())
+	r.False(sc.IsSampled())
+
+	// And the sampled traces of the callers should be continued
+	ctx := propagation.TraceContext{}.Extract(context.Background(), propagation.HeaderCarrier{
+		"Traceparent": []string{"00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01"},
+	})
+	_, span = StartSpan(ctx, "request", SpanKindServer)
+	sc = span.span.SpanContext()
+	r.True(sc.IsSampled())
+	r.Equal("4bf92f3577b34da6a3ce929d0e0e4736", sc.TraceID().String())
+}